@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// FileCollector re-reads the legacy status file on every scrape, so the
+// metrics are as fresh as the file and nothing is cached between scrapes
+// except the last good parse.
+//
+// It is an "unchecked" collector (Describe sends nothing): the metric names
+// depend on what the legacy app writes, so they can't be declared up front.
+type FileCollector struct {
+	Path   string
+	Rules  Rules
+	Prefix string
+	// StaleAfter drops the legacy metrics once the file hasn't been updated
+	// for this long. Exporting a frozen value as if it were current is worse
+	// than exporting nothing: alerts on absent() or on the stale gauge fire,
+	// alerts on the value don't.
+	StaleAfter time.Duration
+	// TimestampKey names the key holding the app's own update time (unix
+	// seconds). Without it the file's mtime is used.
+	TimestampKey string
+
+	mu       sync.Mutex
+	lastGood []sample
+}
+
+type sample struct {
+	key   string
+	value string
+}
+
+var (
+	fileAgeDesc = prometheus.NewDesc("adapter_status_file_age_seconds",
+		"Seconds since the legacy app last updated its status file.", nil, nil)
+	fileStaleDesc = prometheus.NewDesc("adapter_status_file_stale",
+		"1 if the status file is older than STALE_AFTER and its metrics are withheld.", nil, nil)
+)
+
+func (c *FileCollector) Describe(chan<- *prometheus.Desc) {}
+
+func (c *FileCollector) Collect(ch chan<- prometheus.Metric) {
+	samples, updated, err := c.read()
+	if err != nil {
+		if !os.IsNotExist(err) {
+			fmt.Printf("Error reading %s: %s\n", c.Path, err)
+		}
+		samplesTotal.WithLabelValues("file", "error").Inc()
+		return
+	}
+
+	age := time.Since(updated)
+	stale := c.StaleAfter > 0 && age > c.StaleAfter
+	ch <- prometheus.MustNewConstMetric(fileAgeDesc, prometheus.GaugeValue, age.Seconds())
+	ch <- prometheus.MustNewConstMetric(fileStaleDesc, prometheus.GaugeValue, boolFloat(stale))
+	if stale {
+		return
+	}
+
+	seen := map[string]bool{}
+	for _, s := range samples {
+		m, err := c.convert(s)
+		if err != nil {
+			fmt.Printf("Skipping %s: %s\n", s.key, err)
+			samplesTotal.WithLabelValues("file", "error").Inc()
+			continue
+		}
+		if m == nil {
+			samplesTotal.WithLabelValues("file", "dropped").Inc()
+			continue
+		}
+		// Two keys mapped to one name would make the whole scrape fail.
+		name := m.Desc().String()
+		if seen[name] {
+			samplesTotal.WithLabelValues("file", "dropped").Inc()
+			continue
+		}
+		seen[name] = true
+		samplesTotal.WithLabelValues("file", "ok").Inc()
+		ch <- m
+	}
+}
+
+// read parses the file into samples and works out when it was last updated.
+// The legacy app rewrites the file in place (truncate, then write), so a
+// scrape can land between the two and see an empty or half-written file. In
+// that case the previous parse is served instead.
+func (c *FileCollector) read() ([]sample, time.Time, error) {
+	info, err := os.Stat(c.Path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	data, err := os.ReadFile(c.Path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	samples := parseStatus(data)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	complete := len(samples) > 0 && bytes.HasSuffix(data, []byte("\n"))
+	if c.TimestampKey != "" {
+		complete = complete && lookup(samples, c.TimestampKey) != ""
+	}
+	if complete {
+		c.lastGood = samples
+	} else if c.lastGood != nil {
+		samples = c.lastGood
+	}
+
+	updated := info.ModTime()
+	if ts, err := strconv.ParseFloat(lookup(samples, c.TimestampKey), 64); err == nil {
+		updated = time.Unix(0, int64(ts*float64(time.Second)))
+	}
+	return samples, updated, nil
+}
+
+// parseStatus reads "Key: value" lines. Anything else is ignored.
+func parseStatus(data []byte) []sample {
+	var samples []sample
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok || key == "" || value == "" {
+			continue
+		}
+		samples = append(samples, sample{key: key, value: value})
+	}
+	return samples
+}
+
+func lookup(samples []sample, key string) string {
+	for _, s := range samples {
+		if s.key == key {
+			return s.value
+		}
+	}
+	return ""
+}
+
+// convert turns one sample into a metric, or nil for a dropped key. Numeric
+// values are scaled into base units (MB -> bytes, percent -> ratio). Text
+// values become a "state" metric: {state="OK"} 1, which PromQL can alert on
+// without string matching on the value.
+func (c *FileCollector) convert(s sample) (prometheus.Metric, error) {
+	value, err := strconv.ParseFloat(s.value, 64)
+	numeric := err == nil
+	rule := c.Rules.For(s.key, c.Prefix, numeric)
+
+	switch rule.Type {
+	case "drop":
+		return nil, nil
+	case "state":
+		desc := prometheus.NewDesc(rule.Name, rule.Help, []string{"state"}, nil)
+		return prometheus.NewConstMetric(desc, prometheus.GaugeValue, 1, s.value)
+	case "gauge", "counter":
+		if !numeric {
+			return nil, fmt.Errorf("%q is not a number", s.value)
+		}
+		valueType := prometheus.GaugeValue
+		if rule.Type == "counter" {
+			valueType = prometheus.CounterValue
+		}
+		desc := prometheus.NewDesc(rule.Name, rule.Help, nil, nil)
+		return prometheus.NewConstMetric(desc, valueType, value*rule.Scale)
+	}
+	return nil, fmt.Errorf("unknown type %q", rule.Type)
+}
+
+func boolFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
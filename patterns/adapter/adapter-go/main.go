@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func getEnvInt(key string, fallback int) int {
+	if value, ok := os.LookupEnv(key); ok {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+		fmt.Printf("Invalid %s=%q, using default %d\n", key, value, fallback)
+	}
+	return fallback
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if value, ok := os.LookupEnv(key); ok {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+		fmt.Printf("Invalid %s=%q, using default %s\n", key, value, fallback)
+	}
+	return fallback
+}
+
+// parseBuckets reads histogram bounds in seconds, e.g. "0.01,0.05,0.1".
+func parseBuckets(s string) ([]float64, error) {
+	var buckets []float64
+	for _, f := range strings.Split(s, ",") {
+		b, err := strconv.ParseFloat(strings.TrimSpace(f), 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad bucket %q", f)
+		}
+		if len(buckets) > 0 && b <= buckets[len(buckets)-1] {
+			return nil, fmt.Errorf("buckets must increase, got %s after %g", f, buckets[len(buckets)-1])
+		}
+		buckets = append(buckets, b)
+	}
+	return buckets, nil
+}
+
+func main() {
+	statusFile := getEnv("STATUS_FILE", "/var/log/app/status.txt")
+	statsSocket := getEnv("STATS_SOCKET", "")
+	statsUDP := getEnv("STATS_UDP_ADDR", "")
+	listenAddr := getEnv("LISTEN_ADDR", ":8080")
+	prefix := getEnv("METRIC_PREFIX", "legacy_")
+
+	rules, err := LoadRules(getEnv("MAPPING_FILE", ""))
+	if err != nil {
+		fmt.Printf("Error loading mapping rules: %s\n", err)
+		os.Exit(1)
+	}
+	buckets, err := parseBuckets(getEnv("TIMER_BUCKETS", "0.005,0.01,0.025,0.05,0.1,0.25,0.5,1,2.5,5"))
+	if err != nil {
+		fmt.Printf("Error parsing TIMER_BUCKETS: %s\n", err)
+		os.Exit(1)
+	}
+
+	// Both sources are optional: an app that only writes a file, only sends
+	// stats, or both.
+	if statusFile != "" {
+		prometheus.MustRegister(&FileCollector{
+			Path:         statusFile,
+			Rules:        rules,
+			Prefix:       prefix,
+			StaleAfter:   getEnvDuration("STALE_AFTER", 30*time.Second),
+			TimestampKey: getEnv("TIMESTAMP_KEY", "LastUpdate"),
+		})
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	if statsSocket != "" || statsUDP != "" {
+		sink := &StatSink{
+			Rules:     rules,
+			Prefix:    prefix,
+			Buckets:   buckets,
+			MaxSeries: getEnvInt("MAX_SERIES", 1000),
+		}
+		prometheus.MustRegister(sink)
+		conn, err := ListenStats(statsSocket, statsUDP)
+		if err != nil {
+			fmt.Printf("Error opening the stats socket: %s\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Receiving stats on %s\n", conn.LocalAddr())
+		go ServeStats(ctx, conn, sink)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	// Healthy means "serving", not "the legacy app is fine": restarting the
+	// adapter never fixes a stale status file.
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) { w.Write([]byte("ok")) })
+	server := &http.Server{Addr: listenAddr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Printf("Translating %q (%d mapping rules) to Prometheus metrics on %s/metrics\n", statusFile, len(rules), listenAddr)
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		fmt.Printf("Error starting server: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("Shutting down...")
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Rule says how one legacy key becomes one Prometheus metric. Keys without a
+// rule are still exported, under an automatic name, so a new key in the
+// legacy output shows up instead of silently disappearing.
+type Rule struct {
+	Name  string
+	Type  string // gauge, counter, state or drop (file source only)
+	Scale float64
+	Help  string
+}
+
+// Rules maps legacy keys (file keys and socket metric names) to rules.
+type Rules map[string]Rule
+
+// LoadRules reads a mapping file: one rule per line, '#' starts a comment.
+//
+//	# key        name                        type   scale    help...
+//	MemUsageMB   legacy_memory_usage_bytes   gauge  1048576  Memory used by the legacy app.
+func LoadRules(path string) (Rules, error) {
+	rules := Rules{}
+	if path == "" {
+		return rules, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			return nil, fmt.Errorf("%s:%d: want 'key name type scale [help]', got %q", path, n, line)
+		}
+		scale, err := strconv.ParseFloat(fields[3], 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: bad scale %q", path, n, fields[3])
+		}
+		switch fields[2] {
+		case "gauge", "counter", "state", "drop":
+		default:
+			return nil, fmt.Errorf("%s:%d: unknown type %q", path, n, fields[2])
+		}
+		if !validName(fields[1]) {
+			return nil, fmt.Errorf("%s:%d: %q is not a valid metric name", path, n, fields[1])
+		}
+		rules[fields[0]] = Rule{
+			Name:  fields[1],
+			Type:  fields[2],
+			Scale: scale,
+			Help:  strings.Join(fields[4:], " "),
+		}
+	}
+	return rules, scanner.Err()
+}
+
+// For returns the rule for key, or the automatic one: prefix + snake_case key,
+// a gauge if the value is numeric and a state otherwise.
+func (r Rules) For(key, prefix string, numeric bool) Rule {
+	if rule, ok := r[key]; ok {
+		return rule
+	}
+	rule := Rule{Name: prefix + snakeCase(key), Type: "gauge", Scale: 1, Help: fmt.Sprintf("Legacy key %s (no mapping rule).", key)}
+	if !numeric {
+		rule.Type = "state"
+	}
+	return rule
+}
+
+// snakeCase turns "MemUsageMB", "CPU_Load" and "http.request.duration" into
+// "mem_usage_mb", "cpu_load" and "http_request_duration".
+func snakeCase(s string) string {
+	var b strings.Builder
+	runes := []rune(s)
+	for i, r := range runes {
+		switch {
+		case unicode.IsUpper(r):
+			// A new word starts at an upper-case letter after a lower-case
+			// one (memUsage) or before one (MBValue -> mb_value).
+			if i > 0 && b.Len() > 0 && !strings.HasSuffix(b.String(), "_") &&
+				(unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) ||
+					(i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+				b.WriteByte('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+		case unicode.IsLower(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		default:
+			if b.Len() > 0 && !strings.HasSuffix(b.String(), "_") {
+				b.WriteByte('_')
+			}
+		}
+	}
+	return strings.Trim(b.String(), "_")
+}
+
+// validName reports whether s is usable as a metric or label name.
+func validName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		if r == '_' || r == ':' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (i > 0 && r >= '0' && r <= '9') {
+			continue
+		}
+		return false
+	}
+	return true
+}
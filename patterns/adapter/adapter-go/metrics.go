@@ -0,0 +1,20 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// The adapter's own metrics, registered with the default registry via
+// 'promauto' and served next to the translated ones.
+var (
+	samplesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "adapter_samples_total",
+		Help: "Legacy samples handled, by source (file, socket) and result (ok, dropped, error). File samples count once per scrape.",
+	}, []string{"source", "result"})
+
+	seriesGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "adapter_socket_series",
+		Help: "Series currently held for socket stats (never above MAX_SERIES).",
+	})
+)
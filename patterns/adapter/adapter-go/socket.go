@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Stat is one line of the legacy app's socket output, StatsD style with
+// DogStatsD tags:
+//
+//	http.requests:1|c|#path:/cart,code:200
+//	http.request.duration:231|ms|#path:/cart
+//	queue.depth:17|g
+//	cache.misses:1|c|@0.1
+type Stat struct {
+	Name  string
+	Value float64
+	Type  string // c, g or ms
+	Rate  float64
+	Tags  map[string]string
+}
+
+// ParseStat parses one line. The sample rate only applies to counters: a
+// counter sampled at @0.1 stands for ten events.
+func ParseStat(line string) (Stat, error) {
+	s := Stat{Rate: 1}
+	// Tag values become label values, which Prometheus refuses unless
+	// they are UTF-8.
+	if !utf8.ValidString(line) {
+		return s, fmt.Errorf("invalid UTF-8 in %q", line)
+	}
+	parts := strings.Split(line, "|")
+	if len(parts) < 2 {
+		return s, fmt.Errorf("want name:value|type, got %q", line)
+	}
+	name, value, ok := strings.Cut(parts[0], ":")
+	if !ok || name == "" {
+		return s, fmt.Errorf("want name:value, got %q", parts[0])
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return s, fmt.Errorf("bad value %q", value)
+	}
+	s.Name, s.Value, s.Type = name, v, parts[1]
+	switch s.Type {
+	case "c", "g", "ms":
+	default:
+		return s, fmt.Errorf("unsupported type %q", s.Type)
+	}
+
+	for _, p := range parts[2:] {
+		switch {
+		case strings.HasPrefix(p, "@"):
+			rate, err := strconv.ParseFloat(p[1:], 64)
+			if err != nil || rate <= 0 || rate > 1 {
+				return s, fmt.Errorf("bad sample rate %q", p)
+			}
+			s.Rate = rate
+		case strings.HasPrefix(p, "#"):
+			s.Tags = map[string]string{}
+			for _, tag := range strings.Split(p[1:], ",") {
+				k, v, _ := strings.Cut(tag, ":")
+				if k = snakeCase(k); k != "" {
+					s.Tags[k] = v
+				}
+			}
+		}
+	}
+	return s, nil
+}
+
+// StatSink turns stats into Prometheus series and holds them between
+// scrapes: unlike the status file, socket output is a stream of events, so
+// counters and histograms have to be accumulated here.
+type StatSink struct {
+	Rules     Rules
+	Prefix    string
+	Buckets   []float64
+	MaxSeries int
+
+	mu       sync.Mutex
+	families map[string]*family
+	series   int
+}
+
+type family struct {
+	desc      *prometheus.Desc
+	kind      string // c, g or ms
+	labelKeys []string
+	series    map[string]*series
+}
+
+type series struct {
+	labels  []string
+	value   float64
+	count   uint64
+	sum     float64
+	buckets []uint64
+}
+
+func (s *StatSink) Describe(chan<- *prometheus.Desc) {}
+
+// Collect sends every series. One Prometheus refuses is skipped, counted as
+// an error and forgotten, as convert's errors are for the status file: a
+// panic here would take /metrics down for everything else.
+func (s *StatSink) Collect(ch chan<- prometheus.Metric) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name, f := range s.families {
+		for id, sr := range f.series {
+			var m prometheus.Metric
+			var err error
+			switch f.kind {
+			case "c":
+				m, err = prometheus.NewConstMetric(f.desc, prometheus.CounterValue, sr.value, sr.labels...)
+			case "g":
+				m, err = prometheus.NewConstMetric(f.desc, prometheus.GaugeValue, sr.value, sr.labels...)
+			case "ms":
+				// ConstHistogram wants cumulative counts per upper bound.
+				cumulative := make(map[float64]uint64, len(s.Buckets))
+				var total uint64
+				for i, bound := range s.Buckets {
+					total += sr.buckets[i]
+					cumulative[bound] = total
+				}
+				m, err = prometheus.NewConstHistogram(f.desc, sr.count, sr.sum, cumulative, sr.labels...)
+			}
+			if err != nil {
+				fmt.Printf("Skipping %s%q: %s\n", name, sr.labels, err)
+				samplesTotal.WithLabelValues("socket", "error").Inc()
+				delete(f.series, id)
+				s.series--
+				seriesGauge.Set(float64(s.series))
+				continue
+			}
+			ch <- m
+		}
+	}
+}
+
+// Add records one stat. The family (name, type and tag keys) is fixed by the
+// first stat seen for a name; a later stat with another type or other tag
+// keys is rejected, as Prometheus would reject it at scrape time.
+func (s *StatSink) Add(st Stat) error {
+	rule := s.Rules.For(st.Name, s.Prefix, true)
+	if rule.Type == "drop" {
+		return errDropped
+	}
+	name, scale := rule.Name, rule.Scale
+	if _, mapped := s.Rules[st.Name]; !mapped {
+		// Automatic names follow Prometheus conventions: counters end in
+		// _total, and timers are exported in seconds.
+		switch st.Type {
+		case "c":
+			name += "_total"
+		case "ms":
+			name += "_seconds"
+			scale = 0.001
+		}
+	}
+
+	keys := make([]string, 0, len(st.Tags))
+	for k := range st.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.families == nil {
+		s.families = map[string]*family{}
+	}
+	f := s.families[name]
+	if f == nil {
+		f = &family{
+			desc:      prometheus.NewDesc(name, rule.Help, keys, nil),
+			kind:      st.Type,
+			labelKeys: keys,
+			series:    map[string]*series{},
+		}
+		s.families[name] = f
+	}
+	if f.kind != st.Type || strings.Join(f.labelKeys, ",") != strings.Join(keys, ",") {
+		return fmt.Errorf("%s: type %s with tags %v conflicts with the first one seen (type %s, tags %v)",
+			name, st.Type, keys, f.kind, f.labelKeys)
+	}
+
+	values := make([]string, len(keys))
+	for i, k := range keys {
+		values[i] = st.Tags[k]
+	}
+	id := strings.Join(values, "\xff")
+	sr := f.series[id]
+	if sr == nil {
+		// A tag carrying a user ID or a raw URL would create a series per
+		// value and eventually take Prometheus down with it.
+		if s.MaxSeries > 0 && s.series >= s.MaxSeries {
+			return errTooManySeries
+		}
+		sr = &series{labels: values, buckets: make([]uint64, len(s.Buckets))}
+		f.series[id] = sr
+		s.series++
+		seriesGauge.Set(float64(s.series))
+	}
+
+	v := st.Value * scale
+	switch st.Type {
+	case "c":
+		sr.value += v / st.Rate
+	case "g":
+		sr.value = v
+	case "ms":
+		sr.count++
+		sr.sum += v
+		for i, bound := range s.Buckets {
+			if v <= bound {
+				sr.buckets[i]++
+				break
+			}
+		}
+	}
+	return nil
+}
+
+var (
+	errDropped       = errors.New("dropped by mapping rule")
+	errTooManySeries = errors.New("MAX_SERIES reached")
+)
+
+// ListenStats opens the datagram socket the legacy app sends to: a Unix
+// socket in a shared emptyDir when path is set, UDP otherwise.
+func ListenStats(path, udpAddr string) (net.PacketConn, error) {
+	if path == "" {
+		return net.ListenPacket("udp", udpAddr)
+	}
+	// A socket file left over from a previous container run would make
+	// bind() fail with "address already in use".
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	conn, err := net.ListenPacket("unixgram", path)
+	if err != nil {
+		return nil, err
+	}
+	// The legacy app may run as a different UID than we do.
+	if err := os.Chmod(path, 0o666); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// ServeStats reads datagrams until ctx is done. One datagram may carry
+// several newline-separated lines.
+func ServeStats(ctx context.Context, conn net.PacketConn, sink *StatSink) {
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	buf := make([]byte, 64*1024)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() == nil {
+				fmt.Printf("Error reading stats socket: %s\n", err)
+			}
+			return
+		}
+		for _, line := range strings.Split(string(buf[:n]), "\n") {
+			if line = strings.TrimSpace(line); line == "" {
+				continue
+			}
+			st, err := ParseStat(line)
+			if err == nil {
+				err = sink.Add(st)
+			}
+			switch {
+			case err == nil:
+				samplesTotal.WithLabelValues("socket", "ok").Inc()
+			case errors.Is(err, errDropped), errors.Is(err, errTooManySeries):
+				samplesTotal.WithLabelValues("socket", "dropped").Inc()
+			default:
+				fmt.Printf("Skipping %q: %s\n", line, err)
+				samplesTotal.WithLabelValues("socket", "error").Inc()
+			}
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if value, ok := os.LookupEnv(key); ok {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+		fmt.Printf("Invalid %s=%q, using default %s\n", key, value, fallback)
+	}
+	return fallback
+}
+
+// writeStatus rewrites the status file the way the legacy app always has:
+// truncate, then write line by line. It is deliberately not atomic, so a
+// reader can see a half-written file; the adapter has to cope.
+func writeStatus(path string, served int) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fmt.Fprintf(f, "SystemStatus: %s\n", []string{"OK", "OK", "OK", "DEGRADED"}[rand.Intn(4)])
+	fmt.Fprintf(f, "MemUsageMB: %d\n", 256+rand.Intn(768))
+	fmt.Fprintf(f, "CPU_Load: %d\n", 10+rand.Intn(80))
+	fmt.Fprintf(f, "RequestsServed: %d\n", served)
+	fmt.Fprintf(f, "BuildVersion: 4.2.1-legacy\n")
+	_, err = fmt.Fprintf(f, "LastUpdate: %s\n", strconv.FormatFloat(float64(time.Now().UnixNano())/1e9, 'f', 3, 64))
+	return err
+}
+
+// statsClient sends StatsD lines over a Unix datagram socket. Like any StatsD
+// client it is fire-and-forget: if nobody is listening, the line is lost and
+// the app carries on.
+type statsClient struct {
+	path string
+	conn net.Conn
+}
+
+func (c *statsClient) send(line string) {
+	if c.path == "" {
+		return
+	}
+	if c.conn == nil {
+		conn, err := net.Dial("unixgram", c.path)
+		if err != nil {
+			return
+		}
+		c.conn = conn
+	}
+	if _, err := c.conn.Write([]byte(line)); err != nil {
+		// The adapter restarted and recreated its socket: dial again next time.
+		c.conn.Close()
+		c.conn = nil
+	}
+}
+
+func main() {
+	statusFile := getEnv("STATUS_FILE", "/var/log/app/status.txt")
+	interval := getEnvDuration("STATUS_INTERVAL", 5*time.Second)
+	stats := &statsClient{path: getEnv("STATS_SOCKET", "")}
+	fmt.Printf("Legacy app starting... writing to %s, stats to %q\n", statusFile, stats.path)
+
+	paths := []string{"/", "/cart", "/checkout", "/search"}
+	served := 0
+	lastStatus := time.Time{}
+	for {
+		// Simulate a request.
+		path := paths[rand.Intn(len(paths))]
+		code := 200
+		if rand.Intn(20) == 0 {
+			code = 500
+		}
+		took := 5 + rand.ExpFloat64()*40
+		served++
+		stats.send(fmt.Sprintf("http.requests:1|c|#path:%s,code:%d", path, code))
+		stats.send(fmt.Sprintf("http.request.duration:%.1f|ms|#path:%s", took, path))
+		if rand.Intn(10) == 0 {
+			// Sampled: one line stands for ten cache misses.
+			stats.send("cache.misses:1|c|@0.1")
+		}
+
+		if time.Since(lastStatus) >= interval {
+			stats.send(fmt.Sprintf("queue.depth:%d|g", rand.Intn(50)))
+			if err := writeStatus(statusFile, served); err != nil {
+				fmt.Printf("Error writing status: %s\n", err)
+			}
+			fmt.Printf("Updated status: %d requests served\n", served)
+			lastStatus = time.Now()
+		}
+		time.Sleep(time.Duration(took) * time.Millisecond)
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/yaml"
+)
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+// inClusterClient returns a client authenticated as the Pod's
+// ServiceAccount; see manifests/rbac.yaml for what it may do.
+func inClusterClient() (kubernetes.Interface, error) {
+	cfg, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(cfg)
+}
+
+// sidecarConfig is the SIDECAR_CONFIG file: the container to inject, and
+// the volumes it mounts, in the Pod spec's own YAML.
+type sidecarConfig struct {
+	Container corev1.Container `json:"container"`
+	Volumes   []corev1.Volume  `json:"volumes"`
+}
+
+// parseLabels reads STANDARD_LABELS, "key=value,key=value".
+func parseLabels(s string) (map[string]string, error) {
+	out := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("%q is not key=value", pair)
+		}
+		out[k] = v
+	}
+	return out, nil
+}
+
+func loadInjector() (*Injector, error) {
+	path := getEnv("SIDECAR_CONFIG", "/etc/webhook/sidecar.yaml")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg sidecarConfig
+	if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if cfg.Container.Name == "" || cfg.Container.Image == "" {
+		return nil, fmt.Errorf("%s: the container needs a name and an image", path)
+	}
+	selector, err := labels.Parse(getEnv("INJECT_SELECTOR", ""))
+	if err != nil {
+		return nil, fmt.Errorf("INJECT_SELECTOR: %w", err)
+	}
+	std, err := parseLabels(getEnv("STANDARD_LABELS", "app.kubernetes.io/managed-by=kubernetes"))
+	if err != nil {
+		return nil, fmt.Errorf("STANDARD_LABELS: %w", err)
+	}
+	mode := getEnv("SIDECAR_MODE", "native")
+	if mode != "native" && mode != "container" {
+		return nil, fmt.Errorf("SIDECAR_MODE must be native or container, got %q", mode)
+	}
+	return &Injector{
+		Sidecar:  cfg.Container,
+		Volumes:  cfg.Volumes,
+		Native:   mode == "native",
+		Labels:   std,
+		Selector: selector,
+	}, nil
+}
+
+// tlsConfig picks the serving certificate according to TLS_MODE.
+func tlsConfig(ctx context.Context) (*tls.Config, error) {
+	switch mode := getEnv("TLS_MODE", "bootstrap"); mode {
+	case "files":
+		dir := getEnv("TLS_DIR", "/etc/webhook/tls")
+		fc := &fileCertificate{certFile: dir + "/tls.crt", keyFile: dir + "/tls.key"}
+		if _, err := fc.GetCertificate(nil); err != nil {
+			return nil, err
+		}
+		return &tls.Config{MinVersion: tls.VersionTLS12, GetCertificate: fc.GetCertificate}, nil
+	case "bootstrap":
+		client, err := inClusterClient()
+		if err != nil {
+			return nil, err
+		}
+		b := &Bootstrap{
+			Client:        client,
+			Namespace:     getEnv("POD_NAMESPACE", "default"),
+			Secret:        getEnv("TLS_SECRET", "admission-webhook-tls"),
+			Service:       getEnv("SERVICE_NAME", "admission-webhook"),
+			WebhookConfig: getEnv("WEBHOOK_CONFIG", "sidecar-injector"),
+		}
+		cert, ca, err := b.Certificate(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("TLS bootstrap: %w", err)
+		}
+		if err := b.InjectCABundle(ctx, ca); err != nil {
+			// Serve anyway: with failurePolicy Ignore, Pods are still
+			// created, just without the sidecar, until the CA is in place.
+			fmt.Printf("Error: can't inject the CA into %s: %s\n", b.WebhookConfig, err)
+		}
+		return &tls.Config{MinVersion: tls.VersionTLS12, Certificates: []tls.Certificate{cert}}, nil
+	default:
+		return nil, fmt.Errorf("TLS_MODE must be bootstrap or files, got %q", mode)
+	}
+}
+
+func main() {
+	listenAddr := getEnv("LISTEN_ADDR", ":8443")
+	metricsAddr := getEnv("METRICS_ADDR", ":9090")
+
+	injector, err := loadInjector()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+	failurePolicy := getEnv("FAILURE_POLICY", "Ignore")
+	if failurePolicy != "Ignore" && failurePolicy != "Fail" {
+		fmt.Printf("Error: FAILURE_POLICY must be Ignore or Fail, got %q\n", failurePolicy)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	tlsCfg, err := tlsConfig(ctx)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("POST /mutate", &Handler{Injector: injector, FailOpen: failurePolicy == "Ignore"})
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) { w.Write([]byte("ok")) })
+	server := &http.Server{
+		Addr:      listenAddr,
+		Handler:   mux,
+		TLSConfig: tlsCfg,
+		// The API server gives up after the webhook's timeoutSeconds anyway.
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	metricsMux := http.NewServeMux()
+	metricsMux.Handle("GET /metrics", promhttp.Handler())
+	metrics := &http.Server{Addr: metricsAddr, Handler: metricsMux}
+	go func() {
+		if err := metrics.ListenAndServe(); err != http.ErrServerClosed {
+			fmt.Printf("Error starting metrics server: %s\n", err)
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		metrics.Shutdown(shutdownCtx)
+		server.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Printf("Listening on %s (TLS), injecting %s (%s) into Pods matching %q, failurePolicy %s\n",
+		listenAddr, injector.Sidecar.Name, getEnv("SIDECAR_MODE", "native"), injector.Selector.String(), failurePolicy)
+	// Certificates come from TLSConfig, so no file names here.
+	if err := server.ListenAndServeTLS("", ""); err != http.ErrServerClosed {
+		fmt.Printf("Error starting server: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("Shutting down...")
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Metrics are registered with the default registry via 'promauto', and
+// served on the plain-HTTP METRICS_ADDR, apart from the TLS admission port.
+var (
+	requestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "webhook_admission_requests_total",
+		Help: "AdmissionReviews answered, by result (patched, skipped, error).",
+	}, []string{"result"})
+
+	// The API server waits for this on every Pod creation: keep it far
+	// below the webhook's timeoutSeconds.
+	requestDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "webhook_admission_duration_seconds",
+		Help:    "Time to answer an AdmissionReview.",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, 1},
+	})
+)
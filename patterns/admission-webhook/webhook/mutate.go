@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Handler serves POST /mutate: one AdmissionReview in, the same
+// AdmissionReview with a response out.
+type Handler struct {
+	Injector *Injector
+	// FailOpen mirrors the webhook's failurePolicy: Ignore. When the
+	// webhook can't work out a patch, it admits the Pod unchanged (with a
+	// warning), just as the API server would if the webhook were down.
+	FailOpen bool
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+		http.Error(w, "want Content-Type application/json, got "+ct, http.StatusUnsupportedMediaType)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 3<<20))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var review admissionv1.AdmissionReview
+	if err := json.Unmarshal(body, &review); err != nil || review.Request == nil {
+		http.Error(w, fmt.Sprintf("not an AdmissionReview: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	resp, result := h.review(review.Request)
+	resp.UID = review.Request.UID // the API server matches the answer by UID
+	review.Response = resp
+	review.Request = nil // not needed in the reply; keeps it small
+
+	requestsTotal.WithLabelValues(result).Inc()
+	requestDuration.Observe(time.Since(start).Seconds())
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(review)
+}
+
+// review decides on one request. It returns the response and a result
+// label for metrics: patched, skipped or error.
+func (h *Handler) review(req *admissionv1.AdmissionRequest) (*admissionv1.AdmissionResponse, string) {
+	allow := &admissionv1.AdmissionResponse{Allowed: true}
+	if req.Kind.Group != "" || req.Kind.Kind != "Pod" || req.Operation != admissionv1.Create {
+		// The webhook configuration only sends Pod CREATEs; anything else
+		// is a misconfiguration, and passes through untouched.
+		return allow, "skipped"
+	}
+
+	var pod corev1.Pod
+	if err := json.Unmarshal(req.Object.Raw, &pod); err != nil {
+		return h.failure(fmt.Errorf("decoding the Pod: %w", err)), "error"
+	}
+	// Pods from a controller have no name yet, only generateName.
+	name := pod.Name
+	if name == "" {
+		name = pod.GenerateName + "<generated>"
+	}
+	dryRun := ""
+	if req.DryRun != nil && *req.DryRun {
+		dryRun = " (dry run)"
+	}
+
+	ops, reason := h.Injector.Mutate(&pod)
+	if len(ops) == 0 {
+		fmt.Printf("%s/%s: unchanged, %s%s\n", req.Namespace, name, reason, dryRun)
+		return allow, "skipped"
+	}
+	patch, err := json.Marshal(ops)
+	if err != nil {
+		return h.failure(fmt.Errorf("encoding the patch: %w", err)), "error"
+	}
+	patchType := admissionv1.PatchTypeJSONPatch
+	allow.Patch, allow.PatchType = patch, &patchType
+	what := "labels only"
+	if reason == "" {
+		what = "sidecar " + h.Injector.Sidecar.Name
+	}
+	fmt.Printf("%s/%s: patched (%d ops, %s)%s\n", req.Namespace, name, len(ops), what, dryRun)
+	return allow, "patched"
+}
+
+func (h *Handler) failure(err error) *admissionv1.AdmissionResponse {
+	fmt.Printf("Error: %s\n", err)
+	if h.FailOpen {
+		return &admissionv1.AdmissionResponse{
+			Allowed:  true,
+			Warnings: []string{"sidecar injection skipped: " + err.Error()},
+		}
+	}
+	return &admissionv1.AdmissionResponse{
+		Allowed: false,
+		Result:  &metav1.Status{Code: http.StatusInternalServerError, Message: "sidecar injection failed: " + err.Error()},
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"maps"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	// annotationInject = "false" on a Pod opts it out.
+	annotationInject = "sidecar.mydomain.com/inject"
+	// annotationStatus marks a Pod as injected, with the sidecar's image.
+	annotationStatus = "sidecar.mydomain.com/status"
+)
+
+// PatchOp is one RFC 6902 JSON Patch operation. The webhook only ever
+// adds: it never replaces or removes what the Pod's author wrote.
+type PatchOp struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	Value any    `json:"value,omitempty"`
+}
+
+// escape makes a map key safe as a JSON Pointer token (RFC 6901): label
+// keys like app.kubernetes.io/name contain a "/", which would otherwise
+// be read as a path separator.
+func escape(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
+
+// Injector decides what to add to a Pod.
+type Injector struct {
+	Sidecar  corev1.Container
+	Volumes  []corev1.Volume   // volumes the sidecar mounts
+	Native   bool              // inject as a native sidecar (initContainer, restartPolicy: Always)
+	Labels   map[string]string // standard labels, added when missing
+	Selector labels.Selector   // which Pods get the sidecar; the labels go on every Pod
+}
+
+// Mutate returns the patch for pod, and why nothing was done when the
+// patch is empty. Running it on an already injected Pod returns nothing,
+// so the API server can safely call the webhook again
+// (reinvocationPolicy: IfNeeded) after other webhooks changed the Pod.
+func (in *Injector) Mutate(pod *corev1.Pod) (ops []PatchOp, reason string) {
+	ops = in.labelOps(pod)
+
+	switch {
+	case pod.Annotations[annotationInject] == "false":
+		reason = "opted out by annotation"
+	case !in.Selector.Matches(labels.Set(pod.Labels)):
+		reason = "not selected by " + in.Selector.String()
+	case hasContainer(pod, in.Sidecar.Name):
+		reason = "sidecar already present"
+	default:
+		ops = append(ops, in.sidecarOps(pod)...)
+	}
+	if len(ops) == 0 && reason == "" {
+		reason = "nothing to change"
+	}
+	return ops, reason
+}
+
+// labelOps adds each standard label the Pod doesn't have yet, and
+// app.kubernetes.io/name from the common "app" label. Existing values are
+// never overwritten.
+func (in *Injector) labelOps(pod *corev1.Pod) []PatchOp {
+	want := map[string]string{}
+	for k, v := range in.Labels {
+		want[k] = v
+	}
+	if app, ok := pod.Labels["app"]; ok {
+		want["app.kubernetes.io/name"] = app
+	}
+	missing := map[string]string{}
+	for k, v := range want {
+		if _, ok := pod.Labels[k]; !ok {
+			missing[k] = v
+		}
+	}
+	return addToMap("/metadata/labels", pod.Labels == nil, missing)
+}
+
+func (in *Injector) sidecarOps(pod *corev1.Pod) []PatchOp {
+	var ops []PatchOp
+	sidecar := *in.Sidecar.DeepCopy()
+	if in.Native {
+		// A native sidecar goes first among the init containers, so it is
+		// up before the others run, and stays up for the app containers.
+		always := corev1.ContainerRestartPolicyAlways
+		sidecar.RestartPolicy = &always
+		if pod.Spec.InitContainers == nil {
+			ops = append(ops, PatchOp{Op: "add", Path: "/spec/initContainers", Value: []corev1.Container{sidecar}})
+		} else {
+			ops = append(ops, PatchOp{Op: "add", Path: "/spec/initContainers/0", Value: sidecar})
+		}
+	} else if pod.Spec.Containers == nil {
+		ops = append(ops, PatchOp{Op: "add", Path: "/spec/containers", Value: []corev1.Container{sidecar}})
+	} else {
+		ops = append(ops, PatchOp{Op: "add", Path: "/spec/containers/-", Value: sidecar})
+	}
+
+	var volumes []corev1.Volume
+	for _, v := range in.Volumes {
+		if !hasVolume(pod, v.Name) {
+			volumes = append(volumes, v)
+		}
+	}
+	if len(volumes) > 0 {
+		// "add" to a missing array fails, and "add" to /spec/volumes when
+		// it exists replaces it: create it whole, or append item by item.
+		if pod.Spec.Volumes == nil {
+			ops = append(ops, PatchOp{Op: "add", Path: "/spec/volumes", Value: volumes})
+		} else {
+			for _, v := range volumes {
+				ops = append(ops, PatchOp{Op: "add", Path: "/spec/volumes/-", Value: v})
+			}
+		}
+	}
+	return append(ops, addToMap("/metadata/annotations", pod.Annotations == nil,
+		map[string]string{annotationStatus: "injected " + in.Sidecar.Image})...)
+}
+
+// addToMap adds keys to a map field: in one operation creating the map when
+// it is absent, else one operation per key (which leaves other keys alone).
+func addToMap(path string, absent bool, kv map[string]string) []PatchOp {
+	if len(kv) == 0 {
+		return nil
+	}
+	if absent {
+		return []PatchOp{{Op: "add", Path: path, Value: kv}}
+	}
+	ops := make([]PatchOp, 0, len(kv))
+	for _, k := range slices.Sorted(maps.Keys(kv)) {
+		ops = append(ops, PatchOp{Op: "add", Path: path + "/" + escape(k), Value: kv[k]})
+	}
+	return ops
+}
+
+func hasContainer(pod *corev1.Pod, name string) bool {
+	for _, list := range [][]corev1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for _, c := range list {
+			if c.Name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func hasVolume(pod *corev1.Pod, name string) bool {
+	for _, v := range pod.Spec.Volumes {
+		if v.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	jsonpatch "gopkg.in/evanphx/json-patch.v4"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func testInjector(native bool) *Injector {
+	return &Injector{
+		Sidecar: corev1.Container{
+			Name:         "log-shipper",
+			Image:        "busybox:1.36",
+			VolumeMounts: []corev1.VolumeMount{{Name: "shipper-buffer", MountPath: "/buffer"}},
+		},
+		Volumes:  []corev1.Volume{{Name: "shipper-buffer", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}}},
+		Native:   native,
+		Labels:   map[string]string{"app.kubernetes.io/managed-by": "kubernetes", "example.com/team": "platform"},
+		Selector: labels.Everything(),
+	}
+}
+
+// apply runs Mutate and applies the patch to pod the way the API server
+// would, returning the patched Pod.
+func apply(t *testing.T, in *Injector, pod *corev1.Pod) (*corev1.Pod, []PatchOp) {
+	t.Helper()
+	ops, _ := in.Mutate(pod)
+	original, err := json.Marshal(pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ops) == 0 {
+		return pod.DeepCopy(), nil
+	}
+	raw, err := json.Marshal(ops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	patch, err := jsonpatch.DecodePatch(raw)
+	if err != nil {
+		t.Fatalf("decoding %s: %v", raw, err)
+	}
+	patched, err := patch.Apply(original)
+	if err != nil {
+		t.Fatalf("applying %s: %v", raw, err)
+	}
+	var out corev1.Pod
+	if err := json.Unmarshal(patched, &out); err != nil {
+		t.Fatal(err)
+	}
+	return &out, ops
+}
+
+// The paths depend on which fields the Pod already has: "add" to a
+// missing parent fails, and "add" to an existing map or array replaces it.
+func TestMutateBarePod(t *testing.T) {
+	for _, native := range []bool{true, false} {
+		pod := &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: "app:v1"}}}}
+		out, _ := apply(t, testInjector(native), pod)
+
+		if got := out.Labels["example.com/team"]; got != "platform" {
+			t.Errorf("native=%v: team label = %q", native, got)
+		}
+		if got := out.Annotations[annotationStatus]; got != "injected busybox:1.36" {
+			t.Errorf("native=%v: status annotation = %q", native, got)
+		}
+		if len(out.Spec.Volumes) != 1 || out.Spec.Volumes[0].Name != "shipper-buffer" {
+			t.Errorf("native=%v: volumes = %+v", native, out.Spec.Volumes)
+		}
+		if native {
+			if len(out.Spec.InitContainers) != 1 || out.Spec.InitContainers[0].Name != "log-shipper" {
+				t.Fatalf("init containers = %+v", out.Spec.InitContainers)
+			}
+			if rp := out.Spec.InitContainers[0].RestartPolicy; rp == nil || *rp != corev1.ContainerRestartPolicyAlways {
+				t.Errorf("native sidecar restartPolicy = %v", rp)
+			}
+			if len(out.Spec.Containers) != 1 {
+				t.Errorf("containers = %+v", out.Spec.Containers)
+			}
+		} else if len(out.Spec.Containers) != 2 || out.Spec.Containers[1].Name != "log-shipper" {
+			t.Errorf("containers = %+v", out.Spec.Containers)
+		}
+	}
+}
+
+func TestMutateExistingFields(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels:      map[string]string{"app": "shop", "example.com/team": "payments"},
+			Annotations: map[string]string{"keep": "me"},
+		},
+		Spec: corev1.PodSpec{
+			InitContainers: []corev1.Container{{Name: "migrate", Image: "app:v1"}},
+			Containers:     []corev1.Container{{Name: "app", Image: "app:v1"}},
+			Volumes:        []corev1.Volume{{Name: "data"}},
+		},
+	}
+	out, _ := apply(t, testInjector(true), pod)
+
+	want := map[string]string{
+		"app":                          "shop",
+		"example.com/team":             "payments", // never overwritten
+		"app.kubernetes.io/managed-by": "kubernetes",
+		"app.kubernetes.io/name":       "shop",
+	}
+	for k, v := range want {
+		if out.Labels[k] != v {
+			t.Errorf("label %s = %q, want %q", k, out.Labels[k], v)
+		}
+	}
+	if out.Annotations["keep"] != "me" || out.Annotations[annotationStatus] == "" {
+		t.Errorf("annotations = %v", out.Annotations)
+	}
+	if len(out.Spec.InitContainers) != 2 || out.Spec.InitContainers[0].Name != "log-shipper" {
+		t.Errorf("the native sidecar should be the first init container: %+v", out.Spec.InitContainers)
+	}
+	if len(out.Spec.Volumes) != 2 || out.Spec.Volumes[0].Name != "data" {
+		t.Errorf("volumes = %+v", out.Spec.Volumes)
+	}
+}
+
+func TestEscape(t *testing.T) {
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"x": "y"}}}
+	in := testInjector(true)
+	in.Labels = map[string]string{"example.com/a~b": "v"}
+	ops := in.labelOps(pod)
+	if len(ops) != 1 || ops[0].Path != "/metadata/labels/example.com~1a~0b" {
+		t.Fatalf("ops = %+v", ops)
+	}
+	out, _ := apply(t, in, pod)
+	if out.Labels["example.com/a~b"] != "v" {
+		t.Errorf("labels = %v", out.Labels)
+	}
+}
+
+// The API server may call the webhook again on a Pod it already patched
+// (reinvocationPolicy: IfNeeded). The second call must change nothing.
+func TestMutateIdempotent(t *testing.T) {
+	for _, native := range []bool{true, false} {
+		in := testInjector(native)
+		pod := &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}}}
+		once, _ := apply(t, in, pod)
+		if ops, reason := in.Mutate(once); len(ops) != 0 || reason != "sidecar already present" {
+			t.Errorf("native=%v: second Mutate = %+v, %q", native, ops, reason)
+		}
+	}
+}
+
+func TestMutateSkips(t *testing.T) {
+	in := testInjector(true)
+	in.Selector, _ = labels.Parse("sidecar=enabled")
+
+	optedOut := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Labels:      map[string]string{"sidecar": "enabled"},
+		Annotations: map[string]string{annotationInject: "false"},
+	}}
+	notSelected := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"sidecar": "no"}}}
+
+	for name, pod := range map[string]*corev1.Pod{"opted out": optedOut, "not selected": notSelected} {
+		out, ops := apply(t, in, pod)
+		if hasContainer(out, "log-shipper") {
+			t.Errorf("%s: sidecar injected", name)
+		}
+		// Labels go on every Pod, whether it gets the sidecar or not.
+		if len(ops) == 0 || out.Labels["example.com/team"] != "platform" {
+			t.Errorf("%s: labels = %v", name, out.Labels)
+		}
+	}
+}
+
+func review(t *testing.T, h *Handler, raw []byte) *admissionv1.AdmissionResponse {
+	t.Helper()
+	body, _ := json.Marshal(admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+		Request: &admissionv1.AdmissionRequest{
+			UID:       "42",
+			Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+			Operation: admissionv1.Create,
+			Namespace: "demo",
+			Object:    runtime.RawExtension{Raw: raw},
+		},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/mutate", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var out admissionv1.AdmissionReview
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Kind != "AdmissionReview" || out.APIVersion != "admission.k8s.io/v1" {
+		t.Errorf("reply is %s %s", out.APIVersion, out.Kind)
+	}
+	if out.Response == nil || out.Response.UID != "42" {
+		t.Fatalf("response = %+v, want UID 42", out.Response)
+	}
+	return out.Response
+}
+
+func TestHandler(t *testing.T) {
+	h := &Handler{Injector: testInjector(true)}
+	pod, _ := json.Marshal(corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}}})
+	resp := review(t, h, pod)
+	if !resp.Allowed || resp.PatchType == nil || *resp.PatchType != admissionv1.PatchTypeJSONPatch {
+		t.Fatalf("response = %+v", resp)
+	}
+	if _, err := jsonpatch.DecodePatch(resp.Patch); err != nil {
+		t.Errorf("patch %s: %v", resp.Patch, err)
+	}
+}
+
+func TestHandlerFailurePolicy(t *testing.T) {
+	broken := []byte(`{"spec": "not a spec"}`)
+
+	resp := review(t, &Handler{Injector: testInjector(true), FailOpen: true}, broken)
+	if !resp.Allowed || resp.Patch != nil || len(resp.Warnings) == 0 {
+		t.Errorf("fail open: %+v", resp)
+	}
+	resp = review(t, &Handler{Injector: testInjector(true), FailOpen: false}, broken)
+	if resp.Allowed || resp.Result == nil || resp.Result.Code != http.StatusInternalServerError {
+		t.Errorf("fail closed: %+v", resp)
+	}
+}
@@ -0,0 +1,34 @@
+// Package v1alpha1 contains API Schema definitions for the mydomain.com v1alpha1 API group.
+// +kubebuilder:object:generate=true
+// +groupName=mydomain.com
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var (
+	// GroupVersion is group version used to register these objects.
+	GroupVersion = schema.GroupVersion{Group: "mydomain.com", Version: "v1alpha1"}
+
+	// SchemeBuilder is used to add go types to the GroupVersionKind scheme.
+	SchemeBuilder = runtime.NewSchemeBuilder(addKnownTypes)
+
+	// AddToScheme adds the types in this group-version to the given scheme.
+	AddToScheme = SchemeBuilder.AddToScheme
+)
+
+// Resource takes an unqualified resource and returns a Group qualified GroupResource.
+func Resource(resource string) schema.GroupResource {
+	return GroupVersion.WithResource(resource).GroupResource()
+}
+
+func addKnownTypes(scheme *runtime.Scheme) error {
+	scheme.AddKnownTypes(GroupVersion, &Topology{}, &TopologyList{})
+	// ListOptions, GetOptions, WatchEvent... in this group-version too: the
+	// generic API server decodes query parameters with them.
+	metav1.AddToGroupVersion(scheme, GroupVersion)
+	return nil
+}
@@ -0,0 +1,61 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TopologySpec is the layout of one region: its zones, their racks, and
+// the machines in each.
+type TopologySpec struct {
+	// Region is the region's name at the provider, e.g. "eu-west-1".
+	Region string `json:"region"`
+
+	// Zones are the region's failure domains.
+	// +optional
+	Zones []Zone `json:"zones,omitempty"`
+}
+
+// Zone is a failure domain: a power feed, a network, a building.
+type Zone struct {
+	Name string `json:"name"`
+
+	// +optional
+	Racks []Rack `json:"racks,omitempty"`
+}
+
+// Rack is a set of machines behind one top-of-rack switch.
+type Rack struct {
+	Name string `json:"name"`
+
+	// Machines are the hostnames of the machines in the rack.
+	// +optional
+	Machines []string `json:"machines,omitempty"`
+}
+
+// TopologyStatus is counted from the spec by the server on every read.
+// Nothing stores it.
+type TopologyStatus struct {
+	Zones    int32 `json:"zones"`
+	Racks    int32 `json:"racks"`
+	Machines int32 `json:"machines"`
+}
+
+// Topology is the layout of one region of the fleet. It isn't stored in
+// etcd: the aggregated API server holds them in memory, and serves them
+// read-only.
+// +kubebuilder:object:root=true
+type Topology struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   TopologySpec   `json:"spec,omitempty"`
+	Status TopologyStatus `json:"status,omitempty"`
+}
+
+// TopologyList contains a list of Topology.
+// +kubebuilder:object:root=true
+type TopologyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Topology `json:"items"`
+}
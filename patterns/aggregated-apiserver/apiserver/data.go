@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"aggregated-apiserver/api/v1alpha1"
+)
+
+// continentLabel lets clients pick topologies with a label selector:
+// kubectl get topologies -l mydomain.com/continent=europe.
+const continentLabel = "mydomain.com/continent"
+
+// seedTopologies is the server's whole data set. A real server would
+// load it from an inventory system, a CMDB or a cloud API, and refresh
+// it; here it is fixed at build time.
+func seedTopologies() []v1alpha1.Topology {
+	return []v1alpha1.Topology{
+		topology("eu-west-1", "europe", map[string][]int{"eu-west-1a": {4, 4}, "eu-west-1b": {4, 3}, "eu-west-1c": {2}}),
+		topology("us-east-1", "america", map[string][]int{"us-east-1a": {8, 8, 8}, "us-east-1b": {8, 6}}),
+		topology("ap-south-1", "asia", map[string][]int{"ap-south-1a": {3}}),
+	}
+}
+
+// topology builds a region from the number of machines in each rack of
+// each zone.
+func topology(region, continent string, zones map[string][]int) v1alpha1.Topology {
+	t := v1alpha1.Topology{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   region,
+			Labels: map[string]string{continentLabel: continent},
+		},
+		Spec: v1alpha1.TopologySpec{Region: region},
+	}
+	for _, zone := range sortedKeys(zones) {
+		z := v1alpha1.Zone{Name: zone}
+		for r, machines := range zones[zone] {
+			rack := v1alpha1.Rack{Name: fmt.Sprintf("%s-r%02d", zone, r+1)}
+			for m := range machines {
+				rack.Machines = append(rack.Machines, fmt.Sprintf("%s-m%02d", rack.Name, m+1))
+			}
+			z.Racks = append(z.Racks, rack)
+		}
+		t.Spec.Zones = append(t.Spec.Zones, z)
+	}
+	return t
+}
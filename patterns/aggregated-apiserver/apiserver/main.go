@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/spf13/pflag"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	openapinamer "k8s.io/apiserver/pkg/endpoints/openapi"
+	"k8s.io/apiserver/pkg/registry/rest"
+	genericapiserver "k8s.io/apiserver/pkg/server"
+	genericoptions "k8s.io/apiserver/pkg/server/options"
+	utilversion "k8s.io/apiserver/pkg/util/version"
+	"k8s.io/component-base/logs"
+
+	"aggregated-apiserver/api/v1alpha1"
+)
+
+var (
+	// scheme knows the served types, and metav1's: Status for errors,
+	// the discovery documents, the options in query parameters.
+	scheme = runtime.NewScheme()
+	codecs = serializer.NewCodecFactory(scheme)
+)
+
+func init() {
+	utilruntime.Must(v1alpha1.AddToScheme(scheme))
+	metav1.AddToGroupVersion(scheme, schema.GroupVersion{Version: "v1"})
+	unversioned := schema.GroupVersion{Group: "", Version: "v1"}
+	scheme.AddUnversionedTypes(unversioned,
+		&metav1.Status{}, &metav1.APIVersions{}, &metav1.APIGroupList{}, &metav1.APIGroup{}, &metav1.APIResourceList{})
+}
+
+func main() {
+	logs.InitLogs()
+	defer logs.FlushLogs()
+
+	// The recommended options of an aggregated API server (--secure-port,
+	// --cert-dir, delegated authentication and authorization...), minus
+	// what a read-only server with no storage doesn't need.
+	opts := genericoptions.NewRecommendedOptions("", codecs.LegacyCodec(v1alpha1.GroupVersion))
+	opts.Etcd = nil
+	opts.Admission = nil
+	opts.CoreAPI = nil
+	// The kube-apiserver in front already applies priority and fairness.
+	opts.Features.EnablePriorityAndFairness = false
+	opts.AddFlags(pflag.CommandLine)
+	pflag.Parse()
+
+	// No certificate given: one is self-signed into --cert-dir. The
+	// APIService in manifests/apiserver.yaml skips verification to match.
+	if err := opts.SecureServing.MaybeDefaultWithSelfSignedCerts("localhost", nil, []net.IP{net.ParseIP("127.0.0.1")}); err != nil {
+		fmt.Printf("Error creating self-signed certificate: %v\n", err)
+		os.Exit(1)
+	}
+	if errs := opts.Validate(); len(errs) > 0 {
+		fmt.Printf("Invalid flags: %v\n", utilerrors.NewAggregate(errs))
+		os.Exit(2)
+	}
+
+	config := genericapiserver.NewRecommendedConfig(codecs)
+	namer := openapinamer.NewDefinitionNamer(scheme)
+	config.OpenAPIConfig = genericapiserver.DefaultOpenAPIConfig(getOpenAPIDefinitions, namer)
+	config.OpenAPIConfig.Info.Title = "topology-apiserver"
+	config.OpenAPIConfig.Info.Version = "1.0.0"
+	config.OpenAPIV3Config = genericapiserver.DefaultOpenAPIV3Config(getOpenAPIDefinitions, namer)
+	config.OpenAPIV3Config.Info.Title = "topology-apiserver"
+	config.OpenAPIV3Config.Info.Version = "1.0.0"
+	config.EffectiveVersion = utilversion.NewEffectiveVersion("1.0")
+	if err := opts.ApplyTo(config); err != nil {
+		fmt.Printf("Error applying options: %v\n", err)
+		os.Exit(1)
+	}
+
+	server, err := config.Complete().New("topology-apiserver", genericapiserver.NewEmptyDelegate())
+	if err != nil {
+		fmt.Printf("Error creating server: %v\n", err)
+		os.Exit(1)
+	}
+
+	// One group, one version, one resource. Its storage is all the server
+	// adds to the generic one.
+	topologies := seedTopologies()
+	group := genericapiserver.NewDefaultAPIGroupInfo(v1alpha1.GroupVersion.Group, scheme, metav1.ParameterCodec, codecs)
+	group.VersionedResourcesStorageMap[v1alpha1.GroupVersion.Version] = map[string]rest.Storage{
+		"topologies": newTopologyStorage(topologies, time.Now()),
+	}
+	if err := server.InstallAPIGroup(&group); err != nil {
+		fmt.Printf("Error installing API group: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Serving %d topologies as topologies.%s/%s\n", len(topologies), v1alpha1.GroupVersion.Group, v1alpha1.GroupVersion.Version)
+	if err := server.PrepareRun().RunWithContext(genericapiserver.SetupSignalContext()); err != nil {
+		fmt.Printf("Error running server: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("Shutting down...")
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"k8s.io/kube-openapi/pkg/common"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+	generatedcore "sigs.k8s.io/custom-metrics-apiserver/pkg/generated/openapi/core"
+)
+
+// The generic API server needs an OpenAPI schema for every type it serves:
+// it publishes them on /openapi/v2 and /openapi/v3, where the
+// kube-apiserver merges them into its own, for kubectl explain and
+// client-side validation.
+//
+// openapi-gen would generate these from the Go types. They are written
+// out here, for three small structs, so the pattern builds with go build
+// alone. metav1's (ObjectMeta, ListMeta, and what they refer to) come
+// generated, from custom-metrics-apiserver.
+const pkg = "aggregated-apiserver/api/v1alpha1."
+
+func getOpenAPIDefinitions(ref common.ReferenceCallback) map[string]common.OpenAPIDefinition {
+	defs := generatedcore.GetOpenAPIDefinitions(ref)
+	objectMeta := "k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta"
+	listMeta := "k8s.io/apimachinery/pkg/apis/meta/v1.ListMeta"
+
+	defs[pkg+"Topology"] = object("Topology is the layout of one region of the fleet, served read-only from memory.",
+		map[string]spec.Schema{
+			"apiVersion": str("APIVersion defines the versioned schema of this representation of an object."),
+			"kind":       str("Kind is a string value representing the REST resource this object represents."),
+			"metadata":   refTo(ref, objectMeta, "Standard object's metadata."),
+			"spec":       refTo(ref, pkg+"TopologySpec", "The region's zones, racks and machines."),
+			"status":     refTo(ref, pkg+"TopologyStatus", "Counts, computed from the spec on every read."),
+		}, nil, objectMeta, pkg+"TopologySpec", pkg+"TopologyStatus")
+
+	defs[pkg+"TopologyList"] = object("TopologyList contains a list of Topology.",
+		map[string]spec.Schema{
+			"apiVersion": str("APIVersion defines the versioned schema of this representation of an object."),
+			"kind":       str("Kind is a string value representing the REST resource this object represents."),
+			"metadata":   refTo(ref, listMeta, "Standard list metadata."),
+			"items":      arrayOf(refTo(ref, pkg+"Topology", ""), "The topologies."),
+		}, []string{"items"}, listMeta, pkg+"Topology")
+
+	defs[pkg+"TopologySpec"] = object("TopologySpec is the layout of one region.",
+		map[string]spec.Schema{
+			"region": str("Region is the region's name at the provider, e.g. \"eu-west-1\"."),
+			"zones":  arrayOf(refTo(ref, pkg+"Zone", ""), "Zones are the region's failure domains."),
+		}, []string{"region"}, pkg+"Zone")
+
+	defs[pkg+"Zone"] = object("Zone is a failure domain: a power feed, a network, a building.",
+		map[string]spec.Schema{
+			"name":  str(""),
+			"racks": arrayOf(refTo(ref, pkg+"Rack", ""), ""),
+		}, []string{"name"}, pkg+"Rack")
+
+	defs[pkg+"Rack"] = object("Rack is a set of machines behind one top-of-rack switch.",
+		map[string]spec.Schema{
+			"name":     str(""),
+			"machines": arrayOf(str(""), "Machines are the hostnames of the machines in the rack."),
+		}, []string{"name"})
+
+	defs[pkg+"TopologyStatus"] = object("TopologyStatus is counted from the spec by the server on every read.",
+		map[string]spec.Schema{
+			"zones":    integer("Zones in the region."),
+			"racks":    integer("Racks in all zones."),
+			"machines": integer("Machines in all racks."),
+		}, []string{"zones", "racks", "machines"})
+
+	return defs
+}
+
+func object(description string, properties map[string]spec.Schema, required []string, dependencies ...string) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{SchemaProps: spec.SchemaProps{
+			Description: description,
+			Type:        []string{"object"},
+			Properties:  properties,
+			Required:    required,
+		}},
+		Dependencies: dependencies,
+	}
+}
+
+func str(description string) spec.Schema {
+	return spec.Schema{SchemaProps: spec.SchemaProps{Description: description, Type: []string{"string"}, Default: ""}}
+}
+
+func integer(description string) spec.Schema {
+	return spec.Schema{SchemaProps: spec.SchemaProps{Description: description, Type: []string{"integer"}, Format: "int32", Default: 0}}
+}
+
+func refTo(ref common.ReferenceCallback, name, description string) spec.Schema {
+	return spec.Schema{SchemaProps: spec.SchemaProps{Description: description, Default: map[string]interface{}{}, Ref: ref(name)}}
+}
+
+func arrayOf(items spec.Schema, description string) spec.Schema {
+	return spec.Schema{
+		VendorExtensible: spec.VendorExtensible{Extensions: spec.Extensions{"x-kubernetes-list-type": "atomic"}},
+		SchemaProps: spec.SchemaProps{
+			Description: description,
+			Type:        []string{"array"},
+			Items:       &spec.SchemaOrArray{Schema: &items},
+		},
+	}
+}
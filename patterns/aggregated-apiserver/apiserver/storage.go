@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metainternalversion "k8s.io/apimachinery/pkg/apis/meta/internalversion"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/apiserver/pkg/registry/rest"
+
+	"aggregated-apiserver/api/v1alpha1"
+)
+
+// resourceVersion is the same for every topology, and every list: the
+// data never changes while the server runs.
+const resourceVersion = "1"
+
+// topologyStorage serves topologies from memory. It implements the rest
+// interfaces for get and list only: the generic API server answers any
+// other verb with 405 Method Not Allowed, and doesn't advertise it in
+// discovery.
+type topologyStorage struct {
+	items map[string]*v1alpha1.Topology
+}
+
+var (
+	_ rest.Storage              = &topologyStorage{}
+	_ rest.Scoper               = &topologyStorage{}
+	_ rest.SingularNameProvider = &topologyStorage{}
+	_ rest.Getter               = &topologyStorage{}
+	_ rest.Lister               = &topologyStorage{}
+)
+
+func newTopologyStorage(topologies []v1alpha1.Topology, created time.Time) *topologyStorage {
+	s := &topologyStorage{items: map[string]*v1alpha1.Topology{}}
+	for i := range topologies {
+		t := topologies[i].DeepCopy()
+		t.UID = uuid.NewUUID()
+		t.ResourceVersion = resourceVersion
+		t.CreationTimestamp = metav1.NewTime(created)
+		s.items[t.Name] = t
+	}
+	return s
+}
+
+func (s *topologyStorage) New() runtime.Object     { return &v1alpha1.Topology{} }
+func (s *topologyStorage) NewList() runtime.Object { return &v1alpha1.TopologyList{} }
+func (s *topologyStorage) Destroy()                {}
+func (s *topologyStorage) NamespaceScoped() bool   { return false }
+func (s *topologyStorage) GetSingularName() string { return "topology" }
+
+func (s *topologyStorage) Get(ctx context.Context, name string, opts *metav1.GetOptions) (runtime.Object, error) {
+	t, ok := s.items[name]
+	if !ok {
+		return nil, apierrors.NewNotFound(v1alpha1.Resource("topologies"), name)
+	}
+	return withStatus(t), nil
+}
+
+// List applies the label and field selectors itself: there is no storage
+// layer to do it. metadata.name is the only field it can select on.
+func (s *topologyStorage) List(ctx context.Context, opts *metainternalversion.ListOptions) (runtime.Object, error) {
+	labelSelector, fieldSelector := labels.Everything(), fields.Everything()
+	if opts != nil && opts.LabelSelector != nil {
+		labelSelector = opts.LabelSelector
+	}
+	if opts != nil && opts.FieldSelector != nil {
+		fieldSelector = opts.FieldSelector
+	}
+
+	list := &v1alpha1.TopologyList{ListMeta: metav1.ListMeta{ResourceVersion: resourceVersion}}
+	for _, name := range sortedKeys(s.items) {
+		t := s.items[name]
+		if labelSelector.Matches(labels.Set(t.Labels)) && fieldSelector.Matches(fields.Set{"metadata.name": t.Name}) {
+			list.Items = append(list.Items, *withStatus(t))
+		}
+	}
+	return list, nil
+}
+
+// withStatus returns a copy of t, with its status counted from its spec.
+func withStatus(t *v1alpha1.Topology) *v1alpha1.Topology {
+	t = t.DeepCopy()
+	t.Status = v1alpha1.TopologyStatus{Zones: int32(len(t.Spec.Zones))}
+	for _, zone := range t.Spec.Zones {
+		t.Status.Racks += int32(len(zone.Racks))
+		for _, rack := range zone.Racks {
+			t.Status.Machines += int32(len(rack.Machines))
+		}
+	}
+	return t
+}
+
+// ConvertToTable is what kubectl get prints: it asks the server for a
+// Table, not for the objects.
+func (s *topologyStorage) ConvertToTable(ctx context.Context, object runtime.Object, tableOptions runtime.Object) (*metav1.Table, error) {
+	table := &metav1.Table{
+		ColumnDefinitions: []metav1.TableColumnDefinition{
+			{Name: "Name", Type: "string", Format: "name", Description: "Name of the topology"},
+			{Name: "Region", Type: "string", Description: "Region at the provider"},
+			{Name: "Zones", Type: "integer", Description: "Failure domains in the region"},
+			{Name: "Racks", Type: "integer", Description: "Racks in all zones"},
+			{Name: "Machines", Type: "integer", Description: "Machines in all racks"},
+			{Name: "Age", Type: "string", Description: "Time since the server loaded the topology"},
+		},
+	}
+	var items []v1alpha1.Topology
+	switch obj := object.(type) {
+	case *v1alpha1.Topology:
+		items = []v1alpha1.Topology{*obj}
+	case *v1alpha1.TopologyList:
+		items = obj.Items
+		table.ResourceVersion = obj.ResourceVersion
+	}
+	for i := range items {
+		t := &items[i]
+		table.Rows = append(table.Rows, metav1.TableRow{
+			Cells: []interface{}{
+				t.Name, t.Spec.Region, t.Status.Zones, t.Status.Racks, t.Status.Machines,
+				duration.HumanDuration(time.Since(t.CreationTimestamp.Time)),
+			},
+			Object: runtime.RawExtension{Object: t},
+		})
+	}
+	return table, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	return slices.Sorted(maps.Keys(m))
+}
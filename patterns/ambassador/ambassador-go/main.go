@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func getEnvInt(key string, fallback int) int {
+	if value, ok := os.LookupEnv(key); ok {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+		fmt.Printf("Invalid %s=%q, using default %d\n", key, value, fallback)
+	}
+	return fallback
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if value, ok := os.LookupEnv(key); ok {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+		fmt.Printf("Invalid %s=%q, using default %s\n", key, value, fallback)
+	}
+	return fallback
+}
+
+// listen opens the app-facing listener. With a socket path it uses a Unix
+// domain socket (shared with the app through an emptyDir) instead of TCP.
+func listen(addr, socket string) (net.Listener, error) {
+	if socket == "" {
+		return net.Listen("tcp", addr)
+	}
+
+	// A socket file left over from a previous container run would make
+	// bind() fail with "address already in use".
+	if err := os.Remove(socket); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, err
+	}
+	// The app container may run as a different UID than we do.
+	if err := os.Chmod(socket, 0o666); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+func main() {
+	// MODE=http (default) proxies requests; MODE=tcp forwards raw connections
+	// to a database-style backend with a connection cap.
+	mode := getEnv("MODE", "http")
+	listenAddr := getEnv("LISTEN_ADDR", ":8080")
+	listenSocket := getEnv("LISTEN_SOCKET", "")
+	metricsAddr := getEnv("METRICS_ADDR", ":9090")
+
+	// Metrics live on their own port: everything on LISTEN_ADDR is proxied.
+	go func() {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.Handler())
+		if err := http.ListenAndServe(metricsAddr, mux); err != nil {
+			fmt.Printf("Error starting metrics server: %s\n", err)
+		}
+	}()
+
+	l, err := listen(listenAddr, listenSocket)
+	if err != nil {
+		fmt.Printf("Error opening listener: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Serving metrics on %s/metrics\n", metricsAddr)
+
+	switch mode {
+	case "tcp":
+		err = serveTCP(l)
+	case "http":
+		err = serveHTTP(l)
+	default:
+		err = fmt.Errorf("unknown MODE %q (want http or tcp)", mode)
+	}
+	if err != nil {
+		fmt.Printf("Error starting server: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func serveHTTP(l net.Listener) error {
+	// The remote service the app must never know about.
+	upstream, err := url.Parse(getEnv("UPSTREAM_URL", "http://httpbin.org"))
+	if err != nil {
+		return fmt.Errorf("invalid UPSTREAM_URL: %w", err)
+	}
+
+	proxy := &Proxy{
+		Upstream:       upstream,
+		Client:         &http.Client{},
+		DefaultTimeout: getEnvDuration("DEFAULT_TIMEOUT", 10*time.Second),
+		HedgeAfter:     getEnvDuration("HEDGE_AFTER", 0),
+		MaxAttempts:    getEnvInt("HEDGE_MAX_ATTEMPTS", 2),
+	}
+
+	fmt.Printf("Ambassador (http) listening on %s://%s -> %s (timeout %s, hedge after %s)\n",
+		l.Addr().Network(), l.Addr(), upstream, proxy.DefaultTimeout, proxy.HedgeAfter)
+
+	// MAX_INFLIGHT=0 (default) forwards everything immediately, which is the
+	// unbounded behaviour the queue exists to demonstrate against.
+	var handler http.Handler = proxy
+	if maxInflight := getEnvInt("MAX_INFLIGHT", 0); maxInflight > 0 {
+		depth := getEnvInt("QUEUE_DEPTH", 50)
+		timeout := getEnvDuration("QUEUE_TIMEOUT", 5*time.Second)
+		handler = NewQueue(proxy, maxInflight, depth, timeout)
+		fmt.Printf("Backpressure enabled: %d in flight, %d queued, queue timeout %s\n",
+			maxInflight, depth, timeout)
+	}
+	return http.Serve(l, handler)
+}
+
+func serveTCP(l net.Listener) error {
+	pool := &TCPPool{
+		UpstreamAddr: getEnv("UPSTREAM_ADDR", "localhost:5432"),
+		MaxConns:     getEnvInt("MAX_UPSTREAM_CONNS", 10),
+		QueueTimeout: getEnvDuration("QUEUE_TIMEOUT", 30*time.Second),
+		DialTimeout:  getEnvDuration("DIAL_TIMEOUT", 5*time.Second),
+		KeepAlive:    getEnvDuration("TCP_KEEPALIVE", 30*time.Second),
+	}
+	if pool.MaxConns < 1 {
+		return fmt.Errorf("MAX_UPSTREAM_CONNS must be at least 1, got %d", pool.MaxConns)
+	}
+
+	fmt.Printf("Ambassador (tcp) listening on %s://%s -> %s (max %d conns, queue timeout %s)\n",
+		l.Addr().Network(), l.Addr(), pool.UpstreamAddr, pool.MaxConns, pool.QueueTimeout)
+	return pool.Serve(l)
+}
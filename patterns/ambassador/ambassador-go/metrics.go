@@ -0,0 +1,99 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Metrics are registered with the default registry via 'promauto' and served
+// on a separate port, so they never mix with the traffic being proxied.
+var (
+	requestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ambassador_requests_total",
+		Help: "Requests proxied by the ambassador, by response code and whether a hedge won.",
+	}, []string{"code", "hedged"})
+
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "ambassador_request_duration_seconds",
+		Help:    "End-to-end latency seen by the app, including hedging.",
+		Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"hedged"})
+
+	hedgesSent = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "ambassador_hedges_sent_total",
+		Help: "Duplicate requests fired because the original was slower than HEDGE_AFTER.",
+	})
+
+	hedgeWins = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "ambassador_hedge_wins_total",
+		Help: "Requests where a hedge answered before the original attempt.",
+	})
+
+	deadlineExceeded = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "ambassador_deadline_exceeded_total",
+		Help: "Requests answered with 504 because the propagated deadline expired.",
+	})
+
+	// Request queue / backpressure (MAX_INFLIGHT > 0)
+	inflightRequests = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "ambassador_inflight_requests",
+		Help: "Requests currently being forwarded upstream.",
+	})
+
+	queueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "ambassador_queue_depth",
+		Help: "Requests waiting for a free upstream slot.",
+	})
+
+	queueWait = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "ambassador_queue_wait_seconds",
+		Help:    "Time requests spent queued before being forwarded.",
+		Buckets: []float64{.001, .005, .01, .05, .1, .5, 1, 5},
+	})
+
+	queueRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ambassador_queue_rejected_total",
+		Help: "Requests rejected by the queue, by reason (queue_full, queue_timeout, client_gone).",
+	}, []string{"reason"})
+
+	// TCP pooling mode (MODE=tcp)
+	tcpConnections = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "ambassador_tcp_client_connections_total",
+		Help: "Client connections accepted by the TCP ambassador.",
+	})
+
+	tcpActive = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "ambassador_tcp_upstream_connections",
+		Help: "Upstream connections currently open (never above MAX_UPSTREAM_CONNS).",
+	})
+
+	tcpWaiting = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "ambassador_tcp_waiting_clients",
+		Help: "Clients queued for a free upstream slot.",
+	})
+
+	tcpQueueWait = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "ambassador_tcp_queue_wait_seconds",
+		Help:    "Time clients spent waiting for an upstream slot.",
+		Buckets: []float64{.001, .01, .05, .1, .5, 1, 5, 10, 30},
+	})
+
+	tcpRejected = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "ambassador_tcp_rejected_total",
+		Help: "Clients disconnected because no slot freed up within QUEUE_TIMEOUT.",
+	})
+
+	tcpDialErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "ambassador_tcp_upstream_dial_errors_total",
+		Help: "Failed attempts to connect to the upstream.",
+	})
+)
+
+func observe(code int, hedged bool, start time.Time) {
+	h := strconv.FormatBool(hedged)
+	requestsTotal.WithLabelValues(strconv.Itoa(code), h).Inc()
+	requestDuration.WithLabelValues(h).Observe(time.Since(start).Seconds())
+}
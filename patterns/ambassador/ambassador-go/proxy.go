@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// TimeoutHeader carries the caller's remaining time budget (a Go duration such
+// as "1500ms"). The ambassador honours it on the way in and rewrites it on the
+// way out, so every hop sees how much of the original deadline is left.
+const TimeoutHeader = "X-Request-Timeout"
+
+// Hop-by-hop headers must not be forwarded by a proxy (RFC 7230 §6.1).
+var hopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// Proxy forwards every request to a single upstream, enforcing a deadline and
+// optionally hedging slow idempotent requests.
+type Proxy struct {
+	Upstream       *url.URL
+	Client         *http.Client
+	DefaultTimeout time.Duration
+
+	// HedgeAfter is how long to wait for the first attempt before firing a
+	// duplicate. Zero disables hedging.
+	HedgeAfter time.Duration
+	// MaxAttempts caps the original request plus its hedges.
+	MaxAttempts int
+}
+
+type attemptResult struct {
+	resp  *http.Response
+	err   error
+	index int
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	// 1. DEADLINE: The smaller of our own default and whatever the app asked for.
+	// r.Context() is also cancelled when the app hangs up, so an abandoned
+	// request stops consuming upstream capacity immediately.
+	timeout := p.DefaultTimeout
+	if v := r.Header.Get(TimeoutHeader); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 && d < timeout {
+			timeout = d
+		}
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	// 2. FORWARD (with hedging if allowed)
+	resp, release, hedged, err := p.do(ctx, r)
+	if err != nil {
+		code := http.StatusBadGateway
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			code = http.StatusGatewayTimeout
+			deadlineExceeded.Inc()
+		}
+		fmt.Printf("Ambassador: %s %s failed after %s: %v\n", r.Method, r.URL.Path, time.Since(start), err)
+		observe(code, hedged, start)
+		http.Error(w, http.StatusText(code), code)
+		return
+	}
+	defer release()
+	defer resp.Body.Close()
+
+	// 3. RESPOND
+	for _, h := range hopHeaders {
+		resp.Header.Del(h)
+	}
+	for k, vv := range resp.Header {
+		for _, v := range vv {
+			w.Header().Add(k, v)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+
+	observe(resp.StatusCode, hedged, start)
+}
+
+// hedgeable reports whether a request can safely be sent more than once.
+// Only bodiless safe methods qualify; hedging a POST could double-charge a card.
+func hedgeable(r *http.Request) bool {
+	return (r.Method == http.MethodGet || r.Method == http.MethodHead) && r.ContentLength == 0
+}
+
+// do sends the request upstream and returns the winning response together with
+// the cancel func for its context, which the caller must invoke once the body
+// has been consumed. hedged reports whether a hedge attempt produced the response.
+func (p *Proxy) do(ctx context.Context, in *http.Request) (*http.Response, context.CancelFunc, bool, error) {
+	maxAttempts := p.MaxAttempts
+	if p.HedgeAfter <= 0 || !hedgeable(in) {
+		maxAttempts = 1
+	}
+
+	results := make(chan attemptResult, maxAttempts)
+	var cancels []context.CancelFunc
+
+	launch := func() {
+		actx, cancel := context.WithCancel(ctx)
+		cancels = append(cancels, cancel)
+		index := len(cancels) - 1
+		go func() {
+			resp, err := p.attempt(actx, in)
+			results <- attemptResult{resp: resp, err: err, index: index}
+		}()
+	}
+
+	launch()
+	var hedgeTimer <-chan time.Time
+	if maxAttempts > 1 {
+		t := time.NewTimer(p.HedgeAfter)
+		defer t.Stop()
+		hedgeTimer = t.C
+	}
+
+	inflight := 1
+	var lastErr error
+	for inflight > 0 {
+		select {
+		case <-hedgeTimer:
+			launch()
+			inflight++
+			hedgesSent.Inc()
+			if len(cancels) < maxAttempts {
+				hedgeTimer = time.After(p.HedgeAfter)
+			} else {
+				hedgeTimer = nil
+			}
+
+		case res := <-results:
+			inflight--
+			if res.err != nil {
+				lastErr = res.err
+				cancels[res.index]()
+				continue
+			}
+
+			// First response wins. Cancel the others and drain whatever they
+			// still return so their connections go back to the pool.
+			for i, c := range cancels {
+				if i != res.index {
+					c()
+				}
+			}
+			go func(n int) {
+				for ; n > 0; n-- {
+					if late := <-results; late.resp != nil {
+						late.resp.Body.Close()
+					}
+				}
+			}(inflight)
+
+			hedged := res.index > 0
+			if hedged {
+				hedgeWins.Inc()
+			}
+			return res.resp, cancels[res.index], hedged, nil
+		}
+	}
+	return nil, nil, len(cancels) > 1, lastErr
+}
+
+// attempt performs one upstream round trip.
+func (p *Proxy) attempt(ctx context.Context, in *http.Request) (*http.Response, error) {
+	target := *p.Upstream
+	target.Path = singleJoiningSlash(p.Upstream.Path, in.URL.Path)
+	target.RawQuery = in.URL.RawQuery
+
+	var body io.Reader
+	if !hedgeable(in) {
+		body = in.Body
+	}
+	out, err := http.NewRequestWithContext(ctx, in.Method, target.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	out.ContentLength = in.ContentLength
+	out.Header = in.Header.Clone()
+	for _, h := range hopHeaders {
+		out.Header.Del(h)
+	}
+
+	// Propagate what is left of the deadline, not the original value: time
+	// already spent in the ambassador is gone for the upstream too.
+	if deadline, ok := ctx.Deadline(); ok {
+		out.Header.Set(TimeoutHeader, time.Until(deadline).Round(time.Millisecond).String())
+	}
+
+	return p.Client.Do(out)
+}
+
+func singleJoiningSlash(a, b string) string {
+	switch {
+	case a == "" || a == "/":
+		return b
+	case b == "":
+		return a
+	case a[len(a)-1] == '/' && b[0] == '/':
+		return a + b[1:]
+	case a[len(a)-1] != '/' && b[0] != '/':
+		return a + "/" + b
+	}
+	return a + b
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Queue bounds how much work the ambassador accepts. At most MaxInflight
+// requests are forwarded at once; up to Depth more wait for a slot. Anything
+// beyond that is rejected immediately with 503 instead of parking yet another
+// goroutine (and its memory) while the upstream is already struggling.
+//
+// Fast rejection is the point: the app sees the overload right away and can
+// shed load or back off, rather than every request slowly timing out.
+type Queue struct {
+	Next        http.Handler
+	MaxInflight int
+	Depth       int
+	// Timeout is how long a request may wait in the queue before it is
+	// rejected. Zero waits until the request's own context ends.
+	Timeout time.Duration
+
+	inflight chan struct{}
+	waiting  chan struct{}
+}
+
+// NewQueue builds a Queue in front of next.
+func NewQueue(next http.Handler, maxInflight, depth int, timeout time.Duration) *Queue {
+	return &Queue{
+		Next:        next,
+		MaxInflight: maxInflight,
+		Depth:       depth,
+		Timeout:     timeout,
+		inflight:    make(chan struct{}, maxInflight),
+		waiting:     make(chan struct{}, depth),
+	}
+}
+
+func (q *Queue) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	// 1. FAST PATH: A slot is free, go straight through.
+	select {
+	case q.inflight <- struct{}{}:
+		q.forward(w, r, start)
+		return
+	default:
+	}
+
+	// 2. QUEUE: Take a place in line, or bounce if the line is full.
+	select {
+	case q.waiting <- struct{}{}:
+	default:
+		q.reject(w, r, "queue_full")
+		return
+	}
+	queueDepth.Inc()
+
+	var timeout <-chan time.Time
+	if q.Timeout > 0 {
+		t := time.NewTimer(q.Timeout)
+		defer t.Stop()
+		timeout = t.C
+	}
+
+	select {
+	case q.inflight <- struct{}{}:
+		<-q.waiting
+		queueDepth.Dec()
+		q.forward(w, r, start)
+	case <-timeout:
+		<-q.waiting
+		queueDepth.Dec()
+		q.reject(w, r, "queue_timeout")
+	case <-r.Context().Done():
+		// The app gave up while waiting; nobody is left to answer.
+		<-q.waiting
+		queueDepth.Dec()
+		queueRejected.WithLabelValues("client_gone").Inc()
+	}
+}
+
+func (q *Queue) forward(w http.ResponseWriter, r *http.Request, queuedAt time.Time) {
+	queueWait.Observe(time.Since(queuedAt).Seconds())
+	inflightRequests.Inc()
+	defer func() {
+		inflightRequests.Dec()
+		<-q.inflight
+	}()
+	q.Next.ServeHTTP(w, r)
+}
+
+func (q *Queue) reject(w http.ResponseWriter, r *http.Request, reason string) {
+	queueRejected.WithLabelValues(reason).Inc()
+	fmt.Printf("Ambassador: rejecting %s %s (%s)\n", r.Method, r.URL.Path, reason)
+
+	// Retry-After hints well-behaved clients to back off instead of hammering.
+	w.Header().Set("Retry-After", "1")
+	http.Error(w, "ambassador overloaded: "+reason, http.StatusServiceUnavailable)
+}
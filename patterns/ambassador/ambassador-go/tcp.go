@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// TCPPool is the "pgbouncer-lite" ambassador: it forwards raw TCP connections
+// to a database but never opens more than MaxConns upstream connections.
+// Clients above that limit wait in a queue for a free slot instead of piling
+// onto the backend, which is exactly what blows up max_connections on Postgres
+// when a Deployment scales out.
+//
+// It does not understand the wire protocol, so it cannot share one server
+// connection between clients (transaction pooling). Each client gets its own
+// upstream connection for as long as it stays connected.
+type TCPPool struct {
+	UpstreamAddr string
+	MaxConns     int
+	// QueueTimeout is how long a client may wait for a slot before it is
+	// disconnected. Zero waits forever.
+	QueueTimeout time.Duration
+	DialTimeout  time.Duration
+	// KeepAlive is the TCP keep-alive period for both legs, so half-dead
+	// connections (e.g. after a NAT/conntrack timeout) are detected and freed.
+	KeepAlive time.Duration
+
+	slots chan struct{}
+	once  sync.Once
+}
+
+func (p *TCPPool) init() {
+	p.slots = make(chan struct{}, p.MaxConns)
+}
+
+// Serve accepts client connections until the listener fails.
+func (p *TCPPool) Serve(l net.Listener) error {
+	p.once.Do(p.init)
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go p.handle(conn)
+	}
+}
+
+// acquire blocks until an upstream slot is free or the queue timeout expires.
+func (p *TCPPool) acquire() bool {
+	start := time.Now()
+	tcpWaiting.Inc()
+	defer tcpWaiting.Dec()
+
+	var timeout <-chan time.Time
+	if p.QueueTimeout > 0 {
+		t := time.NewTimer(p.QueueTimeout)
+		defer t.Stop()
+		timeout = t.C
+	}
+
+	select {
+	case p.slots <- struct{}{}:
+		tcpQueueWait.Observe(time.Since(start).Seconds())
+		return true
+	case <-timeout:
+		return false
+	}
+}
+
+func (p *TCPPool) release() {
+	<-p.slots
+}
+
+func (p *TCPPool) handle(client net.Conn) {
+	defer client.Close()
+	tcpConnections.Inc()
+	setKeepAlive(client, p.KeepAlive)
+
+	// 1. QUEUE: Wait for one of the MaxConns upstream slots.
+	if !p.acquire() {
+		tcpRejected.Inc()
+		fmt.Printf("Ambassador: %s waited %s for a slot, disconnecting\n", client.RemoteAddr(), p.QueueTimeout)
+		return
+	}
+	defer p.release()
+
+	// 2. DIAL: Only now do we touch the backend.
+	d := net.Dialer{Timeout: p.DialTimeout, KeepAlive: p.KeepAlive}
+	upstream, err := d.Dial("tcp", p.UpstreamAddr)
+	if err != nil {
+		tcpDialErrors.Inc()
+		fmt.Printf("Ambassador: dial %s failed: %v\n", p.UpstreamAddr, err)
+		return
+	}
+	defer upstream.Close()
+
+	tcpActive.Inc()
+	defer tcpActive.Dec()
+
+	// 3. PIPE: Copy both directions. When one side finishes sending we
+	// half-close the other so protocols that rely on EOF still terminate.
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		io.Copy(upstream, client)
+		closeWrite(upstream)
+	}()
+	go func() {
+		defer wg.Done()
+		io.Copy(client, upstream)
+		closeWrite(client)
+	}()
+	wg.Wait()
+}
+
+func setKeepAlive(c net.Conn, period time.Duration) {
+	if tc, ok := c.(*net.TCPConn); ok && period > 0 {
+		tc.SetKeepAlive(true)
+		tc.SetKeepAlivePeriod(period)
+	}
+}
+
+func closeWrite(c net.Conn) {
+	if cw, ok := c.(interface{ CloseWrite() error }); ok {
+		cw.CloseWrite()
+		return
+	}
+	c.Close()
+}
@@ -1,30 +1,137 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"math/rand"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
 	"time"
 )
 
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func getEnvInt(key string, fallback int) int {
+	if value, ok := os.LookupEnv(key); ok {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+		fmt.Printf("Invalid %s=%q, using default %d\n", key, value, fallback)
+	}
+	return fallback
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if value, ok := os.LookupEnv(key); ok {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+		fmt.Printf("Invalid %s=%q, using default %s\n", key, value, fallback)
+	}
+	return fallback
+}
+
+// jittered spreads polls over [interval*(1-fraction), interval*(1+fraction)]
+// so several replicas started together don't hit the ambassador in lockstep.
+func jittered(interval time.Duration, fraction float64) time.Duration {
+	if fraction <= 0 {
+		return interval
+	}
+	delta := (rand.Float64()*2 - 1) * fraction * float64(interval)
+	return interval + time.Duration(delta)
+}
+
+// poll performs a single request and reports whether it counts as a success.
+// Anything other than a 2xx is a failure: the ambassador answering with a 502
+// means the remote side is down even though localhost is reachable.
+func poll(ctx context.Context, client *http.Client, targetURL string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, targetURL, nil)
+	if err != nil {
+		return err
+	}
+	// Tell the ambassador how long we are willing to wait. The Go ambassador
+	// propagates the remaining budget upstream; nginx simply ignores it.
+	req.Header.Set("X-Request-Timeout", client.Timeout.String())
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error reaching ambassador: %w", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("ambassador returned %s", resp.Status)
+	}
+
+	fmt.Printf("Success! Status: %s | Body Length: %d bytes\n", resp.Status, len(body))
+	return nil
+}
+
 func main() {
 	// The application thinks it is talking to a local service.
 	// It has NO idea that the ambassador is actually routing this to httpbin.org
-	targetURL := "http://localhost:8080/get"
+	targetURL := getEnv("TARGET_URL", "http://localhost:8080/get")
+	interval := getEnvDuration("POLL_INTERVAL", 5*time.Second)
+	timeout := getEnvDuration("REQUEST_TIMEOUT", 3*time.Second)
+	jitter := float64(getEnvInt("POLL_JITTER_PERCENT", 20)) / 100
 
-	fmt.Println("Client App Started: Polling " + targetURL)
+	// MAX_CONSECUTIVE_FAILURES=0 keeps polling forever. Any positive value makes
+	// the process exit non-zero after that many failures in a row, which is what
+	// turns a broken ambassador into a visible CrashLoopBackOff.
+	maxFailures := getEnvInt("MAX_CONSECUTIVE_FAILURES", 0)
 
+	// SIGTERM is what the kubelet sends on pod deletion; stop between polls
+	// instead of being killed mid-request after the grace period.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	client := &http.Client{Timeout: timeout}
+
+	// TARGET_SOCKET switches the app→ambassador hop from localhost TCP to a
+	// Unix domain socket on a shared emptyDir. The URL's host is then ignored;
+	// only its path and query are sent.
+	if socket := getEnv("TARGET_SOCKET", ""); socket != "" {
+		client.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		}
+		fmt.Printf("Using Unix socket %s for the ambassador hop\n", socket)
+	}
+
+	fmt.Printf("Client App Started: Polling %s every %s (jitter %.0f%%, max failures %d)\n",
+		targetURL, interval, jitter*100, maxFailures)
+
+	failures := 0
 	for {
-		resp, err := http.Get(targetURL)
-		if err != nil {
-			fmt.Printf("Error reaching ambassador: %v\n", err)
-		} else {
-			body, _ := io.ReadAll(resp.Body)
-			resp.Body.Close()
-			fmt.Printf("Success! Status: %s | Body Length: %d bytes\n", resp.Status, len(body))
+		if err := poll(ctx, client, targetURL); err != nil && ctx.Err() == nil {
+			failures++
+			fmt.Printf("Failure %d: %v\n", failures, err)
+			if maxFailures > 0 && failures >= maxFailures {
+				fmt.Printf("Giving up after %d consecutive failures\n", failures)
+				os.Exit(1)
+			}
+		} else if err == nil {
+			failures = 0
 		}
 
-		// Wait 5 seconds before next request
-		time.Sleep(5 * time.Second)
+		select {
+		case <-ctx.Done():
+			fmt.Println("Received shutdown signal, exiting cleanly")
+			return
+		case <-time.After(jittered(interval, jitter)):
+		}
 	}
 }
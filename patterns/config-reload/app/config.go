@@ -0,0 +1,167 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Config is what the app is configured with. The same keys arrive three
+// ways: as env vars, as files in a mounted ConfigMap, and from the API.
+type Config struct {
+	Greeting string        `json:"greeting"`
+	LogLevel string        `json:"logLevel"`
+	Features []string      `json:"features"`
+	Timeout  time.Duration `json:"timeout"`
+}
+
+// MarshalJSON shows Timeout as "5s" rather than nanoseconds.
+func (c Config) MarshalJSON() ([]byte, error) {
+	type plain Config
+	return json.Marshal(struct {
+		plain
+		Timeout string `json:"timeout"`
+	}{plain(c), c.Timeout.String()})
+}
+
+// Parse validates raw ConfigMap data. A bad value rejects the whole
+// config: half-applying an edit is worse than keeping the old one.
+func Parse(data map[string]string) (Config, error) {
+	cfg := Config{Greeting: "Hello", LogLevel: "info", Timeout: 5 * time.Second}
+	if v, ok := data["GREETING"]; ok {
+		cfg.Greeting = strings.TrimSpace(v)
+	}
+	if v, ok := data["LOG_LEVEL"]; ok {
+		switch v = strings.TrimSpace(v); v {
+		case "debug", "info", "warn", "error":
+			cfg.LogLevel = v
+		default:
+			return Config{}, fmt.Errorf("LOG_LEVEL must be debug, info, warn or error, got %q", v)
+		}
+	}
+	for _, f := range strings.Split(data["FEATURES"], ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			cfg.Features = append(cfg.Features, f)
+		}
+	}
+	if v, ok := data["TIMEOUT"]; ok {
+		d, err := time.ParseDuration(strings.TrimSpace(v))
+		if err != nil || d <= 0 {
+			return Config{}, fmt.Errorf("TIMEOUT must be a positive duration, got %q", v)
+		}
+		cfg.Timeout = d
+	}
+	return cfg, nil
+}
+
+// hash identifies the content, whatever source it came from: the same
+// ConfigMap seen through env, file and API hashes the same.
+func hash(data map[string]string) string {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	h := sha256.New()
+	for _, k := range keys {
+		fmt.Fprintf(h, "%s=%s\x00", k, data[k])
+	}
+	return hex.EncodeToString(h.Sum(nil))[:12]
+}
+
+// Snapshot is one source's view of the config.
+type Snapshot struct {
+	Source   string    `json:"source"`
+	Version  string    `json:"version"` // "startup", the ..data target, or the resourceVersion
+	Hash     string    `json:"hash"`
+	LoadedAt time.Time `json:"loadedAt"`
+	Config   Config    `json:"config"`
+	Rejected string    `json:"rejected,omitempty"` // why the latest update was refused
+}
+
+// Live holds the last good config of every source, and which one the app
+// runs on.
+type Live struct {
+	mu     sync.RWMutex
+	active string
+	snaps  map[string]*Snapshot
+}
+
+func NewLive(active string) *Live {
+	return &Live{active: active, snaps: map[string]*Snapshot{}}
+}
+
+// Set offers new data from a source. Valid data replaces the source's
+// snapshot; invalid data is refused and the last good config stays.
+func (l *Live) Set(source, version string, data map[string]string) {
+	cfg, err := Parse(data)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err != nil {
+		l.reject(source, fmt.Sprintf("version %s: %s", version, err))
+		return
+	}
+	h := hash(data)
+	if prev := l.snaps[source]; prev != nil && prev.Hash == h && prev.Rejected == "" {
+		return // same content, e.g. a resync or an unrelated file event
+	}
+	l.snaps[source] = &Snapshot{Source: source, Version: version, Hash: h, LoadedAt: time.Now().UTC(), Config: cfg}
+	reloadsTotal.WithLabelValues(source, "applied").Inc()
+	lastReload.WithLabelValues(source).SetToCurrentTime()
+	configInfo.DeletePartialMatch(prometheus.Labels{"source": source})
+	configInfo.WithLabelValues(source, h).Set(1)
+	marker := ""
+	if source == l.active {
+		marker = " (active)"
+	}
+	fmt.Printf("[%s] Loaded config version %s, hash %s%s\n", source, version, h, marker)
+}
+
+// Reject records that a source's config is unusable (deleted, empty),
+// keeping its last good one.
+func (l *Live) Reject(source, reason string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.reject(source, reason)
+}
+
+func (l *Live) reject(source, reason string) {
+	reloadsTotal.WithLabelValues(source, "rejected").Inc()
+	fmt.Printf("[%s] Rejected config, keeping the last good one: %s\n", source, reason)
+	prev := l.snaps[source]
+	if prev == nil {
+		prev = &Snapshot{Source: source}
+		l.snaps[source] = prev
+	}
+	prev.Rejected = reason
+}
+
+// Active is the config the app runs on, from the source chosen by
+// CONFIG_SOURCE.
+func (l *Live) Active() (Snapshot, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	s, ok := l.snaps[l.active]
+	if !ok || s.Hash == "" {
+		return Snapshot{}, false
+	}
+	return *s, true
+}
+
+// All returns every source's snapshot, for /config.
+func (l *Live) All() map[string]Snapshot {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	out := make(map[string]Snapshot, len(l.snaps))
+	for k, s := range l.snaps {
+		out[k] = *s
+	}
+	return out
+}
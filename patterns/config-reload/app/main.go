@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// inClusterClient returns a client authenticated as the Pod's
+// ServiceAccount; see manifests/rbac.yaml for what it may do.
+func inClusterClient() (kubernetes.Interface, error) {
+	cfg, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(cfg)
+}
+
+func routes(live *Live) *http.ServeMux {
+	mux := http.NewServeMux()
+	// The app itself: a greeting shaped by whichever config is active.
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, _ *http.Request) {
+		snap, ok := live.Active()
+		if !ok {
+			http.Error(w, "no config yet", http.StatusServiceUnavailable)
+			return
+		}
+		cfg := snap.Config
+		if cfg.LogLevel == "debug" {
+			fmt.Printf("[debug] Serving / with config %s from %s\n", snap.Hash, snap.Source)
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"message":  fmt.Sprintf("%s from %s!", cfg.Greeting, getEnv("POD_NAME", "config-reload")),
+			"features": cfg.Features,
+			"config":   snap.Source + "@" + snap.Version,
+		})
+	})
+	// Every source side by side: after an edit, watch api change at once,
+	// file within a minute or so, and env never.
+	mux.HandleFunc("GET /config", func(w http.ResponseWriter, _ *http.Request) {
+		active, _ := live.Active()
+		writeJSON(w, http.StatusOK, map[string]any{
+			"active":  live.active,
+			"hash":    active.Hash,
+			"sources": live.All(),
+		})
+	})
+	mux.Handle("GET /metrics", promhttp.Handler())
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) { w.Write([]byte("ok")) })
+	// Not ready until the active source has a valid config. A bad edit after
+	// that doesn't unready the Pod: it keeps serving the last good config.
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, _ *http.Request) {
+		if _, ok := live.Active(); !ok {
+			http.Error(w, "no valid config from "+live.active, http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+	return mux
+}
+
+func main() {
+	listenAddr := getEnv("LISTEN_ADDR", ":8080")
+	source := getEnv("CONFIG_SOURCE", "file")
+	switch source {
+	case "env", "file", "api":
+	default:
+		fmt.Printf("Error: CONFIG_SOURCE must be env, file or api, got %q\n", source)
+		os.Exit(1)
+	}
+	live := NewLive(source)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	loadEnv(live, getEnv("ENV_PREFIX", "APP_"))
+
+	dir := getEnv("CONFIG_DIR", "/etc/app-config")
+	if err := watchFile(ctx, live, dir); err != nil {
+		live.Reject("file", fmt.Sprintf("watching %s: %s", dir, err))
+	}
+
+	if name := getEnv("CONFIGMAP_NAME", "app-config"); name != "" {
+		client, err := inClusterClient()
+		if err != nil {
+			live.Reject("api", "no API access: "+err.Error())
+		} else {
+			go watchAPI(ctx, live, client, getEnv("POD_NAMESPACE", "default"), name)
+		}
+	}
+
+	server := &http.Server{Addr: listenAddr, Handler: routes(live)}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Printf("Listening on %s, running on the %s config\n", listenAddr, source)
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		fmt.Printf("Error starting server: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("Shutting down...")
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Metrics are registered with the default registry via 'promauto'.
+// config_info answers "are all replicas on the same config?":
+// count by (hash) (config_info{source="file"}) should be a single series.
+var (
+	reloadsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "config_reloads_total",
+		Help: "Config updates seen, by source (env, file, api) and result (applied, rejected).",
+	}, []string{"source", "result"})
+
+	lastReload = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "config_last_reload_timestamp_seconds",
+		Help: "When each source last applied a new config.",
+	}, []string{"source"})
+
+	configInfo = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "config_info",
+		Help: "1 for the content hash each source currently holds.",
+	}, []string{"source", "hash"})
+)
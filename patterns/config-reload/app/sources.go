@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// 1. Env vars. envFrom copies the ConfigMap into the environment when the
+// container starts, and never again: the only way to pick up an edit is a
+// new container.
+func loadEnv(live *Live, prefix string) {
+	data := map[string]string{}
+	for _, kv := range os.Environ() {
+		k, v, _ := strings.Cut(kv, "=")
+		if key, ok := strings.CutPrefix(k, prefix); ok {
+			data[key] = v
+		}
+	}
+	if len(data) == 0 {
+		live.Reject("env", fmt.Sprintf("no %s* variables (is envFrom set?)", prefix))
+		return
+	}
+	live.Set("env", "startup", data)
+}
+
+// 2. Mounted files. The kubelet writes each ConfigMap version into a new
+// timestamped directory, then swaps the ..data symlink to it in a single
+// rename; the key files are symlinks through ..data. So a reader never sees
+// half an update, and the version is the name ..data points at.
+//
+//	/etc/app-config/GREETING -> ..data/GREETING
+//	/etc/app-config/..data   -> ..2026_10_16_17_30_00.123456789
+func readDir(dir string) (version string, data map[string]string, err error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", nil, err
+	}
+	data = map[string]string{}
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") {
+			continue // ..data and the timestamped directories
+		}
+		b, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue // a key removed in the new version; the kubelet deletes its link next
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		data[e.Name()] = string(b)
+	}
+	version, err = os.Readlink(filepath.Join(dir, "..data"))
+	if err != nil {
+		version = "local" // a plain directory, not a ConfigMap volume
+	}
+	return version, data, nil
+}
+
+func loadFile(live *Live, dir string) {
+	version, data, err := readDir(dir)
+	switch {
+	case err != nil:
+		live.Reject("file", err.Error())
+	case len(data) == 0:
+		live.Reject("file", "no config files in "+dir)
+	default:
+		live.Set("file", version, data)
+	}
+}
+
+// watchFile reloads on any change in dir. It watches the directory, not
+// the files: the files are symlinks that never change themselves, and
+// inotify on a path follows the inode it resolved to when the watch was
+// added, which the swap orphans. Events arrive in bursts (create ..data_tmp,
+// rename to ..data, remove the old directory), so they are debounced.
+func watchFile(ctx context.Context, live *Live, dir string) error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	if err := w.Add(dir); err != nil {
+		w.Close()
+		return err
+	}
+	loadFile(live, dir)
+	go func() {
+		defer w.Close()
+		var debounce <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev := <-w.Events:
+				if ev.Has(fsnotify.Chmod) {
+					continue
+				}
+				debounce = time.After(100 * time.Millisecond)
+			case err := <-w.Errors:
+				fmt.Printf("[file] Watch error: %s\n", err)
+			case <-debounce:
+				loadFile(live, dir)
+			}
+		}
+	}()
+	return nil
+}
+
+// 3. The API. An informer on this one ConfigMap sees every edit as soon as
+// the API server commits it, with no kubelet in between. The field selector
+// keeps the watch (and the RBAC it needs) down to a single object.
+func watchAPI(ctx context.Context, live *Live, client kubernetes.Interface, namespace, name string) {
+	factory := informers.NewSharedInformerFactoryWithOptions(client, 0,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(o *metav1.ListOptions) {
+			o.FieldSelector = fields.OneTermEqualSelector("metadata.name", name).String()
+		}))
+	informer := factory.Core().V1().ConfigMaps().Informer()
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj any) {
+			cm := obj.(*corev1.ConfigMap)
+			live.Set("api", cm.ResourceVersion, cm.Data)
+		},
+		UpdateFunc: func(_, obj any) {
+			cm := obj.(*corev1.ConfigMap)
+			live.Set("api", cm.ResourceVersion, cm.Data)
+		},
+		DeleteFunc: func(any) {
+			live.Reject("api", fmt.Sprintf("ConfigMap %s/%s was deleted", namespace, name))
+		},
+	})
+	factory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		return
+	}
+	if _, ok := live.All()["api"]; !ok {
+		live.Reject("api", fmt.Sprintf("ConfigMap %s/%s not found", namespace, name))
+	}
+}
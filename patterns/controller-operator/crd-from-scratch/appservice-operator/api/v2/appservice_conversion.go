@@ -0,0 +1,312 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v2
+
+import (
+	"strings"
+
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	webappv1 "mydomain.com/appservice/api/v1"
+)
+
+// ConvertTo converts this AppService (v2) to the Hub version (v1).
+func (src *AppService) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*webappv1.AppService)
+	dst.ObjectMeta = src.ObjectMeta
+
+	dst.Spec.Replicas = src.Spec.Replicas
+	dst.Spec.Autoscaling = (*webappv1.AutoscalingSpec)(src.Spec.Autoscaling)
+	dst.Spec.Image = joinImage(src.Spec.Container.Image, src.Spec.Container.Tag)
+	dst.Spec.Command = src.Spec.Container.Command
+	dst.Spec.Args = src.Spec.Container.Args
+	dst.Spec.Port = src.Spec.Port
+	dst.Spec.Host = src.Spec.Host
+	dst.Spec.Path = src.Spec.Path
+	dst.Spec.Resources = src.Spec.Resources
+	dst.Spec.Env = src.Spec.Env
+	dst.Spec.LivenessProbe = src.Spec.LivenessProbe
+	dst.Spec.ReadinessProbe = src.Spec.ReadinessProbe
+	dst.Spec.Config = src.Spec.Config
+	dst.Spec.ImagePullSecretRefs = src.Spec.ImagePullSecretRefs
+	dst.Spec.GeneratedSecret = (*webappv1.GeneratedSecretSpec)(src.Spec.GeneratedSecret)
+	dst.Spec.MinAvailable = src.Spec.MinAvailable
+	dst.Spec.TopologySpreadConstraints = src.Spec.TopologySpreadConstraints
+	dst.Spec.PriorityClassName = src.Spec.PriorityClassName
+	dst.Spec.NodeSelector = src.Spec.NodeSelector
+	dst.Spec.Tolerations = src.Spec.Tolerations
+	dst.Spec.Affinity = src.Spec.Affinity
+	dst.Spec.WorkloadType = webappv1.WorkloadType(src.Spec.WorkloadType)
+	dst.Spec.Storage = (*webappv1.StorageSpec)(src.Spec.Storage)
+	dst.Spec.Suspend = src.Spec.Suspend
+	dst.Spec.Strategy = convertStrategyTo(src.Spec.Strategy)
+	dst.Spec.Promote = src.Spec.Promote
+	dst.Spec.Metrics = (*webappv1.MetricsSpec)(src.Spec.Metrics)
+	dst.Spec.ProgressDeadlineSeconds = src.Spec.ProgressDeadlineSeconds
+	dst.Spec.RollbackOnFailure = src.Spec.RollbackOnFailure
+	dst.Spec.AllowFrom = convertAllowFromTo(src.Spec.AllowFrom)
+	dst.Spec.PinImageDigest = src.Spec.PinImageDigest
+	dst.Spec.Containers = convertContainersTo(src.Spec.Containers)
+	dst.Spec.MaintenanceJob = (*webappv1.MaintenanceJobSpec)(src.Spec.MaintenanceJob)
+	dst.Spec.Migrate = (*webappv1.MigrateSpec)(src.Spec.Migrate)
+
+	dst.Status = webappv1.AppServiceStatus{
+		ObservedGeneration: src.Status.ObservedGeneration,
+		Replicas:           src.Status.Replicas,
+		Selector:           src.Status.Selector,
+		URL:                src.Status.URL,
+		SecretRotation:     src.Status.SecretRotation,
+		Canary:             (*webappv1.CanaryStatus)(src.Status.Canary),
+		BlueGreen:          convertBlueGreenStatusTo(src.Status.BlueGreen),
+		ReferencesChecksum: src.Status.ReferencesChecksum,
+		PinnedImage:        src.Status.PinnedImage,
+		LastGoodImage:      src.Status.LastGoodImage,
+		FailedImage:        src.Status.FailedImage,
+		Migration:          (*webappv1.MigrationStatus)(src.Status.Migration),
+		DNS:                (*webappv1.DNSStatus)(src.Status.DNS),
+		Inventory:          convertInventoryTo(src.Status.Inventory),
+		DeployedResources:  convertDeployedResourcesTo(src.Status.DeployedResources),
+		ReadyReplicas:      src.Status.ReadyReplicas,
+		AvailableReplicas:  src.Status.AvailableReplicas,
+		Conditions:         src.Status.Conditions,
+	}
+	return nil
+}
+
+// ConvertFrom converts the Hub version (v1) to this version (v2).
+func (dst *AppService) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*webappv1.AppService)
+	dst.ObjectMeta = src.ObjectMeta
+
+	dst.Spec.Replicas = src.Spec.Replicas
+	dst.Spec.Autoscaling = (*AutoscalingSpec)(src.Spec.Autoscaling)
+	dst.Spec.Container.Image, dst.Spec.Container.Tag = splitImage(src.Spec.Image)
+	dst.Spec.Container.Command = src.Spec.Command
+	dst.Spec.Container.Args = src.Spec.Args
+	dst.Spec.Port = src.Spec.Port
+	dst.Spec.Host = src.Spec.Host
+	dst.Spec.Path = src.Spec.Path
+	dst.Spec.Resources = src.Spec.Resources
+	dst.Spec.Env = src.Spec.Env
+	dst.Spec.LivenessProbe = src.Spec.LivenessProbe
+	dst.Spec.ReadinessProbe = src.Spec.ReadinessProbe
+	dst.Spec.Config = src.Spec.Config
+	dst.Spec.ImagePullSecretRefs = src.Spec.ImagePullSecretRefs
+	dst.Spec.GeneratedSecret = (*GeneratedSecretSpec)(src.Spec.GeneratedSecret)
+	dst.Spec.MinAvailable = src.Spec.MinAvailable
+	dst.Spec.TopologySpreadConstraints = src.Spec.TopologySpreadConstraints
+	dst.Spec.PriorityClassName = src.Spec.PriorityClassName
+	dst.Spec.NodeSelector = src.Spec.NodeSelector
+	dst.Spec.Tolerations = src.Spec.Tolerations
+	dst.Spec.Affinity = src.Spec.Affinity
+	dst.Spec.WorkloadType = WorkloadType(src.Spec.WorkloadType)
+	dst.Spec.Storage = (*StorageSpec)(src.Spec.Storage)
+	dst.Spec.Suspend = src.Spec.Suspend
+	dst.Spec.Strategy = convertStrategyFrom(src.Spec.Strategy)
+	dst.Spec.Promote = src.Spec.Promote
+	dst.Spec.Metrics = (*MetricsSpec)(src.Spec.Metrics)
+	dst.Spec.ProgressDeadlineSeconds = src.Spec.ProgressDeadlineSeconds
+	dst.Spec.RollbackOnFailure = src.Spec.RollbackOnFailure
+	dst.Spec.AllowFrom = convertAllowFromFrom(src.Spec.AllowFrom)
+	dst.Spec.PinImageDigest = src.Spec.PinImageDigest
+	dst.Spec.Containers = convertContainersFrom(src.Spec.Containers)
+	dst.Spec.MaintenanceJob = (*MaintenanceJobSpec)(src.Spec.MaintenanceJob)
+	dst.Spec.Migrate = (*MigrateSpec)(src.Spec.Migrate)
+
+	dst.Status = AppServiceStatus{
+		ObservedGeneration: src.Status.ObservedGeneration,
+		Replicas:           src.Status.Replicas,
+		Selector:           src.Status.Selector,
+		URL:                src.Status.URL,
+		SecretRotation:     src.Status.SecretRotation,
+		Canary:             (*CanaryStatus)(src.Status.Canary),
+		BlueGreen:          convertBlueGreenStatusFrom(src.Status.BlueGreen),
+		ReferencesChecksum: src.Status.ReferencesChecksum,
+		PinnedImage:        src.Status.PinnedImage,
+		LastGoodImage:      src.Status.LastGoodImage,
+		FailedImage:        src.Status.FailedImage,
+		Migration:          (*MigrationStatus)(src.Status.Migration),
+		DNS:                (*DNSStatus)(src.Status.DNS),
+		Inventory:          convertInventoryFrom(src.Status.Inventory),
+		DeployedResources:  convertDeployedResourcesFrom(src.Status.DeployedResources),
+		ReadyReplicas:      src.Status.ReadyReplicas,
+		AvailableReplicas:  src.Status.AvailableReplicas,
+		Conditions:         src.Status.Conditions,
+	}
+	return nil
+}
+
+// convertStrategyTo and convertStrategyFrom copy spec.strategy. It has the
+// same shape in both versions, but nests types a single cast can't convert.
+func convertStrategyTo(src *StrategySpec) *webappv1.StrategySpec {
+	if src == nil {
+		return nil
+	}
+	dst := &webappv1.StrategySpec{}
+	if src.Canary != nil {
+		dst.Canary = &webappv1.CanaryStrategy{}
+		for _, step := range src.Canary.Steps {
+			dst.Canary.Steps = append(dst.Canary.Steps, webappv1.CanaryStep(step))
+		}
+	}
+	dst.BlueGreen = (*webappv1.BlueGreenStrategy)(src.BlueGreen)
+	return dst
+}
+
+func convertStrategyFrom(src *webappv1.StrategySpec) *StrategySpec {
+	if src == nil {
+		return nil
+	}
+	dst := &StrategySpec{}
+	if src.Canary != nil {
+		dst.Canary = &CanaryStrategy{}
+		for _, step := range src.Canary.Steps {
+			dst.Canary.Steps = append(dst.Canary.Steps, CanaryStep(step))
+		}
+	}
+	dst.BlueGreen = (*BlueGreenStrategy)(src.BlueGreen)
+	return dst
+}
+
+// convertBlueGreenStatusTo and convertBlueGreenStatusFrom copy
+// status.blueGreen, whose Color differs by version.
+func convertBlueGreenStatusTo(src *BlueGreenStatus) *webappv1.BlueGreenStatus {
+	if src == nil {
+		return nil
+	}
+	return &webappv1.BlueGreenStatus{
+		ActiveColor:      webappv1.Color(src.ActiveColor),
+		ActiveRevision:   src.ActiveRevision,
+		PreviewRevision:  src.PreviewRevision,
+		PreviewReadyTime: src.PreviewReadyTime,
+		SwitchTime:       src.SwitchTime,
+	}
+}
+
+func convertBlueGreenStatusFrom(src *webappv1.BlueGreenStatus) *BlueGreenStatus {
+	if src == nil {
+		return nil
+	}
+	return &BlueGreenStatus{
+		ActiveColor:      Color(src.ActiveColor),
+		ActiveRevision:   src.ActiveRevision,
+		PreviewRevision:  src.PreviewRevision,
+		PreviewReadyTime: src.PreviewReadyTime,
+		SwitchTime:       src.SwitchTime,
+	}
+}
+
+// convertAllowFromTo and convertAllowFromFrom copy spec.allowFrom, item by
+// item like the inventory below.
+func convertAllowFromTo(src []NetworkPeer) []webappv1.NetworkPeer {
+	var dst []webappv1.NetworkPeer
+	for _, peer := range src {
+		dst = append(dst, webappv1.NetworkPeer(peer))
+	}
+	return dst
+}
+
+func convertAllowFromFrom(src []webappv1.NetworkPeer) []NetworkPeer {
+	var dst []NetworkPeer
+	for _, peer := range src {
+		dst = append(dst, NetworkPeer(peer))
+	}
+	return dst
+}
+
+// convertContainersTo and convertContainersFrom copy spec.containers, item
+// by item like the inventory below.
+func convertContainersTo(src []Container) []webappv1.Container {
+	var dst []webappv1.Container
+	for _, container := range src {
+		dst = append(dst, webappv1.Container(container))
+	}
+	return dst
+}
+
+func convertContainersFrom(src []webappv1.Container) []Container {
+	var dst []Container
+	for _, container := range src {
+		dst = append(dst, Container(container))
+	}
+	return dst
+}
+
+// convertInventoryTo and convertInventoryFrom copy status.inventory item by
+// item: a slice can't be cast to one of another element type.
+func convertInventoryTo(src []ChildReference) []webappv1.ChildReference {
+	var dst []webappv1.ChildReference
+	for _, ref := range src {
+		dst = append(dst, webappv1.ChildReference(ref))
+	}
+	return dst
+}
+
+func convertInventoryFrom(src []webappv1.ChildReference) []ChildReference {
+	var dst []ChildReference
+	for _, ref := range src {
+		dst = append(dst, ChildReference(ref))
+	}
+	return dst
+}
+
+// convertDeployedResourcesTo and convertDeployedResourcesFrom copy
+// status.deployedResources field by field, as Health differs by version.
+func convertDeployedResourcesTo(src []DeployedResource) []webappv1.DeployedResource {
+	var dst []webappv1.DeployedResource
+	for _, res := range src {
+		dst = append(dst, webappv1.DeployedResource{
+			APIVersion: res.APIVersion, Kind: res.Kind, Name: res.Name, UID: res.UID,
+			Health: webappv1.Health(res.Health), Message: res.Message,
+		})
+	}
+	return dst
+}
+
+func convertDeployedResourcesFrom(src []webappv1.DeployedResource) []DeployedResource {
+	var dst []DeployedResource
+	for _, res := range src {
+		dst = append(dst, DeployedResource{
+			APIVersion: res.APIVersion, Kind: res.Kind, Name: res.Name, UID: res.UID,
+			Health: Health(res.Health), Message: res.Message,
+		})
+	}
+	return dst
+}
+
+// splitImage splits a v1 image reference into image and tag. The tag is what
+// follows the last ":" after the last "/", so a registry port
+// ("registry:5000/app") isn't mistaken for one. References pinned by digest
+// are kept whole: the digest already identifies the image.
+func splitImage(ref string) (image, tag string) {
+	if strings.Contains(ref, "@") {
+		return ref, ""
+	}
+	slash := strings.LastIndex(ref, "/")
+	if colon := strings.LastIndex(ref, ":"); colon > slash {
+		return ref[:colon], ref[colon+1:]
+	}
+	return ref, ""
+}
+
+// joinImage is the inverse of splitImage.
+func joinImage(image, tag string) string {
+	if tag == "" {
+		return image
+	}
+	return image + ":" + tag
+}
@@ -0,0 +1,746 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v2
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// ContainerSpec describes the app's container. v1 had a single image string;
+// v2 splits it so tools (and people) can bump the tag without parsing.
+type ContainerSpec struct {
+	// Image is the image without its tag, e.g. "nginx" or "ghcr.io/org/app".
+	// A digest ("app@sha256:...") stays here, with an empty tag.
+	// +kubebuilder:validation:MinLength=1
+	Image string `json:"image"`
+
+	// Tag is the image tag. Empty means the runtime's default, "latest".
+	// +optional
+	Tag string `json:"tag,omitempty"`
+
+	// Command overrides the image's entrypoint.
+	// +listType=atomic
+	// +optional
+	Command []string `json:"command,omitempty"`
+
+	// Args are the arguments to the entrypoint. $(VAR) references to
+	// spec.env are expanded by Kubernetes.
+	// +listType=atomic
+	// +optional
+	Args []string `json:"args,omitempty"`
+}
+
+// AppServiceSpec defines the desired state of AppService
+// +kubebuilder:validation:XValidation:rule="!has(self.storage) || self.workloadType == 'StatefulSet'",message="storage is only valid with workloadType StatefulSet"
+// +kubebuilder:validation:XValidation:rule="has(self.storage) == has(oldSelf.storage)",message="storage can't be added or removed after creation"
+// +kubebuilder:validation:XValidation:rule="!has(self.progressDeadlineSeconds) || self.workloadType == 'Deployment'",message="only Deployments have a progress deadline"
+// +kubebuilder:validation:XValidation:rule="!has(self.migrate) || self.workloadType == 'Deployment'",message="only Deployments are migrated"
+type AppServiceSpec struct {
+	// Replicas defines how many pods we want. Defaults to 1.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// Autoscaling, if set, gives the replicas to a HorizontalPodAutoscaler
+	// the controller creates, and spec.replicas is no longer applied.
+	// +optional
+	Autoscaling *AutoscalingSpec `json:"autoscaling,omitempty"`
+
+	// Container defines which image to run.
+	Container ContainerSpec `json:"container"`
+
+	// Port is the port the container listens on. The controller exposes it
+	// through a ClusterIP Service with the same name as the AppService.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +kubebuilder:default=80
+	// +optional
+	Port int32 `json:"port,omitempty"`
+
+	// Host is the hostname the app is published on, e.g. "shop.example.com".
+	// Setting it makes the controller create an Ingress, or an HTTPRoute when
+	// the operator runs with --gateway.
+	// +kubebuilder:validation:MaxLength=253
+	// +kubebuilder:validation:Pattern=`^(\*\.)?[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`
+	// +optional
+	Host string `json:"host,omitempty"`
+
+	// Path is the URL path prefix routed to the app. Only used with Host.
+	// +kubebuilder:validation:Pattern=`^/`
+	// +kubebuilder:default="/"
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// Resources are the container's compute requests and limits.
+	// +optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// Env are environment variables for the container.
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Env []corev1.EnvVar `json:"env,omitempty"`
+
+	// LivenessProbe restarts the container when it fails.
+	// +optional
+	LivenessProbe *corev1.Probe `json:"livenessProbe,omitempty"`
+
+	// ReadinessProbe takes the Pod out of the Service while it fails.
+	// +optional
+	ReadinessProbe *corev1.Probe `json:"readinessProbe,omitempty"`
+
+	// Config is rendered into a ConfigMap owned by the AppService and mounted
+	// read-only at /etc/app, one file per key. Changing it rolls the Pods.
+	// +optional
+	Config map[string]string `json:"config,omitempty"`
+
+	// ImagePullSecretRefs are Secrets in the same namespace used to pull the
+	// image from a private registry. They're passed to the Pod as is.
+	// +optional
+	ImagePullSecretRefs []corev1.LocalObjectReference `json:"imagePullSecretRefs,omitempty"`
+
+	// GeneratedSecret, if set, makes the controller generate a random token
+	// into a Secret it owns and pass it to the container as an env var.
+	// +optional
+	GeneratedSecret *GeneratedSecretSpec `json:"generatedSecret,omitempty"`
+
+	// MinAvailable, if set, is kept available during voluntary disruptions
+	// (node drains, cluster upgrades) through a PodDisruptionBudget: a number
+	// of Pods, or a percentage like "50%".
+	// +optional
+	MinAvailable *intstr.IntOrString `json:"minAvailable,omitempty"`
+
+	// TopologySpreadConstraints spread the Pods across zones or nodes. A
+	// constraint without a labelSelector selects the AppService's own Pods.
+	// +optional
+	TopologySpreadConstraints []corev1.TopologySpreadConstraint `json:"topologySpreadConstraints,omitempty"`
+
+	// PriorityClassName is the PriorityClass of the Pods: which Pods the
+	// scheduler preempts first when the cluster is full.
+	// +kubebuilder:validation:MaxLength=253
+	// +optional
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+
+	// NodeSelector only schedules the Pods on nodes with these labels.
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Tolerations let the Pods run on nodes with matching taints.
+	// +listType=atomic
+	// +optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+
+	// Affinity holds node and Pod (anti-)affinity rules for the Pods. They're
+	// passed to the Pods as is.
+	// +optional
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+
+	// WorkloadType is what runs the Pods: a Deployment, or a StatefulSet for
+	// apps that need stable names and their own storage. It can't be changed
+	// after creation.
+	// +kubebuilder:validation:Enum=Deployment;StatefulSet
+	// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="workloadType is immutable"
+	// +kubebuilder:default=Deployment
+	// +optional
+	WorkloadType WorkloadType `json:"workloadType,omitempty"`
+
+	// Storage gives each Pod of a StatefulSet its own PersistentVolumeClaim.
+	// Only valid with workloadType StatefulSet, and can't be changed after
+	// creation (StatefulSet volumeClaimTemplates are immutable).
+	// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="storage is immutable"
+	// +optional
+	Storage *StorageSpec `json:"storage,omitempty"`
+
+	// Suspend stops the controller from changing anything: children are left
+	// as they are, and only the status is kept up to date. Use it to debug or
+	// hand-fix a child without the controller reverting it.
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+
+	// Strategy is how a new revision of the app replaces the running one.
+	// Without it, the Deployment's rolling update does. Only for
+	// Deployments that aren't autoscaled.
+	// +optional
+	Strategy *StrategySpec `json:"strategy,omitempty"`
+
+	// Promote approves a blue/green preview: set it to
+	// status.blueGreen.previewRevision to switch the Service over. A value
+	// naming any other revision does nothing, so it can be left in place.
+	// +optional
+	Promote string `json:"promote,omitempty"`
+
+	// Metrics, if set, has Prometheus scrape the app: the controller creates
+	// a ServiceMonitor for it when the Prometheus Operator's CRDs are
+	// installed, and skips it when they aren't.
+	// +optional
+	Metrics *MetricsSpec `json:"metrics,omitempty"`
+
+	// ProgressDeadlineSeconds is how long a rollout may go without progress
+	// before the Deployment reports it failed, and the AppService turns
+	// Degraded. Without it, the Deployment's default of 600 applies.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	ProgressDeadlineSeconds *int32 `json:"progressDeadlineSeconds,omitempty"`
+
+	// RollbackOnFailure puts status.lastGoodImage back when a rollout of
+	// spec.image exceeds its progress deadline. spec.image is left as is:
+	// setting it to another image tries again. Only for Deployments without
+	// a strategy, as canaries and blue/green have their own gates.
+	// +optional
+	RollbackOnFailure bool `json:"rollbackOnFailure,omitempty"`
+
+	// AllowFrom, if set, limits who can reach the Pods: the controller puts
+	// a default-deny NetworkPolicy on them, and a second one letting these
+	// peers in on the app's ports. Without it, no NetworkPolicy is created
+	// and the namespace's own policies apply.
+	// +listType=atomic
+	// +kubebuilder:validation:MaxItems=32
+	// +optional
+	AllowFrom []NetworkPeer `json:"allowFrom,omitempty"`
+
+	// PinImageDigest, if set, resolves the image's tag to a digest at the
+	// registry and runs the Pods on that digest, recorded in
+	// status.pinnedImage. The tag is resolved again only when the image
+	// changes, so a tag moved at the registry doesn't reach the Pods
+	// unnoticed.
+	// +optional
+	PinImageDigest bool `json:"pinImageDigest,omitempty"`
+
+	// Containers run next to the app's container in every Pod.
+	// +listType=map
+	// +listMapKey=name
+	// +kubebuilder:validation:MaxItems=8
+	// +optional
+	Containers []Container `json:"containers,omitempty"`
+
+	// MaintenanceJob, if set, runs a task on a schedule next to the app, as
+	// a CronJob: a cleanup, a report, a cache refresh.
+	// +optional
+	MaintenanceJob *MaintenanceJobSpec `json:"maintenanceJob,omitempty"`
+
+	// Migrate, if set, runs a Job in the new image before the Deployment
+	// moves to it, and holds the Deployment back until the Job succeeded:
+	// a schema migration the new version needs before it starts. It runs
+	// before the first Deployment too. Rollbacks don't run it.
+	// +optional
+	Migrate *MigrateSpec `json:"migrate,omitempty"`
+}
+
+// AutoscalingSpec sizes the workload by its Pods' CPU use, through a
+// HorizontalPodAutoscaler.
+// +kubebuilder:validation:XValidation:rule="!has(self.minReplicas) || self.minReplicas <= self.maxReplicas",message="minReplicas can't be greater than maxReplicas"
+type AutoscalingSpec struct {
+	// MinReplicas is the fewest replicas the autoscaler scales down to.
+	// Defaults to 1.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MinReplicas *int32 `json:"minReplicas,omitempty"`
+
+	// MaxReplicas is the most replicas the autoscaler scales up to.
+	// +kubebuilder:validation:Minimum=1
+	MaxReplicas int32 `json:"maxReplicas"`
+
+	// TargetCPUUtilization is the average CPU use the autoscaler aims for,
+	// in percent of the Pods' CPU request.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=80
+	// +optional
+	TargetCPUUtilization int32 `json:"targetCPUUtilization,omitempty"`
+}
+
+// MaintenanceJobSpec is a task run on a schedule, in the app's environment:
+// its env, config and image pull secrets.
+type MaintenanceJobSpec struct {
+	// Schedule is a cron expression, like "0 3 * * *", in the time zone of
+	// the kube-controller-manager.
+	// +kubebuilder:validation:MinLength=1
+	Schedule string `json:"schedule"`
+
+	// Image is the container image of the task. Without it, the app's own
+	// image is run.
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// Command overrides the image's entrypoint.
+	// +listType=atomic
+	// +optional
+	Command []string `json:"command,omitempty"`
+
+	// Args are the arguments to the entrypoint.
+	// +listType=atomic
+	// +optional
+	Args []string `json:"args,omitempty"`
+}
+
+// MigrateSpec is the migration run in each new image before the Deployment
+// rolls it out, in the app's environment.
+// +kubebuilder:validation:XValidation:rule="has(self.command) || has(self.args)",message="a command, args or both"
+type MigrateSpec struct {
+	// Command overrides the image's entrypoint.
+	// +listType=atomic
+	// +optional
+	Command []string `json:"command,omitempty"`
+
+	// Args are the arguments to the entrypoint.
+	// +listType=atomic
+	// +optional
+	Args []string `json:"args,omitempty"`
+
+	// BackoffLimit is how many times the migration is retried before it's
+	// considered failed. Kubernetes' default, 6, applies without it.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	BackoffLimit *int32 `json:"backoffLimit,omitempty"`
+}
+
+// Container runs next to the app's own container in every Pod: a sidecar
+// such as a log shipper or a proxy.
+// +kubebuilder:validation:XValidation:rule="self.name != 'main'",message="main is the app's own container"
+type Container struct {
+	// Name identifies the container. Containers are matched by name, and
+	// those that aren't sidecars are sorted by it, so reordering them in
+	// spec.containers changes nothing.
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+
+	// Image is the container image, tag included.
+	// +kubebuilder:validation:MinLength=1
+	Image string `json:"image"`
+
+	// Command overrides the image's entrypoint.
+	// +listType=atomic
+	// +optional
+	Command []string `json:"command,omitempty"`
+
+	// Args are the arguments to the entrypoint. $(VAR) references to the
+	// container's env are expanded by Kubernetes.
+	// +listType=atomic
+	// +optional
+	Args []string `json:"args,omitempty"`
+
+	// Ports are the ports the container listens on. They are not added to
+	// the Service, which only exposes the app's own.
+	// +listType=atomic
+	// +optional
+	Ports []corev1.ContainerPort `json:"ports,omitempty"`
+
+	// Sidecar starts the container before the app's and keeps it running
+	// until the app's has stopped: a native sidecar, run as an init
+	// container with restartPolicy Always. Sidecars start in the order they
+	// are listed in.
+	// +optional
+	Sidecar bool `json:"sidecar,omitempty"`
+}
+
+// MetricsSpec is where the app serves Prometheus metrics.
+type MetricsSpec struct {
+	// Port is the container port serving the metrics. Without it, they're
+	// scraped from spec.port; a different port is added to the container
+	// and the Service under the name "metrics".
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	Port int32 `json:"port,omitempty"`
+
+	// Path is the HTTP path of the metrics endpoint.
+	// +kubebuilder:validation:Pattern=`^/`
+	// +kubebuilder:default="/metrics"
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// Interval is how often Prometheus scrapes the app, in whole seconds.
+	// Without it, Prometheus' global scrape interval applies.
+	// +kubebuilder:validation:XValidation:rule="duration(self) >= duration('1s') && duration(self).getMilliseconds() % 1000 == 0",message="must be a whole number of seconds, at least one"
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty"`
+}
+
+// NetworkPeer selects Pods allowed to reach the app, as a NetworkPolicy
+// peer does: the Pods matching podSelector in the namespaces matching
+// namespaceSelector. Without a namespaceSelector, the AppService's own
+// namespace is meant; without a podSelector, all Pods of the namespaces.
+// +kubebuilder:validation:XValidation:rule="has(self.namespaceSelector) || has(self.podSelector)",message="a namespaceSelector, a podSelector or both"
+type NetworkPeer struct {
+	// NamespaceSelector selects namespaces by their labels, e.g.
+	// kubernetes.io/metadata.name: ingress-nginx.
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// PodSelector selects Pods by their labels.
+	// +optional
+	PodSelector *metav1.LabelSelector `json:"podSelector,omitempty"`
+}
+
+// StrategySpec picks a rollout strategy.
+// +kubebuilder:validation:XValidation:rule="!(has(self.canary) && has(self.blueGreen))",message="canary and blueGreen can't be combined"
+type StrategySpec struct {
+	// Canary moves a growing share of the replicas to the new revision, in
+	// steps, before promoting it to all of them.
+	// +optional
+	Canary *CanaryStrategy `json:"canary,omitempty"`
+
+	// BlueGreen brings the new revision up next to the running one and
+	// switches all traffic at once, when it's ready and promoted.
+	// +optional
+	BlueGreen *BlueGreenStrategy `json:"blueGreen,omitempty"`
+}
+
+// CanaryStrategy runs a new revision next to the stable one, in a second
+// Deployment <name>-canary. The Service selects the Pods of both, so traffic
+// follows the replica ratio.
+type CanaryStrategy struct {
+	// Steps are run in order. After the last one, the new revision is
+	// promoted: the stable Deployment is updated and the canary removed.
+	// +kubebuilder:validation:MinItems=1
+	// +listType=atomic
+	// +required
+	Steps []CanaryStep `json:"steps"`
+}
+
+// CanaryStep sets the canary's weight, then holds.
+type CanaryStep struct {
+	// Weight is the percentage of the replicas running the new revision,
+	// rounded up to a whole Pod.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +required
+	Weight int32 `json:"weight"`
+
+	// Pause is how long to stay at this weight, counted from the start of
+	// the step. The next step also waits for the canary Pods to be available.
+	// +optional
+	Pause *metav1.Duration `json:"pause,omitempty"`
+}
+
+// BlueGreenStrategy runs the app in two Deployments, <name>-blue and
+// <name>-green. The Service selects the Pods of the active one; a new
+// revision goes to the other, and the Service is switched to it once all
+// its Pods are available and it's promoted. The old color is then scaled to
+// zero.
+type BlueGreenStrategy struct {
+	// AutoPromotionAfter promotes a preview this long after it became
+	// available. Without it, only spec.promote does.
+	// +optional
+	AutoPromotionAfter *metav1.Duration `json:"autoPromotionAfter,omitempty"`
+}
+
+// Color is one of the two Deployments of a blue/green AppService.
+// +kubebuilder:validation:Enum=blue;green
+type Color string
+
+// Values of Color.
+const (
+	ColorBlue  Color = "blue"
+	ColorGreen Color = "green"
+)
+
+// BlueGreenStatus is where a blue/green AppService stands.
+type BlueGreenStatus struct {
+	// ActiveColor is the Deployment the Service sends traffic to. It's empty
+	// until the first revision is available.
+	// +optional
+	ActiveColor Color `json:"activeColor,omitempty"`
+
+	// ActiveRevision identifies the pod template of the active color.
+	// +optional
+	ActiveRevision string `json:"activeRevision,omitempty"`
+
+	// PreviewRevision identifies the pod template being brought up on the
+	// other color, while a rollout runs.
+	// +optional
+	PreviewRevision string `json:"previewRevision,omitempty"`
+
+	// PreviewReadyTime is when all Pods of the preview became available.
+	// +optional
+	PreviewReadyTime *metav1.Time `json:"previewReadyTime,omitempty"`
+
+	// SwitchTime is when the Service last switched colors.
+	// +optional
+	SwitchTime *metav1.Time `json:"switchTime,omitempty"`
+}
+
+// MigrationStatus is a migration the Deployment waits for before it rolls
+// out a new image.
+type MigrationStatus struct {
+	// Image is the image being migrated to.
+	Image string `json:"image"`
+
+	// Job is the name of the migration Job.
+	Job string `json:"job"`
+
+	// Failed is set once the Job has failed. The Deployment stays on its
+	// image until spec.image or spec.migrate changes.
+	// +optional
+	Failed bool `json:"failed,omitempty"`
+}
+
+// DNSStatus is a record at the external DNS provider.
+type DNSStatus struct {
+	// RecordID is the provider's ID for the record.
+	// +optional
+	RecordID string `json:"recordID,omitempty"`
+
+	// FQDN is the record's name.
+	// +optional
+	FQDN string `json:"fqdn,omitempty"`
+
+	// Ready is set once the provider reports the record in sync, that is
+	// resolvable as last written.
+	// +optional
+	Ready bool `json:"ready,omitempty"`
+}
+
+// ChildReference names an object the controller made for an AppService, in
+// its namespace.
+type ChildReference struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+}
+
+// Health sums up the status of a child.
+// +kubebuilder:validation:Enum=Healthy;Progressing;Degraded
+type Health string
+
+// Values of Health.
+const (
+	// HealthHealthy: the child is as its spec asks, e.g. a Deployment whose
+	// Pods are all updated and available, or a Job that completed.
+	HealthHealthy Health = "Healthy"
+	// HealthProgressing: the child is on its way there, or the controller
+	// hasn't seen it yet.
+	HealthProgressing Health = "Progressing"
+	// HealthDegraded: the child won't get there without help, e.g. a
+	// Deployment past its progress deadline, or a failed Job.
+	HealthDegraded Health = "Degraded"
+)
+
+// DeployedResource is a child the last reconcile applied or kept, as it
+// was then.
+type DeployedResource struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+
+	// UID is the child's metadata.uid. It's empty until the controller has
+	// seen the child it created.
+	// +optional
+	UID types.UID `json:"uid,omitempty"`
+
+	// Health sums up the child's status.
+	Health Health `json:"health"`
+
+	// Message says why the child isn't healthy.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// CanaryStatus is where a canary rollout stands.
+type CanaryStatus struct {
+	// Revision identifies the pod template being rolled out.
+	Revision string `json:"revision"`
+
+	// Step is the index of the current step in spec.strategy.canary.steps.
+	Step int32 `json:"step"`
+
+	// Weight is the current step's weight.
+	Weight int32 `json:"weight"`
+
+	// StepStartTime is when the current step started.
+	StepStartTime metav1.Time `json:"stepStartTime"`
+}
+
+// WorkloadType is the kind of workload an AppService runs as.
+type WorkloadType string
+
+// StorageSpec is the PersistentVolumeClaim template of a StatefulSet.
+type StorageSpec struct {
+	// Size is the requested capacity of each Pod's volume, e.g. "1Gi".
+	// +required
+	Size resource.Quantity `json:"size"`
+
+	// StorageClassName selects the StorageClass; empty uses the cluster default.
+	// +optional
+	StorageClassName *string `json:"storageClassName,omitempty"`
+
+	// MountPath is where the volume is mounted in the container.
+	// +kubebuilder:validation:Pattern=`^/`
+	// +kubebuilder:default="/data"
+	// +optional
+	MountPath string `json:"mountPath,omitempty"`
+}
+
+// GeneratedSecretSpec describes the token in the Secret <name>-secret. The
+// token is generated once and kept; annotate the AppService with
+// webappv1.RotateSecretAnnotation to replace it.
+type GeneratedSecretSpec struct {
+	// Key is the token's key in the Secret.
+	// +kubebuilder:default=token
+	// +optional
+	Key string `json:"key,omitempty"`
+
+	// EnvName is the environment variable the container reads the token from.
+	// +kubebuilder:default=APP_TOKEN
+	// +optional
+	EnvName string `json:"envName,omitempty"`
+}
+
+// AppServiceStatus defines the observed state of AppService. It is the same
+// as in v1.
+type AppServiceStatus struct {
+	// ObservedGeneration is the .metadata.generation this status was computed
+	// for. If it lags behind, the controller hasn't caught up with the spec yet.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Replicas is the number of Pods the owned Deployment has, copied for the
+	// scale subresource (kubectl scale, HorizontalPodAutoscaler).
+	// +optional
+	Replicas int32 `json:"replicas,omitempty"`
+
+	// Selector is the label selector of the Pods, in string form, for the
+	// scale subresource. An HPA uses it to find the Pods to take metrics from.
+	// +optional
+	Selector string `json:"selector,omitempty"`
+
+	// URL is where the app is published, when spec.host is set.
+	// +optional
+	URL string `json:"url,omitempty"`
+
+	// SecretRotation is the rotate-secret annotation value the generated
+	// token was last rotated for.
+	// +optional
+	SecretRotation string `json:"secretRotation,omitempty"`
+
+	// Canary is the progress of a canary rollout, while one runs.
+	// +optional
+	Canary *CanaryStatus `json:"canary,omitempty"`
+
+	// BlueGreen is the state of spec.strategy.blueGreen.
+	// +optional
+	BlueGreen *BlueGreenStatus `json:"blueGreen,omitempty"`
+
+	// ReferencesChecksum identifies what spec.env reads from ConfigMaps and
+	// Secrets. It is stamped on the pod template, so an edit to a referenced
+	// key rolls the Pods.
+	// +optional
+	ReferencesChecksum string `json:"referencesChecksum,omitempty"`
+
+	// PinnedImage is the image the Pods run with spec.pinImageDigest: the
+	// image, tag included, and the digest the tag pointed at, as in
+	// "nginx:1.27@sha256:...".
+	// +optional
+	PinnedImage string `json:"pinnedImage,omitempty"`
+
+	// LastGoodImage is the image the Deployment last finished rolling out.
+	// +optional
+	LastGoodImage string `json:"lastGoodImage,omitempty"`
+
+	// FailedImage is an image whose rollout exceeded the progress deadline
+	// and was rolled back. As long as spec.image names it, the Pods run
+	// lastGoodImage instead.
+	// +optional
+	FailedImage string `json:"failedImage,omitempty"`
+
+	// Migration is the migration Job the Deployment waits for, while it
+	// hasn't succeeded.
+	// +optional
+	Migration *MigrationStatus `json:"migration,omitempty"`
+
+	// DNS is the app's record at the DNS provider, kept by the DNS record
+	// controller when the operator runs with --dns-zone.
+	// +optional
+	DNS *DNSStatus `json:"dns,omitempty"`
+
+	// Inventory lists the children the last reconcile applied or kept. One
+	// that drops out of it, because it was renamed or isn't wanted anymore,
+	// is deleted.
+	// +listType=atomic
+	// +optional
+	Inventory []ChildReference `json:"inventory,omitempty"`
+
+	// DeployedResources reports each child of the inventory, with its UID
+	// and health, for users and GitOps tools to see what the AppService is
+	// made of and which part of it is unwell. Only status.inventory is used
+	// for pruning.
+	// +listType=atomic
+	// +optional
+	DeployedResources []DeployedResource `json:"deployedResources,omitempty"`
+
+	// ReadyReplicas is copied from the owned Deployment.
+	// +optional
+	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
+
+	// AvailableReplicas is copied from the owned Deployment.
+	// +optional
+	AvailableReplicas int32 `json:"availableReplicas,omitempty"`
+
+	// conditions represent the current state of the AppService resource.
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:subresource:scale:specpath=.spec.replicas,statuspath=.status.replicas,selectorpath=.status.selector
+// +kubebuilder:storageversion
+// +kubebuilder:printcolumn:name="Image",type=string,JSONPath=`.spec.container.image`
+// +kubebuilder:printcolumn:name="Tag",type=string,JSONPath=`.spec.container.tag`
+// +kubebuilder:printcolumn:name="Replicas",type=integer,JSONPath=`.spec.replicas`
+// +kubebuilder:printcolumn:name="Ready",type=integer,JSONPath=`.status.readyReplicas`
+// +kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].reason`
+// +kubebuilder:printcolumn:name="Available",type=string,JSONPath=`.status.conditions[?(@.type=="Available")].status`,priority=1
+// +kubebuilder:printcolumn:name="URL",type=string,JSONPath=`.status.url`,priority=1
+// +kubebuilder:printcolumn:name="Suspended",type=boolean,JSONPath=`.spec.suspend`,priority=1
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// AppService is the Schema for the appservices API
+type AppService struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// metadata is a standard object metadata
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitzero"`
+
+	// spec defines the desired state of AppService
+	// +required
+	Spec AppServiceSpec `json:"spec"`
+
+	// status defines the observed state of AppService
+	// +optional
+	Status AppServiceStatus `json:"status,omitzero"`
+}
+
+// +kubebuilder:object:root=true
+
+// AppServiceList contains a list of AppService
+type AppServiceList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitzero"`
+	Items           []AppService `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AppService{}, &AppServiceList{})
+}
@@ -17,18 +17,31 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"crypto/tls"
+	"errors"
 	"flag"
+	"fmt"
+	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/cluster"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	"sigs.k8s.io/controller-runtime/pkg/metrics/filters"
@@ -36,7 +49,14 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	webappv1 "mydomain.com/appservice/api/v1"
+	webappv2 "mydomain.com/appservice/api/v2"
+	"mydomain.com/appservice/config"
 	"mydomain.com/appservice/internal/controller"
+	"mydomain.com/appservice/internal/digest"
+	"mydomain.com/appservice/internal/dns"
+	"mydomain.com/appservice/internal/install"
+	"mydomain.com/appservice/internal/registry"
+	webhookwebappv1 "mydomain.com/appservice/internal/webhook/v1"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -49,6 +69,7 @@ func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
 	utilruntime.Must(webappv1.AddToScheme(scheme))
+	utilruntime.Must(webappv2.AddToScheme(scheme))
 	// +kubebuilder:scaffold:scheme
 }
 
@@ -58,9 +79,21 @@ func main() {
 	var metricsCertPath, metricsCertName, metricsCertKey string
 	var webhookCertPath, webhookCertName, webhookCertKey string
 	var enableLeaderElection bool
+	var leaseDuration, renewDeadline, retryPeriod time.Duration
 	var probeAddr string
 	var secureMetrics bool
 	var enableHTTP2 bool
+	var registryWebhookURL string
+	var dryRun bool
+	var installCRDs bool
+	var spokeKubeconfig string
+	var allowedRegistries string
+	var gateway string
+	var resyncPeriod time.Duration
+	var maxConcurrentReconciles int
+	retries := controller.DefaultRateLimiterOptions()
+	var watchNamespaces, watchNamespaceSelector string
+	var dnsZone, dnsStateFile string
 	var tlsOpts []func(*tls.Config)
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
@@ -68,6 +101,13 @@ func main() {
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.DurationVar(&leaseDuration, "leader-elect-lease-duration", 15*time.Second,
+		"How long a standby waits after the leader's last renewal before taking over the lease.")
+	flag.DurationVar(&renewDeadline, "leader-elect-renew-deadline", 10*time.Second,
+		"How long the leader keeps retrying to renew the lease before it gives up leadership. "+
+			"Must be shorter than the lease duration.")
+	flag.DurationVar(&retryPeriod, "leader-elect-retry-period", 2*time.Second,
+		"How often candidates try to acquire, and the leader to renew, the lease.")
 	flag.BoolVar(&secureMetrics, "metrics-secure", true,
 		"If set, the metrics endpoint is served securely via HTTPS. Use --metrics-secure=false to use HTTP instead.")
 	flag.StringVar(&webhookCertPath, "webhook-cert-path", "", "The directory that contains the webhook certificate.")
@@ -79,6 +119,46 @@ func main() {
 	flag.StringVar(&metricsCertKey, "metrics-cert-key", "tls.key", "The name of the metrics server key file.")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.StringVar(&registryWebhookURL, "registry-webhook-url", "",
+		"Base URL of the external registry AppServices are registered in (PUT/DELETE {url}/{namespace}%2F{name}). "+
+			"Leave empty to use an in-memory registry.")
+	flag.StringVar(&allowedRegistries, "allowed-registries", "",
+		"Comma-separated registry hosts AppService images may come from (e.g. docker.io,ghcr.io). "+
+			"Leave empty to allow any registry.")
+	flag.StringVar(&gateway, "gateway", "",
+		"Publish AppServices with a host through Gateway API HTTPRoutes attached to this Gateway (<namespace>/<name>) "+
+			"instead of Ingresses. Requires the Gateway API CRDs.")
+	flag.DurationVar(&resyncPeriod, "resync-period", 10*time.Minute,
+		"Reconcile every AppService at least this often, to correct drift the watches don't see. 0 disables it.")
+	flag.IntVar(&maxConcurrentReconciles, "max-concurrent-reconciles", 1,
+		"How many AppServices are reconciled at once. Raise it when many AppServices wait in the queue.")
+	flag.DurationVar(&retries.BaseDelay, "retry-base-delay", retries.BaseDelay,
+		"First delay before an AppService that failed is retried; it doubles on each failure in a row.")
+	flag.DurationVar(&retries.MaxDelay, "retry-max-delay", retries.MaxDelay,
+		"Longest delay before an AppService that keeps failing is retried.")
+	flag.Float64Var(&retries.QPS, "retry-qps", retries.QPS,
+		"Retries per second after errors, across all AppServices.")
+	flag.IntVar(&retries.Burst, "retry-burst", retries.Burst,
+		"Retries after errors allowed at once above --retry-qps, across all AppServices.")
+	flag.StringVar(&watchNamespaces, "watch-namespaces", "",
+		"Comma-separated namespaces to watch AppServices (and their children) in. Leave empty to watch all namespaces.")
+	flag.StringVar(&watchNamespaceSelector, "watch-namespace-selector", "",
+		"Watch the namespaces matching this label selector (e.g. tenant=team-a), as they are at startup. "+
+			"Mutually exclusive with --watch-namespaces.")
+	flag.StringVar(&dnsZone, "dns-zone", "",
+		"Give each AppService a record <name>.<namespace>.<zone> at the (simulated) DNS provider. "+
+			"Leave empty to disable the DNS record controller.")
+	flag.StringVar(&dnsStateFile, "dns-state-file", "",
+		"File the simulated DNS provider keeps its records in. Leave empty to keep them in memory.")
+	flag.BoolVar(&dryRun, "dry-run", false,
+		"Change nothing: send every write as a server-side dry run, and log and record as Events what would change. "+
+			"Runs next to the operator, under a leader election lease of its own.")
+	flag.BoolVar(&installCRDs, "install-crds", true,
+		"Apply the CRDs and the manager's ClusterRole built into the binary on startup, so it runs without make install. "+
+			"The in-cluster manager, installed with kustomize, runs with --install-crds=false.")
+	flag.StringVar(&spokeKubeconfig, "spoke-kubeconfig", "",
+		"Kubeconfig of a spoke cluster to create the AppServices' children in, while the AppServices stay in this one, "+
+			"the hub. Leave empty to create them next to their AppService.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -87,6 +167,20 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	if enableLeaderElection && (renewDeadline >= leaseDuration || retryPeriod >= renewDeadline) {
+		setupLog.Error(nil, "leader election needs retry period < renew deadline < lease duration",
+			"lease-duration", leaseDuration, "renew-deadline", renewDeadline, "retry-period", retryPeriod)
+		os.Exit(1)
+	}
+
+	if maxConcurrentReconciles < 1 || retries.QPS <= 0 || retries.Burst < 1 ||
+		retries.BaseDelay <= 0 || retries.BaseDelay > retries.MaxDelay {
+		setupLog.Error(nil, "the controller needs at least one worker, a positive retry rate and retry base delay <= max delay",
+			"max-concurrent-reconciles", maxConcurrentReconciles, "retry-qps", retries.QPS, "retry-burst", retries.Burst,
+			"retry-base-delay", retries.BaseDelay, "retry-max-delay", retries.MaxDelay)
+		os.Exit(1)
+	}
+
 	// if the enable-http2 flag is false (the default), http/2 should be disabled
 	// due to its vulnerabilities. More specifically, disabling http/2 will
 	// prevent from being vulnerable to the HTTP/2 Stream Cancellation and
@@ -154,37 +248,165 @@ func main() {
 		metricsServerOptions.KeyName = metricsCertKey
 	}
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+	cfg := ctrl.GetConfigOrDie()
+	// Before anything reads AppServices: the CRDs must be served by then
+	if installCRDs && dryRun {
+		setupLog.Info("--install-crds is ignored in dry-run mode; the CRDs are left as they are")
+	}
+	if installCRDs && !dryRun {
+		if err := installBundle(cfg); err != nil {
+			setupLog.Error(err, "unable to install the CRDs")
+			os.Exit(1)
+		}
+	}
+	namespaces, err := resolveNamespaces(cfg, watchNamespaces, watchNamespaceSelector)
+	if err != nil {
+		setupLog.Error(err, "unable to resolve the namespaces to watch")
+		os.Exit(1)
+	}
+	if len(namespaces) > 0 {
+		setupLog.Info("watching a subset of namespaces", "namespaces", namespaces)
+	}
+
+	// A dry run shadows the operator, rather than waiting for its lease
+	leaderElectionID := "6d33719f.mydomain.com"
+	if dryRun {
+		leaderElectionID = "6d33719f-dry-run.mydomain.com"
+		setupLog.Info("dry-run mode: nothing is changed; see the log and the DryRun Events for what would be")
+	}
+
+	mgr, err := ctrl.NewManager(cfg, ctrl.Options{
 		Scheme:                 scheme,
+		Cache:                  controller.CacheOptions(namespaces),
 		Metrics:                metricsServerOptions,
 		WebhookServer:          webhookServer,
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
-		LeaderElectionID:       "6d33719f.mydomain.com",
+		LeaderElectionID:       leaderElectionID,
+		LeaseDuration:          &leaseDuration,
+		RenewDeadline:          &renewDeadline,
+		RetryPeriod:            &retryPeriod,
 		// LeaderElectionReleaseOnCancel defines if the leader should step down voluntarily
 		// when the Manager ends. This requires the binary to immediately end when the
 		// Manager is stopped, otherwise, this setting is unsafe. Setting this significantly
 		// speeds up voluntary leader transitions as the new leader don't have to wait
 		// LeaseDuration time first.
 		//
-		// In the default scaffold provided, the program ends immediately after
-		// the manager stops, so would be fine to enable this option. However,
-		// if you are doing or is intended to do any operation such as perform cleanups
-		// after the manager stops then its usage might be unsafe.
-		// LeaderElectionReleaseOnCancel: true,
+		// main returns as soon as the manager stops and cleans nothing up
+		// afterwards, so stepping down is safe, and makes a rolling update
+		// hand over in seconds instead of a full lease duration.
+		LeaderElectionReleaseOnCancel: true,
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
+	// The cluster the children go to, when it isn't the manager's own. Added
+	// to the manager, its cache is started and synced with the manager's.
+	var spoke cluster.Cluster
+	childMapper := mgr.GetRESTMapper()
+	if spokeKubeconfig != "" {
+		if spoke, err = newSpoke(spokeKubeconfig, namespaces); err != nil {
+			setupLog.Error(err, "unable to set up the spoke cluster")
+			os.Exit(1)
+		}
+		if err := mgr.Add(spoke); err != nil {
+			setupLog.Error(err, "unable to add the spoke cluster")
+			os.Exit(1)
+		}
+		childMapper = spoke.GetRESTMapper()
+		setupLog.Info("creating children in the spoke cluster", "kubeconfig", spokeKubeconfig)
+	}
+	// The Secrets spec.env reads, where the children are. The manager's
+	// cache only has ours; this one has them all, but only their metadata.
+	childConfig := cfg
+	if spoke != nil {
+		childConfig = spoke.GetConfig()
+	}
+	secretsCache := controller.ReferencedSecretsCacheOptions(namespaces)
+	secretsCache.Scheme = scheme
+	secretsCache.Mapper = childMapper
+	referencedSecrets, err := cache.New(childConfig, secretsCache)
+	if err != nil {
+		setupLog.Error(err, "unable to set up the referenced Secrets' cache")
+		os.Exit(1)
+	}
+	if err := mgr.Add(referencedSecrets); err != nil {
+		setupLog.Error(err, "unable to add the referenced Secrets' cache")
+		os.Exit(1)
+	}
+
+	var appRegistry registry.Registry = registry.NewMemory()
+	if registryWebhookURL != "" {
+		appRegistry = &registry.Webhook{URL: registryWebhookURL}
+	}
+	var gatewayRef *types.NamespacedName
+	if gateway != "" {
+		namespace, name, ok := strings.Cut(gateway, "/")
+		if !ok || namespace == "" || name == "" {
+			setupLog.Error(nil, "--gateway must be <namespace>/<name>", "gateway", gateway)
+			os.Exit(1)
+		}
+		gatewayRef = &types.NamespacedName{Namespace: namespace, Name: name}
+	}
+	// The Prometheus Operator is optional: without its CRDs, AppServices
+	// are still reconciled, just without ServiceMonitors. They go where the
+	// children do.
+	serviceMonitors, err := controller.ServiceMonitorsInstalled(childMapper)
+	if err != nil {
+		setupLog.Error(err, "unable to check for the ServiceMonitor CRD")
+		os.Exit(1)
+	}
+	if !serviceMonitors {
+		setupLog.Info("ServiceMonitor CRD not installed; spec.metrics won't get ServiceMonitors")
+	}
 	if err := (&controller.AppServiceReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:                  mgr.GetClient(),
+		Scheme:                  mgr.GetScheme(),
+		Registry:                appRegistry,
+		Recorder:                mgr.GetEventRecorderFor("appservice-controller"),
+		Gateway:                 gatewayRef,
+		ServiceMonitors:         serviceMonitors,
+		Digests:                 &digest.Registry{Client: &http.Client{Timeout: 10 * time.Second}},
+		ResyncPeriod:            resyncPeriod,
+		RateLimiter:             controller.NewRateLimiter(retries),
+		DryRun:                  dryRun,
+		MaxConcurrentReconciles: maxConcurrentReconciles,
+		Spoke:                   spoke,
+		ReferencedSecrets:       referencedSecrets,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "AppService")
 		os.Exit(1)
 	}
+	// The DNS provider has no dry run of its own
+	if dnsZone != "" && dryRun {
+		setupLog.Info("--dns-zone is ignored in dry-run mode; DNS records are left to the operator")
+	}
+	if dnsZone != "" && !dryRun {
+		provider, err := dns.NewFake(dnsStateFile)
+		if err != nil {
+			setupLog.Error(err, "unable to load the DNS records")
+			os.Exit(1)
+		}
+		if err := (&controller.DNSRecordReconciler{
+			Client:   mgr.GetClient(),
+			Provider: provider,
+			Zone:     dnsZone,
+			Recorder: mgr.GetEventRecorderFor("dnsrecord-controller"),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "DNSRecord")
+			os.Exit(1)
+		}
+	}
+	// nolint:goconst
+	enableWebhooks := os.Getenv("ENABLE_WEBHOOKS") != "false"
+	if enableWebhooks {
+		if err := webhookwebappv1.SetupAppServiceWebhookWithManager(mgr, splitList(allowedRegistries)); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "AppService")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -195,6 +417,23 @@ func main() {
 		setupLog.Error(err, "unable to set up ready check")
 		os.Exit(1)
 	}
+	// Every replica serves the webhooks, leader or not: it is ready for
+	// traffic once the webhook server listens.
+	if enableWebhooks {
+		if err := mgr.AddReadyzCheck("webhook", mgr.GetWebhookServer().StartedChecker()); err != nil {
+			setupLog.Error(err, "unable to set up webhook ready check")
+			os.Exit(1)
+		}
+	}
+	// /readyz/leader answers "is this replica the one reconciling?". The Pod's
+	// readinessProbe excludes it (/readyz?exclude=leader): a standby must stay
+	// Ready to serve webhooks, and with one replica a rolling update would
+	// deadlock, the new Pod waiting for a lease the old one only releases
+	// once the new Pod is Ready.
+	if err := mgr.AddReadyzCheck("leader", leaderCheck(mgr)); err != nil {
+		setupLog.Error(err, "unable to set up leader ready check")
+		os.Exit(1)
+	}
 
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
@@ -202,3 +441,84 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// leaderCheck passes once this replica has won the leader election, which is
+// right away when leader election is disabled.
+func leaderCheck(mgr ctrl.Manager) healthz.Checker {
+	return func(_ *http.Request) error {
+		select {
+		case <-mgr.Elected():
+			return nil
+		default:
+			return errors.New("not the leader")
+		}
+	}
+}
+
+// installBundle applies config.Bundle for --install-crds, with a client of
+// its own, as the manager doesn't exist yet.
+func installBundle(cfg *rest.Config) error {
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	objs, err := install.Apply(ctx, c, config.Bundle)
+	if err != nil {
+		return err
+	}
+	for _, obj := range objs {
+		setupLog.Info("Installed "+obj.GetKind(), "name", obj.GetName())
+	}
+	return nil
+}
+
+// newSpoke connects to the spoke cluster of the kubeconfig at path. Its cache
+// is scoped like the manager's: the same namespaces, by name, and only our
+// Secrets.
+func newSpoke(path string, namespaces []string) (cluster.Cluster, error) {
+	cfg, err := clientcmd.BuildConfigFromFlags("", path)
+	if err != nil {
+		return nil, fmt.Errorf("loading %s: %w", path, err)
+	}
+	return cluster.New(cfg, func(o *cluster.Options) {
+		o.Scheme = scheme
+		o.Cache = controller.CacheOptions(namespaces)
+	})
+}
+
+// resolveNamespaces turns --watch-namespaces or --watch-namespace-selector
+// into the namespaces to cache. None means the whole cluster.
+func resolveNamespaces(cfg *rest.Config, names, selector string) ([]string, error) {
+	if selector == "" {
+		return splitList(names), nil
+	}
+	if names != "" {
+		return nil, errors.New("--watch-namespaces and --watch-namespace-selector are mutually exclusive")
+	}
+	parsed, err := labels.Parse(selector)
+	if err != nil {
+		return nil, fmt.Errorf("--watch-namespace-selector: %w", err)
+	}
+	// The manager's client reads from its cache, which isn't scoped yet:
+	// ask the API server directly, once.
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return controller.NamespacesMatching(ctx, c, parsed)
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries.
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
@@ -0,0 +1,27 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package config embeds the manifests the manager can install itself with
+// --install-crds: the CRDs and the manager's ClusterRole, as generated by
+// make manifests. The rest of this directory is kustomize input.
+package config
+
+import "embed"
+
+// Bundle holds crd/bases/*.yaml and rbac/role.yaml.
+//
+//go:embed crd/bases/*.yaml rbac/role.yaml
+var Bundle embed.FS
@@ -0,0 +1,28 @@
+#!/usr/bin/env bash
+# Rewrites every AppService so etcd holds it in the current storage version
+# (v2), then records that v1 is no longer stored anywhere. Run it after
+# deploying a release that changed the storage version, and before a release
+# that stops serving v1.
+#
+# The no-op patch works because the API server re-encodes the object in the
+# storage version on every write, even when nothing changed. This is what
+# kube-storage-version-migrator does, one object at a time.
+set -euo pipefail
+
+CRD=appservices.webapp.mydomain.com
+STORAGE_VERSION=$(kubectl get crd "$CRD" \
+  -o jsonpath='{.spec.versions[?(@.storage==true)].name}')
+
+echo "Migrating ${CRD} to ${STORAGE_VERSION}"
+kubectl get "$CRD" --all-namespaces \
+  -o jsonpath='{range .items[*]}{.metadata.namespace}{" "}{.metadata.name}{"\n"}{end}' |
+while read -r namespace name; do
+  kubectl patch "$CRD" "$name" -n "$namespace" --type=merge -p '{}' >/dev/null
+  echo "  ${namespace}/${name}"
+done
+
+# Only safe once every object has been rewritten; otherwise removing v1 from
+# the CRD later would make the remaining v1-encoded objects unreadable.
+kubectl patch crd "$CRD" --subresource=status --type=merge \
+  -p "{\"status\":{\"storedVersions\":[\"${STORAGE_VERSION}\"]}}"
+kubectl get crd "$CRD" -o jsonpath='{.status.storedVersions}{"\n"}'
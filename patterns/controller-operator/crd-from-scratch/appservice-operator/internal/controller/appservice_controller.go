@@ -18,124 +18,1129 @@ package controller
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/errors"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	appsv1ac "k8s.io/client-go/applyconfigurations/apps/v1"
+	corev1ac "k8s.io/client-go/applyconfigurations/core/v1"
+	metav1ac "k8s.io/client-go/applyconfigurations/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/utils/clock"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/cluster"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	webappv1 "mydomain.com/appservice/api/v1"
+	"mydomain.com/appservice/internal/digest"
+	"mydomain.com/appservice/internal/registry"
 )
 
+// Every child object carries this label. The watches on Deployments and
+// Services filter on it, so the cache only reacts to objects we manage.
+const (
+	managedByLabel = "app.kubernetes.io/managed-by"
+	managedByValue = "appservice-operator"
+)
+
+// Standard labels the defaulting webhook puts on the AppService itself.
+const (
+	nameLabel     = "app.kubernetes.io/name"
+	instanceLabel = "app.kubernetes.io/instance"
+)
+
+// registryFinalizer holds an AppService in Terminating until its entry in the
+// external registry is gone. Owned Kubernetes objects don't need one; the
+// garbage collector follows their ownerReferences. Children in a spoke
+// cluster do, and are deleted on the way (see Spoke).
+const registryFinalizer = "webapp.mydomain.com/registry-cleanup"
+
 // AppServiceReconciler reconciles a AppService object
 type AppServiceReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+	// Registry is the external system each AppService is registered in.
+	Registry registry.Registry
+	// Recorder emits Events on the AppService, shown by kubectl describe.
+	Recorder record.EventRecorder
+	// Gateway, if set, switches routing from Ingress to Gateway API: each
+	// AppService with a host gets an HTTPRoute attached to this Gateway.
+	Gateway *types.NamespacedName
+	// ServiceMonitors is set when the Prometheus Operator's CRDs are
+	// installed (see ServiceMonitorsInstalled): only then does spec.metrics
+	// get a ServiceMonitor.
+	ServiceMonitors bool
+	// Digests resolves image tags for spec.pinImageDigest.
+	Digests digest.Resolver
+	// ResyncPeriod, if set, reconciles every AppService at least this often,
+	// whether or not a watch fired.
+	ResyncPeriod time.Duration
+	// ConflictBackoff spaces out retries after conflicts; SetupWithManager
+	// defaults it to NewConflictBackoff().
+	ConflictBackoff workqueue.TypedRateLimiter[reconcile.Request]
+	// RateLimiter spaces out retries after other errors; SetupWithManager
+	// defaults it to NewRateLimiter(DefaultRateLimiterOptions()).
+	RateLimiter workqueue.TypedRateLimiter[reconcile.Request]
+	// MaxConcurrentReconciles is how many AppServices are reconciled at
+	// once; 0 keeps controller-runtime's default of 1. The same AppService
+	// is never reconciled twice at the same time.
+	MaxConcurrentReconciles int
+	// Clock, if set, drives the controller's queue, and so when RequeueAfter
+	// and retries come due. Tests use a fake one to step through time.
+	Clock clock.WithTicker
+	// DryRun, if set, changes nothing: every write is sent as a server-side
+	// dry run, which SetupWithManager makes sure of by wrapping the client,
+	// and what would change is logged and recorded as "DryRun" Events. The
+	// external registry isn't called.
+	DryRun bool
+	// Spoke, if set, is the cluster the children go to, while AppServices
+	// are read from the manager's, the hub. Its cache must have been added
+	// to the manager, to be started with it.
+	Spoke cluster.Cluster
+	// ReferencedSecrets, if set, is where the Secrets spec.env reads are
+	// looked up and watched: a cache of every Secret where the children
+	// are, metadata only (see ReferencedSecretsCacheOptions). The children's
+	// own cache only has the Secrets we manage. It must have been added to
+	// the manager. Without it, as in the envtest suite, they are read
+	// through the children's client, and not watched.
+	ReferencedSecrets cache.Cache
+
+	// spokeClient is the Spoke's client, set by SetupWithManager (and
+	// wrapped for DryRun). Tests set it to a client of their own.
+	spokeClient client.Client
+	// ownerIndexed is set by SetupWithManager once the cache indexes
+	// children by owner. A reconciler on a plain client, as in the envtest
+	// suite, has no such index and prunes by inventory only.
+	ownerIndexed bool
 }
 
 // +kubebuilder:rbac:groups=webapp.mydomain.com,resources=appservices,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=webapp.mydomain.com,resources=appservices/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=webapp.mydomain.com,resources=appservices/finalizers,verbs=update
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=services,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups=autoscaling,resources=horizontalpodautoscalers,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=batch,resources=cronjobs,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=httproutes,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=monitoring.coreos.com,resources=servicemonitors,verbs=get;list;watch;create;update;patch;delete
 
-// Reconcile is part of the main kubernetes reconciliation loop which aims to
-// move the current state of the cluster closer to the desired state.
-// TODO(user): Modify the Reconcile function to compare the state specified by
-// the AppService object against the actual cluster state, and then
-// perform operations to make the cluster state reflect the state specified by
-// the user.
+// reconcile is part of the main kubernetes reconciliation loop which aims to
+// move the current state of the cluster closer to the desired state. The
+// requeue policy around it is in Reconcile (requeue.go).
 //
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.22.4/pkg/reconcile
-func (r *AppServiceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	l := log.FromContext(ctx)
-
+func (r *AppServiceReconciler) reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	// 1. Fetch the AppService instance (The "Instruction")
 	var appService webappv1.AppService
 	if err := r.Get(ctx, req.NamespacedName, &appService); err != nil {
+		if apierrors.IsNotFound(err) {
+			forgetMetrics(req.NamespacedName)
+		}
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
+	// The status as read, so it is only written back if this pass changed it
+	observed := appService.Status.DeepCopy()
 
-	// 2. Define the Desired Deployment (The "Goal")
-	// We want a Deployment with the same name as the AppService
-	desiredDep := &appsv1.Deployment{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      appService.Name,
-			Namespace: appService.Namespace,
-		},
-		Spec: appsv1.DeploymentSpec{
-			Replicas: &appService.Spec.Replicas,
-			Selector: &metav1.LabelSelector{
-				MatchLabels: map[string]string{"app": appService.Name},
-			},
-			Template: corev1.PodTemplateSpec{
-				ObjectMeta: metav1.ObjectMeta{
-					Labels: map[string]string{"app": appService.Name},
-				},
-				Spec: corev1.PodSpec{
-					Containers: []corev1.Container{{
-						Name:  "main",
-						Image: appService.Spec.Image,
-					}},
-				},
-			},
-		},
+	// Being deleted: clean up outside the cluster, then let it go
+	if !appService.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, r.finalize(ctx, &appService)
+	}
+	// Suspended: change nothing, only report what's there
+	if appService.Spec.Suspend {
+		if !meta.IsStatusConditionTrue(appService.Status.Conditions, webappv1.ConditionPaused) {
+			r.Recorder.Event(&appService, corev1.EventTypeNormal, "Suspended", "Reconciliation suspended; children are left as they are")
+		}
+		workload, err := r.currentWorkload(ctx, &appService)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		return r.updateStatus(ctx, &appService, observed, workload)
+	}
+	if meta.IsStatusConditionTrue(appService.Status.Conditions, webappv1.ConditionPaused) {
+		r.Recorder.Event(&appService, corev1.EventTypeNormal, "Resumed", "Reconciliation resumed")
+	}
+
+	// Persist the finalizer before registering anything it must clean up
+	if controllerutil.AddFinalizer(&appService, registryFinalizer) {
+		if err := r.Update(ctx, &appService); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// Every child applied or kept below is listed again; the ones that
+	// aren't are pruned at the end of the pass
+	previous := appService.Status.Inventory
+	appService.Status.Inventory = nil
+
+	// 2. Render spec.config first, so new Pods find their ConfigMap...
+	if err := r.reconcileConfig(ctx, &appService); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// ... and the generated Secret, for the same reason
+	if err := r.reconcileSecret(ctx, &appService); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// ... then see what spec.env reads from ConfigMaps and Secrets
+	if err := r.reconcileReferences(ctx, &appService); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// ... and which digest spec.image runs on, if it's to be pinned
+	if err := r.reconcileDigest(ctx, &appService); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// ... and whether the last rollout has to be undone
+	if err := r.reconcileRollback(ctx, &appService); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// 3. Apply the workload (The "Goal"): a Deployment, or a StatefulSet for
+	// apps with their own storage
+	var workload client.Object
+	var err error
+	if statefulSet(&appService) {
+		workload, err = r.reconcileStatefulSet(ctx, &appService)
+	} else {
+		workload, err = r.reconcileDeployment(ctx, &appService)
+	}
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// 4. Make the Pods reachable: a ClusterIP Service in front of them
+	if err := r.apply(ctx, &appService, "Service", appService.Name, &corev1.Service{}, desiredService(&appService)); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// ... and keep enough of it up during node drains
+	if err := r.reconcilePDB(ctx, &appService); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// ... and scale it with the load, if asked to
+	if err := r.reconcileAutoscaler(ctx, &appService); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// ... and only let in what spec.allowFrom names, if anything
+	if err := r.reconcileNetworkPolicies(ctx, &appService); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// ... and run its maintenance task on schedule, if it has one
+	if err := r.reconcileMaintenanceJob(ctx, &appService); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// 5. Publish it on spec.host, if any: Ingress or HTTPRoute
+	if err := r.reconcileRoute(ctx, &appService); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// ... and have Prometheus scrape it, if asked to and installed
+	if err := r.reconcileServiceMonitor(ctx, &appService); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// 6. Tell the outside world where to find it (idempotent, so every pass)
+	endpoint := fmt.Sprintf("http://%s.%s.svc:%d", appService.Name, appService.Namespace, servicePort(&appService))
+	if r.DryRun {
+		log.FromContext(ctx).Info("Dry run: would register", "key", registryKey(&appService), "endpoint", endpoint)
+	} else if err := r.Registry.Register(ctx, registryKey(&appService), endpoint); err != nil {
+		return ctrl.Result{}, fmt.Errorf("registering %s: %w", endpoint, err)
 	}
-	// Set OwnerReference (Garbage Collection glue)
-	if err := ctrl.SetControllerReference(&appService, desiredDep, r.Scheme); err != nil {
+
+	// 7. Delete what the spec no longer asks for, whatever its name was
+	if err := r.prune(ctx, &appService, previous); err != nil {
 		return ctrl.Result{}, err
 	}
 
-	// 3. Check if Deployment exists
+	// 8. Report what we observed (The "Feedback")
+	return r.updateStatus(ctx, &appService, observed, workload)
+}
+
+// fieldOwner is our field manager name in the objects' managedFields.
+const fieldOwner = client.FieldOwner("appservice-operator")
+
+// apply server-side applies desired (the child called name), reads the result
+// into obj, and reports what happened as an Event on the AppService (and in
+// the log). Whether
+// anything changed is told by the resourceVersion: an apply that changes
+// nothing is a no-op on the server and doesn't bump it.
+func (r *AppServiceReconciler) apply(ctx context.Context, appService *webappv1.AppService, kind, name string,
+	obj client.Object, desired runtime.ApplyConfiguration) error {
+	l := log.FromContext(ctx)
+	key := types.NamespacedName{Name: name, Namespace: appService.Namespace}
+	err := r.children().Get(ctx, key, obj)
+	if client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	existed, before := err == nil, obj.GetResourceVersion()
+	pending := referencesPending(appService, obj) || rollbackPending(appService, obj) ||
+		migrationPending(appService, obj)
+	if desired, err = r.forCluster(appService, desired); err != nil {
+		return err
+	}
+
+	// ForceOwnership: if someone else took over a field we manage (e.g. with
+	// kubectl edit), take it back, which is the point of reconciling.
+	if err := r.children().Apply(ctx, desired, fieldOwner, client.ForceOwnership); err != nil {
+		reason := "FailedUpdate"
+		if !existed {
+			reason = "FailedCreate"
+		}
+		r.Recorder.Eventf(appService, corev1.EventTypeWarning, reason, "%s %s: %v", kind, key.Name, err)
+		return err
+	}
+	if r.DryRun {
+		if err := r.track(appService, obj, key.Name); err != nil {
+			return err
+		}
+		return r.reportDryRun(ctx, appService, kind, key.Name, obj, existed, desired)
+	}
+	if err := r.children().Get(ctx, key, obj); client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	if err := r.track(appService, obj, key.Name); err != nil {
+		return err
+	}
+
+	switch {
+	case !existed:
+		l.Info("Created " + kind)
+		childOperationsTotal.WithLabelValues(kind, operationCreate).Inc()
+		r.Recorder.Eventf(appService, corev1.EventTypeNormal, "Created", "Created %s %s", kind, key.Name)
+	case obj.GetResourceVersion() == before:
+		// Nothing to report
+	case appService.Status.ObservedGeneration == appService.Generation && !rotationPending(appService) &&
+		!rollingOut(appService) && !pending:
+		// The spec didn't change, and no rotation, rollout, rollback or
+		// referenced config change was due, yet the child did: someone
+		// edited a field we own, and the apply put it back.
+		l.Info("Drift detected. Reverted " + kind)
+		driftCorrectionsTotal.WithLabelValues(kind).Inc()
+		childOperationsTotal.WithLabelValues(kind, operationUpdate).Inc()
+		r.Recorder.Eventf(appService, corev1.EventTypeWarning, "DriftDetected",
+			"%s %s was changed outside the operator; reverted", kind, key.Name)
+	default:
+		l.Info("Updated " + kind + " to match the spec")
+		childOperationsTotal.WithLabelValues(kind, operationUpdate).Inc()
+		r.Recorder.Eventf(appService, corev1.EventTypeNormal, "Updated", "Updated %s %s", kind, key.Name)
+	}
+	return nil
+}
+
+// currentWorkload reads the Deployment or StatefulSet without changing it.
+// A missing one is returned empty, which reports zero replicas.
+func (r *AppServiceReconciler) currentWorkload(ctx context.Context, appService *webappv1.AppService) (client.Object, error) {
+	var workload client.Object = &appsv1.Deployment{}
+	if statefulSet(appService) {
+		workload = &appsv1.StatefulSet{}
+	}
+	key := types.NamespacedName{Name: appService.Name, Namespace: appService.Namespace}
+	if color := activeColor(appService); color != "" {
+		key.Name = colorName(appService, color)
+	}
+	if err := r.children().Get(ctx, key, workload); client.IgnoreNotFound(err) != nil {
+		return nil, err
+	}
+	return workload, nil
+}
+
+// reconcileDeployment applies the Deployment and returns it as it is now.
+func (r *AppServiceReconciler) reconcileDeployment(ctx context.Context, appService *webappv1.AppService) (*appsv1.Deployment, error) {
+	// Server-Side Apply: we send only the fields we have an opinion on, and
+	// the API server merges them. Fields set by others (an HPA's replicas, a
+	// mutating webhook's sidecar) are left alone instead of being fought over.
 	foundDep := &appsv1.Deployment{}
-	err := r.Get(ctx, types.NamespacedName{Name: appService.Name, Namespace: appService.Namespace}, foundDep)
+	key := types.NamespacedName{Name: appService.Name, Namespace: appService.Namespace}
+	if err := r.children().Get(ctx, key, foundDep); client.IgnoreNotFound(err) != nil {
+		return nil, err
+	}
+	desiredDep, err := desiredDeployment(appService)
+	if err != nil {
+		return nil, err
+	}
+	if canary(appService) {
+		return foundDep, r.reconcileCanary(ctx, appService, foundDep, desiredDep)
+	}
+	if blueGreen(appService) {
+		return r.reconcileBlueGreen(ctx, appService, desiredDep.Annotations[templateHashAnnotation])
+	}
+	// A new image waits for its migration; until then, the Deployment runs
+	// on as it is, and is kept
+	migrated, err := r.reconcileMigration(ctx, appService, foundDep)
+	if err != nil {
+		return nil, err
+	}
+	if !migrated {
+		if foundDep.Name == "" {
+			return foundDep, nil
+		}
+		return foundDep, r.track(appService, foundDep, foundDep.Name)
+	}
+	if autoscaled(appService) && soleManager(foundDep, fieldOwner, "f:spec", "f:replicas") {
+		// Handing replicas over to an autoscaler: a field dropped from the
+		// apply that nobody else manages is removed, and the API server
+		// would reset it to 1. Keep the current value until the autoscaler
+		// has written it once, which makes it the manager.
+		desiredDep.Spec.WithReplicas(*foundDep.Spec.Replicas)
+	}
+	if err := r.apply(ctx, appService, "Deployment", appService.Name, foundDep, desiredDep); err != nil {
+		return nil, err
+	}
+	// Deployments left over from a strategy that has been removed
+	appService.Status.Canary = nil
+	if err := r.deleteOwned(ctx, appService, "Deployment", canaryName(appService), &appsv1.Deployment{}); err != nil {
+		return nil, err
+	}
+	if err := r.deleteColors(ctx, appService, foundDep); err != nil {
+		return nil, err
+	}
+	return foundDep, nil
+}
 
-	if err != nil && errors.IsNotFound(err) {
-		// CASE A: Deployment does not exist -> CREATE IT
-		l.Info("Creating a new Deployment", "Replicas", appService.Spec.Replicas)
-		err = r.Create(ctx, desiredDep)
+// desiredDeployment is the Deployment an AppService asks for: only the fields
+// we own. Everything left out is defaulted by the API server or owned by
+// someone else.
+func desiredDeployment(appService *webappv1.AppService) (*appsv1ac.DeploymentApplyConfiguration, error) {
+	selector := podLabels(appService)
+	spec := appsv1ac.DeploymentSpec()
+	// Under an external autoscaler we leave replicas out, giving up our
+	// ownership of the field; the HPA's writes are then no longer drift.
+	if !autoscaled(appService) {
+		spec.WithReplicas(replicas(appService))
+	}
+	if appService.Spec.ProgressDeadlineSeconds != nil {
+		spec.WithProgressDeadlineSeconds(*appService.Spec.ProgressDeadlineSeconds)
+	}
+	template, err := desiredPodTemplate(appService)
+	if err != nil {
+		return nil, err
+	}
+	revision, err := templateHash(template)
+	if err != nil {
+		return nil, err
+	}
+	return appsv1ac.Deployment(appService.Name, appService.Namespace).
+		// The label keeps the child visible to our watch (see SetupWithManager)
+		WithLabels(childLabels(appService)).
+		WithAnnotations(map[string]string{templateHashAnnotation: revision}).
+		// OwnerReference (Garbage Collection glue)
+		WithOwnerReferences(ownerReference(appService)).
+		WithSpec(spec.
+			WithSelector(metav1ac.LabelSelector().WithMatchLabels(selector)).
+			WithTemplate(template)), nil
+}
+
+// desiredPodTemplate is the pod template of the Deployment or StatefulSet.
+func desiredPodTemplate(appService *webappv1.AppService) (*corev1ac.PodTemplateSpecApplyConfiguration, error) {
+	container, err := desiredContainer(appService)
+	if err != nil {
+		return nil, err
+	}
+	template := corev1ac.PodTemplateSpec().WithLabels(podLabels(appService))
+	podSpec := corev1ac.PodSpec().WithContainers(container)
+	if err := withContainers(appService, podSpec); err != nil {
+		return nil, err
+	}
+	if len(appService.Spec.Config) > 0 {
+		checksum, err := configChecksum(appService)
 		if err != nil {
-			return ctrl.Result{}, err
+			return nil, fmt.Errorf("spec.config: %w", err)
 		}
-	} else if err == nil {
-		// CASE B: Deployment exists -> CHECK FOR DRIFT (Update)
+		template.WithAnnotations(map[string]string{configChecksumAnnotation: checksum})
+		podSpec.WithVolumes(corev1ac.Volume().
+			WithName(configVolume).
+			WithConfigMap(corev1ac.ConfigMapVolumeSource().WithName(configMapName(appService))))
+	}
+	if checksum := appService.Status.ReferencesChecksum; checksum != "" {
+		template.WithAnnotations(map[string]string{referencesChecksumAnnotation: checksum})
+	}
+	if rotation := appService.Annotations[webappv1.RotateSecretAnnotation]; appService.Spec.GeneratedSecret != nil && rotation != "" {
+		template.WithAnnotations(map[string]string{secretRotationAnnotation: rotation})
+	}
+	for _, ref := range appService.Spec.ImagePullSecretRefs {
+		podSpec.WithImagePullSecrets(corev1ac.LocalObjectReference().WithName(ref.Name))
+	}
+	constraints, err := topologySpreadConstraints(appService)
+	if err != nil {
+		return nil, err
+	}
+	podSpec.WithTopologySpreadConstraints(constraints...)
+	if err := withScheduling(appService, podSpec); err != nil {
+		return nil, err
+	}
+	return template.WithSpec(podSpec), nil
+}
 
-		shouldUpdate := false
+// withContainers adds spec.containers to the pod spec: sidecars as init
+// containers that keep running, in the order given, which is the order they
+// start in. The others follow the app's container sorted by name, so
+// reordering them in the spec doesn't change the template and roll the Pods.
+// Containers are keyed by name in the apply; none is ever told apart by its
+// index.
+func withContainers(appService *webappv1.AppService, podSpec *corev1ac.PodSpecApplyConfiguration) error {
+	order := make([]int, len(appService.Spec.Containers))
+	for i := range order {
+		order[i] = i
+	}
+	containers := appService.Spec.Containers
+	slices.SortStableFunc(order, func(a, b int) int {
+		switch {
+		case containers[a].Sidecar && containers[b].Sidecar:
+			return 0
+		case containers[a].Sidecar != containers[b].Sidecar:
+			// Sidecars first; they go to a list of their own anyway
+			if containers[a].Sidecar {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(containers[a].Name, containers[b].Name)
+	})
+	for _, i := range order {
+		spec := &containers[i]
+		container := corev1ac.Container().
+			WithName(spec.Name).
+			WithImage(spec.Image).
+			WithCommand(spec.Command...).
+			WithArgs(spec.Args...)
+		for j := range spec.Ports {
+			port := &corev1ac.ContainerPortApplyConfiguration{}
+			if err := toApplyConfiguration(&spec.Ports[j], port); err != nil {
+				return fmt.Errorf("spec.containers[%d].ports[%d]: %w", i, j, err)
+			}
+			container.WithPorts(port)
+		}
+		if spec.Sidecar {
+			podSpec.WithInitContainers(container.WithRestartPolicy(corev1.ContainerRestartPolicyAlways))
+		} else {
+			podSpec.WithContainers(container)
+		}
+	}
+	return nil
+}
+
+// desiredContainer is the app's own container, named "main". Probes and env
+// vars we don't set (or that the API server defaults, like a probe's
+// timeoutSeconds) are not ours, so they're never reported as drift.
+func desiredContainer(appService *webappv1.AppService) (*corev1ac.ContainerApplyConfiguration, error) {
+	container := corev1ac.Container().
+		WithName("main").
+		WithImage(podImage(appService)).
+		WithCommand(appService.Spec.Command...).
+		WithArgs(appService.Spec.Args...).
+		WithPorts(corev1ac.ContainerPort().
+			WithName("http").
+			WithContainerPort(servicePort(appService))).
+		WithResources(resources(appService))
+	if separateMetricsPort(appService) {
+		container.WithPorts(corev1ac.ContainerPort().
+			WithName(metricsPortName).
+			WithContainerPort(appService.Spec.Metrics.Port))
+	}
+	if len(appService.Spec.Config) > 0 {
+		container.WithVolumeMounts(corev1ac.VolumeMount().
+			WithName(configVolume).
+			WithMountPath(configMountPath).
+			WithReadOnly(true))
+	}
+	if statefulSet(appService) && appService.Spec.Storage != nil {
+		container.WithVolumeMounts(corev1ac.VolumeMount().
+			WithName(dataVolume).
+			WithMountPath(dataMountPath(appService)))
+	}
 
-		// Check 1: Are replicas correct?
-		if *foundDep.Spec.Replicas != *desiredDep.Spec.Replicas {
-			foundDep.Spec.Replicas = desiredDep.Spec.Replicas
-			shouldUpdate = true
+	for i := range appService.Spec.Env {
+		env := &corev1ac.EnvVarApplyConfiguration{}
+		if err := toApplyConfiguration(&appService.Spec.Env[i], env); err != nil {
+			return nil, fmt.Errorf("spec.env[%d]: %w", i, err)
+		}
+		container.WithEnv(env)
+	}
+	if appService.Spec.GeneratedSecret != nil {
+		container.WithEnv(corev1ac.EnvVar().
+			WithName(secretEnvName(appService)).
+			WithValueFrom(corev1ac.EnvVarSource().
+				WithSecretKeyRef(corev1ac.SecretKeySelector().
+					WithName(secretName(appService)).
+					WithKey(secretKey(appService)))))
+	}
+	if appService.Spec.LivenessProbe != nil {
+		probe := &corev1ac.ProbeApplyConfiguration{}
+		if err := toApplyConfiguration(appService.Spec.LivenessProbe, probe); err != nil {
+			return nil, fmt.Errorf("spec.livenessProbe: %w", err)
 		}
+		container.WithLivenessProbe(probe)
+	}
+	if appService.Spec.ReadinessProbe != nil {
+		probe := &corev1ac.ProbeApplyConfiguration{}
+		if err := toApplyConfiguration(appService.Spec.ReadinessProbe, probe); err != nil {
+			return nil, fmt.Errorf("spec.readinessProbe: %w", err)
+		}
+		container.WithReadinessProbe(probe)
+	}
+	return container, nil
+}
 
-		// Check 2: Is image correct?
-		currentImage := foundDep.Spec.Template.Spec.Containers[0].Image
-		desiredImage := desiredDep.Spec.Template.Spec.Containers[0].Image
-		if currentImage != desiredImage {
-			foundDep.Spec.Template.Spec.Containers[0].Image = desiredImage
-			shouldUpdate = true
+// toApplyConfiguration fills an apply configuration from the API type it
+// mirrors. Both have the same JSON shape, and the API types omit unset
+// fields, so the apply only claims what the user wrote.
+func toApplyConfiguration(in, out any) error {
+	data, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// desiredService is the ClusterIP Service in front of the Deployment. The API
+// server fills in clusterIP, ipFamilies etc.; since we don't send them, we
+// don't own them and never "fix" them.
+func desiredService(appService *webappv1.AppService) *corev1ac.ServiceApplyConfiguration {
+	spec := corev1ac.ServiceSpec().
+		WithType(corev1.ServiceTypeClusterIP).
+		WithSelector(serviceSelector(appService)).
+		WithPorts(corev1ac.ServicePort().
+			WithName("http").
+			WithProtocol(corev1.ProtocolTCP).
+			WithPort(servicePort(appService)).
+			WithTargetPort(intstr.FromString("http")))
+	if separateMetricsPort(appService) {
+		spec.WithPorts(corev1ac.ServicePort().
+			WithName(metricsPortName).
+			WithProtocol(corev1.ProtocolTCP).
+			WithPort(appService.Spec.Metrics.Port).
+			WithTargetPort(intstr.FromString(metricsPortName)))
+	}
+	return corev1ac.Service(appService.Name, appService.Namespace).
+		WithLabels(serviceLabels(appService)).
+		WithOwnerReferences(ownerReference(appService)).
+		WithSpec(spec)
+}
+
+// serviceLabels are the Service's labels: the child labels, plus the Pods'
+// "app" label, which tells the Service apart from other AppServices' for a
+// ServiceMonitor's selector.
+func serviceLabels(appService *webappv1.AppService) map[string]string {
+	labels := childLabels(appService)
+	maps.Copy(labels, podLabels(appService))
+	return labels
+}
+
+// ownerReference is what ctrl.SetControllerReference would set, as an apply
+// configuration.
+func ownerReference(appService *webappv1.AppService) *metav1ac.OwnerReferenceApplyConfiguration {
+	return metav1ac.OwnerReference().
+		WithAPIVersion(webappv1.GroupVersion.String()).
+		WithKind("AppService").
+		WithName(appService.Name).
+		WithUID(appService.UID).
+		WithController(true).
+		WithBlockOwnerDeletion(true)
+}
+
+// childLabels are the labels on every child: our managed-by label plus the
+// AppService's standard app.kubernetes.io labels (set by the defaulting
+// webhook), so "kubectl get all -l app.kubernetes.io/instance=my-app" finds
+// everything that belongs to it. Pod template labels are left alone; changing
+// them would roll every existing Deployment.
+func childLabels(appService *webappv1.AppService) map[string]string {
+	labels := map[string]string{managedByLabel: managedByValue}
+	for _, key := range []string{nameLabel, instanceLabel} {
+		if value, ok := appService.Labels[key]; ok {
+			labels[key] = value
 		}
+	}
+	return labels
+}
 
-		if shouldUpdate {
-			l.Info("Drift detected. Updating Deployment.")
-			err = r.Update(ctx, foundDep)
-			if err != nil {
-				return ctrl.Result{}, err
+// podLabels select the AppService's Pods, for the Deployment and Service
+// selectors and the scale subresource's status.selector.
+func podLabels(appService *webappv1.AppService) map[string]string {
+	return map[string]string{"app": appService.Name}
+}
+
+// autoscaled reports whether the workload's replicas are managed by an
+// autoscaler: ours, for spec.autoscaling, or an external one (see
+// webappv1.AutoscalingAnnotation).
+func autoscaled(appService *webappv1.AppService) bool {
+	return appService.Spec.Autoscaling != nil || appService.Annotations[webappv1.AutoscalingAnnotation] == "enabled"
+}
+
+// soleManager reports whether owner is the only field manager of the field
+// at path (in managedFields notation, e.g. "f:spec", "f:replicas").
+func soleManager(obj client.Object, owner client.FieldOwner, path ...string) bool {
+	var managers []string
+	for _, entry := range obj.GetManagedFields() {
+		if entry.FieldsV1 == nil {
+			continue
+		}
+		var fields map[string]any
+		if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+			continue
+		}
+		found := true
+		for _, key := range path {
+			if fields, found = fields[key].(map[string]any); !found {
+				break
 			}
 		}
+		if found {
+			managers = append(managers, entry.Manager)
+		}
+	}
+	return len(managers) == 1 && managers[0] == string(owner)
+}
+
+// replicas returns spec.replicas, falling back to the default the webhook
+// would have set, for objects created while it wasn't running.
+func replicas(appService *webappv1.AppService) int32 {
+	if appService.Spec.Replicas == nil {
+		return webappv1.DefaultReplicas
+	}
+	return *appService.Spec.Replicas
+}
+
+// resources turns spec.resources into an apply configuration, leaving out
+// empty lists so we don't claim ownership of fields we don't set.
+func resources(appService *webappv1.AppService) *corev1ac.ResourceRequirementsApplyConfiguration {
+	res := corev1ac.ResourceRequirements()
+	if len(appService.Spec.Resources.Requests) > 0 {
+		res.WithRequests(appService.Spec.Resources.Requests)
+	}
+	if len(appService.Spec.Resources.Limits) > 0 {
+		res.WithLimits(appService.Spec.Resources.Limits)
+	}
+	return res
+}
+
+// servicePort returns spec.port, falling back to the CRD default for objects
+// stored before the field existed.
+func servicePort(appService *webappv1.AppService) int32 {
+	if appService.Spec.Port == 0 {
+		return 80
+	}
+	return appService.Spec.Port
+}
+
+// finalize removes the AppService from the external registry (and its
+// children from a spoke) and then drops the finalizer, letting the API
+// server delete the object. If the registry
+// call fails, the error requeues with backoff and the object stays
+// Terminating until it succeeds.
+func (r *AppServiceReconciler) finalize(ctx context.Context, appService *webappv1.AppService) error {
+	if !controllerutil.ContainsFinalizer(appService, registryFinalizer) {
+		return nil
+	}
+	if r.DryRun {
+		// The operator that isn't dry-running deregisters it
+		log.FromContext(ctx).Info("Dry run: would deregister before deletion", "key", registryKey(appService))
+		return nil
+	}
+	if r.remote() {
+		if err := r.deleteChildren(ctx, appService); err != nil {
+			return fmt.Errorf("deleting the children in the spoke: %w", err)
+		}
+	}
+	log.FromContext(ctx).Info("Deregistering before deletion", "key", registryKey(appService))
+	if err := r.Registry.Deregister(ctx, registryKey(appService)); err != nil {
+		r.Recorder.Eventf(appService, corev1.EventTypeWarning, "FailedDeregister", "Deregistering: %v", err)
+		return fmt.Errorf("deregistering: %w", err)
+	}
+	r.Recorder.Event(appService, corev1.EventTypeNormal, "Deregistered", "Removed from the external registry")
+	controllerutil.RemoveFinalizer(appService, registryFinalizer)
+	return r.Update(ctx, appService)
+}
+
+func registryKey(appService *webappv1.AppService) string {
+	return appService.Namespace + "/" + appService.Name
+}
+
+// updateStatus mirrors the owned Deployment or StatefulSet into the
+// AppService status and writes it through the status subresource, only when
+// it differs from before.
+func (r *AppServiceReconciler) updateStatus(ctx context.Context, appService *webappv1.AppService,
+	before *webappv1.AppServiceStatus, workload client.Object) (ctrl.Result, error) {
+	status := &appService.Status
+	status.ObservedGeneration = appService.Generation
+	status.Selector = labels.SelectorFromSet(podLabels(appService)).String()
+	status.URL = appURL(appService)
+	status.SecretRotation = ""
+	if appService.Spec.GeneratedSecret != nil {
+		status.SecretRotation = appService.Annotations[webappv1.RotateSecretAnnotation]
+	}
+	if err := r.reportDeployed(ctx, appService); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	setCondition := func(condType string, ok bool, reason, message string) {
+		condStatus := metav1.ConditionFalse
+		if ok {
+			condStatus = metav1.ConditionTrue
+		}
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:               condType,
+			Status:             condStatus,
+			ObservedGeneration: appService.Generation,
+			Reason:             reason,
+			Message:            message,
+		})
+	}
+
+	// This pass went through, so nothing is stalled (any more)
+	meta.RemoveStatusCondition(&status.Conditions, webappv1.ConditionStalled)
+
+	if appService.Spec.Suspend {
+		setCondition(webappv1.ConditionPaused, true, "Suspended", "spec.suspend is set; children are not reconciled")
+	} else {
+		setCondition(webappv1.ConditionPaused, false, "Reconciling", "")
 	}
 
+	switch workload := workload.(type) {
+	case *appsv1.Deployment:
+		status.Replicas = workload.Status.Replicas
+		status.ReadyReplicas = workload.Status.ReadyReplicas
+		status.AvailableReplicas = workload.Status.AvailableReplicas
+		setDeploymentConditions(appService, workload, setCondition)
+		// Rolled back: the Deployment is healthy, but doesn't run the spec
+		if rolledBack(appService) {
+			setCondition(webappv1.ConditionDegraded, true, "RolledBack",
+				fmt.Sprintf("Rollout of %s exceeded its progress deadline; running %s",
+					status.FailedImage, status.LastGoodImage))
+		}
+		// Held back on purpose, on the image it ran before
+		if migration := status.Migration; migration != nil && migration.Failed {
+			setCondition(webappv1.ConditionDegraded, true, "MigrationFailed",
+				fmt.Sprintf("Migration Job %s failed; %s is not rolled out", migration.Job, migration.Image))
+		} else if migration != nil {
+			setCondition(webappv1.ConditionProgressing, true, "Migrating",
+				fmt.Sprintf("Waiting for migration Job %s before rolling out %s", migration.Job, migration.Image))
+		}
+		// The stable or active Deployment may be done, but the rollout isn't
+		if canary := status.Canary; canary != nil {
+			setCondition(webappv1.ConditionProgressing, true, "Canary",
+				fmt.Sprintf("Canary revision %s at %d%% (step %d)", canary.Revision, canary.Weight, canary.Step+1))
+		}
+		if progress := blueGreenProgress(appService); progress != "" {
+			setCondition(webappv1.ConditionProgressing, true, "BlueGreen", progress)
+		}
+	case *appsv1.StatefulSet:
+		status.Replicas = workload.Status.Replicas
+		status.ReadyReplicas = workload.Status.ReadyReplicas
+		status.AvailableReplicas = workload.Status.AvailableReplicas
+		setStatefulSetConditions(appService, workload, setCondition)
+	}
+	setReadyCondition(appService, setCondition)
+
+	if !equality.Semantic.DeepEqual(before, status) {
+		// Status().Update only touches .status; a plain Update would drop it
+		// because the CRD has the status subresource enabled.
+		if err := r.Status().Update(ctx, appService); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	lastReconcileTimestamp.WithLabelValues(appService.Namespace, appService.Name).SetToCurrentTime()
+
+	// A rollout is transient: check back soon rather than only on events
+	if meta.IsStatusConditionTrue(status.Conditions, webappv1.ConditionProgressing) && !appService.Spec.Suspend {
+		after := rolloutPollInterval
+		// ... or when a canary pause or a blue/green auto-promotion is due,
+		// if that comes first
+		for _, left := range []time.Duration{canaryPauseLeft(appService, r.now()), blueGreenPromotionLeft(appService, r.now())} {
+			if left > 0 && left < after {
+				after = left
+			}
+		}
+		return ctrl.Result{RequeueAfter: after}, nil
+	}
 	return ctrl.Result{}, nil
 }
 
+// setReadyCondition derives Ready from the conditions set in this pass. They
+// only say "rolled out" once the workload's controller has observed its
+// latest generation, so an Available left over from the previous spec never
+// makes a fresh change look Ready.
+func setReadyCondition(appService *webappv1.AppService, setCondition conditionSetter) {
+	conditions := appService.Status.Conditions
+	switch {
+	case appService.Spec.Suspend:
+		setCondition(webappv1.ConditionReady, false, "Suspended", "spec.suspend is set")
+	case meta.IsStatusConditionTrue(conditions, webappv1.ConditionDegraded):
+		setCondition(webappv1.ConditionReady, false, "Degraded",
+			meta.FindStatusCondition(conditions, webappv1.ConditionDegraded).Message)
+	case meta.IsStatusConditionTrue(conditions, webappv1.ConditionProgressing):
+		setCondition(webappv1.ConditionReady, false, "RollingOut", "The workload is rolling out the current spec")
+	case !meta.IsStatusConditionTrue(conditions, webappv1.ConditionAvailable):
+		setCondition(webappv1.ConditionReady, false, "Unavailable",
+			meta.FindStatusCondition(conditions, webappv1.ConditionAvailable).Message)
+	default:
+		setCondition(webappv1.ConditionReady, true, "Ready", "")
+	}
+}
+
+// conditionSetter sets one of the AppService's conditions.
+type conditionSetter func(condType string, ok bool, reason, message string)
+
+// setDeploymentConditions translates the Deployment's conditions.
+func setDeploymentConditions(appService *webappv1.AppService, dep *appsv1.Deployment, setCondition conditionSetter) {
+	// Available: straight from the Deployment controller's own verdict. The
+	// Deployment's replicas are the target, as an autoscaler may own them.
+	desired := replicas(appService)
+	if dep.Spec.Replicas != nil {
+		desired = *dep.Spec.Replicas
+	}
+	replicas := fmt.Sprintf("%d/%d replicas available", dep.Status.AvailableReplicas, desired)
+	if available := deploymentCondition(dep, appsv1.DeploymentAvailable); available != nil && available.Status == corev1.ConditionTrue {
+		setCondition(webappv1.ConditionAvailable, true, "MinimumReplicasAvailable", replicas)
+	} else {
+		setCondition(webappv1.ConditionAvailable, false, "MinimumReplicasUnavailable", replicas)
+	}
+
+	// Progressing/Degraded: the Deployment keeps Progressing=True after a
+	// rollout finished (reason NewReplicaSetAvailable), so we translate.
+	progressing := deploymentCondition(dep, appsv1.DeploymentProgressing)
+	replicaFailure := deploymentCondition(dep, appsv1.DeploymentReplicaFailure)
+	rolledOut := deploymentRolledOut(dep)
+	switch {
+	case progressing != nil && progressing.Reason == "ProgressDeadlineExceeded":
+		setCondition(webappv1.ConditionProgressing, false, "ProgressDeadlineExceeded", progressing.Message)
+		setCondition(webappv1.ConditionDegraded, true, "ProgressDeadlineExceeded", progressing.Message)
+	case replicaFailure != nil && replicaFailure.Status == corev1.ConditionTrue:
+		setCondition(webappv1.ConditionProgressing, !rolledOut, replicaFailure.Reason, replicaFailure.Message)
+		setCondition(webappv1.ConditionDegraded, true, "ReplicaFailure", replicaFailure.Message)
+	case rolledOut:
+		setCondition(webappv1.ConditionProgressing, false, "RolloutComplete", "Deployment has finished rolling out")
+		setCondition(webappv1.ConditionDegraded, false, "AsExpected", "")
+	default:
+		setCondition(webappv1.ConditionProgressing, true, "RollingOut", "Waiting for the Deployment to roll out")
+		setCondition(webappv1.ConditionDegraded, false, "AsExpected", "")
+	}
+}
+
+// deploymentRolledOut reports whether the Deployment controller has observed
+// the Deployment's latest generation and finished rolling it out.
+func deploymentRolledOut(dep *appsv1.Deployment) bool {
+	progressing := deploymentCondition(dep, appsv1.DeploymentProgressing)
+	return progressing != nil && progressing.Reason == "NewReplicaSetAvailable" &&
+		dep.Status.ObservedGeneration >= dep.Generation
+}
+
+// deploymentCondition returns the condition of the given type, or nil.
+func deploymentCondition(dep *appsv1.Deployment, condType appsv1.DeploymentConditionType) *appsv1.DeploymentCondition {
+	for i := range dep.Status.Conditions {
+		if dep.Status.Conditions[i].Type == condType {
+			return &dep.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
 // SetupWithManager sets up the controller with the Manager.
+//
+// Owns() maps an event on a child back to its controller owner, so deleting
+// or editing the Deployment or StatefulSet, a Service, ConfigMap, Secret,
+// PodDisruptionBudget, HorizontalPodAutoscaler, NetworkPolicy, CronJob, Job
+// or route reconciles the AppService and heals it.
+// With a Spoke, the children are watched in its cache instead, and mapped
+// back by their owner annotation.
+// The predicates keep the noise down:
+//   - AppService: only spec changes (generation) and annotation changes
+//     (the autoscaling switch); our own status writes would otherwise
+//     trigger a reconcile each time.
+//   - Children: only objects with our managed-by label, and only spec or
+//     label changes, deletions, workload progress (for the status), a Job
+//     finishing, or ConfigMap data changes (ConfigMaps have no generation).
 func (r *AppServiceReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&webappv1.AppService{}).
-		Named("appservice").
-		Complete(r)
+	if r.ConflictBackoff == nil {
+		r.ConflictBackoff = NewConflictBackoff()
+	}
+	if r.RateLimiter == nil {
+		r.RateLimiter = NewRateLimiter(DefaultRateLimiterOptions())
+	}
+	// The children's cluster: a spoke's own, or the manager's
+	var children cluster.Cluster = mgr
+	if r.Spoke != nil {
+		children = r.Spoke
+		r.spokeClient = r.Spoke.GetClient()
+	}
+	if r.DryRun {
+		r.Client = client.NewDryRunClient(r.Client)
+		if r.spokeClient != nil {
+			r.spokeClient = client.NewDryRunClient(r.spokeClient)
+		}
+	}
+	if err := r.setupIndexes(context.Background(), mgr.GetFieldIndexer(), children.GetFieldIndexer()); err != nil {
+		return err
+	}
+	managed, err := predicate.LabelSelectorPredicate(metav1.LabelSelector{
+		MatchLabels: map[string]string{managedByLabel: managedByValue},
+	})
+	if err != nil {
+		return err
+	}
+	childChanged := predicate.Or(
+		predicate.GenerationChangedPredicate{},
+		predicate.LabelChangedPredicate{},
+		deploymentStatusChanged(),
+		statefulSetStatusChanged(),
+	)
+
+	// The route kind depends on the mode; HTTPRoute is only watched when
+	// asked for, as its CRD may not be installed.
+	var route client.Object = &networkingv1.Ingress{}
+	if r.Gateway != nil {
+		u := &unstructured.Unstructured{}
+		u.SetGroupVersionKind(httpRouteGVK)
+		route = u
+	}
+
+	opts := controller.Options{
+		MaxConcurrentReconciles: r.MaxConcurrentReconciles,
+		RateLimiter:             observedRateLimiter{TypedRateLimiter: r.RateLimiter, reason: resultError},
+	}
+	if r.Clock != nil {
+		opts.NewQueue = func(name string, rateLimiter workqueue.TypedRateLimiter[reconcile.Request]) workqueue.TypedRateLimitingInterface[reconcile.Request] {
+			return workqueue.NewTypedRateLimitingQueueWithConfig(rateLimiter,
+				workqueue.TypedRateLimitingQueueConfig[reconcile.Request]{Name: name, Clock: r.Clock})
+		}
+	}
+
+	b := ctrl.NewControllerManagedBy(mgr).
+		WithOptions(opts).
+		For(&webappv1.AppService{}, builder.WithPredicates(predicate.Or(
+			predicate.GenerationChangedPredicate{},
+			predicate.AnnotationChangedPredicate{},
+		)))
+	// owns watches a kind of child. Children in a spoke have no
+	// ownerReference to follow, and are mapped back by their owner
+	// annotation, from the spoke's cache.
+	owns := func(obj client.Object, predicates ...predicate.Predicate) {
+		if r.Spoke == nil {
+			b = b.Owns(obj, builder.WithPredicates(predicates...))
+			return
+		}
+		b = b.WatchesRawSource(source.Kind(r.Spoke.GetCache(), obj,
+			handler.EnqueueRequestsFromMapFunc(requestForOwner), predicates...))
+	}
+	owns(&appsv1.Deployment{}, managed, childChanged)
+	owns(&appsv1.StatefulSet{}, managed, childChanged)
+	owns(&corev1.Service{}, managed, childChanged)
+	owns(&corev1.ConfigMap{}, managed, predicate.Or(childChanged, configMapDataChanged()))
+	// Secret data edits are kept (see reconcileSecret), so only deletions
+	// and label changes matter here.
+	owns(&corev1.Secret{}, managed, childChanged)
+	owns(&policyv1.PodDisruptionBudget{}, managed, childChanged)
+	owns(&networkingv1.NetworkPolicy{}, managed, childChanged)
+	owns(&autoscalingv2.HorizontalPodAutoscaler{}, managed, childChanged)
+	owns(&batchv1.CronJob{}, managed, childChanged)
+	owns(&batchv1.Job{}, managed, predicate.Or(childChanged, jobStatusChanged()))
+	owns(route, managed, childChanged)
+	// ServiceMonitors only when their CRD was there at startup: a watch on a
+	// kind the API server doesn't serve would keep the manager from starting
+	if r.ServiceMonitors {
+		monitor := &unstructured.Unstructured{}
+		monitor.SetGroupVersionKind(serviceMonitorGVK)
+		owns(monitor, managed, childChanged)
+	}
+	// ConfigMaps and Secrets that spec.env reads, mapped back to the
+	// AppServices reading them through the indexes. They are where the Pods
+	// are, so in the spoke if there is one. The children's cache only has
+	// the Secrets with our label (see CacheOptions): others are watched,
+	// and read, in ReferencedSecrets.
+	b = b.WatchesRawSource(source.Kind(children.GetCache(), client.Object(&corev1.ConfigMap{}),
+		handler.EnqueueRequestsFromMapFunc(r.appServicesReading(configMapRefIndex)), configMapDataChanged()))
+	if r.ReferencedSecrets != nil {
+		b = b.WatchesRawSource(source.Kind(r.ReferencedSecrets, client.Object(secretMetadata()),
+			handler.EnqueueRequestsFromMapFunc(r.appServicesReading(secretRefIndex))))
+	}
+	return b.Named("appservice").Complete(r)
+}
+
+// setupIndexes adds the caches' field indexes: AppServices by the ConfigMaps
+// and Secrets they read, and, in the children's cache, children by the
+// AppService controlling them.
+func (r *AppServiceReconciler) setupIndexes(ctx context.Context, indexer, children client.FieldIndexer) error {
+	if err := indexer.IndexField(ctx, &webappv1.AppService{}, configMapRefIndex, func(obj client.Object) []string {
+		return configMapRefs(obj.(*webappv1.AppService))
+	}); err != nil {
+		return err
+	}
+	if err := indexer.IndexField(ctx, &webappv1.AppService{}, secretRefIndex, func(obj client.Object) []string {
+		return secretRefs(obj.(*webappv1.AppService))
+	}); err != nil {
+		return err
+	}
+	for _, child := range ownedTypes() {
+		if err := children.IndexField(ctx, child, ownerIndex, controllerName); err != nil {
+			return err
+		}
+	}
+	r.ownerIndexed = true
+	return nil
+}
+
+// deploymentStatusChanged passes Deployment updates that change what we
+// report in the AppService status. Status updates don't bump the generation,
+// so GenerationChangedPredicate alone would leave the status stale.
+func deploymentStatusChanged() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldDep, ok := e.ObjectOld.(*appsv1.Deployment)
+			if !ok {
+				return false
+			}
+			newDep, ok := e.ObjectNew.(*appsv1.Deployment)
+			if !ok {
+				return false
+			}
+			return oldDep.Status.ObservedGeneration != newDep.Status.ObservedGeneration ||
+				oldDep.Status.Replicas != newDep.Status.Replicas ||
+				oldDep.Status.ReadyReplicas != newDep.Status.ReadyReplicas ||
+				oldDep.Status.AvailableReplicas != newDep.Status.AvailableReplicas ||
+				!equality.Semantic.DeepEqual(oldDep.Status.Conditions, newDep.Status.Conditions)
+		},
+	}
 }
@@ -18,16 +18,42 @@ package controller
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
+	clocktesting "k8s.io/utils/clock/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
 
 	webappv1 "mydomain.com/appservice/api/v1"
+	"mydomain.com/appservice/internal/digest"
+	"mydomain.com/appservice/internal/registry"
 )
 
 var _ = Describe("AppService Controller", func() {
@@ -42,7 +68,22 @@ var _ = Describe("AppService Controller", func() {
 		}
 		appservice := &webappv1.AppService{}
 
+		var (
+			reg                  *registry.Memory
+			recorder             *record.FakeRecorder
+			controllerReconciler *AppServiceReconciler
+		)
+
 		BeforeEach(func() {
+			reg = registry.NewMemory()
+			recorder = record.NewFakeRecorder(20)
+			controllerReconciler = &AppServiceReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Registry: reg,
+				Recorder: recorder,
+			}
+
 			By("creating the custom resource for the Kind AppService")
 			err := k8sClient.Get(ctx, typeNamespacedName, appservice)
 			if err != nil && errors.IsNotFound(err) {
@@ -51,7 +92,11 @@ var _ = Describe("AppService Controller", func() {
 						Name:      resourceName,
 						Namespace: "default",
 					},
-					// TODO(user): Specify other spec details if needed.
+					Spec: webappv1.AppServiceSpec{
+						Replicas: ptr.To(int32(2)),
+						Image:    "nginx:alpine",
+						Port:     8080,
+					},
 				}
 				Expect(k8sClient.Create(ctx, resource)).To(Succeed())
 			}
@@ -65,20 +110,1513 @@ var _ = Describe("AppService Controller", func() {
 
 			By("Cleanup the specific resource instance AppService")
 			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+			// Release the finalizer, as the running controller would
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, typeNamespacedName, resource))).To(BeTrue())
 		})
 		It("should successfully reconcile the resource", func() {
 			By("Reconciling the created resource")
-			controllerReconciler := &AppServiceReconciler{
-				Client: k8sClient,
-				Scheme: k8sClient.Scheme(),
-			}
-
 			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
 				NamespacedName: typeNamespacedName,
 			})
 			Expect(err).NotTo(HaveOccurred())
-			// TODO(user): Add more specific assertions depending on your controller's reconciliation logic.
-			// Example: If you expect a certain status condition after reconciliation, verify it here.
+
+			By("Checking the Deployment and the Service were created")
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			Expect(dep.Spec.Template.Spec.Containers[0].Ports[0].ContainerPort).To(Equal(int32(8080)))
+
+			svc := &corev1.Service{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, svc)).To(Succeed())
+			Expect(svc.Spec.Type).To(Equal(corev1.ServiceTypeClusterIP))
+			Expect(svc.Spec.Selector).To(Equal(map[string]string{"app": resourceName}))
+			Expect(svc.Spec.Ports).To(HaveLen(1))
+			Expect(svc.Spec.Ports[0].Port).To(Equal(int32(8080)))
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			Expect(metav1.IsControlledBy(svc, appservice)).To(BeTrue())
+
+			By("Checking the Events recorded on the AppService")
+			Expect(drainEvents(recorder)).To(Equal([]string{
+				"Normal Created Created Deployment test-resource",
+				"Normal Created Created Service test-resource",
+			}))
+		})
+
+		It("should report the Deployment's state in the status", func() {
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			// envtest runs no Deployment controller, so the rollout never
+			// starts: not available, still progressing, not degraded.
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			Expect(appservice.Status.ObservedGeneration).To(Equal(appservice.Generation))
+			Expect(appservice.Status.ReadyReplicas).To(BeZero())
+			Expect(meta.IsStatusConditionFalse(appservice.Status.Conditions, webappv1.ConditionAvailable)).To(BeTrue())
+			Expect(meta.IsStatusConditionTrue(appservice.Status.Conditions, webappv1.ConditionProgressing)).To(BeTrue())
+			Expect(meta.IsStatusConditionFalse(appservice.Status.Conditions, webappv1.ConditionDegraded)).To(BeTrue())
+			Expect(meta.IsStatusConditionFalse(appservice.Status.Conditions, webappv1.ConditionReady)).To(BeTrue())
+		})
+
+		It("should report each child with its UID and health in status.deployedResources", func() {
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			svc := &corev1.Service{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, svc)).To(Succeed())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			Expect(appservice.Status.DeployedResources).To(ConsistOf(
+				webappv1.DeployedResource{APIVersion: "apps/v1", Kind: "Deployment", Name: resourceName, UID: dep.UID,
+					Health: webappv1.HealthProgressing, Message: "0/2 replicas available"},
+				webappv1.DeployedResource{APIVersion: "v1", Kind: "Service", Name: resourceName, UID: svc.UID,
+					Health: webappv1.HealthHealthy},
+			))
+
+			By("the Deployment missing its progress deadline")
+			dep.Status = appsv1.DeploymentStatus{
+				ObservedGeneration: dep.Generation,
+				Conditions: []appsv1.DeploymentCondition{{
+					Type: appsv1.DeploymentProgressing, Status: corev1.ConditionFalse, Reason: "ProgressDeadlineExceeded",
+					Message: `ReplicaSet "test-resource-5d4f8" has timed out progressing.`,
+				}},
+			}
+			Expect(k8sClient.Status().Update(ctx, dep)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			Expect(appservice.Status.DeployedResources).To(ContainElement(webappv1.DeployedResource{
+				APIVersion: "apps/v1", Kind: "Deployment", Name: resourceName, UID: dep.UID,
+				Health: webappv1.HealthDegraded, Message: `ReplicaSet "test-resource-5d4f8" has timed out progressing.`,
+			}))
+		})
+
+		It("should only report Ready once the Deployment runs the current spec", func() {
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("faking a Deployment controller that hasn't seen the latest generation")
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			dep.Status = appsv1.DeploymentStatus{
+				ObservedGeneration: dep.Generation - 1,
+				Replicas:           2, ReadyReplicas: 2, AvailableReplicas: 2, UpdatedReplicas: 2,
+				Conditions: []appsv1.DeploymentCondition{
+					{Type: appsv1.DeploymentAvailable, Status: corev1.ConditionTrue, Reason: "MinimumReplicasAvailable"},
+					{Type: appsv1.DeploymentProgressing, Status: corev1.ConditionTrue, Reason: "NewReplicaSetAvailable"},
+				},
+			}
+			Expect(k8sClient.Status().Update(ctx, dep)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			ready := meta.FindStatusCondition(appservice.Status.Conditions, webappv1.ConditionReady)
+			Expect(ready.Status).To(Equal(metav1.ConditionFalse))
+			Expect(ready.Reason).To(Equal("RollingOut"))
+
+			By("catching up")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			dep.Status.ObservedGeneration = dep.Generation
+			Expect(k8sClient.Status().Update(ctx, dep)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			Expect(meta.IsStatusConditionTrue(appservice.Status.Conditions, webappv1.ConditionReady)).To(BeTrue())
+			Expect(appservice.Status.ObservedGeneration).To(Equal(appservice.Generation))
+		})
+
+		It("should back off after conflicts, then poll the rollout and resync", func() {
+			watchClient, err := client.NewWithWatch(cfg, client.Options{Scheme: k8sClient.Scheme()})
+			Expect(err).NotTo(HaveOccurred())
+			conflicts := 2
+			controllerReconciler.Client = interceptor.NewClient(watchClient, interceptor.Funcs{
+				Apply: func(ctx context.Context, c client.WithWatch, obj runtime.ApplyConfiguration, opts ...client.ApplyOption) error {
+					if conflicts > 0 {
+						conflicts--
+						return errors.NewConflict(appsv1.Resource("deployments"), resourceName, fmt.Errorf("the object has been modified"))
+					}
+					return c.Apply(ctx, obj, opts...)
+				},
+			})
+			controllerReconciler.ConflictBackoff = NewConflictBackoff()
+			req := reconcile.Request{NamespacedName: typeNamespacedName}
+
+			By("retrying conflicts with exponential backoff, without an error")
+			result, err := controllerReconciler.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(time.Second))
+			result, err = controllerReconciler.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(2 * time.Second))
+
+			By("succeeding, which resets the backoff; envtest never rolls out, so it polls")
+			result, err = controllerReconciler.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(rolloutPollInterval))
+			Expect(controllerReconciler.ConflictBackoff.NumRequeues(req)).To(BeZero())
+
+			By("resyncing sooner than that when configured to")
+			controllerReconciler.ResyncPeriod = time.Second
+			result, err = controllerReconciler.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeNumerically("~", time.Second, 100*time.Millisecond))
+		})
+
+		It("should back off retries after errors per AppService, up to the cap", func() {
+			limiter := NewRateLimiter(RateLimiterOptions{BaseDelay: time.Second, MaxDelay: 4 * time.Second, QPS: 100, Burst: 100})
+			req := reconcile.Request{NamespacedName: typeNamespacedName}
+			other := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "other"}}
+
+			Expect(limiter.When(req)).To(Equal(time.Second))
+			Expect(limiter.When(req)).To(Equal(2 * time.Second))
+			Expect(limiter.When(req)).To(Equal(4 * time.Second))
+			Expect(limiter.When(req)).To(Equal(4 * time.Second))
+
+			By("leaving the other AppServices' backoff alone")
+			Expect(limiter.When(other)).To(Equal(time.Second))
+
+			By("starting over after a success")
+			limiter.Forget(req)
+			Expect(limiter.When(req)).To(Equal(time.Second))
+		})
+
+		It("should put back the managed-by label the watch filters on", func() {
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			delete(dep.Labels, managedByLabel)
+			Expect(k8sClient.Update(ctx, dep)).To(Succeed())
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			Expect(dep.Labels).To(HaveKeyWithValue(managedByLabel, managedByValue))
+		})
+
+		It("should pass resources and the standard labels on to the children", func() {
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			appservice.Labels = map[string]string{instanceLabel: resourceName}
+			appservice.Spec.Resources.Requests = corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("64Mi")}
+			Expect(k8sClient.Update(ctx, appservice)).To(Succeed())
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			Expect(dep.Labels).To(HaveKeyWithValue(instanceLabel, resourceName))
+			Expect(dep.Spec.Template.Spec.Containers[0].Resources.Requests.Memory().String()).To(Equal("64Mi"))
+			svc := &corev1.Service{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, svc)).To(Succeed())
+			Expect(svc.Labels).To(HaveKeyWithValue(instanceLabel, resourceName))
+		})
+
+		It("should fall back to one replica when the webhook didn't default it", func() {
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			appservice.Spec.Replicas = nil
+			Expect(k8sClient.Update(ctx, appservice)).To(Succeed())
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			Expect(dep.Spec.Replicas).To(HaveValue(Equal(webappv1.DefaultReplicas)))
+		})
+
+		It("should be scalable through the scale subresource", func() {
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			Expect(appservice.Status.Selector).To(Equal("app=" + resourceName))
+
+			By("scaling it like kubectl scale or an HPA would")
+			scale := &autoscalingv1.Scale{Spec: autoscalingv1.ScaleSpec{Replicas: 4}}
+			Expect(k8sClient.SubResource("scale").Update(ctx, appservice, client.WithSubResourceBody(scale))).To(Succeed())
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			Expect(dep.Spec.Replicas).To(HaveValue(Equal(int32(4))))
+		})
+
+		It("should leave the replicas to an autoscaler when annotated", func() {
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("enabling autoscaling: the replicas are kept while handing over")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			appservice.Annotations = map[string]string{webappv1.AutoscalingAnnotation: "enabled"}
+			Expect(k8sClient.Update(ctx, appservice)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			Expect(dep.Spec.Replicas).To(HaveValue(Equal(int32(2))))
+
+			By("an HPA scaling the Deployment")
+			dep.Spec.Replicas = ptr.To(int32(5))
+			Expect(k8sClient.Update(ctx, dep, client.FieldOwner("horizontal-pod-autoscaler"))).To(Succeed())
+			drainEvents(recorder)
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			Expect(dep.Spec.Replicas).To(HaveValue(Equal(int32(5))))
+			Expect(drainEvents(recorder)).To(BeEmpty())
+		})
+
+		It("should hand the replicas to the HPA of spec.autoscaling, and take them back", func() {
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			appservice.Spec.Autoscaling = &webappv1.AutoscalingSpec{MinReplicas: ptr.To(int32(2)), MaxReplicas: 5}
+			Expect(k8sClient.Update(ctx, appservice)).To(Succeed())
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			hpa := &autoscalingv2.HorizontalPodAutoscaler{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, hpa)).To(Succeed())
+			Expect(metav1.IsControlledBy(hpa, appservice)).To(BeTrue())
+			Expect(hpa.Spec.ScaleTargetRef.Kind).To(Equal("Deployment"))
+			Expect(hpa.Spec.ScaleTargetRef.Name).To(Equal(resourceName))
+			Expect(hpa.Spec.MinReplicas).To(HaveValue(Equal(int32(2))))
+			Expect(hpa.Spec.MaxReplicas).To(Equal(int32(5)))
+			Expect(hpa.Spec.Metrics).To(HaveLen(1))
+			Expect(hpa.Spec.Metrics[0].Resource.Target.AverageUtilization).To(HaveValue(Equal(int32(80))))
+
+			By("the HPA scaling the Deployment")
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			dep.Spec.Replicas = ptr.To(int32(4))
+			Expect(k8sClient.Update(ctx, dep, client.FieldOwner("horizontal-pod-autoscaler"))).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			Expect(dep.Spec.Replicas).To(HaveValue(Equal(int32(4))))
+
+			By("removing spec.autoscaling")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			appservice.Spec.Autoscaling = nil
+			Expect(k8sClient.Update(ctx, appservice)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			err = k8sClient.Get(ctx, typeNamespacedName, hpa)
+			Expect(errors.IsNotFound(err) || hpa.DeletionTimestamp != nil).To(BeTrue())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			Expect(dep.Spec.Replicas).To(HaveValue(Equal(int32(2))))
+		})
+
+		It("should template env vars and probes into the Pod and revert drift", func() {
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			appservice.Spec.Env = []corev1.EnvVar{{Name: "LOG_LEVEL", Value: "debug"}}
+			appservice.Spec.ReadinessProbe = &corev1.Probe{
+				ProbeHandler: corev1.ProbeHandler{
+					HTTPGet: &corev1.HTTPGetAction{Path: "/healthz", Port: intstr.FromString("http")},
+				},
+			}
+			Expect(k8sClient.Update(ctx, appservice)).To(Succeed())
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			container := dep.Spec.Template.Spec.Containers[0]
+			Expect(container.Env).To(ConsistOf(corev1.EnvVar{Name: "LOG_LEVEL", Value: "debug"}))
+			Expect(container.ReadinessProbe.HTTPGet.Path).To(Equal("/healthz"))
+			Expect(container.LivenessProbe).To(BeNil())
+
+			By("someone editing the probe by hand")
+			dep.Spec.Template.Spec.Containers[0].ReadinessProbe.HTTPGet.Path = "/"
+			Expect(k8sClient.Update(ctx, dep)).To(Succeed())
+			drainEvents(recorder)
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			Expect(dep.Spec.Template.Spec.Containers[0].ReadinessProbe.HTTPGet.Path).To(Equal("/healthz"))
+			Expect(drainEvents(recorder)).To(ContainElement(HavePrefix("Warning DriftDetected Deployment")))
+		})
+
+		It("should run spec.containers next to the app's, matched by name", func() {
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			appservice.Spec.Command = []string{"nginx"}
+			appservice.Spec.Args = []string{"-g", "daemon off;"}
+			appservice.Spec.Containers = []webappv1.Container{
+				{Name: "proxy", Image: "envoyproxy/envoy:v1.31", Args: []string{"-c", "/etc/envoy/envoy.yaml"},
+					Ports: []corev1.ContainerPort{{Name: "proxy", ContainerPort: 15001}}},
+				{Name: "log-shipper", Image: "fluent/fluent-bit:3.1", Sidecar: true},
+				{Name: "exporter", Image: "nginx/nginx-prometheus-exporter:1.3"},
+			}
+			Expect(k8sClient.Update(ctx, appservice)).To(Succeed())
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			podSpec := dep.Spec.Template.Spec
+			names := make([]string, 0, len(podSpec.Containers))
+			for _, container := range podSpec.Containers {
+				names = append(names, container.Name)
+			}
+			Expect(names).To(Equal([]string{"main", "exporter", "proxy"}))
+			Expect(podSpec.Containers[0].Command).To(Equal([]string{"nginx"}))
+			Expect(podSpec.Containers[0].Args).To(Equal([]string{"-g", "daemon off;"}))
+			Expect(podSpec.Containers[2].Args).To(Equal([]string{"-c", "/etc/envoy/envoy.yaml"}))
+			Expect(podSpec.Containers[2].Ports).To(HaveLen(1))
+			Expect(podSpec.InitContainers).To(HaveLen(1))
+			Expect(podSpec.InitContainers[0].Name).To(Equal("log-shipper"))
+			Expect(podSpec.InitContainers[0].RestartPolicy).To(HaveValue(Equal(corev1.ContainerRestartPolicyAlways)))
+
+			By("reordering spec.containers, which leaves the Pods alone")
+			revision := dep.ResourceVersion
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			containers := appservice.Spec.Containers
+			appservice.Spec.Containers = []webappv1.Container{containers[2], containers[1], containers[0]}
+			Expect(k8sClient.Update(ctx, appservice)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			Expect(dep.ResourceVersion).To(Equal(revision))
+
+			By("removing a container")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			appservice.Spec.Containers = appservice.Spec.Containers[:1]
+			Expect(k8sClient.Update(ctx, appservice)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			Expect(dep.Spec.Template.Spec.Containers).To(HaveLen(2))
+			Expect(dep.Spec.Template.Spec.InitContainers).To(BeEmpty())
+		})
+
+		It("should mount spec.config and roll the Pods when it changes", func() {
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			appservice.Spec.Config = map[string]string{"app.yaml": "greeting: hello\n"}
+			Expect(k8sClient.Update(ctx, appservice)).To(Succeed())
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			cmName := types.NamespacedName{Name: resourceName + "-config", Namespace: "default"}
+			cm := &corev1.ConfigMap{}
+			Expect(k8sClient.Get(ctx, cmName, cm)).To(Succeed())
+			Expect(cm.Data).To(Equal(map[string]string{"app.yaml": "greeting: hello\n"}))
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			Expect(metav1.IsControlledBy(cm, appservice)).To(BeTrue())
+
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			Expect(dep.Spec.Template.Spec.Volumes).To(HaveLen(1))
+			Expect(dep.Spec.Template.Spec.Volumes[0].ConfigMap.Name).To(Equal(cmName.Name))
+			Expect(dep.Spec.Template.Spec.Containers[0].VolumeMounts).To(ConsistOf(
+				corev1.VolumeMount{Name: "config", MountPath: "/etc/app", ReadOnly: true}))
+			checksum := dep.Spec.Template.Annotations[configChecksumAnnotation]
+			Expect(checksum).NotTo(BeEmpty())
+
+			By("changing the config")
+			appservice.Spec.Config["app.yaml"] = "greeting: hi\n"
+			Expect(k8sClient.Update(ctx, appservice)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, cmName, cm)).To(Succeed())
+			Expect(cm.Data["app.yaml"]).To(Equal("greeting: hi\n"))
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			Expect(dep.Spec.Template.Annotations[configChecksumAnnotation]).NotTo(Equal(checksum))
+
+			By("removing the config again")
+			appservice.Spec.Config = nil
+			Expect(k8sClient.Update(ctx, appservice)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, cmName, cm))).To(BeTrue())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			Expect(dep.Spec.Template.Spec.Volumes).To(BeEmpty())
+			Expect(dep.Spec.Template.Annotations).NotTo(HaveKey(configChecksumAnnotation))
+		})
+
+		It("should roll the Pods when a ConfigMap or Secret that spec.env reads changes", func() {
+			shared := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "shared-settings", Namespace: "default"},
+				Data:       map[string]string{"mode": "blue"},
+			}
+			Expect(k8sClient.Create(ctx, shared)).To(Succeed())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, shared)).To(Succeed()) })
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			appservice.Spec.Env = []corev1.EnvVar{{Name: "MODE", ValueFrom: &corev1.EnvVarSource{
+				ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: shared.Name}, Key: "mode",
+				},
+			}}}
+			Expect(k8sClient.Update(ctx, appservice)).To(Succeed())
+			Expect(configMapRefs(appservice)).To(Equal([]string{shared.Name}))
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			checksum := appservice.Status.ReferencesChecksum
+			Expect(checksum).NotTo(BeEmpty())
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			Expect(dep.Spec.Template.Annotations).To(HaveKeyWithValue(referencesChecksumAnnotation, checksum))
+			drainEvents(recorder)
+
+			By("editing the ConfigMap, which the AppService doesn't own")
+			shared.Data["mode"] = "green"
+			Expect(k8sClient.Update(ctx, shared)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			Expect(dep.Spec.Template.Annotations[referencesChecksumAnnotation]).NotTo(Equal(checksum))
+			Expect(drainEvents(recorder)).NotTo(ContainElement(HavePrefix("Warning DriftDetected")))
+
+			By("dropping the reference")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			appservice.Spec.Env = nil
+			Expect(k8sClient.Update(ctx, appservice)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			Expect(dep.Spec.Template.Annotations).NotTo(HaveKey(referencesChecksumAnnotation))
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(shared), shared)).To(Succeed())
+		})
+
+		It("should expose spec.metrics, and skip the ServiceMonitor without its CRD", func() {
+			installed, err := ServiceMonitorsInstalled(k8sClient.RESTMapper())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(installed).To(BeFalse(), "envtest has no Prometheus Operator CRDs")
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			appservice.Spec.Metrics = &webappv1.MetricsSpec{Port: 9090}
+			Expect(k8sClient.Update(ctx, appservice)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			Expect(dep.Spec.Template.Spec.Containers[0].Ports).To(ContainElement(
+				corev1.ContainerPort{Name: "metrics", ContainerPort: 9090, Protocol: corev1.ProtocolTCP}))
+			svc := &corev1.Service{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, svc)).To(Succeed())
+			Expect(svc.Labels).To(HaveKeyWithValue("app", resourceName))
+			Expect(svc.Spec.Ports).To(HaveLen(2))
+			Expect(svc.Spec.Ports[1].Name).To(Equal("metrics"))
+			Expect(svc.Spec.Ports[1].TargetPort).To(Equal(intstr.FromString("metrics")))
+
+			By("scraping spec.port instead")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			appservice.Spec.Metrics.Port = 0
+			Expect(k8sClient.Update(ctx, appservice)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, svc)).To(Succeed())
+			Expect(svc.Spec.Ports).To(HaveLen(1))
+		})
+
+		It("should roll back an image whose rollout exceeds its progress deadline", func() {
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			rollOut(typeNamespacedName)
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			Expect(appservice.Status.LastGoodImage).To(Equal("nginx:alpine"))
+
+			By("rolling out an image that never gets ready")
+			appservice.Spec.Image = "nginx:broken"
+			appservice.Spec.ProgressDeadlineSeconds = ptr.To(int32(60))
+			appservice.Spec.RollbackOnFailure = true
+			Expect(k8sClient.Update(ctx, appservice)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			Expect(dep.Spec.ProgressDeadlineSeconds).To(HaveValue(Equal(int32(60))))
+			Expect(dep.Spec.Template.Spec.Containers[0].Image).To(Equal("nginx:broken"))
+
+			By("faking the Deployment controller giving up on it")
+			dep.Status = appsv1.DeploymentStatus{
+				ObservedGeneration: dep.Generation,
+				Conditions: []appsv1.DeploymentCondition{{
+					Type: appsv1.DeploymentProgressing, Status: corev1.ConditionFalse,
+					Reason: "ProgressDeadlineExceeded", Message: "ReplicaSet has timed out progressing.",
+				}},
+			}
+			Expect(k8sClient.Status().Update(ctx, dep)).To(Succeed())
+			drainEvents(recorder)
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			Expect(dep.Spec.Template.Spec.Containers[0].Image).To(Equal("nginx:alpine"))
+			events := drainEvents(recorder)
+			Expect(events).To(ContainElement(
+				"Warning RolledBack Rollout of nginx:broken exceeded its progress deadline; rolling back to nginx:alpine"))
+			Expect(events).NotTo(ContainElement(HavePrefix("Warning DriftDetected")))
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			Expect(appservice.Status.FailedImage).To(Equal("nginx:broken"))
+			degraded := meta.FindStatusCondition(appservice.Status.Conditions, webappv1.ConditionDegraded)
+			Expect(degraded.Status).To(Equal(metav1.ConditionTrue))
+			Expect(degraded.Reason).To(Equal("RolledBack"))
+
+			By("setting a new image, which gets a rollout of its own")
+			appservice.Spec.Image = "nginx:fixed"
+			Expect(k8sClient.Update(ctx, appservice)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			Expect(dep.Spec.Template.Spec.Containers[0].Image).To(Equal("nginx:fixed"))
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			Expect(appservice.Status.FailedImage).To(BeEmpty())
+		})
+
+		It("should migrate a new image before the Deployment rolls it out", func() {
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			rollOut(typeNamespacedName)
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("setting a new image to migrate")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			appservice.Spec.Image = "nginx:1.27"
+			appservice.Spec.Migrate = &webappv1.MigrateSpec{Args: []string{"migrate", "up"}}
+			Expect(k8sClient.Update(ctx, appservice)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			Expect(dep.Spec.Template.Spec.Containers[0].Image).To(Equal("nginx:alpine"))
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			migration := appservice.Status.Migration
+			Expect(migration).NotTo(BeNil())
+			Expect(migration.Image).To(Equal("nginx:1.27"))
+			progressing := meta.FindStatusCondition(appservice.Status.Conditions, webappv1.ConditionProgressing)
+			Expect(progressing.Reason).To(Equal("Migrating"))
+			job := &batchv1.Job{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: migration.Job, Namespace: "default"}, job)).To(Succeed())
+			Expect(job.Spec.Template.Spec.RestartPolicy).To(Equal(corev1.RestartPolicyNever))
+			Expect(job.Spec.Template.Spec.Containers[0].Image).To(Equal("nginx:1.27"))
+			Expect(job.Spec.Template.Spec.Containers[0].Args).To(Equal([]string{"migrate", "up"}))
+			Expect(job.Spec.Template.Spec.Containers[0].Ports).To(BeEmpty())
+
+			By("faking the Job controller finishing the migration")
+			now := metav1.Now()
+			job.Status = batchv1.JobStatus{
+				StartTime: &now, CompletionTime: &now, Succeeded: 1,
+				Conditions: []batchv1.JobCondition{
+					{Type: batchv1.JobSuccessCriteriaMet, Status: corev1.ConditionTrue},
+					{Type: batchv1.JobComplete, Status: corev1.ConditionTrue},
+				},
+			}
+			Expect(k8sClient.Status().Update(ctx, job)).To(Succeed())
+			drainEvents(recorder)
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			Expect(dep.Spec.Template.Spec.Containers[0].Image).To(Equal("nginx:1.27"))
+			events := drainEvents(recorder)
+			Expect(events).To(ContainElement(fmt.Sprintf(
+				"Normal Migrated Migration Job %s succeeded; rolling out nginx:1.27", migration.Job)))
+			Expect(events).NotTo(ContainElement(HavePrefix("Warning DriftDetected")))
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			Expect(appservice.Status.Migration).To(BeNil())
+
+			By("failing the migration of the next image")
+			appservice.Spec.Image = "nginx:1.28"
+			Expect(k8sClient.Update(ctx, appservice)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			migration = appservice.Status.Migration
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: migration.Job, Namespace: "default"}, job)).To(Succeed())
+			job.Status = batchv1.JobStatus{
+				StartTime: &now, Failed: 1,
+				Conditions: []batchv1.JobCondition{
+					{Type: batchv1.JobFailureTarget, Status: corev1.ConditionTrue},
+					{Type: batchv1.JobFailed, Status: corev1.ConditionTrue},
+				},
+			}
+			Expect(k8sClient.Status().Update(ctx, job)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			Expect(dep.Spec.Template.Spec.Containers[0].Image).To(Equal("nginx:1.27"))
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			Expect(appservice.Status.Migration.Failed).To(BeTrue())
+			degraded := meta.FindStatusCondition(appservice.Status.Conditions, webappv1.ConditionDegraded)
+			Expect(degraded.Status).To(Equal(metav1.ConditionTrue))
+			Expect(degraded.Reason).To(Equal("MigrationFailed"))
+		})
+
+		It("should run spec.maintenanceJob on its schedule, and delete it with the field", func() {
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			appservice.Spec.MaintenanceJob = &webappv1.MaintenanceJobSpec{
+				Schedule: "0 3 * * *", Command: []string{"/bin/sh", "-c", "echo cleanup"},
+			}
+			Expect(k8sClient.Update(ctx, appservice)).To(Succeed())
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			cronJob := &batchv1.CronJob{}
+			cronJobKey := types.NamespacedName{Name: resourceName + "-maintenance", Namespace: "default"}
+			Expect(k8sClient.Get(ctx, cronJobKey, cronJob)).To(Succeed())
+			Expect(cronJob.Spec.Schedule).To(Equal("0 3 * * *"))
+			Expect(cronJob.Spec.ConcurrencyPolicy).To(Equal(batchv1.ForbidConcurrent))
+			container := cronJob.Spec.JobTemplate.Spec.Template.Spec.Containers[0]
+			Expect(container.Image).To(Equal("nginx:alpine"))
+			Expect(container.Command).To(Equal([]string{"/bin/sh", "-c", "echo cleanup"}))
+			Expect(metav1.IsControlledBy(cronJob, appservice)).To(BeTrue())
+
+			By("removing spec.maintenanceJob")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			appservice.Spec.MaintenanceJob = nil
+			Expect(k8sClient.Update(ctx, appservice)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, cronJobKey, cronJob))).To(BeTrue())
+		})
+
+		It("should only report what it would change in dry-run mode", func() {
+			dryRun := &AppServiceReconciler{
+				Client:   client.NewDryRunClient(k8sClient),
+				Scheme:   k8sClient.Scheme(),
+				Registry: reg,
+				Recorder: recorder,
+				DryRun:   true,
+			}
+			_, err := dryRun.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, typeNamespacedName, &appsv1.Deployment{}))).To(BeTrue())
+			Expect(reg.Keys()).To(BeEmpty())
+			Expect(drainEvents(recorder)).To(ContainElements(
+				"Normal DryRun Would create Deployment "+resourceName,
+				"Normal DryRun Would create Service "+resourceName,
+			))
+
+			By("letting the operator create the children, then changing the image")
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			appservice.Spec.Image = "nginx:1.27"
+			Expect(k8sClient.Update(ctx, appservice)).To(Succeed())
+			drainEvents(recorder)
+			_, err = dryRun.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			Expect(dep.Spec.Template.Spec.Containers[0].Image).To(Equal("nginx:alpine"))
+			Expect(drainEvents(recorder)).To(ConsistOf(
+				"Normal DryRun Would update Deployment " + resourceName +
+					": metadata.annotations.webapp.mydomain.com/template-hash, spec.template.spec.containers",
+			))
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			Expect(appservice.Status.ObservedGeneration).To(BeNumerically("<", appservice.Generation))
+		})
+
+		It("should pin the image to the digest its tag resolves to, until the image changes", func() {
+			digests := digest.Static{
+				"nginx:alpine": "sha256:aaaa",
+				"nginx:1.27":   "sha256:bbbb",
+			}
+			controllerReconciler.Digests = digests
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			appservice.Spec.PinImageDigest = true
+			Expect(k8sClient.Update(ctx, appservice)).To(Succeed())
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			Expect(dep.Spec.Template.Spec.Containers[0].Image).To(Equal("nginx:alpine@sha256:aaaa"))
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			Expect(appservice.Status.PinnedImage).To(Equal("nginx:alpine@sha256:aaaa"))
+			Expect(drainEvents(recorder)).To(ContainElement("Normal PinnedImage Pinned nginx:alpine to sha256:aaaa"))
+
+			By("moving the tag at the registry, which the Pods don't follow")
+			digests["nginx:alpine"] = "sha256:cccc"
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			Expect(dep.Spec.Template.Spec.Containers[0].Image).To(Equal("nginx:alpine@sha256:aaaa"))
+
+			By("setting a tag the registry doesn't have")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			appservice.Spec.Image = "nginx:missing"
+			Expect(k8sClient.Update(ctx, appservice)).To(Succeed())
+			result, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred(), "a missing tag stalls the AppService rather than being retried")
+			Expect(result).To(Equal(reconcile.Result{}))
+			Expect(drainEvents(recorder)).To(ContainElement("Warning FailedResolveDigest nginx:missing: manifest unknown"))
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			stalled := meta.FindStatusCondition(appservice.Status.Conditions, webappv1.ConditionStalled)
+			Expect(stalled).NotTo(BeNil())
+			Expect(stalled.Reason).To(Equal(webappv1.ReasonImageNotFound))
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			Expect(dep.Spec.Template.Spec.Containers[0].Image).To(Equal("nginx:alpine@sha256:aaaa"))
+
+			By("setting a tag it has")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			appservice.Spec.Image = "nginx:1.27"
+			Expect(k8sClient.Update(ctx, appservice)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			Expect(dep.Spec.Template.Spec.Containers[0].Image).To(Equal("nginx:1.27@sha256:bbbb"))
+
+			By("turning pinning off")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			appservice.Spec.PinImageDigest = false
+			Expect(k8sClient.Update(ctx, appservice)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			Expect(dep.Spec.Template.Spec.Containers[0].Image).To(Equal("nginx:1.27"))
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			Expect(appservice.Status.PinnedImage).To(BeEmpty())
+		})
+
+		It("should isolate the Pods and let spec.allowFrom in", func() {
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			appservice.Spec.AllowFrom = []webappv1.NetworkPeer{{NamespaceSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"kubernetes.io/metadata.name": "ingress-nginx"},
+			}}}
+			Expect(k8sClient.Update(ctx, appservice)).To(Succeed())
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			deny := &networkingv1.NetworkPolicy{}
+			denyName := types.NamespacedName{Name: resourceName + "-default-deny", Namespace: "default"}
+			Expect(k8sClient.Get(ctx, denyName, deny)).To(Succeed())
+			Expect(deny.Spec.PodSelector.MatchLabels).To(HaveKeyWithValue("app", resourceName))
+			Expect(deny.Spec.PolicyTypes).To(Equal([]networkingv1.PolicyType{networkingv1.PolicyTypeIngress}))
+			Expect(deny.Spec.Ingress).To(BeEmpty())
+
+			allow := &networkingv1.NetworkPolicy{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, allow)).To(Succeed())
+			Expect(allow.Spec.Ingress).To(HaveLen(1))
+			Expect(allow.Spec.Ingress[0].From).To(HaveLen(1))
+			Expect(allow.Spec.Ingress[0].From[0].NamespaceSelector.MatchLabels).To(
+				HaveKeyWithValue("kubernetes.io/metadata.name", "ingress-nginx"))
+			Expect(allow.Spec.Ingress[0].Ports).To(HaveLen(1))
+			Expect(*allow.Spec.Ingress[0].Ports[0].Port).To(Equal(intstr.FromString("http")))
+
+			By("dropping both policies with spec.allowFrom")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			appservice.Spec.AllowFrom = nil
+			Expect(k8sClient.Update(ctx, appservice)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, denyName, deny))).To(BeTrue())
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, typeNamespacedName, allow))).To(BeTrue())
+		})
+
+		It("should generate a token Secret, keep it, and rotate it on demand", func() {
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			appservice.Spec.GeneratedSecret = &webappv1.GeneratedSecretSpec{}
+			appservice.Spec.ImagePullSecretRefs = []corev1.LocalObjectReference{{Name: "regcred"}}
+			Expect(k8sClient.Update(ctx, appservice)).To(Succeed())
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			secretName := types.NamespacedName{Name: resourceName + "-secret", Namespace: "default"}
+			secret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, secretName, secret)).To(Succeed())
+			token := secret.Data[webappv1.DefaultSecretKey]
+			Expect(token).To(HaveLen(43)) // 32 bytes, base64
+
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			Expect(dep.Spec.Template.Spec.ImagePullSecrets).To(ConsistOf(corev1.LocalObjectReference{Name: "regcred"}))
+			env := dep.Spec.Template.Spec.Containers[0].Env
+			Expect(env).To(HaveLen(1))
+			Expect(env[0].Name).To(Equal(webappv1.DefaultSecretEnvName))
+			Expect(env[0].ValueFrom.SecretKeyRef.Name).To(Equal(secretName.Name))
+
+			By("reconciling again, which keeps the token")
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, secretName, secret)).To(Succeed())
+			Expect(secret.Data[webappv1.DefaultSecretKey]).To(Equal(token))
+			drainEvents(recorder)
+
+			By("asking for a rotation")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			appservice.Annotations = map[string]string{webappv1.RotateSecretAnnotation: "1"}
+			Expect(k8sClient.Update(ctx, appservice)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, secretName, secret)).To(Succeed())
+			Expect(secret.Data[webappv1.DefaultSecretKey]).NotTo(Equal(token))
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			Expect(dep.Spec.Template.Annotations).To(HaveKeyWithValue("webapp.mydomain.com/secret-rotation", "1"))
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			Expect(appservice.Status.SecretRotation).To(Equal("1"))
+			events := drainEvents(recorder)
+			Expect(events).To(ContainElement("Normal SecretRotated Rotated the token in Secret test-resource-secret"))
+			Expect(events).NotTo(ContainElement(HavePrefix("Warning DriftDetected")))
+			for _, event := range events {
+				Expect(event).NotTo(ContainSubstring(string(secret.Data[webappv1.DefaultSecretKey])))
+			}
+
+			By("dropping the generated Secret again")
+			appservice.Spec.GeneratedSecret = nil
+			Expect(k8sClient.Update(ctx, appservice)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, secretName, secret))).To(BeTrue())
+		})
+
+		It("should protect and spread the Pods", func() {
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			appservice.Spec.MinAvailable = ptr.To(intstr.FromInt32(1))
+			appservice.Spec.TopologySpreadConstraints = []corev1.TopologySpreadConstraint{{
+				MaxSkew:           1,
+				TopologyKey:       "topology.kubernetes.io/zone",
+				WhenUnsatisfiable: corev1.ScheduleAnyway,
+			}}
+			Expect(k8sClient.Update(ctx, appservice)).To(Succeed())
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			pdb := &policyv1.PodDisruptionBudget{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, pdb)).To(Succeed())
+			Expect(pdb.Spec.MinAvailable).To(HaveValue(Equal(intstr.FromInt32(1))))
+			Expect(pdb.Spec.Selector.MatchLabels).To(Equal(map[string]string{"app": resourceName}))
+
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			constraints := dep.Spec.Template.Spec.TopologySpreadConstraints
+			Expect(constraints).To(HaveLen(1))
+			Expect(constraints[0].TopologyKey).To(Equal("topology.kubernetes.io/zone"))
+			Expect(constraints[0].LabelSelector.MatchLabels).To(Equal(map[string]string{"app": resourceName}))
+
+			By("removing minAvailable again")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			appservice.Spec.MinAvailable = nil
+			Expect(k8sClient.Update(ctx, appservice)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, typeNamespacedName, pdb))).To(BeTrue())
+		})
+
+		It("should schedule the Pods as asked and revert drift", func() {
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			appservice.Spec.PriorityClassName = "high-priority"
+			appservice.Spec.NodeSelector = map[string]string{"kubernetes.io/os": "linux"}
+			appservice.Spec.Tolerations = []corev1.Toleration{{
+				Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "web", Effect: corev1.TaintEffectNoSchedule,
+			}}
+			appservice.Spec.Affinity = &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+					NodeSelectorTerms: []corev1.NodeSelectorTerm{{MatchExpressions: []corev1.NodeSelectorRequirement{{
+						Key: "kubernetes.io/arch", Operator: corev1.NodeSelectorOpIn, Values: []string{"amd64", "arm64"},
+					}}}},
+				},
+			}}
+			Expect(k8sClient.Update(ctx, appservice)).To(Succeed())
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			podSpec := dep.Spec.Template.Spec
+			Expect(podSpec.PriorityClassName).To(Equal("high-priority"))
+			Expect(podSpec.NodeSelector).To(Equal(map[string]string{"kubernetes.io/os": "linux"}))
+			Expect(podSpec.Tolerations).To(Equal(appservice.Spec.Tolerations))
+			Expect(podSpec.Affinity).To(Equal(appservice.Spec.Affinity))
+
+			By("someone dropping the toleration by hand")
+			dep.Spec.Template.Spec.Tolerations = nil
+			Expect(k8sClient.Update(ctx, dep)).To(Succeed())
+			drainEvents(recorder)
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			Expect(dep.Spec.Template.Spec.Tolerations).To(Equal(appservice.Spec.Tolerations))
+			Expect(drainEvents(recorder)).To(ContainElement(HavePrefix("Warning DriftDetected Deployment")))
+
+			By("clearing the fields in the spec")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			appservice.Spec.PriorityClassName = ""
+			appservice.Spec.NodeSelector = nil
+			appservice.Spec.Tolerations = nil
+			appservice.Spec.Affinity = nil
+			Expect(k8sClient.Update(ctx, appservice)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			Expect(dep.Spec.Template.Spec.PriorityClassName).To(BeEmpty())
+			Expect(dep.Spec.Template.Spec.NodeSelector).To(BeEmpty())
+			Expect(dep.Spec.Template.Spec.Tolerations).To(BeEmpty())
+			Expect(dep.Spec.Template.Spec.Affinity).To(BeNil())
 		})
+
+		It("should stall on a spec the API server rejects, instead of retrying it", func() {
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			appservice.Spec.Tolerations = []corev1.Toleration{{
+				Key: "dedicated", Operator: corev1.TolerationOpExists, Value: "web",
+			}}
+			Expect(k8sClient.Update(ctx, appservice)).To(Succeed())
+			result, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(reconcile.Result{}))
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			Expect(appservice.Status.ObservedGeneration).To(Equal(appservice.Generation))
+			stalled := meta.FindStatusCondition(appservice.Status.Conditions, webappv1.ConditionStalled)
+			Expect(stalled).NotTo(BeNil())
+			Expect(stalled.Status).To(Equal(metav1.ConditionTrue))
+			Expect(stalled.Reason).To(Equal(webappv1.ReasonInvalidSpec))
+			Expect(stalled.Message).To(ContainSubstring("tolerations[0]"))
+			ready := meta.FindStatusCondition(appservice.Status.Conditions, webappv1.ConditionReady)
+			Expect(ready.Status).To(Equal(metav1.ConditionFalse))
+			Expect(ready.Reason).To(Equal("Stalled"))
+
+			By("fixing the spec")
+			appservice.Spec.Tolerations[0].Value = ""
+			Expect(k8sClient.Update(ctx, appservice)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			Expect(meta.FindStatusCondition(appservice.Status.Conditions, webappv1.ConditionStalled)).To(BeNil())
+			Expect(meta.FindStatusCondition(appservice.Status.Conditions, webappv1.ConditionReady).Reason).NotTo(Equal("Stalled"))
+		})
+
+		It("should roll a new image out through the canary steps", func() {
+			fakeClock := clocktesting.NewFakeClock(time.Now())
+			controllerReconciler.Clock = fakeClock
+			canaryKey := types.NamespacedName{Name: resourceName + "-canary", Namespace: "default"}
+			replicasAndImage := func(key types.NamespacedName) (int32, string) {
+				dep := &appsv1.Deployment{}
+				Expect(k8sClient.Get(ctx, key, dep)).To(Succeed())
+				return *dep.Spec.Replicas, dep.Spec.Template.Spec.Containers[0].Image
+			}
+			reconcileOnce := func() {
+				_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			appservice.Spec.Replicas = ptr.To(int32(4))
+			Expect(k8sClient.Update(ctx, appservice)).To(Succeed())
+			reconcileOnce()
+			rollOut(typeNamespacedName)
+
+			By("changing the image with a canary strategy")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			appservice.Spec.Image = "nginx:1.27"
+			appservice.Spec.Strategy = &webappv1.StrategySpec{Canary: &webappv1.CanaryStrategy{Steps: []webappv1.CanaryStep{
+				{Weight: 25, Pause: &metav1.Duration{Duration: time.Minute}},
+				{Weight: 50},
+			}}}
+			Expect(k8sClient.Update(ctx, appservice)).To(Succeed())
+			reconcileOnce()
+			replicas, image := replicasAndImage(canaryKey)
+			Expect(replicas).To(Equal(int32(1)))
+			Expect(image).To(Equal("nginx:1.27"))
+			replicas, image = replicasAndImage(typeNamespacedName)
+			Expect(replicas).To(Equal(int32(3)))
+			Expect(image).To(Equal("nginx:alpine"))
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			Expect(appservice.Status.Canary).NotTo(BeNil())
+			Expect(appservice.Status.Canary.Weight).To(Equal(int32(25)))
+			Expect(meta.FindStatusCondition(appservice.Status.Conditions, webappv1.ConditionReady).Reason).To(Equal("RollingOut"))
+
+			By("holding for the pause, even with the canary available")
+			rollOut(canaryKey)
+			fakeClock.Step(30 * time.Second)
+			reconcileOnce()
+			replicas, _ = replicasAndImage(canaryKey)
+			Expect(replicas).To(Equal(int32(1)))
+
+			By("moving on once it's over")
+			fakeClock.Step(31 * time.Second)
+			reconcileOnce()
+			replicas, _ = replicasAndImage(canaryKey)
+			Expect(replicas).To(Equal(int32(2)))
+			replicas, _ = replicasAndImage(typeNamespacedName)
+			Expect(replicas).To(Equal(int32(2)))
+
+			By("promoting the new image after the last step")
+			rollOut(canaryKey)
+			reconcileOnce()
+			replicas, image = replicasAndImage(typeNamespacedName)
+			Expect(replicas).To(Equal(int32(4)))
+			Expect(image).To(Equal("nginx:1.27"))
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			Expect(appservice.Status.Canary).To(BeNil())
+
+			By("removing the canary once the stable Pods have taken over")
+			Expect(k8sClient.Get(ctx, canaryKey, &appsv1.Deployment{})).To(Succeed())
+			rollOut(typeNamespacedName)
+			reconcileOnce()
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, canaryKey, &appsv1.Deployment{}))).To(BeTrue())
+			Expect(drainEvents(recorder)).To(ContainElements(
+				HavePrefix("Normal CanaryStarted"), HavePrefix("Normal CanaryStep"), HavePrefix("Normal CanaryPromoted")))
+		})
+
+		It("should switch the Service between blue and green once the new color is ready and promoted", func() {
+			fakeClock := clocktesting.NewFakeClock(time.Now())
+			controllerReconciler.Clock = fakeClock
+			blueKey := types.NamespacedName{Name: resourceName + "-blue", Namespace: "default"}
+			greenKey := types.NamespacedName{Name: resourceName + "-green", Namespace: "default"}
+			image := func(key types.NamespacedName) string {
+				dep := &appsv1.Deployment{}
+				Expect(k8sClient.Get(ctx, key, dep)).To(Succeed())
+				return dep.Spec.Template.Spec.Containers[0].Image
+			}
+			serviceColor := func() string {
+				svc := &corev1.Service{}
+				Expect(k8sClient.Get(ctx, typeNamespacedName, svc)).To(Succeed())
+				return svc.Spec.Selector["webapp.mydomain.com/color"]
+			}
+			reconcileOnce := func() {
+				_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+				Expect(err).NotTo(HaveOccurred())
+			}
+			reconcileOnce()
+			rollOut(typeNamespacedName)
+
+			By("moving to blue/green: blue takes over once it's available")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			appservice.Spec.Strategy = &webappv1.StrategySpec{BlueGreen: &webappv1.BlueGreenStrategy{
+				AutoPromotionAfter: &metav1.Duration{Duration: 10 * time.Minute},
+			}}
+			Expect(k8sClient.Update(ctx, appservice)).To(Succeed())
+			reconcileOnce()
+			Expect(serviceColor()).To(BeEmpty())
+			rollOut(blueKey)
+			reconcileOnce()
+			Expect(serviceColor()).To(Equal("blue"))
+			reconcileOnce()
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, typeNamespacedName, &appsv1.Deployment{}))).To(BeTrue())
+
+			By("changing the image: green comes up, blue keeps the traffic")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			appservice.Spec.Image = "nginx:1.27"
+			Expect(k8sClient.Update(ctx, appservice)).To(Succeed())
+			reconcileOnce()
+			Expect(image(greenKey)).To(Equal("nginx:1.27"))
+			Expect(image(blueKey)).To(Equal("nginx:alpine"))
+			rollOut(greenKey)
+			reconcileOnce()
+			Expect(serviceColor()).To(Equal("blue"))
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			Expect(appservice.Status.BlueGreen.PreviewReadyTime).NotTo(BeNil())
+			Expect(meta.FindStatusCondition(appservice.Status.Conditions, webappv1.ConditionProgressing).Reason).
+				To(Equal("BlueGreen"))
+
+			By("promoting it through spec.promote")
+			appservice.Spec.Promote = appservice.Status.BlueGreen.PreviewRevision
+			Expect(k8sClient.Update(ctx, appservice)).To(Succeed())
+			reconcileOnce()
+			Expect(serviceColor()).To(Equal("green"))
+			reconcileOnce()
+			blue := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, blueKey, blue)).To(Succeed())
+			Expect(*blue.Spec.Replicas).To(BeZero())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			Expect(appservice.Status.BlueGreen.ActiveColor).To(Equal(webappv1.ColorGreen))
+			Expect(appservice.Status.BlueGreen.PreviewRevision).To(BeEmpty())
+
+			By("promoting the next image on its own after autoPromotionAfter")
+			appservice.Spec.Image = "nginx:1.28"
+			Expect(k8sClient.Update(ctx, appservice)).To(Succeed())
+			reconcileOnce()
+			rollOut(blueKey)
+			reconcileOnce()
+			Expect(serviceColor()).To(Equal("green"))
+			fakeClock.Step(11 * time.Minute)
+			reconcileOnce()
+			Expect(serviceColor()).To(Equal("blue"))
+			Expect(drainEvents(recorder)).To(ContainElements(
+				HavePrefix("Normal PreviewReady"), ContainSubstring("switched from blue to green"),
+				ContainSubstring("switched from green to blue")))
+		})
+
+		It("should prune children that drop out of the inventory", func() {
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			Expect(appservice.Status.Inventory).To(Equal([]webappv1.ChildReference{
+				{APIVersion: "apps/v1", Kind: "Deployment", Name: resourceName},
+				{APIVersion: "v1", Kind: "Service", Name: resourceName},
+			}))
+
+			By("leaving behind a child under an old name, and listing one that isn't ours")
+			renamed := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: resourceName + "-old", Namespace: "default"}}
+			Expect(controllerutil.SetControllerReference(appservice, renamed, k8sClient.Scheme())).To(Succeed())
+			Expect(k8sClient.Create(ctx, renamed)).To(Succeed())
+			foreign := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: resourceName + "-foreign", Namespace: "default"}}
+			Expect(k8sClient.Create(ctx, foreign)).To(Succeed())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, foreign)).To(Succeed()) })
+			appservice.Status.Inventory = append(appservice.Status.Inventory,
+				webappv1.ChildReference{APIVersion: "v1", Kind: "ConfigMap", Name: renamed.Name},
+				webappv1.ChildReference{APIVersion: "v1", Kind: "ConfigMap", Name: foreign.Name})
+			Expect(k8sClient.Status().Update(ctx, appservice)).To(Succeed())
+			drainEvents(recorder)
+
+			By("reconciling")
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, client.ObjectKeyFromObject(renamed), &corev1.ConfigMap{}))).To(BeTrue())
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(foreign), &corev1.ConfigMap{})).To(Succeed())
+			Expect(drainEvents(recorder)).To(ConsistOf("Normal Deleted Deleted ConfigMap " + renamed.Name))
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			Expect(appservice.Status.Inventory).To(HaveLen(2))
+		})
+
+		It("should run a StatefulSet with per-Pod storage when asked to", func() {
+			statefulName := types.NamespacedName{Name: "stateful-resource", Namespace: "default"}
+			Expect(k8sClient.Create(ctx, &webappv1.AppService{
+				ObjectMeta: metav1.ObjectMeta{Name: statefulName.Name, Namespace: statefulName.Namespace},
+				Spec: webappv1.AppServiceSpec{
+					Image:        "nginx:alpine",
+					WorkloadType: webappv1.WorkloadStatefulSet,
+					Storage:      &webappv1.StorageSpec{Size: resource.MustParse("1Gi")},
+				},
+			})).To(Succeed())
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: statefulName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, statefulName, &appsv1.Deployment{}))).To(BeTrue())
+
+			sts := &appsv1.StatefulSet{}
+			Expect(k8sClient.Get(ctx, statefulName, sts)).To(Succeed())
+			Expect(sts.Spec.ServiceName).To(Equal("stateful-resource-headless"))
+			Expect(sts.Spec.VolumeClaimTemplates).To(HaveLen(1))
+			claim := sts.Spec.VolumeClaimTemplates[0]
+			Expect(claim.Name).To(Equal("data"))
+			Expect(claim.Spec.Resources.Requests.Storage().String()).To(Equal("1Gi"))
+			Expect(sts.Spec.Template.Spec.Containers[0].VolumeMounts).To(ConsistOf(
+				corev1.VolumeMount{Name: "data", MountPath: "/data"}))
+
+			headless := &corev1.Service{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "stateful-resource-headless", Namespace: "default"},
+				headless)).To(Succeed())
+			Expect(headless.Spec.ClusterIP).To(Equal(corev1.ClusterIPNone))
+
+			stateful := &webappv1.AppService{}
+			Expect(k8sClient.Get(ctx, statefulName, stateful)).To(Succeed())
+			Expect(meta.IsStatusConditionTrue(stateful.Status.Conditions, webappv1.ConditionProgressing)).To(BeTrue())
+
+			By("cleaning up")
+			Expect(k8sClient.Delete(ctx, stateful)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: statefulName})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should leave the children alone while suspended", func() {
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			drainEvents(recorder)
+
+			By("suspending, then scaling the AppService and editing the Service")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			appservice.Spec.Suspend = true
+			appservice.Spec.Replicas = ptr.To(int32(5))
+			Expect(k8sClient.Update(ctx, appservice)).To(Succeed())
+			svc := &corev1.Service{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, svc)).To(Succeed())
+			svc.Spec.Selector = map[string]string{"app": "hand-fixed"}
+			Expect(k8sClient.Update(ctx, svc)).To(Succeed())
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			Expect(dep.Spec.Replicas).To(HaveValue(Equal(int32(2))))
+			Expect(k8sClient.Get(ctx, typeNamespacedName, svc)).To(Succeed())
+			Expect(svc.Spec.Selector).To(Equal(map[string]string{"app": "hand-fixed"}))
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			Expect(meta.IsStatusConditionTrue(appservice.Status.Conditions, webappv1.ConditionPaused)).To(BeTrue())
+			Expect(drainEvents(recorder)).To(Equal([]string{
+				"Normal Suspended Reconciliation suspended; children are left as they are",
+			}))
+
+			By("resuming")
+			appservice.Spec.Suspend = false
+			Expect(k8sClient.Update(ctx, appservice)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			Expect(dep.Spec.Replicas).To(HaveValue(Equal(int32(5))))
+			Expect(k8sClient.Get(ctx, typeNamespacedName, svc)).To(Succeed())
+			Expect(svc.Spec.Selector).To(Equal(map[string]string{"app": resourceName}))
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			Expect(meta.IsStatusConditionFalse(appservice.Status.Conditions, webappv1.ConditionPaused)).To(BeTrue())
+			Expect(drainEvents(recorder)).To(ContainElement("Normal Resumed Reconciliation resumed"))
+		})
+
+		It("should publish the app on spec.host through an Ingress", func() {
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			appservice.Spec.Host = "shop.example.com"
+			appservice.Spec.Path = "/api"
+			Expect(k8sClient.Update(ctx, appservice)).To(Succeed())
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			ing := &networkingv1.Ingress{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, ing)).To(Succeed())
+			Expect(ing.Spec.Rules).To(HaveLen(1))
+			Expect(ing.Spec.Rules[0].Host).To(Equal("shop.example.com"))
+			path := ing.Spec.Rules[0].HTTP.Paths[0]
+			Expect(path.Path).To(Equal("/api"))
+			Expect(path.Backend.Service.Name).To(Equal(resourceName))
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			Expect(appservice.Status.URL).To(Equal("http://shop.example.com/api"))
+
+			By("removing the host again")
+			appservice.Spec.Host = ""
+			Expect(k8sClient.Update(ctx, appservice)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, typeNamespacedName, ing))).To(BeTrue())
+		})
+
+		It("should deregister from the external registry before deletion", func() {
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			endpoint, ok := reg.Lookup("default/" + resourceName)
+			Expect(ok).To(BeTrue())
+			Expect(endpoint).To(Equal("http://test-resource.default.svc:8080"))
+
+			By("Deleting the AppService: the finalizer keeps it around")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			Expect(appservice.Finalizers).To(ContainElement(registryFinalizer))
+			Expect(k8sClient.Delete(ctx, appservice)).To(Succeed())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			Expect(appservice.DeletionTimestamp).NotTo(BeNil())
+
+			By("Reconciling the deletion")
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reg.Keys()).To(BeEmpty())
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, typeNamespacedName, appservice))).To(BeTrue())
+
+			By("Recreating it for AfterEach")
+			Expect(k8sClient.Create(ctx, &webappv1.AppService{
+				ObjectMeta: metav1.ObjectMeta{Name: resourceName, Namespace: "default"},
+				Spec:       webappv1.AppServiceSpec{Replicas: ptr.To(int32(2)), Image: "nginx:alpine", Port: 8080},
+			})).To(Succeed())
+		})
+
+		It("should create the children in the spoke cluster, and delete them from there", func() {
+			spoke := fake.NewClientBuilder().WithScheme(k8sClient.Scheme()).Build()
+			controllerReconciler.spokeClient = spoke
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("finding the Deployment in the spoke, annotated with its AppService")
+			dep := &appsv1.Deployment{}
+			Expect(spoke.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			Expect(dep.OwnerReferences).To(BeEmpty())
+			Expect(dep.Annotations).To(HaveKeyWithValue(ownerAnnotation, "default/"+resourceName))
+			Expect(requestForOwner(ctx, dep)).To(ConsistOf(reconcile.Request{NamespacedName: typeNamespacedName}))
+			Expect(spoke.Get(ctx, typeNamespacedName, &corev1.Service{})).To(Succeed())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, appservice)).To(Succeed())
+			Expect(appservice.Status.Inventory).To(ContainElement(
+				webappv1.ChildReference{APIVersion: "apps/v1", Kind: "Deployment", Name: resourceName}))
+
+			By("deleting the AppService: the finalizer deletes the children, as no garbage collector will")
+			Expect(k8sClient.Delete(ctx, appservice)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(errors.IsNotFound(spoke.Get(ctx, typeNamespacedName, &appsv1.Deployment{}))).To(BeTrue())
+			Expect(errors.IsNotFound(spoke.Get(ctx, typeNamespacedName, &corev1.Service{}))).To(BeTrue())
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, typeNamespacedName, appservice))).To(BeTrue())
+
+			By("Recreating it for AfterEach")
+			Expect(k8sClient.Create(ctx, &webappv1.AppService{
+				ObjectMeta: metav1.ObjectMeta{Name: resourceName, Namespace: "default"},
+				Spec:       webappv1.AppServiceSpec{Replicas: ptr.To(int32(2)), Image: "nginx:alpine", Port: 8080},
+			})).To(Succeed())
+		})
+
+		It("should revert manual changes to the Service", func() {
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Pointing the Service somewhere else behind the controller's back")
+			svc := &corev1.Service{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, svc)).To(Succeed())
+			svc.Spec.Selector = map[string]string{"app": "something-else"}
+			Expect(k8sClient.Update(ctx, svc)).To(Succeed())
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, svc)).To(Succeed())
+			Expect(svc.Spec.Selector).To(Equal(map[string]string{"app": resourceName}))
+			Expect(drainEvents(recorder)).To(ContainElement(HavePrefix("Warning DriftDetected Service test-resource")))
+		})
+
+		It("should count reconciles, drift corrections and child operations", func() {
+			reconciles := testutil.ToFloat64(reconcilesTotal.WithLabelValues(resultSuccess))
+			drift := testutil.ToFloat64(driftCorrectionsTotal.WithLabelValues("Service"))
+			updates := testutil.ToFloat64(childOperationsTotal.WithLabelValues("Service", operationUpdate))
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(testutil.ToFloat64(lastReconcileTimestamp.WithLabelValues("default", resourceName))).
+				To(BeNumerically("~", float64(time.Now().Unix()), 5))
+
+			By("Changing the Service behind the controller's back")
+			svc := &corev1.Service{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, svc)).To(Succeed())
+			svc.Spec.Selector = map[string]string{"app": "something-else"}
+			Expect(k8sClient.Update(ctx, svc)).To(Succeed())
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(testutil.ToFloat64(reconcilesTotal.WithLabelValues(resultSuccess))).To(Equal(reconciles + 2))
+			Expect(testutil.ToFloat64(driftCorrectionsTotal.WithLabelValues("Service"))).To(Equal(drift + 1))
+			Expect(testutil.ToFloat64(childOperationsTotal.WithLabelValues("Service", operationUpdate))).To(Equal(updates + 1))
+
+			By("Dropping the AppService's series once it is gone")
+			series := testutil.CollectAndCount(lastReconcileTimestamp)
+			forgetMetrics(typeNamespacedName)
+			Expect(testutil.CollectAndCount(lastReconcileTimestamp)).To(Equal(series - 1))
+		})
+
+		It("should apply as its own field manager and leave other fields alone", func() {
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Another actor adding an annotation and changing the image")
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			dep.Annotations = map[string]string{"example.com/owner": "team-a"}
+			dep.Spec.Template.Spec.Containers[0].Image = "nginx:latest"
+			Expect(k8sClient.Update(ctx, dep)).To(Succeed())
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, dep)).To(Succeed())
+			Expect(dep.Spec.Template.Spec.Containers[0].Image).To(Equal("nginx:alpine"))
+			Expect(dep.Annotations).To(HaveKeyWithValue("example.com/owner", "team-a"))
+
+			managers := []string{}
+			for _, f := range dep.ManagedFields {
+				managers = append(managers, f.Manager)
+			}
+			Expect(managers).To(ContainElement(string(fieldOwner)))
+		})
+	})
+})
+
+// rollOut plays the part of the Deployment controller, which envtest doesn't
+// run: the Deployment is marked rolled out, with all its replicas available.
+func rollOut(key types.NamespacedName) {
+	dep := &appsv1.Deployment{}
+	Expect(k8sClient.Get(ctx, key, dep)).To(Succeed())
+	dep.Status = appsv1.DeploymentStatus{
+		ObservedGeneration: dep.Generation,
+		Replicas:           *dep.Spec.Replicas, ReadyReplicas: *dep.Spec.Replicas,
+		AvailableReplicas: *dep.Spec.Replicas, UpdatedReplicas: *dep.Spec.Replicas,
+		Conditions: []appsv1.DeploymentCondition{
+			{Type: appsv1.DeploymentAvailable, Status: corev1.ConditionTrue, Reason: "MinimumReplicasAvailable"},
+			{Type: appsv1.DeploymentProgressing, Status: corev1.ConditionTrue, Reason: "NewReplicaSetAvailable"},
+		},
+	}
+	Expect(k8sClient.Status().Update(ctx, dep)).To(Succeed())
+}
+
+// drainEvents returns the events recorded so far.
+func drainEvents(recorder *record.FakeRecorder) []string {
+	var events []string
+	for {
+		select {
+		case e := <-recorder.Events:
+			events = append(events, e)
+		default:
+			return events
+		}
+	}
+}
+
+var _ = Describe("desiredHTTPRoute", func() {
+	It("attaches the route to the Gateway and points it at the Service", func() {
+		app := &webappv1.AppService{
+			ObjectMeta: metav1.ObjectMeta{Name: "shop", Namespace: "apps"},
+			Spec:       webappv1.AppServiceSpec{Host: "shop.example.com", Port: 8080},
+		}
+		route := desiredHTTPRoute(app, types.NamespacedName{Namespace: "infra", Name: "public"})
+
+		Expect(route.GroupVersionKind()).To(Equal(httpRouteGVK))
+		Expect(route.GetOwnerReferences()).To(HaveLen(1))
+		parents, _, _ := unstructured.NestedSlice(route.Object, "spec", "parentRefs")
+		Expect(parents).To(ConsistOf(map[string]any{"name": "public", "namespace": "infra"}))
+		hostnames, _, _ := unstructured.NestedStringSlice(route.Object, "spec", "hostnames")
+		Expect(hostnames).To(Equal([]string{"shop.example.com"}))
+		rules, _, _ := unstructured.NestedSlice(route.Object, "spec", "rules")
+		Expect(rules).To(HaveLen(1))
+		Expect(rules[0]).To(HaveKeyWithValue("backendRefs", ConsistOf(map[string]any{"name": "shop", "port": int64(8080)})))
+		Expect(rules[0]).To(HaveKeyWithValue("matches", ConsistOf(
+			map[string]any{"path": map[string]any{"type": "PathPrefix", "value": "/"}})))
+	})
+})
+
+var _ = Describe("desiredServiceMonitor", func() {
+	It("selects the AppService's Service and scrapes its metrics port", func() {
+		app := &webappv1.AppService{
+			ObjectMeta: metav1.ObjectMeta{Name: "shop", Namespace: "apps"},
+			Spec: webappv1.AppServiceSpec{Port: 8080, Metrics: &webappv1.MetricsSpec{
+				Port: 9090, Path: "/stats", Interval: &metav1.Duration{Duration: time.Minute},
+			}},
+		}
+		monitor := desiredServiceMonitor(app)
+
+		Expect(monitor.GroupVersionKind()).To(Equal(serviceMonitorGVK))
+		Expect(monitor.GetOwnerReferences()).To(HaveLen(1))
+		selector, _, _ := unstructured.NestedStringMap(monitor.Object, "spec", "selector", "matchLabels")
+		Expect(selector).To(Equal(serviceLabels(app)))
+		endpoints, _, _ := unstructured.NestedSlice(monitor.Object, "spec", "endpoints")
+		Expect(endpoints).To(ConsistOf(map[string]any{"port": "metrics", "path": "/stats", "interval": "60s"}))
+
+		By("scraping spec.port when the metrics share it")
+		app.Spec.Metrics = &webappv1.MetricsSpec{Port: 8080}
+		endpoints, _, _ = unstructured.NestedSlice(desiredServiceMonitor(app).Object, "spec", "endpoints")
+		Expect(endpoints).To(ConsistOf(map[string]any{"port": "http", "path": "/metrics"}))
+	})
+})
+
+var _ = Describe("deploymentStatusChanged", func() {
+	p := deploymentStatusChanged()
+	dep := func(ready int32) *appsv1.Deployment {
+		return &appsv1.Deployment{Status: appsv1.DeploymentStatus{ReadyReplicas: ready}}
+	}
+
+	It("passes updates that change the replica counts", func() {
+		Expect(p.Update(event.UpdateEvent{ObjectOld: dep(1), ObjectNew: dep(2)})).To(BeTrue())
+	})
+
+	It("drops updates that leave the status alone", func() {
+		Expect(p.Update(event.UpdateEvent{ObjectOld: dep(2), ObjectNew: dep(2)})).To(BeFalse())
+	})
+})
+
+var _ = Describe("Namespace scoping", func() {
+	It("caches the given namespaces, or all of them", func() {
+		Expect(CacheOptions(nil).DefaultNamespaces).To(BeEmpty())
+		Expect(CacheOptions([]string{"team-a", "team-b"}).DefaultNamespaces).To(SatisfyAll(
+			HaveLen(2), HaveKey("team-a"), HaveKey("team-b")))
+	})
+
+	It("caches every referenced Secret in the same namespaces, not just ours", func() {
+		opts := ReferencedSecretsCacheOptions([]string{"team-a"})
+		Expect(opts.DefaultNamespaces).To(SatisfyAll(HaveLen(1), HaveKey("team-a")))
+		Expect(opts.ByObject).To(BeEmpty())
+		Expect(CacheOptions([]string{"team-a"}).ByObject).To(HaveLen(1))
+	})
+
+	It("resolves a label selector to the namespaces matching it", func() {
+		Expect(k8sClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name: "tenant-a", Labels: map[string]string{"tenant": "a"},
+		}})).To(Succeed())
+
+		namespaces, err := NamespacesMatching(ctx, k8sClient, labels.SelectorFromSet(labels.Set{"tenant": "a"}))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(namespaces).To(Equal([]string{"tenant-a"}))
+
+		By("refusing a selector nothing matches, rather than watching everything")
+		_, err = NamespacesMatching(ctx, k8sClient, labels.SelectorFromSet(labels.Set{"tenant": "nobody"}))
+		Expect(err).To(MatchError(ContainSubstring("no namespace matches")))
 	})
 })
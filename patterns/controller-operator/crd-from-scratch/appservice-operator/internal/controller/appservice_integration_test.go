@@ -0,0 +1,275 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clocktesting "k8s.io/utils/clock/testing"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/config"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+
+	webappv1 "mydomain.com/appservice/api/v1"
+	"mydomain.com/appservice/internal/registry"
+)
+
+// These tests run the controller the way a cluster does: in a manager,
+// driven by its watches, rather than by calling Reconcile. The manager only
+// caches its own namespace, so the tests above, which reconcile by hand,
+// don't race with it.
+//
+// The controller's queue runs on a fake clock, stepped a second at a time
+// in the background: retries and conflict backoff come due within
+// milliseconds, while the hour-long resync only happens when a test steps
+// over it.
+var _ = Describe("AppService controller in a manager", Ordered, func() {
+	const namespace = "integration"
+	key := types.NamespacedName{Name: "shop", Namespace: namespace}
+
+	var (
+		reg       *registry.Memory
+		fakeClock *clocktesting.FakeClock
+		stop      context.CancelFunc
+	)
+
+	BeforeAll(func() {
+		Expect(k8sClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}})).To(Succeed())
+
+		mgr, err := ctrl.NewManager(cfg, ctrl.Options{
+			Scheme:     k8sClient.Scheme(),
+			Cache:      CacheOptions([]string{namespace}),
+			Metrics:    metricsserver.Options{BindAddress: "0"},
+			Controller: config.Controller{SkipNameValidation: ptr.To(true)},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		secretsCache := ReferencedSecretsCacheOptions([]string{namespace})
+		secretsCache.Scheme = mgr.GetScheme()
+		secretsCache.Mapper = mgr.GetRESTMapper()
+		referencedSecrets, err := cache.New(cfg, secretsCache)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(mgr.Add(referencedSecrets)).To(Succeed())
+
+		reg = registry.NewMemory()
+		fakeClock = clocktesting.NewFakeClock(time.Now())
+		Expect((&AppServiceReconciler{
+			Client:            mgr.GetClient(),
+			Scheme:            mgr.GetScheme(),
+			Registry:          reg,
+			Recorder:          mgr.GetEventRecorderFor("appservice-controller"),
+			ResyncPeriod:      time.Hour,
+			Clock:             fakeClock,
+			ReferencedSecrets: referencedSecrets,
+		}).SetupWithManager(mgr)).To(Succeed())
+
+		var mgrCtx context.Context
+		mgrCtx, stop = context.WithCancel(ctx)
+		go func() {
+			defer GinkgoRecover()
+			Expect(mgr.Start(mgrCtx)).To(Succeed())
+		}()
+		go func() {
+			ticker := time.NewTicker(50 * time.Millisecond)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-mgrCtx.Done():
+					return
+				case <-ticker.C:
+					fakeClock.Step(time.Second)
+				}
+			}
+		}()
+	})
+
+	AfterAll(func() {
+		stop()
+	})
+
+	It("creates the children, registers the app and reports status", func() {
+		Expect(k8sClient.Create(ctx, &webappv1.AppService{
+			ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+			Spec:       webappv1.AppServiceSpec{Replicas: ptr.To(int32(2)), Image: "nginx:alpine", Port: 8080},
+		})).To(Succeed())
+
+		Eventually(func(g Gomega) {
+			dep := &appsv1.Deployment{}
+			g.Expect(k8sClient.Get(ctx, key, dep)).To(Succeed())
+			g.Expect(dep.Spec.Replicas).To(Equal(ptr.To(int32(2))))
+			g.Expect(k8sClient.Get(ctx, key, &corev1.Service{})).To(Succeed())
+		}).Should(Succeed())
+
+		Eventually(func(g Gomega) {
+			app := &webappv1.AppService{}
+			g.Expect(k8sClient.Get(ctx, key, app)).To(Succeed())
+			g.Expect(app.Finalizers).To(ContainElement(registryFinalizer))
+			g.Expect(app.Status.ObservedGeneration).To(Equal(app.Generation))
+			g.Expect(meta.FindStatusCondition(app.Status.Conditions, webappv1.ConditionReady)).NotTo(BeNil())
+			endpoint, ok := reg.Lookup("integration/shop")
+			g.Expect(ok).To(BeTrue())
+			g.Expect(endpoint).To(Equal("http://shop.integration.svc:8080"))
+		}).Should(Succeed())
+	})
+
+	It("reverts drift as soon as the watch sees it", func() {
+		Eventually(func(g Gomega) {
+			dep := &appsv1.Deployment{}
+			g.Expect(k8sClient.Get(ctx, key, dep)).To(Succeed())
+			dep.Spec.Template.Spec.Containers[0].Image = "nginx:edited-by-hand"
+			g.Expect(k8sClient.Update(ctx, dep)).To(Succeed())
+		}).Should(Succeed())
+
+		Eventually(func(g Gomega) {
+			dep := &appsv1.Deployment{}
+			g.Expect(k8sClient.Get(ctx, key, dep)).To(Succeed())
+			g.Expect(dep.Spec.Template.Spec.Containers[0].Image).To(Equal("nginx:alpine"))
+		}).Should(Succeed())
+	})
+
+	It("turns Ready once the Deployment has rolled out", func() {
+		By("faking the Deployment controller, which envtest doesn't run")
+		Eventually(func(g Gomega) {
+			dep := &appsv1.Deployment{}
+			g.Expect(k8sClient.Get(ctx, key, dep)).To(Succeed())
+			dep.Status = appsv1.DeploymentStatus{
+				ObservedGeneration: dep.Generation,
+				Replicas:           2, ReadyReplicas: 2, AvailableReplicas: 2, UpdatedReplicas: 2,
+				Conditions: []appsv1.DeploymentCondition{
+					{Type: appsv1.DeploymentAvailable, Status: corev1.ConditionTrue, Reason: "MinimumReplicasAvailable"},
+					{Type: appsv1.DeploymentProgressing, Status: corev1.ConditionTrue, Reason: "NewReplicaSetAvailable"},
+				},
+			}
+			g.Expect(k8sClient.Status().Update(ctx, dep)).To(Succeed())
+		}).Should(Succeed())
+
+		Eventually(func(g Gomega) {
+			app := &webappv1.AppService{}
+			g.Expect(k8sClient.Get(ctx, key, app)).To(Succeed())
+			g.Expect(meta.IsStatusConditionTrue(app.Status.Conditions, webappv1.ConditionReady)).To(BeTrue())
+			g.Expect(app.Status.ReadyReplicas).To(Equal(int32(2)))
+		}).Should(Succeed())
+	})
+
+	It("corrects what no watch sees on the next resync", func() {
+		By("dropping the registration behind the controller's back")
+		Expect(reg.Deregister(ctx, "integration/shop")).To(Succeed())
+		Consistently(func() bool {
+			_, ok := reg.Lookup("integration/shop")
+			return ok
+		}, 500*time.Millisecond).Should(BeFalse())
+
+		By("stepping past the resync period, jitter included")
+		fakeClock.Step(70 * time.Minute)
+		Eventually(func() bool {
+			_, ok := reg.Lookup("integration/shop")
+			return ok
+		}).Should(BeTrue())
+	})
+
+	It("rolls the Pods when a ConfigMap the app reads changes, through the index", func() {
+		shared := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "shared-settings", Namespace: namespace},
+			Data:       map[string]string{"mode": "blue"},
+		}
+		Expect(k8sClient.Create(ctx, shared)).To(Succeed())
+		Eventually(func(g Gomega) {
+			app := &webappv1.AppService{}
+			g.Expect(k8sClient.Get(ctx, key, app)).To(Succeed())
+			app.Spec.Env = []corev1.EnvVar{{Name: "MODE", ValueFrom: &corev1.EnvVarSource{
+				ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: shared.Name}, Key: "mode",
+				},
+			}}}
+			g.Expect(k8sClient.Update(ctx, app)).To(Succeed())
+		}).Should(Succeed())
+
+		var checksum string
+		Eventually(func(g Gomega) {
+			dep := &appsv1.Deployment{}
+			g.Expect(k8sClient.Get(ctx, key, dep)).To(Succeed())
+			checksum = dep.Spec.Template.Annotations[referencesChecksumAnnotation]
+			g.Expect(checksum).NotTo(BeEmpty())
+		}).Should(Succeed())
+
+		By("editing the ConfigMap: only the ConfigMap watch can tell")
+		shared.Data["mode"] = "green"
+		Expect(k8sClient.Update(ctx, shared)).To(Succeed())
+		Eventually(func(g Gomega) {
+			dep := &appsv1.Deployment{}
+			g.Expect(k8sClient.Get(ctx, key, dep)).To(Succeed())
+			g.Expect(dep.Spec.Template.Annotations[referencesChecksumAnnotation]).NotTo(Equal(checksum))
+		}).Should(Succeed())
+	})
+
+	It("rolls the Pods when a Secret the app reads changes, though we don't manage it", func() {
+		credentials := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "db-credentials", Namespace: namespace},
+			StringData: map[string]string{"password": "hunter2"},
+		}
+		Expect(k8sClient.Create(ctx, credentials)).To(Succeed())
+		Eventually(func(g Gomega) {
+			app := &webappv1.AppService{}
+			g.Expect(k8sClient.Get(ctx, key, app)).To(Succeed())
+			app.Spec.Env = []corev1.EnvVar{{Name: "DB_PASSWORD", ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: credentials.Name}, Key: "password",
+				},
+			}}}
+			g.Expect(k8sClient.Update(ctx, app)).To(Succeed())
+		}).Should(Succeed())
+
+		var checksum string
+		Eventually(func(g Gomega) {
+			dep := &appsv1.Deployment{}
+			g.Expect(k8sClient.Get(ctx, key, dep)).To(Succeed())
+			checksum = dep.Spec.Template.Annotations[referencesChecksumAnnotation]
+			g.Expect(checksum).NotTo(BeEmpty())
+		}).Should(Succeed())
+
+		By("editing the Secret, which has no managed-by label")
+		credentials.StringData = map[string]string{"password": "correct-horse"}
+		Expect(k8sClient.Update(ctx, credentials)).To(Succeed())
+		Eventually(func(g Gomega) {
+			dep := &appsv1.Deployment{}
+			g.Expect(k8sClient.Get(ctx, key, dep)).To(Succeed())
+			g.Expect(dep.Spec.Template.Annotations[referencesChecksumAnnotation]).NotTo(Equal(checksum))
+		}).Should(Succeed())
+	})
+
+	It("deregisters the app and releases the finalizer on deletion", func() {
+		Expect(k8sClient.Delete(ctx, &webappv1.AppService{
+			ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+		})).To(Succeed())
+
+		Eventually(func() bool {
+			return errors.IsNotFound(k8sClient.Get(ctx, key, &webappv1.AppService{}))
+		}).Should(BeTrue())
+		Expect(reg.Keys()).To(BeEmpty())
+	})
+})
@@ -0,0 +1,81 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	autoscalingv2ac "k8s.io/client-go/applyconfigurations/autoscaling/v2"
+
+	webappv1 "mydomain.com/appservice/api/v1"
+)
+
+// reconcileAutoscaler applies the HorizontalPodAutoscaler of
+// spec.autoscaling, or deletes it once the field is gone. The replicas
+// change hands in the workload's apply (see autoscaled): the HPA takes them
+// over once it has scaled the workload, and hands them back when deleted,
+// as the next apply sets spec.replicas again.
+func (r *AppServiceReconciler) reconcileAutoscaler(ctx context.Context, appService *webappv1.AppService) error {
+	if appService.Spec.Autoscaling == nil {
+		return r.deleteOwned(ctx, appService, "HorizontalPodAutoscaler", appService.Name,
+			&autoscalingv2.HorizontalPodAutoscaler{})
+	}
+	return r.apply(ctx, appService, "HorizontalPodAutoscaler", appService.Name,
+		&autoscalingv2.HorizontalPodAutoscaler{}, desiredAutoscaler(appService))
+}
+
+// desiredAutoscaler scales the Deployment or StatefulSet on the Pods'
+// average CPU use, relative to their request.
+func desiredAutoscaler(appService *webappv1.AppService) *autoscalingv2ac.HorizontalPodAutoscalerApplyConfiguration {
+	spec := appService.Spec.Autoscaling
+	kind := "Deployment"
+	if statefulSet(appService) {
+		kind = "StatefulSet"
+	}
+	hpa := autoscalingv2ac.HorizontalPodAutoscalerSpec().
+		WithScaleTargetRef(autoscalingv2ac.CrossVersionObjectReference().
+			WithAPIVersion("apps/v1").
+			WithKind(kind).
+			WithName(appService.Name)).
+		WithMaxReplicas(spec.MaxReplicas).
+		WithMetrics(autoscalingv2ac.MetricSpec().
+			WithType(autoscalingv2.ResourceMetricSourceType).
+			WithResource(autoscalingv2ac.ResourceMetricSource().
+				WithName(corev1.ResourceCPU).
+				WithTarget(autoscalingv2ac.MetricTarget().
+					WithType(autoscalingv2.UtilizationMetricType).
+					WithAverageUtilization(targetCPUUtilization(appService)))))
+	if spec.MinReplicas != nil {
+		hpa.WithMinReplicas(*spec.MinReplicas)
+	}
+	return autoscalingv2ac.HorizontalPodAutoscaler(appService.Name, appService.Namespace).
+		WithLabels(childLabels(appService)).
+		WithOwnerReferences(ownerReference(appService)).
+		WithSpec(hpa)
+}
+
+// targetCPUUtilization returns spec.autoscaling.targetCPUUtilization,
+// falling back to the CRD default for objects stored before the field
+// existed.
+func targetCPUUtilization(appService *webappv1.AppService) int32 {
+	if appService.Spec.Autoscaling.TargetCPUUtilization == 0 {
+		return 80
+	}
+	return appService.Spec.Autoscaling.TargetCPUUtilization
+}
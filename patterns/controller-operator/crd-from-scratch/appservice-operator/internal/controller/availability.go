@@ -0,0 +1,95 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	policyv1 "k8s.io/api/policy/v1"
+	corev1ac "k8s.io/client-go/applyconfigurations/core/v1"
+	metav1ac "k8s.io/client-go/applyconfigurations/meta/v1"
+	policyv1ac "k8s.io/client-go/applyconfigurations/policy/v1"
+
+	webappv1 "mydomain.com/appservice/api/v1"
+)
+
+// reconcilePDB protects spec.minAvailable Pods from voluntary disruptions.
+// Without minAvailable, a PodDisruptionBudget we created earlier is deleted.
+func (r *AppServiceReconciler) reconcilePDB(ctx context.Context, appService *webappv1.AppService) error {
+	if appService.Spec.MinAvailable == nil {
+		return r.deleteOwned(ctx, appService, "PodDisruptionBudget", appService.Name, &policyv1.PodDisruptionBudget{})
+	}
+	return r.apply(ctx, appService, "PodDisruptionBudget", appService.Name, &policyv1.PodDisruptionBudget{},
+		desiredPDB(appService))
+}
+
+// desiredPDB selects the same Pods as the Deployment.
+func desiredPDB(appService *webappv1.AppService) *policyv1ac.PodDisruptionBudgetApplyConfiguration {
+	return policyv1ac.PodDisruptionBudget(appService.Name, appService.Namespace).
+		WithLabels(childLabels(appService)).
+		WithOwnerReferences(ownerReference(appService)).
+		WithSpec(policyv1ac.PodDisruptionBudgetSpec().
+			WithMinAvailable(*appService.Spec.MinAvailable).
+			WithSelector(metav1ac.LabelSelector().WithMatchLabels(podLabels(appService))))
+}
+
+// topologySpreadConstraints turns spec.topologySpreadConstraints into apply
+// configurations. A constraint without a labelSelector would count no Pods
+// at all, so it gets the AppService's pod labels.
+func topologySpreadConstraints(appService *webappv1.AppService) ([]*corev1ac.TopologySpreadConstraintApplyConfiguration, error) {
+	constraints := make([]*corev1ac.TopologySpreadConstraintApplyConfiguration, 0, len(appService.Spec.TopologySpreadConstraints))
+	for i := range appService.Spec.TopologySpreadConstraints {
+		constraint := &corev1ac.TopologySpreadConstraintApplyConfiguration{}
+		if err := toApplyConfiguration(&appService.Spec.TopologySpreadConstraints[i], constraint); err != nil {
+			return nil, fmt.Errorf("spec.topologySpreadConstraints[%d]: %w", i, err)
+		}
+		if constraint.LabelSelector == nil {
+			constraint.WithLabelSelector(metav1ac.LabelSelector().WithMatchLabels(podLabels(appService)))
+		}
+		constraints = append(constraints, constraint)
+	}
+	return constraints, nil
+}
+
+// withScheduling passes spec.priorityClassName, nodeSelector, tolerations
+// and affinity to the pod spec. The apply owns them, so an edit to the
+// template is reverted like any other drift, and clearing one in the spec
+// removes it from the Pods.
+func withScheduling(appService *webappv1.AppService, podSpec *corev1ac.PodSpecApplyConfiguration) error {
+	if name := appService.Spec.PriorityClassName; name != "" {
+		podSpec.WithPriorityClassName(name)
+	}
+	if len(appService.Spec.NodeSelector) > 0 {
+		podSpec.WithNodeSelector(appService.Spec.NodeSelector)
+	}
+	for i := range appService.Spec.Tolerations {
+		toleration := &corev1ac.TolerationApplyConfiguration{}
+		if err := toApplyConfiguration(&appService.Spec.Tolerations[i], toleration); err != nil {
+			return fmt.Errorf("spec.tolerations[%d]: %w", i, err)
+		}
+		podSpec.WithTolerations(toleration)
+	}
+	if appService.Spec.Affinity != nil {
+		affinity := &corev1ac.AffinityApplyConfiguration{}
+		if err := toApplyConfiguration(appService.Spec.Affinity, affinity); err != nil {
+			return fmt.Errorf("spec.affinity: %w", err)
+		}
+		podSpec.WithAffinity(affinity)
+	}
+	return nil
+}
@@ -0,0 +1,292 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	appsv1ac "k8s.io/client-go/applyconfigurations/apps/v1"
+	metav1ac "k8s.io/client-go/applyconfigurations/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	webappv1 "mydomain.com/appservice/api/v1"
+)
+
+// colorLabel marks the Pods of each color. The Service adds it to its
+// selector to send traffic to the active color only.
+const colorLabel = "webapp.mydomain.com/color"
+
+// blueGreen reports whether the AppService runs as blue and green
+// Deployments.
+func blueGreen(appService *webappv1.AppService) bool {
+	return !statefulSet(appService) && !autoscaled(appService) &&
+		appService.Spec.Strategy != nil && appService.Spec.Strategy.BlueGreen != nil
+}
+
+// colorName is the name of a color's Deployment.
+func colorName(appService *webappv1.AppService, color webappv1.Color) string {
+	return appService.Name + "-" + string(color)
+}
+
+// otherColor is the color that isn't the given one.
+func otherColor(color webappv1.Color) webappv1.Color {
+	if color == webappv1.ColorBlue {
+		return webappv1.ColorGreen
+	}
+	return webappv1.ColorBlue
+}
+
+// activeColor is the color the Service selects, or "" when it selects every
+// Pod of the AppService: without blue/green, or until the first color is up.
+func activeColor(appService *webappv1.AppService) webappv1.Color {
+	if !blueGreen(appService) || appService.Status.BlueGreen == nil {
+		return ""
+	}
+	return appService.Status.BlueGreen.ActiveColor
+}
+
+// serviceSelector selects every Pod of the AppService, or only the active
+// color's.
+func serviceSelector(appService *webappv1.AppService) map[string]string {
+	selector := podLabels(appService)
+	if color := activeColor(appService); color != "" {
+		selector[colorLabel] = string(color)
+	}
+	return selector
+}
+
+// rollingOut reports whether a canary or blue/green rollout is under way. The
+// child updates it makes are then expected, not drift.
+func rollingOut(appService *webappv1.AppService) bool {
+	return appService.Status.Canary != nil ||
+		appService.Status.BlueGreen != nil && appService.Status.BlueGreen.PreviewRevision != ""
+}
+
+// reconcileBlueGreen rolls the desired revision out through
+// spec.strategy.blueGreen, and returns the Deployment status reports on:
+//   - When the active color runs the desired revision, it is applied as is
+//     and the other color is scaled to zero. The plain Deployment and the
+//     canary of another strategy go once the active color is rolled out.
+//   - Otherwise the other color runs the desired revision on all replicas,
+//     as the preview, and the active color keeps its own. Once the preview's
+//     Pods are all available and it's promoted, by spec.promote or after
+//     autoPromotionAfter, the Service is switched to it.
+//
+// The first revision has nothing to be compared with: it goes to blue, and
+// the Service switches to it as soon as it's available.
+func (r *AppServiceReconciler) reconcileBlueGreen(ctx context.Context,
+	appService *webappv1.AppService, revision string) (*appsv1.Deployment, error) {
+	status := appService.Status.BlueGreen
+	if status == nil {
+		status = &webappv1.BlueGreenStatus{}
+		appService.Status.BlueGreen = status
+	}
+	deps := map[webappv1.Color]*appsv1.Deployment{}
+	for _, color := range []webappv1.Color{webappv1.ColorBlue, webappv1.ColorGreen} {
+		deps[color] = &appsv1.Deployment{}
+		key := types.NamespacedName{Name: colorName(appService, color), Namespace: appService.Namespace}
+		if err := r.children().Get(ctx, key, deps[color]); client.IgnoreNotFound(err) != nil {
+			return nil, err
+		}
+	}
+
+	active := status.ActiveColor
+	if active != "" && deps[active].Name == "" {
+		// The active Deployment was deleted: start over as for a first revision
+		active, status.ActiveColor, status.ActiveRevision = "", "", ""
+	}
+	if active != "" && deps[active].Annotations[templateHashAnnotation] == revision {
+		return deps[active], r.settleBlueGreen(ctx, appService, revision, deps[active], deps[otherColor(active)])
+	}
+
+	// A Deployment from before the strategy serves until a color has
+	// settled in
+	if err := r.retireOwned(ctx, appService, "Deployment", appService.Name, &appsv1.Deployment{}, false); err != nil {
+		return nil, err
+	}
+	preview := webappv1.ColorBlue
+	if active != "" {
+		preview = otherColor(active)
+	}
+	if status.PreviewRevision != revision {
+		status.PreviewRevision, status.PreviewReadyTime = revision, nil
+	}
+	desired, err := desiredColorDeployment(appService, preview, revision)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.apply(ctx, appService, "Deployment", colorName(appService, preview), deps[preview], desired); err != nil {
+		return nil, err
+	}
+	if active == "" {
+		if !colorAvailable(appService, deps[preview]) {
+			return deps[preview], nil
+		}
+		r.switchColor(appService, preview)
+		return deps[preview], nil
+	}
+
+	// The active color keeps serving its revision meanwhile
+	if err := r.applyReplicas(ctx, appService, deps[active], replicas(appService)); err != nil {
+		return nil, err
+	}
+	if !colorAvailable(appService, deps[preview]) {
+		return deps[active], nil
+	}
+	if status.PreviewReadyTime == nil {
+		now := metav1.NewTime(r.now())
+		status.PreviewReadyTime = &now
+		r.Recorder.Eventf(appService, corev1.EventTypeNormal, "PreviewReady",
+			"Revision %s is available on %s; promote it with spec.promote: %s",
+			revision, colorName(appService, preview), revision)
+	}
+	if !promoted(appService, r.now()) {
+		return deps[active], nil
+	}
+	r.switchColor(appService, preview)
+	return deps[preview], nil
+}
+
+// settleBlueGreen applies the active color, which runs the desired revision,
+// and takes down what no longer serves. status.blueGreen.previewRevision is
+// cleared last: while it's set, apply knows the changes are the rollout's,
+// not drift.
+func (r *AppServiceReconciler) settleBlueGreen(ctx context.Context, appService *webappv1.AppService,
+	revision string, active, idle *appsv1.Deployment) error {
+	status := appService.Status.BlueGreen
+	desired, err := desiredColorDeployment(appService, status.ActiveColor, revision)
+	if err != nil {
+		return err
+	}
+	if err := r.apply(ctx, appService, "Deployment", active.Name, active, desired); err != nil {
+		return err
+	}
+	if idle.Name != "" {
+		if err := r.applyReplicas(ctx, appService, idle, 0); err != nil {
+			return err
+		}
+	}
+	status.PreviewRevision, status.PreviewReadyTime = "", nil
+
+	if err := r.deleteOwned(ctx, appService, "Deployment", canaryName(appService), &appsv1.Deployment{}); err != nil {
+		return err
+	}
+	return r.retireOwned(ctx, appService, "Deployment", appService.Name, &appsv1.Deployment{}, deploymentRolledOut(active))
+}
+
+// switchColor points the Service at color, which runs the preview revision.
+// The Service is applied later in the same pass.
+func (r *AppServiceReconciler) switchColor(appService *webappv1.AppService, color webappv1.Color) {
+	status := appService.Status.BlueGreen
+	now := metav1.NewTime(r.now())
+	from := status.ActiveColor
+	status.ActiveColor, status.ActiveRevision, status.SwitchTime = color, status.PreviewRevision, &now
+	if from == "" {
+		r.Recorder.Eventf(appService, corev1.EventTypeNormal, "Switched",
+			"Service %s sends traffic to %s (revision %s)", appService.Name, color, status.ActiveRevision)
+		return
+	}
+	r.Recorder.Eventf(appService, corev1.EventTypeNormal, "Switched",
+		"Service %s switched from %s to %s (revision %s)", appService.Name, from, color, status.ActiveRevision)
+}
+
+// deleteColors removes the Deployments of a blue/green strategy that has been
+// removed, once the Deployment that replaces them is rolled out. Until then
+// the Service, which selects every Pod of the AppService, still uses them.
+func (r *AppServiceReconciler) deleteColors(ctx context.Context, appService *webappv1.AppService,
+	replacement *appsv1.Deployment) error {
+	appService.Status.BlueGreen = nil
+	for _, color := range []webappv1.Color{webappv1.ColorBlue, webappv1.ColorGreen} {
+		if err := r.retireOwned(ctx, appService, "Deployment", colorName(appService, color), &appsv1.Deployment{},
+			deploymentRolledOut(replacement)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// desiredColorDeployment runs the desired pod template, as the given
+// revision, on all replicas, with Pods labeled with their color.
+func desiredColorDeployment(appService *webappv1.AppService, color webappv1.Color,
+	revision string) (*appsv1ac.DeploymentApplyConfiguration, error) {
+	colorLabels := map[string]string{colorLabel: string(color)}
+	template, err := desiredPodTemplate(appService)
+	if err != nil {
+		return nil, err
+	}
+	return appsv1ac.Deployment(colorName(appService, color), appService.Namespace).
+		WithLabels(childLabels(appService)).
+		WithAnnotations(map[string]string{templateHashAnnotation: revision}).
+		WithOwnerReferences(ownerReference(appService)).
+		WithSpec(appsv1ac.DeploymentSpec().
+			WithReplicas(replicas(appService)).
+			WithSelector(metav1ac.LabelSelector().WithMatchLabels(podLabels(appService)).WithMatchLabels(colorLabels)).
+			WithTemplate(template.WithLabels(colorLabels))), nil
+}
+
+// colorAvailable reports whether a color is fully up: rolled out, with all
+// replicas available.
+func colorAvailable(appService *webappv1.AppService, dep *appsv1.Deployment) bool {
+	want := replicas(appService)
+	return dep.Spec.Replicas != nil && *dep.Spec.Replicas == want &&
+		dep.Status.AvailableReplicas >= want && deploymentRolledOut(dep)
+}
+
+// promoted reports whether the available preview may take the traffic.
+func promoted(appService *webappv1.AppService, now time.Time) bool {
+	if appService.Spec.Promote == appService.Status.BlueGreen.PreviewRevision {
+		return true
+	}
+	return appService.Spec.Strategy.BlueGreen.AutoPromotionAfter != nil && blueGreenPromotionLeft(appService, now) == 0
+}
+
+// blueGreenPromotionLeft is how long an available preview still waits for
+// autoPromotionAfter, if it's set.
+func blueGreenPromotionLeft(appService *webappv1.AppService, now time.Time) time.Duration {
+	status := appService.Status.BlueGreen
+	if !blueGreen(appService) || status == nil || status.PreviewReadyTime == nil {
+		return 0
+	}
+	after := appService.Spec.Strategy.BlueGreen.AutoPromotionAfter
+	if after == nil {
+		return 0
+	}
+	return max(status.PreviewReadyTime.Add(after.Duration).Sub(now), 0)
+}
+
+// blueGreenProgress describes a preview in the Progressing condition, or is
+// "" when there is none.
+func blueGreenProgress(appService *webappv1.AppService) string {
+	status := appService.Status.BlueGreen
+	if status == nil || status.PreviewRevision == "" || status.PreviewRevision == status.ActiveRevision {
+		return ""
+	}
+	preview := colorName(appService, otherColor(status.ActiveColor))
+	if status.ActiveColor == "" {
+		preview = colorName(appService, webappv1.ColorBlue)
+	}
+	if status.PreviewReadyTime == nil {
+		return fmt.Sprintf("Bringing up revision %s on %s", status.PreviewRevision, preview)
+	}
+	return fmt.Sprintf("Revision %s is available on %s, waiting to be promoted", status.PreviewRevision, preview)
+}
@@ -0,0 +1,209 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	appsv1ac "k8s.io/client-go/applyconfigurations/apps/v1"
+	corev1ac "k8s.io/client-go/applyconfigurations/core/v1"
+	metav1ac "k8s.io/client-go/applyconfigurations/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	webappv1 "mydomain.com/appservice/api/v1"
+)
+
+// templateHashAnnotation records on each Deployment the revision of the pod
+// template we applied, so the running and desired revisions can be told
+// apart without diffing templates.
+const templateHashAnnotation = "webapp.mydomain.com/template-hash"
+
+// canaryTrackLabel marks the canary's Pods. The stable Deployment's selector
+// is older and matches them too, which is harmless: a Deployment only
+// manages the ReplicaSets it owns.
+const canaryTrackLabel = "webapp.mydomain.com/track"
+
+// canary reports whether the AppService rolls out through a canary.
+func canary(appService *webappv1.AppService) bool {
+	return !statefulSet(appService) && !autoscaled(appService) &&
+		appService.Spec.Strategy != nil && appService.Spec.Strategy.Canary != nil
+}
+
+// canaryName is the name of the canary Deployment.
+func canaryName(appService *webappv1.AppService) string {
+	return appService.Name + "-canary"
+}
+
+// templateHash identifies a pod template: what Deployments call a revision.
+func templateHash(template *corev1ac.PodTemplateSpecApplyConfiguration) (string, error) {
+	data, err := json.Marshal(template)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:5]), nil
+}
+
+// canaryReplicas is weight percent of total, rounded up.
+func canaryReplicas(total, weight int32) int32 {
+	return (total*weight + 99) / 100
+}
+
+// reconcileCanary rolls the desired Deployment out through
+// spec.strategy.canary:
+//   - When the stable Deployment runs the desired revision, or doesn't exist
+//     yet, it is applied as is. A canary left from a rollout is removed once
+//     the stable Pods have taken over.
+//   - Otherwise the canary Deployment runs the desired revision with the
+//     current step's share of the replicas, and the stable Deployment keeps
+//     its revision with the rest. A step ends when its pause is over and the
+//     canary Pods are available.
+//   - After the last step, the stable Deployment is updated.
+//
+// A new revision in the middle of a rollout starts over at the first step.
+func (r *AppServiceReconciler) reconcileCanary(ctx context.Context, appService *webappv1.AppService,
+	stable *appsv1.Deployment, desired *appsv1ac.DeploymentApplyConfiguration) error {
+	revision := desired.Annotations[templateHashAnnotation]
+	steps := appService.Spec.Strategy.Canary.Steps
+	canaryDep := &appsv1.Deployment{}
+	key := types.NamespacedName{Name: canaryName(appService), Namespace: appService.Namespace}
+	if err := r.children().Get(ctx, key, canaryDep); client.IgnoreNotFound(err) != nil {
+		return err
+	}
+
+	if stable.Name != "" && stable.Annotations[templateHashAnnotation] != revision {
+		now := metav1.NewTime(r.now())
+		status := appService.Status.Canary
+		switch {
+		case status == nil || status.Revision != revision:
+			status = &webappv1.CanaryStatus{Revision: revision, StepStartTime: now}
+			r.Recorder.Eventf(appService, corev1.EventTypeNormal, "CanaryStarted",
+				"Rolling out revision %s in %d steps", revision, len(steps))
+		case canaryStepDone(appService, status, canaryDep, now.Time):
+			status.Step++
+			status.StepStartTime = now
+			if int(status.Step) < len(steps) {
+				r.Recorder.Eventf(appService, corev1.EventTypeNormal, "CanaryStep",
+					"Canary at %d%% (step %d of %d)", steps[status.Step].Weight, status.Step+1, len(steps))
+			}
+		}
+		if int(status.Step) < len(steps) {
+			return r.applyCanaryStep(ctx, appService, stable, canaryDep, status)
+		}
+		r.Recorder.Eventf(appService, corev1.EventTypeNormal, "CanaryPromoted",
+			"Promoted revision %s to all replicas", revision)
+	}
+
+	// Status.Canary is cleared after the apply: while it's set, apply knows
+	// the change is the rollout's, not drift.
+	if err := r.apply(ctx, appService, "Deployment", appService.Name, stable, desired); err != nil {
+		return err
+	}
+	appService.Status.Canary = nil
+	if err := r.deleteColors(ctx, appService, stable); err != nil {
+		return err
+	}
+	return r.retireOwned(ctx, appService, "Deployment", canaryName(appService), canaryDep, deploymentRolledOut(stable))
+}
+
+// applyCanaryStep runs the current step: the canary Deployment at the step's
+// share of the replicas, the stable one at the rest.
+func (r *AppServiceReconciler) applyCanaryStep(ctx context.Context, appService *webappv1.AppService,
+	stable, canaryDep *appsv1.Deployment, status *webappv1.CanaryStatus) error {
+	status.Weight = appService.Spec.Strategy.Canary.Steps[status.Step].Weight
+	appService.Status.Canary = status
+	total := replicas(appService)
+	count := canaryReplicas(total, status.Weight)
+
+	desiredCanary, err := desiredCanaryDeployment(appService, status.Revision, count)
+	if err != nil {
+		return err
+	}
+	if err := r.apply(ctx, appService, "Deployment", canaryName(appService), canaryDep, desiredCanary); err != nil {
+		return err
+	}
+	return r.applyReplicas(ctx, appService, stable, total-count)
+}
+
+// applyReplicas scales a Deployment that keeps its template: it applies back
+// what we own of it, with the given replicas.
+func (r *AppServiceReconciler) applyReplicas(ctx context.Context, appService *webappv1.AppService,
+	dep *appsv1.Deployment, replicas int32) error {
+	desired, err := appsv1ac.ExtractDeployment(dep, string(fieldOwner))
+	if err != nil {
+		return err
+	}
+	if desired.Spec == nil {
+		desired.WithSpec(appsv1ac.DeploymentSpec())
+	}
+	desired.Spec.WithReplicas(replicas)
+	return r.apply(ctx, appService, "Deployment", dep.Name, dep, desired)
+}
+
+// desiredCanaryDeployment runs the desired pod template, as the given
+// revision, on replicas Pods labeled as the canary.
+func desiredCanaryDeployment(appService *webappv1.AppService, revision string,
+	replicas int32) (*appsv1ac.DeploymentApplyConfiguration, error) {
+	track := map[string]string{canaryTrackLabel: "canary"}
+	template, err := desiredPodTemplate(appService)
+	if err != nil {
+		return nil, err
+	}
+	return appsv1ac.Deployment(canaryName(appService), appService.Namespace).
+		WithLabels(childLabels(appService)).
+		WithAnnotations(map[string]string{templateHashAnnotation: revision}).
+		WithOwnerReferences(ownerReference(appService)).
+		WithSpec(appsv1ac.DeploymentSpec().
+			WithReplicas(replicas).
+			WithSelector(metav1ac.LabelSelector().WithMatchLabels(podLabels(appService)).WithMatchLabels(track)).
+			WithTemplate(template.WithLabels(track))), nil
+}
+
+// canaryStepDone reports whether the current step is over: its pause has
+// passed, and the canary Deployment runs the step's replicas, available.
+func canaryStepDone(appService *webappv1.AppService, status *webappv1.CanaryStatus,
+	canaryDep *appsv1.Deployment, now time.Time) bool {
+	if canaryPauseLeft(appService, now) > 0 {
+		return false
+	}
+	step := appService.Spec.Strategy.Canary.Steps[status.Step]
+	want := canaryReplicas(replicas(appService), step.Weight)
+	return canaryDep.Spec.Replicas != nil && *canaryDep.Spec.Replicas == want &&
+		canaryDep.Status.AvailableReplicas >= want && deploymentRolledOut(canaryDep)
+}
+
+// canaryPauseLeft is how long the current canary step still holds, if a
+// canary is running.
+func canaryPauseLeft(appService *webappv1.AppService, now time.Time) time.Duration {
+	status := appService.Status.Canary
+	if status == nil || !canary(appService) || int(status.Step) >= len(appService.Spec.Strategy.Canary.Steps) {
+		return 0
+	}
+	pause := appService.Spec.Strategy.Canary.Steps[status.Step].Pause
+	if pause == nil {
+		return 0
+	}
+	return max(status.StepStartTime.Add(pause.Duration).Sub(now), 0)
+}
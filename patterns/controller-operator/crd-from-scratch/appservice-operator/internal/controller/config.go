@@ -0,0 +1,98 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	corev1ac "k8s.io/client-go/applyconfigurations/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	webappv1 "mydomain.com/appservice/api/v1"
+)
+
+// spec.config is mounted from the ConfigMap as the "config" volume.
+const (
+	configVolume    = "config"
+	configMountPath = "/etc/app"
+)
+
+// configChecksumAnnotation on the pod template carries a hash of spec.config.
+// Pods don't restart when a mounted ConfigMap changes, but a changed pod
+// template rolls the Deployment, so every config change reaches the app.
+const configChecksumAnnotation = "webapp.mydomain.com/config-checksum"
+
+// reconcileConfig renders spec.config into the AppService's ConfigMap.
+// Without config, a ConfigMap we created earlier is deleted.
+func (r *AppServiceReconciler) reconcileConfig(ctx context.Context, appService *webappv1.AppService) error {
+	if len(appService.Spec.Config) == 0 {
+		return r.deleteOwned(ctx, appService, "ConfigMap", configMapName(appService), &corev1.ConfigMap{})
+	}
+	return r.apply(ctx, appService, "ConfigMap", configMapName(appService), &corev1.ConfigMap{},
+		desiredConfigMap(appService))
+}
+
+// desiredConfigMap holds spec.config as is, one key per file.
+func desiredConfigMap(appService *webappv1.AppService) *corev1ac.ConfigMapApplyConfiguration {
+	return corev1ac.ConfigMap(configMapName(appService), appService.Namespace).
+		WithLabels(childLabels(appService)).
+		WithOwnerReferences(ownerReference(appService)).
+		WithData(appService.Spec.Config)
+}
+
+// configMapName is suffixed so it can't clash with a ConfigMap the user
+// created under the AppService's own name.
+func configMapName(appService *webappv1.AppService) string {
+	return appService.Name + "-config"
+}
+
+// configChecksum hashes spec.config. json.Marshal sorts map keys, so the
+// same config always gives the same checksum.
+func configChecksum(appService *webappv1.AppService) (string, error) {
+	data, err := json.Marshal(appService.Spec.Config)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// configMapDataChanged passes ConfigMap updates that change the data.
+// ConfigMaps have no generation, so GenerationChangedPredicate would drop
+// every edit and leave drift in place until the next resync.
+func configMapDataChanged() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldCM, ok := e.ObjectOld.(*corev1.ConfigMap)
+			if !ok {
+				return false
+			}
+			newCM, ok := e.ObjectNew.(*corev1.ConfigMap)
+			if !ok {
+				return false
+			}
+			return !equality.Semantic.DeepEqual(oldCM.Data, newCM.Data) ||
+				!equality.Semantic.DeepEqual(oldCM.BinaryData, newCM.BinaryData)
+		},
+	}
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	webappv1 "mydomain.com/appservice/api/v1"
+)
+
+// pinsDigest reports whether spec.image needs resolving: it asks for a
+// pinned digest and isn't pinned by one already.
+func pinsDigest(appService *webappv1.AppService) bool {
+	return appService.Spec.PinImageDigest && !strings.Contains(appService.Spec.Image, "@")
+}
+
+// desiredImage is spec.image, pinned to its digest if asked to.
+func desiredImage(appService *webappv1.AppService) string {
+	if pinsDigest(appService) && appService.Status.PinnedImage != "" {
+		return appService.Status.PinnedImage
+	}
+	return appService.Spec.Image
+}
+
+// reconcileDigest resolves spec.image to a digest, with
+// spec.pinImageDigest, and records it as status.pinnedImage. An image is
+// resolved once: the registry is only asked again when spec.image changes.
+// If it can't be reached, nothing is applied, and the Pods keep the image
+// they run.
+func (r *AppServiceReconciler) reconcileDigest(ctx context.Context, appService *webappv1.AppService) error {
+	status := &appService.Status
+	if !pinsDigest(appService) {
+		status.PinnedImage = ""
+		return nil
+	}
+	if strings.HasPrefix(status.PinnedImage, appService.Spec.Image+"@") {
+		return nil
+	}
+	if r.Digests == nil {
+		return fmt.Errorf("spec.pinImageDigest: no digest resolver configured")
+	}
+	resolved, err := r.Digests.Resolve(ctx, appService.Spec.Image)
+	if err != nil {
+		r.Recorder.Eventf(appService, corev1.EventTypeWarning, "FailedResolveDigest", "%v", err)
+		return err
+	}
+	status.PinnedImage = appService.Spec.Image + "@" + resolved
+	r.Recorder.Eventf(appService, corev1.EventTypeNormal, "PinnedImage", "Pinned %s to %s", appService.Spec.Image, resolved)
+	return nil
+}
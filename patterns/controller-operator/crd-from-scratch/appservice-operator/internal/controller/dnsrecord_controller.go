@@ -0,0 +1,191 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	webappv1 "mydomain.com/appservice/api/v1"
+	"mydomain.com/appservice/internal/dns"
+)
+
+// dnsFinalizer holds an AppService in Terminating until its DNS record is
+// gone. It is this controller's own: the AppService controller's finalizer
+// guards the registry, and each controller only removes its own.
+const dnsFinalizer = "webapp.mydomain.com/dns-cleanup"
+
+// defaultDNSPollInterval is how often a pending record is checked.
+const defaultDNSPollInterval = 5 * time.Second
+
+// DNSRecordReconciler gives each AppService a record at an external DNS
+// provider, <name>.<namespace>.<zone>, pointing at its Service. It is a
+// second controller for the same kind: it shares nothing with the
+// AppService controller but the object, and only writes status.dns.
+type DNSRecordReconciler struct {
+	client.Client
+	// Provider is the DNS API.
+	Provider dns.Provider
+	// Zone is the domain the records are created in.
+	Zone string
+	// Recorder emits Events on the AppService.
+	Recorder record.EventRecorder
+	// PollInterval is how often a record that isn't in sync yet is
+	// checked; it defaults to five seconds.
+	PollInterval time.Duration
+}
+
+// +kubebuilder:rbac:groups=webapp.mydomain.com,resources=appservices,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=webapp.mydomain.com,resources=appservices/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=webapp.mydomain.com,resources=appservices/finalizers,verbs=update
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+
+// Reconcile makes sure the record exists and points at the Service, and
+// reports it in status.dns, until the AppService is deleted; then it deletes
+// the record. The provider has no idempotent create, so the record is found
+// by the ID in status, or else by name, before a new one is created.
+func (r *DNSRecordReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var appService webappv1.AppService
+	if err := r.Get(ctx, req.NamespacedName, &appService); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	name := r.fqdn(&appService)
+
+	if !appService.DeletionTimestamp.IsZero() {
+		if !controllerutil.ContainsFinalizer(&appService, dnsFinalizer) {
+			return ctrl.Result{}, nil
+		}
+		if err := r.deleteRecord(ctx, &appService, name); err != nil {
+			return ctrl.Result{}, err
+		}
+		controllerutil.RemoveFinalizer(&appService, dnsFinalizer)
+		return ctrl.Result{}, r.Update(ctx, &appService)
+	}
+	if controllerutil.AddFinalizer(&appService, dnsFinalizer) {
+		if err := r.Update(ctx, &appService); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	target := fmt.Sprintf("%s.%s.svc.cluster.local", appService.Name, appService.Namespace)
+	rec, err := r.findRecord(ctx, &appService, name)
+	switch {
+	case errors.Is(err, dns.ErrNotFound):
+		if rec, err = r.Provider.Create(ctx, name, target); err != nil {
+			r.Recorder.Eventf(&appService, corev1.EventTypeWarning, "FailedCreateDNSRecord", "%s: %v", name, err)
+			return ctrl.Result{}, err
+		}
+		log.FromContext(ctx).Info("Created DNS record", "name", name, "id", rec.ID)
+		r.Recorder.Eventf(&appService, corev1.EventTypeNormal, "CreatedDNSRecord", "Created DNS record %s (%s)", name, rec.ID)
+	case err != nil:
+		return ctrl.Result{}, err
+	case rec.Target != target:
+		if rec, err = r.Provider.Update(ctx, rec.ID, target); err != nil {
+			return ctrl.Result{}, err
+		}
+		r.Recorder.Eventf(&appService, corev1.EventTypeNormal, "UpdatedDNSRecord", "Pointed DNS record %s at %s", name, target)
+	}
+
+	// A merge patch of status.dns alone: the AppService controller writes
+	// the rest of the status, and neither overwrites the other
+	before := appService.DeepCopy()
+	appService.Status.DNS = &webappv1.DNSStatus{RecordID: rec.ID, FQDN: name, Ready: rec.Status == dns.StatusInSync}
+	if !equality.Semantic.DeepEqual(before.Status.DNS, appService.Status.DNS) {
+		if err := r.Status().Patch(ctx, &appService, client.MergeFrom(before)); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	if !appService.Status.DNS.Ready {
+		return ctrl.Result{RequeueAfter: r.pollInterval()}, nil
+	}
+	return ctrl.Result{}, nil
+}
+
+// findRecord returns the AppService's record: the one status.dns names, or
+// else the one called name, which a create whose ID never made it to status
+// left behind. A record in status under another name, from another zone, is
+// deleted and reported as not found.
+func (r *DNSRecordReconciler) findRecord(ctx context.Context, appService *webappv1.AppService,
+	name string) (dns.Record, error) {
+	if status := appService.Status.DNS; status != nil && status.RecordID != "" {
+		rec, err := r.Provider.Get(ctx, status.RecordID)
+		switch {
+		case err == nil && rec.Name == name:
+			return rec, nil
+		case err == nil:
+			if err := r.Provider.Delete(ctx, rec.ID); err != nil && !errors.Is(err, dns.ErrNotFound) {
+				return dns.Record{}, err
+			}
+		case !errors.Is(err, dns.ErrNotFound):
+			return dns.Record{}, err
+		}
+	}
+	return r.Provider.FindByName(ctx, name)
+}
+
+// deleteRecord deletes the AppService's record, if there is one.
+func (r *DNSRecordReconciler) deleteRecord(ctx context.Context, appService *webappv1.AppService, name string) error {
+	rec, err := r.findRecord(ctx, appService, name)
+	if errors.Is(err, dns.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := r.Provider.Delete(ctx, rec.ID); err != nil && !errors.Is(err, dns.ErrNotFound) {
+		r.Recorder.Eventf(appService, corev1.EventTypeWarning, "FailedDeleteDNSRecord", "%s: %v", name, err)
+		return err
+	}
+	r.Recorder.Eventf(appService, corev1.EventTypeNormal, "DeletedDNSRecord", "Deleted DNS record %s", name)
+	return nil
+}
+
+// fqdn is the name of the AppService's record.
+func (r *DNSRecordReconciler) fqdn(appService *webappv1.AppService) string {
+	return fmt.Sprintf("%s.%s.%s", appService.Name, appService.Namespace, r.Zone)
+}
+
+func (r *DNSRecordReconciler) pollInterval() time.Duration {
+	if r.PollInterval > 0 {
+		return r.PollInterval
+	}
+	return defaultDNSPollInterval
+}
+
+// SetupWithManager sets up the controller with the Manager. It only watches
+// AppServices: the record lives outside the cluster, where no watch reaches,
+// so a pending one is polled, and one changed behind our back is only put
+// back on a spec change or a restart.
+func (r *DNSRecordReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		// Deletion bumps the generation of an object with finalizers
+		For(&webappv1.AppService{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Named("dnsrecord").
+		Complete(r)
+}
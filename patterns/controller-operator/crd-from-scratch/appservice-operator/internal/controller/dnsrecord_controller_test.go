@@ -0,0 +1,120 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	clocktesting "k8s.io/utils/clock/testing"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	webappv1 "mydomain.com/appservice/api/v1"
+	"mydomain.com/appservice/internal/dns"
+)
+
+var _ = Describe("DNSRecord Controller", func() {
+	ctx := context.Background()
+	key := types.NamespacedName{Name: "dns-test", Namespace: "default"}
+	const fqdn = "dns-test.default.apps.example.internal"
+
+	var (
+		provider   *dns.Fake
+		fakeClock  *clocktesting.FakeClock
+		recorder   *record.FakeRecorder
+		reconciler *DNSRecordReconciler
+	)
+
+	BeforeEach(func() {
+		var err error
+		provider, err = dns.NewFake("")
+		Expect(err).NotTo(HaveOccurred())
+		fakeClock = clocktesting.NewFakeClock(time.Now())
+		provider.Clock = fakeClock
+		recorder = record.NewFakeRecorder(20)
+		reconciler = &DNSRecordReconciler{
+			Client:   k8sClient,
+			Provider: provider,
+			Zone:     "apps.example.internal",
+			Recorder: recorder,
+		}
+		Expect(k8sClient.Create(ctx, &webappv1.AppService{
+			ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+			Spec:       webappv1.AppServiceSpec{Image: "nginx:alpine", Port: 8080},
+		})).To(Succeed())
+	})
+
+	AfterEach(func() {
+		app := &webappv1.AppService{}
+		Expect(k8sClient.Get(ctx, key, app)).To(Succeed())
+		Expect(k8sClient.Delete(ctx, app)).To(Succeed())
+
+		By("deleting the record before letting the AppService go")
+		_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(errors.IsNotFound(k8sClient.Get(ctx, key, app))).To(BeTrue())
+		Expect(provider.Names()).To(BeEmpty())
+		Expect(drainEvents(recorder)).To(ContainElement("Normal DeletedDNSRecord Deleted DNS record " + fqdn))
+	})
+
+	It("creates the record and polls it until it is in sync", func() {
+		result, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(Equal(defaultDNSPollInterval))
+		app := &webappv1.AppService{}
+		Expect(k8sClient.Get(ctx, key, app)).To(Succeed())
+		Expect(app.Finalizers).To(ContainElement(dnsFinalizer))
+		Expect(app.Status.DNS).NotTo(BeNil())
+		Expect(app.Status.DNS.FQDN).To(Equal(fqdn))
+		Expect(app.Status.DNS.Ready).To(BeFalse())
+		rec, err := provider.Get(ctx, app.Status.DNS.RecordID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rec.Target).To(Equal("dns-test.default.svc.cluster.local"))
+		Expect(drainEvents(recorder)).To(ConsistOf(HavePrefix("Normal CreatedDNSRecord Created DNS record " + fqdn)))
+
+		By("waiting for the change to propagate")
+		fakeClock.Step(provider.PropagationDelay)
+		result, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal(reconcile.Result{}))
+		Expect(k8sClient.Get(ctx, key, app)).To(Succeed())
+		Expect(app.Status.DNS.Ready).To(BeTrue())
+	})
+
+	It("finds its record by name when the ID never made it to status", func() {
+		_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		app := &webappv1.AppService{}
+		Expect(k8sClient.Get(ctx, key, app)).To(Succeed())
+		id := app.Status.DNS.RecordID
+
+		By("losing the status, as if its update had failed")
+		app.Status.DNS = nil
+		Expect(k8sClient.Status().Update(ctx, app)).To(Succeed())
+		_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(k8sClient.Get(ctx, key, app)).To(Succeed())
+		Expect(app.Status.DNS.RecordID).To(Equal(id))
+		Expect(provider.Names()).To(ConsistOf(fqdn))
+	})
+})
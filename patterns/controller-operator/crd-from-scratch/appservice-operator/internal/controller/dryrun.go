@@ -0,0 +1,122 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/diff"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	webappv1 "mydomain.com/appservice/api/v1"
+)
+
+// reportDryRun reports what the dry-run apply of the child called name would
+// have changed: current is the child as it is (empty unless existed), and
+// desired holds the API server's answer, the child as it would be. Comparing
+// the two, both defaulted and admitted by the server, shows only real
+// changes. The full diff goes to the log, the fields that change to an Event.
+func (r *AppServiceReconciler) reportDryRun(ctx context.Context, appService *webappv1.AppService, kind, name string,
+	current client.Object, existed bool, desired runtime.ApplyConfiguration) error {
+	before := map[string]any{}
+	if existed {
+		var err error
+		if before, err = jsonMap(current); err != nil {
+			return err
+		}
+	}
+	after, err := jsonMap(desired)
+	if err != nil {
+		return err
+	}
+	withoutServerFields(before)
+	withoutServerFields(after)
+
+	l := log.FromContext(ctx)
+	if !existed {
+		l.Info("Dry run: would create "+kind, "name", name, "diff", diff.Diff(nil, after))
+		r.Recorder.Eventf(appService, corev1.EventTypeNormal, "DryRun", "Would create %s %s", kind, name)
+		return nil
+	}
+	changed := changedFields(before, after, "")
+	if len(changed) == 0 {
+		return nil
+	}
+	l.Info("Dry run: would update "+kind, "name", name, "diff", diff.Diff(before, after))
+	r.Recorder.Eventf(appService, corev1.EventTypeNormal, "DryRun", "Would update %s %s: %s",
+		kind, name, strings.Join(changed, ", "))
+	return nil
+}
+
+// jsonMap is obj as generic JSON, so the typed child and the apply
+// configuration compare field by field, numbers included.
+func jsonMap(obj any) (map[string]any, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	m := map[string]any{}
+	return m, json.Unmarshal(data, &m)
+}
+
+// withoutServerFields drops from obj what the API server changes on every
+// write, or keeps up to date on its own, so it's never reported as a change.
+func withoutServerFields(obj map[string]any) {
+	delete(obj, "apiVersion")
+	delete(obj, "kind")
+	delete(obj, "status")
+	if metadata, ok := obj["metadata"].(map[string]any); ok {
+		for _, field := range []string{"managedFields", "resourceVersion", "generation", "uid", "creationTimestamp"} {
+			delete(metadata, field)
+		}
+	}
+}
+
+// changedFields lists the paths, like "spec.replicas", at which before and
+// after differ. Lists are compared whole: their items have no path.
+func changedFields(before, after map[string]any, prefix string) []string {
+	var changed []string
+	keys := slices.Collect(maps.Keys(before))
+	for key := range after {
+		if _, ok := before[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		a, b := before[key], after[key]
+		if reflect.DeepEqual(a, b) {
+			continue
+		}
+		aMap, aOK := a.(map[string]any)
+		bMap, bOK := b.(map[string]any)
+		if aOK && bOK {
+			changed = append(changed, changedFields(aMap, bMap, prefix+key+".")...)
+			continue
+		}
+		changed = append(changed, prefix+key)
+	}
+	return changed
+}
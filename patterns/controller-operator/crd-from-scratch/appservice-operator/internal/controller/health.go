@@ -0,0 +1,164 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	webappv1 "mydomain.com/appservice/api/v1"
+)
+
+// reportDeployed fills status.deployedResources from status.inventory,
+// reading each child from the cache: its UID, and its health as of this
+// pass. A child just created may not be in the cache yet; it's reported
+// Progressing, and its watch event brings the AppService back to fill it in.
+func (r *AppServiceReconciler) reportDeployed(ctx context.Context, appService *webappv1.AppService) error {
+	var deployed []webappv1.DeployedResource
+	for _, ref := range appService.Status.Inventory {
+		res := webappv1.DeployedResource{
+			APIVersion: ref.APIVersion, Kind: ref.Kind, Name: ref.Name,
+			Health: webappv1.HealthProgressing, Message: "Not observed yet",
+		}
+		obj, err := r.newChild(ref)
+		if err != nil {
+			return err
+		}
+		err = r.children().Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: appService.Namespace}, obj)
+		switch {
+		case apierrors.IsNotFound(err) || meta.IsNoMatchError(err):
+		case err != nil:
+			return err
+		default:
+			res.UID = obj.GetUID()
+			res.Health, res.Message = health(obj)
+		}
+		deployed = append(deployed, res)
+	}
+	appService.Status.DeployedResources = deployed
+	return nil
+}
+
+// health sums up the status of a child, along the lines of kstatus and
+// Argo CD: workloads and Jobs by their rollout, HorizontalPodAutoscalers by
+// their conditions, and anything without a status to speak of is Healthy
+// once it exists.
+func health(obj client.Object) (webappv1.Health, string) {
+	if !obj.GetDeletionTimestamp().IsZero() {
+		return webappv1.HealthProgressing, "Being deleted"
+	}
+	switch obj := obj.(type) {
+	case *appsv1.Deployment:
+		return deploymentHealth(obj)
+	case *appsv1.StatefulSet:
+		if !statefulSetRolledOut(obj, ptr.Deref(obj.Spec.Replicas, 1)) {
+			return webappv1.HealthProgressing, fmt.Sprintf("%d/%d replicas updated",
+				obj.Status.UpdatedReplicas, ptr.Deref(obj.Spec.Replicas, 1))
+		}
+	case *batchv1.Job:
+		for _, cond := range obj.Status.Conditions {
+			if cond.Type == batchv1.JobFailed && cond.Status == corev1.ConditionTrue {
+				return webappv1.HealthDegraded, cond.Message
+			}
+		}
+		if !jobFinished(obj, batchv1.JobComplete) {
+			return webappv1.HealthProgressing, "Running"
+		}
+	case *autoscalingv2.HorizontalPodAutoscaler:
+		// A new HPA has no conditions yet, which is fine; one that can't
+		// read its metrics (e.g. a container without a CPU request) or
+		// can't scale its target says so
+		for _, cond := range obj.Status.Conditions {
+			if (cond.Type == autoscalingv2.AbleToScale || cond.Type == autoscalingv2.ScalingActive) &&
+				cond.Status == corev1.ConditionFalse {
+				return webappv1.HealthDegraded, cond.Message
+			}
+		}
+	case *policyv1.PodDisruptionBudget:
+		if obj.Status.ObservedGeneration < obj.Generation {
+			return webappv1.HealthProgressing, "Not observed by the disruption controller yet"
+		}
+	case *corev1.Service:
+		if obj.Spec.Type == corev1.ServiceTypeLoadBalancer && len(obj.Status.LoadBalancer.Ingress) == 0 {
+			return webappv1.HealthProgressing, "Waiting for a load balancer"
+		}
+	case *unstructured.Unstructured:
+		return unstructuredHealth(obj)
+	}
+	return webappv1.HealthHealthy, ""
+}
+
+// deploymentHealth reads the Deployment's conditions, like
+// setDeploymentConditions does for the AppService's own.
+func deploymentHealth(dep *appsv1.Deployment) (webappv1.Health, string) {
+	if progressDeadlineExceeded(dep) {
+		return webappv1.HealthDegraded, deploymentCondition(dep, appsv1.DeploymentProgressing).Message
+	}
+	if failure := deploymentCondition(dep, appsv1.DeploymentReplicaFailure); failure != nil &&
+		failure.Status == corev1.ConditionTrue {
+		return webappv1.HealthDegraded, failure.Message
+	}
+	if !deploymentRolledOut(dep) {
+		return webappv1.HealthProgressing, fmt.Sprintf("%d/%d replicas available",
+			dep.Status.AvailableReplicas, ptr.Deref(dep.Spec.Replicas, 1))
+	}
+	return webappv1.HealthHealthy, ""
+}
+
+// unstructuredHealth covers the children of optional CRDs (HTTPRoute,
+// ServiceMonitor) the generic way: a spec change the controller behind them
+// hasn't observed is Progressing, and an Accepted or Ready condition that is
+// False, in the status or in a parent's status (HTTPRoute), is Degraded.
+func unstructuredHealth(obj *unstructured.Unstructured) (webappv1.Health, string) {
+	observed, found, _ := unstructured.NestedInt64(obj.Object, "status", "observedGeneration")
+	if found && observed < obj.GetGeneration() {
+		return webappv1.HealthProgressing, "Not observed by its controller yet"
+	}
+	statuses := []any{obj.Object["status"]}
+	parents, _, _ := unstructured.NestedSlice(obj.Object, "status", "parents")
+	statuses = append(statuses, parents...)
+	for _, status := range statuses {
+		status, ok := status.(map[string]any)
+		if !ok {
+			continue
+		}
+		conditions, _, _ := unstructured.NestedSlice(status, "conditions")
+		for _, cond := range conditions {
+			cond, ok := cond.(map[string]any)
+			if !ok {
+				continue
+			}
+			if (cond["type"] == "Accepted" || cond["type"] == "Ready") && cond["status"] == "False" {
+				message, _ := cond["message"].(string)
+				return webappv1.HealthDegraded, message
+			}
+		}
+	}
+	return webappv1.HealthHealthy, ""
+}
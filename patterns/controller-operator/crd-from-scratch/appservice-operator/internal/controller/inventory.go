@@ -0,0 +1,192 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"cmp"
+	"context"
+	"slices"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	webappv1 "mydomain.com/appservice/api/v1"
+)
+
+// ownerIndex indexes children by the name of the AppService controlling
+// them, so a pass can list its own children from the cache.
+const ownerIndex = ".metadata.ownerReferences.appService"
+
+// ownedTypes are the typed children the controller watches, and indexes by
+// owner. HTTPRoute, unstructured, is left to the inventory.
+func ownedTypes() []client.Object {
+	return []client.Object{
+		&appsv1.Deployment{}, &appsv1.StatefulSet{}, &corev1.Service{}, &corev1.ConfigMap{},
+		&corev1.Secret{}, &policyv1.PodDisruptionBudget{}, &networkingv1.Ingress{}, &networkingv1.NetworkPolicy{},
+		&autoscalingv2.HorizontalPodAutoscaler{}, &batchv1.CronJob{}, &batchv1.Job{},
+	}
+}
+
+// controllerName is the ownerIndex value of obj: the AppService controlling
+// it, if one does, or the one its owner annotation names, in a spoke.
+func controllerName(obj client.Object) []string {
+	owner := metav1.GetControllerOf(obj)
+	if owner == nil || owner.APIVersion != webappv1.GroupVersion.String() || owner.Kind != "AppService" {
+		if key, ok := annotatedOwner(obj); ok {
+			return []string{key.Name}
+		}
+		return nil
+	}
+	return []string{owner.Name}
+}
+
+// track lists the child obj, called name, in status.inventory: one this pass
+// applied or keeps. The name is passed on its own, as obj may be empty when
+// the cache hasn't seen a child just created yet.
+func (r *AppServiceReconciler) track(appService *webappv1.AppService, obj client.Object, name string) error {
+	gvk, err := apiutil.GVKForObject(obj, r.Scheme)
+	if err != nil {
+		return err
+	}
+	ref := webappv1.ChildReference{APIVersion: gvk.GroupVersion().String(), Kind: gvk.Kind, Name: name}
+	if !slices.Contains(appService.Status.Inventory, ref) {
+		appService.Status.Inventory = append(appService.Status.Inventory, ref)
+	}
+	return nil
+}
+
+// retireOwned deletes a child on its way out once done, and until then keeps
+// it in the inventory, as something still depends on it.
+func (r *AppServiceReconciler) retireOwned(ctx context.Context, appService *webappv1.AppService, kind, name string,
+	obj client.Object, done bool) error {
+	if done {
+		return r.deleteOwned(ctx, appService, kind, name, obj)
+	}
+	key := types.NamespacedName{Name: name, Namespace: appService.Namespace}
+	if err := r.children().Get(ctx, key, obj); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if !r.owns(obj, appService) {
+		return nil
+	}
+	return r.track(appService, obj, name)
+}
+
+// prune deletes the children of the previous inventory that this pass
+// neither applied nor kept. That covers what the code above deletes by name
+// as well as what it can't know about anymore: a child whose name derives
+// from a spec field that changed, or one of a kind that is no longer used,
+// like an Ingress after the operator moved to --gateway.
+func (r *AppServiceReconciler) prune(ctx context.Context, appService *webappv1.AppService,
+	previous []webappv1.ChildReference) error {
+	for _, ref := range previous {
+		if slices.Contains(appService.Status.Inventory, ref) {
+			continue
+		}
+		obj, err := r.newChild(ref)
+		if err != nil {
+			return err
+		}
+		err = r.deleteOwned(ctx, appService, ref.Kind, ref.Name, obj)
+		if meta.IsNoMatchError(err) {
+			// The CRD is gone, and the child with it
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !r.DryRun {
+			log.FromContext(ctx).Info("Pruned "+ref.Kind, "name", ref.Name)
+		}
+	}
+	if r.ownerIndexed {
+		if err := r.pruneUnlisted(ctx, appService); err != nil {
+			return err
+		}
+	}
+	slices.SortFunc(appService.Status.Inventory, func(a, b webappv1.ChildReference) int {
+		return cmp.Or(cmp.Compare(a.Kind, b.Kind), cmp.Compare(a.Name, b.Name), cmp.Compare(a.APIVersion, b.APIVersion))
+	})
+	return nil
+}
+
+// pruneUnlisted deletes the children no inventory ever listed, like the ones
+// created by an operator version without one. They are found through the
+// owner index; only those with our managed-by label are taken, so an object
+// someone else pointed at the AppService isn't.
+func (r *AppServiceReconciler) pruneUnlisted(ctx context.Context, appService *webappv1.AppService) error {
+	for _, child := range ownedTypes() {
+		gvk, err := apiutil.GVKForObject(child, r.Scheme)
+		if err != nil {
+			return err
+		}
+		obj, err := r.Scheme.New(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+		if err != nil {
+			return err
+		}
+		list := obj.(client.ObjectList)
+		if err := r.children().List(ctx, list, client.InNamespace(appService.Namespace),
+			client.MatchingFields{ownerIndex: appService.Name},
+			client.MatchingLabels{managedByLabel: managedByValue}); err != nil {
+			return err
+		}
+		err = meta.EachListItem(list, func(item runtime.Object) error {
+			obj := item.(client.Object)
+			ref := webappv1.ChildReference{APIVersion: gvk.GroupVersion().String(), Kind: gvk.Kind, Name: obj.GetName()}
+			if slices.Contains(appService.Status.Inventory, ref) {
+				return nil
+			}
+			return r.deleteOwned(ctx, appService, gvk.Kind, obj.GetName(), obj)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newChild returns an empty object for ref: typed when the scheme knows the
+// kind, so it's read from the same informer as the rest of the controller,
+// and unstructured otherwise (HTTPRoute).
+func (r *AppServiceReconciler) newChild(ref webappv1.ChildReference) (client.Object, error) {
+	gvk := schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind)
+	if r.Scheme.Recognizes(gvk) {
+		obj, err := r.Scheme.New(gvk)
+		if err != nil {
+			return nil, err
+		}
+		if obj, ok := obj.(client.Object); ok {
+			return obj, nil
+		}
+	}
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(gvk)
+	return obj, nil
+}
@@ -0,0 +1,244 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	batchv1ac "k8s.io/client-go/applyconfigurations/batch/v1"
+	corev1ac "k8s.io/client-go/applyconfigurations/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	webappv1 "mydomain.com/appservice/api/v1"
+)
+
+// maintenanceJobName is the name of the spec.maintenanceJob CronJob.
+func maintenanceJobName(appService *webappv1.AppService) string {
+	return appService.Name + "-maintenance"
+}
+
+// reconcileMaintenanceJob applies the spec.maintenanceJob CronJob, or
+// deletes it once the field is gone. The Jobs it started go with it.
+func (r *AppServiceReconciler) reconcileMaintenanceJob(ctx context.Context, appService *webappv1.AppService) error {
+	if appService.Spec.MaintenanceJob == nil {
+		return r.deleteOwned(ctx, appService, "CronJob", maintenanceJobName(appService), &batchv1.CronJob{})
+	}
+	cronJob, err := desiredCronJob(appService)
+	if err != nil {
+		return err
+	}
+	return r.apply(ctx, appService, "CronJob", maintenanceJobName(appService), &batchv1.CronJob{}, cronJob)
+}
+
+// desiredCronJob runs spec.maintenanceJob on its schedule, by default in the
+// image the Deployment runs. A run still going when the next one is due is
+// left to finish, and the next one skipped.
+func desiredCronJob(appService *webappv1.AppService) (*batchv1ac.CronJobApplyConfiguration, error) {
+	spec := appService.Spec.MaintenanceJob
+	image := spec.Image
+	switch {
+	case image != "":
+	case appService.Status.Migration != nil && appService.Status.LastGoodImage != "":
+		// The new image isn't migrated yet; the task runs the app's
+		image = appService.Status.LastGoodImage
+	default:
+		image = podImage(appService)
+	}
+	template, err := jobPodTemplate(appService, "maintenance", image, spec.Command, spec.Args,
+		corev1.RestartPolicyOnFailure)
+	if err != nil {
+		return nil, err
+	}
+	return batchv1ac.CronJob(maintenanceJobName(appService), appService.Namespace).
+		WithLabels(childLabels(appService)).
+		WithOwnerReferences(ownerReference(appService)).
+		WithSpec(batchv1ac.CronJobSpec().
+			WithSchedule(spec.Schedule).
+			WithConcurrencyPolicy(batchv1.ForbidConcurrent).
+			WithJobTemplate(batchv1ac.JobTemplateSpec().
+				WithSpec(batchv1ac.JobSpec().WithTemplate(template)))), nil
+}
+
+// reconcileMigration gates the Deployment, dep, on spec.migrate, and reports
+// whether it may be applied. A new image, or the first one, is migrated
+// first: a Job runs spec.migrate in it, and until the Job has succeeded, the
+// Deployment is left as it is. status.migration tells what it waits for. A
+// Job that failed isn't retried; a new image or spec.migrate makes a new
+// one, as the Job's name is a hash of its spec.
+//
+// The Job of the image running now is kept, for its logs, until the next
+// one; the older ones are pruned.
+func (r *AppServiceReconciler) reconcileMigration(ctx context.Context, appService *webappv1.AppService,
+	dep *appsv1.Deployment) (bool, error) {
+	status := &appService.Status
+	// Going back to the last good image doesn't migrate it again
+	if appService.Spec.Migrate == nil || rolledBack(appService) {
+		status.Migration = nil
+		return true, nil
+	}
+	desired, err := desiredMigrationJob(appService)
+	if err != nil {
+		return false, err
+	}
+	name := *desired.Name
+	if dep.Name != "" && mainImage(&dep.Spec.Template) == podImage(appService) {
+		status.Migration = nil
+		return true, r.retireOwned(ctx, appService, "Job", name, &batchv1.Job{}, false)
+	}
+
+	// The Job's template can't be changed, so it's only ever created
+	job := &batchv1.Job{}
+	err = r.children().Get(ctx, types.NamespacedName{Name: name, Namespace: appService.Namespace}, job)
+	switch {
+	case apierrors.IsNotFound(err):
+		err = r.apply(ctx, appService, "Job", name, job, desired)
+	case err == nil:
+		err = r.track(appService, job, name)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	migration := &webappv1.MigrationStatus{Image: podImage(appService), Job: name}
+	switch {
+	case jobFinished(job, batchv1.JobComplete):
+		status.Migration = nil
+		r.Recorder.Eventf(appService, corev1.EventTypeNormal, "Migrated",
+			"Migration Job %s succeeded; rolling out %s", name, migration.Image)
+		return true, nil
+	case jobFinished(job, batchv1.JobFailed):
+		migration.Failed = true
+		if status.Migration == nil || !status.Migration.Failed || status.Migration.Job != name {
+			r.Recorder.Eventf(appService, corev1.EventTypeWarning, "MigrationFailed",
+				"Migration Job %s failed; %s is not rolled out", name, migration.Image)
+		}
+	}
+	status.Migration = migration
+	return false, nil
+}
+
+// desiredMigrationJob runs spec.migrate in the image the Pods are to run. It
+// is named after a hash of its spec, so a change makes a new Job instead of
+// an update the API server would refuse.
+func desiredMigrationJob(appService *webappv1.AppService) (*batchv1ac.JobApplyConfiguration, error) {
+	migrate := appService.Spec.Migrate
+	template, err := jobPodTemplate(appService, "migrate", podImage(appService), migrate.Command, migrate.Args,
+		corev1.RestartPolicyNever)
+	if err != nil {
+		return nil, err
+	}
+	spec := batchv1ac.JobSpec().WithTemplate(template)
+	if migrate.BackoffLimit != nil {
+		spec.WithBackoffLimit(*migrate.BackoffLimit)
+	}
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	name := appService.Name + "-migrate-" + hex.EncodeToString(sum[:5])
+	return batchv1ac.Job(name, appService.Namespace).
+		WithLabels(childLabels(appService)).
+		WithOwnerReferences(ownerReference(appService)).
+		WithSpec(spec), nil
+}
+
+// jobPodTemplate is the pod template of a Job run in the app's environment:
+// the app's container with its env, config and resources, but running image
+// with command and args, and without ports or probes. The Pods are
+// scheduled like the app's, and don't carry its labels, so the Service
+// doesn't send them traffic.
+func jobPodTemplate(appService *webappv1.AppService, name, image string, command, args []string,
+	restartPolicy corev1.RestartPolicy) (*corev1ac.PodTemplateSpecApplyConfiguration, error) {
+	container, err := desiredContainer(appService)
+	if err != nil {
+		return nil, err
+	}
+	container.WithName(name).WithImage(image)
+	container.Command, container.Args = command, args
+	container.Ports = nil
+	container.LivenessProbe, container.ReadinessProbe = nil, nil
+	// The data volume is the StatefulSet's, one per Pod
+	container.VolumeMounts = slices.DeleteFunc(container.VolumeMounts, func(mount corev1ac.VolumeMountApplyConfiguration) bool {
+		return *mount.Name != configVolume
+	})
+
+	podSpec := corev1ac.PodSpec().
+		WithRestartPolicy(restartPolicy).
+		WithContainers(container)
+	if len(appService.Spec.Config) > 0 {
+		podSpec.WithVolumes(corev1ac.Volume().
+			WithName(configVolume).
+			WithConfigMap(corev1ac.ConfigMapVolumeSource().WithName(configMapName(appService))))
+	}
+	for _, ref := range appService.Spec.ImagePullSecretRefs {
+		podSpec.WithImagePullSecrets(corev1ac.LocalObjectReference().WithName(ref.Name))
+	}
+	if err := withScheduling(appService, podSpec); err != nil {
+		return nil, err
+	}
+	return corev1ac.PodTemplateSpec().WithSpec(podSpec), nil
+}
+
+// jobFinished reports whether the Job has the condition, Complete or Failed,
+// both of which are final.
+func jobFinished(job *batchv1.Job, condType batchv1.JobConditionType) bool {
+	for _, cond := range job.Status.Conditions {
+		if cond.Type == condType {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// migrationPending reports whether obj, the Deployment, is about to get the
+// image a migration was run for. Updating it is expected then, not drift.
+func migrationPending(appService *webappv1.AppService, obj client.Object) bool {
+	dep, ok := obj.(*appsv1.Deployment)
+	return ok && appService.Spec.Migrate != nil && dep.Name != "" &&
+		mainImage(&dep.Spec.Template) != podImage(appService)
+}
+
+// jobStatusChanged passes Job updates that finish it, so a Deployment
+// waiting for its migration goes ahead right away.
+func jobStatusChanged() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldJob, ok := e.ObjectOld.(*batchv1.Job)
+			if !ok {
+				return false
+			}
+			newJob, ok := e.ObjectNew.(*batchv1.Job)
+			if !ok {
+				return false
+			}
+			return jobFinished(oldJob, batchv1.JobComplete) != jobFinished(newJob, batchv1.JobComplete) ||
+				jobFinished(oldJob, batchv1.JobFailed) != jobFinished(newJob, batchv1.JobFailed)
+		},
+	}
+}
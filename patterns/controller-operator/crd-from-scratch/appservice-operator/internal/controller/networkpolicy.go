@@ -0,0 +1,96 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	metav1ac "k8s.io/client-go/applyconfigurations/meta/v1"
+	networkingv1ac "k8s.io/client-go/applyconfigurations/networking/v1"
+
+	webappv1 "mydomain.com/appservice/api/v1"
+)
+
+// defaultDenyName is the name of the NetworkPolicy that isolates the Pods.
+// The one letting spec.allowFrom in is named after the AppService.
+func defaultDenyName(appService *webappv1.AppService) string {
+	return appService.Name + "-default-deny"
+}
+
+// reconcileNetworkPolicies isolates the Pods and lets spec.allowFrom in.
+// The allow policy is applied first: on its own it already isolates the
+// Pods, so no order leaves the app open or closed to its allowed peers.
+// Without allowFrom, both are deleted.
+func (r *AppServiceReconciler) reconcileNetworkPolicies(ctx context.Context, appService *webappv1.AppService) error {
+	if len(appService.Spec.AllowFrom) == 0 {
+		for _, name := range []string{defaultDenyName(appService), appService.Name} {
+			if err := r.deleteOwned(ctx, appService, "NetworkPolicy", name, &networkingv1.NetworkPolicy{}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	allow, err := desiredAllowPolicy(appService)
+	if err != nil {
+		return err
+	}
+	if err := r.apply(ctx, appService, "NetworkPolicy", appService.Name, &networkingv1.NetworkPolicy{}, allow); err != nil {
+		return err
+	}
+	return r.apply(ctx, appService, "NetworkPolicy", defaultDenyName(appService), &networkingv1.NetworkPolicy{},
+		desiredNetworkPolicy(appService, defaultDenyName(appService)))
+}
+
+// desiredNetworkPolicy selects the AppService's Pods for ingress, and lets
+// nothing in: a default deny, until rules are added.
+func desiredNetworkPolicy(appService *webappv1.AppService, name string) *networkingv1ac.NetworkPolicyApplyConfiguration {
+	return networkingv1ac.NetworkPolicy(name, appService.Namespace).
+		WithLabels(childLabels(appService)).
+		WithOwnerReferences(ownerReference(appService)).
+		WithSpec(networkingv1ac.NetworkPolicySpec().
+			WithPodSelector(metav1ac.LabelSelector().WithMatchLabels(podLabels(appService))).
+			WithPolicyTypes(networkingv1.PolicyTypeIngress))
+}
+
+// desiredAllowPolicy lets the spec.allowFrom peers reach the app's ports:
+// "http", and "metrics" when it's a port of its own.
+func desiredAllowPolicy(appService *webappv1.AppService) (*networkingv1ac.NetworkPolicyApplyConfiguration, error) {
+	rule := networkingv1ac.NetworkPolicyIngressRule()
+	for i := range appService.Spec.AllowFrom {
+		peer := &networkingv1ac.NetworkPolicyPeerApplyConfiguration{}
+		if err := toApplyConfiguration(&appService.Spec.AllowFrom[i], peer); err != nil {
+			return nil, fmt.Errorf("spec.allowFrom[%d]: %w", i, err)
+		}
+		rule.WithFrom(peer)
+	}
+	ports := []string{"http"}
+	if separateMetricsPort(appService) {
+		ports = append(ports, metricsPortName)
+	}
+	for _, port := range ports {
+		rule.WithPorts(networkingv1ac.NetworkPolicyPort().
+			WithProtocol(corev1.ProtocolTCP).
+			WithPort(intstr.FromString(port)))
+	}
+	policy := desiredNetworkPolicy(appService, appService.Name)
+	policy.Spec.WithIngress(rule)
+	return policy, nil
+}
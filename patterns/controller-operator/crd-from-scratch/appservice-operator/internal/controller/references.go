@@ -0,0 +1,168 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	webappv1 "mydomain.com/appservice/api/v1"
+)
+
+// referencesChecksumAnnotation on the pod template carries
+// status.referencesChecksum, as configChecksumAnnotation does for
+// spec.config: env vars are only read at container start.
+const referencesChecksumAnnotation = "webapp.mydomain.com/references-checksum"
+
+// Field indexes on AppServices, by the name of each ConfigMap or Secret
+// spec.env reads. They answer "who reads this ConfigMap?" from the cache,
+// instead of listing and filtering every AppService of the namespace.
+const (
+	configMapRefIndex = ".spec.env.configMapRefs"
+	secretRefIndex    = ".spec.env.secretRefs"
+)
+
+// configMapRefs returns the names of the ConfigMaps spec.env reads.
+func configMapRefs(appService *webappv1.AppService) []string {
+	var names []string
+	for _, env := range appService.Spec.Env {
+		if env.ValueFrom != nil && env.ValueFrom.ConfigMapKeyRef != nil {
+			names = append(names, env.ValueFrom.ConfigMapKeyRef.Name)
+		}
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// secretRefs returns the names of the Secrets spec.env reads.
+func secretRefs(appService *webappv1.AppService) []string {
+	var names []string
+	for _, env := range appService.Spec.Env {
+		if env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil {
+			names = append(names, env.ValueFrom.SecretKeyRef.Name)
+		}
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// appServicesReading maps a ConfigMap or Secret to the AppServices that read
+// it, through the given index.
+func (r *AppServiceReconciler) appServicesReading(index string) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		var list webappv1.AppServiceList
+		if err := r.List(ctx, &list, client.InNamespace(obj.GetNamespace()),
+			client.MatchingFields{index: obj.GetName()}); err != nil {
+			log.FromContext(ctx).Error(err, "Listing the AppServices reading "+obj.GetName())
+			return nil
+		}
+		requests := make([]reconcile.Request, 0, len(list.Items))
+		for _, appService := range list.Items {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&appService)})
+		}
+		return requests
+	}
+}
+
+// secretMetadata is an empty Secret, metadata only: all reconcileReferences
+// needs of one is its resourceVersion.
+func secretMetadata() *metav1.PartialObjectMetadata {
+	secret := &metav1.PartialObjectMetadata{}
+	secret.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Secret"))
+	return secret
+}
+
+// referencedSecrets is where the Secrets spec.env reads are looked up:
+// ReferencedSecrets if set, the children's client otherwise, a plain one in
+// the envtest suite. A manager's cached client would only find our own.
+func (r *AppServiceReconciler) referencedSecrets() client.Reader {
+	if r.ReferencedSecrets != nil {
+		return r.ReferencedSecrets
+	}
+	return r.children()
+}
+
+// reconcileReferences records in status.referencesChecksum what spec.env
+// reads from ConfigMaps and Secrets:
+//   - a ConfigMap key by its value, which is no secret;
+//   - a Secret by its resourceVersion: a hash of a value would let anyone
+//     who can read Deployments brute-force a weak one (see Phase 20), so
+//     any edit of the Secret counts.
+//
+// A missing object or key counts as such, so creating it rolls the Pods
+// that were waiting for it.
+func (r *AppServiceReconciler) reconcileReferences(ctx context.Context, appService *webappv1.AppService) error {
+	appService.Status.ReferencesChecksum = ""
+	hash := sha256.New()
+	found := false
+	for _, env := range appService.Spec.Env {
+		switch {
+		case env.ValueFrom == nil:
+			continue
+		case env.ValueFrom.ConfigMapKeyRef != nil:
+			ref := env.ValueFrom.ConfigMapKeyRef
+			cm := &corev1.ConfigMap{}
+			if err := r.children().Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: appService.Namespace}, cm); client.IgnoreNotFound(err) != nil {
+				return err
+			}
+			value, ok := cm.Data[ref.Key]
+			fmt.Fprintf(hash, "configmap %s %s %t %q\n", ref.Name, ref.Key, ok, value)
+		case env.ValueFrom.SecretKeyRef != nil:
+			ref := env.ValueFrom.SecretKeyRef
+			secret := secretMetadata()
+			if err := r.referencedSecrets().Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: appService.Namespace}, secret); client.IgnoreNotFound(err) != nil {
+				return err
+			}
+			fmt.Fprintf(hash, "secret %s %q\n", ref.Name, secret.ResourceVersion)
+		default:
+			continue
+		}
+		found = true
+	}
+	if found {
+		appService.Status.ReferencesChecksum = hex.EncodeToString(hash.Sum(nil))
+	}
+	return nil
+}
+
+// referencesPending reports whether obj, a workload, runs a pod template
+// from before the last change to what spec.env reads. Updating it is
+// expected then, not drift.
+func referencesPending(appService *webappv1.AppService, obj client.Object) bool {
+	var template corev1.PodTemplateSpec
+	switch workload := obj.(type) {
+	case *appsv1.Deployment:
+		template = workload.Spec.Template
+	case *appsv1.StatefulSet:
+		template = workload.Spec.Template
+	default:
+		return false
+	}
+	return template.Annotations[referencesChecksumAnnotation] != appService.Status.ReferencesChecksum
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/time/rate"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/workqueue"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// rolloutPollInterval is how soon an AppService whose workload is still
+// rolling out is looked at again. The watch on the workload's status usually
+// comes first; this is the safety net for an event that got filtered or lost.
+const rolloutPollInterval = 15 * time.Second
+
+// Bounds of the per-AppService backoff after a conflict.
+const (
+	conflictBaseDelay = time.Second
+	conflictMaxDelay  = 5 * time.Minute
+)
+
+// NewConflictBackoff is the default for AppServiceReconciler.ConflictBackoff:
+// 1s, 2s, 4s, ... up to 5m per AppService, reset by a successful reconcile.
+func NewConflictBackoff() workqueue.TypedRateLimiter[reconcile.Request] {
+	return workqueue.NewTypedItemExponentialFailureRateLimiter[reconcile.Request](conflictBaseDelay, conflictMaxDelay)
+}
+
+// RateLimiterOptions tune the retries after errors, see NewRateLimiter.
+type RateLimiterOptions struct {
+	// BaseDelay and MaxDelay bound each AppService's own backoff: BaseDelay,
+	// twice that, ... up to MaxDelay, reset by a successful reconcile.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// QPS and Burst limit the retries of all AppServices together.
+	QPS   float64
+	Burst int
+}
+
+// DefaultRateLimiterOptions are controller-runtime's own defaults: 5ms up
+// to 1000s per AppService, and 10 retries per second overall with bursts of
+// 100.
+func DefaultRateLimiterOptions() RateLimiterOptions {
+	return RateLimiterOptions{BaseDelay: 5 * time.Millisecond, MaxDelay: 1000 * time.Second, QPS: 10, Burst: 100}
+}
+
+// NewRateLimiter is the default for AppServiceReconciler.RateLimiter, built
+// like controller-runtime's: an AppService that fails is retried after the
+// longer of its own exponential backoff and its turn in the overall token
+// bucket.
+func NewRateLimiter(o RateLimiterOptions) workqueue.TypedRateLimiter[reconcile.Request] {
+	return workqueue.NewTypedMaxOfRateLimiter(
+		workqueue.NewTypedItemExponentialFailureRateLimiter[reconcile.Request](o.BaseDelay, o.MaxDelay),
+		&workqueue.TypedBucketRateLimiter[reconcile.Request]{Limiter: rate.NewLimiter(rate.Limit(o.QPS), o.Burst)},
+	)
+}
+
+// observedRateLimiter records the delays a rate limiter hands out in
+// appservice_retry_delay_seconds.
+type observedRateLimiter struct {
+	workqueue.TypedRateLimiter[reconcile.Request]
+	reason string
+}
+
+func (l observedRateLimiter) When(req reconcile.Request) time.Duration {
+	delay := l.TypedRateLimiter.When(req)
+	retryDelaySeconds.WithLabelValues(l.reason).Observe(delay.Seconds())
+	return delay
+}
+
+// Reconcile wraps reconcile with the requeue policy:
+//   - Conflicts (someone else wrote the object first, or a child of the same
+//     name is still being deleted) are expected in a busy cluster. They are
+//     retried with per-AppService exponential backoff, and logged at V(1)
+//     instead of as errors.
+//   - Terminal errors, which only a change to the spec (or the world) can
+//     fix, are reported in the Stalled condition instead of retried: the
+//     AppService comes back on that change, or at the next resync.
+//   - Other errors go back to controller-runtime, which logs them and retries
+//     them through RateLimiter.
+//   - Successful passes come back after ResyncPeriod at the latest, so drift
+//     the watches can't see (a predicate filtered it, a child kind we don't
+//     watch) is still corrected.
+func (r *AppServiceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	result, err := r.reconcile(ctx, req)
+	if err != nil {
+		if r.ConflictBackoff != nil && (apierrors.IsConflict(err) || apierrors.IsAlreadyExists(err)) {
+			delay := r.ConflictBackoff.When(req)
+			retryDelaySeconds.WithLabelValues(resultConflict).Observe(delay.Seconds())
+			log.FromContext(ctx).V(1).Info("Conflict, retrying", "after", delay, "reason", err.Error())
+			reconcilesTotal.WithLabelValues(resultConflict).Inc()
+			return ctrl.Result{RequeueAfter: delay}, nil
+		}
+		if reason := stalledReason(err); reason != "" {
+			if err := r.stall(ctx, req.NamespacedName, reason, err); err != nil {
+				reconcilesTotal.WithLabelValues(resultError).Inc()
+				return ctrl.Result{}, err
+			}
+			log.FromContext(ctx).Info("Stalled", "reason", reason, "error", err.Error())
+			reconcilesTotal.WithLabelValues(resultStalled).Inc()
+			return r.resync(ctrl.Result{}), nil
+		}
+		reconcilesTotal.WithLabelValues(resultError).Inc()
+		return result, err
+	}
+	reconcilesTotal.WithLabelValues(resultSuccess).Inc()
+	if r.ConflictBackoff != nil {
+		r.ConflictBackoff.Forget(req)
+	}
+
+	return r.resync(result), nil
+}
+
+// resync brings result's requeue forward to ResyncPeriod, if that's sooner.
+func (r *AppServiceReconciler) resync(result ctrl.Result) ctrl.Result {
+	if r.ResyncPeriod > 0 {
+		// Jitter spreads the resyncs of AppServices created together
+		resync := wait.Jitter(r.ResyncPeriod, 0.1)
+		if result.RequeueAfter == 0 || result.RequeueAfter > resync {
+			result.RequeueAfter = resync
+		}
+	}
+	return result
+}
+
+// now is the time on r.Clock, which defaults to the real one.
+func (r *AppServiceReconciler) now() time.Time {
+	if r.Clock != nil {
+		return r.Clock.Now()
+	}
+	return time.Now()
+}
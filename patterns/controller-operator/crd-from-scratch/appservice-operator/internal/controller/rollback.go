@@ -0,0 +1,110 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	webappv1 "mydomain.com/appservice/api/v1"
+)
+
+// plainDeployment reports whether the AppService runs a single Deployment,
+// updated by the Deployment's own rolling update. Only then is there one
+// rollout to watch, and one image to go back to.
+func plainDeployment(appService *webappv1.AppService) bool {
+	return !statefulSet(appService) && !canary(appService) && !blueGreen(appService)
+}
+
+// rolledBack reports whether spec.image (pinned, if asked to) failed to
+// roll out and the Pods run status.lastGoodImage instead.
+func rolledBack(appService *webappv1.AppService) bool {
+	status := appService.Status
+	return appService.Spec.RollbackOnFailure && plainDeployment(appService) &&
+		status.FailedImage != "" && status.FailedImage == desiredImage(appService) && status.LastGoodImage != ""
+}
+
+// podImage is the image the Pods should run.
+func podImage(appService *webappv1.AppService) string {
+	if rolledBack(appService) {
+		return appService.Status.LastGoodImage
+	}
+	return desiredImage(appService)
+}
+
+// reconcileRollback runs before the Deployment is applied:
+//   - A Deployment that finished rolling out has its image recorded as
+//     status.lastGoodImage.
+//   - With spec.rollbackOnFailure, a rollout of spec.image that exceeded
+//     the progress deadline is recorded as status.failedImage, and the
+//     apply that follows puts lastGoodImage back.
+//
+// A new spec.image clears failedImage: it gets a rollout of its own.
+func (r *AppServiceReconciler) reconcileRollback(ctx context.Context, appService *webappv1.AppService) error {
+	status := &appService.Status
+	if status.FailedImage != desiredImage(appService) || !appService.Spec.RollbackOnFailure {
+		status.FailedImage = ""
+	}
+	if !plainDeployment(appService) {
+		return nil
+	}
+	dep := &appsv1.Deployment{}
+	key := types.NamespacedName{Name: appService.Name, Namespace: appService.Namespace}
+	if err := r.children().Get(ctx, key, dep); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	running := mainImage(&dep.Spec.Template)
+	switch {
+	case deploymentRolledOut(dep):
+		status.LastGoodImage = running
+	case appService.Spec.RollbackOnFailure && status.FailedImage == "" && running == desiredImage(appService) &&
+		status.LastGoodImage != "" && status.LastGoodImage != running && progressDeadlineExceeded(dep):
+		status.FailedImage = running
+		r.Recorder.Eventf(appService, corev1.EventTypeWarning, "RolledBack",
+			"Rollout of %s exceeded its progress deadline; rolling back to %s", running, status.LastGoodImage)
+	}
+	return nil
+}
+
+// rollbackPending reports whether obj, the Deployment, still runs the
+// image that is being rolled back. Updating it is expected then, not drift.
+func rollbackPending(appService *webappv1.AppService, obj client.Object) bool {
+	dep, ok := obj.(*appsv1.Deployment)
+	return ok && rolledBack(appService) && mainImage(&dep.Spec.Template) == appService.Status.FailedImage
+}
+
+// progressDeadlineExceeded reports whether the Deployment controller gave up
+// on the current rollout.
+func progressDeadlineExceeded(dep *appsv1.Deployment) bool {
+	progressing := deploymentCondition(dep, appsv1.DeploymentProgressing)
+	return progressing != nil && progressing.Reason == "ProgressDeadlineExceeded" &&
+		dep.Status.ObservedGeneration >= dep.Generation
+}
+
+// mainImage is the image of the app's container in a pod template.
+func mainImage(template *corev1.PodTemplateSpec) string {
+	for _, container := range template.Spec.Containers {
+		if container.Name == "main" {
+			return container.Image
+		}
+	}
+	return ""
+}
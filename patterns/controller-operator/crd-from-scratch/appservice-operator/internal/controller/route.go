@@ -0,0 +1,153 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	networkingv1ac "k8s.io/client-go/applyconfigurations/networking/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	webappv1 "mydomain.com/appservice/api/v1"
+)
+
+// httpRouteGVK is the Gateway API HTTPRoute. It is handled as unstructured
+// so the operator has no Go dependency on the Gateway API, and only needs
+// its CRDs when --gateway is set.
+var httpRouteGVK = schema.GroupVersionKind{Group: "gateway.networking.k8s.io", Version: "v1", Kind: "HTTPRoute"}
+
+// reconcileRoute publishes the Service on spec.host: through an Ingress, or
+// an HTTPRoute attached to r.Gateway. Without a host, a route we created
+// earlier is deleted.
+func (r *AppServiceReconciler) reconcileRoute(ctx context.Context, appService *webappv1.AppService) error {
+	kind, obj := "Ingress", client.Object(&networkingv1.Ingress{})
+	var desired runtime.ApplyConfiguration = desiredIngress(appService)
+	if r.Gateway != nil {
+		route := &unstructured.Unstructured{}
+		route.SetGroupVersionKind(httpRouteGVK)
+		kind, obj = "HTTPRoute", route
+		desired = client.ApplyConfigurationFromUnstructured(desiredHTTPRoute(appService, *r.Gateway))
+	}
+
+	if appService.Spec.Host == "" {
+		return r.deleteOwned(ctx, appService, kind, appService.Name, obj)
+	}
+	return r.apply(ctx, appService, kind, appService.Name, obj, desired)
+}
+
+// deleteOwned deletes the child called name, if it exists and is ours. Its
+// own children go in the background: a Job's Pods would otherwise be
+// orphaned, batch/v1's default.
+func (r *AppServiceReconciler) deleteOwned(ctx context.Context, appService *webappv1.AppService, kind, name string,
+	obj client.Object) error {
+	key := types.NamespacedName{Name: name, Namespace: appService.Namespace}
+	if err := r.children().Get(ctx, key, obj); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if !r.owns(obj, appService) {
+		return nil
+	}
+	if err := r.children().Delete(ctx, obj, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	if r.DryRun {
+		log.FromContext(ctx).Info("Dry run: would delete "+kind, "name", key.Name)
+		r.Recorder.Eventf(appService, corev1.EventTypeNormal, "DryRun", "Would delete %s %s", kind, key.Name)
+		return nil
+	}
+	childOperationsTotal.WithLabelValues(kind, operationDelete).Inc()
+	r.Recorder.Eventf(appService, corev1.EventTypeNormal, "Deleted", "Deleted %s %s", kind, key.Name)
+	return nil
+}
+
+// desiredIngress routes spec.host/spec.path to the Service's "http" port.
+// The IngressClass is left to the cluster default.
+func desiredIngress(appService *webappv1.AppService) *networkingv1ac.IngressApplyConfiguration {
+	return networkingv1ac.Ingress(appService.Name, appService.Namespace).
+		WithLabels(childLabels(appService)).
+		WithOwnerReferences(ownerReference(appService)).
+		WithSpec(networkingv1ac.IngressSpec().
+			WithRules(networkingv1ac.IngressRule().
+				WithHost(appService.Spec.Host).
+				WithHTTP(networkingv1ac.HTTPIngressRuleValue().
+					WithPaths(networkingv1ac.HTTPIngressPath().
+						WithPath(routePath(appService)).
+						WithPathType(networkingv1.PathTypePrefix).
+						WithBackend(networkingv1ac.IngressBackend().
+							WithService(networkingv1ac.IngressServiceBackend().
+								WithName(appService.Name).
+								WithPort(networkingv1ac.ServiceBackendPort().WithName("http"))))))))
+}
+
+// desiredHTTPRoute is the Gateway API equivalent of desiredIngress, attached
+// to the given Gateway.
+func desiredHTTPRoute(appService *webappv1.AppService, gateway types.NamespacedName) *unstructured.Unstructured {
+	route := &unstructured.Unstructured{Object: map[string]any{
+		"metadata": map[string]any{
+			"name":      appService.Name,
+			"namespace": appService.Namespace,
+		},
+		"spec": map[string]any{
+			"parentRefs": []any{
+				map[string]any{"name": gateway.Name, "namespace": gateway.Namespace},
+			},
+			"hostnames": []any{appService.Spec.Host},
+			"rules": []any{
+				map[string]any{
+					"matches": []any{
+						map[string]any{"path": map[string]any{"type": "PathPrefix", "value": routePath(appService)}},
+					},
+					"backendRefs": []any{
+						// HTTPRoute backends take a port number, not a name
+						map[string]any{"name": appService.Name, "port": int64(servicePort(appService))},
+					},
+				},
+			},
+		},
+	}}
+	route.SetGroupVersionKind(httpRouteGVK)
+	route.SetLabels(childLabels(appService))
+	route.SetOwnerReferences([]metav1.OwnerReference{
+		*metav1.NewControllerRef(appService, webappv1.GroupVersion.WithKind("AppService")),
+	})
+	return route
+}
+
+// routePath returns spec.path, falling back to the CRD default.
+func routePath(appService *webappv1.AppService) string {
+	if appService.Spec.Path == "" {
+		return "/"
+	}
+	return appService.Spec.Path
+}
+
+// appURL is what status.url reports for the route.
+func appURL(appService *webappv1.AppService) string {
+	if appService.Spec.Host == "" {
+		return ""
+	}
+	return fmt.Sprintf("http://%s%s", appService.Spec.Host, routePath(appService))
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// +kubebuilder:rbac:groups=core,resources=namespaces,verbs=list
+
+// CacheOptions scopes the manager's cache, and with it everything the
+// operator watches:
+//   - With namespaces, only those are watched and cached, as a tenant-scoped
+//     operator would; without, the whole cluster is.
+//   - Secrets are always limited to the ones we manage. Watching Secrets (for
+//     Owns) would otherwise list and cache them all, readable to anything in
+//     the operator's process.
+func CacheOptions(namespaces []string) cache.Options {
+	opts := cache.Options{
+		ByObject: map[client.Object]cache.ByObject{
+			&corev1.Secret{}: {Label: labels.SelectorFromSet(labels.Set{managedByLabel: managedByValue})},
+		},
+	}
+	if len(namespaces) > 0 {
+		opts.DefaultNamespaces = make(map[string]cache.Config, len(namespaces))
+		for _, namespace := range namespaces {
+			opts.DefaultNamespaces[namespace] = cache.Config{}
+		}
+	}
+	return opts
+}
+
+// ReferencedSecretsCacheOptions scopes the cache of the Secrets spec.env
+// reads (see AppServiceReconciler.ReferencedSecrets): the same namespaces as
+// CacheOptions, but every Secret in them, not just ours. It is only ever
+// asked for metadata, so no one else's credentials are held either way.
+func ReferencedSecretsCacheOptions(namespaces []string) cache.Options {
+	opts := CacheOptions(namespaces)
+	opts.ByObject = nil
+	opts.DefaultTransform = cache.TransformStripManagedFields()
+	return opts
+}
+
+// NamespacesMatching lists the namespaces whose labels match selector, for
+// CacheOptions. The cache's namespaces are fixed when it starts, so a
+// namespace labeled later is only picked up after a restart.
+//
+// No match is an error: an empty list would mean "the whole cluster", the
+// opposite of what a selector asks for.
+func NamespacesMatching(ctx context.Context, c client.Reader, selector labels.Selector) ([]string, error) {
+	var list corev1.NamespaceList
+	if err := c.List(ctx, &list, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, fmt.Errorf("listing namespaces matching %q: %w", selector, err)
+	}
+	if len(list.Items) == 0 {
+		return nil, fmt.Errorf("no namespace matches %q", selector)
+	}
+	namespaces := make([]string, 0, len(list.Items))
+	for _, namespace := range list.Items {
+		namespaces = append(namespaces, namespace.Name)
+	}
+	return namespaces, nil
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	corev1ac "k8s.io/client-go/applyconfigurations/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	webappv1 "mydomain.com/appservice/api/v1"
+)
+
+// secretRotationAnnotation records, on the generated Secret and on the pod
+// template, the webappv1.RotateSecretAnnotation value the token was
+// generated for. A new value on the AppService means "rotate"; on the pod
+// template it rolls the Pods, as env vars are only read at container start.
+const secretRotationAnnotation = "webapp.mydomain.com/secret-rotation"
+
+// tokenBytes is the entropy of a generated token: 256 bits.
+const tokenBytes = 32
+
+// reconcileSecret makes sure the token of spec.generatedSecret exists. An
+// existing token is never overwritten, so a value someone put in by hand
+// stays, except when the AppService asks for a rotation. Without
+// generatedSecret, a Secret we created earlier is deleted.
+//
+// The token never leaves the Secret: it's not logged, not put in Events or
+// status, and the container gets it through a secretKeyRef.
+func (r *AppServiceReconciler) reconcileSecret(ctx context.Context, appService *webappv1.AppService) error {
+	if appService.Spec.GeneratedSecret == nil {
+		return r.deleteOwned(ctx, appService, "Secret", secretName(appService), &corev1.Secret{})
+	}
+
+	secret := &corev1.Secret{}
+	key := types.NamespacedName{Name: secretName(appService), Namespace: appService.Namespace}
+	if err := r.children().Get(ctx, key, secret); client.IgnoreNotFound(err) != nil {
+		return err
+	}
+
+	rotation := appService.Annotations[webappv1.RotateSecretAnnotation]
+	token, ok := secret.Data[secretKey(appService)]
+	rotate := ok && rotation != "" && rotation != secret.Annotations[secretRotationAnnotation]
+	if !ok || rotate {
+		var err error
+		if token, err = generateToken(); err != nil {
+			return fmt.Errorf("generating the token: %w", err)
+		}
+	}
+
+	desired := corev1ac.Secret(key.Name, key.Namespace).
+		WithLabels(childLabels(appService)).
+		WithOwnerReferences(ownerReference(appService)).
+		WithType(corev1.SecretTypeOpaque).
+		WithData(map[string][]byte{secretKey(appService): token})
+	if rotation != "" {
+		desired.WithAnnotations(map[string]string{secretRotationAnnotation: rotation})
+	}
+	if err := r.apply(ctx, appService, "Secret", key.Name, secret, desired); err != nil {
+		return err
+	}
+	if rotate {
+		r.Recorder.Eventf(appService, corev1.EventTypeNormal, "SecretRotated", "Rotated the token in Secret %s", key.Name)
+	}
+	return nil
+}
+
+// rotationPending reports whether the AppService asks for a rotation that
+// status doesn't show as done yet. The Secret and Deployment updates it
+// causes are then expected, not drift.
+func rotationPending(appService *webappv1.AppService) bool {
+	rotation := appService.Annotations[webappv1.RotateSecretAnnotation]
+	return appService.Spec.GeneratedSecret != nil && rotation != "" && rotation != appService.Status.SecretRotation
+}
+
+// generateToken returns tokenBytes of crypto/rand, URL-safe base64 encoded
+// so it can be used in headers and URLs as is.
+func generateToken() ([]byte, error) {
+	raw := make([]byte, tokenBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	return []byte(base64.RawURLEncoding.EncodeToString(raw)), nil
+}
+
+// secretName is suffixed like configMapName, for the same reason.
+func secretName(appService *webappv1.AppService) string {
+	return appService.Name + "-secret"
+}
+
+// secretKey returns spec.generatedSecret.key, falling back to the CRD default.
+func secretKey(appService *webappv1.AppService) string {
+	if appService.Spec.GeneratedSecret.Key == "" {
+		return webappv1.DefaultSecretKey
+	}
+	return appService.Spec.GeneratedSecret.Key
+}
+
+// secretEnvName returns spec.generatedSecret.envName, falling back to the
+// CRD default.
+func secretEnvName(appService *webappv1.AppService) string {
+	if appService.Spec.GeneratedSecret.EnvName == "" {
+		return webappv1.DefaultSecretEnvName
+	}
+	return appService.Spec.GeneratedSecret.EnvName
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	webappv1 "mydomain.com/appservice/api/v1"
+)
+
+// serviceMonitorGVK is the Prometheus Operator's ServiceMonitor. Like
+// HTTPRoute, it is handled as unstructured: its CRD is optional, and so is
+// a Go dependency on the Prometheus Operator.
+var serviceMonitorGVK = schema.GroupVersionKind{Group: "monitoring.coreos.com", Version: "v1", Kind: "ServiceMonitor"}
+
+// metricsPortName names the container and Service port for spec.metrics,
+// when it isn't spec.port.
+const metricsPortName = "metrics"
+
+// ServiceMonitorsInstalled reports whether the cluster serves
+// ServiceMonitors, that is whether the Prometheus Operator's CRDs are
+// installed.
+func ServiceMonitorsInstalled(mapper meta.RESTMapper) (bool, error) {
+	_, err := mapper.RESTMapping(serviceMonitorGVK.GroupKind(), serviceMonitorGVK.Version)
+	if meta.IsNoMatchError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// separateMetricsPort reports whether the metrics are served on a port of
+// their own, rather than on spec.port.
+func separateMetricsPort(appService *webappv1.AppService) bool {
+	metrics := appService.Spec.Metrics
+	return metrics != nil && metrics.Port != 0 && metrics.Port != servicePort(appService)
+}
+
+// reconcileServiceMonitor has Prometheus scrape the app through a
+// ServiceMonitor, as long as spec.metrics asks for it. Without the CRD,
+// there is nothing to do: the metrics port is still exposed, for whatever
+// else scrapes the cluster.
+func (r *AppServiceReconciler) reconcileServiceMonitor(ctx context.Context, appService *webappv1.AppService) error {
+	if !r.ServiceMonitors {
+		return nil
+	}
+	monitor := &unstructured.Unstructured{}
+	monitor.SetGroupVersionKind(serviceMonitorGVK)
+	if appService.Spec.Metrics == nil {
+		return r.deleteOwned(ctx, appService, "ServiceMonitor", appService.Name, monitor)
+	}
+	return r.apply(ctx, appService, "ServiceMonitor", appService.Name, monitor,
+		client.ApplyConfigurationFromUnstructured(desiredServiceMonitor(appService)))
+}
+
+// desiredServiceMonitor selects the AppService's Service, and scrapes the
+// metrics port of the Pods behind it.
+func desiredServiceMonitor(appService *webappv1.AppService) *unstructured.Unstructured {
+	metrics := appService.Spec.Metrics
+	endpoint := map[string]any{"port": "http", "path": metricsPath(appService)}
+	if separateMetricsPort(appService) {
+		endpoint["port"] = metricsPortName
+	}
+	if metrics.Interval != nil {
+		// Prometheus durations have no fractions; the webhook only lets
+		// whole seconds through
+		endpoint["interval"] = fmt.Sprintf("%ds", int64(metrics.Interval.Seconds()))
+	}
+	selector := map[string]any{}
+	for key, value := range serviceLabels(appService) {
+		selector[key] = value
+	}
+
+	monitor := &unstructured.Unstructured{Object: map[string]any{
+		"metadata": map[string]any{
+			"name":      appService.Name,
+			"namespace": appService.Namespace,
+		},
+		"spec": map[string]any{
+			"selector":  map[string]any{"matchLabels": selector},
+			"endpoints": []any{endpoint},
+		},
+	}}
+	monitor.SetGroupVersionKind(serviceMonitorGVK)
+	monitor.SetLabels(childLabels(appService))
+	monitor.SetOwnerReferences([]metav1.OwnerReference{
+		*metav1.NewControllerRef(appService, webappv1.GroupVersion.WithKind("AppService")),
+	})
+	return monitor
+}
+
+// metricsPath returns spec.metrics.path, falling back to the CRD default.
+func metricsPath(appService *webappv1.AppService) string {
+	if appService.Spec.Metrics.Path == "" {
+		return "/metrics"
+	}
+	return appService.Spec.Metrics.Path
+}
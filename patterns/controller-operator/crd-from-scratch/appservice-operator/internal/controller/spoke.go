@@ -0,0 +1,119 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	webappv1 "mydomain.com/appservice/api/v1"
+)
+
+// ownerAnnotation names the AppService, as "<namespace>/<name>", on children
+// in a spoke cluster. An ownerReference can't point across clusters: the
+// spoke's garbage collector would find no such owner and delete the child.
+const ownerAnnotation = "webapp.mydomain.com/owner"
+
+// children is the client for everything the AppService's Pods use: the
+// spoke's when there is one (see Spoke), the hub's own otherwise. Only the
+// AppService itself is read and written through r.Client.
+func (r *AppServiceReconciler) children() client.Client {
+	if r.spokeClient != nil {
+		return r.spokeClient
+	}
+	return r.Client
+}
+
+// remote reports whether the children live in a spoke cluster.
+func (r *AppServiceReconciler) remote() bool {
+	return r.spokeClient != nil
+}
+
+// owns reports whether obj is a child of the AppService: controlled by it
+// or, in a spoke, annotated with it.
+func (r *AppServiceReconciler) owns(obj client.Object, appService *webappv1.AppService) bool {
+	if r.remote() {
+		return obj.GetAnnotations()[ownerAnnotation] == registryKey(appService)
+	}
+	return metav1.IsControlledBy(obj, appService)
+}
+
+// forCluster returns desired as it is applied where the children live. In a
+// spoke, the ownerReference becomes the owner annotation.
+func (r *AppServiceReconciler) forCluster(appService *webappv1.AppService,
+	desired runtime.ApplyConfiguration) (runtime.ApplyConfiguration, error) {
+	if !r.remote() {
+		return desired, nil
+	}
+	m, err := jsonMap(desired)
+	if err != nil {
+		return nil, err
+	}
+	u := &unstructured.Unstructured{Object: m}
+	unstructured.RemoveNestedField(u.Object, "metadata", "ownerReferences")
+	annotations := u.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[ownerAnnotation] = registryKey(appService)
+	u.SetAnnotations(annotations)
+	return client.ApplyConfigurationFromUnstructured(u), nil
+}
+
+// annotatedOwner is the AppService named by obj's owner annotation, if it is
+// in obj's namespace.
+func annotatedOwner(obj client.Object) (types.NamespacedName, bool) {
+	namespace, name, ok := strings.Cut(obj.GetAnnotations()[ownerAnnotation], "/")
+	if !ok || namespace != obj.GetNamespace() || name == "" {
+		return types.NamespacedName{}, false
+	}
+	return types.NamespacedName{Namespace: namespace, Name: name}, true
+}
+
+// requestForOwner maps a child in the spoke back to its AppService in the
+// hub, where Owns() would follow the ownerReference.
+func requestForOwner(_ context.Context, obj client.Object) []reconcile.Request {
+	key, ok := annotatedOwner(obj)
+	if !ok {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: key}}
+}
+
+// deleteChildren deletes every child in the inventory. Nothing else would in
+// a spoke: its garbage collector doesn't know the AppService.
+func (r *AppServiceReconciler) deleteChildren(ctx context.Context, appService *webappv1.AppService) error {
+	for _, ref := range appService.Status.Inventory {
+		obj, err := r.newChild(ref)
+		if err != nil {
+			return err
+		}
+		err = r.deleteOwned(ctx, appService, ref.Kind, ref.Name, obj)
+		if err != nil && !meta.IsNoMatchError(err) {
+			return err
+		}
+	}
+	return nil
+}
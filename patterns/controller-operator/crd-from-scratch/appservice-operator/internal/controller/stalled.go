@@ -0,0 +1,65 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	webappv1 "mydomain.com/appservice/api/v1"
+	"mydomain.com/appservice/internal/digest"
+)
+
+// stalledReason tells a terminal error, one that retrying won't fix, from a
+// transient one, and returns the Stalled reason for it. It is "" for
+// transient errors.
+func stalledReason(err error) string {
+	switch {
+	case apierrors.IsInvalid(err):
+		return webappv1.ReasonInvalidSpec
+	case errors.Is(err, digest.ErrNotFound):
+		return webappv1.ReasonImageNotFound
+	}
+	return ""
+}
+
+// stall reports a terminal error on the AppService, following the kstatus
+// conventions: Stalled is True with a machine-readable reason, Ready is
+// False, and status.observedGeneration says which spec it's about.
+func (r *AppServiceReconciler) stall(ctx context.Context, key types.NamespacedName, reason string, cause error) error {
+	appService := &webappv1.AppService{}
+	if err := r.Get(ctx, key, appService); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	status := &appService.Status
+	status.ObservedGeneration = appService.Generation
+	for _, condition := range []metav1.Condition{
+		{Type: webappv1.ConditionStalled, Status: metav1.ConditionTrue, Reason: reason},
+		{Type: webappv1.ConditionReady, Status: metav1.ConditionFalse, Reason: "Stalled"},
+	} {
+		condition.ObservedGeneration = appService.Generation
+		condition.Message = cause.Error()
+		meta.SetStatusCondition(&status.Conditions, condition)
+	}
+	return r.Status().Update(ctx, appService)
+}
@@ -0,0 +1,188 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	appsv1ac "k8s.io/client-go/applyconfigurations/apps/v1"
+	corev1ac "k8s.io/client-go/applyconfigurations/core/v1"
+	metav1ac "k8s.io/client-go/applyconfigurations/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	webappv1 "mydomain.com/appservice/api/v1"
+)
+
+// dataVolume is the name of spec.storage's volumeClaimTemplate. Each Pod's
+// PVC is named after it: data-<name>-0, data-<name>-1, ...
+const dataVolume = "data"
+
+// statefulSet reports whether the AppService runs as a StatefulSet.
+func statefulSet(appService *webappv1.AppService) bool {
+	return appService.Spec.WorkloadType == webappv1.WorkloadStatefulSet
+}
+
+// reconcileStatefulSet applies the headless Service and the StatefulSet,
+// and returns the StatefulSet as it is now. Replicas are handed to an
+// autoscaler the same way as for a Deployment.
+func (r *AppServiceReconciler) reconcileStatefulSet(ctx context.Context, appService *webappv1.AppService) (*appsv1.StatefulSet, error) {
+	if err := r.apply(ctx, appService, "Service", headlessServiceName(appService), &corev1.Service{},
+		desiredHeadlessService(appService)); err != nil {
+		return nil, err
+	}
+
+	found := &appsv1.StatefulSet{}
+	key := types.NamespacedName{Name: appService.Name, Namespace: appService.Namespace}
+	if err := r.children().Get(ctx, key, found); client.IgnoreNotFound(err) != nil {
+		return nil, err
+	}
+	desired, err := desiredStatefulSet(appService)
+	if err != nil {
+		return nil, err
+	}
+	if autoscaled(appService) && soleManager(found, fieldOwner, "f:spec", "f:replicas") {
+		desired.Spec.WithReplicas(*found.Spec.Replicas)
+	}
+	if err := r.apply(ctx, appService, "StatefulSet", appService.Name, found, desired); err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+// desiredStatefulSet is desiredDeployment's counterpart. The Pods get stable
+// names (<name>-0, <name>-1, ...) and DNS records through the headless
+// Service, and each one its own PVC from spec.storage.
+func desiredStatefulSet(appService *webappv1.AppService) (*appsv1ac.StatefulSetApplyConfiguration, error) {
+	spec := appsv1ac.StatefulSetSpec().
+		WithServiceName(headlessServiceName(appService)).
+		WithSelector(metav1ac.LabelSelector().WithMatchLabels(podLabels(appService)))
+	if !autoscaled(appService) {
+		spec.WithReplicas(replicas(appService))
+	}
+	template, err := desiredPodTemplate(appService)
+	if err != nil {
+		return nil, err
+	}
+	spec.WithTemplate(template)
+	if storage := appService.Spec.Storage; storage != nil {
+		claim := corev1ac.PersistentVolumeClaimSpec().
+			WithAccessModes(corev1.ReadWriteOnce).
+			WithResources(corev1ac.VolumeResourceRequirements().
+				WithRequests(corev1.ResourceList{corev1.ResourceStorage: storage.Size}))
+		if storage.StorageClassName != nil {
+			claim.WithStorageClassName(*storage.StorageClassName)
+		}
+		// Not corev1ac.PersistentVolumeClaim(): a template has no namespace
+		claimTemplate := &corev1ac.PersistentVolumeClaimApplyConfiguration{}
+		spec.WithVolumeClaimTemplates(claimTemplate.WithName(dataVolume).WithSpec(claim))
+	}
+	return appsv1ac.StatefulSet(appService.Name, appService.Namespace).
+		WithLabels(childLabels(appService)).
+		WithOwnerReferences(ownerReference(appService)).
+		WithSpec(spec), nil
+}
+
+// desiredHeadlessService governs the StatefulSet: it gives each Pod a DNS
+// name, <name>-0.<name>-headless.<namespace>.svc. Not-ready Pods are
+// published too, so peers can find each other while starting up.
+func desiredHeadlessService(appService *webappv1.AppService) *corev1ac.ServiceApplyConfiguration {
+	return corev1ac.Service(headlessServiceName(appService), appService.Namespace).
+		WithLabels(childLabels(appService)).
+		WithOwnerReferences(ownerReference(appService)).
+		WithSpec(corev1ac.ServiceSpec().
+			WithClusterIP(corev1.ClusterIPNone).
+			WithPublishNotReadyAddresses(true).
+			WithSelector(podLabels(appService)).
+			WithPorts(corev1ac.ServicePort().
+				WithName("http").
+				WithProtocol(corev1.ProtocolTCP).
+				WithPort(servicePort(appService))))
+}
+
+func headlessServiceName(appService *webappv1.AppService) string {
+	return appService.Name + "-headless"
+}
+
+// dataMountPath returns spec.storage.mountPath, falling back to the CRD
+// default.
+func dataMountPath(appService *webappv1.AppService) string {
+	if appService.Spec.Storage.MountPath == "" {
+		return "/data"
+	}
+	return appService.Spec.Storage.MountPath
+}
+
+// setStatefulSetConditions derives the conditions from the StatefulSet's
+// counters, as it reports no conditions of its own. It has no progress
+// deadline either, so it's never reported Degraded: a Pod stuck in Pending
+// just keeps it Progressing.
+func setStatefulSetConditions(appService *webappv1.AppService, sts *appsv1.StatefulSet, setCondition conditionSetter) {
+	desired := replicas(appService)
+	if sts.Spec.Replicas != nil {
+		desired = *sts.Spec.Replicas
+	}
+	replicas := fmt.Sprintf("%d/%d replicas available", sts.Status.AvailableReplicas, desired)
+	if sts.Status.AvailableReplicas >= desired {
+		setCondition(webappv1.ConditionAvailable, true, "MinimumReplicasAvailable", replicas)
+	} else {
+		setCondition(webappv1.ConditionAvailable, false, "MinimumReplicasUnavailable", replicas)
+	}
+
+	if statefulSetRolledOut(sts, desired) {
+		setCondition(webappv1.ConditionProgressing, false, "RolloutComplete", "StatefulSet has finished rolling out")
+	} else {
+		setCondition(webappv1.ConditionProgressing, true, "RollingOut", "Waiting for the StatefulSet to roll out")
+	}
+	setCondition(webappv1.ConditionDegraded, false, "AsExpected", "")
+}
+
+// statefulSetRolledOut reports whether the StatefulSet controller has
+// observed the StatefulSet's latest generation and moved all desired Pods to
+// it, ready.
+func statefulSetRolledOut(sts *appsv1.StatefulSet, desired int32) bool {
+	return sts.Status.ObservedGeneration >= sts.Generation &&
+		sts.Status.UpdateRevision == sts.Status.CurrentRevision &&
+		sts.Status.UpdatedReplicas == desired && sts.Status.ReadyReplicas == desired
+}
+
+// statefulSetStatusChanged is deploymentStatusChanged for StatefulSets.
+func statefulSetStatusChanged() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldSts, ok := e.ObjectOld.(*appsv1.StatefulSet)
+			if !ok {
+				return false
+			}
+			newSts, ok := e.ObjectNew.(*appsv1.StatefulSet)
+			if !ok {
+				return false
+			}
+			return oldSts.Status.ObservedGeneration != newSts.Status.ObservedGeneration ||
+				oldSts.Status.Replicas != newSts.Status.Replicas ||
+				oldSts.Status.ReadyReplicas != newSts.Status.ReadyReplicas ||
+				oldSts.Status.AvailableReplicas != newSts.Status.AvailableReplicas ||
+				oldSts.Status.UpdatedReplicas != newSts.Status.UpdatedReplicas ||
+				oldSts.Status.CurrentRevision != newSts.Status.CurrentRevision
+		},
+	}
+}
@@ -0,0 +1,222 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package digest resolves image tags to the digest they point at, through
+// the registry's HTTP API (the OCI distribution spec). A tag can be moved to
+// another image at any time; a digest names exactly one.
+package digest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrNotFound is returned for a tag the registry doesn't have.
+var ErrNotFound = errors.New("manifest unknown")
+
+// Resolver turns an image reference into a digest.
+type Resolver interface {
+	// Resolve returns the digest ("sha256:...") the image's tag points at
+	// now. An image already pinned by digest resolves to that digest.
+	Resolve(ctx context.Context, image string) (string, error)
+}
+
+// Static resolves from a fixed map of image to digest: handy in tests.
+type Static map[string]string
+
+// Resolve implements Resolver.
+func (s Static) Resolve(_ context.Context, image string) (string, error) {
+	if digest, ok := s[image]; ok {
+		return digest, nil
+	}
+	return "", fmt.Errorf("%s: %w", image, ErrNotFound)
+}
+
+// manifestTypes are the media types asked for. The index types come first:
+// for a multi-arch image, the digest to pin is the index's, so every node
+// still pulls its own platform's image.
+var manifestTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// Registry asks the image's registry over HTTPS, anonymously: a registry
+// that wants a bearer token for public pulls (Docker Hub, ghcr.io) gets one
+// from the token service its challenge names. Images that need credentials
+// don't resolve.
+type Registry struct {
+	Client *http.Client
+}
+
+// Resolve implements Resolver.
+func (r *Registry) Resolve(ctx context.Context, image string) (string, error) {
+	if _, digest, found := strings.Cut(image, "@"); found {
+		return digest, nil
+	}
+	host, repository, tag := parse(image)
+	manifest := fmt.Sprintf("https://%s/v2/%s/manifests/%s", host, repository, url.PathEscape(tag))
+
+	var token string
+	resp, err := r.manifest(ctx, http.MethodHead, manifest, token)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		resp.Body.Close() //nolint:errcheck
+		if token, err = r.token(ctx, resp.Header.Get("WWW-Authenticate")); err != nil {
+			return "", fmt.Errorf("%s: %w", image, err)
+		}
+		if resp, err = r.manifest(ctx, http.MethodHead, manifest, token); err != nil {
+			return "", err
+		}
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	if digest := resp.Header.Get("Docker-Content-Digest"); resp.StatusCode == http.StatusOK && digest != "" {
+		return digest, nil
+	}
+	// Some registries only send the digest on GET, or not at all
+	return r.get(ctx, image, manifest, token)
+}
+
+// get fetches the manifest, and hashes it when the registry didn't say its
+// digest: the digest of a manifest is the SHA-256 of its bytes.
+func (r *Registry) get(ctx context.Context, image, manifest, token string) (string, error) {
+	resp, err := r.manifest(ctx, http.MethodGet, manifest, token)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return "", fmt.Errorf("%s: %w", image, ErrNotFound)
+	case resp.StatusCode != http.StatusOK:
+		return "", fmt.Errorf("GET %s: %s", manifest, resp.Status)
+	}
+	if digest := resp.Header.Get("Docker-Content-Digest"); digest != "" {
+		return digest, nil
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, resp.Body); err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func (r *Registry) manifest(ctx context.Context, method, manifest, token string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, manifest, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", strings.Join(manifestTypes, ", "))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return r.client().Do(req)
+}
+
+// token answers a Bearer challenge, such as
+// `Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/nginx:pull"`,
+// with an anonymous token from the realm.
+func (r *Registry) token(ctx context.Context, challenge string) (string, error) {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", fmt.Errorf("unauthorized, and no bearer challenge: %q", challenge)
+	}
+	query := url.Values{}
+	var realm string
+	for params != "" {
+		var key, value string
+		key, params, _ = strings.Cut(strings.TrimLeft(params, " ,"), "=")
+		if strings.HasPrefix(params, `"`) {
+			// Quoted values can hold commas: scope="repository:app:pull,push"
+			value, params, _ = strings.Cut(params[1:], `"`)
+		} else {
+			value, params, _ = strings.Cut(params, ",")
+		}
+		if key == "realm" {
+			realm = value
+		} else {
+			query.Set(key, value)
+		}
+	}
+	if realm == "" {
+		return "", fmt.Errorf("bearer challenge without a realm: %q", challenge)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := r.client().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("GET %s: %s", realm, resp.Status)
+	}
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	return body.AccessToken, nil
+}
+
+func (r *Registry) client() *http.Client {
+	if r.Client != nil {
+		return r.Client
+	}
+	return http.DefaultClient
+}
+
+// parse splits an image reference the way the container runtimes do: the
+// first path component is a host only if it contains a "." or ":" or is
+// "localhost", the tag defaults to "latest", and Docker Hub's official
+// images live under "library/".
+func parse(image string) (host, repository, tag string) {
+	host, repository = "registry-1.docker.io", image
+	if first, rest, found := strings.Cut(image, "/"); found &&
+		(strings.ContainsAny(first, ".:") || first == "localhost") {
+		host, repository = first, rest
+	}
+	if host == "docker.io" {
+		host = "registry-1.docker.io"
+	}
+	tag = "latest"
+	slash := strings.LastIndex(repository, "/")
+	if colon := strings.LastIndex(repository, ":"); colon > slash {
+		repository, tag = repository[:colon], repository[colon+1:]
+	}
+	if host == "registry-1.docker.io" && !strings.Contains(repository, "/") {
+		repository = "library/" + repository
+	}
+	return host, repository, tag
+}
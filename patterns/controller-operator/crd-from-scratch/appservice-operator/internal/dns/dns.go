@@ -0,0 +1,221 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package dns is a fake cloud DNS API, standing in for the kind of external
+// resource an operator provisions: it has its own IDs, takes a while to
+// apply a change, and knows nothing about Kubernetes. Records live in
+// memory, and optionally in a JSON file, so they outlive the operator's
+// process the way a real cloud resource would.
+package dns
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/utils/clock"
+)
+
+// ErrNotFound is returned for a record that doesn't exist.
+var ErrNotFound = errors.New("dns: record not found")
+
+// ErrExists is returned when creating a record whose name is taken.
+var ErrExists = errors.New("dns: record already exists")
+
+// Status is how far a change to a record has propagated.
+type Status string
+
+const (
+	// StatusPending: the change is accepted, but not served everywhere yet.
+	StatusPending Status = "PENDING"
+	// StatusInSync: the record resolves as last written.
+	StatusInSync Status = "INSYNC"
+)
+
+// Record is a CNAME record, as the provider reports it.
+type Record struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Target string `json:"target"`
+	Status Status `json:"status"`
+}
+
+// Provider is the cloud API. Like most, it is not idempotent: Create makes
+// a new record with a new ID on every call, so a caller that lost the ID
+// must look the record up by name rather than create it again.
+type Provider interface {
+	// Create creates a record pointing name at target.
+	Create(ctx context.Context, name, target string) (Record, error)
+	// Get returns the record with the given ID.
+	Get(ctx context.Context, id string) (Record, error)
+	// FindByName returns the record called name.
+	FindByName(ctx context.Context, name string) (Record, error)
+	// Update points the record at a new target.
+	Update(ctx context.Context, id, target string) (Record, error)
+	// Delete deletes the record with the given ID.
+	Delete(ctx context.Context, id string) error
+}
+
+// Fake is an in-process Provider. A change is PENDING until
+// PropagationDelay has passed on Clock.
+type Fake struct {
+	// PropagationDelay is how long a change stays PENDING.
+	PropagationDelay time.Duration
+	// Clock tells when a change was made; tests use a fake one.
+	Clock clock.PassiveClock
+
+	mu    sync.Mutex
+	path  string
+	state state
+}
+
+// state is what the file holds.
+type state struct {
+	NextID  int               `json:"nextID"`
+	Records map[string]*entry `json:"records"`
+}
+
+// entry is a stored record.
+type entry struct {
+	Name      string    `json:"name"`
+	Target    string    `json:"target"`
+	ChangedAt time.Time `json:"changedAt"`
+}
+
+// NewFake returns a Fake whose changes propagate in five seconds. With a
+// path, records are loaded from that file, if it exists, and saved to it on
+// every change.
+func NewFake(path string) (*Fake, error) {
+	f := &Fake{
+		PropagationDelay: 5 * time.Second,
+		Clock:            clock.RealClock{},
+		path:             path,
+		state:            state{Records: map[string]*entry{}},
+	}
+	if path == "" {
+		return f, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &f.state); err != nil {
+		return nil, fmt.Errorf("loading %s: %w", path, err)
+	}
+	return f, nil
+}
+
+// Create implements Provider.
+func (f *Fake) Create(_ context.Context, name, target string) (Record, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, e := range f.state.Records {
+		if e.Name == name {
+			return Record{}, fmt.Errorf("%w: %s", ErrExists, name)
+		}
+	}
+	f.state.NextID++
+	id := fmt.Sprintf("rec-%06d", f.state.NextID)
+	f.state.Records[id] = &entry{Name: name, Target: target, ChangedAt: f.Clock.Now()}
+	return f.record(id), f.save()
+}
+
+// Get implements Provider.
+func (f *Fake) Get(_ context.Context, id string) (Record, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.state.Records[id]; !ok {
+		return Record{}, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return f.record(id), nil
+}
+
+// FindByName implements Provider.
+func (f *Fake) FindByName(_ context.Context, name string) (Record, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for id, e := range f.state.Records {
+		if e.Name == name {
+			return f.record(id), nil
+		}
+	}
+	return Record{}, fmt.Errorf("%w: %s", ErrNotFound, name)
+}
+
+// Update implements Provider.
+func (f *Fake) Update(_ context.Context, id, target string) (Record, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	e, ok := f.state.Records[id]
+	if !ok {
+		return Record{}, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	e.Target, e.ChangedAt = target, f.Clock.Now()
+	return f.record(id), f.save()
+}
+
+// Delete implements Provider.
+func (f *Fake) Delete(_ context.Context, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.state.Records[id]; !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	delete(f.state.Records, id)
+	return f.save()
+}
+
+// Names returns the names of all records, sorted.
+func (f *Fake) Names() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	names := make([]string, 0, len(f.state.Records))
+	for _, e := range f.state.Records {
+		names = append(names, e.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// record returns the record with the given ID, which must exist.
+func (f *Fake) record(id string) Record {
+	e := f.state.Records[id]
+	status := StatusInSync
+	if f.Clock.Since(e.ChangedAt) < f.PropagationDelay {
+		status = StatusPending
+	}
+	return Record{ID: id, Name: e.Name, Target: e.Target, Status: status}
+}
+
+// save writes the records to the file, if there is one.
+func (f *Fake) save() error {
+	if f.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(f.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(f.path, data, 0o600)
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package install applies a bundle of manifests, such as the embedded CRDs
+// and RBAC of config.Bundle, with server-side apply: the manager bootstraps
+// its own API on startup, without make install or kustomize.
+package install
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// FieldOwner is the field manager of everything Apply writes. It differs
+// from the controller's, so neither takes fields from the other, and fields
+// the bundle leaves out (like a conversion webhook patched in by kustomize)
+// are left alone.
+const FieldOwner = client.FieldOwner("appservice-operator-install")
+
+// establishTimeout bounds the wait for the API server to serve new CRDs.
+const establishTimeout = 30 * time.Second
+
+// Apply server-side applies every object in the YAML files of bundle, and
+// waits until the CRDs among them are established, so the caches the
+// manager starts next find their kinds. It returns what it applied. Objects
+// are applied as unstructured, so the scheme needs no apiextensions or RBAC
+// types.
+func Apply(ctx context.Context, c client.Client, bundle fs.FS) ([]*unstructured.Unstructured, error) {
+	objs, err := Read(bundle)
+	if err != nil {
+		return nil, err
+	}
+	for _, obj := range objs {
+		if err := c.Apply(ctx, client.ApplyConfigurationFromUnstructured(obj), FieldOwner, client.ForceOwnership); err != nil {
+			return nil, fmt.Errorf("applying %s %s: %w", obj.GetKind(), obj.GetName(), err)
+		}
+	}
+	for _, obj := range objs {
+		if obj.GetKind() != "CustomResourceDefinition" {
+			continue
+		}
+		if err := waitEstablished(ctx, c, obj.GetName()); err != nil {
+			return nil, err
+		}
+	}
+	return objs, nil
+}
+
+// Read decodes every object in the .yaml files of bundle, walked in lexical
+// order. Files may hold several documents; empty ones are skipped.
+func Read(bundle fs.FS) ([]*unstructured.Unstructured, error) {
+	var objs []*unstructured.Unstructured
+	err := fs.WalkDir(bundle, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || path.Ext(name) != ".yaml" {
+			return err
+		}
+		data, err := fs.ReadFile(bundle, name)
+		if err != nil {
+			return err
+		}
+		decoder := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+		for {
+			obj := &unstructured.Unstructured{}
+			err := decoder.Decode(&obj.Object)
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			if len(obj.Object) > 0 {
+				objs = append(objs, obj)
+			}
+		}
+	})
+	return objs, err
+}
+
+// waitEstablished waits for the API server to report the CRD Established,
+// that is serving its kinds.
+func waitEstablished(ctx context.Context, c client.Client, name string) error {
+	crd := &unstructured.Unstructured{}
+	crd.SetAPIVersion("apiextensions.k8s.io/v1")
+	crd.SetKind("CustomResourceDefinition")
+	err := wait.PollUntilContextTimeout(ctx, time.Second, establishTimeout, true, func(ctx context.Context) (bool, error) {
+		if err := c.Get(ctx, client.ObjectKey{Name: name}, crd); err != nil {
+			return false, err
+		}
+		conditions, _, _ := unstructured.NestedSlice(crd.Object, "status", "conditions")
+		for _, cond := range conditions {
+			if cond, ok := cond.(map[string]any); ok && cond["type"] == "Established" {
+				return cond["status"] == "True", nil
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		return fmt.Errorf("waiting for CRD %s to be established: %w", name, err)
+	}
+	return nil
+}
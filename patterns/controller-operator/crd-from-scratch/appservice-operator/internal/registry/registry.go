@@ -0,0 +1,137 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package registry stands in for a system outside the cluster that must be
+// told when an AppService comes and goes: a service catalog, a DNS provider,
+// a monitoring inventory. Kubernetes garbage collection can't clean those up,
+// which is what the controller's finalizer is for.
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+)
+
+// Registry records where each AppService can be reached. Both calls must be
+// idempotent: the controller retries them until they succeed.
+type Registry interface {
+	// Register records (or updates) the endpoint for key.
+	Register(ctx context.Context, key, endpoint string) error
+	// Deregister removes key. Removing an unknown key is not an error.
+	Deregister(ctx context.Context, key string) error
+}
+
+// Memory is an in-process Registry: the default when no webhook is
+// configured, and handy in tests.
+type Memory struct {
+	mu      sync.Mutex
+	entries map[string]string
+}
+
+// NewMemory returns an empty in-memory registry.
+func NewMemory() *Memory {
+	return &Memory{entries: map[string]string{}}
+}
+
+// Register implements Registry.
+func (m *Memory) Register(_ context.Context, key, endpoint string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[key] = endpoint
+	return nil
+}
+
+// Deregister implements Registry.
+func (m *Memory) Deregister(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, key)
+	return nil
+}
+
+// Lookup returns the endpoint registered for key.
+func (m *Memory) Lookup(key string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	endpoint, ok := m.entries[key]
+	return endpoint, ok
+}
+
+// Keys returns the registered keys, sorted.
+func (m *Memory) Keys() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	keys := make([]string, 0, len(m.entries))
+	for k := range m.entries {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Webhook calls an HTTP endpoint: PUT {URL}/{key} with {"endpoint": "..."}
+// to register, DELETE {URL}/{key} to deregister. A 404 on DELETE counts as
+// done.
+type Webhook struct {
+	URL    string
+	Client *http.Client
+}
+
+// Register implements Registry.
+func (w *Webhook) Register(ctx context.Context, key, endpoint string) error {
+	body, err := json.Marshal(map[string]string{"endpoint": endpoint})
+	if err != nil {
+		return err
+	}
+	return w.do(ctx, http.MethodPut, key, body)
+}
+
+// Deregister implements Registry.
+func (w *Webhook) Deregister(ctx context.Context, key string) error {
+	return w.do(ctx, http.MethodDelete, key, nil)
+}
+
+func (w *Webhook) do(ctx context.Context, method, key string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, method, w.URL+"/"+url.PathEscape(key), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	if method == http.MethodDelete && resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s %s: %s", method, req.URL, resp.Status)
+	}
+	return nil
+}
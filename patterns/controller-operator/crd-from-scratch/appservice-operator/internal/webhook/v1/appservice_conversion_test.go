@@ -0,0 +1,148 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	webappv1 "mydomain.com/appservice/api/v1"
+	webappv2 "mydomain.com/appservice/api/v2"
+)
+
+var _ = Describe("AppService Conversion", func() {
+	DescribeTable("converts spec.image both ways without loss",
+		func(image, v2Image, v2Tag string) {
+			hub := &webappv1.AppService{Spec: webappv1.AppServiceSpec{Image: image, Replicas: ptr.To(int32(3))}}
+
+			spoke := &webappv2.AppService{}
+			Expect(spoke.ConvertFrom(hub)).To(Succeed())
+			Expect(spoke.Spec.Container.Image).To(Equal(v2Image))
+			Expect(spoke.Spec.Container.Tag).To(Equal(v2Tag))
+			Expect(spoke.Spec.Replicas).To(HaveValue(Equal(int32(3))))
+
+			back := &webappv1.AppService{}
+			Expect(spoke.ConvertTo(back)).To(Succeed())
+			Expect(back.Spec).To(Equal(hub.Spec))
+		},
+		Entry("name and tag", "nginx:alpine", "nginx", "alpine"),
+		Entry("no tag", "nginx", "nginx", ""),
+		Entry("registry with port", "registry.local:5000/team/app:1.2", "registry.local:5000/team/app", "1.2"),
+		Entry("registry with port, no tag", "registry.local:5000/app", "registry.local:5000/app", ""),
+		Entry("digest", "nginx@sha256:0123abcd", "nginx@sha256:0123abcd", ""),
+	)
+
+	It("Should keep the canary strategy and its progress", func() {
+		hub := &webappv1.AppService{
+			Spec: webappv1.AppServiceSpec{Image: "nginx:alpine", Strategy: &webappv1.StrategySpec{
+				Canary: &webappv1.CanaryStrategy{Steps: []webappv1.CanaryStep{
+					{Weight: 10, Pause: &metav1.Duration{Duration: time.Minute}}, {Weight: 50},
+				}},
+			}},
+			Status: webappv1.AppServiceStatus{
+				Replicas: 2,
+				Canary:   &webappv1.CanaryStatus{Revision: "abc", Step: 1, Weight: 50},
+			},
+		}
+
+		spoke := &webappv2.AppService{}
+		Expect(spoke.ConvertFrom(hub)).To(Succeed())
+		Expect(spoke.Spec.Strategy.Canary.Steps).To(HaveLen(2))
+		back := &webappv1.AppService{}
+		Expect(spoke.ConvertTo(back)).To(Succeed())
+		Expect(back.Spec).To(Equal(hub.Spec))
+		Expect(back.Status).To(Equal(hub.Status))
+	})
+
+	It("Should keep the blue/green strategy, its state, the references checksum, the inventory and allowFrom", func() {
+		switched := metav1.NewTime(time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC))
+		hub := &webappv1.AppService{
+			Spec: webappv1.AppServiceSpec{
+				Image:             "nginx:alpine",
+				Promote:           "def",
+				Metrics:           &webappv1.MetricsSpec{Port: 9090, Interval: &metav1.Duration{Duration: time.Minute}},
+				PriorityClassName: "high-priority",
+				PinImageDigest:    true,
+				Command:           []string{"nginx"},
+				Args:              []string{"-g", "daemon off;"},
+				Containers: []webappv1.Container{
+					{Name: "log-shipper", Image: "fluent/fluent-bit:3.1", Sidecar: true},
+				},
+				Autoscaling:    &webappv1.AutoscalingSpec{MinReplicas: ptr.To(int32(2)), MaxReplicas: 5, TargetCPUUtilization: 70},
+				MaintenanceJob: &webappv1.MaintenanceJobSpec{Schedule: "0 3 * * *", Args: []string{"vacuum"}},
+				Migrate:        &webappv1.MigrateSpec{Args: []string{"migrate", "up"}, BackoffLimit: ptr.To(int32(2))},
+				NodeSelector:   map[string]string{"kubernetes.io/os": "linux"},
+				Tolerations:    []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpExists}},
+				AllowFrom: []webappv1.NetworkPeer{{
+					PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"role": "frontend"}},
+				}},
+				Strategy: &webappv1.StrategySpec{BlueGreen: &webappv1.BlueGreenStrategy{
+					AutoPromotionAfter: &metav1.Duration{Duration: time.Hour},
+				}},
+			},
+			Status: webappv1.AppServiceStatus{
+				BlueGreen: &webappv1.BlueGreenStatus{
+					ActiveColor: webappv1.ColorGreen, ActiveRevision: "abc", PreviewRevision: "def", SwitchTime: &switched,
+				},
+				ReferencesChecksum: "0e40c5fc",
+				LastGoodImage:      "nginx:alpine",
+				FailedImage:        "nginx:broken",
+				Migration:          &webappv1.MigrationStatus{Image: "nginx:1.28", Job: "shop-migrate-0a1b2c3d4e"},
+				Inventory:          []webappv1.ChildReference{{APIVersion: "apps/v1", Kind: "Deployment", Name: "shop-green"}},
+				DeployedResources: []webappv1.DeployedResource{{
+					APIVersion: "apps/v1", Kind: "Deployment", Name: "shop-green", UID: "0f1e2d3c",
+					Health: webappv1.HealthProgressing, Message: "1/2 replicas available",
+				}},
+			},
+		}
+
+		spoke := &webappv2.AppService{}
+		Expect(spoke.ConvertFrom(hub)).To(Succeed())
+		Expect(spoke.Status.BlueGreen.ActiveColor).To(Equal(webappv2.ColorGreen))
+		back := &webappv1.AppService{}
+		Expect(spoke.ConvertTo(back)).To(Succeed())
+		Expect(back.Spec).To(Equal(hub.Spec))
+		Expect(back.Status).To(Equal(hub.Status))
+	})
+
+	It("Should serve an object stored as v2 in both versions", func() {
+		By("creating it through v1")
+		obj := &webappv1.AppService{
+			ObjectMeta: metav1.ObjectMeta{Name: "conversion-test", Namespace: "default"},
+			Spec:       webappv1.AppServiceSpec{Replicas: ptr.To(int32(2)), Image: "ghcr.io/team/app:1.0"},
+		}
+		Expect(k8sClient.Create(ctx, obj)).To(Succeed())
+		DeferCleanup(func() { Expect(k8sClient.Delete(ctx, obj)).To(Succeed()) })
+
+		By("reading it back as v2")
+		v2obj := &webappv2.AppService{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(obj), v2obj)).To(Succeed())
+		Expect(v2obj.Spec.Container).To(Equal(webappv2.ContainerSpec{Image: "ghcr.io/team/app", Tag: "1.0"}))
+
+		By("bumping the tag through v2 and reading it as v1")
+		v2obj.Spec.Container.Tag = "1.1"
+		Expect(k8sClient.Update(ctx, v2obj)).To(Succeed())
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(obj), obj)).To(Succeed())
+		Expect(obj.Spec.Image).To(Equal("ghcr.io/team/app:1.1"))
+	})
+})
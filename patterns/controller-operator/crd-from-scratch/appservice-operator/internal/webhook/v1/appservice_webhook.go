@@ -0,0 +1,499 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	apivalidation "k8s.io/apimachinery/pkg/api/validation"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	webappv1 "mydomain.com/appservice/api/v1"
+)
+
+// nolint:unused
+// log is for logging in this package.
+var appservicelog = logf.Log.WithName("appservice-resource")
+
+// SetupAppServiceWebhookWithManager registers the webhook for AppService in the manager.
+// allowedRegistries limits where images may come from; empty allows any.
+// Since v1 is the conversion hub, this also serves /convert once the other
+// versions are in the manager's scheme.
+func SetupAppServiceWebhookWithManager(mgr ctrl.Manager, allowedRegistries []string) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&webappv1.AppService{}).
+		WithValidator(&AppServiceCustomValidator{AllowedRegistries: allowedRegistries}).
+		WithDefaulter(&AppServiceCustomDefaulter{}).
+		Complete()
+}
+
+// Defaults for the container's resource requests. Without requests a Pod is
+// BestEffort: the scheduler packs it anywhere and it's the first to be
+// evicted under memory pressure.
+var defaultRequests = corev1.ResourceList{
+	corev1.ResourceCPU:    resource.MustParse("100m"),
+	corev1.ResourceMemory: resource.MustParse("128Mi"),
+}
+
+// Standard labels set on every AppService; the controller copies them onto
+// the Deployment and Service.
+const (
+	nameLabel     = "app.kubernetes.io/name"
+	instanceLabel = "app.kubernetes.io/instance"
+)
+
+// +kubebuilder:webhook:path=/mutate-webapp-mydomain-com-v1-appservice,mutating=true,failurePolicy=fail,sideEffects=None,groups=webapp.mydomain.com,resources=appservices,verbs=create;update,versions=v1,name=mappservice-v1.kb.io,admissionReviewVersions=v1
+
+// AppServiceCustomDefaulter fills in what users may leave out. It runs before
+// validation, and only ever adds: a value the user set is never replaced.
+type AppServiceCustomDefaulter struct{}
+
+var _ webhook.CustomDefaulter = &AppServiceCustomDefaulter{}
+
+// Default implements webhook.CustomDefaulter so a webhook will be registered for the Kind AppService.
+func (d *AppServiceCustomDefaulter) Default(_ context.Context, obj runtime.Object) error {
+	appservice, ok := obj.(*webappv1.AppService)
+	if !ok {
+		return fmt.Errorf("expected a AppService object but got %T", obj)
+	}
+	appservicelog.Info("Defaulting for AppService", "name", appservice.GetName())
+
+	if appservice.Spec.Replicas == nil {
+		replicas := webappv1.DefaultReplicas
+		appservice.Spec.Replicas = &replicas
+	}
+
+	for name, quantity := range defaultRequests {
+		// A limit without a request already means request == limit; a
+		// default request above the user's limit would be rejected.
+		if _, ok := appservice.Spec.Resources.Limits[name]; ok {
+			continue
+		}
+		if _, ok := appservice.Spec.Resources.Requests[name]; ok {
+			continue
+		}
+		if appservice.Spec.Resources.Requests == nil {
+			appservice.Spec.Resources.Requests = corev1.ResourceList{}
+		}
+		appservice.Spec.Resources.Requests[name] = quantity.DeepCopy()
+	}
+
+	// The CRD defaults these too, but not when they're sent as ""
+	if gen := appservice.Spec.GeneratedSecret; gen != nil {
+		if gen.Key == "" {
+			gen.Key = webappv1.DefaultSecretKey
+		}
+		if gen.EnvName == "" {
+			gen.EnvName = webappv1.DefaultSecretEnvName
+		}
+	}
+
+	// With generateName the name is only assigned after admission; the
+	// labels are added on the first update instead.
+	if appservice.Name == "" {
+		return nil
+	}
+	if appservice.Labels == nil {
+		appservice.Labels = map[string]string{}
+	}
+	for _, key := range []string{nameLabel, instanceLabel} {
+		if _, ok := appservice.Labels[key]; !ok {
+			appservice.Labels[key] = appservice.Name
+		}
+	}
+	return nil
+}
+
+// NOTE: The 'path' attribute must follow a specific pattern and should not be modified directly here.
+// Modifying the path for an invalid path can cause API server errors; failing to locate the webhook.
+// +kubebuilder:webhook:path=/validate-webapp-mydomain-com-v1-appservice,mutating=false,failurePolicy=fail,sideEffects=None,groups=webapp.mydomain.com,resources=appservices,verbs=create;update,versions=v1,name=vappservice-v1.kb.io,admissionReviewVersions=v1
+
+// AppServiceCustomValidator rejects AppServices the controller can't turn
+// into a working Deployment. The CRD schema already checks types and simple
+// bounds; the webhook adds what OpenAPI can't express, like a registry
+// allow-list that changes with a flag rather than with the CRD.
+type AppServiceCustomValidator struct {
+	// AllowedRegistries are registry hosts (e.g. "ghcr.io",
+	// "registry.example.com:5000"). Images without a host are from docker.io.
+	AllowedRegistries []string
+}
+
+var _ webhook.CustomValidator = &AppServiceCustomValidator{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type AppService.
+func (v *AppServiceCustomValidator) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	appservice, ok := obj.(*webappv1.AppService)
+	if !ok {
+		return nil, fmt.Errorf("expected a AppService object but got %T", obj)
+	}
+	appservicelog.Info("Validation for AppService upon creation", "name", appservice.GetName())
+
+	return warnings(appservice), v.validate(appservice, nil)
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type AppService.
+func (v *AppServiceCustomValidator) ValidateUpdate(_ context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	appservice, ok := newObj.(*webappv1.AppService)
+	if !ok {
+		return nil, fmt.Errorf("expected a AppService object for the newObj but got %T", newObj)
+	}
+	old, ok := oldObj.(*webappv1.AppService)
+	if !ok {
+		return nil, fmt.Errorf("expected a AppService object for the oldObj but got %T", oldObj)
+	}
+	appservicelog.Info("Validation for AppService upon update", "name", appservice.GetName())
+
+	// Once deleted, the only updates left are the controller removing its
+	// finalizer. Refusing one, e.g. after the allow-list was narrowed,
+	// would leave the AppService Terminating for good.
+	if appservice.DeletionTimestamp != nil {
+		return nil, nil
+	}
+
+	return warnings(appservice), v.validate(appservice, old)
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type AppService.
+func (v *AppServiceCustomValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	// Deletion is never refused; the finalizer handles cleanup.
+	return nil, nil
+}
+
+// warnings flags specs that are valid but likely not what the user wants.
+// kubectl prints them, and the object is still admitted.
+func warnings(appservice *webappv1.AppService) admission.Warnings {
+	var warns admission.Warnings
+	minAvailable, replicas := appservice.Spec.MinAvailable, appservice.Spec.Replicas
+	// Under an autoscaler, spec.replicas isn't the Pod count to check against
+	if minAvailable != nil && replicas != nil && *replicas > 0 && !autoscaled(appservice) {
+		available, err := intstr.GetScaledValueFromIntOrPercent(minAvailable, int(*replicas), true)
+		if err == nil && available >= int(*replicas) {
+			warns = append(warns, fmt.Sprintf("spec.minAvailable (%s) leaves no Pod to evict out of %d replicas; "+
+				"node drains will block until it is lowered", minAvailable.String(), *replicas))
+		}
+	}
+	// CPU utilization is relative to the request of every container in the
+	// Pod, and the extra ones get none
+	if appservice.Spec.Autoscaling != nil && len(appservice.Spec.Containers) > 0 {
+		warns = append(warns, "spec.containers have no CPU request, which spec.autoscaling needs to measure "+
+			"the Pods' CPU utilization; the HorizontalPodAutoscaler won't scale until they get one, "+
+			"e.g. from a LimitRange")
+	}
+	return warns
+}
+
+// validate collects every problem at once, so users fix them in one round
+// trip, and returns them as a standard Invalid error (HTTP 422) that kubectl
+// prints field by field. old is the stored object on update, nil on create.
+func (v *AppServiceCustomValidator) validate(appservice, old *webappv1.AppService) error {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	// Images the stored object already has were admitted under the
+	// allow-list of their time. A narrower list applies to new images only,
+	// or every update of an older AppService, a label or a finalizer
+	// included, would be refused.
+	checkImage := func(image string, fldPath *field.Path) field.ErrorList {
+		if old != nil && slices.Contains(images(old), image) {
+			return nil
+		}
+		return v.validateImage(image, fldPath)
+	}
+
+	if appservice.Spec.Replicas != nil && *appservice.Spec.Replicas < 0 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("replicas"), *appservice.Spec.Replicas,
+			"must be greater than or equal to 0"))
+	}
+
+	allErrs = append(allErrs, checkImage(appservice.Spec.Image, specPath.Child("image"))...)
+	for i, container := range appservice.Spec.Containers {
+		containerPath := specPath.Child("containers").Index(i)
+		if container.Name == "main" {
+			allErrs = append(allErrs, field.Invalid(containerPath.Child("name"), container.Name,
+				"is the app's own container"))
+		}
+		for _, msg := range validation.IsDNS1123Label(container.Name) {
+			allErrs = append(allErrs, field.Invalid(containerPath.Child("name"), container.Name, msg))
+		}
+		// The sidecars' images come from the same registries as the app's
+		allErrs = append(allErrs, checkImage(container.Image, containerPath.Child("image"))...)
+		for j, port := range container.Ports {
+			portPath := containerPath.Child("ports").Index(j)
+			for _, msg := range validation.IsValidPortNum(int(port.ContainerPort)) {
+				allErrs = append(allErrs, field.Invalid(portPath.Child("containerPort"), port.ContainerPort, msg))
+			}
+			if port.Name == "" {
+				continue
+			}
+			for _, msg := range validation.IsValidPortName(port.Name) {
+				allErrs = append(allErrs, field.Invalid(portPath.Child("name"), port.Name, msg))
+			}
+		}
+	}
+
+	// Config keys become ConfigMap keys and file names under /etc/app
+	for _, key := range slices.Sorted(maps.Keys(appservice.Spec.Config)) {
+		for _, msg := range validation.IsConfigMapKey(key) {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("config").Key(key), key, msg))
+		}
+	}
+
+	for i, ref := range appservice.Spec.ImagePullSecretRefs {
+		for _, msg := range validation.IsDNS1123Subdomain(ref.Name) {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("imagePullSecretRefs").Index(i).Child("name"), ref.Name, msg))
+		}
+	}
+
+	if minAvailable := appservice.Spec.MinAvailable; minAvailable != nil {
+		// The same check the API server makes on the PodDisruptionBudget,
+		// which would otherwise only fail in the controller
+		percent, err := intstr.GetScaledValueFromIntOrPercent(minAvailable, 100, true)
+		switch {
+		case err != nil:
+			allErrs = append(allErrs, field.Invalid(specPath.Child("minAvailable"), minAvailable.String(),
+				"must be a number or a percentage like \"50%\""))
+		case minAvailable.Type == intstr.String && percent > 100:
+			allErrs = append(allErrs, field.Invalid(specPath.Child("minAvailable"), minAvailable.StrVal,
+				"must not be greater than 100%"))
+		case minAvailable.Type == intstr.Int && minAvailable.IntVal < 0:
+			allErrs = append(allErrs, field.Invalid(specPath.Child("minAvailable"), minAvailable.IntVal,
+				"must be greater than or equal to 0"))
+		}
+	}
+
+	if gen := appservice.Spec.GeneratedSecret; gen != nil {
+		genPath := specPath.Child("generatedSecret")
+		for _, msg := range validation.IsConfigMapKey(gen.Key) {
+			allErrs = append(allErrs, field.Invalid(genPath.Child("key"), gen.Key, msg))
+		}
+		for _, msg := range validation.IsEnvVarName(gen.EnvName) {
+			allErrs = append(allErrs, field.Invalid(genPath.Child("envName"), gen.EnvName, msg))
+		}
+		// The controller adds the env var next to spec.env; a duplicate
+		// name would make every apply of the Deployment fail
+		for _, env := range appservice.Spec.Env {
+			if env.Name == gen.EnvName {
+				allErrs = append(allErrs, field.Duplicate(genPath.Child("envName"), gen.EnvName))
+			}
+		}
+	}
+
+	if appservice.Spec.Storage != nil && workloadType(appservice) != webappv1.WorkloadStatefulSet {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("storage"),
+			"only a StatefulSet has per-Pod storage; set workloadType to StatefulSet"))
+	}
+	if strategy := appservice.Spec.Strategy; strategy != nil && strategy.Canary != nil {
+		canaryPath := specPath.Child("strategy", "canary")
+		switch {
+		case workloadType(appservice) != webappv1.WorkloadDeployment:
+			allErrs = append(allErrs, field.Forbidden(canaryPath, "only Deployments can run a canary"))
+		case autoscaled(appservice):
+			// The canary's share is computed from spec.replicas, which
+			// an autoscaler doesn't keep up to date
+			allErrs = append(allErrs, field.Forbidden(canaryPath, "an autoscaled AppService can't run a canary"))
+		}
+		for i, step := range strategy.Canary.Steps {
+			if step.Pause != nil && step.Pause.Duration < 0 {
+				allErrs = append(allErrs, field.Invalid(canaryPath.Child("steps").Index(i).Child("pause"),
+					step.Pause.Duration.String(), "must not be negative"))
+			}
+		}
+	}
+	if strategy := appservice.Spec.Strategy; strategy != nil && strategy.BlueGreen != nil {
+		blueGreenPath := specPath.Child("strategy", "blueGreen")
+		switch {
+		case strategy.Canary != nil:
+			allErrs = append(allErrs, field.Forbidden(blueGreenPath, "can't be combined with a canary"))
+		case workloadType(appservice) != webappv1.WorkloadDeployment:
+			allErrs = append(allErrs, field.Forbidden(blueGreenPath, "only Deployments can run blue/green"))
+		case autoscaled(appservice):
+			// Both colors are sized from spec.replicas, for the same reason
+			allErrs = append(allErrs, field.Forbidden(blueGreenPath, "an autoscaled AppService can't run blue/green"))
+		}
+		if after := strategy.BlueGreen.AutoPromotionAfter; after != nil && after.Duration < 0 {
+			allErrs = append(allErrs, field.Invalid(blueGreenPath.Child("autoPromotionAfter"),
+				after.Duration.String(), "must not be negative"))
+		}
+	}
+
+	for i, peer := range appservice.Spec.AllowFrom {
+		peerPath := specPath.Child("allowFrom").Index(i)
+		if peer.NamespaceSelector == nil && peer.PodSelector == nil {
+			allErrs = append(allErrs, field.Required(peerPath, "a namespaceSelector, a podSelector or both"))
+		}
+		allErrs = append(allErrs, metav1validation.ValidateLabelSelector(peer.NamespaceSelector,
+			metav1validation.LabelSelectorValidationOptions{}, peerPath.Child("namespaceSelector"))...)
+		allErrs = append(allErrs, metav1validation.ValidateLabelSelector(peer.PodSelector,
+			metav1validation.LabelSelectorValidationOptions{}, peerPath.Child("podSelector"))...)
+	}
+	// StatefulSets have no progress deadline to exceed
+	if appservice.Spec.ProgressDeadlineSeconds != nil && workloadType(appservice) != webappv1.WorkloadDeployment {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("progressDeadlineSeconds"),
+			"only Deployments have a progress deadline"))
+	}
+	if appservice.Spec.RollbackOnFailure {
+		rollbackPath := specPath.Child("rollbackOnFailure")
+		switch {
+		case workloadType(appservice) != webappv1.WorkloadDeployment:
+			allErrs = append(allErrs, field.Forbidden(rollbackPath, "only Deployments can be rolled back"))
+		case appservice.Spec.Strategy != nil &&
+			(appservice.Spec.Strategy.Canary != nil || appservice.Spec.Strategy.BlueGreen != nil):
+			allErrs = append(allErrs, field.Forbidden(rollbackPath,
+				"canary and blue/green rollouts are gated by their own steps"))
+		}
+	}
+	if autoscaling := appservice.Spec.Autoscaling; autoscaling != nil {
+		autoscalingPath := specPath.Child("autoscaling")
+		if autoscaling.MaxReplicas < 1 {
+			allErrs = append(allErrs, field.Invalid(autoscalingPath.Child("maxReplicas"), autoscaling.MaxReplicas,
+				"must be greater than or equal to 1"))
+		}
+		if minReplicas := autoscaling.MinReplicas; minReplicas != nil && *minReplicas > autoscaling.MaxReplicas {
+			allErrs = append(allErrs, field.Invalid(autoscalingPath.Child("minReplicas"), *minReplicas,
+				"must not be greater than maxReplicas"))
+		}
+	}
+	if job := appservice.Spec.MaintenanceJob; job != nil {
+		jobPath := specPath.Child("maintenanceJob")
+		if job.Schedule == "" {
+			allErrs = append(allErrs, field.Required(jobPath.Child("schedule"), "a cron schedule is required"))
+		}
+		// Without an image of its own, the task runs the app's
+		if job.Image != "" {
+			allErrs = append(allErrs, checkImage(job.Image, jobPath.Child("image"))...)
+		}
+	}
+	if migrate := appservice.Spec.Migrate; migrate != nil {
+		migratePath := specPath.Child("migrate")
+		switch {
+		case workloadType(appservice) != webappv1.WorkloadDeployment:
+			allErrs = append(allErrs, field.Forbidden(migratePath, "only Deployments are migrated"))
+		case appservice.Spec.Strategy != nil &&
+			(appservice.Spec.Strategy.Canary != nil || appservice.Spec.Strategy.BlueGreen != nil):
+			// Both run the new image next to the old one from the start
+			allErrs = append(allErrs, field.Forbidden(migratePath,
+				"canary and blue/green rollouts can't wait for a migration"))
+		}
+		if len(migrate.Command) == 0 && len(migrate.Args) == 0 {
+			allErrs = append(allErrs, field.Required(migratePath, "a command, args or both"))
+		}
+		if migrate.BackoffLimit != nil && *migrate.BackoffLimit < 0 {
+			allErrs = append(allErrs, field.Invalid(migratePath.Child("backoffLimit"), *migrate.BackoffLimit,
+				"must be greater than or equal to 0"))
+		}
+	}
+	if metrics := appservice.Spec.Metrics; metrics != nil && metrics.Interval != nil {
+		// Prometheus durations have no fractions of a second
+		if interval := metrics.Interval.Duration; interval < time.Second || interval%time.Second != 0 {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("metrics", "interval"),
+				interval.String(), "must be a whole number of seconds, at least one"))
+		}
+	}
+
+	if old != nil {
+		// Switching the workload would recreate every Pod at once, and
+		// StatefulSet volumeClaimTemplates can't be changed at all
+		allErrs = append(allErrs, apivalidation.ValidateImmutableField(
+			workloadType(appservice), workloadType(old), specPath.Child("workloadType"))...)
+		allErrs = append(allErrs, apivalidation.ValidateImmutableField(
+			appservice.Spec.Storage, old.Spec.Storage, specPath.Child("storage"))...)
+	}
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(webappv1.GroupVersion.WithKind("AppService").GroupKind(), appservice.Name, allErrs)
+}
+
+// validateImage checks that an image is set, and comes from an allowed
+// registry.
+func (v *AppServiceCustomValidator) validateImage(image string, fldPath *field.Path) field.ErrorList {
+	switch image = strings.TrimSpace(image); {
+	case image == "":
+		return field.ErrorList{field.Required(fldPath, "an image is required")}
+	case !v.registryAllowed(imageRegistry(image)):
+		return field.ErrorList{field.Forbidden(fldPath, fmt.Sprintf(
+			"registry %q is not allowed; use one of: %s", imageRegistry(image), strings.Join(v.AllowedRegistries, ", ")))}
+	}
+	return nil
+}
+
+// images returns every image an AppService runs: the app's, the sidecars'
+// and the maintenance job's.
+func images(appservice *webappv1.AppService) []string {
+	all := []string{appservice.Spec.Image}
+	for _, container := range appservice.Spec.Containers {
+		all = append(all, container.Image)
+	}
+	if job := appservice.Spec.MaintenanceJob; job != nil && job.Image != "" {
+		all = append(all, job.Image)
+	}
+	return all
+}
+
+// autoscaled reports whether an autoscaler sizes the workload: the HPA of
+// spec.autoscaling, or an external one (webappv1.AutoscalingAnnotation).
+func autoscaled(appservice *webappv1.AppService) bool {
+	return appservice.Spec.Autoscaling != nil || appservice.Annotations[webappv1.AutoscalingAnnotation] == "enabled"
+}
+
+// workloadType returns spec.workloadType, falling back to the CRD default.
+func workloadType(appservice *webappv1.AppService) webappv1.WorkloadType {
+	if appservice.Spec.WorkloadType == "" {
+		return webappv1.WorkloadDeployment
+	}
+	return appservice.Spec.WorkloadType
+}
+
+func (v *AppServiceCustomValidator) registryAllowed(registry string) bool {
+	if len(v.AllowedRegistries) == 0 {
+		return true
+	}
+	for _, allowed := range v.AllowedRegistries {
+		if strings.EqualFold(strings.TrimSpace(allowed), registry) {
+			return true
+		}
+	}
+	return false
+}
+
+// imageRegistry returns the registry host of an image reference, using the
+// same rule as the container runtimes: the first path component is a host
+// only if it contains a "." or ":" or is "localhost"; otherwise the image is
+// on Docker Hub ("nginx", "bitnami/redis").
+func imageRegistry(image string) string {
+	first, _, found := strings.Cut(image, "/")
+	if found && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		return strings.ToLower(first)
+	}
+	return "docker.io"
+}
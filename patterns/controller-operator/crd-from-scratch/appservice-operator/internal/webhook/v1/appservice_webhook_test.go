@@ -0,0 +1,398 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	webappv1 "mydomain.com/appservice/api/v1"
+)
+
+var _ = Describe("AppService Webhook", func() {
+	var (
+		obj       *webappv1.AppService
+		oldObj    *webappv1.AppService
+		validator AppServiceCustomValidator
+		defaulter AppServiceCustomDefaulter
+	)
+
+	BeforeEach(func() {
+		obj = &webappv1.AppService{
+			ObjectMeta: metav1.ObjectMeta{Name: "webhook-test", Namespace: "default"},
+			Spec:       webappv1.AppServiceSpec{Replicas: ptr.To(int32(2)), Image: "nginx:alpine", Port: 80},
+		}
+		oldObj = obj.DeepCopy()
+		validator = AppServiceCustomValidator{AllowedRegistries: testAllowedRegistries}
+		defaulter = AppServiceCustomDefaulter{}
+	})
+
+	Context("When creating AppService under Defaulting Webhook", func() {
+		It("Should apply defaults when fields are not set", func() {
+			obj.Spec.Replicas = nil
+			Expect(defaulter.Default(ctx, obj)).To(Succeed())
+			Expect(obj.Spec.Replicas).To(HaveValue(Equal(webappv1.DefaultReplicas)))
+			Expect(obj.Spec.Resources.Requests.Cpu().String()).To(Equal("100m"))
+			Expect(obj.Spec.Resources.Requests.Memory().String()).To(Equal("128Mi"))
+			Expect(obj.Labels).To(HaveKeyWithValue(nameLabel, "webhook-test"))
+			Expect(obj.Labels).To(HaveKeyWithValue(instanceLabel, "webhook-test"))
+		})
+
+		It("Should keep what the user set", func() {
+			obj.Spec.Replicas = ptr.To(int32(0))
+			obj.Spec.Resources.Requests = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")}
+			obj.Labels = map[string]string{nameLabel: "shop"}
+			Expect(defaulter.Default(ctx, obj)).To(Succeed())
+			Expect(obj.Spec.Replicas).To(HaveValue(BeZero()))
+			Expect(obj.Spec.Resources.Requests.Cpu().String()).To(Equal("1"))
+			Expect(obj.Spec.Resources.Requests.Memory().String()).To(Equal("128Mi"))
+			Expect(obj.Labels).To(HaveKeyWithValue(nameLabel, "shop"))
+		})
+
+		It("Should not add a request where a limit is set", func() {
+			obj.Spec.Resources.Limits = corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("64Mi")}
+			Expect(defaulter.Default(ctx, obj)).To(Succeed())
+			Expect(obj.Spec.Resources.Requests).NotTo(HaveKey(corev1.ResourceMemory))
+			Expect(obj.Spec.Resources.Requests).To(HaveKey(corev1.ResourceCPU))
+		})
+
+		It("Should fill in an empty generated Secret", func() {
+			obj.Spec.GeneratedSecret = &webappv1.GeneratedSecretSpec{}
+			Expect(defaulter.Default(ctx, obj)).To(Succeed())
+			Expect(obj.Spec.GeneratedSecret.Key).To(Equal(webappv1.DefaultSecretKey))
+			Expect(obj.Spec.GeneratedSecret.EnvName).To(Equal(webappv1.DefaultSecretEnvName))
+		})
+
+		It("Should be called by the API server", func() {
+			obj.Name = "webhook-defaulted"
+			obj.Spec.Replicas = nil
+			Expect(k8sClient.Create(ctx, obj)).To(Succeed())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, obj)).To(Succeed()) })
+
+			stored := &webappv1.AppService{}
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(obj), stored)).To(Succeed())
+			Expect(stored.Spec.Replicas).To(HaveValue(Equal(webappv1.DefaultReplicas)))
+			Expect(stored.Labels).To(HaveKeyWithValue(instanceLabel, "webhook-defaulted"))
+		})
+	})
+
+	Context("When creating or updating AppService under Validating Webhook", func() {
+		It("Should admit a valid spec", func() {
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+			Expect(validator.ValidateUpdate(ctx, oldObj, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should deny negative replicas", func() {
+			obj.Spec.Replicas = ptr.To(int32(-1))
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.replicas"))
+		})
+
+		It("Should deny an empty image", func() {
+			obj.Spec.Image = " "
+			_, err := validator.ValidateUpdate(ctx, oldObj, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.image: Required value"))
+		})
+
+		It("Should deny an image from a registry that is not allowed", func() {
+			obj.Spec.Image = "quay.io/prometheus/busybox:latest"
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring(`registry "quay.io" is not allowed`))
+		})
+
+		It("Should deny config keys that can't be file names", func() {
+			obj.Spec.Config = map[string]string{"app.yaml": "", "../etc/passwd": ""}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.config[../etc/passwd]"))
+			Expect(err.Error()).NotTo(ContainSubstring("app.yaml"))
+		})
+
+		It("Should deny a generated Secret env var that clashes with spec.env", func() {
+			obj.Spec.Env = []corev1.EnvVar{{Name: "APP_TOKEN", Value: "x"}}
+			obj.Spec.GeneratedSecret = &webappv1.GeneratedSecretSpec{Key: "token", EnvName: "APP_TOKEN"}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.generatedSecret.envName: Duplicate value"))
+		})
+
+		It("Should deny a minAvailable that is neither a number nor a percentage", func() {
+			obj.Spec.MinAvailable = ptr.To(intstr.FromString("half"))
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.minAvailable"))
+		})
+
+		It("Should warn when minAvailable would block every eviction", func() {
+			obj.Spec.MinAvailable = ptr.To(intstr.FromString("100%"))
+			warnings, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(warnings).To(ConsistOf(ContainSubstring("node drains will block")))
+
+			obj.Spec.MinAvailable = ptr.To(intstr.FromInt32(1))
+			Expect(validator.ValidateCreate(ctx, obj)).To(BeEmpty())
+		})
+
+		It("Should check spec.autoscaling, and treat it like an external autoscaler", func() {
+			obj.Spec.Autoscaling = &webappv1.AutoscalingSpec{MinReplicas: ptr.To(int32(5)), MaxReplicas: 3}
+			obj.Spec.Strategy = &webappv1.StrategySpec{BlueGreen: &webappv1.BlueGreenStrategy{}}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.autoscaling.minReplicas: Invalid value: 5"))
+			Expect(err.Error()).To(ContainSubstring("spec.strategy.blueGreen: Forbidden: an autoscaled AppService can't run blue/green"))
+
+			By("adding a container without a CPU request")
+			obj.Spec.Strategy = nil
+			obj.Spec.Autoscaling.MinReplicas = ptr.To(int32(2))
+			obj.Spec.MinAvailable = ptr.To(intstr.FromString("100%"))
+			obj.Spec.Containers = []webappv1.Container{{Name: "proxy", Image: "ghcr.io/team/envoy:v1"}}
+			warnings, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(warnings).To(ConsistOf(ContainSubstring("spec.containers have no CPU request")))
+		})
+
+		It("Should deny storage on a Deployment", func() {
+			obj.Spec.Storage = &webappv1.StorageSpec{Size: resource.MustParse("1Gi")}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.storage: Forbidden"))
+
+			obj.Spec.WorkloadType = webappv1.WorkloadStatefulSet
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should deny a canary that can't work", func() {
+			obj.Spec.Strategy = &webappv1.StrategySpec{Canary: &webappv1.CanaryStrategy{Steps: []webappv1.CanaryStep{
+				{Weight: 25, Pause: &metav1.Duration{Duration: -time.Minute}},
+			}}}
+			obj.Annotations = map[string]string{webappv1.AutoscalingAnnotation: "enabled"}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.strategy.canary: Forbidden: an autoscaled AppService can't run a canary"))
+			Expect(err.Error()).To(ContainSubstring("spec.strategy.canary.steps[0].pause: Invalid value: \"-1m0s\""))
+
+			obj.Annotations = nil
+			obj.Spec.Strategy.Canary.Steps[0].Pause.Duration = time.Minute
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should deny a blue/green strategy that can't work", func() {
+			obj.Spec.Strategy = &webappv1.StrategySpec{
+				Canary:    &webappv1.CanaryStrategy{Steps: []webappv1.CanaryStep{{Weight: 50}}},
+				BlueGreen: &webappv1.BlueGreenStrategy{AutoPromotionAfter: &metav1.Duration{Duration: -time.Minute}},
+			}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.strategy.blueGreen: Forbidden: can't be combined with a canary"))
+			Expect(err.Error()).To(ContainSubstring("spec.strategy.blueGreen.autoPromotionAfter: Invalid value: \"-1m0s\""))
+
+			obj.Spec.Strategy.Canary = nil
+			obj.Spec.Strategy.BlueGreen.AutoPromotionAfter.Duration = time.Minute
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should deny a scrape interval Prometheus can't take", func() {
+			obj.Spec.Metrics = &webappv1.MetricsSpec{Interval: &metav1.Duration{Duration: 1500 * time.Millisecond}}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.metrics.interval: Invalid value: \"1.5s\""))
+
+			obj.Spec.Metrics.Interval.Duration = 15 * time.Second
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should only roll back Deployments without a strategy", func() {
+			obj.Spec.RollbackOnFailure = true
+			obj.Spec.Strategy = &webappv1.StrategySpec{BlueGreen: &webappv1.BlueGreenStrategy{}}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.rollbackOnFailure: Forbidden"))
+
+			obj.Spec.Strategy = nil
+			obj.Spec.WorkloadType = webappv1.WorkloadStatefulSet
+			obj.Spec.ProgressDeadlineSeconds = ptr.To(int32(60))
+			_, err = validator.ValidateCreate(ctx, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.progressDeadlineSeconds: Forbidden"))
+
+			obj.Spec.WorkloadType = webappv1.WorkloadDeployment
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should deny an allowFrom peer without selectors", func() {
+			obj.Spec.AllowFrom = []webappv1.NetworkPeer{{}}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.allowFrom[0]: Required value"))
+
+			obj.Spec.AllowFrom[0].PodSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"role": "frontend"}}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should check spec.containers like the app's own", func() {
+			obj.Spec.Containers = []webappv1.Container{
+				{Name: "main", Image: "nginx:alpine"},
+				{Name: "proxy", Image: "registry.example.com/envoy:v1",
+					Ports: []corev1.ContainerPort{{Name: "not_a_port_name", ContainerPort: 70000}}},
+			}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.containers[0].name: Invalid value: \"main\""))
+			Expect(err.Error()).To(ContainSubstring("spec.containers[1].image: Forbidden: registry \"registry.example.com\""))
+			Expect(err.Error()).To(ContainSubstring("spec.containers[1].ports[0].containerPort: Invalid value: 70000"))
+			Expect(err.Error()).To(ContainSubstring("spec.containers[1].ports[0].name: Invalid value: \"not_a_port_name\""))
+
+			obj.Spec.Containers = []webappv1.Container{{Name: "proxy", Image: "ghcr.io/team/envoy:v1", Sidecar: true}}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should only migrate plain Deployments, and check the maintenance job", func() {
+			obj.Spec.Migrate = &webappv1.MigrateSpec{}
+			obj.Spec.Strategy = &webappv1.StrategySpec{BlueGreen: &webappv1.BlueGreenStrategy{}}
+			obj.Spec.MaintenanceJob = &webappv1.MaintenanceJobSpec{Image: "registry.example.com/tools:v1"}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.migrate: Forbidden: canary and blue/green rollouts"))
+			Expect(err.Error()).To(ContainSubstring("spec.migrate: Required value: a command, args or both"))
+			Expect(err.Error()).To(ContainSubstring("spec.maintenanceJob.schedule: Required value"))
+			Expect(err.Error()).To(ContainSubstring("spec.maintenanceJob.image: Forbidden: registry \"registry.example.com\""))
+
+			obj.Spec.Strategy = nil
+			obj.Spec.Migrate.Args = []string{"migrate", "up"}
+			obj.Spec.MaintenanceJob = &webappv1.MaintenanceJobSpec{Schedule: "0 3 * * *", Args: []string{"vacuum"}}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should deny switching the workload type", func() {
+			obj.Spec.WorkloadType = webappv1.WorkloadStatefulSet
+			_, err := validator.ValidateUpdate(ctx, oldObj, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.workloadType: Invalid value: \"StatefulSet\": field is immutable"))
+		})
+
+		It("Should let an AppService being deleted drop its finalizer", func() {
+			// Admitted under a wider allow-list than the one in force now
+			oldObj.Spec.Image = "quay.io/prometheus/busybox:latest"
+			oldObj.Finalizers = []string{"webapp.mydomain.com/registry-cleanup"}
+			oldObj.DeletionTimestamp = ptr.To(metav1.Now())
+			obj = oldObj.DeepCopy()
+			obj.Finalizers = nil
+			Expect(validator.ValidateUpdate(ctx, oldObj, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should keep admitting images the allow-list no longer has, but not new ones", func() {
+			oldObj.Spec.Image = "quay.io/prometheus/busybox:latest"
+			obj = oldObj.DeepCopy()
+			obj.Labels = map[string]string{"team": "payments"}
+			Expect(validator.ValidateUpdate(ctx, oldObj, obj)).Error().NotTo(HaveOccurred())
+
+			obj.Spec.Containers = []webappv1.Container{{Name: "proxy", Image: "quay.io/envoy:v1"}}
+			_, err := validator.ValidateUpdate(ctx, oldObj, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring(`spec.containers[0].image: Forbidden: registry "quay.io" is not allowed`))
+			Expect(err.Error()).NotTo(ContainSubstring("spec.image"))
+		})
+
+		It("Should admit any registry when no allow-list is configured", func() {
+			validator.AllowedRegistries = nil
+			obj.Spec.Image = "quay.io/prometheus/busybox:latest"
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should be called by the API server", func() {
+			By("creating an AppService with an image from a registry that is not allowed")
+			obj.Spec.Image = "registry.example.com/team/app:1.0"
+			err := k8sClient.Create(ctx, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("admission webhook \"vappservice-v1.kb.io\" denied the request"))
+
+			By("creating a valid AppService and updating it to an empty image")
+			obj.Spec.Image = "ghcr.io/team/app:1.0"
+			Expect(k8sClient.Create(ctx, obj)).To(Succeed())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, obj)).To(Succeed()) })
+			obj.Spec.Image = ""
+			Expect(apierrors.IsInvalid(k8sClient.Update(ctx, obj))).To(BeTrue())
+		})
+	})
+
+	Context("When the API server checks the CRD's own rules", func() {
+		It("Should deny what the schema can tell is wrong, before any webhook", func() {
+			By("creating a Deployment with storage")
+			obj.Name = "cel-storage"
+			obj.Spec.Storage = &webappv1.StorageSpec{Size: resource.MustParse("1Gi")}
+			err := k8sClient.Create(ctx, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec: Invalid value: \"object\": storage is only valid with workloadType StatefulSet"))
+			Expect(err.Error()).NotTo(ContainSubstring("admission webhook"))
+
+			By("combining a canary with blue/green")
+			obj.Spec.Storage = nil
+			obj.Spec.Strategy = &webappv1.StrategySpec{
+				Canary:    &webappv1.CanaryStrategy{Steps: []webappv1.CanaryStep{{Weight: 50}}},
+				BlueGreen: &webappv1.BlueGreenStrategy{},
+			}
+			err = k8sClient.Create(ctx, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.strategy: Invalid value: \"object\": canary and blueGreen can't be combined"))
+
+			By("migrating a StatefulSet without saying how")
+			obj.Spec.Strategy = nil
+			obj.Spec.WorkloadType = webappv1.WorkloadStatefulSet
+			obj.Spec.Migrate = &webappv1.MigrateSpec{}
+			err = k8sClient.Create(ctx, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec: Invalid value: \"object\": only Deployments are migrated"))
+			Expect(err.Error()).To(ContainSubstring("spec.migrate: Invalid value: \"object\": a command, args or both"))
+		})
+
+		It("Should keep spec.workloadType immutable", func() {
+			obj.Name = "cel-immutable"
+			Expect(k8sClient.Create(ctx, obj)).To(Succeed())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, obj)).To(Succeed()) })
+
+			obj.Spec.WorkloadType = webappv1.WorkloadStatefulSet
+			err := k8sClient.Update(ctx, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.workloadType: Invalid value: \"string\": workloadType is immutable"))
+		})
+	})
+})
+
+var _ = DescribeTable("imageRegistry",
+	func(image, registry string) {
+		Expect(imageRegistry(image)).To(Equal(registry))
+	},
+	Entry("official image", "nginx:alpine", "docker.io"),
+	Entry("user image on Docker Hub", "bitnami/redis:7", "docker.io"),
+	Entry("explicit docker.io", "docker.io/library/nginx", "docker.io"),
+	Entry("registry host", "ghcr.io/org/app:1.0", "ghcr.io"),
+	Entry("registry with port", "registry.local:5000/app", "registry.local:5000"),
+	Entry("localhost", "localhost/app", "localhost"),
+	Entry("uppercase host", "GHCR.io/org/app", "ghcr.io"),
+)
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+var errLockLost = errors.New("lock lost: another run took over the lease")
+
+// Lock is a run lock on a Lease: held for the length of one run, renewed
+// while the run lasts, and released at the end. Unlike leader election there
+// is no campaigning: a run that finds the lock held just doesn't run.
+type Lock struct {
+	Client    kubernetes.Interface
+	Namespace string
+	Name      string
+	Holder    string
+	TTL       time.Duration
+}
+
+// Acquire takes the lease if it is free, expired, or already ours (a retry of
+// this very Pod). Otherwise it returns the current holder.
+func (l *Lock) Acquire(ctx context.Context) (holder string, acquired bool, err error) {
+	leases := l.Client.CoordinationV1().Leases(l.Namespace)
+	for {
+		now := metav1.NewMicroTime(time.Now())
+		ttl := int32(l.TTL.Seconds())
+		lease, err := leases.Get(ctx, l.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			lease = &coordinationv1.Lease{
+				ObjectMeta: metav1.ObjectMeta{Name: l.Name, Namespace: l.Namespace},
+				Spec: coordinationv1.LeaseSpec{
+					HolderIdentity:       &l.Holder,
+					LeaseDurationSeconds: &ttl,
+					AcquireTime:          &now,
+					RenewTime:            &now,
+				},
+			}
+			_, err = leases.Create(ctx, lease, metav1.CreateOptions{})
+			if apierrors.IsAlreadyExists(err) {
+				continue // another run created it first; look again
+			}
+			return l.Holder, err == nil, err
+		}
+		if err != nil {
+			return "", false, err
+		}
+
+		if h := holderOf(lease); h != "" && h != l.Holder && !expired(lease, now.Time) {
+			return h, false, nil
+		}
+		transitions := int32(0)
+		if lease.Spec.LeaseTransitions != nil {
+			transitions = *lease.Spec.LeaseTransitions
+		}
+		if holderOf(lease) != l.Holder {
+			transitions++
+		}
+		lease.Spec.HolderIdentity = &l.Holder
+		lease.Spec.LeaseDurationSeconds = &ttl
+		lease.Spec.AcquireTime = &now
+		lease.Spec.RenewTime = &now
+		lease.Spec.LeaseTransitions = &transitions
+		// The update carries the resourceVersion we read: if another run
+		// took the lease in between, it fails with a conflict and we look
+		// again instead of both believing we hold it.
+		_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+		if apierrors.IsConflict(err) {
+			continue
+		}
+		return l.Holder, err == nil, err
+	}
+}
+
+// Renew pushes the expiry out. It fails with errLockLost if the lease
+// changed hands, which happens only if renewals stopped for a whole TTL.
+func (l *Lock) Renew(ctx context.Context) error {
+	leases := l.Client.CoordinationV1().Leases(l.Namespace)
+	lease, err := leases.Get(ctx, l.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if holderOf(lease) != l.Holder {
+		return errLockLost
+	}
+	now := metav1.NewMicroTime(time.Now())
+	lease.Spec.RenewTime = &now
+	_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+	return err
+}
+
+// KeepAlive renews every TTL/3 until ctx is done. If the lock is lost it
+// calls lost, once.
+func (l *Lock) KeepAlive(ctx context.Context, lost func(error)) {
+	ticker := time.NewTicker(l.TTL / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := l.Renew(ctx); errors.Is(err, errLockLost) {
+				lost(err)
+				return
+			}
+		}
+	}
+}
+
+// Release frees the lease for the next run. A failed release is harmless:
+// the lease expires after TTL.
+func (l *Lock) Release(ctx context.Context) error {
+	leases := l.Client.CoordinationV1().Leases(l.Namespace)
+	lease, err := leases.Get(ctx, l.Name, metav1.GetOptions{})
+	if err != nil || holderOf(lease) != l.Holder {
+		return err
+	}
+	empty := ""
+	lease.Spec.HolderIdentity = &empty
+	_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+	return err
+}
+
+func holderOf(lease *coordinationv1.Lease) string {
+	if lease.Spec.HolderIdentity == nil {
+		return ""
+	}
+	return *lease.Spec.HolderIdentity
+}
+
+// expired compares the holder's last renewal, on its clock, with ours. A
+// TTL of a minute makes a few seconds of clock skew irrelevant.
+func expired(lease *coordinationv1.Lease, now time.Time) bool {
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return true
+	}
+	return now.After(lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second))
+}
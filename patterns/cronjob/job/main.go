@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	_ "time/tzdata" // TIME_ZONE works in images without /usr/share/zoneinfo
+
+	"github.com/robfig/cron/v3"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func getEnvInt(key string, fallback int) int {
+	if value, ok := os.LookupEnv(key); ok {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+		fmt.Printf("Invalid %s=%q, using default %d\n", key, value, fallback)
+	}
+	return fallback
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if value, ok := os.LookupEnv(key); ok {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+		fmt.Printf("Invalid %s=%q, using default %s\n", key, value, fallback)
+	}
+	return fallback
+}
+
+// Exit codes, matched by the Job's podFailurePolicy (manifests/cronjob.yaml).
+const (
+	exitOK        = 0   // done, or nothing to do (lock held elsewhere, slot already done)
+	exitRetryable = 1   // transient failure: the Job retries, up to backoffLimit
+	exitPermanent = 2   // bad config or input: fail the Job at once, retries can't help
+	exitMissed    = 3   // MISSED_POLICY=fail and runs were missed: fail loudly
+	exitSIGTERM   = 143 // activeDeadlineSeconds, or the Pod was deleted
+)
+
+type config struct {
+	schedule     cron.Schedule
+	location     *time.Location
+	missedPolicy string // catch-up, skip or fail
+	maxCatchUp   int
+	workDuration time.Duration
+	failMode     string // "", transient or permanent: for backoffLimit demos
+	failAttempts int
+}
+
+// result is how a run ends: an exit code, and the one-line reason the
+// kubelet keeps as the container's termination message.
+type result struct {
+	code int
+	msg  string
+}
+
+func (r result) String() string { return r.msg }
+
+func loadConfig() (config, error) {
+	cfg := config{
+		missedPolicy: getEnv("MISSED_POLICY", "catch-up"),
+		maxCatchUp:   getEnvInt("MAX_CATCH_UP", 5),
+		workDuration: getEnvDuration("WORK_DURATION", 10*time.Second),
+		failMode:     getEnv("FAIL_MODE", ""),
+		failAttempts: getEnvInt("FAIL_ATTEMPTS", 2),
+	}
+	loc, err := time.LoadLocation(getEnv("TIME_ZONE", "UTC"))
+	if err != nil {
+		return cfg, fmt.Errorf("TIME_ZONE: %w", err)
+	}
+	cfg.location = loc
+	// SCHEDULE must be the CronJob's own .spec.schedule: it is how the job
+	// knows which fire time it belongs to, and which ones were skipped.
+	cfg.schedule, err = cron.ParseStandard(getEnv("SCHEDULE", ""))
+	if err != nil {
+		return cfg, fmt.Errorf("SCHEDULE: %w", err)
+	}
+	switch cfg.missedPolicy {
+	case "catch-up", "skip", "fail":
+	default:
+		return cfg, fmt.Errorf("MISSED_POLICY must be catch-up, skip or fail, got %q", cfg.missedPolicy)
+	}
+	switch cfg.failMode {
+	case "", "transient", "permanent":
+	default:
+		return cfg, fmt.Errorf("FAIL_MODE must be empty, transient or permanent, got %q", cfg.failMode)
+	}
+	return cfg, nil
+}
+
+// inClusterClient returns a client authenticated as the Pod's
+// ServiceAccount; see manifests/rbac.yaml for what it may do.
+func inClusterClient() (kubernetes.Interface, error) {
+	cfg, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(cfg)
+}
+
+func main() {
+	r := start()
+	fmt.Println(r.msg)
+	terminationMessage(r.msg)
+	os.Exit(r.code)
+}
+
+func start() result {
+	cfg, err := loadConfig()
+	if err != nil {
+		return result{exitPermanent, "config error: " + err.Error()}
+	}
+	client, err := inClusterClient()
+	if err != nil {
+		return result{exitPermanent, "no API access: " + err.Error()}
+	}
+	namespace := getEnv("POD_NAMESPACE", "default")
+	holder := getEnv("POD_NAME", "")
+	if holder == "" {
+		holder, _ = os.Hostname()
+	}
+	lock := &Lock{
+		Client:    client,
+		Namespace: namespace,
+		Name:      getEnv("LOCK_NAME", "cronjob-demo"),
+		Holder:    holder,
+		TTL:       getEnvDuration("LOCK_TTL", time.Minute),
+	}
+	store := &Store{Client: client, Namespace: namespace, Name: getEnv("STATE_CONFIGMAP", "cronjob-demo-state")}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+	return run(ctx, cfg, lock, store)
+}
+
+func run(ctx context.Context, cfg config, lock *Lock, store *Store) result {
+	now := time.Now().In(cfg.location)
+	slot, ok := CurrentSlot(cfg.schedule, now)
+	if !ok {
+		return result{exitPermanent, "SCHEDULE has not fired in the last year"}
+	}
+
+	// 1. Overlap protection. concurrencyPolicy: Forbid only covers Jobs of
+	// this one CronJob; the lock also covers manual runs, a second copy of
+	// the CronJob, and a retry starting while a lost Pod is still running.
+	holder, acquired, err := lock.Acquire(ctx)
+	if err != nil {
+		return result{exitRetryable, "acquiring the lock: " + err.Error()}
+	}
+	if !acquired {
+		return result{exitOK, fmt.Sprintf("skipped: %s holds lease %s, a run is already in progress", holder, lock.Name)}
+	}
+	defer func() {
+		releaseCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := lock.Release(releaseCtx); err != nil {
+			fmt.Printf("Error releasing the lock (it expires in %s anyway): %s\n", lock.TTL, err)
+		}
+	}()
+	runCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	go lock.KeepAlive(runCtx, func(err error) { cancel(err) })
+
+	// 2. Which slots are due? Everything after the last successful one, up
+	// to this run's own.
+	var due []time.Time
+	var missed int
+	var missedList string
+	st, err := store.Update(runCtx, func(st *State) {
+		if !st.LastSuccessSlot.IsZero() && !st.LastSuccessSlot.Before(slot) {
+			return // already done: a manual re-run, or a retry after success
+		}
+		base := st.LastSuccessSlot
+		if base.IsZero() {
+			base = slot.Add(-time.Nanosecond) // first run ever: nothing can have been missed
+		}
+		var total int
+		due, total = Slots(cfg.schedule, base, slot, cfg.maxCatchUp+1)
+		missed = total - 1
+		if missed > 0 {
+			missedList = formatSlots(due[:len(due)-1], total-len(due))
+		}
+		if st.AttemptSlot.Equal(slot) {
+			st.Attempts++
+			return
+		}
+		// The first attempt at this slot records what was missed before it.
+		st.AttemptSlot, st.Attempts = slot, 1
+		if missed > 0 {
+			st.MissedTotal += missed
+			st.LastMissed = missedList
+		}
+	})
+	if err != nil {
+		return result{exitRetryable, "reading state: " + err.Error()}
+	}
+	if len(due) == 0 {
+		return result{exitOK, fmt.Sprintf("skipped: slot %s already succeeded at %s", slot.Format(time.RFC3339), st.LastSuccessAt.Format(time.RFC3339))}
+	}
+
+	// 3. Missed runs: the CronJob controller skipped them (cluster down,
+	// startingDeadlineSeconds passed, concurrencyPolicy: Forbid) or they
+	// all failed. Nothing else tells you.
+	if missed > 0 {
+		fmt.Printf("Detected %d missed run(s) since %s: %s\n", missed, st.LastSuccessSlot.Format(time.RFC3339), missedList)
+		switch cfg.missedPolicy {
+		case "fail":
+			return result{exitMissed, fmt.Sprintf("%d missed run(s) since %s; reset lastSuccessfulSlot in ConfigMap %s to acknowledge",
+				missed, st.LastSuccessSlot.Format(time.RFC3339), store.Name)}
+		case "skip":
+			due = due[len(due)-1:]
+		case "catch-up":
+			if missed >= len(due) {
+				fmt.Printf("Catching up on the newest %d; %d older run(s) are dropped\n", len(due)-1, missed-len(due)+1)
+			}
+		}
+	}
+
+	// 4. The work, oldest slot first. Progress is saved after each slot, so
+	// a retry resumes where this attempt stopped.
+	for _, s := range due {
+		start := time.Now()
+		fmt.Printf("Running slot %s (attempt %d)\n", s.Format(time.RFC3339), st.Attempts)
+		if err := work(runCtx, cfg, st.Attempts); err != nil {
+			switch {
+			case errors.Is(context.Cause(runCtx), errLockLost):
+				return result{exitRetryable, "aborted: " + errLockLost.Error()}
+			case ctx.Err() != nil:
+				return result{exitSIGTERM, fmt.Sprintf("terminated during slot %s; a retry or the next run picks it up", s.Format(time.RFC3339))}
+			case errors.Is(err, errPermanent):
+				return result{exitPermanent, err.Error()}
+			}
+			return result{exitRetryable, fmt.Sprintf("slot %s, attempt %d: %s", s.Format(time.RFC3339), st.Attempts, err)}
+		}
+		if _, err := store.Update(runCtx, func(st *State) {
+			st.LastSuccessSlot, st.LastSuccessAt, st.LastDuration = s, time.Now(), time.Since(start)
+		}); err != nil {
+			return result{exitRetryable, "recording success: " + err.Error()}
+		}
+	}
+	return result{exitOK, fmt.Sprintf("done: %d slot(s) up to %s", len(due), slot.Format(time.RFC3339))}
+}
+
+var errPermanent = errors.New("permanent failure")
+
+// work stands in for the real job. FAIL_MODE makes it fail on purpose:
+// transient fails the first FAIL_ATTEMPTS attempts at a slot (watch the Job
+// retry under backoffLimit), permanent fails in a way retrying can't fix.
+func work(ctx context.Context, cfg config, attempt int) error {
+	switch {
+	case cfg.failMode == "permanent":
+		return fmt.Errorf("%w: input rejected (FAIL_MODE=permanent)", errPermanent)
+	case cfg.failMode == "transient" && attempt <= cfg.failAttempts:
+		return fmt.Errorf("simulated transient failure (FAIL_ATTEMPTS=%d)", cfg.failAttempts)
+	}
+	select {
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	case <-time.After(cfg.workDuration):
+		return nil
+	}
+}
+
+// formatSlots lists missed slots for the state ConfigMap, noting how many
+// older ones didn't fit.
+func formatSlots(slots []time.Time, older int) string {
+	var parts []string
+	for _, s := range slots {
+		parts = append(parts, s.Format(time.RFC3339))
+	}
+	if older > 0 {
+		parts = append([]string{fmt.Sprintf("(+%d older)", older)}, parts...)
+	}
+	return strings.Join(parts, ",")
+}
+
+// terminationMessage writes the outcome where the kubelet picks it up for
+// the container's status, so `kubectl get pod -o yaml` (or a failed Job's
+// Pods) show why without digging through logs.
+func terminationMessage(msg string) {
+	path := getEnv("TERMINATION_MESSAGE_PATH", "/dev/termination-log")
+	_ = os.WriteFile(path, []byte(msg), 0o644)
+}
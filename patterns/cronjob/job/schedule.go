@@ -0,0 +1,38 @@
+package main
+
+import (
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Slots lists the schedule's fire times in (after, until], oldest first,
+// and how many there were in total. Only the newest limit are returned, so a
+// job that hasn't succeeded for months doesn't hand back every minute since.
+func Slots(s cron.Schedule, after, until time.Time, limit int) (slots []time.Time, total int) {
+	for t := s.Next(after); !t.After(until); t = s.Next(t) {
+		total++
+		if len(slots) == limit {
+			slots = append(slots[1:], t)
+			continue
+		}
+		slots = append(slots, t)
+	}
+	return slots, total
+}
+
+// CurrentSlot is the fire time this run belongs to: the latest one at or
+// before now. The CronJob controller creates the Job a little after that
+// time (up to startingDeadlineSeconds later), and retries come later still.
+//
+// cron can only step forwards, so it searches ever larger windows back from
+// now: a frequent schedule is found in the first, small window, and a
+// monthly one has only a few fire times to step through in the large ones.
+func CurrentSlot(s cron.Schedule, now time.Time) (time.Time, bool) {
+	for _, window := range []time.Duration{time.Hour, 24 * time.Hour, 32 * 24 * time.Hour, 366 * 24 * time.Hour} {
+		if slots, _ := Slots(s, now.Add(-window), now, 1); len(slots) == 1 {
+			return slots[0], true
+		}
+	}
+	return time.Time{}, false
+}
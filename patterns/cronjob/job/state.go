@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+// State is what the job remembers between runs. It lives in a ConfigMap, so
+// `kubectl get cm -o yaml` answers "when did this last work?".
+type State struct {
+	LastSuccessSlot time.Time     // the newest slot that completed
+	LastSuccessAt   time.Time     // when it completed
+	LastDuration    time.Duration // how long that run took
+	AttemptSlot     time.Time     // the slot the attempts below belong to
+	Attempts        int           // attempts at AttemptSlot so far, including this one
+	MissedTotal     int           // slots that never ran, over the job's lifetime
+	LastMissed      string        // the most recent missed slots, for humans
+}
+
+// Store reads and writes State in a ConfigMap.
+type Store struct {
+	Client    kubernetes.Interface
+	Namespace string
+	Name      string
+}
+
+// Update applies fn to the current state and writes it back. If another
+// writer got in between, the update conflicts and fn is applied again to the
+// fresh state.
+func (s *Store) Update(ctx context.Context, fn func(*State)) (State, error) {
+	cms := s.Client.CoreV1().ConfigMaps(s.Namespace)
+	var st State
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cm, err := cms.Get(ctx, s.Name, metav1.GetOptions{})
+		exists := err == nil
+		if apierrors.IsNotFound(err) {
+			cm = &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: s.Name, Namespace: s.Namespace}}
+		} else if err != nil {
+			return err
+		}
+		st = decode(cm.Data)
+		fn(&st)
+		cm.Data = encode(st)
+		if !exists {
+			_, err = cms.Create(ctx, cm, metav1.CreateOptions{})
+			if apierrors.IsAlreadyExists(err) {
+				return apierrors.NewConflict(corev1.Resource("configmaps"), s.Name, err)
+			}
+			return err
+		}
+		_, err = cms.Update(ctx, cm, metav1.UpdateOptions{})
+		return err
+	})
+	return st, err
+}
+
+func decode(data map[string]string) State {
+	parseTime := func(k string) time.Time {
+		t, _ := time.Parse(time.RFC3339, data[k])
+		return t
+	}
+	var st State
+	st.LastSuccessSlot = parseTime("lastSuccessfulSlot")
+	st.LastSuccessAt = parseTime("lastSuccessAt")
+	st.LastDuration, _ = time.ParseDuration(data["lastDuration"])
+	st.AttemptSlot = parseTime("attemptSlot")
+	st.Attempts, _ = strconv.Atoi(data["attempts"])
+	st.MissedTotal, _ = strconv.Atoi(data["missedTotal"])
+	st.LastMissed = data["lastMissed"]
+	return st
+}
+
+func encode(st State) map[string]string {
+	data := map[string]string{
+		"attempts":    strconv.Itoa(st.Attempts),
+		"missedTotal": strconv.Itoa(st.MissedTotal),
+	}
+	setTime := func(k string, t time.Time) {
+		if !t.IsZero() {
+			data[k] = t.UTC().Format(time.RFC3339)
+		}
+	}
+	setTime("lastSuccessfulSlot", st.LastSuccessSlot)
+	setTime("lastSuccessAt", st.LastSuccessAt)
+	setTime("attemptSlot", st.AttemptSlot)
+	if st.LastDuration > 0 {
+		data["lastDuration"] = st.LastDuration.Round(time.Millisecond).String()
+	}
+	if st.LastMissed != "" {
+		data["lastMissed"] = st.LastMissed
+	}
+	return data
+}
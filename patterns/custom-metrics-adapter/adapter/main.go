@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/template"
+	"time"
+
+	"k8s.io/component-base/logs"
+	"k8s.io/component-base/metrics/legacyregistry"
+	"sigs.k8s.io/custom-metrics-apiserver/pkg/apiserver/metrics"
+	basecmd "sigs.k8s.io/custom-metrics-apiserver/pkg/cmd"
+)
+
+// defaultQuery is the per-Pod request rate the Envoy sidecars report to
+// Prometheus, counted where the requests arrive (reporter="destination").
+// The namespace and pod labels come from the scrape config of Istio's
+// Prometheus addon.
+const defaultQuery = `sum by (pod) (rate(istio_requests_total{reporter="destination", namespace="{{.Namespace}}", pod=~"{{.Pods}}"}[{{.Window}}]))`
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if value, ok := os.LookupEnv(key); ok {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+		fmt.Printf("Invalid %s=%q, using default %s\n", key, value, fallback)
+	}
+	return fallback
+}
+
+// Adapter is an aggregated API server for custom.metrics.k8s.io. AdapterBase
+// brings the flags (--secure-port, --cert-dir, ...), the delegated
+// authentication and authorization, and the clients; the adapter only adds
+// its provider.
+type Adapter struct {
+	basecmd.AdapterBase
+}
+
+func main() {
+	logs.InitLogs()
+	defer logs.FlushLogs()
+
+	cmd := &Adapter{}
+	cmd.Name = "custom-metrics-adapter"
+	if err := cmd.Flags().Parse(os.Args[1:]); err != nil {
+		fmt.Printf("Invalid flags: %v\n", err)
+		os.Exit(2)
+	}
+
+	promURL := getEnv("PROMETHEUS_URL", "http://prometheus.istio-system:9090")
+	metricName := getEnv("METRIC_NAME", "requests_per_second")
+	window := getEnvDuration("RATE_WINDOW", time.Minute)
+	query, err := template.New("query").Option("missingkey=error").Parse(getEnv("METRIC_QUERY", defaultQuery))
+	if err != nil {
+		fmt.Printf("Invalid METRIC_QUERY: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Clients for the cluster the adapter serves: the Pods to report on
+	// come from there. See manifests/rbac.yaml.
+	client, err := cmd.DynamicClient()
+	if err != nil {
+		fmt.Printf("Error building dynamic client: %v\n", err)
+		os.Exit(1)
+	}
+	mapper, err := cmd.RESTMapper()
+	if err != nil {
+		fmt.Printf("Error building REST mapper: %v\n", err)
+		os.Exit(1)
+	}
+	cmd.WithCustomMetrics(&promProvider{
+		client: client,
+		mapper: mapper,
+		prom:   newPromClient(promURL, 5*time.Second),
+		metric: metricName,
+		query:  query,
+		window: window,
+	})
+
+	// Request counts and latencies of the adapter's own API, on its
+	// /metrics, next to the generic API server's.
+	if err := metrics.RegisterMetrics(legacyregistry.Register); err != nil {
+		fmt.Printf("Error registering metrics: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	fmt.Printf("Serving pods/%s from %s (rate window %s)\n", metricName, promURL, window)
+	if err := cmd.Run(ctx); err != nil {
+		fmt.Printf("Error running adapter: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("Shutting down...")
+}
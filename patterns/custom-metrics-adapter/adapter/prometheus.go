@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// promClient runs instant queries against the Prometheus HTTP API. It is a
+// few lines of net/http: the adapter needs one endpoint, GET /api/v1/query,
+// and one result type, a vector.
+type promClient struct {
+	baseURL string
+	http    *http.Client
+}
+
+func newPromClient(baseURL string, timeout time.Duration) *promClient {
+	return &promClient{baseURL: baseURL, http: &http.Client{Timeout: timeout}}
+}
+
+// queryResponse is the subset of the API's JSON the adapter reads:
+//
+//	{"status":"success","data":{"resultType":"vector","result":[
+//	  {"metric":{"pod":"echo-v1-7d9c"},"value":[1718000000.123,"4.2"]}]}}
+type queryResponse struct {
+	Status    string `json:"status"`
+	ErrorType string `json:"errorType"`
+	Error     string `json:"error"`
+	Data      struct {
+		ResultType string `json:"resultType"`
+		Result     []struct {
+			Metric map[string]string `json:"metric"`
+			Value  [2]any            `json:"value"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+// queryByLabel runs an instant query that returns a vector, and returns
+// each sample's value keyed by the given label, e.g. "pod".
+func (c *promClient) queryByLabel(ctx context.Context, query, label string) (map[string]float64, error) {
+	u := c.baseURL + "/api/v1/query?" + url.Values{"query": {query}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("querying prometheus: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body queryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("decoding prometheus response (HTTP %d): %w", resp.StatusCode, err)
+	}
+	if body.Status != "success" {
+		return nil, fmt.Errorf("prometheus: %s: %s", body.ErrorType, body.Error)
+	}
+	if body.Data.ResultType != "vector" {
+		return nil, fmt.Errorf("prometheus returned a %s, the query must return an instant vector", body.Data.ResultType)
+	}
+
+	values := make(map[string]float64, len(body.Data.Result))
+	for _, sample := range body.Data.Result {
+		key, ok := sample.Metric[label]
+		if !ok {
+			return nil, fmt.Errorf("prometheus sample %v has no %q label: the query must aggregate by it", sample.Metric, label)
+		}
+		// Values come as strings, to carry NaN and +Inf.
+		raw, _ := sample.Value[1].(string)
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("prometheus sample %v: %w", sample.Metric, err)
+		}
+		values[key] = v
+	}
+	return values, nil
+}
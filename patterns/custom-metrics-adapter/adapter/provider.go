@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/metrics/pkg/apis/custom_metrics"
+	"sigs.k8s.io/custom-metrics-apiserver/pkg/provider"
+	"sigs.k8s.io/custom-metrics-apiserver/pkg/provider/helpers"
+)
+
+var podsResource = schema.GroupResource{Resource: "pods"}
+
+// queryArgs fill in the query template: which Pods to ask Prometheus about.
+type queryArgs struct {
+	Namespace string
+	// Pods is a regular expression matching the Pods' names, for a =~
+	// matcher: "echo-v1-7d9c|echo-v1-x2k4".
+	Pods string
+	// Window is the rate window, as a PromQL duration: "60s".
+	Window string
+}
+
+// promProvider serves one custom metric, per Pod, from a PromQL query. It
+// implements provider.CustomMetricsProvider; the library does the rest of
+// the API server (discovery, authn, authz, serving the URLs).
+//
+// The API it answers, as the HPA controller calls it:
+//
+//	GET /apis/custom.metrics.k8s.io/v1beta2/namespaces/default/pods/*/requests_per_second?labelSelector=app=echo
+type promProvider struct {
+	client dynamic.Interface
+	mapper meta.RESTMapper
+	prom   *promClient
+
+	metric string
+	query  *template.Template
+	window time.Duration
+}
+
+// ListAllMetrics is what discovery advertises: "pods/requests_per_second".
+// The HPA doesn't read it, but kubectl get --raw does, and so do people.
+func (p *promProvider) ListAllMetrics() []provider.CustomMetricInfo {
+	return []provider.CustomMetricInfo{{GroupResource: podsResource, Namespaced: true, Metric: p.metric}}
+}
+
+// GetMetricBySelector answers for the Pods matching a label selector: the
+// HPA's question for a "type: Pods" metric.
+func (p *promProvider) GetMetricBySelector(ctx context.Context, namespace string, selector labels.Selector, info provider.CustomMetricInfo, _ labels.Selector) (*custom_metrics.MetricValueList, error) {
+	if err := p.check(info); err != nil {
+		return nil, err
+	}
+	// The Pods come from the API server, not from Prometheus: a Pod that
+	// was deleted a minute ago still has samples in the rate window.
+	names, err := helpers.ListObjectNames(p.mapper, p.client, namespace, selector, info)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return &custom_metrics.MetricValueList{}, nil
+	}
+	values, err := p.values(ctx, namespace, names)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &custom_metrics.MetricValueList{}
+	for _, name := range names {
+		// A Pod without a sample has served no request in the window, or
+		// isn't scraped yet. It is left out, not reported as 0: the HPA
+		// then fills in a value that damps whichever way it would scale,
+		// instead of scaling down on a guess.
+		v, ok := values[name]
+		if !ok {
+			continue
+		}
+		value, err := p.metricValue(types.NamespacedName{Namespace: namespace, Name: name}, info, v)
+		if err != nil {
+			return nil, err
+		}
+		list.Items = append(list.Items, *value)
+	}
+	return list, nil
+}
+
+// GetMetricByName answers for one Pod, as in
+// /namespaces/default/pods/echo-v1-7d9c/requests_per_second.
+func (p *promProvider) GetMetricByName(ctx context.Context, name types.NamespacedName, info provider.CustomMetricInfo, _ labels.Selector) (*custom_metrics.MetricValue, error) {
+	if err := p.check(info); err != nil {
+		return nil, err
+	}
+	values, err := p.values(ctx, name.Namespace, []string{name.Name})
+	if err != nil {
+		return nil, err
+	}
+	v, ok := values[name.Name]
+	if !ok {
+		return nil, provider.NewMetricNotFoundForError(info.GroupResource, info.Metric, name.Name)
+	}
+	return p.metricValue(name, info, v)
+}
+
+// check rejects what the adapter doesn't serve with a 404, which the HPA
+// reports as "unable to get metric".
+func (p *promProvider) check(info provider.CustomMetricInfo) error {
+	if info.Metric != p.metric || info.GroupResource != podsResource || !info.Namespaced {
+		return provider.NewMetricNotFoundError(info.GroupResource, info.Metric)
+	}
+	return nil
+}
+
+// values runs the query for the given Pods, and returns each one's value.
+func (p *promProvider) values(ctx context.Context, namespace string, pods []string) (map[string]float64, error) {
+	quoted := make([]string, len(pods))
+	for i, pod := range pods {
+		quoted[i] = regexp.QuoteMeta(pod)
+	}
+	var query strings.Builder
+	if err := p.query.Execute(&query, queryArgs{
+		Namespace: namespace,
+		Pods:      strings.Join(quoted, "|"),
+		Window:    fmt.Sprintf("%ds", int64(p.window.Seconds())),
+	}); err != nil {
+		return nil, fmt.Errorf("rendering query: %w", err)
+	}
+	start := time.Now()
+	values, err := p.prom.queryByLabel(ctx, query.String(), "pod")
+	if err != nil {
+		fmt.Printf("Query failed after %s: %v\n  %s\n", time.Since(start).Round(time.Millisecond), err, query.String())
+		// A 500 makes the HPA keep its current scale, and say why in its
+		// events.
+		return nil, fmt.Errorf("fetching %s: %w", p.metric, err)
+	}
+	for pod, v := range values {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			delete(values, pod)
+		}
+	}
+	return values, nil
+}
+
+func (p *promProvider) metricValue(name types.NamespacedName, info provider.CustomMetricInfo, v float64) (*custom_metrics.MetricValue, error) {
+	ref, err := helpers.ReferenceFor(p.mapper, name, info)
+	if err != nil {
+		return nil, err
+	}
+	window := int64(p.window.Seconds())
+	return &custom_metrics.MetricValue{
+		DescribedObject: ref,
+		Metric:          custom_metrics.MetricIdentifier{Name: info.Metric},
+		Timestamp:       metav1.Now(),
+		WindowSeconds:   &window,
+		// Milli-units: 2.5 requests per second is "2500m", which is how
+		// kubectl describe hpa shows it.
+		Value: *resource.NewMilliQuantity(int64(math.Round(v*1000)), resource.DecimalSI),
+	}, nil
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"os"
+
+	"k8s.io/component-base/cli"
+	_ "k8s.io/component-base/logs/json/register"          // --logging-format=json
+	_ "k8s.io/component-base/metrics/prometheus/clientgo" // client-go metrics on /metrics
+	"k8s.io/kubernetes/cmd/kube-scheduler/app"
+)
+
+// kube-scheduler, with one more plugin compiled in. Flags, config,
+// leader election, /metrics and /healthz are the stock scheduler's; the
+// plugin runs only in the profiles that enable it.
+func main() {
+	command := app.NewSchedulerCommand(app.WithPlugin(Name, New))
+	os.Exit(cli.Run(command))
+}
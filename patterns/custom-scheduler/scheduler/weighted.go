@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog/v2"
+	fwk "k8s.io/kube-scheduler/framework"
+	"k8s.io/kubernetes/pkg/scheduler/framework"
+	frameworkruntime "k8s.io/kubernetes/pkg/scheduler/framework/runtime"
+)
+
+const (
+	// Name is the plugin's name in the KubeSchedulerConfiguration, under
+	// profiles[].plugins and pluginConfig.
+	Name = "NodeWeight"
+
+	// weightLabel ranks nodes: "mydomain.com/scheduling-weight: 80". 0 to
+	// 100; higher is preferred.
+	weightLabel = "mydomain.com/scheduling-weight"
+
+	// minWeightAnnotation on a Pod keeps it off nodes weighted below it:
+	// "mydomain.com/min-scheduling-weight: 50".
+	minWeightAnnotation = "mydomain.com/min-scheduling-weight"
+)
+
+// NodeWeightArgs are the plugin's arguments, from its pluginConfig entry.
+type NodeWeightArgs struct {
+	// DefaultWeight is the weight of nodes without the label, or with one
+	// that isn't a number from 0 to 100.
+	DefaultWeight int `json:"defaultWeight"`
+}
+
+// NodeWeight keeps Pods off nodes weighted below their minimum (Filter), and
+// prefers the heaviest of the others (Score).
+type NodeWeight struct {
+	args NodeWeightArgs
+}
+
+var (
+	_ framework.FilterPlugin      = &NodeWeight{}
+	_ framework.ScorePlugin       = &NodeWeight{}
+	_ framework.EnqueueExtensions = &NodeWeight{}
+)
+
+// New is the plugin's factory, registered with app.WithPlugin. The scheduler
+// calls it once per profile that enables the plugin.
+func New(_ context.Context, obj runtime.Object, _ framework.Handle) (framework.Plugin, error) {
+	var args NodeWeightArgs
+	if err := frameworkruntime.DecodeInto(obj, &args); err != nil {
+		return nil, fmt.Errorf("decoding %s args: %w", Name, err)
+	}
+	if args.DefaultWeight < 0 || args.DefaultWeight > 100 {
+		return nil, fmt.Errorf("%s: defaultWeight %d, want 0 to 100", Name, args.DefaultWeight)
+	}
+	return &NodeWeight{args: args}, nil
+}
+
+// Name implements framework.Plugin.
+func (w *NodeWeight) Name() string {
+	return Name
+}
+
+// nodeWeight reads a node's weight label.
+func (w *NodeWeight) nodeWeight(node *corev1.Node) int {
+	value, ok := node.Labels[weightLabel]
+	if !ok {
+		return w.args.DefaultWeight
+	}
+	weight, err := strconv.Atoi(value)
+	if err != nil || weight < 0 || weight > 100 {
+		return w.args.DefaultWeight
+	}
+	return weight
+}
+
+// minWeight reads the Pod's minimum: 0 without the annotation.
+func minWeight(pod *corev1.Pod) (int, error) {
+	value, ok := pod.Annotations[minWeightAnnotation]
+	if !ok {
+		return 0, nil
+	}
+	weight, err := strconv.Atoi(value)
+	if err != nil || weight < 0 || weight > 100 {
+		return 0, fmt.Errorf("invalid %s %q: want 0 to 100", minWeightAnnotation, value)
+	}
+	return weight, nil
+}
+
+// Filter runs once per node, in parallel, for the nodes the in-tree filters
+// left. A node's label doesn't change by evicting Pods, so a node below the
+// minimum is unresolvable: the scheduler won't preempt anything for it.
+func (w *NodeWeight) Filter(_ context.Context, _ fwk.CycleState, pod *corev1.Pod, nodeInfo fwk.NodeInfo) *fwk.Status {
+	minimum, err := minWeight(pod)
+	if err != nil {
+		// Every node fails, with the reason in the Pod's events: a typo
+		// shouldn't schedule it anywhere.
+		return fwk.NewStatus(fwk.UnschedulableAndUnresolvable, err.Error())
+	}
+	if weight := w.nodeWeight(nodeInfo.Node()); weight < minimum {
+		return fwk.NewStatus(fwk.UnschedulableAndUnresolvable,
+			fmt.Sprintf("weighted %d, below the Pod's minimum %d", weight, minimum))
+	}
+	return nil
+}
+
+// Score gives each node that passed the filters its weight. Weights and
+// scores both run from 0 to framework.MaxNodeScore (100), so there is
+// nothing to normalize.
+func (w *NodeWeight) Score(ctx context.Context, _ fwk.CycleState, pod *corev1.Pod, nodeInfo fwk.NodeInfo) (int64, *fwk.Status) {
+	node := nodeInfo.Node()
+	score := int64(w.nodeWeight(node))
+	klog.FromContext(ctx).V(4).Info("Scored", "pod", klog.KObj(pod), "node", node.Name, "score", score)
+	return score, nil
+}
+
+// ScoreExtensions implements framework.ScorePlugin: no NormalizeScore.
+func (w *NodeWeight) ScoreExtensions() framework.ScoreExtensions {
+	return nil
+}
+
+// EventsToRegister implements framework.EnqueueExtensions: a Pod this plugin
+// rejected is only worth retrying once a node is added or relabeled. Taint
+// updates are registered as the in-tree NodeAffinity does, in case the
+// node's Add event was missed.
+func (w *NodeWeight) EventsToRegister(_ context.Context) ([]fwk.ClusterEventWithHint, error) {
+	return []fwk.ClusterEventWithHint{{
+		Event:          fwk.ClusterEvent{Resource: fwk.Node, ActionType: fwk.Add | fwk.UpdateNodeLabel | fwk.UpdateNodeTaint},
+		QueueingHintFn: w.isSchedulableAfterNodeChange,
+	}}, nil
+}
+
+// isSchedulableAfterNodeChange retries the Pod if the node now weighs
+// enough for it.
+func (w *NodeWeight) isSchedulableAfterNodeChange(logger klog.Logger, pod *corev1.Pod, _, newObj interface{}) (fwk.QueueingHint, error) {
+	node, ok := newObj.(*corev1.Node)
+	if !ok {
+		return fwk.Queue, fmt.Errorf("want a *v1.Node, got %T", newObj)
+	}
+	minimum, err := minWeight(pod)
+	if err != nil {
+		// No node can fix this, only an edit of the Pod
+		return fwk.QueueSkip, nil
+	}
+	if weight := w.nodeWeight(node); weight < minimum {
+		logger.V(5).Info("Node still too light", "pod", klog.KObj(pod), "node", node.Name, "weight", weight)
+		return fwk.QueueSkip, nil
+	}
+	return fwk.Queue, nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MetricsAuth protects the instrumentation port. A DaemonSet's metrics often
+// reveal more than you'd think (pod names, namespaces, disk layout), and with
+// hostNetwork they are reachable from anything that can reach the node.
+//
+// Credentials are read from files so they can come from a mounted Secret, and
+// re-read on every request so rotating the Secret needs no restart.
+type MetricsAuth struct {
+	BearerTokenFile string
+	BasicUser       string
+	BasicPassFile   string
+}
+
+// Enabled reports whether any credential is configured.
+func (a MetricsAuth) Enabled() bool {
+	return a.BearerTokenFile != "" || a.BasicPassFile != ""
+}
+
+// Wrap returns next guarded by the configured credentials. Either a valid
+// bearer token or valid basic auth is enough when both are configured.
+func (a MetricsAuth) Wrap(next http.Handler) http.Handler {
+	if !a.Enabled() {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.checkBearer(r) || a.checkBasic(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if a.BasicPassFile != "" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="metrics"`)
+		}
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	})
+}
+
+func (a MetricsAuth) checkBearer(r *http.Request) bool {
+	if a.BearerTokenFile == "" {
+		return false
+	}
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+	want, err := readSecret(a.BearerTokenFile)
+	if err != nil {
+		fmt.Printf("auth: %v\n", err)
+		return false
+	}
+	return secureEqual(got, want)
+}
+
+func (a MetricsAuth) checkBasic(r *http.Request) bool {
+	if a.BasicPassFile == "" {
+		return false
+	}
+	user, pass, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+	want, err := readSecret(a.BasicPassFile)
+	if err != nil {
+		fmt.Printf("auth: %v\n", err)
+		return false
+	}
+	// Evaluate both so timing doesn't reveal which one was wrong.
+	userOK := secureEqual(user, a.BasicUser)
+	passOK := secureEqual(pass, want)
+	return userOK && passOK
+}
+
+func readSecret(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// secureEqual compares in constant time; an empty expected value never matches.
+func secureEqual(got, want string) bool {
+	return want != "" && subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
+
+// certReloader serves the certificate from certFile/keyFile and reloads it
+// when the files change. cert-manager (or a kubectl apply) rewrites a mounted
+// Secret in place; without this the pod would serve the old cert until
+// restarted.
+type certReloader struct {
+	certFile, keyFile string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if _, err := r.GetCertificate(nil); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	st, err := os.Stat(r.certFile)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cert != nil && st.ModTime().Equal(r.modTime) {
+		return r.cert, nil
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		if r.cert != nil {
+			// Mid-rotation the cert and key may briefly not match; keep serving the old pair.
+			return r.cert, nil
+		}
+		return nil, fmt.Errorf("loading TLS key pair: %w", err)
+	}
+	r.cert, r.modTime = &cert, st.ModTime()
+	return r.cert, nil
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector is one pluggable source of node metrics (node, kubelet, logs, ...).
+//
+// Scrape-time collectors read their source in CollectInto. Background
+// collectors do their work in goroutines started by Start and only report a
+// snapshot in CollectInto. Either way, a non-nil error from CollectInto marks
+// the scrape as failed in collector_scrape_success{collector}; metrics that
+// were sent before the error are still exported.
+type Collector interface {
+	Name() string
+	Describe(ch chan<- *prometheus.Desc)
+	CollectInto(ch chan<- prometheus.Metric) error
+	Start(ctx context.Context) error
+	Stop() error
+}
+
+// collectorFactories holds every compiled-in collector. Each collector file
+// adds itself from init(), so adding a module never touches main.go.
+var collectorFactories = map[string]func() (Collector, error){}
+
+func registerCollector(name string, factory func() (Collector, error)) {
+	if _, dup := collectorFactories[name]; dup {
+		panic("collector registered twice: " + name)
+	}
+	collectorFactories[name] = factory
+}
+
+func availableCollectors() []string {
+	names := make([]string, 0, len(collectorFactories))
+	for name := range collectorFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CollectorSet is the enabled collectors, each wrapped with its own scrape
+// success and duration metrics.
+type CollectorSet struct {
+	collectors []*instrumentedCollector
+}
+
+// NewCollectorSet builds the named collectors. A collector that fails to
+// build is reported and skipped; an unknown name is a configuration error.
+func NewCollectorSet(names []string) (*CollectorSet, error) {
+	s := &CollectorSet{}
+	for _, name := range names {
+		factory, ok := collectorFactories[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown collector %q (available: %v)", name, availableCollectors())
+		}
+		c, err := factory()
+		if err != nil {
+			fmt.Printf("Collector %s disabled: %s\n", name, err)
+			continue
+		}
+		s.collectors = append(s.collectors, newInstrumentedCollector(c))
+	}
+	return s, nil
+}
+
+// Register adds every collector to reg.
+func (s *CollectorSet) Register(reg prometheus.Registerer) error {
+	for _, c := range s.collectors {
+		if err := reg.Register(c); err != nil {
+			return fmt.Errorf("registering collector %s: %w", c.Name(), err)
+		}
+	}
+	return nil
+}
+
+// Start starts every collector. One that fails to start is reported and
+// keeps exporting collector_scrape_success 0, rather than taking down the
+// whole agent.
+func (s *CollectorSet) Start(ctx context.Context) {
+	for _, c := range s.collectors {
+		if err := c.Start(ctx); err != nil {
+			fmt.Printf("Collector %s failed to start: %s\n", c.Name(), err)
+			c.startErr = err
+			continue
+		}
+		fmt.Printf("Collector %s started\n", c.Name())
+	}
+}
+
+// Stop stops every collector and returns all errors.
+func (s *CollectorSet) Stop() error {
+	var errs []error
+	for _, c := range s.collectors {
+		if err := c.Stop(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", c.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Ready returns an error naming every collector that hasn't succeeded within
+// maxAge. Collectors are normally exercised by scrapes; one that hasn't been
+// scraped recently (push-only setups, or a scraper that is down) is collected
+// here, with the metrics discarded, so readiness doesn't depend on a scraper.
+func (s *CollectorSet) Ready(maxAge time.Duration) error {
+	var errs []error
+	for _, c := range s.collectors {
+		if time.Since(time.Unix(0, c.lastAttempt.Load())) > maxAge/2 && !c.budget.disabled(time.Now()) {
+			ch := make(chan prometheus.Metric)
+			go func() {
+				c.Collect(ch)
+				close(ch)
+			}()
+			for range ch {
+			}
+		}
+		if last := c.lastSuccess.Load(); last == 0 || time.Since(time.Unix(0, last)) > maxAge {
+			errs = append(errs, fmt.Errorf("collector %s has not succeeded in the last %s", c.Name(), maxAge))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// instrumentedCollector adapts a Collector to prometheus.Collector and adds
+// collector_scrape_success and collector_scrape_duration_seconds. The
+// collector label is a const label, so each wrapped collector has its own
+// descriptors and can be registered independently.
+type instrumentedCollector struct {
+	Collector
+	startErr error
+	budget   *errorBudget
+
+	lastAttempt atomic.Int64 // unix nanos of the last CollectInto call
+	lastSuccess atomic.Int64 // unix nanos of the last error-free one
+
+	success  *prometheus.Desc
+	duration *prometheus.Desc
+	lastRun  *prometheus.Desc
+	up       *prometheus.Desc
+	failures *prometheus.Desc
+}
+
+func newInstrumentedCollector(c Collector) *instrumentedCollector {
+	labels := prometheus.Labels{"collector": c.Name()}
+	return &instrumentedCollector{
+		Collector: c,
+		budget:    newErrorBudget(),
+		success: prometheus.NewDesc("collector_scrape_success",
+			"Whether the collector succeeded on the last scrape.", nil, labels),
+		duration: prometheus.NewDesc("collector_scrape_duration_seconds",
+			"How long the collector took on the last scrape.", nil, labels),
+		lastRun: prometheus.NewDesc("collector_last_run_timestamp_seconds",
+			"When the collector last did its work: the last background run, or the last scrape for scrape-time collectors.", nil, labels),
+		up: prometheus.NewDesc("collector_up",
+			"Whether the collector is enabled (1) or disabled after too many consecutive failures (0).", nil, labels),
+		failures: prometheus.NewDesc("collector_consecutive_failures",
+			"Failed scrapes since the collector last succeeded.", nil, labels),
+	}
+}
+
+// Describe implements prometheus.Collector. A collector whose metric names
+// are only known at runtime (statsd) describes nothing; then the wrapper must
+// describe nothing either, or the registry would reject the undescribed
+// metrics as inconsistent.
+func (c *instrumentedCollector) Describe(ch chan<- *prometheus.Desc) {
+	descs := make(chan *prometheus.Desc)
+	go func() {
+		c.Collector.Describe(descs)
+		close(descs)
+	}()
+	n := 0
+	for d := range descs {
+		ch <- d
+		n++
+	}
+	if n > 0 {
+		ch <- c.success
+		ch <- c.duration
+		ch <- c.lastRun
+		ch <- c.up
+		ch <- c.failures
+	}
+}
+
+// Collect implements prometheus.Collector. A disabled collector isn't asked
+// at all: it reports collector_up 0 and its failure count until its backoff
+// expires, then gets one attempt to prove itself.
+func (c *instrumentedCollector) Collect(ch chan<- prometheus.Metric) {
+	start := time.Now()
+	if c.budget.disabled(start) {
+		ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 0)
+		ch <- prometheus.MustNewConstMetric(c.failures, prometheus.GaugeValue, float64(c.budget.consecutive()))
+		ch <- prometheus.MustNewConstMetric(c.success, prometheus.GaugeValue, 0)
+		return
+	}
+
+	err := c.startErr
+	if err == nil {
+		err = c.CollectInto(ch)
+	}
+	ch <- prometheus.MustNewConstMetric(c.duration, prometheus.GaugeValue, time.Since(start).Seconds())
+
+	ok := 1.0
+	c.lastAttempt.Store(start.UnixNano())
+	if err != nil {
+		fmt.Printf("%s: %v\n", c.Name(), err)
+		ok = 0
+		if backoff := c.budget.fail(start); backoff > 0 {
+			fmt.Printf("Collector %s disabled for %s after %d consecutive failures\n", c.Name(), backoff, c.budget.consecutive())
+		}
+	} else {
+		if c.budget.succeed() {
+			fmt.Printf("Collector %s re-enabled\n", c.Name())
+		}
+		c.lastSuccess.Store(start.UnixNano())
+	}
+	ch <- prometheus.MustNewConstMetric(c.success, prometheus.GaugeValue, ok)
+	ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 1)
+	ch <- prometheus.MustNewConstMetric(c.failures, prometheus.GaugeValue, float64(c.budget.consecutive()))
+
+	// Collectors on a schedule report their last background run; for the
+	// others (scrape-time or event-driven) the work happens now.
+	last := start
+	if s, ok := c.Collector.(interface{ LastRun() time.Time }); ok {
+		last = s.LastRun()
+	}
+	if !last.IsZero() {
+		ch <- prometheus.MustNewConstMetric(c.lastRun, prometheus.GaugeValue, float64(last.UnixNano())/1e9)
+	}
+}
+
+// errorBudget disables a collector that keeps failing. A broken hostPath or
+// an unreachable kubelet then costs one log line per backoff period instead
+// of a timeout and an error on every scrape, and the rest of the exporter is
+// unaffected. After MaxFailures consecutive failures the collector is skipped
+// for Backoff; every failed retry doubles that, up to MaxBackoff. One success
+// resets everything.
+type errorBudget struct {
+	MaxFailures int // 0 = never disable
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+
+	mu            sync.Mutex
+	failures      int
+	disables      int // consecutive disable periods, for the doubling
+	disabledUntil time.Time
+}
+
+// newErrorBudget reads COLLECTOR_MAX_FAILURES, COLLECTOR_BACKOFF and
+// COLLECTOR_MAX_BACKOFF.
+func newErrorBudget() *errorBudget {
+	b := &errorBudget{
+		MaxFailures: getEnvInt("COLLECTOR_MAX_FAILURES", 5),
+		Backoff:     30 * time.Second,
+		MaxBackoff:  10 * time.Minute,
+	}
+	if d, err := time.ParseDuration(getEnv("COLLECTOR_BACKOFF", "30s")); err == nil && d > 0 {
+		b.Backoff = d
+	}
+	if d, err := time.ParseDuration(getEnv("COLLECTOR_MAX_BACKOFF", "10m")); err == nil && d > 0 {
+		b.MaxBackoff = d
+	}
+	return b
+}
+
+func (b *errorBudget) disabled(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return now.Before(b.disabledUntil)
+}
+
+func (b *errorBudget) consecutive() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.failures
+}
+
+// fail records a failure and returns how long the collector is now disabled
+// for, or 0 if it stays enabled.
+func (b *errorBudget) fail(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.MaxFailures <= 0 || b.failures < b.MaxFailures {
+		return 0
+	}
+	backoff := b.Backoff << min(b.disables, 16)
+	if backoff <= 0 || backoff > b.MaxBackoff {
+		backoff = b.MaxBackoff
+	}
+	b.disables++
+	b.disabledUntil = now.Add(backoff)
+	return backoff
+}
+
+// succeed resets the budget and reports whether the collector had been
+// disabled.
+func (b *errorBudget) succeed() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	wasDisabled := b.disables > 0
+	b.failures, b.disables, b.disabledUntil = 0, 0, time.Time{}
+	return wasDisabled
+}
+
+// schedule runs a background collector's work every Interval. The first run
+// happens at a random point within the first interval, and every later wait
+// is stretched or shrunk by up to Jitter*Interval. Without this, a DaemonSet
+// rolled out to 500 nodes at once would hit the API server (or every node's
+// disks) in lockstep forever.
+type schedule struct {
+	Interval time.Duration
+	Jitter   float64 // 0.1 = +/-10%
+
+	lastRun atomic.Int64 // unix nanos
+}
+
+// run calls fn on the schedule until ctx is cancelled.
+func (s *schedule) run(ctx context.Context, fn func(context.Context)) {
+	wait := time.Duration(rand.Int63n(int64(s.Interval)))
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		fn(ctx)
+		s.lastRun.Store(time.Now().UnixNano())
+		wait = time.Duration(float64(s.Interval) * (1 + s.Jitter*(2*rand.Float64()-1)))
+	}
+}
+
+// LastRun is when fn last completed; zero before the first run.
+func (s *schedule) LastRun() time.Time {
+	if n := s.lastRun.Load(); n != 0 {
+		return time.Unix(0, n)
+	}
+	return time.Time{}
+}
+
+// collectorJitter is the jitter applied to every schedule (COLLECTOR_JITTER,
+// a fraction of the interval).
+func collectorJitter() float64 {
+	j, err := strconv.ParseFloat(getEnv("COLLECTOR_JITTER", "0.1"), 64)
+	if err != nil || j < 0 || j > 1 {
+		return 0.1
+	}
+	return j
+}
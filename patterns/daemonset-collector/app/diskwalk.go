@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	registerCollector("diskwalk", func() (Collector, error) {
+		roots := splitList(getEnv("DISK_WALK_ROOTS", "/var/log"))
+		interval, err := time.ParseDuration(getEnv("DISK_WALK_INTERVAL", "5m"))
+		if err != nil || interval <= 0 {
+			interval = 5 * time.Minute
+		}
+		return NewDiskWalker(roots, getEnvInt("DISK_WALK_DEPTH", 2),
+			splitList(getEnv("DISK_WALK_EXCLUDE", "")), interval, getEnvInt("DISK_WALK_RATE", 2000)), nil
+	})
+}
+
+// DiskWalker answers "what is filling up this node's disk?" by periodically
+// walking hostPath directories (du-style) and exporting per-directory sizes.
+//
+// Walking is IO-heavy: /var/lib/containerd can hold millions of files, and a
+// DaemonSet runs on every node at once. So walks are rate-limited, spread out
+// with jitter, stay on one filesystem (like du -x), and results are served
+// from the last completed walk instead of walking at scrape time.
+type DiskWalker struct {
+	Roots    []string
+	MaxDepth int      // directories deeper than this are rolled up into their ancestor
+	Exclude  []string // globs matched against the full path and the base name
+	Rate     int      // max filesystem entries visited per second, per walker
+	schedule
+
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	sizes    map[string]map[string]float64 // root -> dir -> bytes
+	duration map[string]float64
+	errors   map[string]float64
+	failed   map[string]error // roots whose last walk could not start
+
+	sizeDesc     *prometheus.Desc
+	durationDesc *prometheus.Desc
+	errorsDesc   *prometheus.Desc
+}
+
+// NewDiskWalker returns a walker for roots; call Start to start walking.
+func NewDiskWalker(roots []string, maxDepth int, exclude []string, interval time.Duration, rate int) *DiskWalker {
+	return &DiskWalker{
+		Roots:    roots,
+		MaxDepth: maxDepth,
+		Exclude:  exclude,
+		Rate:     rate,
+		schedule: schedule{Interval: interval, Jitter: collectorJitter()},
+		sizes:    map[string]map[string]float64{},
+		duration: map[string]float64{},
+		errors:   map[string]float64{},
+		failed:   map[string]error{},
+
+		sizeDesc: prometheus.NewDesc("node_dir_size_bytes",
+			"Disk usage of a directory including everything below it, as of the last walk.", []string{"root", "path"}, nil),
+		durationDesc: prometheus.NewDesc("node_dir_walk_duration_seconds",
+			"How long the last walk of a root took.", []string{"root"}, nil),
+		errorsDesc: prometheus.NewDesc("node_dir_walk_errors_total",
+			"Entries that could not be read while walking (permissions, races with deletes).", []string{"root"}, nil),
+	}
+}
+
+// Name implements Collector.
+func (w *DiskWalker) Name() string { return "diskwalk" }
+
+// Start implements Collector.
+func (w *DiskWalker) Start(ctx context.Context) error {
+	ctx, w.cancel = context.WithCancel(ctx)
+	go w.Run(ctx)
+	fmt.Printf("Walking %v every %s\n", w.Roots, w.Interval)
+	return nil
+}
+
+// Stop implements Collector. An in-progress walk stops at the next entry.
+func (w *DiskWalker) Stop() error {
+	if w.cancel != nil {
+		w.cancel()
+	}
+	return nil
+}
+
+// Run walks every root once per Interval (with jitter) until ctx is cancelled.
+func (w *DiskWalker) Run(ctx context.Context) {
+	w.run(ctx, func(ctx context.Context) {
+		for _, root := range w.Roots {
+			w.walk(ctx, root)
+		}
+	})
+}
+
+func (w *DiskWalker) walk(ctx context.Context, root string) {
+	start := time.Now()
+	rootDev, err := deviceOf(root)
+	if err != nil {
+		w.mu.Lock()
+		w.failed[root] = err
+		w.errors[root]++
+		w.mu.Unlock()
+		return
+	}
+
+	sizes := map[string]float64{}
+	var errCount float64
+	visited := 0
+	pace := time.Now()
+
+	_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			errCount++
+			if d != nil && d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if path != root && w.excluded(path) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		// Rate limit in small batches: sleeping per entry costs more than the
+		// stat itself.
+		if visited++; w.Rate > 0 && visited%100 == 0 {
+			want := time.Duration(float64(visited) / float64(w.Rate) * float64(time.Second))
+			if ahead := want - time.Since(pace); ahead > 0 {
+				time.Sleep(ahead)
+			}
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			errCount++
+			return nil
+		}
+		st, ok := info.Sys().(*syscall.Stat_t)
+		if !ok {
+			return nil
+		}
+		if d.IsDir() && path != root && uint64(st.Dev) != rootDev {
+			return filepath.SkipDir // another filesystem (e.g. /proc under /host/root)
+		}
+
+		// Blocks, not apparent size: sparse files and small files count what
+		// they actually occupy, matching du.
+		used := float64(st.Blocks) * 512
+		for dir := w.bucket(root, path, d.IsDir()); ; dir = filepath.Dir(dir) {
+			sizes[dir] += used
+			if dir == root {
+				break
+			}
+		}
+		return nil
+	})
+	if ctx.Err() != nil {
+		return // stopped mid-walk; keep the last complete snapshot
+	}
+
+	w.record(root, sizes, time.Since(start).Seconds(), errCount)
+}
+
+// bucket returns the directory a path's usage is attributed to: its own
+// directory, or its ancestor at MaxDepth below root if it is deeper.
+func (w *DiskWalker) bucket(root, path string, isDir bool) string {
+	dir := path
+	if !isDir {
+		dir = filepath.Dir(path)
+	}
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || rel == "." {
+		return root
+	}
+	parts := strings.Split(rel, string(filepath.Separator))
+	if len(parts) > w.MaxDepth {
+		parts = parts[:w.MaxDepth]
+	}
+	return filepath.Join(append([]string{root}, parts...)...)
+}
+
+func (w *DiskWalker) excluded(path string) bool {
+	for _, pattern := range w.Exclude {
+		if ok, _ := filepath.Match(pattern, path); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(pattern, filepath.Base(path)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *DiskWalker) record(root string, sizes map[string]float64, seconds, errCount float64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	// Swap the whole snapshot: directories deleted since the last walk
+	// disappear instead of reporting a stale size forever.
+	w.sizes[root] = sizes
+	w.duration[root] = seconds
+	w.errors[root] += errCount
+	delete(w.failed, root)
+}
+
+func deviceOf(path string) (uint64, error) {
+	st, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	sys, ok := st.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, fmt.Errorf("no device information for %s", path)
+	}
+	return uint64(sys.Dev), nil
+}
+
+// Describe implements Collector.
+func (w *DiskWalker) Describe(ch chan<- *prometheus.Desc) {
+	ch <- w.sizeDesc
+	ch <- w.durationDesc
+	ch <- w.errorsDesc
+}
+
+// CollectInto implements Collector. It never touches the disk; it fails if
+// a root could not be walked at all (e.g. a missing hostPath mount).
+func (w *DiskWalker) CollectInto(ch chan<- prometheus.Metric) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for root, dirs := range w.sizes {
+		for dir, size := range dirs {
+			ch <- prometheus.MustNewConstMetric(w.sizeDesc, prometheus.GaugeValue, size, root, dir)
+		}
+		ch <- prometheus.MustNewConstMetric(w.durationDesc, prometheus.GaugeValue, w.duration[root], root)
+	}
+	for root, n := range w.errors {
+		ch <- prometheus.MustNewConstMetric(w.errorsDesc, prometheus.CounterValue, n, root)
+	}
+	var errs []error
+	for _, err := range w.failed {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+func init() {
+	registerCollector("events", func() (Collector, error) {
+		return NewEventsCounter(os.Getenv("NODE_NAME"), getEnv("EVENTS_SCOPE", "node"))
+	})
+}
+
+// EventsCounter turns Kubernetes Events into counters. Events expire after an
+// hour and are awkward to alert on; kube_node_events_total{reason="BackOff"}
+// is easy to rate() and keeps its history in the TSDB.
+//
+// Each DaemonSet pod only counts events about its own node, so the work is
+// spread across the fleet instead of one pod watching the whole cluster:
+//
+//   - events whose involvedObject is this Node (NodeNotReady, Rebooted, ...)
+//   - events the local kubelet emitted about pods (BackOff, Unhealthy,
+//     Failed, Killing, ...). The API server can filter by source but not by
+//     host, so host is checked here.
+//
+// Scheduler events such as FailedScheduling belong to no node; set
+// EVENTS_SCOPE=cluster on a single replica to count everything instead.
+type EventsCounter struct {
+	Node   string
+	Scope  string // "node" or "cluster"
+	client kubernetes.Interface
+
+	cancel    context.CancelFunc
+	informers []cache.Controller
+
+	events *prometheus.CounterVec
+}
+
+// NewEventsCounter builds an in-cluster client for the events watch.
+func NewEventsCounter(node, scope string) (*EventsCounter, error) {
+	if scope == "node" && node == "" {
+		return nil, fmt.Errorf("NODE_NAME is required to count node-scoped events")
+	}
+	client, err := inClusterClient()
+	if err != nil {
+		return nil, err
+	}
+	return &EventsCounter{
+		Node:   node,
+		Scope:  scope,
+		client: client,
+		events: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "kube_node_events_total",
+			Help: "Kubernetes Events seen since the collector started, by type, reason and involved object kind.",
+		}, []string{"type", "reason", "kind", "namespace"}),
+	}, nil
+}
+
+// Name implements Collector.
+func (c *EventsCounter) Name() string { return "events" }
+
+// Describe implements Collector.
+func (c *EventsCounter) Describe(ch chan<- *prometheus.Desc) {
+	c.events.Describe(ch)
+}
+
+// CollectInto implements Collector. Until every watch has completed its
+// initial list the counts are incomplete, so the scrape counts as failed.
+func (c *EventsCounter) CollectInto(ch chan<- prometheus.Metric) error {
+	c.events.Collect(ch)
+	for _, informer := range c.informers {
+		if !informer.HasSynced() {
+			return fmt.Errorf("events watch not synced yet")
+		}
+	}
+	return nil
+}
+
+// Stop implements Collector.
+func (c *EventsCounter) Stop() error {
+	if c.cancel != nil {
+		c.cancel()
+	}
+	return nil
+}
+
+// Start implements Collector: it starts the watches, which run until Stop.
+func (c *EventsCounter) Start(ctx context.Context) error {
+	ctx, c.cancel = context.WithCancel(ctx)
+	selectors := []fields.Selector{fields.Everything()}
+	if c.Scope == "node" {
+		selectors = []fields.Selector{
+			fields.Set{"involvedObject.kind": "Node", "involvedObject.name": c.Node}.AsSelector(),
+			fields.OneTermEqualSelector("source", "kubelet"),
+		}
+	}
+	for _, sel := range selectors {
+		lw := cache.NewListWatchFromClient(c.client.CoreV1().RESTClient(), "events", corev1.NamespaceAll, sel)
+		_, informer := cache.NewInformerWithOptions(cache.InformerOptions{
+			ListerWatcher: lw,
+			ObjectType:    &corev1.Event{},
+			Handler: cache.ResourceEventHandlerDetailedFuncs{
+				AddFunc: func(obj any, isInInitialList bool) {
+					// The initial list is up to an hour of history; counting it
+					// would make every restart look like an incident.
+					if !isInInitialList {
+						c.count(obj, 0)
+					}
+				},
+				UpdateFunc: func(oldObj, newObj any) {
+					// Repeats are folded into one Event with a rising Count.
+					if old, ok := oldObj.(*corev1.Event); ok {
+						c.count(newObj, occurrences(old))
+					}
+				},
+			},
+		})
+		c.informers = append(c.informers, informer)
+		go informer.Run(ctx.Done())
+	}
+	fmt.Printf("Counting %s-scoped Kubernetes events\n", c.Scope)
+	return nil
+}
+
+// count adds the occurrences of obj beyond the seen ones.
+func (c *EventsCounter) count(obj any, seen int32) {
+	e, ok := obj.(*corev1.Event)
+	if !ok {
+		return
+	}
+	if c.Scope == "node" && e.InvolvedObject.Kind != "Node" && e.Source.Host != c.Node {
+		return // another node's kubelet
+	}
+	if n := occurrences(e) - seen; n > 0 {
+		c.events.WithLabelValues(e.Type, e.Reason, e.InvolvedObject.Kind, e.InvolvedObject.Namespace).Add(float64(n))
+	}
+}
+
+// occurrences is how many times an Event has happened so far. Old-style
+// events bump Count; events.k8s.io-style ones bump Series.Count instead.
+func occurrences(e *corev1.Event) int32 {
+	if e.Series != nil {
+		return e.Series.Count
+	}
+	return max(e.Count, 1)
+}
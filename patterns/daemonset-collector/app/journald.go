@@ -0,0 +1,431 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	registerCollector("journald", func() (Collector, error) {
+		interval, err := time.ParseDuration(getEnv("JOURNAL_POLL_INTERVAL", "5s"))
+		if err != nil || interval <= 0 {
+			interval = 5 * time.Second
+		}
+		return NewJournalReader(splitList(getEnv("JOURNAL_PATHS", "/var/log/journal,/run/log/journal")),
+			getEnv("JOURNAL_KUBELET_UNIT", "kubelet.service"), interval)
+	})
+}
+
+// journalEvents are the node problems worth a counter, in the spirit of
+// node-problem-detector: each matching journal entry counts once.
+var journalEvents = []struct {
+	event string
+	match func(e journalEntry, kubeletUnit string) bool
+}{
+	{"oom_kill", func(e journalEntry, _ string) bool {
+		return e.transport == "kernel" && oomKillRE.MatchString(e.message)
+	}},
+	{"disk_error", func(e journalEntry, _ string) bool {
+		return e.transport == "kernel" && diskErrorRE.MatchString(e.message)
+	}},
+	// systemd logs "Started kubelet.service ..." with UNIT= set on every
+	// start, whether Restart=always kicked in or someone ran systemctl.
+	{"kubelet_restart", func(e journalEntry, kubeletUnit string) bool {
+		return e.unit == kubeletUnit && strings.HasPrefix(e.message, "Started ")
+	}},
+}
+
+var (
+	// The global OOM killer and the memory cgroup one (a container over its
+	// limit) both end with this line, once per killed process.
+	oomKillRE = regexp.MustCompile(`(Out of memory|Memory cgroup out of memory): Killed process`)
+	// Block layer and filesystem errors. One failing request usually logs
+	// more than one of these, so the counter is "error lines", not "errors".
+	diskErrorRE = regexp.MustCompile(`I/O error|EXT4-fs error|XFS \(.*\): (metadata I/O error|Corruption)|critical medium error`)
+)
+
+// journalEntry holds the fields of an entry the matchers look at.
+type journalEntry struct {
+	message   string // MESSAGE
+	transport string // _TRANSPORT: kernel, journal, syslog, stdout, ...
+	unit      string // UNIT: set by systemd on messages about a unit
+}
+
+// JournalReader follows the systemd journal files of the node and counts
+// kernel OOM kills, disk errors and kubelet restarts.
+//
+// It reads the journal file format directly instead of linking libsystemd
+// (cgo) or shelling out to journalctl, neither of which the image has. Only
+// what's needed is implemented: entries are walked in file order and their
+// data objects decoded, uncompressed or zstd (the default since systemd 246).
+// XZ and LZ4 payloads are skipped and counted as read errors.
+//
+// Like the logs module, files present at startup are read from their end,
+// so a pod restart doesn't count the same OOM kill twice.
+type JournalReader struct {
+	Dirs        []string
+	KubeletUnit string
+	schedule
+
+	files  map[[16]byte]*journalFile // by file ID, which survives rotation renames
+	zstd   *zstd.Decoder
+	cancel context.CancelFunc
+
+	events      *prometheus.CounterVec
+	entries     prometheus.Counter
+	readErrors  prometheus.Counter
+	filesOpened prometheus.Gauge
+}
+
+// NewJournalReader creates a reader; call Start to begin following dirs.
+func NewJournalReader(dirs []string, kubeletUnit string, interval time.Duration) (*JournalReader, error) {
+	dec, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	r := &JournalReader{
+		Dirs:        dirs,
+		KubeletUnit: kubeletUnit,
+		schedule:    schedule{Interval: interval, Jitter: collectorJitter()},
+		files:       map[[16]byte]*journalFile{},
+		zstd:        dec,
+
+		events: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "journal_events_total",
+			Help: "Journal entries recognised as node problems, by event (oom_kill, disk_error, kubelet_restart).",
+		}, []string{"event"}),
+		entries: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "journal_entries_read_total",
+			Help: "Journal entries read.",
+		}),
+		readErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "journal_read_errors_total",
+			Help: "Journal objects that could not be read or decoded.",
+		}),
+		filesOpened: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "journal_files_followed",
+			Help: "Journal files currently open.",
+		}),
+	}
+	// Start every event at 0 so increase() works from the first occurrence.
+	for _, ev := range journalEvents {
+		r.events.WithLabelValues(ev.event)
+	}
+	return r, nil
+}
+
+// Name implements Collector.
+func (r *JournalReader) Name() string { return "journald" }
+
+// Describe implements Collector.
+func (r *JournalReader) Describe(ch chan<- *prometheus.Desc) {
+	r.events.Describe(ch)
+	r.entries.Describe(ch)
+	r.readErrors.Describe(ch)
+	r.filesOpened.Describe(ch)
+}
+
+// CollectInto implements Collector.
+func (r *JournalReader) CollectInto(ch chan<- prometheus.Metric) error {
+	r.events.Collect(ch)
+	r.entries.Collect(ch)
+	r.readErrors.Collect(ch)
+	r.filesOpened.Collect(ch)
+	return nil
+}
+
+// Start implements Collector.
+func (r *JournalReader) Start(ctx context.Context) error {
+	ctx, r.cancel = context.WithCancel(ctx)
+	go r.Run(ctx)
+	fmt.Printf("Following the systemd journal in %s\n", strings.Join(r.Dirs, ", "))
+	return nil
+}
+
+// Stop implements Collector.
+func (r *JournalReader) Stop() error {
+	if r.cancel != nil {
+		r.cancel()
+	}
+	return nil
+}
+
+// Run polls until ctx is cancelled.
+func (r *JournalReader) Run(ctx context.Context) {
+	r.poll(true)
+	r.run(ctx, func(context.Context) { r.poll(false) })
+	for _, f := range r.files {
+		f.close()
+	}
+}
+
+func (r *JournalReader) poll(initial bool) {
+	// Persistent journals live in <dir>/<machine-id>/; journald's
+	// "namespaces" and some distros put files directly in <dir>.
+	var paths []string
+	for _, dir := range r.Dirs {
+		for _, pattern := range []string{"*.journal", "*/*.journal"} {
+			m, _ := filepath.Glob(filepath.Join(dir, pattern))
+			paths = append(paths, m...)
+		}
+	}
+
+	seen := map[[16]byte]bool{}
+	for _, p := range paths {
+		f, err := os.Open(p)
+		if err != nil {
+			continue
+		}
+		h, err := readJournalHeader(f)
+		if err != nil {
+			f.Close()
+			fmt.Printf("journald: %s: %v\n", p, err)
+			continue
+		}
+		seen[h.fileID] = true
+		jf, ok := r.files[h.fileID]
+		if ok {
+			// Already following it, possibly under its old name.
+			f.Close()
+		} else {
+			jf = &journalFile{path: p, file: f}
+			if err := jf.init(h, initial); err != nil {
+				f.Close()
+				fmt.Printf("journald: %s: %v\n", p, err)
+				continue
+			}
+			r.files[h.fileID] = jf
+		}
+		if err := r.readNew(jf); err != nil {
+			r.readErrors.Inc()
+			fmt.Printf("journald: %s: %v\n", jf.path, err)
+		}
+	}
+	// Archived files are deleted by journald's vacuuming.
+	for id, jf := range r.files {
+		if !seen[id] {
+			jf.close()
+			delete(r.files, id)
+		}
+	}
+	open := 0
+	for _, jf := range r.files {
+		if jf.file != nil {
+			open++
+		}
+	}
+	r.filesOpened.Set(float64(open))
+}
+
+// readNew walks the objects appended since the last poll. Journal files only
+// grow: objects are appended and never moved, and the header says where the
+// last one starts.
+func (r *JournalReader) readNew(jf *journalFile) error {
+	if jf.file == nil {
+		return nil
+	}
+	h, err := readJournalHeader(jf.file)
+	if err != nil {
+		return err
+	}
+	for jf.offset <= h.tailObject {
+		typ, size, err := jf.objectHeader(jf.offset)
+		if err != nil {
+			return err
+		}
+		if size < journalObjectHeaderSize {
+			return fmt.Errorf("object at %d has size %d", jf.offset, size)
+		}
+		if typ == journalObjectEntry {
+			r.entries.Inc()
+			e, err := r.readEntry(jf, h, jf.offset, size)
+			if err != nil {
+				r.readErrors.Inc()
+			}
+			for _, ev := range journalEvents {
+				if ev.match(e, r.KubeletUnit) {
+					r.events.WithLabelValues(ev.event).Inc()
+				}
+			}
+		}
+		jf.offset += align8(size)
+	}
+	// An archived file never changes again; keep its ID (so it isn't picked
+	// up as new) but give back the descriptor.
+	if h.state == journalStateArchived {
+		jf.close()
+	}
+	return nil
+}
+
+// readEntry decodes the fields of the entry object at off that the matchers
+// need. An error means some fields were skipped; the rest are still returned.
+func (r *JournalReader) readEntry(jf *journalFile, h journalHeader, off, size uint64) (journalEntry, error) {
+	// Entry object: header, seqnum, realtime, monotonic, boot_id[16],
+	// xor_hash, then the items: {offset u64, hash u64}, or {offset u32} in
+	// compact files.
+	const itemsStart = 64
+	buf := make([]byte, size)
+	if _, err := jf.file.ReadAt(buf, int64(off)); err != nil {
+		return journalEntry{}, err
+	}
+	itemSize := 16
+	if h.compact() {
+		itemSize = 4
+	}
+	var e journalEntry
+	var errs []error
+	for i := itemsStart; i+itemSize <= len(buf); i += itemSize {
+		var dataOff uint64
+		if h.compact() {
+			dataOff = uint64(binary.LittleEndian.Uint32(buf[i:]))
+		} else {
+			dataOff = binary.LittleEndian.Uint64(buf[i:])
+		}
+		field, err := r.readData(jf, h, dataOff)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		name, value, _ := bytes.Cut(field, []byte("="))
+		switch string(name) {
+		case "MESSAGE":
+			e.message = string(value)
+		case "_TRANSPORT":
+			e.transport = string(value)
+		case "UNIT":
+			e.unit = string(value)
+		}
+	}
+	return e, errors.Join(errs...)
+}
+
+// readData returns the "FIELD=value" payload of the data object at off.
+func (r *JournalReader) readData(jf *journalFile, h journalHeader, off uint64) ([]byte, error) {
+	var hdr [journalObjectHeaderSize]byte
+	if _, err := jf.file.ReadAt(hdr[:], int64(off)); err != nil {
+		return nil, err
+	}
+	if hdr[0] != journalObjectData {
+		return nil, fmt.Errorf("object at %d is type %d, not data", off, hdr[0])
+	}
+	size := binary.LittleEndian.Uint64(hdr[8:])
+	// Data object: header, hash, next_hash_offset, next_field_offset,
+	// entry_offset, entry_array_offset, n_entries, then in compact files
+	// two u32 tail-entry-array fields, then the payload.
+	payloadStart := uint64(64)
+	if h.compact() {
+		payloadStart = 72
+	}
+	if size <= payloadStart || size > 64<<20 {
+		return nil, fmt.Errorf("data object at %d has size %d", off, size)
+	}
+	payload := make([]byte, size-payloadStart)
+	if _, err := jf.file.ReadAt(payload, int64(off+payloadStart)); err != nil {
+		return nil, err
+	}
+	switch flags := hdr[1]; {
+	case flags == 0:
+		return payload, nil
+	case flags&journalCompressedZstd != 0:
+		return r.zstd.DecodeAll(payload, nil)
+	default:
+		return nil, fmt.Errorf("data object at %d: unsupported compression (flags %#x)", off, flags)
+	}
+}
+
+// Journal file format constants, from systemd's journal-def.h.
+const (
+	journalObjectHeaderSize = 16 // type u8, flags u8, reserved[6], size u64
+
+	journalObjectData  = 1
+	journalObjectEntry = 3
+
+	journalCompressedZstd = 1 << 2 // object flag
+
+	journalIncompatCompact = 1 << 4 // header incompatible_flags
+
+	journalStateArchived = 2
+)
+
+var journalSignature = []byte("LPKSHHRH")
+
+// journalHeader is the part of the file header the reader uses.
+type journalHeader struct {
+	incompatible uint32
+	state        uint8
+	fileID       [16]byte
+	headerSize   uint64
+	tailObject   uint64 // offset of the last object, 0 if there is none
+}
+
+func (h journalHeader) compact() bool { return h.incompatible&journalIncompatCompact != 0 }
+
+func readJournalHeader(f io.ReaderAt) (journalHeader, error) {
+	var buf [144]byte
+	if _, err := f.ReadAt(buf[:], 0); err != nil {
+		return journalHeader{}, fmt.Errorf("reading header: %w", err)
+	}
+	if !bytes.Equal(buf[:8], journalSignature) {
+		return journalHeader{}, fmt.Errorf("not a journal file")
+	}
+	h := journalHeader{
+		incompatible: binary.LittleEndian.Uint32(buf[12:]),
+		state:        buf[16],
+		headerSize:   binary.LittleEndian.Uint64(buf[88:]),
+		tailObject:   binary.LittleEndian.Uint64(buf[136:]),
+	}
+	copy(h.fileID[:], buf[24:40])
+	return h, nil
+}
+
+// journalFile is one journal file being followed.
+type journalFile struct {
+	path   string
+	file   *os.File // nil once an archived file has been read to its end
+	offset uint64   // next object to read
+}
+
+// init positions jf at the start of its objects, or past the last one when
+// atEnd is set.
+func (jf *journalFile) init(h journalHeader, atEnd bool) error {
+	jf.offset = h.headerSize
+	if atEnd && h.tailObject != 0 {
+		_, size, err := jf.objectHeader(h.tailObject)
+		if err != nil {
+			return err
+		}
+		jf.offset = h.tailObject + align8(size)
+	}
+	return nil
+}
+
+func (jf *journalFile) objectHeader(off uint64) (typ uint8, size uint64, err error) {
+	var hdr [journalObjectHeaderSize]byte
+	if _, err := jf.file.ReadAt(hdr[:], int64(off)); err != nil {
+		return 0, 0, err
+	}
+	return hdr[0], binary.LittleEndian.Uint64(hdr[8:]), nil
+}
+
+func (jf *journalFile) close() {
+	if jf.file != nil {
+		jf.file.Close()
+		jf.file = nil
+	}
+}
+
+// align8 rounds up to the 8-byte alignment of journal objects.
+func align8(n uint64) uint64 { return (n + 7) &^ 7 }
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// inClusterClient returns a client authenticated as the pod's ServiceAccount.
+// Modules that talk to the API server (events, nodeinfo) share this; what
+// they may read is decided by the ClusterRole in node-collector.yaml.
+func inClusterClient() (kubernetes.Interface, error) {
+	cfg, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(cfg)
+}
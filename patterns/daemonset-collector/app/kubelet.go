@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Paths every pod gets from its projected service account volume.
+const (
+	serviceAccountToken = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	serviceAccountCA    = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+)
+
+func init() {
+	registerCollector("kubelet", func() (Collector, error) {
+		// NODE_IP comes from the Downward API (status.hostIP).
+		endpoint := getEnv("KUBELET_ENDPOINT", "https://"+os.Getenv("NODE_IP")+":10250")
+		return NewKubeletCollector(endpoint, getEnvBool("KUBELET_INSECURE_TLS", false))
+	})
+}
+
+// kubeletSummary is the subset of the kubelet's /stats/summary response
+// (k8s.io/kubelet/pkg/apis/stats/v1alpha1) that we re-export. Pointers
+// distinguish "not reported yet" from zero.
+type kubeletSummary struct {
+	Pods []struct {
+		PodRef struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"podRef"`
+		CPU *struct {
+			UsageNanoCores       *uint64 `json:"usageNanoCores"`
+			UsageCoreNanoSeconds *uint64 `json:"usageCoreNanoSeconds"`
+		} `json:"cpu"`
+		Memory *struct {
+			WorkingSetBytes *uint64 `json:"workingSetBytes"`
+		} `json:"memory"`
+		EphemeralStorage *struct {
+			UsedBytes *uint64 `json:"usedBytes"`
+		} `json:"ephemeral-storage"`
+	} `json:"pods"`
+}
+
+// KubeletCollector queries the local kubelet's summary API at scrape time and
+// re-exports per-pod usage. This is the node-local API a DaemonSet is uniquely
+// placed to use: one request per node, no cluster-wide fan-out.
+type KubeletCollector struct {
+	URL    string
+	client *http.Client
+
+	cpuCores     *prometheus.Desc
+	cpuSeconds   *prometheus.Desc
+	memWorkSet   *prometheus.Desc
+	ephemeralUse *prometheus.Desc
+}
+
+// NewKubeletCollector authenticates with the pod's service account token and
+// trusts the cluster CA unless insecure is set. Many distributions serve the
+// kubelet with a self-signed certificate, in which case insecure is required.
+func NewKubeletCollector(endpoint string, insecure bool) (*KubeletCollector, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: insecure}
+	if !insecure {
+		ca, err := os.ReadFile(serviceAccountCA)
+		if err != nil {
+			return nil, fmt.Errorf("reading cluster CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		pool.AppendCertsFromPEM(ca)
+		tlsConfig.RootCAs = pool
+	}
+
+	podLabels := []string{"namespace", "pod"}
+	return &KubeletCollector{
+		URL: strings.TrimSuffix(endpoint, "/") + "/stats/summary",
+		client: &http.Client{
+			Timeout:   5 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		},
+
+		cpuCores: prometheus.NewDesc("kubelet_pod_cpu_usage_cores",
+			"Pod CPU usage averaged over the kubelet's sampling window.", podLabels, nil),
+		cpuSeconds: prometheus.NewDesc("kubelet_pod_cpu_seconds_total",
+			"Cumulative pod CPU time.", podLabels, nil),
+		memWorkSet: prometheus.NewDesc("kubelet_pod_memory_working_set_bytes",
+			"Pod memory working set (what the OOM killer looks at).", podLabels, nil),
+		ephemeralUse: prometheus.NewDesc("kubelet_pod_ephemeral_storage_used_bytes",
+			"Ephemeral storage used by the pod (emptyDirs, logs, writable layers).", podLabels, nil),
+	}, nil
+}
+
+// Name implements Collector.
+func (c *KubeletCollector) Name() string { return "kubelet" }
+
+// Start implements Collector; the kubelet is queried at scrape time.
+func (c *KubeletCollector) Start(context.Context) error { return nil }
+
+// Stop implements Collector.
+func (c *KubeletCollector) Stop() error { return nil }
+
+// Describe implements Collector.
+func (c *KubeletCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.cpuCores
+	ch <- c.cpuSeconds
+	ch <- c.memWorkSet
+	ch <- c.ephemeralUse
+}
+
+// CollectInto implements Collector. A failed query shows up as
+// collector_scrape_success{collector="kubelet"} 0.
+func (c *KubeletCollector) CollectInto(ch chan<- prometheus.Metric) error {
+	summary, err := c.fetch(context.Background())
+	if err != nil {
+		return err
+	}
+
+	for _, p := range summary.Pods {
+		ns, name := p.PodRef.Namespace, p.PodRef.Name
+		if p.CPU != nil && p.CPU.UsageNanoCores != nil {
+			ch <- prometheus.MustNewConstMetric(c.cpuCores, prometheus.GaugeValue, float64(*p.CPU.UsageNanoCores)/1e9, ns, name)
+		}
+		if p.CPU != nil && p.CPU.UsageCoreNanoSeconds != nil {
+			ch <- prometheus.MustNewConstMetric(c.cpuSeconds, prometheus.CounterValue, float64(*p.CPU.UsageCoreNanoSeconds)/1e9, ns, name)
+		}
+		if p.Memory != nil && p.Memory.WorkingSetBytes != nil {
+			ch <- prometheus.MustNewConstMetric(c.memWorkSet, prometheus.GaugeValue, float64(*p.Memory.WorkingSetBytes), ns, name)
+		}
+		if p.EphemeralStorage != nil && p.EphemeralStorage.UsedBytes != nil {
+			ch <- prometheus.MustNewConstMetric(c.ephemeralUse, prometheus.GaugeValue, float64(*p.EphemeralStorage.UsedBytes), ns, name)
+		}
+	}
+	return nil
+}
+
+func (c *KubeletCollector) fetch(ctx context.Context) (*kubeletSummary, error) {
+	// Re-read the token every time: projected tokens are rotated by the kubelet.
+	token, err := os.ReadFile(serviceAccountToken)
+	if err != nil {
+		return nil, fmt.Errorf("reading service account token: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		// 403 here means RBAC: the ServiceAccount needs get on nodes/stats.
+		return nil, fmt.Errorf("%s returned %s", c.URL, resp.Status)
+	}
+
+	var summary kubeletSummary
+	if err := json.NewDecoder(resp.Body).Decode(&summary); err != nil {
+		return nil, fmt.Errorf("decoding summary: %w", err)
+	}
+	return &summary, nil
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	registerCollector("logs", func() (Collector, error) {
+		mode := getEnv("LOG_SHIP_MODE", "stdout")
+		shipper, err := NewLogShipper(mode, getEnv("LOG_SHIP_URL", ""))
+		if err != nil {
+			return nil, err
+		}
+		interval, err := time.ParseDuration(getEnv("LOG_POLL_INTERVAL", "1s"))
+		if err != nil || interval <= 0 {
+			interval = time.Second
+		}
+		return NewLogTailer(getEnv("LOG_GLOB", "/var/log/containers/*.log"),
+			os.Getenv("NODE_NAME"), interval, shipper), nil
+	})
+}
+
+// LogEntry is one container log line, parsed from the CRI format and enriched
+// with the pod it belongs to. Entries from other sources (syslog) leave Pod
+// mostly empty and describe themselves in Labels.
+type LogEntry struct {
+	Time   time.Time
+	Stream string // stdout or stderr
+	Line   string
+	Pod    PodRef
+	Labels map[string]string // extra stream labels, e.g. app and level
+}
+
+// PodRef identifies the container that wrote a log file.
+type PodRef struct {
+	Namespace string
+	Pod       string
+	Container string
+	Node      string
+}
+
+// parseContainerLogName extracts pod metadata from the kubelet's naming scheme
+// for /var/log/containers: <pod>_<namespace>_<container>-<containerID>.log
+// Pod and namespace names are DNS labels, so they can't contain '_'.
+func parseContainerLogName(path string) (PodRef, bool) {
+	name := strings.TrimSuffix(filepath.Base(path), ".log")
+	parts := strings.SplitN(name, "_", 3)
+	if len(parts) != 3 {
+		return PodRef{}, false
+	}
+	container := parts[2]
+	// Strip "-<64 hex chars>" container ID.
+	if i := strings.LastIndex(container, "-"); i > 0 {
+		container = container[:i]
+	}
+	return PodRef{Pod: parts[0], Namespace: parts[1], Container: container}, true
+}
+
+// parseCRILine parses "<RFC3339Nano> <stream> <P|F> <message>".
+// partial is true for the P tag: the runtime split a long line and the rest
+// follows in the next record.
+func parseCRILine(raw string) (ts time.Time, stream string, partial bool, msg string, err error) {
+	fields := strings.SplitN(raw, " ", 4)
+	if len(fields) < 3 {
+		return ts, "", false, "", fmt.Errorf("not a CRI log line: %q", raw)
+	}
+	ts, err = time.Parse(time.RFC3339Nano, fields[0])
+	if err != nil {
+		return ts, "", false, "", err
+	}
+	if len(fields) == 4 {
+		msg = fields[3]
+	}
+	return ts, fields[1], fields[2] == "P", msg, nil
+}
+
+// tailedFile is the read position in one log file.
+type tailedFile struct {
+	path    string
+	file    *os.File
+	reader  *bufio.Reader
+	inode   uint64
+	offset  int64
+	pod     PodRef
+	pending string // a partial (P) record waiting for its final (F) part
+	carry   string // bytes after the last newline, not yet a complete record
+}
+
+// LogTailer follows every file matching Glob, like `tail -F` across rotations,
+// and hands complete entries to a LogShipper in batches.
+type LogTailer struct {
+	Glob    string
+	Node    string
+	Shipper LogShipper
+	// MaxPending bounds how many entries are buffered while the sink is down.
+	MaxPending int
+	schedule
+
+	files   map[string]*tailedFile
+	pending []LogEntry
+	cancel  context.CancelFunc
+
+	mu      sync.Mutex
+	shipErr error // result of the last delivery attempt
+
+	linesRead   *prometheus.CounterVec
+	bytesRead   prometheus.Counter
+	shipErrors  prometheus.Counter
+	dropped     prometheus.Counter
+	filesTailed prometheus.Gauge
+}
+
+// NewLogTailer creates a tailer; call Start to begin following files.
+func NewLogTailer(glob, node string, interval time.Duration, shipper LogShipper) *LogTailer {
+	return &LogTailer{
+		Glob:       glob,
+		Node:       node,
+		Shipper:    shipper,
+		MaxPending: 10000,
+		schedule:   schedule{Interval: interval, Jitter: collectorJitter()},
+		files:      map[string]*tailedFile{},
+
+		linesRead: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "collector_logs_lines_total",
+			Help: "Container log lines read, by namespace.",
+		}, []string{"namespace"}),
+		bytesRead: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "collector_logs_read_bytes_total",
+			Help: "Bytes read from container log files.",
+		}),
+		shipErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "collector_logs_ship_errors_total",
+			Help: "Failed attempts to deliver a batch to the log sink.",
+		}),
+		dropped: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "collector_logs_dropped_total",
+			Help: "Entries dropped because the sink was unavailable for too long.",
+		}),
+		filesTailed: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "collector_logs_files_tailed",
+			Help: "Container log files currently being followed.",
+		}),
+	}
+}
+
+// Name implements Collector.
+func (t *LogTailer) Name() string { return "logs" }
+
+// Describe implements Collector.
+func (t *LogTailer) Describe(ch chan<- *prometheus.Desc) {
+	t.linesRead.Describe(ch)
+	t.bytesRead.Describe(ch)
+	t.shipErrors.Describe(ch)
+	t.dropped.Describe(ch)
+	t.filesTailed.Describe(ch)
+}
+
+// CollectInto implements Collector. It fails while the sink is rejecting
+// batches, i.e. while logs are piling up locally.
+func (t *LogTailer) CollectInto(ch chan<- prometheus.Metric) error {
+	t.linesRead.Collect(ch)
+	t.bytesRead.Collect(ch)
+	t.shipErrors.Collect(ch)
+	t.dropped.Collect(ch)
+	t.filesTailed.Collect(ch)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.shipErr
+}
+
+// Start implements Collector.
+func (t *LogTailer) Start(ctx context.Context) error {
+	ctx, t.cancel = context.WithCancel(ctx)
+	go t.Run(ctx)
+	fmt.Printf("Following container logs in %s\n", t.Glob)
+	return nil
+}
+
+// Stop implements Collector.
+func (t *LogTailer) Stop() error {
+	if t.cancel != nil {
+		t.cancel()
+	}
+	return nil
+}
+
+// Run polls until ctx is cancelled. Files found on the first scan are read
+// from their end so a restart doesn't re-ship the node's whole log history;
+// files that appear later are read from the beginning.
+func (t *LogTailer) Run(ctx context.Context) {
+	t.scan(true)
+	t.run(ctx, func(ctx context.Context) {
+		t.scan(false)
+		t.readAll()
+		t.flush(ctx)
+	})
+	for _, f := range t.files {
+		f.file.Close()
+	}
+}
+
+func (t *LogTailer) scan(initial bool) {
+	paths, err := filepath.Glob(t.Glob)
+	if err != nil {
+		fmt.Printf("logs: bad glob %q: %v\n", t.Glob, err)
+		return
+	}
+	seen := map[string]bool{}
+	for _, p := range paths {
+		seen[p] = true
+		if _, ok := t.files[p]; ok {
+			continue
+		}
+		pod, ok := parseContainerLogName(p)
+		if !ok {
+			continue
+		}
+		pod.Node = t.Node
+		tf := &tailedFile{path: p, pod: pod}
+		if err := tf.open(initial); err != nil {
+			fmt.Printf("logs: open %s: %v\n", p, err)
+			continue
+		}
+		t.files[p] = tf
+	}
+	// The kubelet removes the symlink when the container is deleted.
+	for p, tf := range t.files {
+		if !seen[p] {
+			tf.file.Close()
+			delete(t.files, p)
+		}
+	}
+	t.filesTailed.Set(float64(len(t.files)))
+}
+
+func (tf *tailedFile) open(atEnd bool) error {
+	f, err := os.Open(tf.path)
+	if err != nil {
+		return err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	var offset int64
+	if atEnd {
+		if offset, err = f.Seek(0, io.SeekEnd); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	tf.file, tf.reader, tf.offset, tf.inode = f, bufio.NewReader(f), offset, inodeOf(st)
+	tf.carry = ""
+	return nil
+}
+
+func inodeOf(st os.FileInfo) uint64 {
+	if sys, ok := st.Sys().(*syscall.Stat_t); ok {
+		return uint64(sys.Ino)
+	}
+	return 0
+}
+
+func (t *LogTailer) readAll() {
+	for _, tf := range t.files {
+		t.readFile(tf)
+
+		// Rotation: the path now points at a new inode. We've drained the old
+		// file above; continue from the start of the new one.
+		// Truncation: same inode but smaller than where we were.
+		st, err := os.Stat(tf.path)
+		if err != nil {
+			continue
+		}
+		if inodeOf(st) != tf.inode || st.Size() < tf.offset {
+			tf.file.Close()
+			if err := tf.open(false); err != nil {
+				fmt.Printf("logs: reopen %s: %v\n", tf.path, err)
+				continue
+			}
+			t.readFile(tf)
+		}
+	}
+}
+
+func (t *LogTailer) readFile(tf *tailedFile) {
+	for {
+		chunk, err := tf.reader.ReadString('\n')
+		tf.offset += int64(len(chunk))
+		t.bytesRead.Add(float64(len(chunk)))
+		if err != nil {
+			// No newline yet: the runtime is mid-write. Keep the bytes for later.
+			tf.carry += chunk
+			if !errors.Is(err, io.EOF) {
+				fmt.Printf("logs: read %s: %v\n", tf.path, err)
+			}
+			return
+		}
+		raw := strings.TrimSuffix(tf.carry+chunk, "\n")
+		tf.carry = ""
+
+		ts, stream, partial, msg, err := parseCRILine(raw)
+		if err != nil {
+			continue
+		}
+		if partial {
+			tf.pending += msg
+			continue
+		}
+		t.enqueue(LogEntry{Time: ts, Stream: stream, Line: tf.pending + msg, Pod: tf.pod})
+		tf.pending = ""
+	}
+}
+
+func (t *LogTailer) enqueue(e LogEntry) {
+	t.linesRead.WithLabelValues(e.Pod.Namespace).Inc()
+	if len(t.pending) >= t.MaxPending {
+		// Drop the oldest: fresh logs are more useful than stale ones.
+		t.pending = t.pending[1:]
+		t.dropped.Inc()
+	}
+	t.pending = append(t.pending, e)
+}
+
+func (t *LogTailer) flush(ctx context.Context) {
+	if len(t.pending) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	err := t.Shipper.Ship(ctx, t.pending)
+	t.mu.Lock()
+	t.shipErr = err
+	t.mu.Unlock()
+	if err != nil {
+		// Keep the batch and retry on the next tick.
+		t.shipErrors.Inc()
+		fmt.Printf("logs: ship %d entries: %v\n", len(t.pending), err)
+		return
+	}
+	t.pending = t.pending[:0]
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LogShipper delivers a batch of log entries to a sink.
+type LogShipper interface {
+	Ship(ctx context.Context, entries []LogEntry) error
+}
+
+// NewLogShipper returns the shipper for mode: "loki", "http" or "stdout".
+func NewLogShipper(mode, url string) (LogShipper, error) {
+	switch mode {
+	case "loki":
+		return &LokiShipper{URL: url, Client: &http.Client{}}, nil
+	case "http":
+		return &HTTPShipper{URL: url, Client: &http.Client{}}, nil
+	case "stdout":
+		return StdoutShipper{}, nil
+	}
+	return nil, fmt.Errorf("unknown log ship mode %q (want loki, http or stdout)", mode)
+}
+
+// LokiShipper pushes to Loki's /loki/api/v1/push JSON API. Entries are grouped
+// into one stream per pod/container/stream label set.
+type LokiShipper struct {
+	URL    string
+	Client *http.Client
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+func (s *LokiShipper) Ship(ctx context.Context, entries []LogEntry) error {
+	streams := map[string]*lokiStream{}
+	var order []*lokiStream
+	for _, e := range entries {
+		labels := map[string]string{
+			"namespace": e.Pod.Namespace,
+			"pod":       e.Pod.Pod,
+			"container": e.Pod.Container,
+			"node":      e.Pod.Node,
+			"stream":    e.Stream,
+		}
+		for k, v := range e.Labels {
+			labels[k] = v
+		}
+		// Loki treats an empty label as absent; leave those out so syslog
+		// entries don't carry pod="" and the like.
+		names := make([]string, 0, len(labels))
+		for k, v := range labels {
+			if v == "" {
+				delete(labels, k)
+				continue
+			}
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		var key strings.Builder
+		for _, k := range names {
+			key.WriteString(k + "\xff" + labels[k] + "\xff")
+		}
+
+		ls, ok := streams[key.String()]
+		if !ok {
+			ls = &lokiStream{Stream: labels}
+			streams[key.String()] = ls
+			order = append(order, ls)
+		}
+		ls.Values = append(ls.Values, [2]string{strconv.FormatInt(e.Time.UnixNano(), 10), e.Line})
+	}
+	return postJSON(ctx, s.Client, s.URL, map[string]any{"streams": order})
+}
+
+// HTTPShipper POSTs the batch as a JSON array to any endpoint (e.g. a
+// webhook, Vector or Fluent Bit's http input).
+type HTTPShipper struct {
+	URL    string
+	Client *http.Client
+}
+
+type httpLogRecord struct {
+	Time      string            `json:"time"`
+	Stream    string            `json:"stream"`
+	Namespace string            `json:"namespace"`
+	Pod       string            `json:"pod"`
+	Container string            `json:"container"`
+	Node      string            `json:"node"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Message   string            `json:"message"`
+}
+
+func (s *HTTPShipper) Ship(ctx context.Context, entries []LogEntry) error {
+	records := make([]httpLogRecord, 0, len(entries))
+	for _, e := range entries {
+		records = append(records, httpLogRecord{
+			Time:      e.Time.Format(time.RFC3339Nano),
+			Stream:    e.Stream,
+			Namespace: e.Pod.Namespace,
+			Pod:       e.Pod.Pod,
+			Container: e.Pod.Container,
+			Node:      e.Pod.Node,
+			Labels:    e.Labels,
+			Message:   e.Line,
+		})
+	}
+	return postJSON(ctx, s.Client, s.URL, records)
+}
+
+// StdoutShipper prints entries; handy with `kubectl logs` while developing.
+type StdoutShipper struct{}
+
+func (StdoutShipper) Ship(_ context.Context, entries []LogEntry) error {
+	for _, e := range entries {
+		if e.Pod.Pod == "" {
+			fmt.Printf("[%s %s] %s\n", e.Labels["app"], e.Stream, e.Line)
+			continue
+		}
+		fmt.Printf("[%s/%s/%s %s] %s\n", e.Pod.Namespace, e.Pod.Pod, e.Pod.Container, e.Stream, e.Line)
+	}
+	return nil
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, body any) error {
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	return nil
+}
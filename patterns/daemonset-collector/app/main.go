@@ -1,50 +1,309 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/http/pprof"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-// 1. Define a custom metric (Counter)
-// We use 'promauto' to automatically register it with the default registry.
-var (
-	opsProcessed = promauto.NewCounter(prometheus.CounterOpts{
-		Name: "myapp_processed_ops_total",
-		Help: "The total number of processed operations",
-	})
-)
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
 
-// 2. Simulate traffic/work in the background
-// In a real app, this would be your API handler logic.
-func recordMetrics() {
-	go func() {
-		for {
-			opsProcessed.Inc() // Increment the counter
-			time.Sleep(2 * time.Second)
+func getEnvBool(key string, fallback bool) bool {
+	if value, ok := os.LookupEnv(key); ok {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
 		}
-	}()
+		fmt.Printf("Invalid %s=%q, using default %t\n", key, value, fallback)
+	}
+	return fallback
+}
+
+func getEnvInt(key string, fallback int) int {
+	if value, ok := os.LookupEnv(key); ok {
+		if i, err := strconv.Atoi(value); err == nil {
+			return i
+		}
+		fmt.Printf("Invalid %s=%q, using default %d\n", key, value, fallback)
+	}
+	return fallback
+}
+
+// splitList turns "a, b,,c" into [a b c].
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// downwardLabels returns the identity the Downward API injected into our env.
+// The names follow the OpenTelemetry k8s.* conventions rather than plain
+// "pod"/"namespace": Prometheus' kubernetes_sd already attaches those as target
+// labels, and a clash would silently rename ours to exported_pod.
+func downwardLabels() prometheus.Labels {
+	labels := prometheus.Labels{}
+	for label, env := range map[string]string{
+		"k8s_node_name":      "NODE_NAME",
+		"k8s_pod_name":       "POD_NAME",
+		"k8s_namespace_name": "NAMESPACE",
+	} {
+		// Unset when running outside Kubernetes; skip rather than emit "".
+		if v := os.Getenv(env); v != "" {
+			labels[label] = v
+		}
+	}
+	return labels
+}
+
+// registerStandardCollectors adds the collectors the global default registry
+// would have given us for free. With an explicit registry each one is opt-in,
+// which is how you keep a 500-node DaemonSet from shipping 100+ go_* series
+// per pod that nobody looks at.
+func registerStandardCollectors(reg prometheus.Registerer) {
+	// go_goroutines, go_memstats_*, go_gc_duration_seconds, ...
+	// GO_RUNTIME_METRICS_ALL additionally exports every runtime/metrics value
+	// (scheduler latencies, GC pauses by cause, ...) — roughly 5x the series.
+	if getEnvBool("COLLECT_GO_RUNTIME", true) {
+		goCollector := collectors.NewGoCollector()
+		if getEnvBool("GO_RUNTIME_METRICS_ALL", false) {
+			goCollector = collectors.NewGoCollector(collectors.WithGoCollectorRuntimeMetrics(collectors.MetricsAll))
+		}
+		reg.MustRegister(goCollector)
+	}
+
+	// process_cpu_seconds_total, process_resident_memory_bytes, open fds, ...
+	if getEnvBool("COLLECT_PROCESS", true) {
+		reg.MustRegister(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	}
+
+	// go_build_info{path,version,checksum}: which binary is running where.
+	// Off by default in client_golang, so opt-in here too.
+	if getEnvBool("COLLECT_BUILD_INFO", false) {
+		reg.MustRegister(collectors.NewBuildInfoCollector())
+	}
 }
 
 func main() {
-	// Start the background simulation
-	recordMetrics()
+	// 3. Build a dedicated registry
+	// Everything registered through 'registerer' is wrapped with the Downward
+	// API labels, so every series can be attributed to the node it came from
+	// even after it leaves the cluster (remote write, federation, OTLP).
+	registry := prometheus.NewRegistry()
+	labels := downwardLabels()
+	registerer := prometheus.WrapRegistererWith(labels, registry)
 
-	// 3. Expose the registered metrics via HTTP
-	// The 'promhttp.Handler()' function gives us the standard scrape page
-	http.Handle("/metrics", promhttp.Handler())
+	registerStandardCollectors(registerer)
+
+	// Traces for the simulated requests (tracing.go), exported to
+	// OTLP_ENDPOINT when set. Sampled ones are linked from the metrics as
+	// exemplars.
+	sampleRatio, err := strconv.ParseFloat(getEnv("TRACE_SAMPLE_RATIO", "0.1"), 64)
+	if err != nil || sampleRatio < 0 || sampleRatio > 1 {
+		sampleRatio = 0.1
+	}
+	tracer, flushTraces, err := newTracerProvider(context.Background(), getEnv("OTLP_ENDPOINT", ""), getEnvBool("OTLP_INSECURE", false), sampleRatio)
+	if err != nil {
+		fmt.Printf("Error setting up tracing: %s\n", err)
+		os.Exit(1)
+	}
+
+	// Start the background simulation (simulate.go)
+	// One example of each metric type: Counter, Gauge, Histogram, Summary.
+	newSimulation(registerer, tracer).run()
+
+	// Node collectors (collector.go). Each module registers itself; COLLECTORS
+	// picks which ones run. Every collector reports its own
+	// collector_scrape_success and collector_scrape_duration_seconds.
+	collectors, err := NewCollectorSet(splitList(getEnv("COLLECTORS", "node")))
+	if err != nil {
+		fmt.Printf("Error configuring collectors: %s\n", err)
+		os.Exit(1)
+	}
+	if err := collectors.Register(registerer); err != nil {
+		fmt.Printf("Error registering collectors: %s\n", err)
+		os.Exit(1)
+	}
+	// Exporter-side relabeling (relabel.go): METRICS_ALLOW / METRICS_DENY
+	// regexes on the metric name, then RELABEL_CONFIG rules. Applies to
+	// /metrics and to every push path alike.
+	var gatherer prometheus.Gatherer = registry
+	rules, err := LoadRelabelRules(getEnv("METRICS_ALLOW", ""), getEnv("METRICS_DENY", ""), getEnv("RELABEL_CONFIG", ""))
+	if err != nil {
+		fmt.Printf("Error loading relabel rules: %s\n", err)
+		os.Exit(1)
+	}
+	if len(rules) > 0 {
+		gatherer = newRelabelGatherer(registry, rules, registerer)
+		fmt.Printf("Applying %d relabel rules\n", len(rules))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	collectors.Start(ctx)
+
+	// Optional OTLP push: the same registry, exported over OTLP/gRPC to a
+	// collector on a timer. Scraping /metrics keeps working alongside it.
+	flushOTLP := func(context.Context) error { return nil }
+	if endpoint := getEnv("OTLP_ENDPOINT", ""); endpoint != "" {
+		interval, err := time.ParseDuration(getEnv("OTLP_INTERVAL", "30s"))
+		if err != nil {
+			interval = 30 * time.Second
+		}
+		shutdown, err := startOTLPPush(ctx, gatherer, endpoint, interval, getEnvBool("OTLP_INSECURE", false))
+		if err != nil {
+			fmt.Printf("OTLP push disabled: %s\n", err)
+		} else {
+			flushOTLP = shutdown
+			fmt.Printf("Pushing metrics via OTLP to %s every %s\n", endpoint, interval)
+		}
+	}
+
+	// Optional push modes for clusters where nothing can scrape this pod:
+	// PUSH_MODE=pushgateway or remote_write, to PUSH_URL every PUSH_INTERVAL.
+	if mode := getEnv("PUSH_MODE", ""); mode != "" {
+		interval, err := time.ParseDuration(getEnv("PUSH_INTERVAL", "30s"))
+		if err != nil {
+			interval = 30 * time.Second
+		}
+		instance := getEnv("NODE_NAME", getEnv("HOSTNAME", "unknown"))
+		pusher, err := NewMetricPusher(mode, getEnv("PUSH_URL", ""), getEnv("PUSH_JOB", "node-collector"), instance, gatherer)
+		if err != nil {
+			fmt.Printf("Push disabled: %s\n", err)
+		} else {
+			go runPusher(ctx, pusher, interval)
+			fmt.Printf("Pushing metrics (%s) to %s every %s\n", mode, getEnv("PUSH_URL", ""), interval)
+		}
+	}
+
+	// 4. Expose the registered metrics via HTTP
+	// 'promhttp.HandlerFor' serves our registry in the standard scrape format.
+	// Optional bearer token / basic auth in front of it (auth.go).
+	auth := MetricsAuth{
+		BearerTokenFile: getEnv("METRICS_BEARER_TOKEN_FILE", ""),
+		BasicUser:       getEnv("METRICS_BASIC_AUTH_USER", ""),
+		BasicPassFile:   getEnv("METRICS_BASIC_AUTH_PASSWORD_FILE", ""),
+	}
+	// A dedicated mux rather than http.DefaultServeMux: importing net/http/pprof
+	// registers /debug/pprof on the default mux as a side effect, and that must
+	// stay opt-in.
+	mux := http.NewServeMux()
+	// EnableOpenMetrics: scrapers that ask for OpenMetrics (Accept header) get
+	// it, and only that format carries exemplars in text. Others still get
+	// the classic text format.
+	mux.Handle("/metrics", auth.Wrap(promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{EnableOpenMetrics: true})))
+
+	// Probes. /healthz only says the process is serving (liveness: restart me
+	// if this fails). /readyz fails while any collector hasn't succeeded
+	// within READY_MAX_AGE (readiness: don't count me as available). Neither
+	// requires credentials, because the kubelet's probes don't send any.
+	readyMaxAge, err := time.ParseDuration(getEnv("READY_MAX_AGE", "2m"))
+	if err != nil || readyMaxAge <= 0 {
+		readyMaxAge = 2 * time.Minute
+	}
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if err := collectors.Ready(readyMaxAge); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+
+	// Optional multi-target probing (probe.go): /probe?target=...&module=http|tcp|icmp
+	// checks the target from this node. Off by default and behind the
+	// /metrics credentials: it makes the node send requests on the caller's
+	// behalf.
+	if getEnvBool("ENABLE_PROBE", false) {
+		timeout, err := time.ParseDuration(getEnv("PROBE_TIMEOUT", "5s"))
+		if err != nil || timeout <= 0 {
+			timeout = 5 * time.Second
+		}
+		mux.Handle("/probe", auth.Wrap(NewProber(timeout, getEnvBool("PROBE_INSECURE_TLS", false), labels)))
+		fmt.Println("Serving probes on :2112/probe")
+	}
+
+	// Optional profiling, behind the same credentials as /metrics:
+	//   go tool pprof http://<pod>:2112/debug/pprof/heap
+	if getEnvBool("ENABLE_PPROF", false) {
+		mux.Handle("/debug/pprof/", auth.Wrap(http.HandlerFunc(pprof.Index)))
+		mux.Handle("/debug/pprof/cmdline", auth.Wrap(http.HandlerFunc(pprof.Cmdline)))
+		mux.Handle("/debug/pprof/profile", auth.Wrap(http.HandlerFunc(pprof.Profile)))
+		mux.Handle("/debug/pprof/symbol", auth.Wrap(http.HandlerFunc(pprof.Symbol)))
+		mux.Handle("/debug/pprof/trace", auth.Wrap(http.HandlerFunc(pprof.Trace)))
+		fmt.Println("Serving pprof on :2112/debug/pprof/")
+	}
 
 	fmt.Println("Starting server...")
-	fmt.Println("Serving metrics on :2112/metrics")
+	fmt.Printf("Attaching labels to all metrics: %v\n", labels)
+	if auth.Enabled() {
+		fmt.Println("Requiring credentials for /metrics")
+	}
 
 	// Start the web server on port 2112
 	// 2112 is a common convention for instrumentation ports to avoid collision with 80/8080
-	err := http.ListenAndServe(":2112", nil)
-	if err != nil {
+	server := &http.Server{Addr: ":2112", Handler: mux}
+
+	// On SIGTERM (pod deletion, rolling update): stop the collectors, push
+	// the last OTLP interval, and let in-flight scrapes finish.
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		<-ctx.Done()
+		fmt.Println("Shutting down...")
+		if err := collectors.Stop(); err != nil {
+			fmt.Printf("Error stopping collectors: %s\n", err)
+		}
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := flushOTLP(shutdownCtx); err != nil {
+			fmt.Printf("Error flushing OTLP: %s\n", err)
+		}
+		if err := flushTraces(shutdownCtx); err != nil {
+			fmt.Printf("Error flushing traces: %s\n", err)
+		}
+		server.Shutdown(shutdownCtx)
+	}()
+
+	certFile, keyFile := getEnv("METRICS_TLS_CERT_FILE", ""), getEnv("METRICS_TLS_KEY_FILE", "")
+	if certFile != "" && keyFile != "" {
+		reloader, certErr := newCertReloader(certFile, keyFile)
+		if certErr != nil {
+			fmt.Printf("Error loading TLS certificate: %s\n", certErr)
+			os.Exit(1)
+		}
+		server.TLSConfig = &tls.Config{GetCertificate: reloader.GetCertificate, MinVersion: tls.VersionTLS12}
+		fmt.Println("Serving metrics on https://:2112/metrics")
+		err = server.ListenAndServeTLS("", "")
+	} else {
+		fmt.Println("Serving metrics on :2112/metrics")
+		err = server.ListenAndServe()
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		<-shutdownDone
+	} else if err != nil {
 		fmt.Printf("Error starting server: %s\n", err)
 	}
 }
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/procfs"
+)
+
+func init() {
+	registerCollector("netstat", func() (Collector, error) {
+		return NewNetstatCollector(getEnv("HOST_PROC", "/proc"))
+	})
+}
+
+// tcpStates are the socket states in /proc/net/tcp's "st" column (hex),
+// named as in the kernel's include/net/tcp_states.h.
+var tcpStates = map[uint64]string{
+	0x01: "established",
+	0x02: "syn_sent",
+	0x03: "syn_recv",
+	0x04: "fin_wait1",
+	0x05: "fin_wait2",
+	0x06: "time_wait",
+	0x07: "close",
+	0x08: "close_wait",
+	0x09: "last_ack",
+	0x0A: "listen",
+	0x0B: "closing",
+}
+
+// NetstatCollector reports the node's networking health: how full the
+// conntrack table is and what state its sockets are in. These are the
+// numbers behind the classic node-level outages that per-pod metrics can't
+// see: "nf_conntrack: table full, dropping packet" in dmesg, ephemeral port
+// exhaustion from piles of TIME_WAIT, or TCP memory pressure.
+//
+// Like the network counters in node.go, everything is read from PID 1's view
+// of /proc (the host network namespace), at scrape time.
+type NetstatCollector struct {
+	procPath string
+	host     procfs.FS // rooted at HOST_PROC/1, so net/* is the host's
+
+	conntrackEntries *prometheus.Desc
+	conntrackLimit   *prometheus.Desc
+	conntrackDrops   *prometheus.Desc
+	tcpStates        *prometheus.Desc
+	socketsUsed      *prometheus.Desc
+	sockets          *prometheus.Desc
+	socketMemory     *prometheus.Desc
+}
+
+// NewNetstatCollector creates a collector reading from the procfs mounted at
+// procPath.
+func NewNetstatCollector(procPath string) (*NetstatCollector, error) {
+	host, err := procfs.NewFS(filepath.Join(procPath, "1"))
+	if err != nil {
+		return nil, fmt.Errorf("opening procfs at %s: %w", procPath, err)
+	}
+
+	return &NetstatCollector{
+		procPath: procPath,
+		host:     host,
+
+		conntrackEntries: prometheus.NewDesc("node_nf_conntrack_entries",
+			"Connections currently tracked by netfilter.", nil, nil),
+		conntrackLimit: prometheus.NewDesc("node_nf_conntrack_entries_limit",
+			"Size of the conntrack table (nf_conntrack_max).", nil, nil),
+		conntrackDrops: prometheus.NewDesc("node_nf_conntrack_stat_drops_total",
+			"Conntrack failures summed over CPUs: drop and early_drop mean the table was full, insert_failed usually means a race on the same tuple.",
+			[]string{"reason"}, nil),
+		tcpStates: prometheus.NewDesc("node_tcp_connection_states",
+			"TCP sockets (IPv4 and IPv6) by state.", []string{"state"}, nil),
+		socketsUsed: prometheus.NewDesc("node_sockstat_sockets_used",
+			"Sockets of all kinds in use.", nil, nil),
+		sockets: prometheus.NewDesc("node_sockstat_sockets",
+			"Sockets in use, by protocol and state from /proc/net/sockstat.", []string{"protocol", "state"}, nil),
+		socketMemory: prometheus.NewDesc("node_sockstat_memory_bytes",
+			"Kernel memory used by socket buffers, by protocol.", []string{"protocol"}, nil),
+	}, nil
+}
+
+// Name implements Collector.
+func (c *NetstatCollector) Name() string { return "netstat" }
+
+// Start implements Collector; there is nothing to run in the background.
+func (c *NetstatCollector) Start(context.Context) error { return nil }
+
+// Stop implements Collector.
+func (c *NetstatCollector) Stop() error { return nil }
+
+// Describe implements Collector.
+func (c *NetstatCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.conntrackEntries
+	ch <- c.conntrackLimit
+	ch <- c.conntrackDrops
+	ch <- c.tcpStates
+	ch <- c.socketsUsed
+	ch <- c.sockets
+	ch <- c.socketMemory
+}
+
+// CollectInto implements Collector.
+func (c *NetstatCollector) CollectInto(ch chan<- prometheus.Metric) error {
+	var errs []error
+	if err := c.collectConntrack(ch); err != nil {
+		errs = append(errs, fmt.Errorf("conntrack: %w", err))
+	}
+	if err := c.collectTCPStates(ch); err != nil {
+		errs = append(errs, fmt.Errorf("tcp states: %w", err))
+	}
+	if err := c.collectSockstat(ch); err != nil {
+		errs = append(errs, fmt.Errorf("sockstat: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+func (c *NetstatCollector) collectConntrack(ch chan<- prometheus.Metric) error {
+	stats, err := c.host.ConntrackStat()
+	if errors.Is(err, os.ErrNotExist) {
+		return nil // nf_conntrack not loaded (e.g. an eBPF dataplane without kube-proxy)
+	}
+	if err != nil {
+		return err
+	}
+	if len(stats) == 0 {
+		return nil
+	}
+	// Every per-CPU line repeats the table-wide entry count.
+	ch <- prometheus.MustNewConstMetric(c.conntrackEntries, prometheus.GaugeValue, float64(stats[0].Entries))
+
+	var drop, earlyDrop, insertFailed uint64
+	for _, s := range stats {
+		drop += s.Drop
+		earlyDrop += s.EarlyDrop
+		insertFailed += s.InsertFailed
+	}
+	ch <- prometheus.MustNewConstMetric(c.conntrackDrops, prometheus.CounterValue, float64(drop), "drop")
+	ch <- prometheus.MustNewConstMetric(c.conntrackDrops, prometheus.CounterValue, float64(earlyDrop), "early_drop")
+	ch <- prometheus.MustNewConstMetric(c.conntrackDrops, prometheus.CounterValue, float64(insertFailed), "insert_failed")
+
+	// nf_conntrack_max is global: any network namespace shows the host's value.
+	b, err := os.ReadFile(filepath.Join(c.procPath, "sys", "net", "netfilter", "nf_conntrack_max"))
+	if err != nil {
+		return err
+	}
+	limit, err := strconv.ParseFloat(strings.TrimSpace(string(b)), 64)
+	if err != nil {
+		return err
+	}
+	ch <- prometheus.MustNewConstMetric(c.conntrackLimit, prometheus.GaugeValue, limit)
+	return nil
+}
+
+func (c *NetstatCollector) collectTCPStates(ch chan<- prometheus.Metric) error {
+	counts := make(map[string]float64, len(tcpStates))
+	for _, name := range tcpStates {
+		counts[name] = 0 // export zeros, so absent() isn't needed in alerts
+	}
+	for _, read := range []func() (procfs.NetTCP, error){c.host.NetTCP, c.host.NetTCP6} {
+		sockets, err := read()
+		if errors.Is(err, os.ErrNotExist) {
+			continue // IPv6 disabled
+		}
+		if err != nil {
+			return err
+		}
+		for _, s := range sockets {
+			if name, ok := tcpStates[s.St]; ok {
+				counts[name]++
+			}
+		}
+	}
+	for state, n := range counts {
+		ch <- prometheus.MustNewConstMetric(c.tcpStates, prometheus.GaugeValue, n, state)
+	}
+	return nil
+}
+
+func (c *NetstatCollector) collectSockstat(ch chan<- prometheus.Metric) error {
+	stat, err := c.host.NetSockstat()
+	if err != nil {
+		return err
+	}
+	if stat.Used != nil {
+		ch <- prometheus.MustNewConstMetric(c.socketsUsed, prometheus.GaugeValue, float64(*stat.Used))
+	}
+	pageSize := float64(os.Getpagesize())
+	for _, p := range stat.Protocols {
+		proto := strings.ToLower(p.Protocol)
+		ch <- prometheus.MustNewConstMetric(c.sockets, prometheus.GaugeValue, float64(p.InUse), proto, "inuse")
+		for state, v := range map[string]*int{"orphan": p.Orphan, "tw": p.TW, "alloc": p.Alloc} {
+			if v != nil {
+				ch <- prometheus.MustNewConstMetric(c.sockets, prometheus.GaugeValue, float64(*v), proto, state)
+			}
+		}
+		// "mem" is in pages (TCP, UDP); FRAG reports "memory" in bytes.
+		switch {
+		case p.Mem != nil:
+			ch <- prometheus.MustNewConstMetric(c.socketMemory, prometheus.GaugeValue, float64(*p.Mem)*pageSize, proto)
+		case p.Memory != nil:
+			ch <- prometheus.MustNewConstMetric(c.socketMemory, prometheus.GaugeValue, float64(*p.Memory), proto)
+		}
+	}
+	return nil
+}
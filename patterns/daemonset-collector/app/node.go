@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"syscall"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/procfs"
+)
+
+func init() {
+	registerCollector("node", func() (Collector, error) {
+		// HOST_PROC points at the node's /proc (hostPath) and DISK_PATHS at
+		// hostPath mounts to measure.
+		return NewNodeCollector(getEnv("HOST_PROC", "/proc"), splitList(getEnv("DISK_PATHS", "/")))
+	})
+}
+
+// NodeCollector reads real node metrics from the host's /proc and from
+// statfs() on hostPath mounts. Values are read at scrape time instead of
+// being cached by a background loop.
+//
+// In the DaemonSet the host's /proc is mounted at HOST_PROC (e.g. /host/proc).
+// Network counters are read from PID 1's view of /proc so they describe the
+// node's network namespace, not the collector pod's.
+type NodeCollector struct {
+	proc      procfs.FS
+	diskPaths []string
+
+	cpuSeconds  *prometheus.Desc
+	memBytes    *prometheus.Desc
+	diskSize    *prometheus.Desc
+	diskFree    *prometheus.Desc
+	diskAvail   *prometheus.Desc
+	netRxBytes  *prometheus.Desc
+	netTxBytes  *prometheus.Desc
+	netRxErrors *prometheus.Desc
+	netTxErrors *prometheus.Desc
+}
+
+// NewNodeCollector creates a collector reading from the procfs mounted at
+// procPath and reporting filesystem usage for each of diskPaths.
+func NewNodeCollector(procPath string, diskPaths []string) (*NodeCollector, error) {
+	fs, err := procfs.NewFS(procPath)
+	if err != nil {
+		return nil, fmt.Errorf("opening procfs at %s: %w", procPath, err)
+	}
+
+	return &NodeCollector{
+		proc:      fs,
+		diskPaths: diskPaths,
+
+		cpuSeconds: prometheus.NewDesc("node_cpu_seconds_total",
+			"Seconds all CPUs spent in each mode.", []string{"mode"}, nil),
+		memBytes: prometheus.NewDesc("node_memory_bytes",
+			"Node memory from /proc/meminfo, by field.", []string{"field"}, nil),
+		diskSize: prometheus.NewDesc("node_filesystem_size_bytes",
+			"Filesystem size of a mounted host path.", []string{"path"}, nil),
+		diskFree: prometheus.NewDesc("node_filesystem_free_bytes",
+			"Free bytes, including those reserved for root.", []string{"path"}, nil),
+		diskAvail: prometheus.NewDesc("node_filesystem_avail_bytes",
+			"Bytes available to unprivileged users.", []string{"path"}, nil),
+		netRxBytes: prometheus.NewDesc("node_network_receive_bytes_total",
+			"Bytes received per interface.", []string{"device"}, nil),
+		netTxBytes: prometheus.NewDesc("node_network_transmit_bytes_total",
+			"Bytes transmitted per interface.", []string{"device"}, nil),
+		netRxErrors: prometheus.NewDesc("node_network_receive_errors_total",
+			"Receive errors per interface.", []string{"device"}, nil),
+		netTxErrors: prometheus.NewDesc("node_network_transmit_errors_total",
+			"Transmit errors per interface.", []string{"device"}, nil),
+	}, nil
+}
+
+// Name implements Collector.
+func (c *NodeCollector) Name() string { return "node" }
+
+// Start implements Collector; there is nothing to run in the background.
+func (c *NodeCollector) Start(context.Context) error { return nil }
+
+// Stop implements Collector.
+func (c *NodeCollector) Stop() error { return nil }
+
+// Describe implements Collector.
+func (c *NodeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.cpuSeconds
+	ch <- c.memBytes
+	ch <- c.diskSize
+	ch <- c.diskFree
+	ch <- c.diskAvail
+	ch <- c.netRxBytes
+	ch <- c.netTxBytes
+	ch <- c.netRxErrors
+	ch <- c.netTxErrors
+}
+
+// CollectInto implements Collector. A failing source is skipped so that,
+// say, a missing hostPath does not hide CPU and memory, but it still fails
+// the scrape.
+func (c *NodeCollector) CollectInto(ch chan<- prometheus.Metric) error {
+	var errs []error
+	if err := c.collectCPU(ch); err != nil {
+		errs = append(errs, fmt.Errorf("cpu: %w", err))
+	}
+	if err := c.collectMemory(ch); err != nil {
+		errs = append(errs, fmt.Errorf("memory: %w", err))
+	}
+	for _, path := range c.diskPaths {
+		if err := c.collectDisk(ch, path); err != nil {
+			errs = append(errs, fmt.Errorf("disk %s: %w", path, err))
+		}
+	}
+	if err := c.collectNetwork(ch); err != nil {
+		errs = append(errs, fmt.Errorf("network: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+func (c *NodeCollector) collectCPU(ch chan<- prometheus.Metric) error {
+	stat, err := c.proc.Stat()
+	if err != nil {
+		return err
+	}
+	cpu := stat.CPUTotal
+	for mode, v := range map[string]float64{
+		"user":    cpu.User,
+		"nice":    cpu.Nice,
+		"system":  cpu.System,
+		"idle":    cpu.Idle,
+		"iowait":  cpu.Iowait,
+		"irq":     cpu.IRQ,
+		"softirq": cpu.SoftIRQ,
+		"steal":   cpu.Steal,
+	} {
+		ch <- prometheus.MustNewConstMetric(c.cpuSeconds, prometheus.CounterValue, v, mode)
+	}
+	return nil
+}
+
+func (c *NodeCollector) collectMemory(ch chan<- prometheus.Metric) error {
+	mem, err := c.proc.Meminfo()
+	if err != nil {
+		return err
+	}
+	for field, v := range map[string]*uint64{
+		"MemTotal":     mem.MemTotalBytes,
+		"MemFree":      mem.MemFreeBytes,
+		"MemAvailable": mem.MemAvailableBytes,
+		"Buffers":      mem.BuffersBytes,
+		"Cached":       mem.CachedBytes,
+		"SwapTotal":    mem.SwapTotalBytes,
+		"SwapFree":     mem.SwapFreeBytes,
+	} {
+		// Older kernels omit some fields (e.g. MemAvailable before 3.14).
+		if v != nil {
+			ch <- prometheus.MustNewConstMetric(c.memBytes, prometheus.GaugeValue, float64(*v), field)
+		}
+	}
+	return nil
+}
+
+func (c *NodeCollector) collectDisk(ch chan<- prometheus.Metric, path string) error {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return err
+	}
+	bsize := float64(st.Bsize)
+	ch <- prometheus.MustNewConstMetric(c.diskSize, prometheus.GaugeValue, float64(st.Blocks)*bsize, path)
+	ch <- prometheus.MustNewConstMetric(c.diskFree, prometheus.GaugeValue, float64(st.Bfree)*bsize, path)
+	ch <- prometheus.MustNewConstMetric(c.diskAvail, prometheus.GaugeValue, float64(st.Bavail)*bsize, path)
+	return nil
+}
+
+func (c *NodeCollector) collectNetwork(ch chan<- prometheus.Metric) error {
+	// PID 1 on the host lives in the root network namespace.
+	init, err := c.proc.Proc(1)
+	if err != nil {
+		return err
+	}
+	dev, err := init.NetDev()
+	if err != nil {
+		return err
+	}
+	for name, line := range dev {
+		ch <- prometheus.MustNewConstMetric(c.netRxBytes, prometheus.CounterValue, float64(line.RxBytes), name)
+		ch <- prometheus.MustNewConstMetric(c.netTxBytes, prometheus.CounterValue, float64(line.TxBytes), name)
+		ch <- prometheus.MustNewConstMetric(c.netRxErrors, prometheus.CounterValue, float64(line.RxErrors), name)
+		ch <- prometheus.MustNewConstMetric(c.netTxErrors, prometheus.CounterValue, float64(line.TxErrors), name)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+func init() {
+	registerCollector("nodeinfo", func() (Collector, error) {
+		return NewNodeInfoCollector(os.Getenv("NODE_NAME"))
+	})
+}
+
+// NodeInfoCollector exports the metadata of this pod's Node as info metrics:
+// a constant 1 whose labels carry the information. Joined on k8s_node_name,
+// they let a dashboard group any collector metric by zone or instance type:
+//
+//	node_memory_bytes{field="MemAvailable"}
+//	  * on (k8s_node_name) group_left (zone, instance_type) node_info
+//
+// The Node is watched rather than read per scrape: a label or taint change
+// shows up on the next scrape, and the API server isn't hit by every node on
+// every scrape.
+type NodeInfoCollector struct {
+	Node   string
+	client kubernetes.Interface
+
+	cancel   context.CancelFunc
+	store    cache.Store
+	informer cache.Controller
+
+	info  *prometheus.Desc
+	taint *prometheus.Desc
+}
+
+// NewNodeInfoCollector builds an in-cluster client for the Node watch.
+func NewNodeInfoCollector(node string) (*NodeInfoCollector, error) {
+	if node == "" {
+		return nil, fmt.Errorf("NODE_NAME is required to export node info")
+	}
+	client, err := inClusterClient()
+	if err != nil {
+		return nil, err
+	}
+	return &NodeInfoCollector{
+		Node:   node,
+		client: client,
+		info: prometheus.NewDesc("node_info",
+			"Metadata of the Node this collector runs on; always 1.",
+			[]string{"zone", "region", "instance_type", "kernel_version", "os_image",
+				"container_runtime_version", "kubelet_version", "taints"}, nil),
+		taint: prometheus.NewDesc("node_taint",
+			"One series per taint on the Node; always 1.", []string{"key", "value", "effect"}, nil),
+	}, nil
+}
+
+// Name implements Collector.
+func (c *NodeInfoCollector) Name() string { return "nodeinfo" }
+
+// Describe implements Collector.
+func (c *NodeInfoCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.info
+	ch <- c.taint
+}
+
+// Start implements Collector: it watches this one Node until Stop.
+func (c *NodeInfoCollector) Start(ctx context.Context) error {
+	ctx, c.cancel = context.WithCancel(ctx)
+	lw := cache.NewListWatchFromClient(c.client.CoreV1().RESTClient(), "nodes", corev1.NamespaceAll,
+		fields.OneTermEqualSelector("metadata.name", c.Node))
+	// No handler: CollectInto reads the informer's store, which the watch
+	// keeps current.
+	c.store, c.informer = cache.NewInformerWithOptions(cache.InformerOptions{
+		ListerWatcher: lw,
+		ObjectType:    &corev1.Node{},
+		Handler:       cache.ResourceEventHandlerFuncs{},
+	})
+	go c.informer.Run(ctx.Done())
+	fmt.Printf("Watching Node %s for node_info\n", c.Node)
+	return nil
+}
+
+// Stop implements Collector.
+func (c *NodeInfoCollector) Stop() error {
+	if c.cancel != nil {
+		c.cancel()
+	}
+	return nil
+}
+
+// CollectInto implements Collector.
+func (c *NodeInfoCollector) CollectInto(ch chan<- prometheus.Metric) error {
+	if c.informer == nil || !c.informer.HasSynced() {
+		return fmt.Errorf("node watch not synced yet")
+	}
+	obj, ok, err := c.store.GetByKey(c.Node)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("node %s not found", c.Node)
+	}
+	node := obj.(*corev1.Node)
+
+	// Taints as one sorted label too, so node_info alone answers "which
+	// nodes are tainted?"; node_taint is easier to filter on.
+	taints := make([]string, 0, len(node.Spec.Taints))
+	for _, t := range node.Spec.Taints {
+		taints = append(taints, fmt.Sprintf("%s=%s:%s", t.Key, t.Value, t.Effect))
+		ch <- prometheus.MustNewConstMetric(c.taint, prometheus.GaugeValue, 1, t.Key, t.Value, string(t.Effect))
+	}
+	sort.Strings(taints)
+
+	labels, sys := node.Labels, node.Status.NodeInfo
+	ch <- prometheus.MustNewConstMetric(c.info, prometheus.GaugeValue, 1,
+		labels[corev1.LabelTopologyZone],
+		labels[corev1.LabelTopologyRegion],
+		labels[corev1.LabelInstanceTypeStable],
+		sys.KernelVersion,
+		sys.OSImage,
+		sys.ContainerRuntimeVersion,
+		sys.KubeletVersion,
+		strings.Join(taints, ","),
+	)
+	return nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	promotel "go.opentelemetry.io/contrib/bridges/prometheus"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// startOTLPPush pushes everything in gatherer to an OTLP/gRPC endpoint (an
+// OpenTelemetry Collector, usually) every interval. /metrics keeps working:
+// the bridge reads the same registry Prometheus scrapes, so the two paths
+// never disagree.
+//
+// The returned function flushes once more and stops the pusher; call it on
+// shutdown so the last interval isn't lost.
+func startOTLPPush(ctx context.Context, gatherer prometheus.Gatherer, endpoint string, interval time.Duration, insecure bool) (func(context.Context) error, error) {
+	// 1. The exporter speaks OTLP over gRPC. Endpoint is host:port, no scheme.
+	opts := []otlpmetricgrpc.Option{otlpmetricgrpc.WithEndpoint(endpoint)}
+	if insecure {
+		opts = append(opts, otlpmetricgrpc.WithInsecure())
+	}
+	exporter, err := otlpmetricgrpc.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("creating OTLP exporter: %w", err)
+	}
+
+	// 2. The bridge turns a Prometheus gather into OTel metric data. Downward
+	// API labels are already on every series, so they travel as attributes.
+	producer := promotel.NewMetricProducer(promotel.WithGatherer(gatherer))
+
+	// 3. A periodic reader collects from the producer and exports on a timer.
+	reader := sdkmetric.NewPeriodicReader(exporter,
+		sdkmetric.WithInterval(interval),
+		sdkmetric.WithProducer(producer),
+	)
+
+	// 4. The MeterProvider owns the reader's lifecycle. We don't create any
+	// OTel instruments ourselves; resource.Default() adds service.name etc.
+	// from OTEL_SERVICE_NAME / OTEL_RESOURCE_ATTRIBUTES.
+	provider := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(reader),
+		sdkmetric.WithResource(resource.Default()),
+	)
+	return provider.Shutdown, nil
+}
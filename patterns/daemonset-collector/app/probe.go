@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// Prober is the multi-target exporter pattern (blackbox_exporter style):
+// instead of exposing metrics about itself, the app checks whatever target
+// Prometheus passes in the URL and returns metrics about that one check.
+//
+//	GET /probe?target=https://example.com&module=http
+//	GET /probe?target=10.0.0.7:5432&module=tcp
+//	GET /probe?target=10.0.0.1&module=icmp
+//
+// Run from a DaemonSet, every node probes the same targets, so a failure on
+// one node (bad route, broken CNI, full conntrack table) stands out from a
+// target that is down everywhere.
+type Prober struct {
+	Timeout  time.Duration // upper bound; Prometheus' scrape timeout may lower it
+	Insecure bool          // skip TLS verification for https targets
+	labels   prometheus.Labels
+}
+
+// NewProber returns a prober whose metrics carry labels (the Downward API
+// identity), so results can be told apart by node.
+func NewProber(timeout time.Duration, insecure bool, labels prometheus.Labels) *Prober {
+	return &Prober{Timeout: timeout, Insecure: insecure, labels: labels}
+}
+
+// probeResult collects the metrics of one probe. A fresh registry per request
+// is the heart of the pattern: nothing leaks between targets and nothing is
+// kept in memory between scrapes.
+type probeResult struct {
+	reg prometheus.Registerer
+}
+
+func (r probeResult) gauge(name, help string, value float64) {
+	g := prometheus.NewGauge(prometheus.GaugeOpts{Name: name, Help: help})
+	g.Set(value)
+	r.reg.MustRegister(g)
+}
+
+// ServeHTTP implements http.Handler.
+func (p *Prober) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	target := r.URL.Query().Get("target")
+	if target == "" {
+		http.Error(w, "target parameter is missing", http.StatusBadRequest)
+		return
+	}
+	module := r.URL.Query().Get("module")
+	if module == "" {
+		module = "http"
+	}
+	var probe func(context.Context, string, probeResult) error
+	switch module {
+	case "http":
+		probe = p.probeHTTP
+	case "tcp":
+		probe = p.probeTCP
+	case "icmp":
+		probe = p.probeICMP
+	default:
+		http.Error(w, fmt.Sprintf("unknown module %q (want http, tcp or icmp)", module), http.StatusBadRequest)
+		return
+	}
+
+	// Finish before Prometheus gives up on us, or the whole scrape is lost
+	// instead of reporting probe_success 0.
+	timeout := p.Timeout
+	if s, err := strconv.ParseFloat(r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds"), 64); err == nil {
+		timeout = min(timeout, time.Duration((s-0.5)*float64(time.Second)))
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	registry := prometheus.NewRegistry()
+	res := probeResult{reg: prometheus.WrapRegistererWith(p.labels, registry)}
+	start := time.Now()
+	err := probe(ctx, target, res)
+	res.gauge("probe_duration_seconds", "How long the probe took.", time.Since(start).Seconds())
+	success := 1.0
+	if err != nil {
+		success = 0
+		fmt.Printf("probe %s %s: %v\n", module, target, err)
+	}
+	res.gauge("probe_success", "Whether the probe succeeded.", success)
+
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
+
+func (p *Prober) probeHTTP(ctx context.Context, target string, res probeResult) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	var dnsStart, dnsDone, connDone, firstByte time.Time
+	start := time.Now()
+	req = req.WithContext(httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart:             func(httptrace.DNSStartInfo) { dnsStart = time.Now() },
+		DNSDone:              func(httptrace.DNSDoneInfo) { dnsDone = time.Now() },
+		ConnectDone:          func(string, string, error) { connDone = time.Now() },
+		GotFirstResponseByte: func() { firstByte = time.Now() },
+	}))
+
+	// A new transport per probe: a pooled keep-alive connection would skip
+	// DNS, connect and TLS and make the probe measure nothing.
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: p.Insecure},
+		DisableKeepAlives: true,
+		Proxy:             http.ProxyFromEnvironment,
+	}}
+	resp, err := client.Do(req)
+	if !dnsStart.IsZero() && !dnsDone.IsZero() {
+		res.gauge("probe_dns_lookup_time_seconds", "Time spent resolving the target's name.", dnsDone.Sub(dnsStart).Seconds())
+	}
+	if !connDone.IsZero() {
+		res.gauge("probe_http_connect_seconds", "Time until the TCP connection was established.", connDone.Sub(start).Seconds())
+	}
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if !firstByte.IsZero() {
+		res.gauge("probe_http_first_byte_seconds", "Time until the first response byte.", firstByte.Sub(start).Seconds())
+	}
+	res.gauge("probe_http_status_code", "HTTP status code of the response.", float64(resp.StatusCode))
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		res.gauge("probe_ssl_earliest_cert_expiry", "When the first certificate in the served chain expires, as a unix timestamp.",
+			float64(resp.TLS.PeerCertificates[0].NotAfter.Unix()))
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 399 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (p *Prober) probeTCP(ctx context.Context, target string, res probeResult) error {
+	start := time.Now()
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", target)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	res.gauge("probe_tcp_connect_seconds", "Time until the TCP connection was established.", time.Since(start).Seconds())
+	return nil
+}
+
+// probeICMP sends one echo request. It first tries an unprivileged ping
+// socket (needs net.ipv4.ping_group_range to include our GID), then a raw
+// socket (needs CAP_NET_RAW).
+func (p *Prober) probeICMP(ctx context.Context, target string, res probeResult) error {
+	ip, err := net.DefaultResolver.LookupIPAddr(ctx, target)
+	if err != nil {
+		return err
+	}
+	var dst net.IP
+	for _, a := range ip {
+		if a.IP.To4() != nil {
+			dst = a.IP
+			break
+		}
+	}
+	if dst == nil {
+		return fmt.Errorf("no IPv4 address for %s", target)
+	}
+
+	network, addr := "udp4", net.Addr(&net.UDPAddr{IP: dst})
+	conn, err := icmp.ListenPacket(network, "0.0.0.0")
+	if err != nil {
+		network, addr = "ip4:icmp", &net.IPAddr{IP: dst}
+		if conn, err = icmp.ListenPacket(network, "0.0.0.0"); err != nil {
+			return fmt.Errorf("no ICMP socket (allow ping_group_range or add CAP_NET_RAW): %w", err)
+		}
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	id, seq := os.Getpid()&0xffff, rand.Intn(0xffff)
+	msg, err := (&icmp.Message{
+		Type: ipv4.ICMPTypeEcho,
+		Body: &icmp.Echo{ID: id, Seq: seq, Data: []byte("node-collector")},
+	}).Marshal(nil)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	if _, err := conn.WriteTo(msg, addr); err != nil {
+		return err
+	}
+
+	buf := make([]byte, 1500)
+	for {
+		n, from, err := conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		reply, err := icmp.ParseMessage(1, buf[:n]) // 1 = ICMP for IPv4
+		if err != nil || reply.Type != ipv4.ICMPTypeEchoReply {
+			continue
+		}
+		// Raw sockets see every reply on the host; ping sockets rewrite the ID.
+		echo, ok := reply.Body.(*icmp.Echo)
+		if !ok || echo.Seq != seq || (network == "ip4:icmp" && echo.ID != id) {
+			continue
+		}
+		if fromIP := addrIP(from); fromIP != nil && !fromIP.Equal(dst) {
+			continue
+		}
+		res.gauge("probe_icmp_rtt_seconds", "Round-trip time of the echo request.", time.Since(start).Seconds())
+		return nil
+	}
+}
+
+func addrIP(a net.Addr) net.IP {
+	switch a := a.(type) {
+	case *net.UDPAddr:
+		return a.IP
+	case *net.IPAddr:
+		return a.IP
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// MetricPusher sends one snapshot of the registry somewhere. It is the
+// fallback for clusters where nothing can scrape DaemonSet pods (locked-down
+// network policies, no Prometheus in-cluster, a SaaS TSDB).
+type MetricPusher interface {
+	Push(ctx context.Context) error
+}
+
+// NewMetricPusher returns the pusher for mode: "pushgateway" or "remote_write".
+func NewMetricPusher(mode, url, job, instance string, gatherer prometheus.Gatherer) (MetricPusher, error) {
+	switch mode {
+	case "pushgateway":
+		return &PushgatewayPusher{Pusher: push.New(url, job).Gatherer(gatherer).Grouping("instance", instance)}, nil
+	case "remote_write":
+		return &RemoteWritePusher{URL: url, Gatherer: gatherer, Client: &http.Client{Timeout: 10 * time.Second}}, nil
+	}
+	return nil, fmt.Errorf("unknown push mode %q (want pushgateway or remote_write)", mode)
+}
+
+// runPusher pushes every interval until ctx is cancelled.
+func runPusher(ctx context.Context, p MetricPusher, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pushCtx, cancel := context.WithTimeout(ctx, interval)
+			if err := p.Push(pushCtx); err != nil {
+				fmt.Printf("push: %v\n", err)
+			}
+			cancel()
+		}
+	}
+}
+
+// PushgatewayPusher replaces this pod's group on a Prometheus Pushgateway.
+// Each pod pushes under grouping key instance=<node>, so pods don't overwrite
+// each other. The Pushgateway never forgets a group on its own: when a node
+// goes away its last values stay until someone deletes them.
+type PushgatewayPusher struct {
+	Pusher *push.Pusher
+}
+
+func (p *PushgatewayPusher) Push(ctx context.Context) error {
+	return p.Pusher.PushContext(ctx)
+}
+
+// RemoteWritePusher speaks Prometheus remote_write (protocol 1.0) directly to
+// a TSDB: Prometheus with --web.enable-remote-write-receiver, Mimir, Thanos
+// Receive, VictoriaMetrics, ... No Pushgateway in between, and samples carry
+// their own timestamps.
+type RemoteWritePusher struct {
+	URL      string
+	Gatherer prometheus.Gatherer
+	Client   *http.Client
+}
+
+func (p *RemoteWritePusher) Push(ctx context.Context) error {
+	families, err := p.Gatherer.Gather()
+	if err != nil {
+		return fmt.Errorf("gathering: %w", err)
+	}
+	body := snappy.Encode(nil, encodeWriteRequest(families, time.Now().UnixMilli()))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s returned %s", p.URL, resp.Status)
+	}
+	return nil
+}
+
+// encodeWriteRequest flattens metric families into remote_write time series
+// the same way the text format does (histograms become _bucket/_sum/_count,
+// summaries become quantiles plus _sum/_count) and encodes a prompb
+// WriteRequest by hand:
+//
+//	WriteRequest { repeated TimeSeries timeseries = 1; }
+//	TimeSeries   { repeated Label labels = 1; repeated Sample samples = 2; }
+//	Label        { string name = 1; string value = 2; }
+//	Sample       { double value = 1; int64 timestamp = 2; }
+func encodeWriteRequest(families []*dto.MetricFamily, nowMs int64) []byte {
+	var out []byte
+	for _, mf := range families {
+		name := mf.GetName()
+		for _, m := range mf.GetMetric() {
+			ts := nowMs
+			if m.TimestampMs != nil {
+				ts = m.GetTimestampMs()
+			}
+			series := func(suffix string, value float64, extra ...string) {
+				out = protowire.AppendTag(out, 1, protowire.BytesType)
+				out = protowire.AppendBytes(out, encodeTimeSeries(name+suffix, m.GetLabel(), extra, value, ts))
+			}
+
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				series("", m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				series("", m.GetGauge().GetValue())
+			case dto.MetricType_UNTYPED:
+				series("", m.GetUntyped().GetValue())
+			case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE_HISTOGRAM:
+				h := m.GetHistogram()
+				for _, b := range h.GetBucket() {
+					if math.IsInf(b.GetUpperBound(), +1) {
+						continue // emitted below from the sample count
+					}
+					series("_bucket", float64(b.GetCumulativeCount()), "le", formatFloat(b.GetUpperBound()))
+				}
+				series("_bucket", float64(h.GetSampleCount()), "le", "+Inf")
+				series("_sum", h.GetSampleSum())
+				series("_count", float64(h.GetSampleCount()))
+			case dto.MetricType_SUMMARY:
+				s := m.GetSummary()
+				for _, q := range s.GetQuantile() {
+					series("", q.GetValue(), "quantile", formatFloat(q.GetQuantile()))
+				}
+				series("_sum", s.GetSampleSum())
+				series("_count", float64(s.GetSampleCount()))
+			}
+		}
+	}
+	return out
+}
+
+func encodeTimeSeries(name string, labels []*dto.LabelPair, extra []string, value float64, ts int64) []byte {
+	pairs := [][2]string{{"__name__", name}}
+	for _, l := range labels {
+		pairs = append(pairs, [2]string{l.GetName(), l.GetValue()})
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, [2]string{extra[i], extra[i+1]})
+	}
+	// Receivers require labels sorted by name.
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i][0] < pairs[j][0] })
+
+	var b []byte
+	for _, p := range pairs {
+		var label []byte
+		label = protowire.AppendTag(label, 1, protowire.BytesType)
+		label = protowire.AppendString(label, p[0])
+		label = protowire.AppendTag(label, 2, protowire.BytesType)
+		label = protowire.AppendString(label, p[1])
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendBytes(b, label)
+	}
+
+	var sample []byte
+	sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+	sample = protowire.AppendFixed64(sample, math.Float64bits(value))
+	sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+	sample = protowire.AppendVarint(sample, uint64(ts))
+	b = protowire.AppendTag(b, 2, protowire.BytesType)
+	return protowire.AppendBytes(b, sample)
+}
+
+// formatFloat renders le/quantile values the way the exposition format does.
+func formatFloat(f float64) string {
+	if math.IsInf(f, +1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
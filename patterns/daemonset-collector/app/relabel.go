@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
+	"sigs.k8s.io/yaml"
+)
+
+// RelabelRule is one step of exporter-side relabeling, with the same fields
+// and semantics as a Prometheus metric_relabel_configs entry:
+//
+//   - action: drop
+//     source_labels: [__name__]
+//     regex: go_gc_.*
+//
+// Supported actions: replace (default), keep, drop, labeldrop, labelkeep.
+type RelabelRule struct {
+	SourceLabels []string `json:"source_labels"`
+	Separator    *string  `json:"separator"`
+	Regex        string   `json:"regex"`
+	TargetLabel  string   `json:"target_label"`
+	Replacement  *string  `json:"replacement"`
+	Action       string   `json:"action"`
+
+	re *regexp.Regexp
+}
+
+var (
+	labelNameRE  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+	metricNameRE = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+)
+
+// compile fills in the defaults and checks the rule.
+func (r *RelabelRule) compile() error {
+	if r.Action == "" {
+		r.Action = "replace"
+	}
+	if r.Regex == "" {
+		r.Regex = "(.*)"
+	}
+	if r.Separator == nil {
+		sep := ";"
+		r.Separator = &sep
+	}
+	if r.Replacement == nil {
+		repl := "$1"
+		r.Replacement = &repl
+	}
+	re, err := regexp.Compile("^(?:" + r.Regex + ")$") // anchored, as in Prometheus
+	if err != nil {
+		return fmt.Errorf("bad regex %q: %w", r.Regex, err)
+	}
+	r.re = re
+
+	switch r.Action {
+	case "replace":
+		if !labelNameRE.MatchString(r.TargetLabel) {
+			return fmt.Errorf("replace needs a valid target_label, got %q", r.TargetLabel)
+		}
+		fallthrough
+	case "keep", "drop":
+		if len(r.SourceLabels) == 0 {
+			return fmt.Errorf("%s needs source_labels", r.Action)
+		}
+	case "labeldrop", "labelkeep":
+	default:
+		return fmt.Errorf("unsupported action %q", r.Action)
+	}
+	return nil
+}
+
+// apply relabels one series in place; false means drop it.
+func (r *RelabelRule) apply(labels map[string]string) bool {
+	values := make([]string, len(r.SourceLabels))
+	for i, name := range r.SourceLabels {
+		values[i] = labels[name]
+	}
+	value := strings.Join(values, *r.Separator)
+
+	switch r.Action {
+	case "keep":
+		return r.re.MatchString(value)
+	case "drop":
+		return !r.re.MatchString(value)
+	case "replace":
+		match := r.re.FindStringSubmatchIndex(value)
+		if match == nil {
+			return true
+		}
+		result := string(r.re.ExpandString(nil, *r.Replacement, value, match))
+		switch {
+		case r.TargetLabel == "__name__":
+			if metricNameRE.MatchString(result) {
+				labels["__name__"] = result
+			}
+		case result == "":
+			delete(labels, r.TargetLabel)
+		default:
+			labels[r.TargetLabel] = result
+		}
+	case "labeldrop", "labelkeep":
+		for name := range labels {
+			if name != "__name__" && r.re.MatchString(name) == (r.Action == "labeldrop") {
+				delete(labels, name)
+			}
+		}
+	}
+	return true
+}
+
+// LoadRelabelRules builds the rule list: METRICS_ALLOW and METRICS_DENY
+// (regexes on the metric name) first, then the rules in the RELABEL_CONFIG
+// file, a YAML list in metric_relabel_configs format.
+func LoadRelabelRules(allow, deny, configFile string) ([]*RelabelRule, error) {
+	var rules []*RelabelRule
+	if allow != "" {
+		rules = append(rules, &RelabelRule{Action: "keep", SourceLabels: []string{"__name__"}, Regex: allow})
+	}
+	if deny != "" {
+		rules = append(rules, &RelabelRule{Action: "drop", SourceLabels: []string{"__name__"}, Regex: deny})
+	}
+	if configFile != "" {
+		b, err := os.ReadFile(configFile)
+		if err != nil {
+			return nil, err
+		}
+		var fromFile []*RelabelRule
+		if err := yaml.UnmarshalStrict(b, &fromFile); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", configFile, err)
+		}
+		rules = append(rules, fromFile...)
+	}
+	for i, r := range rules {
+		if err := r.compile(); err != nil {
+			return nil, fmt.Errorf("relabel rule %d: %w", i, err)
+		}
+	}
+	return rules, nil
+}
+
+// relabelGatherer applies relabel rules to everything gathered from the
+// registry, before it is exposed on /metrics or pushed. Cutting series here
+// is the cheapest place to do it: they are never serialized, sent, or
+// stored, on any of the N nodes.
+//
+// Rules see the metric family name as __name__: a histogram is matched as
+// myapp_request_duration_seconds, not as its _bucket/_sum/_count series.
+type relabelGatherer struct {
+	gatherer prometheus.Gatherer
+	rules    []*RelabelRule
+	dropped  prometheus.Gauge
+}
+
+func newRelabelGatherer(g prometheus.Gatherer, rules []*RelabelRule, reg prometheus.Registerer) *relabelGatherer {
+	dropped := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "metrics_relabel_dropped_series",
+		Help: "Series removed by relabel rules on the previous gather, including duplicates left after dropping labels.",
+	})
+	reg.MustRegister(dropped)
+	return &relabelGatherer{gatherer: g, rules: rules, dropped: dropped}
+}
+
+// Gather implements prometheus.Gatherer.
+func (g *relabelGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.gatherer.Gather()
+	if len(g.rules) == 0 {
+		return families, err
+	}
+
+	byName := map[string]*dto.MetricFamily{}
+	seen := map[string]bool{} // name + labels, to catch series that became identical
+	var dropped float64
+	var errs prometheus.MultiError
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	for _, mf := range families {
+		for _, m := range mf.Metric {
+			labels := map[string]string{"__name__": mf.GetName()}
+			for _, lp := range m.Label {
+				labels[lp.GetName()] = lp.GetValue()
+			}
+			keep := true
+			for _, r := range g.rules {
+				if keep = r.apply(labels); !keep {
+					break
+				}
+			}
+			if !keep {
+				dropped++
+				continue
+			}
+
+			name := labels["__name__"]
+			delete(labels, "__name__")
+			out := proto.Clone(m).(*dto.Metric)
+			out.Label = out.Label[:0]
+			names := make([]string, 0, len(labels))
+			for n := range labels {
+				names = append(names, n)
+			}
+			sort.Strings(names)
+			key := name
+			for _, n := range names {
+				out.Label = append(out.Label, &dto.LabelPair{Name: proto.String(n), Value: proto.String(labels[n])})
+				key += "\xff" + n + "\xff" + labels[n]
+			}
+			if seen[key] {
+				dropped++
+				continue
+			}
+			seen[key] = true
+
+			target, ok := byName[name]
+			if !ok {
+				target = &dto.MetricFamily{Name: proto.String(name), Help: mf.Help, Type: mf.Type, Unit: mf.Unit}
+				byName[name] = target
+			} else if target.GetType() != mf.GetType() {
+				errs = append(errs, fmt.Errorf("relabeling renamed %s to %s, which already exists as a %s", mf.GetName(), name, target.GetType()))
+				continue
+			}
+			target.Metric = append(target.Metric, out)
+		}
+	}
+	g.dropped.Set(dropped)
+
+	out := make([]*dto.MetricFamily, 0, len(byName))
+	for _, mf := range byName {
+		out = append(out, mf)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].GetName() < out[j].GetName() })
+	return out, errs.MaybeUnwrap()
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// The simulated workload exists to show which metric type fits which question:
+//
+//	Counter    "how many?"           -> myapp_processed_ops_total, myapp_requests_total
+//	Gauge      "how many right now?" -> myapp_queue_depth
+//	Histogram  "how slow, in p99?"   -> myapp_request_duration_seconds (aggregatable)
+//	Summary    "how slow, here?"     -> myapp_request_duration_summary_seconds (not aggregatable)
+type simulation struct {
+	opsProcessed    prometheus.Counter
+	requests        *prometheus.CounterVec
+	latency         prometheus.Histogram
+	latencySummary  prometheus.Summary
+	queueDepth      prometheus.Gauge
+	queueCapacity   int
+	arrivalsPerTick int
+	opsSchedule     schedule
+	tracer          trace.Tracer
+}
+
+// newSimulation registers the simulated metrics with reg. Each simulated
+// request is also a span from tracer, and its observations carry the trace ID
+// as an exemplar.
+func newSimulation(reg prometheus.Registerer, tracer trace.Tracer) *simulation {
+	f := promauto.With(reg)
+	return &simulation{
+		// 1. Counter: only ever goes up. Use rate() on it.
+		opsProcessed: f.NewCounter(prometheus.CounterOpts{
+			Name: "myapp_processed_ops_total",
+			Help: "The total number of processed operations",
+		}),
+
+		// 2. Labeled counter: one series per label combination. Keep label
+		// values bounded (methods, status codes) — never user IDs or URLs.
+		requests: f.NewCounterVec(prometheus.CounterOpts{
+			Name: "myapp_requests_total",
+			Help: "Simulated requests by method and status code.",
+		}, []string{"method", "code"}),
+
+		// 3. Histogram: counts observations into buckets. Buckets from many
+		// pods can be summed, so histogram_quantile() works fleet-wide.
+		// NativeHistogramBucketFactor additionally exposes a native
+		// (sparse, exponential) histogram when the scraper negotiates
+		// protobuf; classic buckets are kept for everyone else.
+		latency: f.NewHistogram(prometheus.HistogramOpts{
+			Name:                            "myapp_request_duration_seconds",
+			Help:                            "Simulated request latency.",
+			Buckets:                         []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+			NativeHistogramBucketFactor:     1.1,
+			NativeHistogramMaxBucketNumber:  100,
+			NativeHistogramMinResetDuration: time.Hour,
+		}),
+
+		// 4. Summary: quantiles computed inside this process. Cheap to query
+		// but they can NOT be averaged across pods — prefer histograms.
+		latencySummary: f.NewSummary(prometheus.SummaryOpts{
+			Name:       "myapp_request_duration_summary_seconds",
+			Help:       "Simulated request latency as client-side quantiles.",
+			Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
+			MaxAge:     time.Minute,
+		}),
+
+		// 5. Gauge: a value that goes up and down. Read it directly (or
+		// max_over_time); rate() on a gauge is meaningless.
+		queueDepth: f.NewGauge(prometheus.GaugeOpts{
+			Name: "myapp_queue_depth",
+			Help: "Items waiting in the simulated work queue.",
+		}),
+
+		queueCapacity:   500,
+		arrivalsPerTick: 10,
+		opsSchedule:     schedule{Interval: opsInterval(), Jitter: collectorJitter()},
+		tracer:          tracer,
+	}
+}
+
+func opsInterval() time.Duration {
+	d, err := time.ParseDuration(getEnv("SIM_OPS_INTERVAL", "2s"))
+	if err != nil || d <= 0 {
+		return 2 * time.Second
+	}
+	return d
+}
+
+// requestLatency draws from a log-normal around ~40ms with a slow tail, which
+// is what real service latency tends to look like.
+func requestLatency() float64 {
+	d := math.Exp(rand.NormFloat64()*0.6 + math.Log(0.04))
+	if rand.Intn(100) < 3 {
+		d += 0.5 + rand.Float64()*1.5 // 3% hit a slow dependency
+	}
+	return d
+}
+
+// run drives all metrics until the process exits.
+func (s *simulation) run() {
+	// Background operations, every SIM_OPS_INTERVAL (default 2s).
+	go s.opsSchedule.run(context.Background(), func(context.Context) {
+		s.opsProcessed.Inc() // Increment the counter
+	})
+
+	// Requests: a steady trickle with method/status mix. Each one is a span,
+	// and the histogram and counter get an exemplar with its trace ID: the
+	// link from "p99 went up" to "here is one of the slow requests".
+	go func() {
+		methods := []string{"GET", "GET", "GET", "POST", "DELETE"}
+		for {
+			d := requestLatency()
+			code := http.StatusOK
+			switch r := rand.Intn(100); {
+			case r < 2:
+				code = http.StatusInternalServerError
+			case r < 7:
+				code = http.StatusNotFound
+			}
+			method := methods[rand.Intn(len(methods))]
+
+			end := time.Now()
+			ctx, span := s.tracer.Start(context.Background(), method+" /simulated",
+				trace.WithTimestamp(end.Add(-time.Duration(d*float64(time.Second)))),
+				trace.WithAttributes(attribute.String("http.request.method", method), attribute.Int("http.response.status_code", code)))
+			span.End(trace.WithTimestamp(end))
+
+			// Exemplars only make it out over OpenMetrics or protobuf; the
+			// summary has no exemplar support at all.
+			exemplar := traceExemplar(ctx)
+			s.latency.(prometheus.ExemplarObserver).ObserveWithExemplar(d, exemplar)
+			s.latencySummary.Observe(d)
+			s.requests.WithLabelValues(method, strconv.Itoa(code)).(prometheus.ExemplarAdder).AddWithExemplar(1, exemplar)
+			time.Sleep(100 * time.Millisecond)
+		}
+	}()
+
+	// Queue: bursty producer, steady consumer. Depth drifts up during bursts
+	// and drains afterwards, which makes a nice sawtooth on a dashboard.
+	go func() {
+		depth := 0
+		for tick := 0; ; tick++ {
+			arrivals := rand.Intn(s.arrivalsPerTick)
+			if tick%60 < 10 {
+				arrivals *= 4 // a burst every minute
+			}
+			depth = min(depth+arrivals, s.queueCapacity)
+			depth = max(depth-s.arrivalsPerTick/2, 0)
+			s.queueDepth.Set(float64(depth))
+			time.Sleep(time.Second)
+		}
+	}()
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	registerCollector("statsd", func() (Collector, error) {
+		return NewStatsDAdapter(getEnv("STATSD_ADDR", ":8125")), nil
+	})
+}
+
+// StatsDAdapter is the adapter pattern applied to metrics: applications that
+// only speak StatsD (fire-and-forget UDP, push) send to the DaemonSet on their
+// node, and we aggregate and re-expose everything in Prometheus format (pull).
+//
+// Supported lines, optionally several per packet separated by '\n':
+//
+//	name:1|c|@0.1        counter, sample rate 10%
+//	name:42|g            gauge set;  name:+3|g / name:-3|g adjust it
+//	name:320|ms          timer (also h and d): observed into a histogram, in seconds
+//	name:1|c|#env:prod   DogStatsD tags become labels
+type StatsDAdapter struct {
+	Addr string
+	conn net.PacketConn
+
+	mu     sync.Mutex
+	series map[string]*statsdSeries // by name + sorted labels
+	shapes map[string]statsdShape   // by name: first type/label set seen wins
+
+	packets     prometheus.Counter
+	lines       *prometheus.CounterVec
+	parseErrors prometheus.Counter
+	dropped     *prometheus.CounterVec
+}
+
+type statsdShape struct {
+	kind   string // c, g or ms
+	labels string // comma-joined label names
+}
+
+type statsdSeries struct {
+	name        string
+	kind        string
+	labelNames  []string
+	labelValues []string
+
+	value   float64            // counter total or gauge value
+	count   uint64             // timer observations
+	sum     float64            // timer sum, seconds
+	buckets map[float64]uint64 // timer cumulative bucket counts
+}
+
+// statsdBuckets cover 1ms to 10s, the range StatsD timers usually live in.
+var statsdBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// NewStatsDAdapter creates an adapter; call Start to begin listening.
+func NewStatsDAdapter(addr string) *StatsDAdapter {
+	return &StatsDAdapter{
+		Addr:   addr,
+		series: map[string]*statsdSeries{},
+		shapes: map[string]statsdShape{},
+
+		packets: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "statsd_packets_total",
+			Help: "UDP packets received by the StatsD adapter.",
+		}),
+		lines: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "statsd_lines_total",
+			Help: "StatsD lines accepted, by StatsD type.",
+		}, []string{"type"}),
+		parseErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "statsd_parse_errors_total",
+			Help: "StatsD lines that could not be parsed.",
+		}),
+		dropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "statsd_dropped_total",
+			Help: "Valid StatsD lines that could not be mapped to a Prometheus series.",
+		}, []string{"reason"}),
+	}
+}
+
+// Name implements Collector.
+func (a *StatsDAdapter) Name() string { return "statsd" }
+
+// Start implements Collector. Binding happens here so a port conflict is
+// reported at startup rather than silently dropping packets.
+func (a *StatsDAdapter) Start(context.Context) error {
+	conn, err := net.ListenPacket("udp", a.Addr)
+	if err != nil {
+		return err
+	}
+	a.conn = conn
+	go a.serve()
+	fmt.Printf("Accepting StatsD on udp %s\n", a.Addr)
+	return nil
+}
+
+// Stop implements Collector.
+func (a *StatsDAdapter) Stop() error {
+	if a.conn == nil {
+		return nil
+	}
+	return a.conn.Close()
+}
+
+// serve reads packets until the socket is closed.
+func (a *StatsDAdapter) serve() {
+	buf := make([]byte, 65535)
+	for {
+		n, _, err := a.conn.ReadFrom(buf)
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			fmt.Printf("statsd: %v\n", err)
+			continue
+		}
+		a.packets.Inc()
+		for _, line := range strings.Split(string(buf[:n]), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				a.handleLine(line)
+			}
+		}
+	}
+}
+
+func (a *StatsDAdapter) handleLine(line string) {
+	name, value, kind, rate, tags, err := parseStatsDLine(line)
+	if err != nil {
+		a.parseErrors.Inc()
+		return
+	}
+	if kind == "s" {
+		// Sets (unique counts) need per-interval state Prometheus has no type for.
+		a.dropped.WithLabelValues("unsupported_type").Inc()
+		return
+	}
+	if kind == "h" || kind == "d" {
+		kind = "ms"
+	}
+
+	name = sanitizeMetricName(name)
+	labelNames := make([]string, 0, len(tags))
+	for k := range tags {
+		labelNames = append(labelNames, k)
+	}
+	sort.Strings(labelNames)
+	labelValues := make([]string, len(labelNames))
+	for i, k := range labelNames {
+		labelValues[i] = tags[k]
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// Prometheus requires every series of a metric to have the same type and
+	// label names. StatsD doesn't, so the first shape seen for a name wins.
+	shape := statsdShape{kind: kind, labels: strings.Join(labelNames, ",")}
+	if prev, ok := a.shapes[name]; !ok {
+		a.shapes[name] = shape
+	} else if prev != shape {
+		a.dropped.WithLabelValues("inconsistent_shape").Inc()
+		return
+	}
+
+	key := name + "\xff" + strings.Join(labelValues, "\xff")
+	s, ok := a.series[key]
+	if !ok {
+		s = &statsdSeries{name: name, kind: kind, labelNames: labelNames, labelValues: labelValues}
+		if kind == "ms" {
+			s.buckets = map[float64]uint64{}
+			for _, b := range statsdBuckets {
+				s.buckets[b] = 0
+			}
+		}
+		a.series[key] = s
+	}
+
+	switch kind {
+	case "c":
+		s.value += value / rate
+	case "g":
+		// An explicit sign makes the gauge relative: "+3" adds, "-3" subtracts.
+		if _, raw, _ := strings.Cut(line, ":"); strings.HasPrefix(raw, "+") || strings.HasPrefix(raw, "-") {
+			s.value += value
+		} else {
+			s.value = value
+		}
+	case "ms":
+		seconds := value / 1000
+		// A sampled timer stands for 1/rate observations.
+		n := uint64(1/rate + 0.5)
+		s.count += n
+		s.sum += seconds * float64(n)
+		for _, b := range statsdBuckets {
+			if seconds <= b {
+				s.buckets[b] += n
+			}
+		}
+	}
+	a.lines.WithLabelValues(kind).Inc()
+}
+
+// Describe implements Collector. It sends nothing: the metric set is only
+// known at runtime, which makes this an unchecked collector.
+func (a *StatsDAdapter) Describe(chan<- *prometheus.Desc) {}
+
+// CollectInto implements Collector. A series Prometheus refuses is dropped
+// and counted, rather than failing the scrape: it came from a datagram, and
+// anyone on the node can send one.
+func (a *StatsDAdapter) CollectInto(ch chan<- prometheus.Metric) error {
+	a.packets.Collect(ch)
+	a.lines.Collect(ch)
+	a.parseErrors.Collect(ch)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for key, s := range a.series {
+		help := "StatsD metric " + s.name + " received by the adapter."
+		var m prometheus.Metric
+		var err error
+		switch s.kind {
+		case "c":
+			desc := prometheus.NewDesc(s.name+"_total", help, s.labelNames, nil)
+			m, err = prometheus.NewConstMetric(desc, prometheus.CounterValue, s.value, s.labelValues...)
+		case "g":
+			desc := prometheus.NewDesc(s.name, help, s.labelNames, nil)
+			m, err = prometheus.NewConstMetric(desc, prometheus.GaugeValue, s.value, s.labelValues...)
+		case "ms":
+			desc := prometheus.NewDesc(s.name+"_seconds", help, s.labelNames, nil)
+			buckets := make(map[float64]uint64, len(s.buckets))
+			for k, v := range s.buckets {
+				buckets[k] = v
+			}
+			m, err = prometheus.NewConstHistogram(desc, s.count, s.sum, buckets, s.labelValues...)
+		}
+		if err != nil {
+			delete(a.series, key)
+			a.dropped.WithLabelValues("invalid_series").Inc()
+			continue
+		}
+		ch <- m
+	}
+	a.dropped.Collect(ch)
+	return nil
+}
+
+// parseStatsDLine parses "name:value|type[|@rate][|#k:v,k2:v2]".
+func parseStatsDLine(line string) (name string, value float64, kind string, rate float64, tags map[string]string, err error) {
+	name, rest, ok := strings.Cut(line, ":")
+	if !ok || name == "" {
+		return "", 0, "", 0, nil, fmt.Errorf("missing name in %q", line)
+	}
+	// Prometheus refuses label values that aren't UTF-8, and names are
+	// sanitized byte by byte: check the whole line once.
+	if !utf8.ValidString(line) {
+		return "", 0, "", 0, nil, fmt.Errorf("invalid UTF-8 in %q", line)
+	}
+	fields := strings.Split(rest, "|")
+	if len(fields) < 2 {
+		return "", 0, "", 0, nil, fmt.Errorf("missing type in %q", line)
+	}
+	kind = fields[1]
+	switch kind {
+	case "c", "g", "ms", "h", "d", "s":
+	default:
+		return "", 0, "", 0, nil, fmt.Errorf("unknown type %q", kind)
+	}
+	if kind != "s" {
+		if value, err = strconv.ParseFloat(fields[0], 64); err != nil {
+			return "", 0, "", 0, nil, err
+		}
+	}
+
+	rate, tags = 1, map[string]string{}
+	for _, f := range fields[2:] {
+		switch {
+		case strings.HasPrefix(f, "@"):
+			if rate, err = strconv.ParseFloat(f[1:], 64); err != nil || rate <= 0 || rate > 1 {
+				return "", 0, "", 0, nil, fmt.Errorf("bad sample rate %q", f)
+			}
+		case strings.HasPrefix(f, "#"):
+			for _, tag := range strings.Split(f[1:], ",") {
+				k, v, _ := strings.Cut(tag, ":")
+				if k = sanitizeLabelName(k); k != "" {
+					tags[k] = v
+				}
+			}
+		}
+	}
+	return name, value, kind, rate, tags, nil
+}
+
+// sanitizeMetricName maps StatsD's dotted names onto Prometheus' charset:
+// "api.requests-count" becomes "api_requests_count".
+func sanitizeMetricName(s string) string {
+	out := []byte(s)
+	for i, c := range out {
+		if !(c == '_' || c == ':' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 0 && c >= '0' && c <= '9') {
+			out[i] = '_'
+		}
+	}
+	return string(out)
+}
+
+func sanitizeLabelName(s string) string {
+	s = strings.ReplaceAll(sanitizeMetricName(s), ":", "_")
+	if strings.HasPrefix(s, "__") {
+		return "" // reserved for Prometheus internals
+	}
+	return s
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// collect runs CollectInto, and returns how many metrics it sent.
+func collect(t *testing.T, a *StatsDAdapter) int {
+	t.Helper()
+	ch := make(chan prometheus.Metric, 100)
+	if err := a.CollectInto(ch); err != nil {
+		t.Fatalf("CollectInto: %v", err)
+	}
+	close(ch)
+	n := 0
+	for range ch {
+		n++
+	}
+	return n
+}
+
+func TestStatsDInvalidUTF8IsAParseError(t *testing.T) {
+	a := NewStatsDAdapter(":0")
+	for _, line := range []string{"x:1|c|#k:\xff", "x\xff:1|c", "x:1|c|#k\xff:v"} {
+		if _, _, _, _, _, err := parseStatsDLine(line); err == nil {
+			t.Errorf("parseStatsDLine(%q): want an error", line)
+		}
+		a.handleLine(line)
+	}
+	a.handleLine("x:1|c|#k:v")
+
+	if got := testutil.ToFloat64(a.parseErrors); got != 3 {
+		t.Errorf("statsd_parse_errors_total = %v, want 3", got)
+	}
+	if len(a.series) != 1 {
+		t.Errorf("got %d series, want only the valid one", len(a.series))
+	}
+	collect(t, a)
+}
+
+func TestStatsDCollectDropsSeriesPrometheusRefuses(t *testing.T) {
+	a := NewStatsDAdapter(":0")
+	a.handleLine("x:1|c|#k:v")
+	// Past parseStatsDLine, e.g. from a future line format
+	a.series["bad"] = &statsdSeries{name: "y", kind: "g", labelNames: []string{"k"}, labelValues: []string{"\xff"}}
+
+	before := collect(t, a)
+	if got := testutil.ToFloat64(a.dropped.WithLabelValues("invalid_series")); got != 1 {
+		t.Errorf(`statsd_dropped_total{reason="invalid_series"} = %v, want 1`, got)
+	}
+	if _, ok := a.series["bad"]; ok {
+		t.Error("the refused series is still there")
+	}
+	if after := collect(t, a); after != before {
+		t.Errorf("second scrape sent %d metrics, want %d", after, before)
+	}
+}
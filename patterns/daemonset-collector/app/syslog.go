@@ -0,0 +1,380 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	registerCollector("syslog", func() (Collector, error) {
+		// SYSLOG_FORWARD empty = count only, nothing is shipped.
+		var shipper LogShipper
+		if mode := getEnv("SYSLOG_FORWARD", ""); mode != "" {
+			var err error
+			if shipper, err = NewLogShipper(mode, getEnv("SYSLOG_FORWARD_URL", "")); err != nil {
+				return nil, err
+			}
+		}
+		return NewSyslogReceiver(getEnv("SYSLOG_ADDR", ":5514"), getEnv("NODE_NAME", ""), shipper), nil
+	})
+}
+
+var (
+	syslogSeverities = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+	syslogFacilities = []string{"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+		"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+		"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7"}
+)
+
+// syslogMessage is the part of a syslog message we keep.
+type syslogMessage struct {
+	Facility string
+	Severity string
+	Time     time.Time // zero if the sender didn't say
+	Hostname string
+	App      string
+	Msg      string
+}
+
+// SyslogReceiver is the classic node-agent log intake: services on the node
+// (or appliances that can't run an agent) send syslog to the DaemonSet, which
+// counts messages by facility and severity and, optionally, forwards them to
+// the same sinks as container logs (Loki, HTTP, stdout).
+//
+// It accepts RFC 5424 over UDP (one message per datagram) and over TCP with
+// either octet-counting or newline framing (RFC 6587). Older RFC 3164
+// messages are counted by their priority; their text is kept as-is.
+type SyslogReceiver struct {
+	Addr    string
+	Node    string
+	Shipper LogShipper // nil: count only
+	// MaxPending bounds how many messages are buffered while the sink is down.
+	MaxPending int
+	schedule
+
+	udp    net.PacketConn
+	tcp    net.Listener
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	pending []LogEntry
+	shipErr error
+
+	messages    *prometheus.CounterVec
+	parseErrors *prometheus.CounterVec
+	shipErrors  prometheus.Counter
+	dropped     prometheus.Counter
+}
+
+// NewSyslogReceiver creates a receiver; call Start to begin listening.
+func NewSyslogReceiver(addr, node string, shipper LogShipper) *SyslogReceiver {
+	return &SyslogReceiver{
+		Addr:       addr,
+		Node:       node,
+		Shipper:    shipper,
+		MaxPending: 10000,
+		schedule:   schedule{Interval: time.Second, Jitter: collectorJitter()},
+
+		messages: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "syslog_messages_total",
+			Help: "Syslog messages received, by transport, facility and severity.",
+		}, []string{"transport", "facility", "severity"}),
+		parseErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "syslog_parse_errors_total",
+			Help: "Syslog messages that could not be parsed, by transport.",
+		}, []string{"transport"}),
+		shipErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "syslog_ship_errors_total",
+			Help: "Failed attempts to forward a batch of syslog messages.",
+		}),
+		dropped: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "syslog_dropped_total",
+			Help: "Syslog messages dropped because the sink was unavailable for too long.",
+		}),
+	}
+}
+
+// Name implements Collector.
+func (s *SyslogReceiver) Name() string { return "syslog" }
+
+// Describe implements Collector.
+func (s *SyslogReceiver) Describe(ch chan<- *prometheus.Desc) {
+	s.messages.Describe(ch)
+	s.parseErrors.Describe(ch)
+	s.shipErrors.Describe(ch)
+	s.dropped.Describe(ch)
+}
+
+// CollectInto implements Collector. Like the logs module, it fails while the
+// sink is rejecting batches.
+func (s *SyslogReceiver) CollectInto(ch chan<- prometheus.Metric) error {
+	s.messages.Collect(ch)
+	s.parseErrors.Collect(ch)
+	s.shipErrors.Collect(ch)
+	s.dropped.Collect(ch)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.shipErr
+}
+
+// Start implements Collector. Both sockets are bound here so a port conflict
+// is reported at startup.
+func (s *SyslogReceiver) Start(ctx context.Context) error {
+	udp, err := net.ListenPacket("udp", s.Addr)
+	if err != nil {
+		return err
+	}
+	tcp, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		udp.Close()
+		return err
+	}
+	s.udp, s.tcp = udp, tcp
+	ctx, s.cancel = context.WithCancel(ctx)
+	go s.serveUDP()
+	go s.serveTCP()
+	if s.Shipper != nil {
+		go s.run(ctx, s.flush)
+	}
+	fmt.Printf("Accepting syslog on udp and tcp %s\n", s.Addr)
+	return nil
+}
+
+// Stop implements Collector.
+func (s *SyslogReceiver) Stop() error {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	if s.udp == nil {
+		return nil
+	}
+	return errors.Join(s.udp.Close(), s.tcp.Close())
+}
+
+func (s *SyslogReceiver) serveUDP() {
+	buf := make([]byte, 65535)
+	for {
+		n, _, err := s.udp.ReadFrom(buf)
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			fmt.Printf("syslog: %v\n", err)
+			continue
+		}
+		s.handle(buf[:n], "udp")
+	}
+}
+
+func (s *SyslogReceiver) serveTCP() {
+	for {
+		conn, err := s.tcp.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			fmt.Printf("syslog: %v\n", err)
+			continue
+		}
+		go s.serveConn(conn)
+	}
+}
+
+// serveConn reads frames until the sender disconnects. RFC 6587 allows two
+// framings: "<length> <message>" (octet counting) or one message per line.
+// The first byte tells them apart: a message starts with '<', a length with
+// a digit.
+func (s *SyslogReceiver) serveConn(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		// Idle connections are closed after a while; senders reconnect.
+		conn.SetReadDeadline(time.Now().Add(5 * time.Minute))
+		first, err := r.Peek(1)
+		if err != nil {
+			return
+		}
+		var frame []byte
+		if first[0] >= '0' && first[0] <= '9' {
+			lenStr, err := r.ReadString(' ')
+			if err != nil {
+				return
+			}
+			n, err := strconv.Atoi(strings.TrimSpace(lenStr))
+			if err != nil || n <= 0 || n > 65535 {
+				s.parseErrors.WithLabelValues("tcp").Inc()
+				return // framing is lost; nothing else on this connection can be trusted
+			}
+			frame = make([]byte, n)
+			if _, err := io.ReadFull(r, frame); err != nil {
+				return
+			}
+		} else {
+			line, err := r.ReadBytes('\n')
+			if len(line) == 0 && err != nil {
+				return
+			}
+			frame = bytes.TrimRight(line, "\r\n")
+		}
+		if len(frame) > 0 {
+			s.handle(frame, "tcp")
+		}
+	}
+}
+
+func (s *SyslogReceiver) handle(raw []byte, transport string) {
+	msg, err := parseSyslog(string(raw))
+	if err != nil {
+		s.parseErrors.WithLabelValues(transport).Inc()
+		return
+	}
+	s.messages.WithLabelValues(transport, msg.Facility, msg.Severity).Inc()
+	if s.Shipper == nil {
+		return
+	}
+
+	ts := msg.Time
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	// Facility and severity are bounded, so they are fine as Loki labels;
+	// hostname and app usually are too on one node. Message IDs and PIDs are
+	// not, and stay in the line.
+	entry := LogEntry{Time: ts, Stream: "syslog", Line: msg.Msg, Pod: PodRef{Node: s.Node}, Labels: map[string]string{
+		"job":      "syslog",
+		"app":      msg.App,
+		"host":     msg.Hostname,
+		"facility": msg.Facility,
+		"level":    msg.Severity,
+	}}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.pending) >= s.MaxPending {
+		s.pending = s.pending[1:]
+		s.dropped.Inc()
+	}
+	s.pending = append(s.pending, entry)
+}
+
+func (s *SyslogReceiver) flush(ctx context.Context) {
+	s.mu.Lock()
+	batch := s.pending
+	s.pending = nil
+	s.mu.Unlock()
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	err := s.Shipper.Ship(ctx, batch)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.shipErr = err
+	if err != nil {
+		// Put the batch back in front of whatever arrived meanwhile and
+		// retry on the next tick.
+		s.shipErrors.Inc()
+		fmt.Printf("syslog: ship %d messages: %v\n", len(batch), err)
+		s.pending = append(batch, s.pending...)
+		if over := len(s.pending) - s.MaxPending; over > 0 {
+			s.pending = s.pending[over:]
+			s.dropped.Add(float64(over))
+		}
+	}
+}
+
+// parseSyslog parses an RFC 5424 message:
+//
+//	<PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA [MSG]
+//
+// "-" is the nil value for any header field. Anything else that starts with
+// a valid <PRI> (RFC 3164, "<34>Oct 11 22:14:15 host su: ...") is accepted
+// with only the priority parsed.
+func parseSyslog(raw string) (syslogMessage, error) {
+	var m syslogMessage
+	if !strings.HasPrefix(raw, "<") {
+		return m, fmt.Errorf("missing priority")
+	}
+	end := strings.IndexByte(raw, '>')
+	if end < 2 || end > 4 {
+		return m, fmt.Errorf("bad priority")
+	}
+	pri, err := strconv.Atoi(raw[1:end])
+	if err != nil || pri < 0 || pri > 191 {
+		return m, fmt.Errorf("bad priority %q", raw[1:end])
+	}
+	m.Facility, m.Severity = syslogFacilities[pri/8], syslogSeverities[pri%8]
+	rest := raw[end+1:]
+
+	if !strings.HasPrefix(rest, "1 ") {
+		m.Msg = strings.TrimSpace(rest) // RFC 3164 or unknown: keep the text
+		return m, nil
+	}
+	fields := strings.SplitN(rest[2:], " ", 6)
+	if len(fields) < 6 {
+		return m, fmt.Errorf("truncated RFC 5424 header")
+	}
+	nilable := func(s string) string {
+		if s == "-" {
+			return ""
+		}
+		return s
+	}
+	if ts := nilable(fields[0]); ts != "" {
+		if m.Time, err = time.Parse(time.RFC3339Nano, ts); err != nil {
+			return m, fmt.Errorf("bad timestamp %q", ts)
+		}
+	}
+	m.Hostname, m.App = nilable(fields[1]), nilable(fields[2])
+
+	// fields[5] is STRUCTURED-DATA followed by the optional MSG.
+	// Structured data is validated but not exported: its keys are unbounded.
+	_, msg, err := splitStructuredData(fields[5])
+	if err != nil {
+		return m, err
+	}
+	m.Msg = strings.TrimPrefix(msg, "\uFEFF") // UTF-8 BOM, allowed before MSG
+	return m, nil
+}
+
+// splitStructuredData splits "-" or "[id k="v"][id2 ...]" from the message
+// after it. Inside quoted values, '\]', '\"' and '\\' are escapes.
+func splitStructuredData(s string) (sd, msg string, err error) {
+	if strings.HasPrefix(s, "-") {
+		return "", strings.TrimPrefix(s[1:], " "), nil
+	}
+	i := 0
+	for i < len(s) && s[i] == '[' {
+		inQuotes := false
+		for i++; i < len(s); i++ {
+			switch c := s[i]; {
+			case c == '\\' && inQuotes:
+				i++
+			case c == '"':
+				inQuotes = !inQuotes
+			case c == ']' && !inQuotes:
+				goto next
+			}
+		}
+		return "", "", fmt.Errorf("unterminated structured data")
+	next:
+		i++
+	}
+	if i == 0 {
+		return "", "", fmt.Errorf("bad structured data")
+	}
+	return s[:i], strings.TrimPrefix(s[i:], " "), nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newTracerProvider returns the tracer for the simulated requests. With an
+// endpoint, sampled spans are exported over OTLP/gRPC (next to the metrics
+// pushed by otlp.go); without one, trace IDs are still generated so exemplars
+// can be demonstrated on /metrics, but the traces go nowhere.
+//
+// The returned function flushes pending spans; call it on shutdown.
+func newTracerProvider(ctx context.Context, endpoint string, insecure bool, sampleRatio float64) (trace.Tracer, func(context.Context) error, error) {
+	opts := []sdktrace.TracerProviderOption{
+		// ParentBased: a request that arrives with a sampled parent is always
+		// kept, so traces stay whole across services.
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+		sdktrace.WithResource(resource.Default()),
+	}
+	if endpoint != "" {
+		exporterOpts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(endpoint)}
+		if insecure {
+			exporterOpts = append(exporterOpts, otlptracegrpc.WithInsecure())
+		}
+		exporter, err := otlptracegrpc.New(ctx, exporterOpts...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("creating OTLP trace exporter: %w", err)
+		}
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	}
+	provider := sdktrace.NewTracerProvider(opts...)
+	return provider.Tracer("metrics-app/simulate"), provider.Shutdown, nil
+}
+
+// traceExemplar returns the exemplar labels linking an observation to the
+// span it was made in, or nil when the span isn't sampled: an exemplar
+// pointing at a trace nobody kept is a dead link.
+func traceExemplar(ctx context.Context) prometheus.Labels {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsSampled() {
+		return nil
+	}
+	return prometheus.Labels{"trace_id": sc.TraceID().String()}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func getEnvInt(key string, fallback int) int {
+	if value, ok := os.LookupEnv(key); ok {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+		fmt.Printf("Invalid %s=%q, using default %d\n", key, value, fallback)
+	}
+	return fallback
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if value, ok := os.LookupEnv(key); ok {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+		fmt.Printf("Invalid %s=%q, using default %s\n", key, value, fallback)
+	}
+	return fallback
+}
+
+func main() {
+	resourceName := getEnv("RESOURCE_NAME", "mydomain.com/widget")
+	pluginDir := getEnv("DEVICE_PLUGIN_DIR", pluginapi.DevicePluginPath)
+	kubeletSocket := filepath.Join(pluginDir, filepath.Base(pluginapi.KubeletSocket))
+	socket := filepath.Join(pluginDir, "widget.sock")
+	healthInterval := getEnvDuration("HEALTH_INTERVAL", 5*time.Second)
+
+	w, err := NewWidgets(getEnv("WIDGET_DIR", "/var/lib/widgets"), getEnvInt("WIDGET_COUNT", 4),
+		getEnvInt("WIDGETS_PER_BOARD", 2), getEnv("NODE_NAME", "node"))
+	if err != nil {
+		fmt.Printf("Error creating widgets: %s\n", err)
+		os.Exit(1)
+	}
+
+	// The kubelet deletes every socket in the directory when it starts,
+	// and forgets its plugins: watch for that, and register again.
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		fmt.Printf("Error creating watcher: %s\n", err)
+		os.Exit(1)
+	}
+	defer watcher.Close()
+	if err := watcher.Add(pluginDir); err != nil {
+		fmt.Printf("Error watching %s: %s\n", pluginDir, err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	go func() {
+		ticker := time.NewTicker(healthInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				w.CheckHealth()
+			}
+		}
+	}()
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", promhttp.Handler())
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) { w.Write([]byte("ok")) })
+	server := &http.Server{Addr: getEnv("METRICS_ADDR", ":8080"), Handler: mux}
+	go func() {
+		if err := server.ListenAndServe(); err != http.ErrServerClosed {
+			fmt.Printf("Error starting metrics server: %s\n", err)
+			os.Exit(1)
+		}
+	}()
+
+	fmt.Printf("Advertising %d widgets as %s; health checked every %s\n", len(w.Devices()), resourceName, healthInterval)
+	for {
+		// A fresh server each time: the old one's streams are done.
+		plugin := &Plugin{ResourceName: resourceName, Socket: socket, Widgets: w}
+		if err := plugin.Serve(); err != nil {
+			fmt.Printf("Error serving on %s: %s\n", socket, err)
+		} else if err := plugin.Register(kubeletSocket); err != nil {
+			fmt.Printf("Error registering with the kubelet: %s\n", err)
+			plugin.Stop()
+		} else {
+			fmt.Printf("Registered %s with the kubelet, serving on %s\n", resourceName, socket)
+		}
+
+		restart := waitForRestart(ctx, watcher, socket, kubeletSocket, plugin.server == nil)
+		plugin.Stop()
+		if !restart {
+			break
+		}
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server.Shutdown(shutdownCtx)
+	fmt.Println("Shutting down...")
+}
+
+// waitForRestart blocks until the plugin must serve and register again,
+// and says whether to. That is when the kubelet restarts: it removes the
+// plugin's socket, and creates kubelet.sock anew. After a failure, it
+// retries in 5s, or as soon as kubelet.sock appears.
+func waitForRestart(ctx context.Context, watcher *fsnotify.Watcher, socket, kubeletSocket string, failed bool) bool {
+	var retry <-chan time.Time
+	if failed {
+		retry = time.After(5 * time.Second)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-retry:
+			return true
+		case event := <-watcher.Events:
+			switch {
+			case event.Name == kubeletSocket && event.Has(fsnotify.Create):
+				fmt.Println("kubelet.sock created: the kubelet restarted, registering again")
+				return true
+			case event.Name == socket && event.Has(fsnotify.Remove) && !failed:
+				// Serve removes a stale socket before it listens: only a
+				// socket that is still gone was removed by the kubelet.
+				if _, err := os.Stat(socket); err == nil {
+					continue
+				}
+				fmt.Println("Plugin socket removed: the kubelet restarted, serving again")
+				return true
+			}
+		case err := <-watcher.Errors:
+			fmt.Printf("Error watching the device plugin directory: %s\n", err)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Metrics are registered with the default registry via 'promauto'.
+var (
+	widgets = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "device_plugin_widgets",
+		Help: "Widgets on this node, by health (Healthy, Unhealthy).",
+	}, []string{"health"})
+
+	allocationsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "device_plugin_allocations_total",
+		Help: "Allocate calls from the kubelet, by result (success, error).",
+	}, []string{"result"})
+
+	registrationsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "device_plugin_registrations_total",
+		Help: "Registrations with the kubelet, by result (success, error).",
+	}, []string{"result"})
+)
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// containerDir is where a container finds its widgets' files.
+const containerDir = "/var/run/widgets"
+
+// Plugin is the device plugin's gRPC server. The kubelet connects to it
+// on Socket, after Register told it where that is, and asks:
+// ListAndWatch for the devices and their health, GetPreferredAllocation
+// for which ones a container should get, Allocate for how to hand them
+// to the container.
+type Plugin struct {
+	ResourceName string
+	// Socket is the plugin's own socket, in the kubelet's device-plugins
+	// directory.
+	Socket  string
+	Widgets *Widgets
+
+	server *grpc.Server
+	stop   chan struct{}
+}
+
+// Serve listens on Socket and returns once the server answers.
+func (p *Plugin) Serve() error {
+	// A socket left by a previous run would fail the listen.
+	if err := os.Remove(p.Socket); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	ln, err := net.Listen("unix", p.Socket)
+	if err != nil {
+		return err
+	}
+	p.server = grpc.NewServer()
+	p.stop = make(chan struct{})
+	pluginapi.RegisterDevicePluginServer(p.server, p)
+	go p.server.Serve(ln)
+
+	// Make sure it answers before telling the kubelet about it: the
+	// kubelet calls back as soon as Register returns.
+	conn, err := dial(p.Socket)
+	if err != nil {
+		p.Stop()
+		return err
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := pluginapi.NewDevicePluginClient(conn).GetDevicePluginOptions(ctx, &pluginapi.Empty{}, grpc.WaitForReady(true)); err != nil {
+		p.Stop()
+		return fmt.Errorf("plugin socket not answering: %w", err)
+	}
+	return nil
+}
+
+// Register tells the kubelet about the plugin: the resource name, and the
+// socket's name in the device-plugins directory.
+func (p *Plugin) Register(kubeletSocket string) error {
+	conn, err := dial(kubeletSocket)
+	if err != nil {
+		registrationsTotal.WithLabelValues("error").Inc()
+		return err
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = pluginapi.NewRegistrationClient(conn).Register(ctx, &pluginapi.RegisterRequest{
+		Version:      pluginapi.Version,
+		Endpoint:     filepath.Base(p.Socket),
+		ResourceName: p.ResourceName,
+		Options:      p.options(),
+	})
+	if err != nil {
+		registrationsTotal.WithLabelValues("error").Inc()
+		return err
+	}
+	registrationsTotal.WithLabelValues("success").Inc()
+	return nil
+}
+
+// Stop ends every ListAndWatch stream and stops the server.
+func (p *Plugin) Stop() {
+	if p.server == nil {
+		return
+	}
+	close(p.stop)
+	p.server.Stop()
+	p.server = nil
+	os.Remove(p.Socket)
+}
+
+func dial(socket string) (*grpc.ClientConn, error) {
+	return grpc.NewClient("unix://"+socket, grpc.WithTransportCredentials(insecure.NewCredentials()))
+}
+
+func (p *Plugin) options() *pluginapi.DevicePluginOptions {
+	return &pluginapi.DevicePluginOptions{GetPreferredAllocationAvailable: true}
+}
+
+func (p *Plugin) GetDevicePluginOptions(context.Context, *pluginapi.Empty) (*pluginapi.DevicePluginOptions, error) {
+	return p.options(), nil
+}
+
+// ListAndWatch sends the full device list, then again on every health
+// change, for as long as the kubelet keeps the stream open. The node's
+// capacity and allocatable for the resource follow: allocatable counts
+// only Healthy devices.
+func (p *Plugin) ListAndWatch(_ *pluginapi.Empty, stream pluginapi.DevicePlugin_ListAndWatchServer) error {
+	for {
+		changed := p.Widgets.Changed()
+		if err := stream.Send(&pluginapi.ListAndWatchResponse{Devices: p.Widgets.Devices()}); err != nil {
+			return err
+		}
+		select {
+		case <-changed:
+		case <-stream.Context().Done():
+			return nil
+		case <-p.stop:
+			return nil
+		}
+	}
+}
+
+// GetPreferredAllocation picks, from the devices free for a container,
+// the ones it should get: the ones it must, then whole boards first, so a
+// container's widgets share as few boards as possible. The kubelet may
+// still choose differently, e.g. to satisfy the Topology Manager.
+func (p *Plugin) GetPreferredAllocation(_ context.Context, req *pluginapi.PreferredAllocationRequest) (*pluginapi.PreferredAllocationResponse, error) {
+	resp := &pluginapi.PreferredAllocationResponse{}
+	for _, creq := range req.ContainerRequests {
+		chosen := slices.Clone(creq.MustIncludeDeviceIDs)
+		free := map[int][]string{}
+		for _, id := range creq.AvailableDeviceIDs {
+			if !slices.Contains(chosen, id) {
+				board := p.Widgets.Board(id)
+				free[board] = append(free[board], id)
+			}
+		}
+		// Boards that already have a chosen device first, then the
+		// boards with the most free devices, then in order.
+		boards := make([]int, 0, len(free))
+		for board := range free {
+			boards = append(boards, board)
+		}
+		used := func(board int) bool {
+			return slices.ContainsFunc(chosen, func(id string) bool { return p.Widgets.Board(id) == board })
+		}
+		slices.SortFunc(boards, func(a, b int) int {
+			if ua, ub := used(a), used(b); ua != ub {
+				if ua {
+					return -1
+				}
+				return 1
+			}
+			return cmp.Or(cmp.Compare(len(free[b]), len(free[a])), cmp.Compare(a, b))
+		})
+		for _, board := range boards {
+			for _, id := range free[board] {
+				if len(chosen) >= int(creq.AllocationSize) {
+					break
+				}
+				chosen = append(chosen, id)
+			}
+		}
+		resp.ContainerResponses = append(resp.ContainerResponses, &pluginapi.ContainerPreferredAllocationResponse{DeviceIDs: chosen})
+	}
+	return resp, nil
+}
+
+// Allocate tells the kubelet how to give a container its widgets: their
+// files mounted read-only under /var/run/widgets, and their IDs in
+// WIDGET_VISIBLE_DEVICES. It runs when the container is created; an error
+// fails the Pod with UnexpectedAdmissionError.
+func (p *Plugin) Allocate(_ context.Context, req *pluginapi.AllocateRequest) (*pluginapi.AllocateResponse, error) {
+	resp := &pluginapi.AllocateResponse{}
+	for _, creq := range req.ContainerRequests {
+		cresp := &pluginapi.ContainerAllocateResponse{}
+		for _, id := range creq.DevicesIDs {
+			if !p.Widgets.Healthy(id) {
+				allocationsTotal.WithLabelValues("error").Inc()
+				return nil, status.Errorf(codes.FailedPrecondition, "widget %q is unknown or unhealthy", id)
+			}
+			cresp.Mounts = append(cresp.Mounts, &pluginapi.Mount{
+				ContainerPath: filepath.Join(containerDir, id),
+				HostPath:      p.Widgets.HostPath(id),
+				ReadOnly:      true,
+			})
+		}
+		cresp.Envs = map[string]string{"WIDGET_VISIBLE_DEVICES": strings.Join(creq.DevicesIDs, ",")}
+		resp.ContainerResponses = append(resp.ContainerResponses, cresp)
+		allocationsTotal.WithLabelValues("success").Inc()
+		fmt.Printf("Allocated %s\n", strings.Join(creq.DevicesIDs, ", "))
+	}
+	return resp, nil
+}
+
+// PreStartContainer is only called with PreStartRequired in the options,
+// to reset devices before each container start. Widgets need no reset.
+func (p *Plugin) PreStartContainer(context.Context, *pluginapi.PreStartContainerRequest) (*pluginapi.PreStartContainerResponse, error) {
+	return &pluginapi.PreStartContainerResponse{}, nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// Widgets is the node's set of fake devices. Each has a file under Dir
+// that stands in for the hardware: it holds the widget's serial number,
+// and is what a container gets mounted. A widget whose file is gone is
+// Unhealthy, the way a GPU that fell off the bus would be.
+type Widgets struct {
+	Dir string
+	// BoardSize is how many widgets share a board. Widgets on the same
+	// board talk to each other faster; GetPreferredAllocation keeps a
+	// Pod's widgets on as few boards as it can.
+	BoardSize int
+
+	ids []string
+
+	mu     sync.Mutex
+	health map[string]string
+	// changed is closed, and replaced, whenever a widget's health
+	// changes: every ListAndWatch stream wakes up.
+	changed chan struct{}
+}
+
+// NewWidgets creates count widgets, widget-0 to widget-<count-1>, with
+// their files under dir.
+func NewWidgets(dir string, count, boardSize int, node string) (*Widgets, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	w := &Widgets{Dir: dir, BoardSize: max(1, boardSize), health: map[string]string{}, changed: make(chan struct{})}
+	for i := range count {
+		id := fmt.Sprintf("widget-%d", i)
+		serial := fmt.Sprintf("serial=WGT-%s-%d\nboard=%d\n", node, i, i/w.BoardSize)
+		if err := os.WriteFile(w.HostPath(id), []byte(serial), 0o644); err != nil {
+			return nil, err
+		}
+		w.ids = append(w.ids, id)
+		w.health[id] = pluginapi.Healthy
+	}
+	w.updateMetrics()
+	return w, nil
+}
+
+// HostPath is the widget's file on the node.
+func (w *Widgets) HostPath(id string) string {
+	return filepath.Join(w.Dir, id)
+}
+
+// Board is the board a widget sits on.
+func (w *Widgets) Board(id string) int {
+	return slices.Index(w.ids, id) / w.BoardSize
+}
+
+// Devices is the list ListAndWatch sends: every widget, with its health.
+func (w *Widgets) Devices() []*pluginapi.Device {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	devices := make([]*pluginapi.Device, 0, len(w.ids))
+	for _, id := range w.ids {
+		devices = append(devices, &pluginapi.Device{ID: id, Health: w.health[id]})
+	}
+	return devices
+}
+
+// Healthy reports whether id is a widget, and a healthy one.
+func (w *Widgets) Healthy(id string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.health[id] == pluginapi.Healthy
+}
+
+// Changed returns a channel that is closed on the next health change.
+func (w *Widgets) Changed() <-chan struct{} {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.changed
+}
+
+// CheckHealth looks for each widget's file, and marks the widget
+// Unhealthy if it is gone, Healthy if it is back.
+func (w *Widgets) CheckHealth() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	changed := false
+	for _, id := range w.ids {
+		health := pluginapi.Healthy
+		if _, err := os.Stat(w.HostPath(id)); err != nil {
+			health = pluginapi.Unhealthy
+		}
+		if w.health[id] != health {
+			fmt.Printf("Widget %s is now %s\n", id, health)
+			w.health[id] = health
+			changed = true
+		}
+	}
+	if changed {
+		close(w.changed)
+		w.changed = make(chan struct{})
+		w.updateMetricsLocked()
+	}
+}
+
+func (w *Widgets) updateMetrics() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.updateMetricsLocked()
+}
+
+func (w *Widgets) updateMetricsLocked() {
+	counts := map[string]int{pluginapi.Healthy: 0, pluginapi.Unhealthy: 0}
+	for _, health := range w.health {
+		counts[health]++
+	}
+	for health, n := range counts {
+		widgets.WithLabelValues(health).Set(float64(n))
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// CgroupLimits is what the container's cgroup allows. The kernel enforces
+// these numbers: the CFS quota throttles the container once it has used
+// its share of a period, and the memory limit OOM-kills it. They are the
+// Pod spec's limits, as the kubelet translated them.
+type CgroupLimits struct {
+	// Version is 1 or 2, or 0 if no cgroup filesystem was found (not
+	// on Linux, or not in a container).
+	Version int
+	// CPUMillis is the CPU quota in millicores: 1500 is "1.5 cores' worth
+	// of time per period". 0 means no limit.
+	CPUMillis int64
+	// MemoryBytes is the memory limit. 0 means no limit.
+	MemoryBytes int64
+}
+
+// ReadCgroupLimits reads the limits under root, normally /sys/fs/cgroup.
+// With a cgroup namespace (the default on cgroup v2 with containerd and
+// CRI-O), the root of the mount is the container's own cgroup. On v1,
+// runtimes mount the container's cgroup there too.
+func ReadCgroupLimits(root string) CgroupLimits {
+	// v2: one unified hierarchy, with cgroup.controllers at its root.
+	if _, err := os.Stat(filepath.Join(root, "cgroup.controllers")); err == nil {
+		limits := CgroupLimits{Version: 2}
+		// cpu.max: "<quota> <period>" in microseconds, or "max <period>".
+		if quota, period, ok := strings.Cut(readLine(filepath.Join(root, "cpu.max")), " "); ok && quota != "max" {
+			limits.CPUMillis = millicores(quota, period)
+		}
+		// memory.max: bytes, or "max".
+		if v := readLine(filepath.Join(root, "memory.max")); v != "max" {
+			limits.MemoryBytes, _ = strconv.ParseInt(v, 10, 64)
+		}
+		return limits
+	}
+
+	// v1: one hierarchy per controller. cpu is often co-mounted with
+	// cpuacct, under either name.
+	for _, dir := range []string{"cpu", "cpu,cpuacct", "cpuacct,cpu"} {
+		quota := readLine(filepath.Join(root, dir, "cpu.cfs_quota_us"))
+		if quota == "" {
+			continue
+		}
+		limits := CgroupLimits{Version: 1}
+		// -1 is no limit.
+		if quota != "-1" {
+			limits.CPUMillis = millicores(quota, readLine(filepath.Join(root, dir, "cpu.cfs_period_us")))
+		}
+		// No limit is the largest page-aligned int64, 9223372036854771712.
+		if n, err := strconv.ParseInt(readLine(filepath.Join(root, "memory", "memory.limit_in_bytes")), 10, 64); err == nil && n < 1<<62 {
+			limits.MemoryBytes = n
+		}
+		return limits
+	}
+	return CgroupLimits{}
+}
+
+// millicores turns a CFS quota and period, in microseconds, into
+// millicores: 150000/100000 is 1500m.
+func millicores(quota, period string) int64 {
+	q, err := strconv.ParseInt(quota, 10, 64)
+	if err != nil || q <= 0 {
+		return 0
+	}
+	p, err := strconv.ParseInt(period, 10, 64)
+	if err != nil || p <= 0 {
+		return 0
+	}
+	return q * 1000 / p
+}
+
+func readLine(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,3 @@
+module downward-api
+
+go 1.24.3
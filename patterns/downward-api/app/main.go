@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"runtime"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func getEnvInt64(key string, fallback int64) int64 {
+	if value, ok := os.LookupEnv(key); ok {
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return n
+		}
+		fmt.Printf("Invalid %s=%q, using default %d\n", key, value, fallback)
+	}
+	return fallback
+}
+
+func getEnvFloat(key string, fallback float64) float64 {
+	if value, ok := os.LookupEnv(key); ok {
+		if f, err := strconv.ParseFloat(value, 64); err == nil && f > 0 && f <= 1 {
+			return f
+		}
+		fmt.Printf("Invalid %s=%q, using default %g\n", key, value, fallback)
+	}
+	return fallback
+}
+
+// Info is what /info reports: everything the Pod was told about itself,
+// what the kernel enforces, and what the Go runtime made of it.
+type Info struct {
+	Pod         *PodInfo          `json:"pod"`
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	DownwardAPI ResourcesInfo     `json:"downwardAPI"`
+	Cgroup      CgroupInfo        `json:"cgroup"`
+	Runtime     RuntimeInfo       `json:"runtime"`
+}
+
+type ResourcesInfo struct {
+	CPURequest    string `json:"cpuRequest"`
+	CPULimit      string `json:"cpuLimit"`
+	MemoryRequest string `json:"memoryRequest"`
+	MemoryLimit   string `json:"memoryLimit"`
+}
+
+type CgroupInfo struct {
+	Version     int    `json:"version"`
+	CPULimit    string `json:"cpuLimit"`
+	MemoryLimit string `json:"memoryLimit"`
+}
+
+type RuntimeInfo struct {
+	GoVersion        string `json:"goVersion"`
+	NumCPU           int    `json:"numCPU"`
+	GOMAXPROCS       int    `json:"GOMAXPROCS"`
+	GOMAXPROCSSource string `json:"GOMAXPROCSSource"`
+	GOMEMLIMIT       string `json:"GOMEMLIMIT"`
+	GOMEMLIMITSource string `json:"GOMEMLIMITSource"`
+	HeapAlloc        string `json:"heapAlloc"`
+	Sys              string `json:"sys"`
+	NumGC            uint32 `json:"numGC"`
+}
+
+func cgroupInfo(cg CgroupLimits) CgroupInfo {
+	info := CgroupInfo{Version: cg.Version, CPULimit: "none", MemoryLimit: formatBytes(cg.MemoryBytes)}
+	if cg.CPUMillis > 0 {
+		info.CPULimit = formatMillis(cg.CPUMillis)
+	}
+	return info
+}
+
+// retained is what /alloc holds on to between requests; sink keeps the
+// compiler from optimizing its garbage away.
+var (
+	allocMu  sync.Mutex
+	retained [][]byte
+	sink     []byte
+)
+
+// allocate returns n MiB, with every page written so that it counts
+// against the container's memory, not only the heap's.
+func allocate(n int) [][]byte {
+	chunks := make([][]byte, n)
+	for i := range chunks {
+		chunks[i] = make([]byte, 1<<20)
+		for j := 0; j < len(chunks[i]); j += 4096 {
+			chunks[i][j] = 1
+		}
+	}
+	return chunks
+}
+
+func main() {
+	listenAddr := getEnv("LISTEN_ADDR", ":8080")
+	cgroupRoot := getEnv("CGROUP_ROOT", "/sys/fs/cgroup")
+	pod := PodInfoFromEnv()
+
+	// First thing, before the app allocates or starts goroutines.
+	cg := ReadCgroupLimits(cgroupRoot)
+	tuning := Tune(cg, pod.Resources, getEnvFloat("MEMORY_LIMIT_RATIO", 0.9))
+
+	fmt.Printf("Pod %s/%s on node %s, IP %s\n", pod.Namespace, pod.Name, pod.Node, pod.IP)
+	fmt.Printf("Downward API: cpu %s/%s, memory %s/%s (request/limit)\n",
+		formatMillis(pod.Resources.CPURequestMillis), formatMillis(pod.Resources.CPULimitMillis),
+		formatBytes(pod.Resources.MemoryRequestBytes), formatBytes(pod.Resources.MemoryLimitBytes))
+	if cg.Version == 0 {
+		fmt.Printf("No cgroup found under %s\n", cgroupRoot)
+	} else {
+		c := cgroupInfo(cg)
+		fmt.Printf("cgroup v%d: cpu %s, memory %s\n", cg.Version, c.CPULimit, c.MemoryLimit)
+	}
+	fmt.Printf("GOMAXPROCS=%d, from %s\n", tuning.GOMAXPROCS, tuning.GOMAXPROCSSource)
+	fmt.Printf("GOMEMLIMIT=%s, from %s\n", formatBytes(tuning.GOMEMLIMIT), tuning.GOMEMLIMITSource)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /info", func(w http.ResponseWriter, _ *http.Request) {
+		var mem runtime.MemStats
+		runtime.ReadMemStats(&mem)
+		labels, annotations := pod.Labels()
+		res := pod.Resources
+		info := Info{
+			Pod:         pod,
+			Labels:      labels,
+			Annotations: annotations,
+			DownwardAPI: ResourcesInfo{
+				CPURequest:    formatMillis(res.CPURequestMillis),
+				CPULimit:      formatMillis(res.CPULimitMillis),
+				MemoryRequest: formatBytes(res.MemoryRequestBytes),
+				MemoryLimit:   formatBytes(res.MemoryLimitBytes),
+			},
+			// Read again: an in-place resize changes the cgroup, while
+			// the environment stays as it was at container start.
+			Cgroup: cgroupInfo(ReadCgroupLimits(cgroupRoot)),
+			Runtime: RuntimeInfo{
+				GoVersion:        runtime.Version(),
+				NumCPU:           runtime.NumCPU(),
+				GOMAXPROCS:       tuning.GOMAXPROCS,
+				GOMAXPROCSSource: tuning.GOMAXPROCSSource,
+				GOMEMLIMIT:       formatBytes(tuning.GOMEMLIMIT),
+				GOMEMLIMITSource: tuning.GOMEMLIMITSource,
+				HeapAlloc:        formatBytes(int64(mem.HeapAlloc)),
+				Sys:              formatBytes(int64(mem.Sys)),
+				NumGC:            mem.NumGC,
+			},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(info)
+	})
+	// POST /alloc?retain=40&churn=200: hold 40Mi of live data, then
+	// allocate and drop 200Mi of garbage, 1Mi at a time. Under a 64Mi
+	// limit, that works with GOMEMLIMIT and is OOM-killed without it.
+	mux.HandleFunc("POST /alloc", func(w http.ResponseWriter, r *http.Request) {
+		retain, err := strconv.Atoi(r.URL.Query().Get("retain"))
+		if err != nil || retain < 0 {
+			http.Error(w, "retain must be a number of MiB", http.StatusBadRequest)
+			return
+		}
+		churn, err := strconv.Atoi(r.URL.Query().Get("churn"))
+		if err != nil || churn < 0 {
+			http.Error(w, "churn must be a number of MiB", http.StatusBadRequest)
+			return
+		}
+
+		allocMu.Lock()
+		defer allocMu.Unlock()
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		retained = nil
+		retained = allocate(retain)
+		for range churn {
+			sink = allocate(1)[0]
+		}
+		sink = nil
+		runtime.ReadMemStats(&after)
+
+		fmt.Fprintf(w, "retained %dMi, churned %dMi: %d GC cycles; heap %s, from the OS %s, GOMEMLIMIT %s\n",
+			retain, churn, after.NumGC-before.NumGC, formatBytes(int64(after.HeapAlloc)),
+			formatBytes(int64(after.Sys)), formatBytes(tuning.GOMEMLIMIT))
+	})
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) { w.Write([]byte("ok")) })
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	server := &http.Server{Addr: listenAddr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Printf("Listening on %s\n", listenAddr)
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		fmt.Printf("Error starting server: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("Shutting down...")
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// PodInfo is what the Downward API tells the app about the Pod it runs in.
+// Identity and resources come in as environment variables (fieldRef and
+// resourceFieldRef), fixed when the container starts. Labels and
+// annotations come as files in a downwardAPI volume, which the kubelet
+// rewrites when they change: they are read again on every request.
+type PodInfo struct {
+	Name           string `json:"name"`
+	Namespace      string `json:"namespace"`
+	UID            string `json:"uid"`
+	IP             string `json:"ip"`
+	Node           string `json:"node"`
+	HostIP         string `json:"hostIP"`
+	ServiceAccount string `json:"serviceAccount"`
+
+	Resources PodResources `json:"-"`
+
+	// Dir is the downwardAPI volume's mount path, with "labels" and
+	// "annotations" files; empty to go without.
+	Dir string `json:"-"`
+}
+
+// PodResources is the container's requests and limits, from
+// resourceFieldRef. CPU is in millicores and memory in bytes, as the
+// manifest's divisors say. Without a limit, the Downward API reports the
+// node's allocatable CPU or memory instead: it can't say "no limit".
+type PodResources struct {
+	CPURequestMillis   int64
+	CPULimitMillis     int64
+	MemoryRequestBytes int64
+	MemoryLimitBytes   int64
+}
+
+// PodInfoFromEnv reads the fieldRef and resourceFieldRef environment
+// variables of the manifest.
+func PodInfoFromEnv() *PodInfo {
+	return &PodInfo{
+		Name:           getEnv("POD_NAME", ""),
+		Namespace:      getEnv("POD_NAMESPACE", ""),
+		UID:            getEnv("POD_UID", ""),
+		IP:             getEnv("POD_IP", ""),
+		Node:           getEnv("NODE_NAME", ""),
+		HostIP:         getEnv("HOST_IP", ""),
+		ServiceAccount: getEnv("SERVICE_ACCOUNT", ""),
+		Resources: PodResources{
+			CPURequestMillis:   getEnvInt64("CPU_REQUEST", 0),
+			CPULimitMillis:     getEnvInt64("CPU_LIMIT", 0),
+			MemoryRequestBytes: getEnvInt64("MEMORY_REQUEST", 0),
+			MemoryLimitBytes:   getEnvInt64("MEMORY_LIMIT", 0),
+		},
+		Dir: getEnv("PODINFO_DIR", "/etc/podinfo"),
+	}
+}
+
+// Labels returns the Pod's current labels and annotations.
+func (p *PodInfo) Labels() (labels, annotations map[string]string) {
+	if p.Dir == "" {
+		return nil, nil
+	}
+	return readDownwardFile(filepath.Join(p.Dir, "labels")), readDownwardFile(filepath.Join(p.Dir, "annotations"))
+}
+
+// readDownwardFile parses the Downward API's key="value" lines. The values
+// are quoted and escaped like Go strings.
+func readDownwardFile(path string) map[string]string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	values := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, quoted, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		value, err := strconv.Unquote(quoted)
+		if err != nil {
+			value = quoted
+		}
+		values[key] = value
+	}
+	return values
+}
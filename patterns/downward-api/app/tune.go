@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// Tuning is what GOMAXPROCS and GOMEMLIMIT ended up as, and where each
+// number came from.
+type Tuning struct {
+	GOMAXPROCS       int
+	GOMAXPROCSSource string
+	// GOMEMLIMIT is in bytes; math.MaxInt64 is no limit.
+	GOMEMLIMIT       int64
+	GOMEMLIMITSource string
+}
+
+// Tune sets GOMAXPROCS and GOMEMLIMIT from the container's limits, unless
+// the environment variables of the same names are set: the runtime has
+// applied those already, and an operator's explicit choice wins.
+//
+// The cgroup comes first: it is what the kernel enforces. The Downward API
+// is the fallback when no cgroup can be read, and only for limits it
+// reports, since without a limit it reports the node's allocatable
+// instead.
+func Tune(cg CgroupLimits, res PodResources, memoryRatio float64) Tuning {
+	numCPU := runtime.NumCPU()
+	var t Tuning
+
+	// Go before 1.25 sets GOMAXPROCS to the node's CPU count. With a
+	// 1.5-core quota on a 16-CPU node, 16 threads burn the period's quota
+	// in under 10ms, and the container is throttled for the other 90ms:
+	// latency spikes at low CPU usage. Rounded down, so the Go scheduler
+	// alone can't exceed the quota.
+	maxProcs := func(millis int64) int {
+		return min(numCPU, max(1, int(millis/1000)))
+	}
+	switch {
+	case os.Getenv("GOMAXPROCS") != "":
+		t.GOMAXPROCSSource = "the GOMAXPROCS environment variable"
+	case cg.CPUMillis > 0:
+		runtime.GOMAXPROCS(maxProcs(cg.CPUMillis))
+		t.GOMAXPROCSSource = fmt.Sprintf("the cgroup v%d CPU quota, %s; %d CPUs on the node", cg.Version, formatMillis(cg.CPUMillis), numCPU)
+	case cg.Version == 0 && res.CPULimitMillis > 0:
+		runtime.GOMAXPROCS(maxProcs(res.CPULimitMillis))
+		t.GOMAXPROCSSource = fmt.Sprintf("the Downward API limits.cpu, %s; %d CPUs on the node", formatMillis(res.CPULimitMillis), numCPU)
+	default:
+		t.GOMAXPROCSSource = fmt.Sprintf("no CPU limit: all %d CPUs", numCPU)
+	}
+	t.GOMAXPROCS = runtime.GOMAXPROCS(0)
+
+	// Without GOMEMLIMIT, the GC lets the heap grow to twice the live data
+	// (GOGC=100) and knows nothing of the limit: 40Mi live under a 64Mi
+	// limit is an OOM kill waiting to happen. With it, the GC works harder
+	// as the heap nears the limit. Below 100%, for the memory that isn't
+	// Go heap: stacks, the runtime's own, cgo.
+	memLimit := func(limit int64) int64 {
+		return int64(float64(limit) * memoryRatio)
+	}
+	switch {
+	case os.Getenv("GOMEMLIMIT") != "":
+		t.GOMEMLIMITSource = "the GOMEMLIMIT environment variable"
+	case cg.MemoryBytes > 0:
+		debug.SetMemoryLimit(memLimit(cg.MemoryBytes))
+		t.GOMEMLIMITSource = fmt.Sprintf("%.0f%% of the cgroup v%d memory limit, %s", memoryRatio*100, cg.Version, formatBytes(cg.MemoryBytes))
+	case cg.Version == 0 && res.MemoryLimitBytes > 0:
+		debug.SetMemoryLimit(memLimit(res.MemoryLimitBytes))
+		t.GOMEMLIMITSource = fmt.Sprintf("%.0f%% of the Downward API limits.memory, %s", memoryRatio*100, formatBytes(res.MemoryLimitBytes))
+	default:
+		t.GOMEMLIMITSource = "no memory limit"
+	}
+	t.GOMEMLIMIT = debug.SetMemoryLimit(-1)
+	return t
+}
+
+// formatMillis prints millicores the way a Pod spec would: "2", "1500m".
+func formatMillis(m int64) string {
+	if m%1000 == 0 {
+		return fmt.Sprintf("%d", m/1000)
+	}
+	return fmt.Sprintf("%dm", m)
+}
+
+// formatBytes prints a byte count in Mi or Gi, or "none" for no limit.
+func formatBytes(n int64) string {
+	switch {
+	case n <= 0 || n == math.MaxInt64:
+		return "none"
+	case n >= 1<<30:
+		return strings.TrimSuffix(fmt.Sprintf("%.1f", float64(n)/(1<<30)), ".0") + "Gi"
+	default:
+		return strings.TrimSuffix(fmt.Sprintf("%.1f", float64(n)/(1<<20)), ".0") + "Mi"
+	}
+}
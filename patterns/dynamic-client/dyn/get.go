@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/yaml"
+)
+
+// runResources lists what discovery returns, like kubectl api-resources.
+func runResources(_ context.Context, args []string) error {
+	fs := flag.NewFlagSet("resources", flag.ExitOnError)
+	var common commonFlags
+	common.register(fs)
+	if _, err := parseInterspersed(fs, args); err != nil {
+		return err
+	}
+	c, err := common.connect()
+	if err != nil {
+		return err
+	}
+	// Preferred versions only: one line per resource, not one per version.
+	lists, err := discovery.ServerPreferredResources(c.discovery)
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return err
+	}
+	if err != nil {
+		// An aggregated API whose backend is down (metrics-server, often)
+		// fails its group; the others are still usable.
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", err)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSHORTNAMES\tAPIVERSION\tNAMESPACED\tKIND\tVERBS")
+	for _, list := range lists {
+		for _, r := range list.APIResources {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%v\t%s\t%s\n", r.Name, strings.Join(r.ShortNames, ","),
+				list.GroupVersion, r.Namespaced, r.Kind, strings.Join(r.Verbs, ","))
+		}
+	}
+	return w.Flush()
+}
+
+func runGet(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("get", flag.ExitOnError)
+	var common commonFlags
+	common.register(fs)
+	selector := fs.String("l", "", "label selector")
+	all := fs.Bool("A", false, "all namespaces")
+	output := fs.String("o", "table", "output: table, yaml, json or name")
+	field := fs.String("field", "", "print this field of each object, as a dotted path (spec.replicas)")
+	managers := fs.Bool("managers", false, "print which field managers own which fields (server-side apply)")
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) < 1 || len(positional) > 2 {
+		return fmt.Errorf("usage: dyn get TYPE [NAME]")
+	}
+	c, err := common.connect()
+	if err != nil {
+		return err
+	}
+	mapping, err := c.resolver.ForArg(positional[0])
+	if err != nil {
+		return err
+	}
+
+	// The one branch every dynamic tool needs: namespaced resources live
+	// under /namespaces/<ns>/, cluster-scoped ones don't.
+	var ri dynamic.ResourceInterface = c.client.Resource(mapping.Resource)
+	namespaced := mapping.Scope.Name() == meta.RESTScopeNameNamespace
+	if namespaced && !*all {
+		ri = c.client.Resource(mapping.Resource).Namespace(c.namespace)
+	}
+
+	var items []unstructured.Unstructured
+	if len(positional) == 2 {
+		obj, err := ri.Get(ctx, positional[1], metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		items = append(items, *obj)
+	} else {
+		list, err := ri.List(ctx, metav1.ListOptions{LabelSelector: *selector})
+		if err != nil {
+			return err
+		}
+		items = list.Items
+	}
+
+	switch {
+	case *managers:
+		return printManagers(items)
+	case *field != "":
+		return printField(items, *field, namespaced && *all)
+	}
+	switch *output {
+	case "yaml", "json":
+		var v any = items
+		if len(items) == 1 && len(positional) == 2 {
+			v = items[0].Object
+		}
+		data, err := json.MarshalIndent(v, "", "  ")
+		if *output == "yaml" && err == nil {
+			data, err = yaml.JSONToYAML(data)
+		}
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(append(data, '\n'))
+		return err
+	case "name":
+		for _, obj := range items {
+			fmt.Printf("%s/%s\n", resourceName(mapping), obj.GetName())
+		}
+		return nil
+	case "table":
+		return printTable(items, namespaced && *all)
+	}
+	return fmt.Errorf("unknown output %q", *output)
+}
+
+// resourceName is kubectl's "deployment.apps" form, for messages.
+func resourceName(mapping *meta.RESTMapping) string {
+	kind := strings.ToLower(mapping.GroupVersionKind.Kind)
+	if g := mapping.GroupVersionKind.Group; g != "" {
+		return kind + "." + g
+	}
+	return kind
+}
+
+func printTable(items []unstructured.Unstructured, withNamespace bool) error {
+	if len(items) == 0 {
+		fmt.Println("No resources found")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	if withNamespace {
+		fmt.Fprint(w, "NAMESPACE\t")
+	}
+	fmt.Fprintln(w, "NAME\tAGE")
+	for _, obj := range items {
+		if withNamespace {
+			fmt.Fprintf(w, "%s\t", obj.GetNamespace())
+		}
+		age := duration.HumanDuration(time.Since(obj.GetCreationTimestamp().Time))
+		fmt.Fprintf(w, "%s\t%s\n", obj.GetName(), age)
+	}
+	return w.Flush()
+}
+
+// printField reads a nested field without knowing the type: the
+// unstructured.Nested* helpers walk map[string]any by key.
+func printField(items []unstructured.Unstructured, path string, withNamespace bool) error {
+	fields := strings.Split(path, ".")
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "NAME\t%s\n", strings.ToUpper(path))
+	for _, obj := range items {
+		name := obj.GetName()
+		if withNamespace {
+			name = obj.GetNamespace() + "/" + name
+		}
+		value, found, err := unstructured.NestedFieldNoCopy(obj.Object, fields...)
+		switch {
+		case err != nil:
+			fmt.Fprintf(w, "%s\t<error: %s>\n", name, err)
+		case !found:
+			fmt.Fprintf(w, "%s\t<none>\n", name)
+		default:
+			out, _ := json.Marshal(value)
+			fmt.Fprintf(w, "%s\t%s\n", name, out)
+		}
+	}
+	return w.Flush()
+}
+
+// printManagers shows metadata.managedFields: for each manager, the
+// fields it owns. This is what decides a server-side apply
+// conflict.
+func printManagers(items []unstructured.Unstructured) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tMANAGER\tOPERATION\tSUBRESOURCE\tFIELDS")
+	for _, obj := range items {
+		for _, m := range obj.GetManagedFields() {
+			var owned map[string]any
+			if m.FieldsV1 != nil {
+				json.Unmarshal(m.FieldsV1.Raw, &owned)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", obj.GetName(), m.Manager, m.Operation, m.Subresource, summarize(owned, ""))
+		}
+	}
+	return w.Flush()
+}
+
+// summarize turns a FieldsV1 set ({"f:spec":{"f:replicas":{}}}) into
+// "spec.replicas", listing the leaves two levels deep at most.
+func summarize(set map[string]any, prefix string) string {
+	var out []string
+	for _, k := range slices.Sorted(maps.Keys(set)) {
+		name, ok := strings.CutPrefix(k, "f:")
+		if !ok {
+			continue // k: (list keys) and v: (set values) entries
+		}
+		child, _ := set[k].(map[string]any)
+		if len(child) == 0 || strings.Count(prefix, ".") >= 1 {
+			out = append(out, prefix+name)
+			continue
+		}
+		if sub := summarize(child, prefix+name+"."); sub != "" {
+			out = append(out, sub)
+		} else {
+			out = append(out, prefix+name)
+		}
+	}
+	return strings.Join(out, ",")
+}
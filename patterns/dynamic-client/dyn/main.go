@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const usage = `dyn: read, patch and apply any Kubernetes resource, built-in or custom,
+with the dynamic client. No generated types: the tool doesn't know any Kind
+until discovery tells it.
+
+Usage:
+  dyn resources                             list every resource type the cluster serves
+  dyn get TYPE [NAME] [-l selector] [-A] [-o table|yaml|json|name] [--field path] [--managers]
+  dyn patch TYPE NAME -p PATCH [--type merge|json|strategic]
+  dyn apply -f FILE [--field-manager dyn] [--force-conflicts] [--dry-run]
+
+TYPE is anything kubectl takes: deploy, deployments, deployments.apps,
+deployments.v1.apps, Deployment.
+
+Common flags: --kubeconfig, --context, -n/--namespace
+`
+
+// cluster is what every command needs: a dynamic client, a resolver to
+// find out what to call it with, and the namespace to default to.
+type cluster struct {
+	client    dynamic.Interface
+	discovery discovery.DiscoveryInterface
+	resolver  *Resolver
+	namespace string
+}
+
+type commonFlags struct {
+	kubeconfig, context, namespace string
+}
+
+func (c *commonFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&c.kubeconfig, "kubeconfig", "", "path to the kubeconfig (default: $KUBECONFIG, then ~/.kube/config, then in-cluster)")
+	fs.StringVar(&c.context, "context", "", "kubeconfig context to use")
+	fs.StringVar(&c.namespace, "namespace", "", "namespace (default: the context's, or the Pod's in a cluster)")
+	fs.StringVar(&c.namespace, "n", "", "shorthand for --namespace")
+}
+
+// connect loads the client config the way kubectl does, falling back to
+// the in-cluster ServiceAccount when there is no kubeconfig, so the same
+// binary works on a laptop and in a Job (see manifests/).
+func (c *commonFlags) connect() (*cluster, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = c.kubeconfig
+	config := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{CurrentContext: c.context})
+	rest, err := config.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	namespace := c.namespace
+	if namespace == "" {
+		if namespace, _, err = config.Namespace(); err != nil {
+			return nil, err
+		}
+	}
+	client, err := dynamic.NewForConfig(rest)
+	if err != nil {
+		return nil, err
+	}
+	disc, err := discovery.NewDiscoveryClientForConfig(rest)
+	if err != nil {
+		return nil, err
+	}
+	return &cluster{client: client, discovery: disc, resolver: NewResolver(disc), namespace: namespace}, nil
+}
+
+// parseInterspersed parses flags wherever they are among the arguments,
+// as in "dyn get deploy web -n demo", and returns the positional ones.
+// The standard flag package stops at the first non-flag.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Print(usage)
+		os.Exit(2)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	var err error
+	switch cmd, args := os.Args[1], os.Args[2:]; cmd {
+	case "resources":
+		err = runResources(ctx, args)
+	case "get":
+		err = runGet(ctx, args)
+	case "patch":
+		err = runPatch(ctx, args)
+	case "apply":
+		err = runApply(ctx, args)
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+	default:
+		fmt.Printf("Unknown command %q\n\n%s", cmd, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/restmapper"
+)
+
+// Resolver maps what a user types, or what a manifest says, to the REST
+// resource to call. The dynamic client only speaks GroupVersionResource
+// (apps/v1, deployments); users and YAML speak names and Kinds.
+//
+//	"deploy"               → shortname, from discovery
+//	"deployments.apps"     → resource.group
+//	"deployments.v1.apps"  → resource.version.group
+//	"Deployment"           → a Kind, or its singular
+//	apiVersion: apps/v1, kind: Deployment   (a manifest)
+//
+// All of them end in a RESTMapping: the GVR, the GVK and whether the
+// resource is namespaced, which decides the URL.
+type Resolver struct {
+	mapper *restmapper.DeferredDiscoveryRESTMapper
+	// shortcuts wraps mapper with the short names from discovery
+	// ("deploy", "cm"), the way kubectl resolves them.
+	shortcuts meta.RESTMapper
+}
+
+func NewResolver(client discovery.DiscoveryInterface) *Resolver {
+	// Discovery is one request per API group; the memory cache makes it
+	// once per run. kubectl keeps it on disk, in ~/.kube/cache, for 6h.
+	cached := memory.NewMemCacheClient(client)
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(cached)
+	return &Resolver{
+		mapper:    mapper,
+		shortcuts: restmapper.NewShortcutExpander(mapper, cached, func(msg string) { fmt.Println("Warning:", msg) }),
+	}
+}
+
+// ForArg resolves a resource argument, as in "dyn get deploy".
+func (r *Resolver) ForArg(arg string) (*meta.RESTMapping, error) {
+	fullGVR, gr := schema.ParseResourceArg(arg)
+	var gvr schema.GroupVersionResource
+	var err error
+	if fullGVR != nil {
+		// "a.b.c" is ambiguous: resource.version.group, or resource.group
+		// with a dotted group. Try the first, fall back to the second.
+		gvr, err = r.shortcuts.ResourceFor(*fullGVR)
+	}
+	if fullGVR == nil || err != nil {
+		if gvr, err = r.shortcuts.ResourceFor(gr.WithVersion("")); err != nil {
+			return nil, fmt.Errorf("resource type %q: %w", arg, err)
+		}
+	}
+	gvk, err := r.mapper.KindFor(gvr)
+	if err != nil {
+		return nil, err
+	}
+	return r.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+}
+
+// ForKind resolves the apiVersion and kind of a manifest. A Kind the
+// cached discovery doesn't know, such as a CRD applied earlier in the same
+// run, gets one retry with fresh discovery.
+func (r *Resolver) ForKind(gvk schema.GroupVersionKind) (*meta.RESTMapping, error) {
+	mapping, err := r.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if meta.IsNoMatchError(err) {
+		r.mapper.Reset()
+		mapping, err = r.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", gvk, err)
+	}
+	return mapping, nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var errNoEndpoints = errors.New("no endpoints available")
+
+// Balancer picks an endpoint for each request: round-robin over the
+// endpoints in the client's own zone, or over all of them when the zone
+// has fewer than MinLocal. An endpoint that fails a request is ejected
+// for EjectFor: the EndpointSlice only changes once its Pod fails a
+// readiness probe, which takes seconds the client doesn't have to wait.
+type Balancer struct {
+	Resolver *Resolver
+	Zone     string
+	MinLocal int
+	EjectFor time.Duration
+
+	next    atomic.Uint64
+	mu      sync.Mutex
+	ejected map[string]time.Time
+}
+
+// Pick returns the endpoint for the next request.
+func (b *Balancer) Pick() (Endpoint, error) {
+	all := b.available()
+	if len(all) == 0 {
+		return Endpoint{}, errNoEndpoints
+	}
+	candidates := all
+	if b.Zone != "" {
+		var local []Endpoint
+		for _, e := range all {
+			if e.Zone == b.Zone {
+				local = append(local, e)
+			}
+		}
+		// Too few local endpoints would take the zone's whole load: spill
+		// over to every zone instead.
+		if len(local) >= max(b.MinLocal, 1) {
+			candidates = local
+		}
+	}
+	return candidates[b.next.Add(1)%uint64(len(candidates))], nil
+}
+
+// available is the Resolver's endpoints, minus the ejected ones. If every
+// endpoint is ejected, none is: a guess beats certain failure.
+func (b *Balancer) available() []Endpoint {
+	endpoints := b.Resolver.Endpoints()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	var out []Endpoint
+	for _, e := range endpoints {
+		if until, ok := b.ejected[e.Address]; ok {
+			if now.Before(until) {
+				continue
+			}
+			delete(b.ejected, e.Address)
+		}
+		out = append(out, e)
+	}
+	if len(out) == 0 {
+		return endpoints
+	}
+	return out
+}
+
+// Failed ejects the endpoint for a while.
+func (b *Balancer) Failed(e Endpoint) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.ejected == nil {
+		b.ejected = map[string]time.Time{}
+	}
+	if _, ok := b.ejected[e.Address]; !ok {
+		ejectionsTotal.Inc()
+	}
+	b.ejected[e.Address] = time.Now().Add(b.EjectFor)
+}
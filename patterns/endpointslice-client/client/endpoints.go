@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	discoveryinformers "k8s.io/client-go/informers/discovery/v1"
+	discoverylisters "k8s.io/client-go/listers/discovery/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Endpoint is one backend Pod, as its EndpointSlice describes it.
+type Endpoint struct {
+	Address string // host:port, ready to dial
+	Pod     string
+	Zone    string
+	Node    string
+	// Serving but not Ready: the Pod is terminating. Only used when no
+	// endpoint is Ready, as kube-proxy does.
+	Terminating bool
+}
+
+// Resolver keeps the endpoints of one Service port, from its
+// EndpointSlices. A Service has one slice or more (100 endpoints each by
+// default, one per address family): they are merged into one list,
+// rebuilt on every change and swapped atomically, so readers never lock.
+type Resolver struct {
+	PortName string
+
+	lister    discoverylisters.EndpointSliceLister
+	synced    cache.InformerSynced
+	endpoints atomic.Pointer[[]Endpoint]
+	mu        sync.Mutex // serializes rebuilds
+	last      string
+}
+
+// NewResolver registers with the informer. The informer must only hold
+// the Service's slices: its factory selects on the service-name label.
+func NewResolver(informer discoveryinformers.EndpointSliceInformer, portName string) (*Resolver, error) {
+	r := &Resolver{PortName: portName, lister: informer.Lister(), synced: informer.Informer().HasSynced}
+	r.endpoints.Store(&[]Endpoint{})
+	rebuild := func(any) { r.rebuild() }
+	_, err := informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    rebuild,
+		UpdateFunc: func(_, obj any) { r.rebuild() },
+		DeleteFunc: rebuild,
+	})
+	return r, err
+}
+
+// Endpoints returns the current endpoints. The slice is shared: don't
+// modify it.
+func (r *Resolver) Endpoints() []Endpoint {
+	return *r.endpoints.Load()
+}
+
+func (r *Resolver) HasSynced() bool {
+	return r.synced()
+}
+
+func (r *Resolver) rebuild() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	list, err := r.lister.List(labels.Everything())
+	if err != nil {
+		return
+	}
+	var ready, serving []Endpoint
+	seen := map[string]bool{}
+	for _, slice := range list {
+		if slice.AddressType != discoveryv1.AddressTypeIPv4 && slice.AddressType != discoveryv1.AddressTypeIPv6 {
+			continue // FQDN slices are for ExternalName-like setups
+		}
+		port, ok := findPort(slice, r.PortName)
+		if !ok {
+			continue
+		}
+		for _, ep := range slice.Endpoints {
+			if len(ep.Addresses) == 0 {
+				continue
+			}
+			// An endpoint moving between slices can be in both for a moment.
+			addr := joinHostPort(ep.Addresses[0], port)
+			if seen[addr] {
+				continue
+			}
+			seen[addr] = true
+			e := Endpoint{Address: addr, Zone: deref(ep.Zone), Node: deref(ep.NodeName)}
+			if ep.TargetRef != nil {
+				e.Pod = ep.TargetRef.Name
+			}
+			// Ready is nil when unknown, which means ready.
+			switch {
+			case ep.Conditions.Ready == nil || *ep.Conditions.Ready:
+				ready = append(ready, e)
+			case ep.Conditions.Serving != nil && *ep.Conditions.Serving:
+				e.Terminating = true
+				serving = append(serving, e)
+			}
+		}
+	}
+	endpoints := ready
+	if len(endpoints) == 0 {
+		endpoints = serving
+	}
+	slices.SortFunc(endpoints, func(a, b Endpoint) int { return strings.Compare(a.Address, b.Address) })
+	r.endpoints.Store(&endpoints)
+	endpointsGauge.Reset()
+	for _, e := range endpoints {
+		endpointsGauge.WithLabelValues(zoneOrUnknown(e.Zone)).Inc()
+	}
+
+	if summary := summarize(endpoints); summary != r.last {
+		r.last = summary
+		fmt.Printf("Endpoints: %s\n", summary)
+	}
+}
+
+func findPort(slice *discoveryv1.EndpointSlice, name string) (int32, bool) {
+	for _, p := range slice.Ports {
+		if deref(p.Name) == name && p.Port != nil {
+			return *p.Port, true
+		}
+	}
+	return 0, false
+}
+
+// summarize is one line per change: "6 (zone-a 2, zone-b 2, zone-c 2)".
+func summarize(endpoints []Endpoint) string {
+	if len(endpoints) == 0 {
+		return "none"
+	}
+	byZone := map[string]int{}
+	for _, e := range endpoints {
+		byZone[zoneOrUnknown(e.Zone)]++
+	}
+	zones := make([]string, 0, len(byZone))
+	for z, n := range byZone {
+		zones = append(zones, fmt.Sprintf("%s %d", z, n))
+	}
+	slices.Sort(zones)
+	s := fmt.Sprintf("%d", len(endpoints))
+	if len(endpoints) > 0 && endpoints[0].Terminating {
+		s += " terminating"
+	}
+	return s + " (" + strings.Join(zones, ", ") + ")"
+}
+
+func joinHostPort(ip string, port int32) string {
+	if strings.Contains(ip, ":") {
+		return fmt.Sprintf("[%s]:%d", ip, port)
+	}
+	return fmt.Sprintf("%s:%d", ip, port)
+}
+
+func zoneOrUnknown(zone string) string {
+	if zone == "" {
+		return "unknown"
+	}
+	return zone
+}
+
+func deref[T any](p *T) T {
+	var zero T
+	if p == nil {
+		return zero
+	}
+	return *p
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func getEnvInt(key string, fallback int) int {
+	if value, ok := os.LookupEnv(key); ok {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+		fmt.Printf("Invalid %s=%q, using default %d\n", key, value, fallback)
+	}
+	return fallback
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	if value, ok := os.LookupEnv(key); ok {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+		fmt.Printf("Invalid %s=%q, using default %t\n", key, value, fallback)
+	}
+	return fallback
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if value, ok := os.LookupEnv(key); ok {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+		fmt.Printf("Invalid %s=%q, using default %s\n", key, value, fallback)
+	}
+	return fallback
+}
+
+// ownZone is the zone of the node the Pod runs on: its
+// topology.kubernetes.io/zone label. The downward API can't expose node
+// labels, so it is read from the Node. ZONE overrides it.
+func ownZone(ctx context.Context, client kubernetes.Interface) (string, error) {
+	if zone := os.Getenv("ZONE"); zone != "" {
+		return zone, nil
+	}
+	nodeName := os.Getenv("NODE_NAME")
+	if nodeName == "" {
+		return "", nil
+	}
+	node, err := client.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	return node.Labels[corev1.LabelTopologyZone], nil
+}
+
+func main() {
+	listenAddr := getEnv("LISTEN_ADDR", ":8080")
+	service := getEnv("TARGET_SERVICE", "echo")
+	namespace := getEnv("TARGET_NAMESPACE", getEnv("POD_NAMESPACE", "default"))
+	path := getEnv("REQUEST_PATH", "/hostname")
+	rate := getEnvInt("RATE", 10)
+	reportInterval := getEnvDuration("REPORT_INTERVAL", 10*time.Second)
+	timeout := getEnvDuration("REQUEST_TIMEOUT", 2*time.Second)
+
+	// Authenticates as the Pod's ServiceAccount; see manifests/rbac.yaml
+	// for what it may do.
+	cfg, err := rest.InClusterConfig()
+	if err != nil {
+		fmt.Printf("Error loading in-cluster config: %s\n", err)
+		os.Exit(1)
+	}
+	client, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		fmt.Printf("Error creating Kubernetes client: %s\n", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	zone, err := ownZone(ctx, client)
+	if err != nil {
+		fmt.Printf("Error reading this node's zone: %s\n", err)
+		os.Exit(1)
+	}
+
+	// Only the target Service's slices: EndpointSlices carry their
+	// Service's name in a label, and the watch selects on it. Watching
+	// every slice of the cluster would cost memory for nothing.
+	factory := informers.NewSharedInformerFactoryWithOptions(client, 0,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(o *metav1.ListOptions) {
+			o.LabelSelector = discoveryv1.LabelServiceName + "=" + service
+		}))
+	resolver, err := NewResolver(factory.Discovery().V1().EndpointSlices(), getEnv("TARGET_PORT_NAME", "http"))
+	if err != nil {
+		fmt.Printf("Error setting up the resolver: %s\n", err)
+		os.Exit(1)
+	}
+	factory.Start(ctx.Done())
+	defer factory.Shutdown()
+	if !cache.WaitForCacheSync(ctx.Done(), resolver.HasSynced) {
+		fmt.Println("Error: EndpointSlice cache never synced")
+		os.Exit(1)
+	}
+
+	balancer := &Balancer{
+		Resolver: resolver,
+		Zone:     zone,
+		MinLocal: getEnvInt("MIN_LOCAL_ENDPOINTS", 1),
+		EjectFor: getEnvDuration("EJECT_DURATION", 10*time.Second),
+	}
+	routes := []*route{clientSideRoute(balancer, &http.Client{Timeout: timeout}, path)}
+
+	// Requests to a VIP, for comparison. kube-proxy picks a backend per
+	// connection, not per request: with keep-alive, every request of a
+	// connection goes to the same Pod. Off by default, to see kube-proxy's
+	// spread.
+	vipClient := &http.Client{Timeout: timeout, Transport: &http.Transport{DisableKeepAlives: !getEnvBool("VIP_KEEPALIVE", false)}}
+	for _, url := range strings.Split(getEnv("VIP_URLS", "http://"+service), ",") {
+		if url = strings.TrimSpace(url); url != "" {
+			routes = append(routes, vipRoute(url, resolver, vipClient, path))
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", promhttp.Handler())
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) { w.Write([]byte("ok")) })
+	server := &http.Server{Addr: listenAddr, Handler: mux}
+	go func() {
+		if err := server.ListenAndServe(); err != http.ErrServerClosed {
+			fmt.Printf("Error starting server: %s\n", err)
+			os.Exit(1)
+		}
+	}()
+
+	fmt.Printf("Sending %d req/s per route to service %s/%s from zone %q; listening on %s\n",
+		rate, namespace, service, zone, listenAddr)
+	for _, r := range routes {
+		go r.run(ctx, rate)
+	}
+	ticker := time.NewTicker(reportInterval)
+	defer ticker.Stop()
+	for done := false; !done; {
+		select {
+		case <-ctx.Done():
+			done = true
+		case <-ticker.C:
+			fmt.Printf("--- last %s, from zone %s\n", reportInterval, zoneOrUnknown(zone))
+			for _, r := range routes {
+				fmt.Println(r.report())
+			}
+		}
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server.Shutdown(shutdownCtx)
+	fmt.Println("Shutting down...")
+}
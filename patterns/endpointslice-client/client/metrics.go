@@ -0,0 +1,35 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Metrics are registered with the default registry via 'promauto'.
+var (
+	requestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "endpointslice_client_requests_total",
+		Help: "Successful requests, by route (client-side, or a VIP's URL) and by the zone of the Pod that answered.",
+	}, []string{"route", "zone"})
+
+	requestErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "endpointslice_client_request_errors_total",
+		Help: "Failed requests, by route.",
+	}, []string{"route"})
+
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "endpointslice_client_request_duration_seconds",
+		Help:    "Request latency, by route. Cross-zone hops show here.",
+		Buckets: prometheus.ExponentialBuckets(0.0005, 2, 14),
+	}, []string{"route"})
+
+	endpointsGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "endpointslice_client_endpoints",
+		Help: "Endpoints the balancer can use, by zone.",
+	}, []string{"zone"})
+
+	ejectionsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "endpointslice_client_ejections_total",
+		Help: "Endpoints taken out of rotation after a failed request.",
+	})
+)
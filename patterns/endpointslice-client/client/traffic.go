@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// route is one way of reaching the Service: the client-side balancer, or
+// a Service VIP through kube-proxy. It counts where its requests landed.
+type route struct {
+	name string
+	send func(ctx context.Context) (pod, zone string, err error)
+
+	mu     sync.Mutex
+	byZone map[string]int
+	pods   map[string]bool
+	failed int
+}
+
+func (r *route) record(pod, zone string, err error, elapsed time.Duration) {
+	requestDuration.WithLabelValues(r.name).Observe(elapsed.Seconds())
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		requestErrors.WithLabelValues(r.name).Inc()
+		r.failed++
+		return
+	}
+	requestsTotal.WithLabelValues(r.name, zone).Inc()
+	if r.byZone == nil {
+		r.byZone, r.pods = map[string]int{}, map[string]bool{}
+	}
+	r.byZone[zone]++
+	r.pods[pod] = true
+}
+
+// report returns a line for the period, and starts a new one:
+// "client-side    100 ok, 0 failed  zone-a 100% (2 pods)".
+func (r *route) report() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	total := 0
+	for _, n := range r.byZone {
+		total += n
+	}
+	var zones []string
+	for zone, n := range r.byZone {
+		zones = append(zones, fmt.Sprintf("%s %3.0f%%", zone, 100*float64(n)/float64(total)))
+	}
+	slices.Sort(zones)
+	line := fmt.Sprintf("%-28s %4d ok, %d failed  %s (%d pods)", r.name, total, r.failed, strings.Join(zones, "  "), len(r.pods))
+	r.byZone, r.pods, r.failed = nil, nil, 0
+	return line
+}
+
+// run sends rate requests a second, one at a time, until ctx is done.
+func (r *route) run(ctx context.Context, rate int) {
+	ticker := time.NewTicker(time.Second / time.Duration(max(rate, 1)))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		start := time.Now()
+		pod, zone, err := r.send(ctx)
+		r.record(pod, zone, err, time.Since(start))
+	}
+}
+
+// get fetches url, and returns the body: the answering Pod's hostname,
+// which is its name, for the demo's backend.
+func get(ctx context.Context, client *http.Client, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 256))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s answered %s", url, resp.Status)
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+// clientSideRoute sends each request straight to a Pod's IP, picked by
+// the balancer. kube-proxy isn't involved: no VIP, no NAT.
+func clientSideRoute(b *Balancer, client *http.Client, path string) *route {
+	return &route{name: "client-side", send: func(ctx context.Context) (string, string, error) {
+		e, err := b.Pick()
+		if err != nil {
+			return "", "", err
+		}
+		pod, err := get(ctx, client, "http://"+e.Address+path)
+		if err != nil {
+			b.Failed(e)
+			return "", "", err
+		}
+		return pod, zoneOrUnknown(e.Zone), nil
+	}}
+}
+
+// vipRoute sends each request to a Service's ClusterIP, and lets
+// kube-proxy pick. The answering Pod's zone is looked up by its name in
+// the resolver's endpoints.
+func vipRoute(url string, r *Resolver, client *http.Client, path string) *route {
+	return &route{name: url, send: func(ctx context.Context) (string, string, error) {
+		pod, err := get(ctx, client, url+path)
+		if err != nil {
+			return "", "", err
+		}
+		zone := "unknown"
+		for _, e := range r.Endpoints() {
+			if e.Pod == pod {
+				zone = zoneOrUnknown(e.Zone)
+				break
+			}
+		}
+		return pod, zone, nil
+	}}
+}
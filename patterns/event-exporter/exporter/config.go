@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Config is the exporter's config file (CONFIG_FILE, mounted from the
+// ConfigMap in manifests/config.yaml): the sinks, and which Events each
+// one gets.
+type Config struct {
+	Sinks []SinkConfig `json:"sinks"`
+}
+
+// SinkConfig is one destination. An Event goes to the sink if it matches
+// any of the match rules (or there are none) and none of the exclude rules.
+type SinkConfig struct {
+	Name string `json:"name"`
+	// Type is stdout, http or slack.
+	Type string `json:"type"`
+	// URL, or the environment variable holding it: a Slack webhook URL is a
+	// secret and belongs in a Secret, not in the ConfigMap.
+	URL        string `json:"url,omitempty"`
+	URLFromEnv string `json:"urlFromEnv,omitempty"`
+
+	Match   []Rule `json:"match,omitempty"`
+	Exclude []Rule `json:"exclude,omitempty"`
+
+	// RateLimit caps the Events the sink accepts; the rest are dropped and
+	// counted. Unset means no limit.
+	RateLimit *RateLimit `json:"rateLimit,omitempty"`
+	// QueueSize is how many Events may wait for a slow sink. Default 1000.
+	QueueSize int `json:"queueSize,omitempty"`
+	// MaxRetries is how often a failed delivery is retried. Default 3.
+	MaxRetries *int `json:"maxRetries,omitempty"`
+}
+
+// RateLimit is a token bucket: PerMinute on average, Burst at once.
+type RateLimit struct {
+	PerMinute int `json:"perMinute"`
+	Burst     int `json:"burst,omitempty"`
+}
+
+// Rule matches an Event when every field that is set matches. Each field
+// is a regular expression matched against the whole value, except Message,
+// which may match anywhere in it.
+type Rule struct {
+	Type      string `json:"type,omitempty"`
+	Kind      string `json:"kind,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+	// Component is the controller that reported the Event: kubelet,
+	// default-scheduler, deployment-controller...
+	Component string `json:"component,omitempty"`
+	Message   string `json:"message,omitempty"`
+}
+
+// LoadConfig reads and validates the config file. Unknown fields are an
+// error: a typo in a rule would otherwise silently match everything.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg Config
+	if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if len(cfg.Sinks) == 0 {
+		return nil, fmt.Errorf("%s: no sinks", path)
+	}
+	names := map[string]bool{}
+	for _, s := range cfg.Sinks {
+		if s.Name == "" {
+			return nil, fmt.Errorf("%s: a sink has no name", path)
+		}
+		if names[s.Name] {
+			return nil, fmt.Errorf("%s: two sinks named %q", path, s.Name)
+		}
+		names[s.Name] = true
+	}
+	return &cfg, nil
+}
+
+// filter is a sink's compiled rules.
+type filter struct {
+	match, exclude []compiledRule
+}
+
+type compiledRule struct {
+	typ, kind, namespace, reason, component, message *regexp.Regexp
+}
+
+func newFilter(match, exclude []Rule) (*filter, error) {
+	f := &filter{}
+	for i, r := range match {
+		c, err := compileRule(r)
+		if err != nil {
+			return nil, fmt.Errorf("match[%d]: %w", i, err)
+		}
+		f.match = append(f.match, c)
+	}
+	for i, r := range exclude {
+		c, err := compileRule(r)
+		if err != nil {
+			return nil, fmt.Errorf("exclude[%d]: %w", i, err)
+		}
+		f.exclude = append(f.exclude, c)
+	}
+	return f, nil
+}
+
+func compileRule(r Rule) (compiledRule, error) {
+	var c compiledRule
+	var err error
+	anchored := func(field, expr string) *regexp.Regexp {
+		if expr == "" || err != nil {
+			return nil
+		}
+		var re *regexp.Regexp
+		if re, err = regexp.Compile("^(?:" + expr + ")$"); err != nil {
+			err = fmt.Errorf("%s: %w", field, err)
+		}
+		return re
+	}
+	c.typ = anchored("type", r.Type)
+	c.kind = anchored("kind", r.Kind)
+	c.namespace = anchored("namespace", r.Namespace)
+	c.reason = anchored("reason", r.Reason)
+	c.component = anchored("component", r.Component)
+	if r.Message != "" && err == nil {
+		if c.message, err = regexp.Compile(r.Message); err != nil {
+			err = fmt.Errorf("message: %w", err)
+		}
+	}
+	return c, err
+}
+
+// Allows reports whether the sink wants the record.
+func (f *filter) Allows(rec *Record) bool {
+	for _, r := range f.exclude {
+		if r.matches(rec) {
+			return false
+		}
+	}
+	if len(f.match) == 0 {
+		return true
+	}
+	for _, r := range f.match {
+		if r.matches(rec) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c compiledRule) matches(rec *Record) bool {
+	for _, m := range []struct {
+		re    *regexp.Regexp
+		value string
+	}{
+		{c.typ, rec.Type},
+		{c.kind, rec.Object.Kind},
+		{c.namespace, rec.Object.Namespace},
+		{c.reason, rec.Reason},
+		{c.component, rec.Component},
+		{c.message, rec.Message},
+	} {
+		if m.re != nil && !m.re.MatchString(m.value) {
+			return false
+		}
+	}
+	return true
+}
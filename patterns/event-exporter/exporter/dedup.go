@@ -0,0 +1,67 @@
+package main
+
+import "time"
+
+// Deduplicator lets one occurrence of each Event through per window and
+// holds back the rest. A Pod in CrashLoopBackOff emits BackOff every few
+// seconds; with a 5m window the sinks see it once every 5 minutes, with
+// the number of repeats in between.
+//
+// It isn't safe for concurrent use. It needs no lock: the informer calls
+// one handler's functions one at a time.
+type Deduplicator struct {
+	window    time.Duration
+	seen      map[string]*dedupEntry
+	lastPrune time.Time
+}
+
+type dedupEntry struct {
+	exported   time.Time
+	suppressed int32
+}
+
+func NewDeduplicator(window time.Duration) *Deduplicator {
+	return &Deduplicator{window: window, seen: map[string]*dedupEntry{}}
+}
+
+// Admit records n new occurrences of key. It reports whether to export
+// them, and if so how many earlier ones were held back since the last
+// export. A window of 0 admits everything.
+func (d *Deduplicator) Admit(key string, n int32, now time.Time) (bool, int32) {
+	if d.window <= 0 {
+		return true, 0
+	}
+	entry, ok := d.seen[key]
+	if ok && now.Sub(entry.exported) < d.window {
+		entry.suppressed += n
+		return false, 0
+	}
+	var suppressed int32
+	if ok {
+		suppressed = entry.suppressed
+	}
+	d.seen[key] = &dedupEntry{exported: now}
+	d.prune(now)
+	return true, suppressed
+}
+
+// prune forgets keys whose window has passed, at most once per window, so
+// the map holds roughly one window's worth of distinct Events. A key with
+// repeats held back is kept for an hour, the API server's default Event
+// TTL, so they are reported if it comes back. If it doesn't, they never
+// are; the metrics still count them.
+func (d *Deduplicator) prune(now time.Time) {
+	if now.Sub(d.lastPrune) < d.window {
+		return
+	}
+	d.lastPrune = now
+	for key, entry := range d.seen {
+		keep := d.window
+		if entry.suppressed > 0 {
+			keep = max(d.window, time.Hour)
+		}
+		if now.Sub(entry.exported) >= keep {
+			delete(d.seen, key)
+		}
+	}
+}
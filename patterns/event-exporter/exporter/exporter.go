@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Exporter turns informer notifications into Records, deduplicates them,
+// and hands each one to the sinks whose rules it matches. Every sink has
+// its own queue and goroutine, so a slow or failing sink never holds up
+// the informer or the other sinks.
+type Exporter struct {
+	cluster string
+	// maxAge skips Events that last happened longer ago than this: the
+	// initial list, and every relist, replays up to an hour of history.
+	maxAge time.Duration
+	dedup  *Deduplicator
+	routes []*route
+	now    func() time.Time
+
+	// sendCtx is cancelled when Stop gives up on draining the queues.
+	sendCtx    context.Context
+	cancelSend context.CancelFunc
+	workers    sync.WaitGroup
+}
+
+// route is one sink with its rules, rate limit and queue.
+type route struct {
+	name       string
+	sink       Sink
+	filter     *filter
+	limiter    *rate.Limiter // nil: no limit
+	queue      chan *Record
+	maxRetries int
+}
+
+func NewExporter(cfg *Config, cluster string, maxAge, dedupWindow time.Duration) (*Exporter, error) {
+	e := &Exporter{cluster: cluster, maxAge: maxAge, dedup: NewDeduplicator(dedupWindow), now: time.Now}
+	for _, sc := range cfg.Sinks {
+		sink, err := NewSink(sc)
+		if err != nil {
+			return nil, fmt.Errorf("sink %s: %w", sc.Name, err)
+		}
+		f, err := newFilter(sc.Match, sc.Exclude)
+		if err != nil {
+			return nil, fmt.Errorf("sink %s: %w", sc.Name, err)
+		}
+		r := &route{name: sc.Name, sink: sink, filter: f, queue: make(chan *Record, 1000), maxRetries: 3}
+		if sc.QueueSize > 0 {
+			r.queue = make(chan *Record, sc.QueueSize)
+		}
+		if sc.MaxRetries != nil {
+			r.maxRetries = *sc.MaxRetries
+		}
+		if rl := sc.RateLimit; rl != nil && rl.PerMinute > 0 {
+			r.limiter = rate.NewLimiter(rate.Limit(float64(rl.PerMinute)/60), max(rl.Burst, 1))
+		}
+		e.routes = append(e.routes, r)
+	}
+	return e, nil
+}
+
+// Handler is the informer's event handler. Deletions are ignored: the API
+// server deletes Events when they expire, an hour after they last
+// happened, and that is nothing to report.
+func (e *Exporter) Handler() cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(obj any, _ bool) {
+			if ev, ok := obj.(*corev1.Event); ok {
+				e.handle(ev, occurrences(ev))
+			}
+		},
+		UpdateFunc: func(oldObj, newObj any) {
+			old, ok1 := oldObj.(*corev1.Event)
+			ev, ok2 := newObj.(*corev1.Event)
+			if !ok1 || !ok2 {
+				return
+			}
+			// Repeats bump the count of the same Event. An update that
+			// doesn't, e.g. a relist, is nothing new.
+			if n := occurrences(ev) - occurrences(old); n > 0 {
+				e.handle(ev, n)
+			}
+		},
+	}
+}
+
+// handle exports n new occurrences of ev.
+func (e *Exporter) handle(ev *corev1.Event, n int32) {
+	rec := newRecord(e.cluster, ev)
+	now := e.now()
+	if e.maxAge > 0 && now.Sub(rec.Time) > e.maxAge {
+		eventsSeen.WithLabelValues("too_old").Add(float64(n))
+		return
+	}
+	admit, suppressed := e.dedup.Admit(rec.dedupKey(), n, now)
+	if !admit {
+		eventsSeen.WithLabelValues("deduplicated").Add(float64(n))
+		return
+	}
+	rec.Suppressed = suppressed
+
+	matched := false
+	for _, r := range e.routes {
+		if !r.filter.Allows(rec) {
+			continue
+		}
+		matched = true
+		r.enqueue(rec)
+	}
+	if matched {
+		eventsSeen.WithLabelValues("exported").Add(float64(n))
+	} else {
+		eventsSeen.WithLabelValues("filtered").Add(float64(n))
+	}
+}
+
+// enqueue never blocks: the informer must keep up with the watch, so an
+// Event over the rate limit, or for a full queue, is dropped and counted.
+func (r *route) enqueue(rec *Record) {
+	if r.limiter != nil && !r.limiter.Allow() {
+		dropped.WithLabelValues(r.name, "rate_limited").Inc()
+		return
+	}
+	select {
+	case r.queue <- rec:
+		queueLength.WithLabelValues(r.name).Set(float64(len(r.queue)))
+	default:
+		dropped.WithLabelValues(r.name, "queue_full").Inc()
+	}
+}
+
+// Start starts one sender per sink.
+func (e *Exporter) Start() {
+	e.sendCtx, e.cancelSend = context.WithCancel(context.Background())
+	for _, r := range e.routes {
+		e.workers.Add(1)
+		go func() {
+			defer e.workers.Done()
+			r.run(e.sendCtx)
+		}()
+	}
+}
+
+// Stop closes the queues and waits up to timeout for the senders to empty
+// them. Call it after the informer has stopped: a handler still running
+// would send on a closed queue.
+func (e *Exporter) Stop(timeout time.Duration) {
+	for _, r := range e.routes {
+		close(r.queue)
+	}
+	done := make(chan struct{})
+	go func() {
+		e.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		e.cancelSend()
+		<-done
+	}
+	e.cancelSend()
+}
+
+func (r *route) run(ctx context.Context) {
+	for rec := range r.queue {
+		queueLength.WithLabelValues(r.name).Set(float64(len(r.queue)))
+		if err := r.deliver(ctx, rec); err != nil {
+			dropped.WithLabelValues(r.name, "send_failed").Inc()
+			fmt.Printf("Sink %s: dropping %s %s/%s: %s\n", r.name, rec.Reason, rec.Object.Kind, rec.Object.Name, err)
+			continue
+		}
+		sent.WithLabelValues(r.name).Inc()
+	}
+}
+
+// deliver sends rec, retrying with backoff (or the sink's Retry-After).
+// While it waits, this sink's queue waits too; the other sinks don't.
+func (r *route) deliver(ctx context.Context, rec *Record) error {
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		err := r.sink.Send(ctx, rec)
+		sendDuration.WithLabelValues(r.name).Observe(time.Since(start).Seconds())
+		if err == nil {
+			return nil
+		}
+		delay, retry := retryDelay(err, attempt)
+		if !retry || attempt > r.maxRetries {
+			return err
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if value, ok := os.LookupEnv(key); ok {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+		fmt.Printf("Invalid %s=%q, using default %s\n", key, value, fallback)
+	}
+	return fallback
+}
+
+// inClusterClient returns a client authenticated as the Pod's
+// ServiceAccount; see manifests/rbac.yaml for what it may do.
+func inClusterClient() (kubernetes.Interface, error) {
+	cfg, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(cfg)
+}
+
+func main() {
+	listenAddr := getEnv("LISTEN_ADDR", ":8080")
+	configFile := getEnv("CONFIG_FILE", "/etc/event-exporter/config.yaml")
+	// The API server filters by field before anything reaches the
+	// exporter, e.g. "type=Warning". The sinks' rules filter afterwards.
+	fieldSelector, err := fields.ParseSelector(getEnv("EVENT_FIELD_SELECTOR", ""))
+	if err != nil {
+		fmt.Printf("Error parsing EVENT_FIELD_SELECTOR: %s\n", err)
+		os.Exit(1)
+	}
+
+	cfg, err := LoadConfig(configFile)
+	if err != nil {
+		fmt.Printf("Error loading config: %s\n", err)
+		os.Exit(1)
+	}
+	exporter, err := NewExporter(cfg, getEnv("CLUSTER_NAME", ""),
+		getEnvDuration("MAX_EVENT_AGE", 5*time.Minute), getEnvDuration("DEDUP_WINDOW", 5*time.Minute))
+	if err != nil {
+		fmt.Printf("Error setting up sinks: %s\n", err)
+		os.Exit(1)
+	}
+
+	client, err := inClusterClient()
+	if err != nil {
+		fmt.Printf("Error creating Kubernetes client: %s\n", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	// No resync: replaying every cached Event as an update would export
+	// nothing (the count hasn't changed), but costs a pass over the cache.
+	// WATCH_NAMESPACE empty watches every namespace.
+	factory := informers.NewSharedInformerFactoryWithOptions(client, 0,
+		informers.WithNamespace(getEnv("WATCH_NAMESPACE", metav1.NamespaceAll)),
+		informers.WithTweakListOptions(func(o *metav1.ListOptions) { o.FieldSelector = fieldSelector.String() }))
+	informer := factory.Core().V1().Events().Informer()
+	if _, err := informer.AddEventHandler(exporter.Handler()); err != nil {
+		fmt.Printf("Error adding event handler: %s\n", err)
+		os.Exit(1)
+	}
+	exporter.Start()
+	factory.Start(ctx.Done())
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", promhttp.Handler())
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) { w.Write([]byte("ok")) })
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, _ *http.Request) {
+		if !informer.HasSynced() {
+			http.Error(w, "events not synced", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+	server := &http.Server{Addr: listenAddr, Handler: mux}
+	go func() {
+		if err := server.ListenAndServe(); err != http.ErrServerClosed {
+			fmt.Printf("Error starting server: %s\n", err)
+			os.Exit(1)
+		}
+	}()
+
+	fmt.Printf("Exporting Kubernetes Events to %d sinks; listening on %s\n", len(cfg.Sinks), listenAddr)
+	<-ctx.Done()
+
+	// The informer's handlers first, then the queues: whatever was
+	// accepted gets a few seconds to go out.
+	factory.Shutdown()
+	exporter.Stop(5 * time.Second)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server.Shutdown(shutdownCtx)
+	fmt.Println("Shutting down...")
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Metrics are registered with the default registry via 'promauto'.
+var (
+	eventsSeen = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "event_exporter_events_total",
+		Help: "Event occurrences seen, by result (exported, deduplicated, too_old, filtered).",
+	}, []string{"result"})
+
+	sent = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "event_exporter_sent_total",
+		Help: "Events delivered, by sink.",
+	}, []string{"sink"})
+
+	dropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "event_exporter_dropped_total",
+		Help: "Events a sink matched but never delivered, by sink and reason (rate_limited, queue_full, send_failed).",
+	}, []string{"sink", "reason"})
+
+	queueLength = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "event_exporter_queue_length",
+		Help: "Events waiting to be sent, by sink.",
+	}, []string{"sink"})
+
+	sendDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "event_exporter_send_duration_seconds",
+		Help:    "Time one delivery attempt takes, by sink.",
+		Buckets: prometheus.ExponentialBuckets(0.001, 4, 8),
+	}, []string{"sink"})
+)
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Record is what the sinks receive: an Event, flattened to the fields
+// people filter and alert on. It is the JSON the http and stdout sinks
+// write.
+type Record struct {
+	Cluster string    `json:"cluster,omitempty"`
+	Time    time.Time `json:"time"`
+	Type    string    `json:"type"`
+	Reason  string    `json:"reason"`
+	Message string    `json:"message"`
+	Object  ObjectRef `json:"object"`
+	// Component and Host reported the Event: kubelet on node-1, the
+	// scheduler, a controller...
+	Component string `json:"component,omitempty"`
+	Host      string `json:"host,omitempty"`
+	// Count is how often the Event has happened so far, as the API server
+	// tracks it.
+	Count int32 `json:"count"`
+	// Suppressed is how many occurrences the deduplication held back since
+	// this Event was last exported.
+	Suppressed int32 `json:"suppressed,omitempty"`
+}
+
+type ObjectRef struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	FieldPath string `json:"fieldPath,omitempty"`
+}
+
+func newRecord(cluster string, e *corev1.Event) *Record {
+	rec := &Record{
+		Cluster: cluster,
+		Time:    lastSeen(e),
+		Type:    e.Type,
+		Reason:  e.Reason,
+		Message: strings.TrimSpace(e.Message),
+		Object: ObjectRef{
+			Kind:      e.InvolvedObject.Kind,
+			Namespace: e.InvolvedObject.Namespace,
+			Name:      e.InvolvedObject.Name,
+			FieldPath: e.InvolvedObject.FieldPath,
+		},
+		Component: e.Source.Component,
+		Host:      e.Source.Host,
+		Count:     occurrences(e),
+	}
+	// Events written through the events.k8s.io API fill the reporting
+	// fields instead of source.
+	if rec.Component == "" {
+		rec.Component = e.ReportingController
+	}
+	if rec.Host == "" {
+		rec.Host = e.ReportingInstance
+	}
+	return rec
+}
+
+// dedupKey identifies "the same thing happening again": the same object,
+// reason and message. The API server folds exact repeats into one Event
+// with a rising count; this also catches an Event deleted and recreated.
+func (r *Record) dedupKey() string {
+	return strings.Join([]string{r.Type, r.Object.Kind, r.Object.Namespace, r.Object.Name, r.Reason, r.Message}, "\x00")
+}
+
+// occurrences is how many times an Event has happened so far. Old-style
+// events bump Count; events.k8s.io-style ones bump Series.Count instead.
+func occurrences(e *corev1.Event) int32 {
+	if e.Series != nil {
+		return e.Series.Count
+	}
+	return max(e.Count, 1)
+}
+
+// lastSeen is when the Event last happened. Which field says so depends on
+// which API wrote it.
+func lastSeen(e *corev1.Event) time.Time {
+	switch {
+	case e.Series != nil && !e.Series.LastObservedTime.IsZero():
+		return e.Series.LastObservedTime.Time
+	case !e.LastTimestamp.IsZero():
+		return e.LastTimestamp.Time
+	case !e.EventTime.IsZero():
+		return e.EventTime.Time
+	}
+	return e.CreationTimestamp.Time
+}
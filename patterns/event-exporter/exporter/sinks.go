@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Sink delivers one Record somewhere.
+type Sink interface {
+	Send(ctx context.Context, rec *Record) error
+}
+
+// NewSink returns the sink for cfg.Type: "stdout", "http" or "slack".
+func NewSink(cfg SinkConfig) (Sink, error) {
+	url := cfg.URL
+	if cfg.URLFromEnv != "" {
+		if url = os.Getenv(cfg.URLFromEnv); url == "" {
+			return nil, fmt.Errorf("%s is not set", cfg.URLFromEnv)
+		}
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	switch cfg.Type {
+	case "stdout":
+		return StdoutSink{}, nil
+	case "http":
+		if url == "" {
+			return nil, fmt.Errorf("an http sink needs url or urlFromEnv")
+		}
+		return &HTTPSink{URL: url, Client: client}, nil
+	case "slack":
+		if url == "" {
+			return nil, fmt.Errorf("a slack sink needs url or urlFromEnv")
+		}
+		return &SlackSink{WebhookURL: url, Client: client}, nil
+	}
+	return nil, fmt.Errorf("unknown sink type %q (want stdout, http or slack)", cfg.Type)
+}
+
+// StdoutSink prints one JSON object per line: a log collector on the node
+// (see patterns/daemonset-collector) picks it up like any container log.
+type StdoutSink struct{}
+
+func (StdoutSink) Send(_ context.Context, rec *Record) error {
+	buf, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(os.Stdout, "%s\n", buf)
+	return err
+}
+
+// HTTPSink POSTs each Record as JSON to any endpoint: a webhook, Vector or
+// Fluent Bit's http input, a function.
+type HTTPSink struct {
+	URL    string
+	Client *http.Client
+}
+
+func (s *HTTPSink) Send(ctx context.Context, rec *Record) error {
+	return postJSON(ctx, s.Client, s.URL, rec)
+}
+
+// SlackSink posts to a Slack incoming webhook. Slack allows about one
+// message per second per webhook; give it a rateLimit in the config.
+type SlackSink struct {
+	WebhookURL string
+	Client     *http.Client
+}
+
+func (s *SlackSink) Send(ctx context.Context, rec *Record) error {
+	icon := ":information_source:"
+	if rec.Type == "Warning" {
+		icon = ":warning:"
+	}
+	object := rec.Object.Kind + " " + rec.Object.Name
+	if rec.Object.Namespace != "" {
+		object = rec.Object.Kind + " " + rec.Object.Namespace + "/" + rec.Object.Name
+	}
+	text := fmt.Sprintf("%s *%s* on `%s`", icon, rec.Reason, object)
+	if rec.Cluster != "" {
+		text += " in " + rec.Cluster
+	}
+	if rec.Count > 1 {
+		text += fmt.Sprintf(" (%d times)", rec.Count)
+	}
+	text += "\n>" + rec.Message
+	return postJSON(ctx, s.Client, s.WebhookURL, map[string]string{"text": text})
+}
+
+// statusError is a delivery the endpoint refused. 429 and 5xx are worth
+// retrying; other 4xx will fail again the same way.
+type statusError struct {
+	host       string
+	status     string
+	code       int
+	retryAfter time.Duration
+}
+
+func (e *statusError) Error() string { return fmt.Sprintf("%s returned %s", e.host, e.status) }
+
+func (e *statusError) retryable() bool {
+	return e.code == http.StatusTooManyRequests || e.code >= 500
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, body any) error {
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// Errors name the host only: a Slack webhook's path is its secret, and
+	// client.Do's errors would print it.
+	resp, err := client.Do(req)
+	if err != nil {
+		var uerr *neturl.Error
+		if errors.As(err, &uerr) {
+			return fmt.Errorf("%s: %w", req.URL.Host, uerr.Err)
+		}
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		serr := &statusError{host: req.URL.Host, status: resp.Status, code: resp.StatusCode}
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			serr.retryAfter = time.Duration(secs) * time.Second
+		}
+		return serr
+	}
+	return nil
+}
+
+// retryDelay is how long to wait before attempt number attempt (1, 2,
+// ...), or false if err won't go away by retrying.
+func retryDelay(err error, attempt int) (time.Duration, bool) {
+	delay := time.Second << (attempt - 1)
+	var serr *statusError
+	if errors.As(err, &serr) {
+		if !serr.retryable() {
+			return 0, false
+		}
+		if serr.retryAfter > 0 {
+			delay = serr.retryAfter
+		}
+	}
+	return delay, true
+}
@@ -0,0 +1,97 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// FlagSyncSpec names a project of the flags API, and the ConfigMap its
+// flags are copied into. The API is the source of truth: the ConfigMap is
+// a read-only projection of it.
+type FlagSyncSpec struct {
+	// Project is the project's name in the flags API.
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// +required
+	Project string `json:"project"`
+
+	// ConfigMapName is the ConfigMap to write, in the FlagSync's namespace.
+	// Defaults to the FlagSync's name.
+	// +kubebuilder:validation:MaxLength=253
+	// +optional
+	ConfigMapName string `json:"configMapName,omitempty"`
+
+	// Interval is how often the API is polled. Webhooks from the API make
+	// changes show up sooner; polling catches those that were lost.
+	// +kubebuilder:default="30s"
+	// +optional
+	Interval metav1.Duration `json:"interval,omitempty"`
+}
+
+// ConditionReady is True when the ConfigMap holds the project's latest
+// version. While False, its reason says why: APIUnavailable (the last
+// fetch failed; the ConfigMap keeps the last version seen) or
+// ProjectNotFound.
+const ConditionReady = "Ready"
+
+// FlagSyncStatus reports what was last copied from the API.
+type FlagSyncStatus struct {
+	// Version is the project's version in the ConfigMap, as the API counts
+	// them.
+	// +optional
+	Version int64 `json:"version,omitempty"`
+
+	// FlagCount is how many flags the ConfigMap holds.
+	// +optional
+	FlagCount int32 `json:"flagCount,omitempty"`
+
+	// LastChangeTime is when the ConfigMap last changed.
+	// +optional
+	LastChangeTime *metav1.Time `json:"lastChangeTime,omitempty"`
+
+	// Conditions hold the Ready condition.
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName=fs
+// +kubebuilder:printcolumn:name="Project",type=string,JSONPath=`.spec.project`
+// +kubebuilder:printcolumn:name="Version",type=integer,JSONPath=`.status.version`
+// +kubebuilder:printcolumn:name="Flags",type=integer,JSONPath=`.status.flagCount`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Reason",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].reason`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// FlagSync is the Schema for the flagsyncs API
+type FlagSync struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// metadata is a standard object metadata
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitzero"`
+
+	// spec defines the desired state of FlagSync
+	// +required
+	Spec FlagSyncSpec `json:"spec"`
+
+	// status defines the observed state of FlagSync
+	// +optional
+	Status FlagSyncStatus `json:"status,omitzero"`
+}
+
+// +kubebuilder:object:root=true
+
+// FlagSyncList contains a list of FlagSync
+type FlagSyncList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitzero"`
+	Items           []FlagSync `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&FlagSync{}, &FlagSyncList{})
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errProjectNotFound is the API's 404: the project doesn't exist, which a
+// retry won't change.
+var errProjectNotFound = errors.New("project not found")
+
+// Snapshot is a project's flags at one version.
+type Snapshot struct {
+	Version int64             `json:"version"`
+	Flags   map[string]string `json:"flags"`
+}
+
+// FlagsAPI reads projects from the flags API. It remembers the last
+// snapshot of each project and its ETag, and sends If-None-Match: polling
+// an unchanged project costs the API a 304, and no body.
+type FlagsAPI struct {
+	BaseURL string
+	HTTP    *http.Client
+
+	mu    sync.Mutex
+	cache map[string]cachedSnapshot
+}
+
+type cachedSnapshot struct {
+	etag     string
+	snapshot Snapshot
+}
+
+// Flags returns the project's latest snapshot. The map it holds is shared
+// with the cache: callers must not modify it.
+func (a *FlagsAPI) Flags(ctx context.Context, project string) (Snapshot, error) {
+	start := time.Now()
+	snapshot, result, err := a.fetch(ctx, project)
+	fetchDuration.Observe(time.Since(start).Seconds())
+	fetchesTotal.WithLabelValues(result).Inc()
+	return snapshot, err
+}
+
+func (a *FlagsAPI) fetch(ctx context.Context, project string) (Snapshot, string, error) {
+	a.mu.Lock()
+	cached, ok := a.cache[project]
+	a.mu.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.BaseURL+"/projects/"+url.PathEscape(project)+"/flags", nil)
+	if err != nil {
+		return Snapshot{}, "error", err
+	}
+	if ok {
+		req.Header.Set("If-None-Match", cached.etag)
+	}
+	resp, err := a.HTTP.Do(req)
+	if err != nil {
+		return Snapshot{}, "error", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return cached.snapshot, "not_modified", nil
+	case http.StatusNotFound:
+		a.mu.Lock()
+		delete(a.cache, project)
+		a.mu.Unlock()
+		return Snapshot{}, "not_found", errProjectNotFound
+	case http.StatusOK:
+	default:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		if msg := strings.TrimSpace(string(body)); msg != "" {
+			return Snapshot{}, "error", fmt.Errorf("flags API answered %s: %s", resp.Status, msg)
+		}
+		return Snapshot{}, "error", fmt.Errorf("flags API answered %s", resp.Status)
+	}
+
+	var snapshot Snapshot
+	if err := json.NewDecoder(resp.Body).Decode(&snapshot); err != nil {
+		return Snapshot{}, "error", fmt.Errorf("decoding project %s: %w", project, err)
+	}
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		a.mu.Lock()
+		if a.cache == nil {
+			a.cache = map[string]cachedSnapshot{}
+		}
+		a.cache[project] = cachedSnapshot{etag: etag, snapshot: snapshot}
+		a.mu.Unlock()
+	}
+	return snapshot, "modified", nil
+}
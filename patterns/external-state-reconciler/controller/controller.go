@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	flagsv1alpha1 "flag-sync-controller/api/v1alpha1"
+)
+
+const (
+	// projectLabel and versionAnnotation on a ConfigMap say what it holds,
+	// for kubectl and for apps that want to log it.
+	projectLabel      = "flags.mydomain.com/project"
+	versionAnnotation = "flags.mydomain.com/version"
+
+	// projectIndex indexes FlagSyncs by spec.project, to map a webhook
+	// about a project to the FlagSyncs that copy it.
+	projectIndex = "spec.project"
+
+	defaultInterval = 30 * time.Second
+)
+
+// errConfigMapConflict is a ConfigMap of the wanted name that this
+// FlagSync doesn't control: it is never taken over.
+var errConfigMapConflict = errors.New("ConfigMap exists and is not controlled by this FlagSync")
+
+// FlagSyncReconciler copies a project of the flags API into a ConfigMap.
+// Nothing in the cluster changes when the API does, so there is nothing
+// to watch: each FlagSync is requeued every spec.interval, and sooner
+// when the API's webhook names its project (see WebhookReceiver).
+type FlagSyncReconciler struct {
+	client.Client
+	Recorder record.EventRecorder
+	API      *FlagsAPI
+	// Events carries the webhook's reconciles into the controller.
+	Events <-chan event.GenericEvent
+}
+
+func (r *FlagSyncReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+
+	var fs flagsv1alpha1.FlagSync
+	if err := r.Get(ctx, req.NamespacedName, &fs); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	before := fs.Status.DeepCopy()
+	interval := fs.Spec.Interval.Duration
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+
+	snapshot, err := r.API.Flags(ctx, fs.Spec.Project)
+	switch {
+	case errors.Is(err, errProjectNotFound):
+		// Not an error to retry: polled again at the interval, in case the
+		// project is created. The ConfigMap, if any, is left as it was.
+		return requeue(interval, r.setReady(ctx, &fs, before, metav1.ConditionFalse, "ProjectNotFound",
+			fmt.Sprintf("Project %s doesn't exist in the flags API", fs.Spec.Project)))
+	case err != nil:
+		// The ConfigMap keeps the last version seen: an outage of the API
+		// is no reason to take the flags away from the apps. The error
+		// requeues with the controller's backoff (see SetupWithManager).
+		if statusErr := r.setReady(ctx, &fs, before, metav1.ConditionFalse, "APIUnavailable", err.Error()); statusErr != nil {
+			return ctrl.Result{}, statusErr
+		}
+		return ctrl.Result{}, fmt.Errorf("fetching project %s: %w", fs.Spec.Project, err)
+	}
+
+	if bad := invalidKeys(snapshot.Flags); len(bad) > 0 {
+		return requeue(interval, r.setReady(ctx, &fs, before, metav1.ConditionFalse, "InvalidFlags",
+			fmt.Sprintf("Flag names that can't be ConfigMap keys: %s", strings.Join(bad, ", "))))
+	}
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: configMapName(&fs), Namespace: fs.Namespace}}
+	op, err := controllerutil.CreateOrUpdate(ctx, r.Client, cm, func() error {
+		if cm.ResourceVersion != "" && !metav1.IsControlledBy(cm, &fs) {
+			return errConfigMapConflict
+		}
+		metav1.SetMetaDataLabel(&cm.ObjectMeta, projectLabel, fs.Spec.Project)
+		metav1.SetMetaDataAnnotation(&cm.ObjectMeta, versionAnnotation, strconv.FormatInt(snapshot.Version, 10))
+		// The whole of Data: a flag deleted in the API leaves the ConfigMap,
+		// and a key added by hand is taken out again.
+		cm.Data = maps.Clone(snapshot.Flags)
+		return controllerutil.SetControllerReference(&fs, cm, r.Scheme())
+	})
+	if errors.Is(err, errConfigMapConflict) {
+		return requeue(interval, r.setReady(ctx, &fs, before, metav1.ConditionFalse, "ConfigMapConflict",
+			fmt.Sprintf("ConfigMap %s exists and is not controlled by this FlagSync", cm.Name)))
+	}
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if op != controllerutil.OperationResultNone {
+		now := metav1.Now()
+		fs.Status.LastChangeTime = &now
+		log.Info("ConfigMap "+string(op), "configMap", cm.Name, "project", fs.Spec.Project, "version", snapshot.Version)
+		r.Recorder.Eventf(&fs, corev1.EventTypeNormal, "Synced", "ConfigMap %s %s to version %d of project %s",
+			cm.Name, op, snapshot.Version, fs.Spec.Project)
+	}
+	fs.Status.Version = snapshot.Version
+	fs.Status.FlagCount = int32(len(snapshot.Flags))
+	return requeue(interval, r.setReady(ctx, &fs, before, metav1.ConditionTrue, "Synced",
+		fmt.Sprintf("ConfigMap %s holds version %d of project %s", cm.Name, snapshot.Version, fs.Spec.Project)))
+}
+
+// setReady sets the Ready condition, and writes the status if anything in
+// it changed: a poll that finds nothing new writes nothing.
+func (r *FlagSyncReconciler) setReady(ctx context.Context, fs *flagsv1alpha1.FlagSync, before *flagsv1alpha1.FlagSyncStatus,
+	status metav1.ConditionStatus, reason, message string) error {
+	changed := meta.SetStatusCondition(&fs.Status.Conditions, metav1.Condition{
+		Type: flagsv1alpha1.ConditionReady, Status: status, Reason: reason, Message: message,
+		ObservedGeneration: fs.Generation,
+	})
+	if changed && status == metav1.ConditionFalse {
+		r.Recorder.Event(fs, corev1.EventTypeWarning, reason, message)
+	}
+	if equality.Semantic.DeepEqual(before, &fs.Status) {
+		return nil
+	}
+	return r.Status().Update(ctx, fs)
+}
+
+// requeue polls again after the interval, unless err asks for a backoff:
+// controller-runtime ignores the Result when there is an error.
+func requeue(interval time.Duration, err error) (ctrl.Result, error) {
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: interval}, nil
+}
+
+func configMapName(fs *flagsv1alpha1.FlagSync) string {
+	if fs.Spec.ConfigMapName != "" {
+		return fs.Spec.ConfigMapName
+	}
+	return fs.Name
+}
+
+// invalidKeys returns the flag names a ConfigMap refuses as keys.
+func invalidKeys(flags map[string]string) []string {
+	var bad []string
+	for key := range flags {
+		if len(validation.IsConfigMapKey(key)) > 0 {
+			bad = append(bad, key)
+		}
+	}
+	slices.Sort(bad)
+	return bad
+}
+
+// SetupWithManager watches FlagSyncs, the ConfigMaps they own (an edit by
+// hand is undone at once) and the webhook's events. Failures back off
+// from 1s to 5m: the default starts at 5ms, which is for the API server,
+// not for someone else's API.
+func (r *FlagSyncReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(ctx, &flagsv1alpha1.FlagSync{}, projectIndex,
+		func(obj client.Object) []string {
+			return []string{obj.(*flagsv1alpha1.FlagSync).Spec.Project}
+		}); err != nil {
+		return err
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&flagsv1alpha1.FlagSync{}).
+		Owns(&corev1.ConfigMap{}).
+		WatchesRawSource(source.Channel(r.Events, &handler.EnqueueRequestForObject{})).
+		WithOptions(controller.Options{
+			RateLimiter: workqueue.NewTypedItemExponentialFailureRateLimiter[reconcile.Request](time.Second, 5*time.Minute),
+		}).
+		Named("flagsync").
+		Complete(r)
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Metrics go to controller-runtime's registry, which the Manager serves on
+// its /metrics next to the controller_runtime_* and workqueue_* metrics.
+var (
+	fetchesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "flagsync_api_fetches_total",
+		Help: "Reads of the flags API, by result (modified, not_modified, not_found, error).",
+	}, []string{"result"})
+
+	fetchDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "flagsync_api_fetch_duration_seconds",
+		Help:    "Time to read a project from the flags API.",
+		Buckets: prometheus.ExponentialBuckets(0.005, 2, 12),
+	})
+
+	webhooksTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "flagsync_webhooks_total",
+		Help: "Change notifications received from the flags API, by result (accepted, invalid).",
+	}, []string{"result"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(fetchesTotal, fetchDuration, webhooksTotal)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	flagsv1alpha1 "flag-sync-controller/api/v1alpha1"
+)
+
+// WebhookReceiver serves POST /webhook for the flags API's change
+// notifications. A notification is only a hint: it names a project, and
+// each FlagSync of the project is reconciled, which fetches the flags
+// from the API. Its payload is never trusted as data, so a forged or
+// replayed one costs a fetch, and a lost one costs an interval.
+type WebhookReceiver struct {
+	Addr   string
+	Reader client.Reader
+	Events chan<- event.GenericEvent
+}
+
+// Start is the manager.Runnable: the Manager runs it once this replica
+// is the leader, with the controller it feeds.
+func (w *WebhookReceiver) Start(ctx context.Context) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /webhook", w.serve)
+	server := &http.Server{Addr: w.Addr, Handler: mux}
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- server.ListenAndServe() }()
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (w *WebhookReceiver) serve(rw http.ResponseWriter, r *http.Request) {
+	log := logf.FromContext(r.Context()).WithName("webhook")
+
+	var payload struct {
+		Project string `json:"project"`
+		Version int64  `json:"version"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(rw, r.Body, 1<<16)).Decode(&payload); err != nil || payload.Project == "" {
+		webhooksTotal.WithLabelValues("invalid").Inc()
+		http.Error(rw, "want {\"project\": \"...\"}", http.StatusBadRequest)
+		return
+	}
+	webhooksTotal.WithLabelValues("accepted").Inc()
+
+	var list flagsv1alpha1.FlagSyncList
+	if err := w.Reader.List(r.Context(), &list, client.MatchingFields{projectIndex: payload.Project}); err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	queued := 0
+	for i := range list.Items {
+		// Never block the API's request on a full channel: the next poll
+		// picks the change up anyway.
+		select {
+		case w.Events <- event.GenericEvent{Object: &list.Items[i]}:
+			queued++
+		default:
+		}
+	}
+	log.Info("Change notified", "project", payload.Project, "version", payload.Version, "flagSyncs", queued)
+	rw.WriteHeader(http.StatusAccepted)
+	fmt.Fprintf(rw, "queued %d FlagSyncs\n", queued)
+}
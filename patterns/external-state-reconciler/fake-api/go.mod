@@ -0,0 +1,3 @@
+module fake-flags-api
+
+go 1.24.3
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+// project is a set of feature flags, and its version: a counter bumped on
+// every change, which is also its ETag.
+type project struct {
+	Version int               `json:"version"`
+	Flags   map[string]string `json:"flags"`
+}
+
+// store is the SaaS this API stands for: the source of truth lives here,
+// not in the cluster.
+type store struct {
+	mu          sync.Mutex
+	projects    map[string]*project
+	outageUntil time.Time
+}
+
+func newStore() *store {
+	return &store{projects: map[string]*project{
+		"checkout": {Version: 1, Flags: map[string]string{
+			"new-payment-flow": "false",
+			"express-shipping": "true",
+			"max-cart-items":   "50",
+		}},
+		"search": {Version: 1, Flags: map[string]string{
+			"semantic-ranking": "true",
+			"typo-tolerance":   "2",
+		}},
+	}}
+}
+
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// notifier tells a subscriber that a project changed. The payload only
+// names the project and its version: receivers fetch the flags themselves.
+type notifier struct {
+	url    string
+	client *http.Client
+}
+
+func (n *notifier) notify(name string, version int) {
+	if n.url == "" {
+		return
+	}
+	body, _ := json.Marshal(map[string]any{"project": name, "version": version})
+	go func() {
+		resp, err := n.client.Post(n.url, "application/json", bytes.NewReader(body))
+		if err != nil {
+			fmt.Printf("Webhook for %s v%d failed: %s\n", name, version, err)
+			return
+		}
+		resp.Body.Close()
+		fmt.Printf("Webhook for %s v%d: %s\n", name, version, resp.Status)
+	}()
+}
+
+func main() {
+	listenAddr := getEnv("LISTEN_ADDR", ":8080")
+	s := newStore()
+	n := &notifier{url: os.Getenv("WEBHOOK_URL"), client: &http.Client{Timeout: 5 * time.Second}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) { w.Write([]byte("ok")) })
+
+	// GET a project's flags. With If-None-Match set to the current ETag,
+	// the answer is 304 and no body: polling an unchanged project is cheap.
+	mux.HandleFunc("GET /projects/{project}/flags", func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if time.Now().Before(s.outageUntil) {
+			http.Error(w, "service unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		p, ok := s.projects[r.PathValue("project")]
+		if !ok {
+			http.Error(w, "project not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("ETag", etag(p.Version))
+		if r.Header.Get("If-None-Match") == etag(p.Version) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(p)
+	})
+
+	// PUT a flag's value as the raw body; the project is created if new.
+	mux.HandleFunc("PUT /projects/{project}/flags/{flag}", func(w http.ResponseWriter, r *http.Request) {
+		value, err := io.ReadAll(io.LimitReader(r.Body, 4096))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		name := r.PathValue("project")
+		s.mu.Lock()
+		p, ok := s.projects[name]
+		if !ok {
+			p = &project{Flags: map[string]string{}}
+			s.projects[name] = p
+		}
+		p.Flags[r.PathValue("flag")] = string(bytes.TrimSpace(value))
+		p.Version++
+		version := p.Version
+		s.mu.Unlock()
+		fmt.Printf("Set %s/%s=%s (v%d)\n", name, r.PathValue("flag"), bytes.TrimSpace(value), version)
+		n.notify(name, version)
+		w.Header().Set("ETag", etag(version))
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	mux.HandleFunc("DELETE /projects/{project}/flags/{flag}", func(w http.ResponseWriter, r *http.Request) {
+		name, flag := r.PathValue("project"), r.PathValue("flag")
+		s.mu.Lock()
+		p, ok := s.projects[name]
+		if ok {
+			_, ok = p.Flags[flag]
+		}
+		if !ok {
+			s.mu.Unlock()
+			http.Error(w, "flag not found", http.StatusNotFound)
+			return
+		}
+		delete(p.Flags, flag)
+		p.Version++
+		version := p.Version
+		s.mu.Unlock()
+		fmt.Printf("Deleted %s/%s (v%d)\n", name, flag, version)
+		n.notify(name, version)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	mux.HandleFunc("DELETE /projects/{project}", func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("project")
+		s.mu.Lock()
+		_, ok := s.projects[name]
+		delete(s.projects, name)
+		s.mu.Unlock()
+		if !ok {
+			http.Error(w, "project not found", http.StatusNotFound)
+			return
+		}
+		fmt.Printf("Deleted project %s\n", name)
+		n.notify(name, 0)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	// POST /outage?for=1m answers 503 to every read for that long, to see
+	// what the controller does when the source of truth is down.
+	mux.HandleFunc("POST /outage", func(w http.ResponseWriter, r *http.Request) {
+		d, err := time.ParseDuration(r.URL.Query().Get("for"))
+		if err != nil {
+			http.Error(w, "want ?for=<duration>, e.g. ?for=1m", http.StatusBadRequest)
+			return
+		}
+		s.mu.Lock()
+		s.outageUntil = time.Now().Add(d)
+		s.mu.Unlock()
+		fmt.Printf("Outage for %s\n", d)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	server := &http.Server{Addr: listenAddr, Handler: mux}
+	go func() {
+		if err := server.ListenAndServe(); err != http.ErrServerClosed {
+			fmt.Printf("Error starting server: %s\n", err)
+			os.Exit(1)
+		}
+	}()
+	fmt.Printf("Fake flags API listening on %s; webhook %q\n", listenAddr, n.url)
+
+	<-ctx.Done()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server.Shutdown(shutdownCtx)
+	fmt.Println("Shutting down...")
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	// managedByLabel is set on everything the reconciler applies. Prune
+	// only deletes objects that still carry it with its value.
+	managedByLabel = "app.kubernetes.io/managed-by"
+
+	// pruneAnnotation set to "disabled" on an object in Git keeps it in the
+	// cluster once it is removed from Git.
+	pruneAnnotation = "gitops.mydomain.com/prune"
+)
+
+// objectRef identifies an applied object in the inventory. It has no
+// version: a Deployment stays the same Deployment across apps/v1beta2 and
+// apps/v1.
+type objectRef struct {
+	Group, Kind, Namespace, Name string
+}
+
+// String is Flux's inventory format: namespace_name_group_kind. None of
+// the parts can contain an underscore.
+func (r objectRef) String() string {
+	return strings.Join([]string{r.Namespace, r.Name, r.Group, r.Kind}, "_")
+}
+
+func parseObjectRef(s string) (objectRef, error) {
+	parts := strings.Split(s, "_")
+	if len(parts) != 4 {
+		return objectRef{}, fmt.Errorf("invalid inventory entry %q", s)
+	}
+	return objectRef{Namespace: parts[0], Name: parts[1], Group: parts[2], Kind: parts[3]}, nil
+}
+
+// display is how kubectl would name it: deployment.apps/web -n demo.
+func (r objectRef) display() string {
+	s := strings.ToLower(r.Kind)
+	if r.Group != "" {
+		s += "." + r.Group
+	}
+	s += "/" + r.Name
+	if r.Namespace != "" {
+		s += " -n " + r.Namespace
+	}
+	return s
+}
+
+// rank orders objects so that what others need comes first: Namespaces
+// before what is in them, CRDs before their custom resources. Pruning
+// deletes in the reverse order.
+func (r objectRef) rank() int {
+	if (r.Group == "" && r.Kind == "Namespace") || (r.Group == "apiextensions.k8s.io" && r.Kind == "CustomResourceDefinition") {
+		return 0
+	}
+	return 1
+}
+
+// Applier server-side applies objects of any kind, and deletes them,
+// through the dynamic client.
+type Applier struct {
+	Client dynamic.Interface
+	// Mapper turns a Kind into a resource, from discovery. It is reset
+	// when a Kind is unknown: a CRD applied in this sync brings new ones.
+	Mapper           meta.ResettableRESTMapper
+	FieldManager     string
+	DefaultNamespace string
+}
+
+func (a *Applier) mapping(gk schema.GroupKind, version string) (*meta.RESTMapping, error) {
+	mapping, err := a.Mapper.RESTMapping(gk, version)
+	if meta.IsNoMatchError(err) {
+		a.Mapper.Reset()
+		mapping, err = a.Mapper.RESTMapping(gk, version)
+	}
+	return mapping, err
+}
+
+// resourceFor returns the client for obj's resource, in obj's namespace
+// (or the default one) if the resource is namespaced.
+func (a *Applier) resourceFor(mapping *meta.RESTMapping, namespace string) dynamic.ResourceInterface {
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		return a.Client.Resource(mapping.Resource)
+	}
+	return a.Client.Resource(mapping.Resource).Namespace(namespace)
+}
+
+// Apply server-side applies each object, Namespaces and CRDs first. Every
+// object is attempted; the error joins those that failed, and the
+// returned refs are those that were applied.
+func (a *Applier) Apply(ctx context.Context, objs []*unstructured.Unstructured) ([]objectRef, error) {
+	objs = slices.Clone(objs)
+	rank := func(obj *unstructured.Unstructured) int {
+		gvk := obj.GroupVersionKind()
+		return objectRef{Group: gvk.Group, Kind: gvk.Kind}.rank()
+	}
+	slices.SortStableFunc(objs, func(x, y *unstructured.Unstructured) int { return rank(x) - rank(y) })
+
+	var applied []objectRef
+	var errs []error
+	for _, obj := range objs {
+		gvk := obj.GroupVersionKind()
+		mapping, err := a.mapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s %s: %w", gvk.Kind, obj.GetName(), err))
+			continue
+		}
+		if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+			if obj.GetNamespace() == "" {
+				obj.SetNamespace(a.DefaultNamespace)
+			}
+		} else {
+			obj.SetNamespace("")
+		}
+		labels := obj.GetLabels()
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[managedByLabel] = a.FieldManager
+		obj.SetLabels(labels)
+
+		ref := objectRef{Group: gvk.Group, Kind: gvk.Kind, Namespace: obj.GetNamespace(), Name: obj.GetName()}
+		ri := a.resourceFor(mapping, obj.GetNamespace())
+
+		// Only to tell created, configured and unchanged apart: apply
+		// itself doesn't need the current object.
+		before := ""
+		if current, err := ri.Get(ctx, obj.GetName(), metav1.GetOptions{}); err == nil {
+			before = current.GetResourceVersion()
+		}
+		// Force: Git is the source of truth. A field someone changed with
+		// kubectl edit is taken back, and set to what Git says.
+		result, err := ri.Apply(ctx, obj.GetName(), obj, metav1.ApplyOptions{FieldManager: a.FieldManager, Force: true})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", ref.display(), err))
+			continue
+		}
+		action := "configured"
+		switch before {
+		case "":
+			action = "created"
+		case result.GetResourceVersion():
+			action = "unchanged"
+		}
+		objectsApplied.WithLabelValues(action).Inc()
+		if action != "unchanged" {
+			fmt.Printf("%s %s\n", ref.display(), action)
+		}
+		applied = append(applied, ref)
+	}
+	return applied, errors.Join(errs...)
+}
+
+// Prune deletes objects that were applied before and no longer are, the
+// others before Namespaces and CRDs. It returns those it failed to
+// delete. It skips objects whose managed-by label was changed (someone
+// else took them over) or that are annotated to be kept.
+func (a *Applier) Prune(ctx context.Context, refs []objectRef) ([]objectRef, error) {
+	refs = slices.Clone(refs)
+	slices.SortStableFunc(refs, func(x, y objectRef) int { return y.rank() - x.rank() })
+
+	var failed []objectRef
+	var errs []error
+	for _, ref := range refs {
+		mapping, err := a.mapping(schema.GroupKind{Group: ref.Group, Kind: ref.Kind}, "")
+		if meta.IsNoMatchError(err) {
+			continue // its CRD is gone, and so is it
+		}
+		if err != nil {
+			failed, errs = append(failed, ref), append(errs, fmt.Errorf("%s: %w", ref.display(), err))
+			continue
+		}
+		ri := a.resourceFor(mapping, ref.Namespace)
+		current, err := ri.Get(ctx, ref.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			failed, errs = append(failed, ref), append(errs, fmt.Errorf("%s: %w", ref.display(), err))
+			continue
+		}
+		if current.GetLabels()[managedByLabel] != a.FieldManager {
+			fmt.Printf("%s not pruned: no longer labeled %s=%s\n", ref.display(), managedByLabel, a.FieldManager)
+			continue
+		}
+		if current.GetAnnotations()[pruneAnnotation] == "disabled" {
+			fmt.Printf("%s not pruned: %s=disabled\n", ref.display(), pruneAnnotation)
+			continue
+		}
+		// Background: the object goes now, its dependents (a Deployment's
+		// ReplicaSets and Pods) after it, by the garbage collector.
+		policy := metav1.DeletePropagationBackground
+		if err := ri.Delete(ctx, ref.Name, metav1.DeleteOptions{PropagationPolicy: &policy}); err != nil && !apierrors.IsNotFound(err) {
+			failed, errs = append(failed, ref), append(errs, fmt.Errorf("%s: %w", ref.display(), err))
+			continue
+		}
+		objectsPruned.Inc()
+		fmt.Printf("%s pruned\n", ref.display())
+	}
+	return failed, errors.Join(errs...)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Repo is a shallow checkout of one branch of a Git repository. It runs
+// the git binary, as Argo CD does: git's own transports, credentials
+// helpers and SSH config all work, for a fork and an exec per sync.
+type Repo struct {
+	URL     string
+	Branch  string
+	Dir     string
+	Timeout time.Duration
+}
+
+// Sync brings the checkout to the branch's latest commit, and returns its
+// hash. The first sync clones; later ones fetch that one commit, and
+// reset to it: local changes, and history, are never kept.
+func (r *Repo) Sync(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.Timeout)
+	defer cancel()
+
+	if _, err := os.Stat(filepath.Join(r.Dir, ".git")); errors.Is(err, fs.ErrNotExist) {
+		if _, err := r.git(ctx, "", "clone", "--quiet", "--depth", "1", "--single-branch", "--branch", r.Branch, r.URL, r.Dir); err != nil {
+			return "", err
+		}
+	} else {
+		if _, err := r.git(ctx, r.Dir, "fetch", "--quiet", "--depth", "1", "origin", r.Branch); err != nil {
+			return "", err
+		}
+		if _, err := r.git(ctx, r.Dir, "reset", "--quiet", "--hard", "FETCH_HEAD"); err != nil {
+			return "", err
+		}
+		if _, err := r.git(ctx, r.Dir, "clean", "--quiet", "-fdx"); err != nil {
+			return "", err
+		}
+	}
+	return r.git(ctx, r.Dir, "rev-parse", "HEAD")
+}
+
+func (r *Repo) git(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	// Never wait for a password on a terminal that isn't there.
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
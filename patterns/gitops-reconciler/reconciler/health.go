@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// WaitHealthy polls the workloads among refs until they have all rolled
+// out, or the timeout is up. Other kinds are healthy once applied.
+func (a *Applier) WaitHealthy(ctx context.Context, refs []objectRef, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+	for {
+		reason, err := a.firstUnhealthy(ctx, refs)
+		if err == nil && reason == "" {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			if err != nil {
+				return fmt.Errorf("health check timed out after %s: %w", timeout, err)
+			}
+			return fmt.Errorf("health check timed out after %s: %s", timeout, reason)
+		case <-ticker.C:
+		}
+	}
+}
+
+// firstUnhealthy says why the first workload that isn't ready isn't, or
+// returns "" if they all are.
+func (a *Applier) firstUnhealthy(ctx context.Context, refs []objectRef) (string, error) {
+	for _, ref := range refs {
+		if ref.Group != "apps" || (ref.Kind != "Deployment" && ref.Kind != "StatefulSet" && ref.Kind != "DaemonSet") {
+			continue
+		}
+		mapping, err := a.mapping(schema.GroupKind{Group: ref.Group, Kind: ref.Kind}, "")
+		if err != nil {
+			return "", err
+		}
+		obj, err := a.resourceFor(mapping, ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+		if reason := unhealthyReason(obj); reason != "" {
+			return ref.display() + ": " + reason, nil
+		}
+	}
+	return "", nil
+}
+
+// unhealthyReason is kubectl rollout status, for the three workload
+// kinds: the controller has seen the latest spec, and every replica runs
+// it and is available.
+func unhealthyReason(obj *unstructured.Unstructured) string {
+	observed, _, _ := unstructured.NestedInt64(obj.Object, "status", "observedGeneration")
+	if observed < obj.GetGeneration() {
+		return "waiting for the controller to observe the new spec"
+	}
+	status := func(field string) int64 {
+		n, _, _ := unstructured.NestedInt64(obj.Object, "status", field)
+		return n
+	}
+
+	switch obj.GetKind() {
+	case "Deployment", "StatefulSet":
+		replicas, found, _ := unstructured.NestedInt64(obj.Object, "spec", "replicas")
+		if !found {
+			replicas = 1
+		}
+		ready := "availableReplicas"
+		if obj.GetKind() == "StatefulSet" {
+			ready = "readyReplicas"
+		}
+		if updated := status("updatedReplicas"); updated < replicas {
+			return fmt.Sprintf("%d of %d replicas updated", updated, replicas)
+		}
+		if n := status(ready); n < replicas {
+			return fmt.Sprintf("%d of %d replicas available", n, replicas)
+		}
+	case "DaemonSet":
+		desired := status("desiredNumberScheduled")
+		if updated := status("updatedNumberScheduled"); updated < desired {
+			return fmt.Sprintf("%d of %d Pods updated", updated, desired)
+		}
+		if available := status("numberAvailable"); available < desired {
+			return fmt.Sprintf("%d of %d Pods available", available, desired)
+		}
+	}
+	return ""
+}
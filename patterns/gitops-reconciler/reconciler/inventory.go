@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1ac "k8s.io/client-go/applyconfigurations/core/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// InventoryState is what the reconciler remembers between syncs: the
+// objects it applied, which is what it may prune, and how the last sync
+// went.
+type InventoryState struct {
+	Entries               []objectRef
+	LastAppliedRevision   string
+	LastAttemptedRevision string
+	Ready                 string
+	Message               string
+}
+
+// Inventory keeps the state in a ConfigMap, so it survives restarts: a
+// new Pod knows what the previous one applied, and can prune it.
+type Inventory struct {
+	Client       kubernetes.Interface
+	Namespace    string
+	Name         string
+	FieldManager string
+}
+
+func (i *Inventory) Load(ctx context.Context) (InventoryState, error) {
+	cm, err := i.Client.CoreV1().ConfigMaps(i.Namespace).Get(ctx, i.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return InventoryState{}, nil
+	}
+	if err != nil {
+		return InventoryState{}, err
+	}
+	state := InventoryState{
+		LastAppliedRevision:   cm.Data["lastAppliedRevision"],
+		LastAttemptedRevision: cm.Data["lastAttemptedRevision"],
+		Ready:                 cm.Data["ready"],
+		Message:               cm.Data["message"],
+	}
+	for _, line := range strings.Split(cm.Data["entries"], "\n") {
+		if line == "" {
+			continue
+		}
+		ref, err := parseObjectRef(line)
+		if err != nil {
+			return InventoryState{}, err
+		}
+		state.Entries = append(state.Entries, ref)
+	}
+	return state, nil
+}
+
+// Save server-side applies the whole ConfigMap. An unchanged state is a
+// no-op: the resourceVersion doesn't move.
+func (i *Inventory) Save(ctx context.Context, state InventoryState) error {
+	entries := make([]string, 0, len(state.Entries))
+	for _, ref := range state.Entries {
+		entries = append(entries, ref.String())
+	}
+	slices.Sort(entries)
+	entries = slices.Compact(entries)
+
+	cm := corev1ac.ConfigMap(i.Name, i.Namespace).
+		WithLabels(map[string]string{managedByLabel: i.FieldManager}).
+		WithData(map[string]string{
+			"entries":               strings.Join(entries, "\n"),
+			"lastAppliedRevision":   state.LastAppliedRevision,
+			"lastAttemptedRevision": state.LastAttemptedRevision,
+			"ready":                 state.Ready,
+			"message":               state.Message,
+		})
+	_, err := i.Client.CoreV1().ConfigMaps(i.Namespace).Apply(ctx, cm, metav1.ApplyOptions{FieldManager: i.FieldManager, Force: true})
+	return err
+}
+
+// without returns the refs of a that aren't in b.
+func without(a, b []objectRef) []objectRef {
+	var out []objectRef
+	for _, ref := range a {
+		if !slices.Contains(b, ref) {
+			out = append(out, ref)
+		}
+	}
+	return out
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+)
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	if value, ok := os.LookupEnv(key); ok {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+		fmt.Printf("Invalid %s=%q, using default %t\n", key, value, fallback)
+	}
+	return fallback
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if value, ok := os.LookupEnv(key); ok {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+		fmt.Printf("Invalid %s=%q, using default %s\n", key, value, fallback)
+	}
+	return fallback
+}
+
+func main() {
+	repoURL := os.Getenv("REPO_URL")
+	if repoURL == "" {
+		fmt.Println("REPO_URL is not set")
+		os.Exit(1)
+	}
+	listenAddr := getEnv("LISTEN_ADDR", ":8080")
+	interval := getEnvDuration("SYNC_INTERVAL", time.Minute)
+	fieldManager := getEnv("FIELD_MANAGER", "gitops-reconciler")
+
+	// Authenticates as the Pod's ServiceAccount: whatever is in the repo,
+	// it must be allowed to apply and delete. See manifests/rbac.yaml.
+	cfg, err := rest.InClusterConfig()
+	if err != nil {
+		fmt.Printf("Error loading in-cluster config: %s\n", err)
+		os.Exit(1)
+	}
+	client, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		fmt.Printf("Error creating Kubernetes client: %s\n", err)
+		os.Exit(1)
+	}
+	dyn, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		fmt.Printf("Error creating dynamic client: %s\n", err)
+		os.Exit(1)
+	}
+	disc, err := discovery.NewDiscoveryClientForConfig(cfg)
+	if err != nil {
+		fmt.Printf("Error creating discovery client: %s\n", err)
+		os.Exit(1)
+	}
+
+	reconciler := &Reconciler{
+		Repo: &Repo{
+			URL:     repoURL,
+			Branch:  getEnv("BRANCH", "main"),
+			Dir:     getEnv("WORK_DIR", "/work/repo"),
+			Timeout: getEnvDuration("GIT_TIMEOUT", time.Minute),
+		},
+		Path: getEnv("REPO_PATH", "."),
+		Applier: &Applier{
+			Client:           dyn,
+			Mapper:           restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(disc)),
+			FieldManager:     fieldManager,
+			DefaultNamespace: getEnv("TARGET_NAMESPACE", "default"),
+		},
+		Inventory: &Inventory{
+			Client:       client,
+			Namespace:    getEnv("POD_NAMESPACE", "default"),
+			Name:         getEnv("INVENTORY_NAME", "gitops-inventory"),
+			FieldManager: fieldManager,
+		},
+		Prune:         getEnvBool("PRUNE", true),
+		HealthTimeout: getEnvDuration("HEALTH_TIMEOUT", 2*time.Minute),
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	// A push webhook only shortens the wait: the next sync starts now
+	// instead of at the end of the interval. One pending trigger is enough.
+	trigger := make(chan struct{}, 1)
+	secret := os.Getenv("WEBHOOK_SECRET")
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", promhttp.Handler())
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) { w.Write([]byte("ok")) })
+	mux.HandleFunc("POST /webhook", func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if secret != "" && !validSignature(secret, body, r.Header.Get("X-Hub-Signature-256")) {
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+		select {
+		case trigger <- struct{}{}:
+		default:
+		}
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("sync triggered\n"))
+	})
+	server := &http.Server{Addr: listenAddr, Handler: mux}
+	go func() {
+		if err := server.ListenAndServe(); err != http.ErrServerClosed {
+			fmt.Printf("Error starting server: %s\n", err)
+			os.Exit(1)
+		}
+	}()
+
+	fmt.Printf("Syncing %s (branch %s, path %s) every %s; prune %t; listening on %s\n",
+		repoURL, reconciler.Repo.Branch, reconciler.Path, interval, reconciler.Prune, listenAddr)
+	for ctx.Err() == nil {
+		if err := reconciler.Reconcile(ctx); err != nil {
+			fmt.Printf("Sync failed: %s\n", err)
+		}
+		// The interval counts from the end of a sync, so syncs never overlap.
+		select {
+		case <-ctx.Done():
+		case <-time.After(interval):
+		case <-trigger:
+			fmt.Println("Webhook received, syncing now")
+		}
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server.Shutdown(shutdownCtx)
+	fmt.Println("Shutting down...")
+}
+
+// validSignature checks a GitHub-style signature: "sha256=" and the hex
+// HMAC-SHA256 of the body, keyed with the webhook's secret. Gitea and
+// Forgejo send the same header.
+func validSignature(secret string, body []byte, header string) bool {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(header))
+}
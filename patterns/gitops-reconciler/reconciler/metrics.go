@@ -0,0 +1,40 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Metrics are registered with the default registry via 'promauto'.
+var (
+	reconcileTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gitops_reconcile_total",
+		Help: "Syncs run, by result (success, failure).",
+	}, []string{"result"})
+
+	reconcileDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "gitops_reconcile_duration_seconds",
+		Help:    "Time to fetch, apply, prune and check health.",
+		Buckets: prometheus.ExponentialBuckets(0.1, 2, 12),
+	})
+
+	lastSuccess = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "gitops_last_success_timestamp_seconds",
+		Help: "When the last successful sync finished. Alert when it gets old.",
+	})
+
+	managedObjects = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "gitops_managed_objects",
+		Help: "Objects in the inventory.",
+	})
+
+	objectsApplied = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gitops_objects_applied_total",
+		Help: "Objects applied, by action (created, configured, unchanged). Configured with no new revision is drift undone.",
+	}, []string{"action"})
+
+	objectsPruned = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "gitops_objects_pruned_total",
+		Help: "Objects deleted because they were removed from Git.",
+	})
+)
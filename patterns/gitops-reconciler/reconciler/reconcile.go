@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+// Reconciler makes the cluster match a directory of a Git repository:
+// fetch, render, apply, prune, check health. Each sync does it all, even
+// when the revision hasn't changed: applying again undoes drift.
+type Reconciler struct {
+	Repo          *Repo
+	Path          string
+	Applier       *Applier
+	Inventory     *Inventory
+	Prune         bool
+	HealthTimeout time.Duration
+}
+
+// Reconcile runs one sync, and records its outcome in the inventory,
+// whatever it was.
+func (r *Reconciler) Reconcile(ctx context.Context) error {
+	start := time.Now()
+	state, err := r.Inventory.Load(ctx)
+	if err != nil {
+		reconcileTotal.WithLabelValues("failure").Inc()
+		return fmt.Errorf("loading inventory: %w", err)
+	}
+
+	err = r.sync(ctx, &state)
+	state.Ready, state.Message = "True", "Applied revision "+shortRevision(state.LastAppliedRevision)
+	if err != nil {
+		state.Ready, state.Message = "False", err.Error()
+	}
+	if saveErr := r.Inventory.Save(ctx, state); saveErr != nil {
+		err = errors.Join(err, fmt.Errorf("saving inventory: %w", saveErr))
+	}
+
+	reconcileDuration.Observe(time.Since(start).Seconds())
+	managedObjects.Set(float64(len(state.Entries)))
+	if err != nil {
+		reconcileTotal.WithLabelValues("failure").Inc()
+		return err
+	}
+	reconcileTotal.WithLabelValues("success").Inc()
+	lastSuccess.SetToCurrentTime()
+	return nil
+}
+
+func (r *Reconciler) sync(ctx context.Context, state *InventoryState) error {
+	revision, err := r.Repo.Sync(ctx)
+	if err != nil {
+		return fmt.Errorf("fetching %s: %w", r.Repo.URL, err)
+	}
+	if revision != state.LastAttemptedRevision {
+		fmt.Printf("New revision %s on %s\n", shortRevision(revision), r.Repo.Branch)
+	}
+	state.LastAttemptedRevision = revision
+
+	objs, err := render(filepath.Join(r.Repo.Dir, r.Path))
+	if err != nil {
+		return fmt.Errorf("rendering %s: %w", r.Path, err)
+	}
+
+	applied, err := r.Applier.Apply(ctx, objs)
+	if err != nil {
+		// Nothing is pruned after a failed apply: what failed might still
+		// be in the cluster, and in Git. What did apply is tracked from now on.
+		state.Entries = append(state.Entries, without(applied, state.Entries)...)
+		return fmt.Errorf("applying revision %s: %w", shortRevision(revision), err)
+	}
+
+	stale := without(state.Entries, applied)
+	state.Entries = applied
+	if r.Prune && len(stale) > 0 {
+		failed, err := r.Applier.Prune(ctx, stale)
+		if err != nil {
+			state.Entries = append(state.Entries, failed...)
+			return fmt.Errorf("pruning: %w", err)
+		}
+	}
+	state.LastAppliedRevision = revision
+
+	return r.Applier.WaitHealthy(ctx, applied, r.HealthTimeout)
+}
+
+func shortRevision(revision string) string {
+	if len(revision) > 7 {
+		return revision[:7]
+	}
+	return revision
+}
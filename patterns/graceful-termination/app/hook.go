@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// runHook is the container's lifecycle hooks: the manifest runs
+// "graceful-termination hook postStart" and "... hook preStop 10s" in the
+// container. A hook's output goes nowhere you can read (only a failure
+// shows, in an Event), so the hook tells the app, and the app logs it.
+//
+// A hook that fails kills the container, so this one never fails: the
+// demo is about timing, not about the hook's own errors.
+func runHook(args []string) {
+	if len(args) == 0 {
+		fmt.Println("usage: graceful-termination hook postStart|preStop [sleep]")
+		return
+	}
+	phase, sleep := args[0], ""
+	if len(args) > 1 {
+		sleep = args[1]
+	}
+	addr := "http://127.0.0.1" + getEnv("LISTEN_ADDR", ":8080")
+	client := &http.Client{Timeout: time.Second}
+	target := addr + "/lifecycle/" + phase + "?sleep=" + url.QueryEscape(sleep)
+
+	switch phase {
+	case "postStart":
+		// postStart runs alongside the entrypoint, not after it: the
+		// server may not be listening yet. Retry for a while.
+		for range 20 {
+			if notify(client, target) == nil {
+				return
+			}
+			time.Sleep(250 * time.Millisecond)
+		}
+	case "preStop":
+		if err := notify(client, target); err != nil {
+			fmt.Printf("Error notifying the app: %s\n", err)
+		}
+		// The point of the hook: keep serving while the Pod's removal
+		// from the Service's endpoints reaches every node and ingress.
+		// SIGTERM only comes after this returns.
+		if d, err := time.ParseDuration(sleep); err == nil {
+			time.Sleep(d)
+		}
+	default:
+		fmt.Printf("Unknown hook %q\n", phase)
+	}
+}
+
+func notify(client *http.Client, target string) error {
+	resp, err := client.Post(target, "text/plain", strings.NewReader(""))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s", resp.Status)
+	}
+	return nil
+}
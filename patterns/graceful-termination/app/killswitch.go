@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Killswitch breaks the app on purpose, one Pod at a time, to show what
+// the kubelet and the Service do about it:
+//
+//	liveness   /healthz fails: the kubelet restarts the container, with preStop and the grace period
+//	readiness  /readyz fails: the Pod leaves the Service's endpoints, nothing restarts
+//	crash      exit 1 at once: in-flight requests fail, no preStop, the container restarts
+//	hang       SIGTERM is ignored: the kubelet waits out the grace period, then sends SIGKILL
+//	reset      all of the above off
+type Killswitch struct {
+	lc *Lifecycle
+
+	mu     sync.Mutex
+	active map[string]bool
+}
+
+var killswitchModes = map[string]bool{"liveness": true, "readiness": true, "crash": true, "hang": true}
+
+func NewKillswitch(lc *Lifecycle) *Killswitch {
+	return &Killswitch{lc: lc, active: map[string]bool{}}
+}
+
+func (k *Killswitch) On(mode string) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.active[mode]
+}
+
+// Get lists the switches that are on.
+func (k *Killswitch) Get(w http.ResponseWriter, _ *http.Request) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	on := []string{}
+	for mode := range k.active {
+		on = append(on, mode)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"on": on})
+}
+
+// Set flips a switch: POST /killswitch/{mode}.
+func (k *Killswitch) Set(w http.ResponseWriter, r *http.Request) {
+	mode := r.PathValue("mode")
+	if mode == "reset" {
+		k.mu.Lock()
+		k.active = map[string]bool{}
+		k.mu.Unlock()
+		k.lc.Phase("KILLSWITCH", "reset")
+		writeJSON(w, http.StatusOK, map[string]any{"on": []string{}})
+		return
+	}
+	if !killswitchModes[mode] {
+		http.Error(w, "unknown mode "+mode+": want liveness, readiness, crash, hang or reset", http.StatusBadRequest)
+		return
+	}
+	k.mu.Lock()
+	k.active[mode] = true
+	k.mu.Unlock()
+	k.lc.Phase("KILLSWITCH", "%s on", mode)
+	writeJSON(w, http.StatusOK, map[string]any{"on": mode})
+
+	if mode == "crash" {
+		// After the response is out, so the caller sees it worked.
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			k.lc.Phase("CRASH", "exit 1; in flight: %d, all lost", k.lc.inFlight.Load())
+			os.Exit(1)
+		}()
+	}
+}
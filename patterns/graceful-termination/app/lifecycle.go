@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Lifecycle is what the app knows about where its Pod is in its life: when
+// the container started, when the preStop hook ran, when SIGTERM came, and
+// how many requests are in flight. Every change is logged as a phase, with
+// the time since the container started, so `kubectl logs` reads as a
+// timeline of a rollout.
+type Lifecycle struct {
+	start time.Time
+	// grace is terminationGracePeriodSeconds, as told through the
+	// environment: the Pod spec field isn't in the downward API.
+	grace time.Duration
+
+	inFlight atomic.Int64
+	ready    atomic.Bool
+	draining atomic.Bool
+
+	mu      sync.Mutex
+	preStop time.Time
+	sigterm time.Time
+}
+
+func NewLifecycle(grace time.Duration) *Lifecycle {
+	return &Lifecycle{start: time.Now(), grace: grace}
+}
+
+// Phase logs one step of the lifecycle:
+//
+//	12:04:31.207 SIGTERM    +95.214s  in flight: 3; 20.0s since preStop, 25.0s of the 45s grace period left
+func (l *Lifecycle) Phase(name, format string, args ...any) {
+	now := time.Now()
+	fmt.Printf("%s %-10s +%.3fs  %s\n", now.UTC().Format("15:04:05.000"), name,
+		now.Sub(l.start).Seconds(), fmt.Sprintf(format, args...))
+}
+
+// PreStop records that the preStop hook started. The kubelet runs it
+// before it sends SIGTERM, and the grace period is already running.
+func (l *Lifecycle) PreStop(sleep string) {
+	l.mu.Lock()
+	l.preStop = time.Now()
+	l.mu.Unlock()
+	l.Phase("PRESTOP", "hook started, sleeping %s before SIGTERM; in flight: %d; still serving", sleep, l.inFlight.Load())
+}
+
+// SIGTERM records the signal, and says how much of the grace period the
+// preStop hook used up.
+func (l *Lifecycle) SIGTERM() {
+	l.mu.Lock()
+	l.sigterm = time.Now()
+	preStop := l.preStop
+	l.mu.Unlock()
+
+	used := "no preStop hook"
+	left := l.grace
+	if !preStop.IsZero() {
+		elapsed := l.sigterm.Sub(preStop)
+		used = fmt.Sprintf("%.1fs since preStop", elapsed.Seconds())
+		left -= elapsed
+	}
+	l.Phase("SIGTERM", "in flight: %d; %s, %.1fs of the %s grace period left", l.inFlight.Load(), used, left.Seconds(), l.grace)
+}
+
+// Deadline is when the kubelet sends SIGKILL: the grace period counts from
+// the start of preStop, or from SIGTERM without one. It is zero until
+// either has happened.
+func (l *Lifecycle) Deadline() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	switch {
+	case !l.preStop.IsZero():
+		return l.preStop.Add(l.grace)
+	case !l.sigterm.IsZero():
+		return l.sigterm.Add(l.grace)
+	}
+	return time.Time{}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if value, ok := os.LookupEnv(key); ok {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+		fmt.Printf("Invalid %s=%q, using default %s\n", key, value, fallback)
+	}
+	return fallback
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// statusRecorder remembers the status code for the metrics.
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.code = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// track counts a request as in flight while it runs. Requests that finish
+// after preStop started are logged: with a preStop sleep they keep coming
+// for a few seconds, until every node has the new endpoints.
+func track(lc *Lifecycle, path string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lc.inFlight.Add(1)
+		defer lc.inFlight.Add(-1)
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+		next(rec, r)
+		requestsTotal.WithLabelValues(path, strconv.Itoa(rec.code)).Inc()
+		if !lc.Deadline().IsZero() {
+			lc.Phase("SERVED", "%s %d in %s, from %s", r.URL.RequestURI(), rec.code, time.Since(start).Round(time.Millisecond), r.RemoteAddr)
+		}
+	}
+}
+
+func routes(lc *Lifecycle, ks *Killswitch) *http.ServeMux {
+	pod := getEnv("POD_NAME", "graceful-termination")
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", track(lc, "/", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"pod": pod})
+	}))
+	// A slow request, to have something in flight when SIGTERM comes:
+	// /work?duration=5s.
+	mux.HandleFunc("GET /work", track(lc, "/work", func(w http.ResponseWriter, r *http.Request) {
+		d, err := time.ParseDuration(r.URL.Query().Get("duration"))
+		if err != nil || d < 0 || d > 5*time.Minute {
+			d = time.Second
+		}
+		time.Sleep(d)
+		writeJSON(w, http.StatusOK, map[string]any{"pod": pod, "worked": d.String()})
+	}))
+
+	// Liveness: is the process healthy? Failing it gets the container
+	// restarted, so it doesn't change while draining.
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) {
+		if ks.On("liveness") {
+			http.Error(w, "killswitch: liveness", http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+	// Readiness: should the Pod get traffic? Not before the startup delay
+	// is over, and not once it's draining.
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, _ *http.Request) {
+		switch {
+		case !lc.ready.Load():
+			http.Error(w, "starting", http.StatusServiceUnavailable)
+		case lc.draining.Load():
+			http.Error(w, "draining", http.StatusServiceUnavailable)
+		case ks.On("readiness"):
+			http.Error(w, "killswitch: readiness", http.StatusServiceUnavailable)
+		default:
+			w.Write([]byte("ok"))
+		}
+	})
+
+	// The lifecycle hooks report here; see hook.go.
+	mux.HandleFunc("POST /lifecycle/{hook}", func(w http.ResponseWriter, r *http.Request) {
+		switch r.PathValue("hook") {
+		case "postStart":
+			lc.Phase("POSTSTART", "hook ran")
+		case "preStop":
+			lc.PreStop(r.URL.Query().Get("sleep"))
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("GET /killswitch", ks.Get)
+	mux.HandleFunc("POST /killswitch/{mode}", ks.Set)
+	mux.Handle("GET /metrics", promhttp.Handler())
+	return mux
+}
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "hook" {
+		runHook(os.Args[2:])
+		return
+	}
+
+	listenAddr := getEnv("LISTEN_ADDR", ":8080")
+	startupDelay := getEnvDuration("STARTUP_DELAY", 3*time.Second)
+	drainTimeout := getEnvDuration("DRAIN_TIMEOUT", 20*time.Second)
+	lc := NewLifecycle(getEnvDuration("TERMINATION_GRACE_PERIOD", 30*time.Second))
+	ks := NewKillswitch(lc)
+	registerInFlight(lc)
+	lc.Phase("STARTING", "pid %d; startup delay %s, drain timeout %s, grace period %s",
+		os.Getpid(), startupDelay, drainTimeout, lc.grace)
+
+	// Registered before anything else can go wrong: a SIGTERM that comes
+	// before this would kill the process on the spot.
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM, syscall.SIGINT)
+
+	ln, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		fmt.Printf("Error starting server: %s\n", err)
+		os.Exit(1)
+	}
+	server := &http.Server{Handler: routes(lc, ks)}
+	go func() {
+		if err := server.Serve(ln); err != http.ErrServerClosed {
+			fmt.Printf("Error serving: %s\n", err)
+			os.Exit(1)
+		}
+	}()
+	lc.Phase("LISTENING", "on %s; /readyz says 503 until the startup delay is over", listenAddr)
+
+	go func() {
+		time.Sleep(startupDelay)
+		lc.ready.Store(true)
+		lc.Phase("READY", "/readyz says 200; the Pod joins the Service's endpoints")
+	}()
+
+	for sig := range sigs {
+		if sig == syscall.SIGTERM && ks.On("hang") {
+			lc.Phase("SIGTERM", "ignored (killswitch hang); SIGKILL comes at the end of the grace period")
+			continue
+		}
+		break
+	}
+	lc.SIGTERM()
+	os.Exit(drain(lc, server, drainTimeout))
+}
+
+// drain stops accepting connections and waits for the requests in flight,
+// for up to timeout. It returns the exit code.
+func drain(lc *Lifecycle, server *http.Server, timeout time.Duration) int {
+	lc.draining.Store(true)
+	deadline := lc.Deadline()
+	if until := time.Until(deadline); timeout > until {
+		lc.Phase("DRAINING", "drain timeout %s is longer than the %.1fs left before SIGKILL", timeout, until.Seconds())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- server.Shutdown(ctx) }()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	lc.Phase("DRAINING", "listener closed, new connections refused; in flight: %d", lc.inFlight.Load())
+	for {
+		select {
+		case <-ticker.C:
+			lc.Phase("DRAINING", "in flight: %d", lc.inFlight.Load())
+		case err := <-done:
+			code := 0
+			if err != nil {
+				code = 1
+				lc.Phase("ABANDONED", "drain timeout %s; requests cut off: %d", timeout, lc.inFlight.Load())
+				server.Close()
+			} else {
+				lc.Phase("DRAINED", "all requests finished")
+			}
+			lc.Phase("EXIT", "code %d, %.1fs before SIGKILL would have come", code, time.Until(deadline).Seconds())
+			fmt.Println("Shutting down...")
+			return code
+		}
+	}
+}
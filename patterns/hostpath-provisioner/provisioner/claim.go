@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+const (
+	// provisionerName is what a StorageClass names in "provisioner:" to
+	// have its claims served by this controller.
+	provisionerName = "mydomain.com/hostpath"
+
+	// kube-controller-manager's PV controller sets this on a claim it
+	// leaves to an external provisioner: the StorageClass's provisioner.
+	storageProvisionerAnnotation = "volume.kubernetes.io/storage-provisioner"
+
+	// With volumeBindingMode: WaitForFirstConsumer, the scheduler picks a
+	// node for the claim's first Pod, and writes it here. Until then,
+	// nothing is provisioned.
+	selectedNodeAnnotation = "volume.kubernetes.io/selected-node"
+
+	// provisionedByAnnotation marks a PV as this provisioner's: the PV
+	// controller then leaves its deletion to it.
+	provisionedByAnnotation = "pv.kubernetes.io/provisioned-by"
+
+	// nodeLabel on a PV is the node its directory is on: each node's
+	// provisioner only caches its own PVs.
+	nodeLabel = "mydomain.com/hostpath-node"
+
+	// cleanupFinalizer keeps a PV until its directory is gone, however
+	// the PV is deleted.
+	cleanupFinalizer = "mydomain.com/hostpath-cleanup"
+)
+
+// ClaimReconciler provisions a volume for each claim of a hostpath
+// StorageClass that the scheduler sent to this node: a directory under
+// BasePath, and a PV pinned to the node that points at it. The PV
+// controller then binds the two.
+type ClaimReconciler struct {
+	client.Client
+	Recorder record.EventRecorder
+	// Node is the node this provisioner runs on; Hostname, its
+	// kubernetes.io/hostname label, for the PV's node affinity.
+	Node     string
+	Hostname string
+	// BasePath is where the volumes' directories go, on the node, and
+	// at the same path in the provisioner's container.
+	BasePath string
+}
+
+func (r *ClaimReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+
+	var pvc corev1.PersistentVolumeClaim
+	if err := r.Get(ctx, req.NamespacedName, &pvc); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !r.isMine(&pvc) || pvc.Spec.VolumeName != "" || !pvc.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	var class storagev1.StorageClass
+	if err := r.Get(ctx, client.ObjectKey{Name: ptrValue(pvc.Spec.StorageClassName)}, &class); err != nil {
+		return ctrl.Result{}, err
+	}
+	if class.Provisioner != provisionerName {
+		return ctrl.Result{}, nil
+	}
+
+	// The PV's name is derived from the claim's UID: a retry finds the PV
+	// it created before, instead of creating a second one.
+	pvName := "pvc-" + string(pvc.UID)
+	var existing corev1.PersistentVolume
+	if err := r.Get(ctx, client.ObjectKey{Name: pvName}, &existing); err == nil {
+		return ctrl.Result{}, nil
+	} else if !apierrors.IsNotFound(err) {
+		return ctrl.Result{}, err
+	}
+
+	// Not retried: the claim's spec is immutable, so it can't get better.
+	if reason := unsupported(&pvc); reason != "" {
+		provisionsTotal.WithLabelValues("failed").Inc()
+		r.Recorder.Eventf(&pvc, corev1.EventTypeWarning, "ProvisioningFailed", "%s: %s", provisionerName, reason)
+		return ctrl.Result{}, nil
+	}
+
+	dir := filepath.Join(r.BasePath, fmt.Sprintf("%s_%s_%s", pvName, pvc.Namespace, pvc.Name))
+	r.Recorder.Eventf(&pvc, corev1.EventTypeNormal, "Provisioning", "Creating %s on node %s", dir, r.Node)
+	// World-writable: the Pod's containers may run as any UID, and
+	// hostPath volumes ignore fsGroup.
+	if err := os.MkdirAll(dir, 0o777); err != nil {
+		provisionsTotal.WithLabelValues("failed").Inc()
+		r.Recorder.Eventf(&pvc, corev1.EventTypeWarning, "ProvisioningFailed", "Creating %s: %s", dir, err)
+		return ctrl.Result{}, err
+	}
+	if err := os.Chmod(dir, 0o777); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	reclaim := corev1.PersistentVolumeReclaimDelete
+	if class.ReclaimPolicy != nil {
+		reclaim = *class.ReclaimPolicy
+	}
+	dirType := corev1.HostPathDirectory
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        pvName,
+			Labels:      map[string]string{nodeLabel: r.Node},
+			Annotations: map[string]string{provisionedByAnnotation: provisionerName},
+			Finalizers:  []string{cleanupFinalizer},
+		},
+		Spec: corev1.PersistentVolumeSpec{
+			Capacity:                      corev1.ResourceList{corev1.ResourceStorage: pvc.Spec.Resources.Requests[corev1.ResourceStorage]},
+			AccessModes:                   pvc.Spec.AccessModes,
+			PersistentVolumeReclaimPolicy: reclaim,
+			StorageClassName:              class.Name,
+			VolumeMode:                    pvc.Spec.VolumeMode,
+			// Pre-bound to the claim: the PV controller binds the claim to
+			// it, and to nothing else.
+			ClaimRef: &corev1.ObjectReference{
+				Kind:       "PersistentVolumeClaim",
+				APIVersion: "v1",
+				Namespace:  pvc.Namespace,
+				Name:       pvc.Name,
+				UID:        pvc.UID,
+			},
+			PersistentVolumeSource: corev1.PersistentVolumeSource{
+				HostPath: &corev1.HostPathVolumeSource{Path: dir, Type: &dirType},
+			},
+			// The data is on this node only: every Pod of the claim is
+			// scheduled here.
+			NodeAffinity: &corev1.VolumeNodeAffinity{
+				Required: &corev1.NodeSelector{NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+					MatchExpressions: []corev1.NodeSelectorRequirement{{
+						Key:      corev1.LabelHostname,
+						Operator: corev1.NodeSelectorOpIn,
+						Values:   []string{r.Hostname},
+					}},
+				}}},
+			},
+		},
+	}
+	if err := r.Create(ctx, pv); err != nil {
+		provisionsTotal.WithLabelValues("failed").Inc()
+		r.Recorder.Eventf(&pvc, corev1.EventTypeWarning, "ProvisioningFailed", "Creating PV %s: %s", pvName, err)
+		return ctrl.Result{}, err
+	}
+	provisionsTotal.WithLabelValues("succeeded").Inc()
+	r.Recorder.Eventf(&pvc, corev1.EventTypeNormal, "ProvisioningSucceeded", "Successfully provisioned volume %s", pvName)
+	log.Info("Provisioned", "claim", req.NamespacedName, "pv", pvName, "path", dir)
+	return ctrl.Result{}, nil
+}
+
+// isMine reports whether the claim is for this provisioner, and the
+// scheduler has picked this node for it.
+func (r *ClaimReconciler) isMine(pvc *corev1.PersistentVolumeClaim) bool {
+	return pvc.Annotations[storageProvisionerAnnotation] == provisionerName &&
+		pvc.Annotations[selectedNodeAnnotation] == r.Node
+}
+
+// unsupported says why a claim can't be served by a directory, or "".
+func unsupported(pvc *corev1.PersistentVolumeClaim) string {
+	switch {
+	case pvc.Spec.Selector != nil:
+		return "claims with a selector are not supported"
+	case pvc.Spec.DataSource != nil || pvc.Spec.DataSourceRef != nil:
+		return "cloning and restoring from snapshots are not supported"
+	case pvc.Spec.VolumeMode != nil && *pvc.Spec.VolumeMode == corev1.PersistentVolumeBlock:
+		return "volumeMode: Block is not supported"
+	}
+	for _, mode := range pvc.Spec.AccessModes {
+		if !slices.Contains([]corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce, corev1.ReadWriteOncePod}, mode) {
+			return fmt.Sprintf("access mode %s is not supported: a directory is on one node", mode)
+		}
+	}
+	return ""
+}
+
+func ptrValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// SetupWithManager watches claims, and only reconciles this node's.
+// Every provisioner sees every claim: claims can't be selected on
+// annotations.
+func (r *ClaimReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&corev1.PersistentVolumeClaim{}, builder.WithPredicates(predicate.NewPredicateFuncs(func(obj client.Object) bool {
+			return r.isMine(obj.(*corev1.PersistentVolumeClaim))
+		}))).
+		Named("claims").
+		Complete(r)
+}
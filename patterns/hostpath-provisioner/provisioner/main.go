@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+)
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func main() {
+	ctrl.SetLogger(zap.New())
+
+	// One provisioner per node, from a DaemonSet: each creates the
+	// directories on its own node. Set from the downward API in
+	// manifests/daemonset.yaml.
+	node := os.Getenv("NODE_NAME")
+	if node == "" {
+		fmt.Println("NODE_NAME is not set")
+		os.Exit(1)
+	}
+	basePath := filepath.Clean(getEnv("BASE_PATH", "/var/lib/hostpath-provisioner"))
+	if err := os.MkdirAll(basePath, 0o755); err != nil {
+		fmt.Printf("Error creating %s: %s\n", basePath, err)
+		os.Exit(1)
+	}
+
+	// Authenticates as the Pod's ServiceAccount, or the local kubeconfig for
+	// go run .; see manifests/rbac.yaml for what it may do.
+	cfg := ctrl.GetConfigOrDie()
+
+	// Only this node's PVs are cached. There is no leader election: the
+	// nodes never work on the same claim or the same PV.
+	mgr, err := ctrl.NewManager(cfg, ctrl.Options{
+		Metrics:                metricsserver.Options{BindAddress: getEnv("METRICS_ADDR", ":8080")},
+		HealthProbeBindAddress: getEnv("PROBE_ADDR", ":8081"),
+		Cache: cache.Options{ByObject: map[client.Object]cache.ByObject{
+			&corev1.PersistentVolume{}: {Label: labels.SelectorFromSet(labels.Set{nodeLabel: node})},
+		}},
+	})
+	if err != nil {
+		fmt.Printf("Error creating manager: %s\n", err)
+		os.Exit(1)
+	}
+
+	// PVs are pinned with the node's kubernetes.io/hostname label, which
+	// is usually, but not always, its name. The cache isn't started yet:
+	// read it straight from the API server.
+	var n corev1.Node
+	if err := mgr.GetAPIReader().Get(context.Background(), client.ObjectKey{Name: node}, &n); err != nil {
+		fmt.Printf("Error getting node %s: %s\n", node, err)
+		os.Exit(1)
+	}
+	hostname := n.Labels[corev1.LabelHostname]
+	if hostname == "" {
+		hostname = node
+	}
+
+	if err := (&ClaimReconciler{
+		Client:   mgr.GetClient(),
+		Recorder: mgr.GetEventRecorderFor(provisionerName),
+		Node:     node,
+		Hostname: hostname,
+		BasePath: basePath,
+	}).SetupWithManager(mgr); err != nil {
+		fmt.Printf("Error setting up claim controller: %s\n", err)
+		os.Exit(1)
+	}
+	if err := (&VolumeReconciler{
+		Client:   mgr.GetClient(),
+		Node:     node,
+		BasePath: basePath,
+	}).SetupWithManager(mgr); err != nil {
+		fmt.Printf("Error setting up volume controller: %s\n", err)
+		os.Exit(1)
+	}
+	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
+		fmt.Printf("Error adding health check: %s\n", err)
+		os.Exit(1)
+	}
+	if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
+		fmt.Printf("Error adding ready check: %s\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Provisioning %s volumes on node %s under %s\n", provisionerName, node, basePath)
+	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+		fmt.Printf("Error running manager: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("Shutting down...")
+}
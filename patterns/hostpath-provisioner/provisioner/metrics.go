@@ -0,0 +1,24 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Metrics go to controller-runtime's registry, which the Manager serves on
+// its /metrics next to the controller_runtime_* and workqueue_* metrics.
+var (
+	provisionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "hostpath_provisioner_provisions_total",
+		Help: "Volumes provisioned on this node, by result (succeeded, failed).",
+	}, []string{"result"})
+
+	deletionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "hostpath_provisioner_deletions_total",
+		Help: "Volume directories removed on this node, by result (succeeded, failed).",
+	}, []string{"result"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(provisionsTotal, deletionsTotal)
+}
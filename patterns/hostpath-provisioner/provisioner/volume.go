@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// VolumeReconciler reclaims this node's volumes. A PV whose claim is gone
+// is Released; with reclaimPolicy Delete, its directory is removed and
+// the PV deleted. A PV deleted by hand loses its directory the same way,
+// thanks to the finalizer. With Retain, the directory stays.
+type VolumeReconciler struct {
+	client.Client
+	Node     string
+	BasePath string
+}
+
+func (r *VolumeReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+
+	var pv corev1.PersistentVolume
+	if err := r.Get(ctx, req.NamespacedName, &pv); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if pv.Annotations[provisionedByAnnotation] != provisionerName || pv.Labels[nodeLabel] != r.Node {
+		return ctrl.Result{}, nil
+	}
+	deleting := !pv.DeletionTimestamp.IsZero()
+	reclaim := pv.Spec.PersistentVolumeReclaimPolicy == corev1.PersistentVolumeReclaimDelete
+	if !deleting && !(reclaim && pv.Status.Phase == corev1.VolumeReleased) {
+		return ctrl.Result{}, nil
+	}
+
+	if reclaim && pv.Spec.HostPath != nil {
+		dir := pv.Spec.HostPath.Path
+		// Never outside BasePath, whatever the PV says: PVs can be edited.
+		if filepath.Dir(dir) != filepath.Clean(r.BasePath) || !strings.HasPrefix(filepath.Base(dir), pv.Name+"_") {
+			return ctrl.Result{}, fmt.Errorf("refusing to remove %s: not a volume directory under %s", dir, r.BasePath)
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			deletionsTotal.WithLabelValues("failed").Inc()
+			return ctrl.Result{}, err
+		}
+		deletionsTotal.WithLabelValues("succeeded").Inc()
+		log.Info("Removed", "pv", pv.Name, "path", dir)
+	}
+
+	if !deleting {
+		// The finalizer holds the PV until the next pass removes it.
+		return ctrl.Result{}, client.IgnoreNotFound(r.Delete(ctx, &pv))
+	}
+	if controllerutil.RemoveFinalizer(&pv, cleanupFinalizer) {
+		return ctrl.Result{}, client.IgnoreNotFound(r.Update(ctx, &pv))
+	}
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager watches this node's PVs: the cache only holds those
+// (see main.go).
+func (r *VolumeReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&corev1.PersistentVolume{}).
+		Named("volumes").
+		Complete(r)
+}
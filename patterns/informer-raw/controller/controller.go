@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	appsinformers "k8s.io/client-go/informers/apps/v1"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// Controller copies a fixed set of labels from each Namespace onto every
+// Deployment in it. Everything controller-runtime's Manager and Builder
+// would wire up is spelled out here: informers, listers, event handlers,
+// the workqueue and its rate limiter, and the worker loop.
+type Controller struct {
+	client   kubernetes.Interface
+	recorder record.EventRecorder
+	// syncLabels are the Namespace label keys copied to Deployments.
+	syncLabels []string
+	// maxRetries is how often a failing key is retried, with backoff,
+	// before it is dropped until its next event or resync.
+	maxRetries int
+
+	// Listers read from the informers' caches: no API call per reconcile.
+	namespaces  corelisters.NamespaceLister
+	deployments appslisters.DeploymentLister
+	synced      []cache.InformerSynced
+
+	// The queue holds "namespace/name" keys of Deployments, never objects:
+	// the worker always reads the latest state from the cache, and several
+	// events for one Deployment collapse into a single reconcile.
+	queue workqueue.TypedRateLimitingInterface[string]
+
+	ready atomic.Bool // set once the caches are synced
+}
+
+func NewController(client kubernetes.Interface, recorder record.EventRecorder,
+	nsInformer coreinformers.NamespaceInformer, deployInformer appsinformers.DeploymentInformer,
+	rateLimiter workqueue.TypedRateLimiter[string], syncLabels []string, maxRetries int) (*Controller, error) {
+	c := &Controller{
+		client:      client,
+		recorder:    recorder,
+		syncLabels:  syncLabels,
+		maxRetries:  maxRetries,
+		namespaces:  nsInformer.Lister(),
+		deployments: deployInformer.Lister(),
+		synced:      []cache.InformerSynced{nsInformer.Informer().HasSynced, deployInformer.Informer().HasSynced},
+		queue: workqueue.NewTypedRateLimitingQueueWithConfig(rateLimiter,
+			workqueue.TypedRateLimitingQueueConfig[string]{Name: "labelsync"}),
+	}
+
+	// Deployments: the primary resource. Every event, resyncs included,
+	// enqueues the Deployment's key.
+	if _, err := deployInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    c.enqueue,
+		UpdateFunc: func(_, obj any) { c.enqueue(obj) },
+		// A deleted Deployment has nothing left to sync, but this is where
+		// every controller meets tombstones: a delete the watch missed
+		// arrives as cache.DeletedFinalStateUnknown, not a Deployment.
+		// DeletionHandlingMetaNamespaceKeyFunc unwraps it.
+		DeleteFunc: c.enqueue,
+	}); err != nil {
+		return nil, err
+	}
+
+	// Namespaces: a watched resource that maps to the primary one. A label
+	// change on a Namespace enqueues every Deployment in it; in
+	// controller-runtime, Watches() with EnqueueRequestsFromMapFunc.
+	if _, err := nsInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj any) { c.enqueueNamespace(obj.(*corev1.Namespace).Name) },
+		UpdateFunc: func(old, obj any) {
+			o, n := old.(*corev1.Namespace), obj.(*corev1.Namespace)
+			// The predicate: resyncs and status changes don't change the
+			// labels, and the Deployments get their own resync anyway.
+			if !c.labelsChanged(o.Labels, n.Labels) {
+				return
+			}
+			c.enqueueNamespace(n.Name)
+		},
+	}); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Controller) enqueue(obj any) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	c.queue.Add(key)
+}
+
+func (c *Controller) enqueueNamespace(namespace string) {
+	list, err := c.deployments.Deployments(namespace).List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	for _, d := range list {
+		c.enqueue(d)
+	}
+}
+
+func (c *Controller) labelsChanged(old, new map[string]string) bool {
+	for _, k := range c.syncLabels {
+		if old[k] != new[k] {
+			return true
+		}
+	}
+	return false
+}
+
+// Run starts the workers once the caches are synced, and blocks until ctx
+// is done. Work in progress is finished before it returns.
+func (c *Controller) Run(ctx context.Context, workers int) error {
+	defer utilruntime.HandleCrash()
+	defer c.queue.ShutDown()
+
+	fmt.Println("Waiting for informer caches to sync")
+	if !cache.WaitForCacheSync(ctx.Done(), c.synced...) {
+		return fmt.Errorf("caches did not sync")
+	}
+	c.ready.Store(true)
+	fmt.Printf("Caches synced, starting %d workers\n", workers)
+
+	// Syncs in progress at shutdown run to the end, on a context that
+	// isn't cancelled with ctx, rather than leave a patch half sent.
+	workCtx := context.WithoutCancel(ctx)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// The queue never hands the same key to two workers at once,
+			// so a Deployment is only ever reconciled by one of them.
+			wait.UntilWithContext(ctx, func(context.Context) { c.runWorker(workCtx) }, time.Second)
+		}()
+	}
+	<-ctx.Done()
+	// Stop handing out keys, and wait for the ones being processed.
+	c.queue.ShutDownWithDrain()
+	wg.Wait()
+	return nil
+}
+
+// Ready reports whether the caches are synced, for /readyz.
+func (c *Controller) Ready() bool {
+	return c.ready.Load()
+}
+
+func (c *Controller) runWorker(ctx context.Context) {
+	for c.processNextItem(ctx) {
+	}
+}
+
+// processNextItem is controller-runtime's reconcileHandler: Get, sync,
+// then Forget on success or AddRateLimited on error, and always Done.
+func (c *Controller) processNextItem(ctx context.Context) bool {
+	key, shutdown := c.queue.Get()
+	if shutdown {
+		return false
+	}
+	// Done lets the queue hand the key out again. If the key was added
+	// while it was being processed, that is when it comes back.
+	defer c.queue.Done(key)
+
+	start := time.Now()
+	err := c.sync(ctx, key)
+	reconcileDuration.Observe(time.Since(start).Seconds())
+
+	switch {
+	case err == nil:
+		// Resets the key's backoff: its next failure starts from the base
+		// delay again.
+		c.queue.Forget(key)
+		reconcileTotal.WithLabelValues("success").Inc()
+	case c.queue.NumRequeues(key) < c.maxRetries:
+		reconcileTotal.WithLabelValues("retry").Inc()
+		fmt.Printf("Error syncing %s (retry %d): %s\n", key, c.queue.NumRequeues(key)+1, err)
+		c.queue.AddRateLimited(key)
+	default:
+		// Give up for now. Level-triggered: the next event or resync
+		// brings the key back with a clean slate.
+		reconcileTotal.WithLabelValues("dropped").Inc()
+		fmt.Printf("Dropping %s after %d retries: %s\n", key, c.maxRetries, err)
+		c.queue.Forget(key)
+		utilruntime.HandleError(err)
+	}
+	return true
+}
+
+// deploymentEvent emits an Event on the Deployment, seen in kubectl
+// describe.
+func (c *Controller) deploymentEvent(d *appsv1.Deployment, reason, format string, args ...any) {
+	c.recorder.Eventf(d, corev1.EventTypeNormal, reason, format, args...)
+}
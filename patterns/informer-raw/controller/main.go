@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"golang.org/x/time/rate"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+)
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func getEnvInt(key string, fallback int) int {
+	if value, ok := os.LookupEnv(key); ok {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+		fmt.Printf("Invalid %s=%q, using default %d\n", key, value, fallback)
+	}
+	return fallback
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if value, ok := os.LookupEnv(key); ok {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+		fmt.Printf("Invalid %s=%q, using default %s\n", key, value, fallback)
+	}
+	return fallback
+}
+
+// inClusterClient returns a client authenticated as the Pod's
+// ServiceAccount; see manifests/rbac.yaml for what it may do.
+func inClusterClient() (kubernetes.Interface, error) {
+	cfg, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(cfg)
+}
+
+// newRateLimiter is workqueue.DefaultTypedControllerRateLimiter, with its
+// knobs exposed. A key's retry delay is the larger of:
+//   - per item: base, 2×base, 4×base, ... capped at max, reset by Forget;
+//   - overall: a token bucket shared by all keys, so a burst of failures
+//     can't turn into a burst of API calls.
+func newRateLimiter() workqueue.TypedRateLimiter[string] {
+	return workqueue.NewTypedMaxOfRateLimiter(
+		workqueue.NewTypedItemExponentialFailureRateLimiter[string](
+			getEnvDuration("RETRY_BASE_DELAY", 5*time.Millisecond),
+			getEnvDuration("RETRY_MAX_DELAY", 5*time.Minute)),
+		&workqueue.TypedBucketRateLimiter[string]{Limiter: rate.NewLimiter(
+			rate.Limit(getEnvInt("RETRY_QPS", 10)), getEnvInt("RETRY_BURST", 100))},
+	)
+}
+
+func main() {
+	listenAddr := getEnv("LISTEN_ADDR", ":8080")
+	var syncLabels []string
+	for _, k := range strings.Split(getEnv("SYNC_LABELS", "team,cost-center"), ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			syncLabels = append(syncLabels, k)
+		}
+	}
+
+	client, err := inClusterClient()
+	if err != nil {
+		fmt.Printf("Error creating Kubernetes client: %s\n", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	// Events go through a broadcaster, which batches and rate-limits them,
+	// to the API. controller-runtime's GetEventRecorderFor does the same.
+	broadcaster := record.NewBroadcaster(record.WithContext(ctx))
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: client.CoreV1().Events("")})
+	defer broadcaster.Shutdown()
+	recorder := broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: "labelsync"})
+
+	// One factory, one informer per type, shared by everything that asks
+	// for it: a second controller on Deployments in this process would
+	// reuse the same watch and cache. The resync period replays every
+	// cached object as an update, so every Deployment is reconciled at
+	// least this often, even without changes.
+	factory := informers.NewSharedInformerFactory(client, getEnvDuration("RESYNC_PERIOD", 10*time.Minute))
+	controller, err := NewController(client, recorder,
+		factory.Core().V1().Namespaces(), factory.Apps().V1().Deployments(),
+		newRateLimiter(), syncLabels, getEnvInt("MAX_RETRIES", 5))
+	if err != nil {
+		fmt.Printf("Error setting up the controller: %s\n", err)
+		os.Exit(1)
+	}
+	// Start only starts the informers requested so far, hence after
+	// NewController. It doesn't block.
+	factory.Start(ctx.Done())
+	defer factory.Shutdown()
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", promhttp.Handler())
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) { w.Write([]byte("ok")) })
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, _ *http.Request) {
+		if !controller.Ready() {
+			http.Error(w, "caches not synced", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+	server := &http.Server{Addr: listenAddr, Handler: mux}
+	go func() {
+		if err := server.ListenAndServe(); err != http.ErrServerClosed {
+			fmt.Printf("Error starting server: %s\n", err)
+			os.Exit(1)
+		}
+	}()
+
+	fmt.Printf("Syncing namespace labels %v onto Deployments; listening on %s\n", syncLabels, listenAddr)
+	if err := controller.Run(ctx, getEnvInt("WORKERS", 2)); err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server.Shutdown(shutdownCtx)
+	fmt.Println("Shutting down...")
+}
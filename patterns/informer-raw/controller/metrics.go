@@ -0,0 +1,85 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// Metrics are registered with the default registry via 'promauto'.
+var (
+	reconcileTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "labelsync_reconcile_total",
+		Help: "Keys processed, by result (success, retry, dropped).",
+	}, []string{"result"})
+
+	reconcileDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "labelsync_reconcile_duration_seconds",
+		Help:    "Time to sync one key.",
+		Buckets: prometheus.ExponentialBuckets(0.0001, 4, 10),
+	})
+
+	labelsPatched = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "labelsync_deployments_patched_total",
+		Help: "Deployments whose labels were changed.",
+	})
+)
+
+// client-go's workqueue measures itself, but reports to a no-op provider
+// unless one is set; controller-runtime sets one for you. These are the
+// same metric names controller-runtime uses, so its dashboards work.
+var (
+	wqDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "workqueue_depth", Help: "Current depth of the workqueue.",
+	}, []string{"name"})
+	wqAdds = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "workqueue_adds_total", Help: "Adds handled by the workqueue.",
+	}, []string{"name"})
+	wqLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "workqueue_queue_duration_seconds", Help: "Time an item waits in the queue before it is processed.",
+		Buckets: prometheus.ExponentialBuckets(10e-9, 10, 12),
+	}, []string{"name"})
+	wqWorkDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "workqueue_work_duration_seconds", Help: "Time processing an item takes.",
+		Buckets: prometheus.ExponentialBuckets(10e-9, 10, 12),
+	}, []string{"name"})
+	wqUnfinished = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "workqueue_unfinished_work_seconds", Help: "Seconds of work in progress not yet observed by work_duration; large values mean stuck workers.",
+	}, []string{"name"})
+	wqLongestRunning = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "workqueue_longest_running_processor_seconds", Help: "How long the longest running item has been processed.",
+	}, []string{"name"})
+	wqRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "workqueue_retries_total", Help: "Retries (AddRateLimited) handled by the workqueue.",
+	}, []string{"name"})
+)
+
+type workqueueMetrics struct{}
+
+func (workqueueMetrics) NewDepthMetric(name string) workqueue.GaugeMetric {
+	return wqDepth.WithLabelValues(name)
+}
+func (workqueueMetrics) NewAddsMetric(name string) workqueue.CounterMetric {
+	return wqAdds.WithLabelValues(name)
+}
+func (workqueueMetrics) NewLatencyMetric(name string) workqueue.HistogramMetric {
+	return wqLatency.WithLabelValues(name)
+}
+func (workqueueMetrics) NewWorkDurationMetric(name string) workqueue.HistogramMetric {
+	return wqWorkDuration.WithLabelValues(name)
+}
+func (workqueueMetrics) NewUnfinishedWorkSecondsMetric(name string) workqueue.SettableGaugeMetric {
+	return wqUnfinished.WithLabelValues(name)
+}
+func (workqueueMetrics) NewLongestRunningProcessorSecondsMetric(name string) workqueue.SettableGaugeMetric {
+	return wqLongestRunning.WithLabelValues(name)
+}
+func (workqueueMetrics) NewRetriesMetric(name string) workqueue.CounterMetric {
+	return wqRetries.WithLabelValues(name)
+}
+
+// SetProvider only takes effect for queues created after it, and only the
+// first call counts: it runs before any queue exists.
+func init() {
+	workqueue.SetProvider(workqueueMetrics{})
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+)
+
+// syncedAnnotation lists the label keys the controller set on a
+// Deployment. Without it, a label removed from the Namespace couldn't be
+// told apart from one the Deployment's author added: only the keys listed
+// here are ever removed.
+const syncedAnnotation = "labelsync.mydomain.com/synced"
+
+// sync is the reconcile function: it reads the Deployment and its
+// Namespace from the caches, and patches the Deployment's labels to match.
+// It is idempotent, and doesn't care which event brought the key.
+func (c *Controller) sync(ctx context.Context, key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return nil // a malformed key will never succeed; don't retry it
+	}
+	d, err := c.deployments.Deployments(namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		return nil // deleted since it was queued
+	}
+	if err != nil {
+		return err
+	}
+	if d.DeletionTimestamp != nil {
+		return nil
+	}
+	ns, err := c.namespaces.Get(namespace)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	want := map[string]string{}
+	for _, k := range c.syncLabels {
+		if v, ok := ns.Labels[k]; ok {
+			want[k] = v
+		}
+	}
+	// JSON merge patch: a key set to null is removed.
+	labels := map[string]any{}
+	var set, removed []string
+	for _, k := range slices.Sorted(maps.Keys(want)) {
+		if v, ok := d.Labels[k]; !ok || v != want[k] {
+			labels[k] = want[k]
+			set = append(set, k+"="+want[k])
+		}
+	}
+	for _, k := range strings.Split(d.Annotations[syncedAnnotation], ",") {
+		if _, stillWanted := want[k]; k != "" && !stillWanted {
+			if _, ok := d.Labels[k]; ok {
+				labels[k] = nil
+				removed = append(removed, k)
+			}
+		}
+	}
+	var synced any = strings.Join(slices.Sorted(maps.Keys(want)), ",")
+	if len(labels) == 0 && synced == d.Annotations[syncedAnnotation] {
+		return nil // already in sync: the common case, with no API call
+	}
+	if synced == "" {
+		synced = nil
+	}
+
+	patch, err := json.Marshal(map[string]any{"metadata": map[string]any{
+		"labels":      labels,
+		"annotations": map[string]any{syncedAnnotation: synced},
+		// The decision was made on the cached copy. With its
+		// resourceVersion in the patch, the API server refuses it (409
+		// Conflict) if the Deployment changed since; the key is retried
+		// with backoff, by which time the cache has caught up.
+		"resourceVersion": d.ResourceVersion,
+	}})
+	if err != nil {
+		return err
+	}
+	// Only the Deployment's own labels: changing the Pod template's labels
+	// would roll out every Pod, and could break the selector.
+	_, err = c.client.AppsV1().Deployments(namespace).Patch(ctx, name, types.MergePatchType, patch,
+		metav1.PatchOptions{FieldManager: "labelsync"})
+	if err != nil {
+		return fmt.Errorf("patching %s: %w", key, err)
+	}
+
+	var changes []string
+	if len(set) > 0 {
+		changes = append(changes, "set "+strings.Join(set, ", "))
+	}
+	if len(removed) > 0 {
+		changes = append(changes, "removed "+strings.Join(removed, ", "))
+	}
+	if msg := strings.Join(changes, "; "); msg != "" {
+		fmt.Printf("Synced %s from namespace labels: %s\n", key, msg)
+		c.deploymentEvent(d, "LabelsSynced", "Namespace labels: %s", msg)
+		labelsPatched.Inc()
+	}
+	return nil
+}
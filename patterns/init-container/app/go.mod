@@ -0,0 +1,3 @@
+module init-app
+
+go 1.24.3
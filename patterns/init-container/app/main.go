@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+// mustStart does what a lot of real apps do on startup: read the config
+// and open connections once, and give up if anything is missing. There is
+// no retry loop on purpose. Ordering is the init container's job; without
+// it, this app crash-loops until its dependencies happen to be up, and
+// every crash shows up as a restart and an alert.
+func mustStart() (config string, cacheAddr string) {
+	configFile := getEnv("CONFIG_FILE", "/config/feature-flags")
+	data, err := os.ReadFile(configFile)
+	if err != nil {
+		fmt.Printf("FATAL: reading config: %s\n", err)
+		os.Exit(1)
+	}
+
+	cacheAddr = getEnv("CACHE_ADDR", "cache:6379")
+	conn, err := net.DialTimeout("tcp", cacheAddr, 2*time.Second)
+	if err != nil {
+		fmt.Printf("FATAL: connecting to the cache: %s\n", err)
+		os.Exit(1)
+	}
+	conn.Close()
+
+	backend := getEnv("BACKEND_URL", "http://backend/")
+	resp, err := http.Get(backend)
+	if err != nil {
+		fmt.Printf("FATAL: warming up against the backend: %s\n", err)
+		os.Exit(1)
+	}
+	resp.Body.Close()
+	return strings.TrimSpace(string(data)), cacheAddr
+}
+
+func main() {
+	started := time.Now()
+	config, cacheAddr := mustStart()
+	fmt.Printf("Started with feature flags %q, cache at %s\n", config, cacheAddr)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"featureFlags": config,
+			"cache":        cacheAddr,
+			"startedAt":    started.Format(time.RFC3339),
+		})
+	})
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) { w.Write([]byte("ok")) })
+	if err := http.ListenAndServe(getEnv("LISTEN_ADDR", ":8080"), mux); err != nil {
+		fmt.Printf("Error starting server: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// Check is one dependency. Check returns nil once it is available, and an
+// error saying what is missing otherwise.
+type Check interface {
+	Name() string
+	Check(ctx context.Context) error
+}
+
+// TCPCheck waits for something to accept connections on Addr. The Service's
+// DNS name failing to resolve counts as not ready: the Service (or its
+// namespace) may simply not exist yet.
+type TCPCheck struct {
+	Addr    string
+	Timeout time.Duration
+}
+
+func (c *TCPCheck) Name() string { return "tcp " + c.Addr }
+
+func (c *TCPCheck) Check(ctx context.Context) error {
+	dialer := net.Dialer{Timeout: c.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", c.Addr)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// HTTPCheck waits for URL to answer 2xx. A TCP connect only proves the port
+// is open; a health endpoint says whether the app behind it is ready.
+type HTTPCheck struct {
+	URL    string
+	Client *http.Client
+}
+
+func (c *HTTPCheck) Name() string { return "http " + c.URL }
+
+func (c *HTTPCheck) Check(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.URL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("status %s", resp.Status)
+	}
+	return nil
+}
+
+// ConfigMapCheck waits for Key to be set, non-empty, in the ConfigMap.
+// The ConfigMap may be created by someone else entirely (another team's
+// pipeline, an operator), after the Pod.
+//
+// A ConfigMap volume or env var wouldn't do this: a missing ConfigMap keeps
+// the Pod in ContainerCreating (or CreateContainerConfigError), and a
+// missing key in a volume just means a missing file. With WriteDir set, the
+// value is written to <WriteDir>/<Key> for the app to read, so the app
+// finds its config in place before its first line of code runs.
+type ConfigMapCheck struct {
+	Client    kubernetes.Interface
+	Namespace string
+	ConfigMap string
+	Key       string
+	WriteDir  string
+}
+
+func (c *ConfigMapCheck) Name() string {
+	return fmt.Sprintf("configmap %s/%s key %s", c.Namespace, c.ConfigMap, c.Key)
+}
+
+func (c *ConfigMapCheck) Check(ctx context.Context) error {
+	cm, err := c.Client.CoreV1().ConfigMaps(c.Namespace).Get(ctx, c.ConfigMap, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return fmt.Errorf("configmap not found")
+	}
+	if apierrors.IsForbidden(err) {
+		// Waiting won't fix RBAC, but someone may still be applying it
+		return fmt.Errorf("forbidden; does the ServiceAccount have get on this ConfigMap? %w", err)
+	}
+	if err != nil {
+		return err
+	}
+	value, ok := cm.Data[c.Key]
+	if !ok || value == "" {
+		return fmt.Errorf("key not set")
+	}
+	if c.WriteDir == "" {
+		return nil
+	}
+	// Write then rename, so the app never reads half a file
+	path := filepath.Join(c.WriteDir, c.Key)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(value), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// inClusterClient returns a client authenticated as the Pod's
+// ServiceAccount; what it may read is decided by manifests/rbac.yaml.
+func inClusterClient() (kubernetes.Interface, error) {
+	cfg, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(cfg)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if value, ok := os.LookupEnv(key); ok {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+		fmt.Printf("Invalid %s=%q, using default %s\n", key, value, fallback)
+	}
+	return fallback
+}
+
+func getEnvFloat(key string, fallback float64) float64 {
+	if value, ok := os.LookupEnv(key); ok {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+		fmt.Printf("Invalid %s=%q, using default %g\n", key, value, fallback)
+	}
+	return fallback
+}
+
+// splitList splits a comma-separated env var, dropping empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Exit codes. Any non-zero one fails the init container, and the kubelet
+// restarts it (with its own backoff) under the Pod's restartPolicy; the app
+// container never starts until it succeeds.
+const (
+	exitReady   = 0
+	exitTimeout = 1
+	exitConfig  = 2
+)
+
+// checks builds the checks from the environment:
+//
+//	WAIT_TCP=postgres.db.svc:5432,redis:6379
+//	WAIT_HTTP=http://auth.svc:8080/healthz
+//	WAIT_CONFIGMAP=app-config:feature-flags  (name:key, in the Pod's namespace)
+func checks() ([]Check, error) {
+	var list []Check
+	for _, addr := range splitList(getEnv("WAIT_TCP", "")) {
+		list = append(list, &TCPCheck{Addr: addr, Timeout: 2 * time.Second})
+	}
+	for _, url := range splitList(getEnv("WAIT_HTTP", "")) {
+		list = append(list, &HTTPCheck{URL: url, Client: &http.Client{Timeout: 2 * time.Second}})
+	}
+	if refs := splitList(getEnv("WAIT_CONFIGMAP", "")); len(refs) > 0 {
+		client, err := inClusterClient()
+		if err != nil {
+			return nil, fmt.Errorf("WAIT_CONFIGMAP needs the API server: %w", err)
+		}
+		for _, ref := range refs {
+			name, key, ok := strings.Cut(ref, ":")
+			if !ok || name == "" || key == "" {
+				return nil, fmt.Errorf("WAIT_CONFIGMAP item %q must be <name>:<key>", ref)
+			}
+			list = append(list, &ConfigMapCheck{
+				Client:    client,
+				Namespace: getEnv("POD_NAMESPACE", "default"),
+				ConfigMap: name,
+				Key:       key,
+				WriteDir:  getEnv("WRITE_DIR", ""),
+			})
+		}
+	}
+	if len(list) == 0 {
+		return nil, errors.New("nothing to wait for: set WAIT_TCP, WAIT_HTTP and/or WAIT_CONFIGMAP")
+	}
+	return list, nil
+}
+
+func main() {
+	list, err := checks()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		terminationMessage(err.Error())
+		os.Exit(exitConfig)
+	}
+	backoff := Backoff{
+		Initial: getEnvDuration("BACKOFF_INITIAL", 500*time.Millisecond),
+		Max:     getEnvDuration("BACKOFF_MAX", 10*time.Second),
+		Factor:  getEnvFloat("BACKOFF_FACTOR", 2),
+		Jitter:  getEnvFloat("BACKOFF_JITTER", 0.2),
+	}
+	timeout := getEnvDuration("WAIT_TIMEOUT", 5*time.Minute)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	start := time.Now()
+	fmt.Printf("Waiting up to %s for %d dependencies\n", timeout, len(list))
+
+	pending := WaitAll(ctx, list, backoff)
+	if len(pending) > 0 {
+		msg := fmt.Sprintf("timed out after %s waiting for: %s", timeout, strings.Join(pending, "; "))
+		fmt.Println(msg)
+		terminationMessage(msg)
+		os.Exit(exitTimeout)
+	}
+	fmt.Printf("All dependencies ready after %s\n", time.Since(start).Round(time.Millisecond))
+	os.Exit(exitReady)
+}
+
+// terminationMessage writes why we failed where the kubelet picks it up for
+// the container's status: "kubectl describe pod" shows it under the init
+// container's Last State, with no need to dig through its logs.
+func terminationMessage(msg string) {
+	path := getEnv("TERMINATION_MESSAGE_PATH", "/dev/termination-log")
+	_ = os.WriteFile(path, []byte(msg), 0o644)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Backoff spaces out the attempts of one check: Initial, then Factor times
+// longer each time, up to Max. Jitter (0.2 = ±20%) keeps the Pods of a
+// Deployment that all started together from polling the dependency in
+// lockstep, which after an outage would hit it as a thundering herd.
+type Backoff struct {
+	Initial time.Duration
+	Max     time.Duration
+	Factor  float64
+	Jitter  float64
+}
+
+// next returns the delay after d, and the jittered one to sleep.
+func (b Backoff) next(d time.Duration) (time.Duration, time.Duration) {
+	sleep := d
+	if b.Jitter > 0 {
+		sleep = time.Duration(float64(d) * (1 + b.Jitter*(2*rand.Float64()-1)))
+	}
+	d = time.Duration(float64(d) * b.Factor)
+	if d > b.Max {
+		d = b.Max
+	}
+	return d, sleep
+}
+
+// WaitAll runs the checks side by side, each retrying with its own backoff,
+// until all pass or ctx is done. It returns what was still missing then,
+// with the last error of each; nothing means everything is ready.
+func WaitAll(ctx context.Context, checks []Check, backoff Backoff) []string {
+	var (
+		mu      sync.Mutex
+		pending = map[string]string{}
+		wg      sync.WaitGroup
+	)
+	for _, c := range checks {
+		pending[c.Name()] = "not checked yet"
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := waitFor(ctx, c, backoff)
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				delete(pending, c.Name())
+			} else {
+				pending[c.Name()] = err.Error()
+			}
+		}()
+	}
+	wg.Wait()
+
+	var missing []string
+	for name, reason := range pending {
+		missing = append(missing, fmt.Sprintf("%s (%s)", name, reason))
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// waitFor retries one check until it passes, returning nil, or ctx is done,
+// returning its last error.
+func waitFor(ctx context.Context, c Check, backoff Backoff) error {
+	delay := backoff.Initial
+	for attempt := 1; ; attempt++ {
+		err := c.Check(ctx)
+		if err == nil {
+			fmt.Printf("Ready: %s (attempt %d)\n", c.Name(), attempt)
+			return nil
+		}
+		var sleep time.Duration
+		delay, sleep = backoff.next(delay)
+		fmt.Printf("Waiting for %s: %s (attempt %d, retrying in %s)\n",
+			c.Name(), err, attempt, sleep.Round(time.Millisecond))
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(sleep):
+		}
+	}
+}
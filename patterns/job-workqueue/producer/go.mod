@@ -0,0 +1,3 @@
+module workqueue-producer
+
+go 1.24.3
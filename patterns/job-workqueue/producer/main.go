@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func getEnvInt(key string, fallback int) int {
+	if value, ok := os.LookupEnv(key); ok {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+		fmt.Printf("Invalid %s=%q, using default %d\n", key, value, fallback)
+	}
+	return fallback
+}
+
+type item struct {
+	ID      string         `json:"id"`
+	Payload map[string]any `json:"payload"`
+}
+
+// post sends one batch, retrying while the queue isn't reachable yet (the
+// producer Job may start before the queue's Pod is ready).
+func post(url string, batch []item) (created, duplicates int, err error) {
+	body, _ := json.Marshal(batch)
+	delay := time.Second
+	for attempt := 1; ; attempt++ {
+		var resp *http.Response
+		resp, err = http.Post(url+"/items", "application/json", bytes.NewReader(body))
+		if err == nil {
+			var out struct{ Created, Duplicates int }
+			decodeErr := json.NewDecoder(resp.Body).Decode(&out)
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK && decodeErr == nil {
+				return out.Created, out.Duplicates, nil
+			}
+			err = fmt.Errorf("queue answered %s", resp.Status)
+		}
+		if attempt == 8 {
+			return 0, 0, err
+		}
+		fmt.Printf("Enqueue failed (attempt %d), retrying in %s: %s\n", attempt, delay, err)
+		time.Sleep(delay)
+		delay = min(delay*2, 15*time.Second)
+	}
+}
+
+func main() {
+	queueURL := getEnv("QUEUE_URL", "http://workqueue:8080")
+	count := getEnvInt("COUNT", 100)
+	batchSize := getEnvInt("BATCH_SIZE", 50)
+	prefix := getEnv("ID_PREFIX", "order-")
+
+	// IDs are derived from the item's identity, never random: running this
+	// Job twice (or its Pod being retried) enqueues nothing new the second
+	// time, because the queue skips IDs it has already seen.
+	var batch []item
+	created, duplicates := 0, 0
+	for n := 1; n <= count; n++ {
+		batch = append(batch, item{
+			ID: fmt.Sprintf("%s%05d", prefix, n),
+			Payload: map[string]any{
+				"order":       n,
+				"customer":    fmt.Sprintf("customer-%03d", n%37),
+				"amountCents": 100 + (n*7919)%9900,
+			},
+		})
+		if len(batch) == batchSize || n == count {
+			c, d, err := post(queueURL, batch)
+			if err != nil {
+				fmt.Printf("Error enqueueing items: %s\n", err)
+				os.Exit(1)
+			}
+			created += c
+			duplicates += d
+			batch = batch[:0]
+		}
+	}
+	fmt.Printf("Enqueued %d items (%d already queued)\n", created, duplicates)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Effect is one call to the mock downstream API ("charge this order",
+// "send this email"). It stands in for whatever real side effect a worker
+// has, and shows the contract the worker relies on: the same Idempotency-Key
+// applies the effect once, however often it is sent.
+type Effect struct {
+	Key       string          `json:"key"`
+	Body      json.RawMessage `json:"body"`
+	AppliedAt time.Time       `json:"appliedAt"`
+}
+
+var errKeyReused = errors.New("idempotency key reused with a different body")
+
+// Apply records the effect under key. A repeat with the same body returns
+// the original effect and replayed=true; a repeat with a different body is a
+// client bug, and is refused rather than silently ignored.
+func (q *Queue) Apply(key string, body json.RawMessage) (Effect, bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if e, ok := q.effects[key]; ok {
+		if !bytes.Equal(compact(e.Body), compact(body)) {
+			return e, true, errKeyReused
+		}
+		effectsTotal.WithLabelValues("replayed").Inc()
+		return e, true, nil
+	}
+	e := Effect{Key: key, Body: body, AppliedAt: time.Now()}
+	q.effects[key] = e
+	effectsTotal.WithLabelValues("applied").Inc()
+	return e, false, nil
+}
+
+func compact(b json.RawMessage) []byte {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, b); err != nil {
+		return b
+	}
+	return buf.Bytes()
+}
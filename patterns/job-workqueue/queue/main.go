@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func getEnvInt(key string, fallback int) int {
+	if value, ok := os.LookupEnv(key); ok {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+		fmt.Printf("Invalid %s=%q, using default %d\n", key, value, fallback)
+	}
+	return fallback
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if value, ok := os.LookupEnv(key); ok {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+		fmt.Printf("Invalid %s=%q, using default %s\n", key, value, fallback)
+	}
+	return fallback
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, errNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, errStaleLease):
+		status = http.StatusConflict
+	case errors.Is(err, errKeyReused):
+		status = http.StatusUnprocessableEntity
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+type leaseRequest struct {
+	Worker string `json:"worker"`
+	Shard  int    `json:"shard"`
+	Shards int    `json:"shards"`
+	TTL    string `json:"ttl"`
+}
+
+type updateRequest struct {
+	Lease  string `json:"lease"`
+	Result string `json:"result,omitempty"`
+	Error  string `json:"error,omitempty"`
+	TTL    string `json:"ttl,omitempty"`
+}
+
+func routes(q *Queue, defaultTTL time.Duration) *http.ServeMux {
+	ttl := func(s string) time.Duration {
+		if d, err := time.ParseDuration(s); err == nil && d > 0 {
+			return d
+		}
+		return defaultTTL
+	}
+
+	mux := http.NewServeMux()
+	// Enqueue one item or a batch. Existing IDs are skipped, not replaced.
+	mux.HandleFunc("POST /items", func(w http.ResponseWriter, r *http.Request) {
+		var items []struct {
+			ID      string          `json:"id"`
+			Payload json.RawMessage `json:"payload"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&items); err != nil {
+			writeError(w, fmt.Errorf("want a JSON array of {id, payload}: %w", err))
+			return
+		}
+		created, duplicates := 0, 0
+		for _, it := range items {
+			if it.ID == "" {
+				writeError(w, errors.New("every item needs an id"))
+				return
+			}
+			if q.Add(it.ID, it.Payload) {
+				created++
+			} else {
+				duplicates++
+			}
+		}
+		writeJSON(w, http.StatusOK, map[string]int{"created": created, "duplicates": duplicates})
+	})
+	mux.HandleFunc("POST /lease", func(w http.ResponseWriter, r *http.Request) {
+		var req leaseRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, err)
+			return
+		}
+		if req.Shards > 0 && (req.Shard < 0 || req.Shard >= req.Shards) {
+			writeError(w, fmt.Errorf("shard %d out of range for %d shards", req.Shard, req.Shards))
+			return
+		}
+		item, drained := q.Lease(req.Worker, req.Shard, req.Shards, ttl(req.TTL))
+		writeJSON(w, http.StatusOK, map[string]any{"item": item, "drained": drained})
+	})
+	mux.HandleFunc("POST /items/{id}/{action}", func(w http.ResponseWriter, r *http.Request) {
+		var req updateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, err)
+			return
+		}
+		id := r.PathValue("id")
+		var err error
+		switch r.PathValue("action") {
+		case "ack":
+			err = q.Ack(id, req.Lease, req.Result)
+		case "nack":
+			err = q.Nack(id, req.Lease, req.Error)
+		case "extend":
+			err = q.Extend(id, req.Lease, ttl(req.TTL))
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("GET /items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		item, ok := q.Get(r.PathValue("id"))
+		if !ok {
+			writeError(w, errNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, item)
+	})
+	mux.HandleFunc("GET /stats", func(w http.ResponseWriter, _ *http.Request) {
+		counts, dead := q.Stats()
+		writeJSON(w, http.StatusOK, map[string]any{"items": counts, "dead": dead})
+	})
+	// The mock downstream API. 201 the first time a key is seen, 200 with
+	// Idempotent-Replayed: true after that.
+	mux.HandleFunc("POST /effects", func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			writeError(w, errors.New("Idempotency-Key header is required"))
+			return
+		}
+		var body json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeError(w, err)
+			return
+		}
+		effect, replayed, err := q.Apply(key, body)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		status := http.StatusCreated
+		if replayed {
+			w.Header().Set("Idempotent-Replayed", "true")
+			status = http.StatusOK
+		}
+		writeJSON(w, status, effect)
+	})
+	mux.Handle("GET /metrics", promhttp.Handler())
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) { w.Write([]byte("ok")) })
+	return mux
+}
+
+func main() {
+	listenAddr := getEnv("LISTEN_ADDR", ":8080")
+	dataFile := getEnv("DATA_FILE", "")
+	q := NewQueue(getEnvInt("MAX_ATTEMPTS", 4), getEnvDuration("RETRY_BACKOFF", 2*time.Second))
+
+	if dataFile != "" {
+		if err := q.Load(dataFile); err != nil && !os.IsNotExist(err) {
+			// Starting empty would silently redo or lose work: refuse.
+			fmt.Printf("Error loading %s: %s\n", dataFile, err)
+			os.Exit(1)
+		}
+		counts, _ := q.Stats()
+		fmt.Printf("Loaded %s: %v\n", dataFile, counts)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	// Once a second: refresh the gauges and snapshot to DATA_FILE. An ack
+	// lost in a crash between snapshots is redone by a worker, which is safe
+	// because workers are idempotent anyway.
+	save := func() {
+		counts, _ := q.Stats()
+		for state, n := range counts {
+			itemsGauge.WithLabelValues(string(state)).Set(float64(n))
+		}
+		if dataFile != "" {
+			if err := q.Save(dataFile); err != nil {
+				fmt.Printf("Error saving %s: %s\n", dataFile, err)
+			}
+		}
+	}
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				save()
+			}
+		}
+	}()
+
+	server := &http.Server{Addr: listenAddr, Handler: routes(q, getEnvDuration("LEASE_TTL", 30*time.Second))}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Printf("Queue listening on %s (data file %q)\n", listenAddr, dataFile)
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		fmt.Printf("Error starting server: %s\n", err)
+		os.Exit(1)
+	}
+	save()
+	fmt.Println("Shutting down...")
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Metrics are registered with the default registry via 'promauto'.
+// queue_items is what a dashboard (or a KEDA-style scaler) watches.
+var (
+	itemsGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "queue_items",
+		Help: "Items in the queue by state (pending, leased, done, dead).",
+	}, []string{"state"})
+
+	leasesExpired = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "queue_leases_expired_total",
+		Help: "Leases that ran out before their worker acked, nacked or extended them.",
+	})
+
+	effectsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "queue_effects_total",
+		Help: "Calls to the mock downstream API, by result (applied, replayed).",
+	}, []string{"result"})
+)
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+type State string
+
+const (
+	Pending State = "pending"
+	Leased  State = "leased"
+	Done    State = "done"
+	Dead    State = "dead" // failed MaxAttempts times; left for a human
+)
+
+// Item is one unit of work. Its ID is the idempotency key: adding the same
+// ID twice is a no-op, and the worker passes it on to every side effect.
+type Item struct {
+	ID         string          `json:"id"`
+	Payload    json.RawMessage `json:"payload"`
+	State      State           `json:"state"`
+	Attempts   int             `json:"attempts"`
+	Lease      string          `json:"lease,omitempty"`
+	LeasedBy   string          `json:"leasedBy,omitempty"`
+	LeaseUntil time.Time       `json:"leaseUntil,omitempty"`
+	NotBefore  time.Time       `json:"notBefore,omitempty"`
+	LastError  string          `json:"lastError,omitempty"`
+	Result     string          `json:"result,omitempty"`
+}
+
+var (
+	errNotFound   = errors.New("no such item")
+	errStaleLease = errors.New("lease expired or taken over by another worker")
+)
+
+// Queue is an in-memory work queue with leases: a leased item goes back to
+// pending if its worker doesn't ack, nack or extend it before the lease runs
+// out, which is how work from a crashed Pod gets retried.
+type Queue struct {
+	MaxAttempts int
+	Backoff     time.Duration
+
+	mu    sync.Mutex
+	items map[string]*Item
+	order []string // FIFO
+	// effects is the idempotency store of the mock downstream API (effects.go)
+	effects map[string]Effect
+}
+
+func NewQueue(maxAttempts int, backoff time.Duration) *Queue {
+	return &Queue{MaxAttempts: maxAttempts, Backoff: backoff, items: map[string]*Item{}, effects: map[string]Effect{}}
+}
+
+// Add enqueues an item, unless one with the same ID exists: a producer that
+// is retried, or run twice, doesn't create duplicate work.
+func (q *Queue) Add(id string, payload json.RawMessage) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok := q.items[id]; ok {
+		return false
+	}
+	q.items[id] = &Item{ID: id, Payload: payload, State: Pending}
+	q.order = append(q.order, id)
+	return true
+}
+
+// Shard maps an item to one of n shards. Indexed Job workers pass their
+// completion index and the Job's completions, so each index owns a fixed
+// slice of the queue.
+func Shard(id string, n int) int {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return int(h.Sum32() % uint32(n))
+}
+
+// Lease hands out the oldest ready item of the shard, or nil. drained is true
+// once the shard has nothing pending or leased: the worker can exit.
+func (q *Queue) Lease(worker string, shard, shards int, ttl time.Duration) (item *Item, drained bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := time.Now()
+	q.expireLeases(now)
+
+	drained = true
+	for _, id := range q.order {
+		it := q.items[id]
+		if shards > 0 && Shard(id, shards) != shard {
+			continue
+		}
+		if it.State == Leased || it.State == Pending {
+			drained = false
+		}
+		if it.State != Pending || now.Before(it.NotBefore) {
+			continue
+		}
+		it.State = Leased
+		it.Attempts++
+		it.Lease = newToken()
+		it.LeasedBy = worker
+		it.LeaseUntil = now.Add(ttl)
+		out := *it
+		return &out, false
+	}
+	return nil, drained
+}
+
+// expireLeases returns items whose worker went quiet to pending, or to dead
+// if that was their last attempt. The caller holds q.mu.
+func (q *Queue) expireLeases(now time.Time) {
+	for _, it := range q.items {
+		if it.State == Leased && now.After(it.LeaseUntil) {
+			leasesExpired.Inc()
+			q.fail(it, "lease expired (worker crashed or too slow)", now)
+		}
+	}
+}
+
+// Extend renews a lease: a worker heartbeats while it processes a long item.
+func (q *Queue) Extend(id, lease string, ttl time.Duration) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	it, err := q.leased(id, lease)
+	if err != nil {
+		return err
+	}
+	it.LeaseUntil = time.Now().Add(ttl)
+	return nil
+}
+
+// Ack marks an item done. Acking a done item again succeeds: a worker whose
+// first ack's response got lost can safely retry.
+func (q *Queue) Ack(id, lease, result string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if it, ok := q.items[id]; ok && it.State == Done {
+		return nil
+	}
+	it, err := q.leased(id, lease)
+	if err != nil {
+		return err
+	}
+	it.State, it.Result, it.Lease, it.LastError = Done, result, "", ""
+	return nil
+}
+
+// Nack reports a failed attempt; the item is retried after a backoff.
+func (q *Queue) Nack(id, lease, reason string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	it, err := q.leased(id, lease)
+	if err != nil {
+		return err
+	}
+	q.fail(it, reason, time.Now())
+	return nil
+}
+
+// fail ends an attempt: pending again after an exponential backoff, or dead.
+// The caller holds q.mu.
+func (q *Queue) fail(it *Item, reason string, now time.Time) {
+	it.Lease, it.LastError = "", reason
+	if it.Attempts >= q.MaxAttempts {
+		it.State = Dead
+		return
+	}
+	it.State = Pending
+	it.NotBefore = now.Add(q.Backoff << (it.Attempts - 1))
+}
+
+// leased returns the item if lease is its current lease. A worker whose lease
+// expired must not ack: the item may already be with another worker. The
+// caller holds q.mu.
+func (q *Queue) leased(id, lease string) (*Item, error) {
+	it, ok := q.items[id]
+	if !ok {
+		return nil, errNotFound
+	}
+	if it.State != Leased || it.Lease != lease {
+		return nil, errStaleLease
+	}
+	return it, nil
+}
+
+func (q *Queue) Get(id string) (Item, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	it, ok := q.items[id]
+	if !ok {
+		return Item{}, false
+	}
+	return *it, true
+}
+
+// Stats counts items by state, and lists the dead ones.
+func (q *Queue) Stats() (map[State]int, []Item) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.expireLeases(time.Now())
+	counts := map[State]int{Pending: 0, Leased: 0, Done: 0, Dead: 0}
+	var dead []Item
+	for _, id := range q.order {
+		it := q.items[id]
+		counts[it.State]++
+		if it.State == Dead {
+			dead = append(dead, *it)
+		}
+	}
+	return counts, dead
+}
+
+type snapshot struct {
+	Items   []*Item           `json:"items"`
+	Effects map[string]Effect `json:"effects"`
+}
+
+// Save writes the queue to path, via a temporary file and a rename so a
+// crash mid-write never leaves a truncated snapshot behind.
+func (q *Queue) Save(path string) error {
+	q.mu.Lock()
+	snap := snapshot{Effects: q.effects}
+	for _, id := range q.order {
+		snap.Items = append(snap.Items, q.items[id])
+	}
+	data, err := json.Marshal(snap)
+	q.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".queue-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Load restores a snapshot written by Save. Leases survive a restart: their
+// workers may still be running, and will ack or let them expire.
+func (q *Queue) Load(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return err
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, it := range snap.Items {
+		q.items[it.ID] = it
+		q.order = append(q.order, it.ID)
+	}
+	if snap.Effects != nil {
+		q.effects = snap.Effects
+	}
+	return nil
+}
+
+func newToken() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
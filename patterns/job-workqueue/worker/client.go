@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Item is the part of a queue item the worker needs.
+type Item struct {
+	ID       string          `json:"id"`
+	Payload  json.RawMessage `json:"payload"`
+	Attempts int             `json:"attempts"`
+	Lease    string          `json:"lease"`
+}
+
+// errLeaseLost means the queue gave the item to someone else: this worker
+// was too slow, or paused, and must drop it.
+var errLeaseLost = errors.New("lease lost")
+
+// Client talks to the queue service. Calls are retried while the queue is
+// unreachable, so a queue restart looks like a pause, not a failure.
+type Client struct {
+	URL     string
+	Worker  string
+	Timeout time.Duration // give up after this long; the Pod then fails and the Job retries it
+	http    http.Client
+}
+
+func (c *Client) call(path string, header http.Header, in, out any) (*http.Response, error) {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(c.Timeout)
+	delay := 500 * time.Millisecond
+	for {
+		req, _ := http.NewRequest(http.MethodPost, c.URL+path, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		resp, err := c.http.Do(req)
+		if err == nil && resp.StatusCode < 500 {
+			defer resp.Body.Close()
+			if resp.StatusCode == http.StatusConflict {
+				return resp, errLeaseLost
+			}
+			if resp.StatusCode >= 400 {
+				var e struct{ Error string }
+				json.NewDecoder(resp.Body).Decode(&e)
+				return resp, fmt.Errorf("%s: %s %s", path, resp.Status, e.Error)
+			}
+			if out != nil && resp.StatusCode != http.StatusNoContent {
+				return resp, json.NewDecoder(resp.Body).Decode(out)
+			}
+			return resp, nil
+		}
+		if err == nil {
+			resp.Body.Close()
+			err = fmt.Errorf("%s: %s", path, resp.Status)
+		}
+		if time.Now().After(deadline) {
+			return nil, err
+		}
+		fmt.Printf("Queue unavailable, retrying in %s: %s\n", delay, err)
+		time.Sleep(delay)
+		delay = min(delay*2, 10*time.Second)
+	}
+}
+
+// Lease asks for the next item of the shard. A nil item with drained=false
+// means "nothing ready now, ask again later".
+func (c *Client) Lease(shard, shards int, ttl time.Duration) (item *Item, drained bool, err error) {
+	var out struct {
+		Item    *Item `json:"item"`
+		Drained bool  `json:"drained"`
+	}
+	in := map[string]any{"worker": c.Worker, "shard": shard, "shards": shards, "ttl": ttl.String()}
+	_, err = c.call("/lease", nil, in, &out)
+	return out.Item, out.Drained, err
+}
+
+func (c *Client) Ack(it *Item, result string) error {
+	_, err := c.call("/items/"+it.ID+"/ack", nil, map[string]string{"lease": it.Lease, "result": result}, nil)
+	return err
+}
+
+func (c *Client) Nack(it *Item, reason string) error {
+	_, err := c.call("/items/"+it.ID+"/nack", nil, map[string]string{"lease": it.Lease, "error": reason}, nil)
+	return err
+}
+
+func (c *Client) Extend(it *Item, ttl time.Duration) error {
+	_, err := c.call("/items/"+it.ID+"/extend", nil, map[string]string{"lease": it.Lease, "ttl": ttl.String()}, nil)
+	return err
+}
+
+// Apply performs the item's side effect on the downstream API, keyed by the
+// item ID. replayed is true if an earlier attempt already applied it.
+func (c *Client) Apply(key string, body any) (replayed bool, err error) {
+	resp, err := c.call("/effects", http.Header{"Idempotency-Key": {key}}, body, nil)
+	if err != nil {
+		return false, err
+	}
+	return resp.Header.Get("Idempotent-Replayed") == "true", nil
+}
@@ -0,0 +1,3 @@
+module workqueue-worker
+
+go 1.24.3
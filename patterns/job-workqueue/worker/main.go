@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Exit codes, matched by the Job's podFailurePolicy.
+const (
+	exitDrained   = 0   // the shard is empty: this index is complete
+	exitRetryable = 1   // crashed or lost the queue: retry this index
+	exitConfig    = 2   // misconfigured: retrying can't help, fail the Job
+	exitSIGTERM   = 143 // stopped after finishing the current item
+)
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func getEnvInt(key string, fallback int) int {
+	if value, ok := os.LookupEnv(key); ok {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+		fmt.Printf("Invalid %s=%q, using default %d\n", key, value, fallback)
+	}
+	return fallback
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	if value, ok := os.LookupEnv(key); ok {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+		fmt.Printf("Invalid %s=%q, using default %t\n", key, value, fallback)
+	}
+	return fallback
+}
+
+func getEnvFloat(key string, fallback float64) float64 {
+	if value, ok := os.LookupEnv(key); ok {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+		fmt.Printf("Invalid %s=%q, using default %g\n", key, value, fallback)
+	}
+	return fallback
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if value, ok := os.LookupEnv(key); ok {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+		fmt.Printf("Invalid %s=%q, using default %s\n", key, value, fallback)
+	}
+	return fallback
+}
+
+type config struct {
+	shard, shards int
+	leaseTTL      time.Duration
+	pollInterval  time.Duration
+	workTime      time.Duration
+	failRate      float64
+	crashRate     float64
+	// exitWhenDrained is false when the worker runs in a Deployment, scaled
+	// by queue length (see patterns/queue-scaler): it waits for more work
+	// instead of exiting, which the Deployment would restart anyway.
+	exitWhenDrained bool
+}
+
+// process works on one leased item: the work, then the side effect, then the
+// ack; an error means the attempt failed and should be nacked. A heartbeat extends the lease meanwhile, so a slow item isn't handed
+// to a second worker. A crash anywhere in between is safe: the lease runs
+// out, the item is leased again, and the side effect, keyed by the item ID,
+// is replayed instead of applied twice.
+func process(c *Client, cfg config, it *Item) error {
+	var lost atomic.Bool
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		ticker := time.NewTicker(cfg.leaseTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := c.Extend(it, cfg.leaseTTL); errors.Is(err, errLeaseLost) {
+					lost.Store(true)
+					return
+				}
+			}
+		}
+	}()
+
+	// The work itself, simulated.
+	time.Sleep(cfg.workTime)
+	if rand.Float64() < cfg.failRate {
+		return fmt.Errorf("simulated failure")
+	}
+	if lost.Load() {
+		return errLeaseLost
+	}
+
+	var payload struct {
+		Customer    string `json:"customer"`
+		AmountCents int    `json:"amountCents"`
+	}
+	json.Unmarshal(it.Payload, &payload)
+	replayed, err := c.Apply(it.ID, map[string]any{"charge": payload.Customer, "amountCents": payload.AmountCents})
+	if err != nil {
+		return err
+	}
+	if replayed {
+		fmt.Printf("%s: side effect already applied by an earlier attempt, not repeating it\n", it.ID)
+	}
+
+	if rand.Float64() < cfg.crashRate {
+		// The worst moment to die: the effect happened, the queue doesn't
+		// know. The retry must not charge the customer twice.
+		fmt.Printf("%s: simulated crash after the side effect, before the ack\n", it.ID)
+		os.Exit(exitRetryable)
+	}
+	return c.Ack(it, fmt.Sprintf("charged %s %d cents", payload.Customer, payload.AmountCents))
+}
+
+func main() {
+	worker := getEnv("POD_NAME", "")
+	if worker == "" {
+		worker, _ = os.Hostname()
+	}
+	cfg := config{
+		shard:        getEnvInt("JOB_COMPLETION_INDEX", 0),
+		shards:       getEnvInt("SHARDS", 1),
+		leaseTTL:     getEnvDuration("LEASE_TTL", 30*time.Second),
+		pollInterval: getEnvDuration("POLL_INTERVAL", time.Second),
+		workTime:     getEnvDuration("WORK_TIME", 500*time.Millisecond),
+		failRate:     getEnvFloat("FAIL_RATE", 0),
+		crashRate:    getEnvFloat("CRASH_RATE", 0),
+
+		exitWhenDrained: getEnvBool("EXIT_WHEN_DRAINED", true),
+	}
+	// SHARDS must equal the Job's completions: a mismatch leaves items in
+	// shards nobody owns, and the Job "succeeds" with work left undone.
+	if cfg.shards < 1 || cfg.shard < 0 || cfg.shard >= cfg.shards {
+		fmt.Printf("JOB_COMPLETION_INDEX=%d is out of range for SHARDS=%d\n", cfg.shard, cfg.shards)
+		os.Exit(exitConfig)
+	}
+	if cfg.leaseTTL < 3*time.Second {
+		fmt.Printf("LEASE_TTL=%s is too short to heartbeat\n", cfg.leaseTTL)
+		os.Exit(exitConfig)
+	}
+	c := &Client{URL: getEnv("QUEUE_URL", "http://workqueue:8080"), Worker: worker, Timeout: getEnvDuration("QUEUE_TIMEOUT", time.Minute)}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	fmt.Printf("Worker %s: shard %d of %d\n", worker, cfg.shard, cfg.shards)
+	done := 0
+	for {
+		if ctx.Err() != nil {
+			fmt.Printf("SIGTERM: stopping after %d items; the rest of shard %d stays queued\n", done, cfg.shard)
+			os.Exit(exitSIGTERM)
+		}
+		it, drained, err := c.Lease(cfg.shard, cfg.shards, cfg.leaseTTL)
+		if err != nil {
+			fmt.Printf("Error leasing: %s\n", err)
+			os.Exit(exitRetryable)
+		}
+		if drained && cfg.exitWhenDrained {
+			fmt.Printf("Shard %d drained: %d items processed by this Pod\n", cfg.shard, done)
+			os.Exit(exitDrained)
+		}
+		if it == nil {
+			// Items of this shard are backing off, or leased by a previous
+			// Pod of this index whose lease hasn't run out yet. Or, with
+			// EXIT_WHEN_DRAINED=false, there is nothing left to do for now.
+			select {
+			case <-ctx.Done():
+			case <-time.After(cfg.pollInterval):
+			}
+			continue
+		}
+
+		switch err := process(c, cfg, it); {
+		case err == nil:
+			done++
+		case errors.Is(err, errLeaseLost):
+			fmt.Printf("%s: lease lost, leaving it to its new worker\n", it.ID)
+		default:
+			// The queue retries the item after a backoff, possibly in
+			// another Pod of this index.
+			fmt.Printf("%s: attempt %d failed: %s\n", it.ID, it.Attempts, err)
+			if err := c.Nack(it, err.Error()); err != nil && !errors.Is(err, errLeaseLost) {
+				fmt.Printf("Error nacking %s: %s\n", it.ID, err)
+				os.Exit(exitRetryable)
+			}
+		}
+	}
+}
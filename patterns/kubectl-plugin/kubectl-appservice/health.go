@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	webappv1 "mydomain.com/appservice/api/v1"
+	webappv2 "mydomain.com/appservice/api/v2"
+)
+
+// Health values. The first three are the operator's own, as reported for
+// each child in status.deployedResources; Suspended and Unknown only make
+// sense for the AppService as a whole.
+const (
+	HealthHealthy     = string(webappv2.HealthHealthy)
+	HealthProgressing = string(webappv2.HealthProgressing)
+	HealthDegraded    = string(webappv2.HealthDegraded)
+	HealthSuspended   = "Suspended"
+	HealthUnknown     = "Unknown"
+)
+
+// Assessment is one AppService's health, with the reason and message
+// behind it.
+type Assessment struct {
+	Health  string
+	Reason  string
+	Message string
+}
+
+// Assess sums an AppService up from its status, the way a person reading
+// "kubectl get appservice -o yaml" would. Nothing is computed that the
+// operator didn't already report: the plugin only reads.
+//
+// The order matters. A status written for an older generation says nothing
+// about the current spec, so it is checked before any condition.
+func Assess(app *webappv2.AppService) Assessment {
+	conditions := app.Status.Conditions
+	ready := meta.FindStatusCondition(conditions, webappv1.ConditionReady)
+	switch {
+	case app.Spec.Suspend:
+		return Assessment{HealthSuspended, "Suspended", "spec.suspend is set; the operator leaves the children alone"}
+	case app.Status.ObservedGeneration < app.Generation:
+		// Also what an AppService looks like when the operator isn't
+		// running at all.
+		return Assessment{HealthProgressing, "Reconciling",
+			fmt.Sprintf("the operator hasn't seen generation %d yet (status is for %d)", app.Generation, app.Status.ObservedGeneration)}
+	case meta.IsStatusConditionTrue(conditions, webappv1.ConditionStalled):
+		stalled := meta.FindStatusCondition(conditions, webappv1.ConditionStalled)
+		return Assessment{HealthDegraded, stalled.Reason, stalled.Message}
+	case ready == nil:
+		return Assessment{HealthUnknown, "NoStatus", "the operator hasn't reported a Ready condition"}
+	case ready.Status != metav1.ConditionTrue:
+		health := HealthDegraded
+		if ready.Reason == "RollingOut" {
+			health = HealthProgressing
+		}
+		return Assessment{health, ready.Reason, ready.Message}
+	}
+	// Ready covers the workload. A child the workload doesn't depend on,
+	// such as an Ingress, can still be unwell.
+	for _, child := range app.Status.DeployedResources {
+		if child.Health == webappv2.HealthDegraded {
+			return Assessment{HealthDegraded, "ChildDegraded", fmt.Sprintf("%s %s: %s", child.Kind, child.Name, child.Message)}
+		}
+	}
+	return Assessment{HealthHealthy, ready.Reason, ready.Message}
+}
+
+// readyReplicas is the READY column of kubectl get deployment: ready Pods
+// out of the ones the Deployment has. status.replicas follows an
+// autoscaler; spec.replicas doesn't.
+func readyReplicas(app *webappv2.AppService) string {
+	desired := app.Status.Replicas
+	if desired == 0 && app.Spec.Replicas != nil {
+		desired = *app.Spec.Replicas
+	}
+	return fmt.Sprintf("%d/%d", app.Status.ReadyReplicas, desired)
+}
+
+// image is the image the Pods run, which isn't always the spec's: after a
+// rollback, the last good one; with spec.pinImageDigest, the pinned one.
+func image(app *webappv2.AppService) string {
+	spec := app.Spec.Container.Image
+	if app.Spec.Container.Tag != "" {
+		spec += ":" + app.Spec.Container.Tag
+	}
+	switch {
+	case app.Status.FailedImage == spec && app.Status.LastGoodImage != "":
+		return app.Status.LastGoodImage
+	case app.Status.PinnedImage != "":
+		return app.Status.PinnedImage
+	}
+	return spec
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+
+	webappv2 "mydomain.com/appservice/api/v2"
+)
+
+// scheme knows the operator's own Go types, imported from its module: the
+// plugin and the operator can't disagree on the schema. Only the storage
+// version is needed; the API server converts.
+var scheme = runtime.NewScheme()
+
+func init() {
+	utilruntime.Must(webappv2.AddToScheme(scheme))
+}
+
+// NewCmdAppService is the root command. kubectl finds the binary as
+// kubectl-appservice on the PATH and runs it for "kubectl appservice ...",
+// passing the rest of the arguments as is.
+func NewCmdAppService(streams genericiooptions.IOStreams) *cobra.Command {
+	// The same kubeconfig flags as kubectl: --kubeconfig, --context, -n,
+	// --as, --token, --server, ...
+	configFlags := genericclioptions.NewConfigFlags(true)
+	cmd := &cobra.Command{
+		Use:   "appservice",
+		Short: "Inspect AppServices, the custom resources of the appservice operator",
+		Annotations: map[string]string{
+			// "kubectl appservice" in help and usage, not "appservice".
+			cobra.CommandDisplayNameAnnotation: "kubectl appservice",
+		},
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	configFlags.AddFlags(cmd.PersistentFlags())
+	cmd.AddCommand(NewCmdStatus(configFlags, streams))
+	return cmd
+}
+
+func main() {
+	// Flags registered on the global set by libraries would show up in
+	// every command's help.
+	pflag.CommandLine = pflag.NewFlagSet("kubectl-appservice", pflag.ExitOnError)
+
+	cmd := NewCmdAppService(genericiooptions.IOStreams{In: os.Stdin, Out: os.Stdout, ErrOut: os.Stderr})
+	if err := cmd.Execute(); err != nil {
+		if !errors.Is(err, errUnhealthy) {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		}
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	"k8s.io/cli-runtime/pkg/printers"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	webappv2 "mydomain.com/appservice/api/v2"
+)
+
+// errUnhealthy makes the plugin exit with status 1, with --exit-code,
+// after the output is printed.
+var errUnhealthy = errors.New("not all AppServices are healthy")
+
+const statusExample = `  # Health of the AppServices in the current namespace
+  kubectl appservice status
+
+  # In every namespace, with the message behind each health
+  kubectl appservice status -A -o wide
+
+  # One AppService: conditions, rollout and children
+  kubectl appservice status my-app -n demo
+
+  # In a script: wait until everything labelled team=web is healthy
+  until kubectl appservice status -l team=web --exit-code >/dev/null; do sleep 5; done`
+
+// StatusOptions follows kubectl's own commands: flags are bound to the
+// fields, Complete fills in what the flags imply, Validate checks them and
+// Run does the work.
+type StatusOptions struct {
+	configFlags *genericclioptions.ConfigFlags
+	printFlags  *genericclioptions.PrintFlags
+	genericiooptions.IOStreams
+
+	allNamespaces bool
+	selector      string
+	exitCode      bool
+
+	name      string
+	namespace string
+	client    client.Client
+	// printer is nil for the default and wide outputs, which are this
+	// plugin's own. -o json, yaml, name, jsonpath and go-template come from
+	// cli-runtime, as in kubectl.
+	printer printers.ResourcePrinter
+	wide    bool
+}
+
+func NewCmdStatus(configFlags *genericclioptions.ConfigFlags, streams genericiooptions.IOStreams) *cobra.Command {
+	o := &StatusOptions{
+		configFlags: configFlags,
+		printFlags:  genericclioptions.NewPrintFlags("").WithTypeSetter(scheme),
+		IOStreams:   streams,
+	}
+	cmd := &cobra.Command{
+		Use:   "status [NAME]",
+		Short: "Show the health of AppServices",
+		Long: `Show the health of AppServices, summed up from the status the operator reports:
+Healthy, Progressing, Degraded, Suspended or Unknown.
+
+Output: the default table, -o wide (adds the message and URL), or any of
+kubectl's -o formats.`,
+		Example: statusExample,
+		Args:    cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := o.Complete(args); err != nil {
+				return err
+			}
+			if err := o.Validate(); err != nil {
+				return err
+			}
+			return o.Run(cmd.Context())
+		},
+	}
+	cmd.Flags().BoolVarP(&o.allNamespaces, "all-namespaces", "A", false, "List AppServices in all namespaces")
+	cmd.Flags().StringVarP(&o.selector, "selector", "l", "", "Label selector to filter on, as in kubectl get")
+	cmd.Flags().BoolVar(&o.exitCode, "exit-code", false, "Exit with status 1 unless every AppService listed is Healthy")
+	o.printFlags.AddFlags(cmd)
+	return cmd
+}
+
+// Complete turns the flags into a namespace, a client and a printer. The
+// kubeconfig flags (--context, -n, --as, ...) were registered on the root
+// command by ConfigFlags, which also builds the client config from them.
+func (o *StatusOptions) Complete(args []string) error {
+	if len(args) == 1 {
+		o.name = args[0]
+	}
+	var err error
+	if o.namespace, _, err = o.configFlags.ToRawKubeConfigLoader().Namespace(); err != nil {
+		return err
+	}
+	restConfig, err := o.configFlags.ToRESTConfig()
+	if err != nil {
+		return err
+	}
+	// ConfigFlags' mapper caches discovery on disk, in ~/.kube/cache,
+	// shared with kubectl.
+	mapper, err := o.configFlags.ToRESTMapper()
+	if err != nil {
+		return err
+	}
+	if o.client, err = client.New(restConfig, client.Options{Scheme: scheme, Mapper: mapper}); err != nil {
+		return err
+	}
+
+	switch format := *o.printFlags.OutputFormat; format {
+	case "":
+	case "wide":
+		o.wide = true
+	default:
+		if o.printer, err = o.printFlags.ToPrinter(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *StatusOptions) Validate() error {
+	if o.name != "" && o.allNamespaces {
+		return fmt.Errorf("a NAME can't be combined with --all-namespaces")
+	}
+	if o.name != "" && o.selector != "" {
+		return fmt.Errorf("a NAME can't be combined with --selector")
+	}
+	if _, err := labels.Parse(o.selector); err != nil {
+		return fmt.Errorf("invalid selector %q: %w", o.selector, err)
+	}
+	return nil
+}
+
+func (o *StatusOptions) Run(ctx context.Context) error {
+	if o.name != "" {
+		app := &webappv2.AppService{}
+		if err := o.client.Get(ctx, client.ObjectKey{Namespace: o.namespace, Name: o.name}, app); err != nil {
+			return explain(err)
+		}
+		switch {
+		case o.printer != nil:
+			if err := o.printer.PrintObj(app, o.Out); err != nil {
+				return err
+			}
+		default:
+			if err := describe(o.Out, app); err != nil {
+				return err
+			}
+		}
+		if o.exitCode && Assess(app).Health != HealthHealthy {
+			return errUnhealthy
+		}
+		return nil
+	}
+
+	list := &webappv2.AppServiceList{}
+	selector, _ := labels.Parse(o.selector)
+	opts := []client.ListOption{client.MatchingLabelsSelector{Selector: selector}}
+	if !o.allNamespaces {
+		opts = append(opts, client.InNamespace(o.namespace))
+	}
+	if err := o.client.List(ctx, list, opts...); err != nil {
+		return explain(err)
+	}
+	if o.printer != nil {
+		if err := o.printer.PrintObj(list, o.Out); err != nil {
+			return err
+		}
+	} else if len(list.Items) == 0 {
+		if o.allNamespaces {
+			fmt.Fprintln(o.ErrOut, "No resources found")
+		} else {
+			fmt.Fprintf(o.ErrOut, "No resources found in %s namespace.\n", o.namespace)
+		}
+	} else if err := o.printTable(list.Items); err != nil {
+		return err
+	}
+	if o.exitCode {
+		for i := range list.Items {
+			if Assess(&list.Items[i]).Health != HealthHealthy {
+				return errUnhealthy
+			}
+		}
+	}
+	return nil
+}
+
+func (o *StatusOptions) printTable(apps []webappv2.AppService) error {
+	w := printers.GetNewTabWriter(o.Out)
+	header := []string{"NAME", "HEALTH", "READY", "IMAGE", "REASON", "AGE"}
+	if o.allNamespaces {
+		header = append([]string{"NAMESPACE"}, header...)
+	}
+	if o.wide {
+		header = append(header, "URL", "MESSAGE")
+	}
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for i := range apps {
+		app := &apps[i]
+		a := Assess(app)
+		row := []string{app.Name, a.Health, readyReplicas(app), image(app), a.Reason, age(app.CreationTimestamp)}
+		if o.allNamespaces {
+			row = append([]string{app.Namespace}, row...)
+		}
+		if o.wide {
+			row = append(row, orNone(app.Status.URL), orNone(a.Message))
+		}
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
+
+// describe prints one AppService the way kubectl describe would, limited
+// to what says whether it's healthy and, if not, why.
+func describe(out io.Writer, app *webappv2.AppService) error {
+	a := Assess(app)
+	w := printers.GetNewTabWriter(out)
+	fmt.Fprintf(w, "Name:\t%s\n", app.Name)
+	fmt.Fprintf(w, "Namespace:\t%s\n", app.Namespace)
+	fmt.Fprintf(w, "Health:\t%s (%s)\n", a.Health, a.Reason)
+	if a.Message != "" {
+		fmt.Fprintf(w, "Message:\t%s\n", a.Message)
+	}
+	fmt.Fprintf(w, "Image:\t%s\n", image(app))
+	if app.Status.FailedImage != "" {
+		fmt.Fprintf(w, "Failed image:\t%s (rolled back)\n", app.Status.FailedImage)
+	}
+	fmt.Fprintf(w, "Replicas:\t%s ready, %d available\n", readyReplicas(app), app.Status.AvailableReplicas)
+	if app.Status.URL != "" {
+		fmt.Fprintf(w, "URL:\t%s\n", app.Status.URL)
+	}
+	if c := app.Status.Canary; c != nil {
+		steps := 0
+		if s := app.Spec.Strategy; s != nil && s.Canary != nil {
+			steps = len(s.Canary.Steps)
+		}
+		fmt.Fprintf(w, "Canary:\tstep %d of %d, weight %d%%, revision %s\n", c.Step+1, max(steps, int(c.Step)+1), c.Weight, c.Revision)
+	}
+	if bg := app.Status.BlueGreen; bg != nil {
+		fmt.Fprintf(w, "Blue/green:\tactive %s, preview revision %s\n", orNone(string(bg.ActiveColor)), orNone(bg.PreviewRevision))
+	}
+	if m := app.Status.Migration; m != nil {
+		state := "running"
+		if m.Failed {
+			state = "failed"
+		}
+		fmt.Fprintf(w, "Migration:\tJob %s to %s, %s\n", m.Job, m.Image, state)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	// Each table gets its own writer, so its columns don't line up with
+	// the fields above.
+	w = printers.GetNewTabWriter(out)
+	fmt.Fprintln(w, "Conditions:")
+	fmt.Fprintln(w, "  TYPE\tSTATUS\tREASON\tAGE\tMESSAGE")
+	for _, c := range app.Status.Conditions {
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\n", c.Type, c.Status, c.Reason, age(c.LastTransitionTime), c.Message)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if len(app.Status.DeployedResources) > 0 {
+		w = printers.GetNewTabWriter(out)
+		fmt.Fprintln(w, "Resources:")
+		fmt.Fprintln(w, "  KIND\tNAME\tHEALTH\tMESSAGE")
+		for _, r := range app.Status.DeployedResources {
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", r.Kind, r.Name, r.Health, r.Message)
+		}
+	}
+	return w.Flush()
+}
+
+// explain turns the error a missing CRD gives into one that says so.
+func explain(err error) error {
+	switch {
+	case meta.IsNoMatchError(err):
+		return fmt.Errorf("the server doesn't serve %s AppServices: is the AppService CRD installed? (see patterns/controller-operator)", webappv2.GroupVersion)
+	case apierrors.IsForbidden(err):
+		return fmt.Errorf("%w\nThe plugin runs with your kubeconfig's credentials; they need get and list on appservices.%s", err, webappv2.GroupVersion.Group)
+	}
+	return err
+}
+
+func age(t metav1.Time) string {
+	if t.IsZero() {
+		return "<unknown>"
+	}
+	return duration.HumanDuration(time.Since(t.Time))
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "<none>"
+	}
+	return s
+}
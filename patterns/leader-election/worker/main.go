@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if value, ok := os.LookupEnv(key); ok {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+		fmt.Printf("Invalid %s=%q, using default %s\n", key, value, fallback)
+	}
+	return fallback
+}
+
+// inClusterClient returns a client authenticated as the Pod's
+// ServiceAccount; see manifests/rbac.yaml for what it may do.
+func inClusterClient() (kubernetes.Interface, error) {
+	cfg, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(cfg)
+}
+
+func main() {
+	identity := getEnv("POD_NAME", "")
+	if identity == "" {
+		identity, _ = os.Hostname()
+	}
+	namespace := getEnv("POD_NAMESPACE", "default")
+	listenAddr := getEnv("LISTEN_ADDR", ":8080")
+
+	client, err := inClusterClient()
+	if err != nil {
+		fmt.Printf("Error creating Kubernetes client: %s\n", err)
+		os.Exit(1)
+	}
+
+	worker := &Worker{
+		Client:    client,
+		Namespace: namespace,
+		ConfigMap: getEnv("REPORT_CONFIGMAP", "leader-election-demo-report"),
+		Identity:  identity,
+		Interval:  getEnvDuration("WORK_INTERVAL", 10*time.Second),
+		Duration:  getEnvDuration("WORK_DURATION", 3*time.Second),
+	}
+
+	// The identity must be unique per replica: two replicas with the same
+	// identity both believe they hold the lease. The Pod name is.
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta:  metav1.ObjectMeta{Name: getEnv("LEASE_NAME", "leader-election-demo"), Namespace: namespace},
+		Client:     client.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{Identity: identity},
+	}
+	// Reports unhealthy if this replica is leading but hasn't renewed for
+	// longer than the lease plus this slack, e.g. when the renew loop is
+	// stuck. The kubelet then restarts it, and the lease expires for others.
+	watchdog := leaderelection.NewLeaderHealthzAdaptor(20 * time.Second)
+
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:          lock,
+		LeaseDuration: getEnvDuration("LEASE_DURATION", 15*time.Second),
+		RenewDeadline: getEnvDuration("RENEW_DEADLINE", 10*time.Second),
+		RetryPeriod:   getEnvDuration("RETRY_PERIOD", 2*time.Second),
+		// On shutdown, clear the holder so a follower takes over within one
+		// RetryPeriod instead of waiting out LeaseDuration.
+		ReleaseOnCancel: true,
+		WatchDog:        watchdog,
+		Name:            lock.LeaseMeta.Name,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				isLeader.Set(1)
+				term := 0
+				if record, _, err := lock.Get(ctx); err == nil {
+					term = record.LeaderTransitions
+				}
+				leaderTerm.Set(float64(term))
+				fmt.Printf("Became leader (term %d)\n", term)
+				worker.Lead(ctx, term)
+			},
+			OnStoppedLeading: func() {
+				isLeader.Set(0)
+				leaderTerm.Set(0)
+			},
+			OnNewLeader: func(leader string) {
+				leaderChanges.Inc()
+				if leader != identity {
+					fmt.Printf("Following %s\n", leader)
+				}
+			},
+		},
+	})
+	if err != nil {
+		fmt.Printf("Error configuring leader election: %s\n", err)
+		os.Exit(1)
+	}
+	watchdog.SetLeaderElection(elector)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/leader", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(worker.status(elector.GetLeader(), elector.IsLeader()))
+	})
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		if err := watchdog.Check(r); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+	go func() {
+		if err := http.ListenAndServe(listenAddr, mux); err != nil {
+			fmt.Printf("Error starting server: %s\n", err)
+		}
+	}()
+
+	// SIGTERM hands leadership over in order: finish the current run, then
+	// release the lease. Cancelling the election first would let the next
+	// leader start while this one is still writing.
+	sigCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+	electionCtx, cancelElection := context.WithCancel(context.Background())
+	go func() {
+		<-sigCtx.Done()
+		fmt.Println("Shutting down: finishing the current run, then releasing the lease")
+		worker.Stop(getEnvDuration("SHUTDOWN_TIMEOUT", 20*time.Second))
+		cancelElection()
+	}()
+
+	fmt.Printf("%s campaigning for lease %s/%s, status on %s/leader\n", identity, namespace, lock.LeaseMeta.Name, listenAddr)
+	elector.Run(electionCtx)
+
+	// Run returns on shutdown, or when a renewal failed. In the second case
+	// another replica may already be leading: exit rather than risk two
+	// leaders, and let the kubelet restart us as a follower.
+	if sigCtx.Err() == nil {
+		fmt.Println("Lost the lease, exiting")
+		os.Exit(1)
+	}
+	fmt.Println("Lease released")
+}
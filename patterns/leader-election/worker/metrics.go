@@ -0,0 +1,36 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Every replica serves these, leader or not: "which replica leads" is a
+// query over all of them (sum(worker_is_leader) should always be 1).
+var (
+	isLeader = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "worker_is_leader",
+		Help: "1 while this replica holds the lease.",
+	})
+
+	leaderTerm = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "worker_leader_term",
+		Help: "The lease's transition count when this replica acquired it (0 while not leading).",
+	})
+
+	leaderChanges = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "worker_leader_changes_total",
+		Help: "Leader changes observed by this replica, including its own.",
+	})
+
+	runsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "worker_runs_total",
+		Help: "Work runs by result (ok, error, fenced, aborted).",
+	}, []string{"result"})
+
+	runDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "worker_run_duration_seconds",
+		Help:    "Duration of work runs, including the report write.",
+		Buckets: []float64{.5, 1, 2, 5, 10, 30, 60},
+	})
+)
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+// Worker is the job only the leader may do: every Interval it runs a batch
+// (simulated by sleeping for Duration) and records it in a report ConfigMap.
+type Worker struct {
+	Client    kubernetes.Interface
+	Namespace string
+	ConfigMap string
+	Identity  string
+	Interval  time.Duration
+	Duration  time.Duration
+
+	mu           sync.Mutex
+	stopped      bool
+	stop         chan struct{}
+	running      sync.WaitGroup
+	term         int
+	leadingSince time.Time
+	lastRun      time.Time
+}
+
+// errFenced means a leader with a newer term has already written the report:
+// this replica's lease expired without it noticing, and its write must not
+// land on top of the new leader's.
+var errFenced = errors.New("fenced off by a newer term")
+
+// Lead runs the work loop until ctx is cancelled (the lease was lost) or
+// Stop is called (shutdown). term is the lease's transition count when this
+// replica acquired it, used as a fencing token.
+func (w *Worker) Lead(ctx context.Context, term int) {
+	w.mu.Lock()
+	if w.stopped {
+		w.mu.Unlock()
+		return
+	}
+	w.running.Add(1)
+	w.term, w.leadingSince = term, time.Now()
+	stop := w.stopping()
+	w.mu.Unlock()
+	defer w.running.Done()
+
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+	for {
+		w.runOnce(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop lets the run in progress finish and waits for the loop to return, or
+// for timeout. Only then is it safe to release the lease: the next leader
+// must not start while this one is still writing.
+func (w *Worker) Stop(timeout time.Duration) {
+	w.mu.Lock()
+	if !w.stopped {
+		w.stopped = true
+		close(w.stopping())
+	}
+	w.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		w.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		fmt.Printf("Run still in progress after %s, releasing anyway\n", timeout)
+	}
+}
+
+// stopping returns the channel Stop closes. The caller holds w.mu.
+func (w *Worker) stopping() chan struct{} {
+	if w.stop == nil {
+		w.stop = make(chan struct{})
+	}
+	return w.stop
+}
+
+func (w *Worker) runOnce(ctx context.Context) {
+	start := time.Now()
+	result := "ok"
+	defer func() {
+		runsTotal.WithLabelValues(result).Inc()
+		runDuration.Observe(time.Since(start).Seconds())
+	}()
+
+	fmt.Printf("Leader %s (term %d): starting run\n", w.Identity, w.term)
+	// The batch itself. Losing the lease aborts it; SIGTERM doesn't, because
+	// shutdown waits for it in Stop.
+	select {
+	case <-ctx.Done():
+		result = "aborted"
+		fmt.Println("Lease lost mid-run, aborting")
+		return
+	case <-time.After(w.Duration):
+	}
+
+	if err := w.report(ctx); err != nil {
+		result = "error"
+		if errors.Is(err, errFenced) {
+			result = "fenced"
+		}
+		fmt.Printf("Error writing report: %s\n", err)
+		return
+	}
+	w.mu.Lock()
+	w.lastRun = time.Now()
+	w.mu.Unlock()
+	fmt.Printf("Leader %s (term %d): run done in %s\n", w.Identity, w.term, time.Since(start).Round(time.Millisecond))
+}
+
+// report records the run in the ConfigMap, unless a newer term has written
+// it already. The check and the write are one optimistic update: a
+// concurrent writer makes it fail with a conflict, and it is retried from a
+// fresh read.
+func (w *Worker) report(ctx context.Context) error {
+	cms := w.Client.CoreV1().ConfigMaps(w.Namespace)
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cm, err := cms.Get(ctx, w.ConfigMap, metav1.GetOptions{})
+		exists := err == nil
+		if apierrors.IsNotFound(err) {
+			cm = &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: w.ConfigMap, Namespace: w.Namespace}}
+		} else if err != nil {
+			return err
+		}
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		if stored, _ := strconv.Atoi(cm.Data["term"]); stored > w.term {
+			return fmt.Errorf("%w: report is at term %d, this replica at %d", errFenced, stored, w.term)
+		}
+		runs, _ := strconv.Atoi(cm.Data["runs"])
+		cm.Data["runs"] = strconv.Itoa(runs + 1)
+		cm.Data["term"] = strconv.Itoa(w.term)
+		cm.Data["lastRunBy"] = w.Identity
+		cm.Data["lastRunAt"] = time.Now().UTC().Format(time.RFC3339)
+
+		if !exists {
+			_, err = cms.Create(ctx, cm, metav1.CreateOptions{})
+			if apierrors.IsAlreadyExists(err) {
+				// Someone created it since our Get: retry as an update.
+				return apierrors.NewConflict(corev1.Resource("configmaps"), w.ConfigMap, err)
+			}
+			return err
+		}
+		_, err = cms.Update(ctx, cm, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// Status is what /leader reports.
+type Status struct {
+	Identity     string     `json:"identity"`
+	Leader       string     `json:"leader"`
+	IsLeader     bool       `json:"isLeader"`
+	Term         int        `json:"term,omitempty"`
+	LeadingSince *time.Time `json:"leadingSince,omitempty"`
+	LastRun      *time.Time `json:"lastRun,omitempty"`
+}
+
+func (w *Worker) status(leader string, isLeader bool) Status {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	s := Status{Identity: w.Identity, Leader: leader, IsLeader: isLeader}
+	if isLeader {
+		since, last := w.leadingSince, w.lastRun
+		s.Term = w.term
+		s.LeadingSince = &since
+		if !last.IsZero() {
+			s.LastRun = &last
+		}
+	}
+	return s
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+const (
+	// ttlLabel opts a Namespace in: "ttl.mydomain.com/expire-after=36h". It
+	// is a label, not an annotation, so the controller can watch only the
+	// Namespaces that carry it (see main.go) and people can list them:
+	// kubectl get ns -l ttl.mydomain.com/expire-after -L ttl.mydomain.com/expire-after
+	ttlLabel = "ttl.mydomain.com/expire-after"
+
+	// The controller's own bookkeeping, as annotations on the Namespace.
+	// expiresAtAnnotation is for people (kubectl describe ns); it also
+	// tells the controller that the TTL changed, which resets the warnings.
+	// lastWarningAnnotation is the shortest warning sent so far, so a
+	// restart doesn't send them all again.
+	expiresAtAnnotation   = "ttl.mydomain.com/expires-at"
+	lastWarningAnnotation = "ttl.mydomain.com/last-warning"
+)
+
+// NamespaceReconciler deletes Namespaces once their TTL, counted from their
+// creation, has elapsed, and warns with Events beforehand.
+type NamespaceReconciler struct {
+	client.Client
+	Recorder record.EventRecorder
+	// Warnings are the lead times of the warnings, longest first.
+	Warnings []time.Duration
+	// Protected Namespaces are never deleted, label or not.
+	Protected map[string]bool
+	// DryRun sends the warnings but, when a TTL elapses, only asks the API
+	// server whether the deletion would succeed.
+	DryRun bool
+	// Now is time.Now, or a fake clock to try a schedule without waiting.
+	Now func() time.Time
+}
+
+func (r *NamespaceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+
+	var ns corev1.Namespace
+	if err := r.Get(ctx, req.NamespacedName, &ns); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !ns.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+	value, ok := ns.Labels[ttlLabel]
+	if !ok {
+		return ctrl.Result{}, nil
+	}
+	if r.Protected[ns.Name] {
+		r.Recorder.Eventf(&ns, corev1.EventTypeWarning, "Protected", "Namespace %s is protected; ignoring %s=%s", ns.Name, ttlLabel, value)
+		return ctrl.Result{}, nil
+	}
+	ttl, err := parseTTL(value)
+	if err != nil {
+		// Not retried: the label has to change, and that's an update event.
+		r.Recorder.Event(&ns, corev1.EventTypeWarning, "InvalidTTL", err.Error())
+		return ctrl.Result{}, nil
+	}
+
+	expiresAt := ns.CreationTimestamp.Add(ttl)
+	left := expiresAt.Sub(r.Now())
+	if left <= 0 {
+		return ctrl.Result{}, r.expire(ctx, &ns, ttl)
+	}
+
+	// Warnings sent for another expiry time don't count: the TTL was
+	// extended, or shortened, since.
+	stamp := expiresAt.UTC().Format(time.RFC3339)
+	var warned time.Duration
+	if ns.Annotations[expiresAtAnnotation] == stamp {
+		warned, _ = time.ParseDuration(ns.Annotations[lastWarningAnnotation])
+	}
+	lead, wait := nextStep(left, r.Warnings, warned)
+
+	if lead != 0 || ns.Annotations[expiresAtAnnotation] != stamp {
+		patch := client.MergeFrom(ns.DeepCopy())
+		metav1.SetMetaDataAnnotation(&ns.ObjectMeta, expiresAtAnnotation, stamp)
+		if lead != 0 {
+			metav1.SetMetaDataAnnotation(&ns.ObjectMeta, lastWarningAnnotation, lead.String())
+		} else {
+			delete(ns.Annotations, lastWarningAnnotation)
+		}
+		if err := r.Patch(ctx, &ns, patch); err != nil {
+			return ctrl.Result{}, err
+		}
+		// The Event goes out after the annotation: a failed patch retries
+		// both, rather than repeating the Event.
+		if lead != 0 {
+			r.Recorder.Eventf(&ns, corev1.EventTypeWarning, "ExpiringSoon",
+				"Namespace %s will be deleted in %s, at %s (%s=%s). Change or remove the label to keep it.",
+				ns.Name, left.Round(time.Second), stamp, ttlLabel, value)
+			warningsTotal.Inc()
+			log.Info("Warned", "namespace", ns.Name, "left", left.Round(time.Second))
+		}
+	}
+	return ctrl.Result{RequeueAfter: wait}, nil
+}
+
+// expire deletes the Namespace: everything in it goes with it. In dry-run
+// mode the API server runs the deletion through authorization and
+// admission, and stops there.
+func (r *NamespaceReconciler) expire(ctx context.Context, ns *corev1.Namespace, ttl time.Duration) error {
+	log := logf.FromContext(ctx)
+	// Only the Namespace we looked at: if it was deleted and re-created
+	// under the same name since, the UID differs and the delete fails.
+	opts := []client.DeleteOption{client.Preconditions{UID: &ns.UID}}
+	if r.DryRun {
+		opts = append(opts, client.DryRunAll)
+	}
+	if err := r.Delete(ctx, ns, opts...); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		r.Recorder.Eventf(ns, corev1.EventTypeWarning, "DeleteFailed", "Deleting expired Namespace %s: %v", ns.Name, err)
+		return err
+	}
+	if r.DryRun {
+		deletionsTotal.WithLabelValues("dry-run").Inc()
+		r.Recorder.Eventf(ns, corev1.EventTypeNormal, "WouldDelete", "Dry run: Namespace %s expired (TTL %s); it would be deleted", ns.Name, ttl)
+		log.Info("Would delete", "namespace", ns.Name, "ttl", ttl)
+		return nil
+	}
+	deletionsTotal.WithLabelValues("deleted").Inc()
+	r.Recorder.Eventf(ns, corev1.EventTypeNormal, "Deleted", "Namespace %s expired (TTL %s) and was deleted", ns.Name, ttl)
+	log.Info("Deleted", "namespace", ns.Name, "ttl", ttl)
+	return nil
+}
+
+// SetupWithManager watches Namespaces. The cache only holds those with the
+// TTL label (see main.go), so a Namespace that loses it looks deleted, and
+// one that gains it looks created. Updates count when labels change; the
+// controller's own annotation patches don't trigger a reconcile.
+func (r *NamespaceReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&corev1.Namespace{}, builder.WithPredicates(predicate.LabelChangedPredicate{})).
+		Named("namespace-ttl").
+		Complete(r)
+}
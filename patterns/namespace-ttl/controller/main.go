@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+)
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	if value, ok := os.LookupEnv(key); ok {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+		fmt.Printf("Invalid %s=%q, using default %t\n", key, value, fallback)
+	}
+	return fallback
+}
+
+func main() {
+	ctrl.SetLogger(zap.New())
+
+	warnings, err := parseWarnings(getEnv("WARN_BEFORE", "1h,10m"))
+	if err != nil {
+		fmt.Printf("Invalid WARN_BEFORE: %s\n", err)
+		os.Exit(1)
+	}
+	protected := map[string]bool{}
+	for _, name := range strings.Split(getEnv("PROTECTED_NAMESPACES", "default,kube-system,kube-public,kube-node-lease"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			protected[name] = true
+		}
+	}
+	// Never its own: set from the downward API in manifests/deployment.yaml.
+	if own := os.Getenv("POD_NAMESPACE"); own != "" {
+		protected[own] = true
+	}
+	dryRun := getEnvBool("DRY_RUN", false)
+
+	// Only Namespaces with the TTL label are watched and cached. The
+	// selector goes to the API server with the list and watch, so the
+	// others never leave it.
+	hasTTL, err := labels.NewRequirement(ttlLabel, selection.Exists, nil)
+	if err != nil {
+		fmt.Printf("Error building selector: %s\n", err)
+		os.Exit(1)
+	}
+
+	// Authenticates as the Pod's ServiceAccount, or the local kubeconfig for
+	// go run .; see manifests/rbac.yaml for what it may do.
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+		Metrics:                metricsserver.Options{BindAddress: getEnv("METRICS_ADDR", ":8080")},
+		HealthProbeBindAddress: getEnv("PROBE_ADDR", ":8081"),
+		Cache: cache.Options{ByObject: map[client.Object]cache.ByObject{
+			&corev1.Namespace{}: {Label: labels.NewSelector().Add(*hasTTL)},
+		}},
+		LeaderElection:          getEnvBool("LEADER_ELECT", true),
+		LeaderElectionID:        "namespace-ttl.ttl.mydomain.com",
+		LeaderElectionNamespace: getEnv("LEADER_ELECTION_NAMESPACE", ""),
+	})
+	if err != nil {
+		fmt.Printf("Error creating manager: %s\n", err)
+		os.Exit(1)
+	}
+
+	if err := (&NamespaceReconciler{
+		Client:    mgr.GetClient(),
+		Recorder:  mgr.GetEventRecorderFor("namespace-ttl"),
+		Warnings:  warnings,
+		Protected: protected,
+		DryRun:    dryRun,
+		Now:       time.Now,
+	}).SetupWithManager(mgr); err != nil {
+		fmt.Printf("Error setting up controller: %s\n", err)
+		os.Exit(1)
+	}
+	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
+		fmt.Printf("Error adding health check: %s\n", err)
+		os.Exit(1)
+	}
+	if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
+		fmt.Printf("Error adding ready check: %s\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Expiring Namespaces labeled %s; warnings %v before; dry run %t\n", ttlLabel, warnings, dryRun)
+	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+		fmt.Printf("Error running manager: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("Shutting down...")
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Metrics go to controller-runtime's registry, which the Manager serves on
+// its /metrics next to the controller_runtime_* and workqueue_* metrics.
+var (
+	warningsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "namespace_ttl_warnings_total",
+		Help: "ExpiringSoon warnings sent.",
+	})
+
+	deletionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "namespace_ttl_deletions_total",
+		Help: "Expired Namespaces, by mode (deleted, dry-run).",
+	}, []string{"mode"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(warningsTotal, deletionsTotal)
+}
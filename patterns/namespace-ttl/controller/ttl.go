@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseTTL reads the label's value. Label values allow only [A-Za-z0-9_.-],
+// which rules out "1h30m" with spaces but not Go durations, so the value is
+// a Go duration ("90m", "36h"), or a number of days ("7d"), which Go
+// durations lack.
+func parseTTL(value string) (time.Duration, error) {
+	var ttl time.Duration
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid TTL %q: want a Go duration or a number of days, e.g. 36h or 7d", value)
+		}
+		ttl = time.Duration(n) * 24 * time.Hour
+	} else {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return 0, fmt.Errorf("invalid TTL %q: want a Go duration or a number of days, e.g. 36h or 7d", value)
+		}
+		ttl = d
+	}
+	if ttl <= 0 {
+		return 0, fmt.Errorf("invalid TTL %q: must be positive", value)
+	}
+	return ttl, nil
+}
+
+// parseWarnings reads WARN_BEFORE, e.g. "1h,10m", into lead times sorted
+// from the longest to the shortest.
+func parseWarnings(value string) ([]time.Duration, error) {
+	var leads []time.Duration
+	for _, s := range strings.Split(value, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid warning lead time %q", s)
+		}
+		leads = append(leads, d)
+	}
+	slices.Sort(leads)
+	slices.Reverse(leads)
+	return slices.Compact(leads), nil
+}
+
+// nextStep says what to do for a Namespace with this much time left, given
+// the shortest warning already sent (0 if none): send the warning for lead
+// (0 if none is due), and come back after wait.
+//
+// With warnings at 1h and 10m, and 3h left: nothing now, come back in 2h.
+// With 45m left and no warning sent yet: the 1h warning, back in 35m. A
+// Namespace created with less TTL than the first lead time gets only the
+// shortest warning that still applies.
+func nextStep(left time.Duration, leads []time.Duration, warned time.Duration) (lead, wait time.Duration) {
+	for _, l := range leads {
+		if left <= l && (warned == 0 || l < warned) {
+			lead = l
+		}
+	}
+	// The next warning is the longest one not sent yet that is still ahead.
+	wait = left
+	for _, l := range leads {
+		if l < left && (warned == 0 || l < warned) {
+			wait = left - l
+			break
+		}
+	}
+	return lead, wait
+}
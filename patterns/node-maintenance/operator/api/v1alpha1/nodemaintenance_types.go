@@ -0,0 +1,128 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NodeMaintenanceSpec names the node to take out of service. Creating the
+// NodeMaintenance cordons and drains it; deleting it uncordons it.
+type NodeMaintenanceSpec struct {
+	// NodeName is the node to cordon and drain. It can't be changed: to move
+	// maintenance to another node, delete this one and create another.
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=253
+	// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="nodeName is immutable"
+	// +required
+	NodeName string `json:"nodeName"`
+
+	// Reason is why the node is in maintenance, e.g. "kernel upgrade". It is
+	// shown by kubectl get and copied into the Events.
+	// +kubebuilder:validation:MaxLength=256
+	// +optional
+	Reason string `json:"reason,omitempty"`
+}
+
+// Phase is where a maintenance is: Pending until the node is cordoned,
+// Draining while Pods remain, Drained when the node is empty, Failed when
+// it can't proceed (no such node, or another NodeMaintenance has it).
+// +kubebuilder:validation:Enum=Pending;Draining;Drained;Failed
+type Phase string
+
+const (
+	PhasePending  Phase = "Pending"
+	PhaseDraining Phase = "Draining"
+	PhaseDrained  Phase = "Drained"
+	PhaseFailed   Phase = "Failed"
+)
+
+// ConditionDrained is True once no Pod that must be evicted is left on the
+// node. While False, its reason says what is in the way: Draining,
+// EvictionBlocked (a PodDisruptionBudget), NodeNotFound or Conflict.
+const ConditionDrained = "Drained"
+
+// BlockedPod is a Pod whose last eviction was refused.
+type BlockedPod struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// Message is the API server's answer, e.g. "Cannot evict pod as it
+	// would violate the pod's disruption budget."
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// NodeMaintenanceStatus reports the drain's progress.
+type NodeMaintenanceStatus struct {
+	// Phase is a one-word summary; the Drained condition has the details.
+	// +optional
+	Phase Phase `json:"phase,omitempty"`
+
+	// TotalPods is the number of Pods the drain has to evict: all Pods on
+	// the node except DaemonSet Pods, static Pods and finished Pods.
+	// +optional
+	TotalPods int32 `json:"totalPods,omitempty"`
+
+	// EvictedPods is how many of them are gone.
+	// +optional
+	EvictedPods int32 `json:"evictedPods,omitempty"`
+
+	// BlockedPods are the Pods whose eviction was refused on the last
+	// attempt, usually by a PodDisruptionBudget. At most 10 are listed.
+	// +kubebuilder:validation:MaxItems=10
+	// +listType=atomic
+	// +optional
+	BlockedPods []BlockedPod `json:"blockedPods,omitempty"`
+
+	// DrainStartTime is when the node was cordoned.
+	// +optional
+	DrainStartTime *metav1.Time `json:"drainStartTime,omitempty"`
+
+	// DrainCompletionTime is when the last Pod left.
+	// +optional
+	DrainCompletionTime *metav1.Time `json:"drainCompletionTime,omitempty"`
+
+	// Conditions hold the Drained condition.
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster,shortName=nm
+// +kubebuilder:printcolumn:name="Node",type=string,JSONPath=`.spec.nodeName`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Evicted",type=integer,JSONPath=`.status.evictedPods`
+// +kubebuilder:printcolumn:name="Total",type=integer,JSONPath=`.status.totalPods`
+// +kubebuilder:printcolumn:name="Reason",type=string,JSONPath=`.spec.reason`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// NodeMaintenance is the Schema for the nodemaintenances API
+type NodeMaintenance struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// metadata is a standard object metadata
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitzero"`
+
+	// spec defines the desired state of NodeMaintenance
+	// +required
+	Spec NodeMaintenanceSpec `json:"spec"`
+
+	// status defines the observed state of NodeMaintenance
+	// +optional
+	Status NodeMaintenanceStatus `json:"status,omitzero"`
+}
+
+// +kubebuilder:object:root=true
+
+// NodeMaintenanceList contains a list of NodeMaintenance
+type NodeMaintenanceList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitzero"`
+	Items           []NodeMaintenance `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NodeMaintenance{}, &NodeMaintenanceList{})
+}
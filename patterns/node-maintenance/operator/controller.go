@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	maintenancev1alpha1 "node-maintenance-operator/api/v1alpha1"
+)
+
+const (
+	// uncordonFinalizer keeps a NodeMaintenance in Terminating until its
+	// node is uncordoned: without it, deleting the object would leave the
+	// node cordoned forever.
+	uncordonFinalizer = "maintenance.mydomain.com/uncordon"
+
+	// cordonedByAnnotation on a Node names the NodeMaintenance that cordoned
+	// it. Only that one uncordons it; a node someone cordoned by hand stays
+	// cordoned, and a second NodeMaintenance for the node is a Conflict.
+	cordonedByAnnotation = "maintenance.mydomain.com/cordoned-by"
+
+	// nodeNameIndex indexes NodeMaintenances by spec.nodeName, to map a
+	// Node event to the NodeMaintenances for it.
+	nodeNameIndex = "spec.nodeName"
+)
+
+// NodeMaintenanceReconciler cordons the node of each NodeMaintenance, evicts
+// its Pods through the Eviction API, which honors PodDisruptionBudgets, and
+// uncordons it when the NodeMaintenance is deleted.
+type NodeMaintenanceReconciler struct {
+	client.Client
+	// APIReader reads Pods from the API server, not the cache: the
+	// controller needs the Pods of one node, and caching every Pod in the
+	// cluster for that would cost more than a List every few seconds.
+	APIReader client.Reader
+	Recorder  record.EventRecorder
+	// PollInterval is how often a drain checks on the Pods it's waiting for.
+	PollInterval time.Duration
+}
+
+func (r *NodeMaintenanceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var nm maintenancev1alpha1.NodeMaintenance
+	if err := r.Get(ctx, req.NamespacedName, &nm); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !nm.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, r.finalize(ctx, &nm)
+	}
+	if controllerutil.AddFinalizer(&nm, uncordonFinalizer) {
+		if err := r.Update(ctx, &nm); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	var node corev1.Node
+	if err := r.Get(ctx, client.ObjectKey{Name: nm.Spec.NodeName}, &node); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		// The Node watch brings the NodeMaintenance back if the node joins.
+		return ctrl.Result{}, r.fail(ctx, &nm, "NodeNotFound", fmt.Sprintf("Node %s doesn't exist", nm.Spec.NodeName))
+	}
+	if owner, ok := node.Annotations[cordonedByAnnotation]; ok && owner != nm.Name {
+		return ctrl.Result{}, r.fail(ctx, &nm, "Conflict", fmt.Sprintf("Node %s is already in maintenance by %s", node.Name, owner))
+	}
+
+	if err := r.cordon(ctx, &nm, &node); err != nil {
+		return ctrl.Result{}, err
+	}
+	progress, err := r.drain(ctx, &nm)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	return r.updateStatus(ctx, &nm, progress)
+}
+
+// cordon marks the node unschedulable, so nothing new lands on it while it
+// drains. It runs on every reconcile: if someone uncordons the node during
+// maintenance, the Node watch triggers a reconcile and it's cordoned again.
+func (r *NodeMaintenanceReconciler) cordon(ctx context.Context, nm *maintenancev1alpha1.NodeMaintenance, node *corev1.Node) error {
+	if node.Spec.Unschedulable {
+		return nil
+	}
+	patch := client.MergeFrom(node.DeepCopy())
+	node.Spec.Unschedulable = true
+	metav1.SetMetaDataAnnotation(&node.ObjectMeta, cordonedByAnnotation, nm.Name)
+	if err := r.Patch(ctx, node, patch); err != nil {
+		return fmt.Errorf("cordoning %s: %w", node.Name, err)
+	}
+	r.Recorder.Eventf(nm, corev1.EventTypeNormal, "Cordoned", "Cordoned node %s: %s", node.Name, reasonOrDefault(nm))
+	return nil
+}
+
+// finalize uncordons the node, if this NodeMaintenance cordoned it, and lets
+// the NodeMaintenance go.
+func (r *NodeMaintenanceReconciler) finalize(ctx context.Context, nm *maintenancev1alpha1.NodeMaintenance) error {
+	if !controllerutil.ContainsFinalizer(nm, uncordonFinalizer) {
+		return nil
+	}
+	var node corev1.Node
+	err := r.Get(ctx, client.ObjectKey{Name: nm.Spec.NodeName}, &node)
+	switch {
+	case apierrors.IsNotFound(err):
+		// Nothing to uncordon.
+	case err != nil:
+		return err
+	case node.Annotations[cordonedByAnnotation] == nm.Name:
+		patch := client.MergeFrom(node.DeepCopy())
+		node.Spec.Unschedulable = false
+		delete(node.Annotations, cordonedByAnnotation)
+		if err := r.Patch(ctx, &node, patch); err != nil {
+			return fmt.Errorf("uncordoning %s: %w", node.Name, err)
+		}
+		r.Recorder.Eventf(nm, corev1.EventTypeNormal, "Uncordoned", "Uncordoned node %s", node.Name)
+	default:
+		r.Recorder.Eventf(nm, corev1.EventTypeNormal, "LeftCordoned",
+			"Node %s was not cordoned by this NodeMaintenance; leaving it as it is", node.Name)
+	}
+	controllerutil.RemoveFinalizer(nm, uncordonFinalizer)
+	return r.Update(ctx, nm)
+}
+
+// updateStatus records the drain's progress, and polls again while Pods
+// remain: the controller doesn't watch Pods (see APIReader).
+func (r *NodeMaintenanceReconciler) updateStatus(ctx context.Context, nm *maintenancev1alpha1.NodeMaintenance, p drainProgress) (ctrl.Result, error) {
+	before := nm.Status.DeepCopy()
+	status := &nm.Status
+	now := metav1.Now()
+	if status.DrainStartTime == nil {
+		status.DrainStartTime = &now
+	}
+	// A Pod the scheduler bound just before the cordon, or one that
+	// tolerates it, shows up in a later List: the total only grows.
+	status.TotalPods = max(status.TotalPods, int32(p.remaining))
+	status.EvictedPods = status.TotalPods - int32(p.remaining)
+	status.BlockedPods = p.blocked
+
+	cond := metav1.Condition{Type: maintenancev1alpha1.ConditionDrained, ObservedGeneration: nm.Generation}
+	switch {
+	case p.remaining == 0:
+		status.Phase = maintenancev1alpha1.PhaseDrained
+		cond.Status, cond.Reason, cond.Message = metav1.ConditionTrue, "Drained", "No Pods left to evict"
+		if status.DrainCompletionTime == nil {
+			status.DrainCompletionTime = &now
+			r.Recorder.Eventf(nm, corev1.EventTypeNormal, "Drained", "Node %s drained in %s",
+				nm.Spec.NodeName, now.Sub(status.DrainStartTime.Time).Round(time.Second))
+		}
+	case p.blockedCount > 0:
+		status.Phase = maintenancev1alpha1.PhaseDraining
+		cond.Status, cond.Reason = metav1.ConditionFalse, "EvictionBlocked"
+		cond.Message = fmt.Sprintf("Pods left: %d, of which %d can't be evicted yet, e.g. %s/%s: %s", p.remaining, p.blockedCount,
+			p.blocked[0].Namespace, p.blocked[0].Name, p.blocked[0].Message)
+	default:
+		status.Phase = maintenancev1alpha1.PhaseDraining
+		cond.Status, cond.Reason = metav1.ConditionFalse, "Draining"
+		cond.Message = fmt.Sprintf("Waiting for Pods to terminate: %d left", p.remaining)
+	}
+	if p.remaining > 0 {
+		status.DrainCompletionTime = nil
+	}
+	meta.SetStatusCondition(&status.Conditions, cond)
+
+	if !equality.Semantic.DeepEqual(before, status) {
+		if err := r.Status().Update(ctx, nm); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	if p.remaining > 0 {
+		return ctrl.Result{RequeueAfter: r.PollInterval}, nil
+	}
+	return ctrl.Result{}, nil
+}
+
+// fail reports a NodeMaintenance that can't proceed. It isn't retried: the
+// Node watch triggers a reconcile when the node, or its annotation, changes.
+func (r *NodeMaintenanceReconciler) fail(ctx context.Context, nm *maintenancev1alpha1.NodeMaintenance, reason, message string) error {
+	before := nm.Status.DeepCopy()
+	nm.Status.Phase = maintenancev1alpha1.PhaseFailed
+	meta.SetStatusCondition(&nm.Status.Conditions, metav1.Condition{
+		Type: maintenancev1alpha1.ConditionDrained, Status: metav1.ConditionFalse,
+		Reason: reason, Message: message, ObservedGeneration: nm.Generation,
+	})
+	if equality.Semantic.DeepEqual(before, &nm.Status) {
+		return nil
+	}
+	r.Recorder.Event(nm, corev1.EventTypeWarning, reason, message)
+	return r.Status().Update(ctx, nm)
+}
+
+func reasonOrDefault(nm *maintenancev1alpha1.NodeMaintenance) string {
+	if nm.Spec.Reason != "" {
+		return nm.Spec.Reason
+	}
+	return "no reason given"
+}
+
+// SetupWithManager watches NodeMaintenances, and the Nodes they name: a node
+// that joins, is uncordoned by hand, or loses a Conflict's annotation is
+// reconciled again. Pods aren't watched; drains poll (see updateStatus).
+func (r *NodeMaintenanceReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(ctx, &maintenancev1alpha1.NodeMaintenance{}, nodeNameIndex,
+		func(obj client.Object) []string {
+			return []string{obj.(*maintenancev1alpha1.NodeMaintenance).Spec.NodeName}
+		}); err != nil {
+		return err
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&maintenancev1alpha1.NodeMaintenance{}).
+		Watches(&corev1.Node{}, handler.EnqueueRequestsFromMapFunc(r.maintenancesForNode),
+			// Kubelets update their Node's status every few minutes; only
+			// what the controller acts on counts.
+			builder.WithPredicates(predicate.Funcs{
+				UpdateFunc: func(e event.UpdateEvent) bool {
+					before, after := e.ObjectOld.(*corev1.Node), e.ObjectNew.(*corev1.Node)
+					return before.Spec.Unschedulable != after.Spec.Unschedulable ||
+						before.Annotations[cordonedByAnnotation] != after.Annotations[cordonedByAnnotation]
+				},
+			})).
+		Named("nodemaintenance").
+		Complete(r)
+}
+
+func (r *NodeMaintenanceReconciler) maintenancesForNode(ctx context.Context, node client.Object) []reconcile.Request {
+	var list maintenancev1alpha1.NodeMaintenanceList
+	if err := r.List(ctx, &list, client.MatchingFields{nodeNameIndex: node.GetName()}); err != nil {
+		return nil
+	}
+	requests := make([]reconcile.Request, len(list.Items))
+	for i, nm := range list.Items {
+		requests[i] = reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&nm)}
+	}
+	return requests
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	maintenancev1alpha1 "node-maintenance-operator/api/v1alpha1"
+)
+
+// maxBlockedPods caps status.blockedPods: a node with 100 Pods under one
+// PDB would otherwise list them all, on every poll.
+const maxBlockedPods = 10
+
+// drainProgress is what one pass over the node's Pods found.
+type drainProgress struct {
+	// remaining counts the Pods still to go, including those terminating.
+	remaining int
+	// blockedCount counts the evictions refused on this pass; blocked lists
+	// the first maxBlockedPods of them.
+	blockedCount int
+	blocked      []maintenancev1alpha1.BlockedPod
+}
+
+// drain asks for the eviction of every Pod on the node that must go, and
+// reports how many are left. It doesn't wait: evictions that are refused
+// are retried on the next pass, PollInterval later, like kubectl drain does
+// every 5 seconds.
+func (r *NodeMaintenanceReconciler) drain(ctx context.Context, nm *maintenancev1alpha1.NodeMaintenance) (drainProgress, error) {
+	log := logf.FromContext(ctx)
+
+	// spec.nodeName is one of the few Pod fields the API server can filter
+	// on, so this is one List of the node's Pods, not of the cluster's.
+	var pods corev1.PodList
+	if err := r.APIReader.List(ctx, &pods, client.MatchingFields{"spec.nodeName": nm.Spec.NodeName}); err != nil {
+		return drainProgress{}, fmt.Errorf("listing Pods on %s: %w", nm.Spec.NodeName, err)
+	}
+
+	var p drainProgress
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if !mustEvict(pod) {
+			continue
+		}
+		p.remaining++
+		if !pod.DeletionTimestamp.IsZero() {
+			// Evicted already, and in its termination grace period.
+			continue
+		}
+
+		// An Eviction is a Delete that checks the PodDisruptionBudgets
+		// first. The API server refuses it with 429 Too Many Requests when
+		// it would take a budget below its minimum.
+		eviction := &policyv1.Eviction{ObjectMeta: metav1.ObjectMeta{Name: pod.Name, Namespace: pod.Namespace}}
+		err := r.SubResource("eviction").Create(ctx, pod, eviction)
+		switch {
+		case err == nil:
+			evictionsTotal.WithLabelValues("evicted").Inc()
+			r.Recorder.Eventf(nm, corev1.EventTypeNormal, "Evicted", "Evicted Pod %s/%s", pod.Namespace, pod.Name)
+		case apierrors.IsNotFound(err):
+			// Gone between the List and the Eviction.
+			p.remaining--
+		case apierrors.IsTooManyRequests(err):
+			evictionsTotal.WithLabelValues("blocked").Inc()
+			p.block(pod, err)
+		default:
+			// e.g. a Pod covered by two PDBs, which the API server refuses
+			// to evict with a 500. Report it like a blocked Pod and retry:
+			// someone has to fix the PDBs.
+			evictionsTotal.WithLabelValues("error").Inc()
+			log.Error(err, "Eviction failed", "pod", client.ObjectKeyFromObject(pod))
+			p.block(pod, err)
+		}
+	}
+	return p, nil
+}
+
+func (p *drainProgress) block(pod *corev1.Pod, err error) {
+	p.blockedCount++
+	if len(p.blocked) < maxBlockedPods {
+		p.blocked = append(p.blocked, maintenancev1alpha1.BlockedPod{
+			Namespace: pod.Namespace, Name: pod.Name, Message: errorMessage(err),
+		})
+	}
+}
+
+// errorMessage is the API server's message, without client-go's prefix.
+func errorMessage(err error) string {
+	if status, ok := err.(apierrors.APIStatus); ok {
+		return status.Status().Message
+	}
+	return err.Error()
+}
+
+// mustEvict is kubectl drain --ignore-daemonsets --delete-emptydir-data: a
+// drain evicts every Pod except
+//   - DaemonSet Pods: the DaemonSet controller would recreate them on the
+//     node right away, as they tolerate the unschedulable taint;
+//   - static (mirror) Pods: the kubelet runs them from a file, and deleting
+//     the API object does nothing;
+//   - finished Pods, which hold no resources.
+//
+// Pods with emptyDir volumes are evicted too, and their data is lost.
+func mustEvict(pod *corev1.Pod) bool {
+	if _, ok := pod.Annotations[corev1.MirrorPodAnnotationKey]; ok {
+		return false
+	}
+	if owner := metav1.GetControllerOf(pod); owner != nil && owner.Kind == "DaemonSet" {
+		return false
+	}
+	return pod.Status.Phase != corev1.PodSucceeded && pod.Status.Phase != corev1.PodFailed
+}
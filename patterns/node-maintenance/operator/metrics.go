@@ -0,0 +1,20 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Metrics go to controller-runtime's registry, not the default one: the
+// Manager serves that registry on its /metrics, next to its own
+// controller_runtime_* and workqueue_* metrics.
+var (
+	evictionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "nodemaintenance_evictions_total",
+		Help: "Eviction requests, by result (evicted, blocked, error).",
+	}, []string{"result"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(evictionsTotal)
+}
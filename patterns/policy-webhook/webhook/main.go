@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+// splitList reads a comma-separated env var, dropping empty items.
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSuffix(strings.TrimSpace(item), "/"); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// inClusterClient returns a client authenticated as the Pod's
+// ServiceAccount; see manifests/rbac.yaml for what it may do.
+func inClusterClient() (kubernetes.Interface, error) {
+	cfg, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(cfg)
+}
+
+// tlsConfig picks the serving certificate according to TLS_MODE.
+func tlsConfig(ctx context.Context) (*tls.Config, error) {
+	switch mode := getEnv("TLS_MODE", "bootstrap"); mode {
+	case "files":
+		dir := getEnv("TLS_DIR", "/etc/webhook/tls")
+		fc := &fileCertificate{certFile: dir + "/tls.crt", keyFile: dir + "/tls.key"}
+		if _, err := fc.GetCertificate(nil); err != nil {
+			return nil, err
+		}
+		return &tls.Config{MinVersion: tls.VersionTLS12, GetCertificate: fc.GetCertificate}, nil
+	case "bootstrap":
+		client, err := inClusterClient()
+		if err != nil {
+			return nil, err
+		}
+		b := &Bootstrap{
+			Client:        client,
+			Namespace:     getEnv("POD_NAMESPACE", "default"),
+			Secret:        getEnv("TLS_SECRET", "policy-webhook-tls"),
+			Service:       getEnv("SERVICE_NAME", "policy-webhook"),
+			WebhookConfig: getEnv("WEBHOOK_CONFIG", "image-policy"),
+		}
+		cert, ca, err := b.Certificate(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("TLS bootstrap: %w", err)
+		}
+		if err := b.InjectCABundle(ctx, ca); err != nil {
+			// Serve anyway. Until the CA is in place, the API server can't
+			// call the webhook, and applies each webhook's failurePolicy.
+			fmt.Printf("Error: can't inject the CA into %s: %s\n", b.WebhookConfig, err)
+		}
+		return &tls.Config{MinVersion: tls.VersionTLS12, Certificates: []tls.Certificate{cert}}, nil
+	default:
+		return nil, fmt.Errorf("TLS_MODE must be bootstrap or files, got %q", mode)
+	}
+}
+
+func main() {
+	listenAddr := getEnv("LISTEN_ADDR", ":8443")
+	metricsAddr := getEnv("METRICS_ADDR", ":9090")
+
+	policy := &Policy{Registries: splitList(getEnv("ALLOWED_REGISTRIES", "registry.k8s.io,docker.io/library"))}
+	for _, r := range splitList(getEnv("REQUIRED_LIMITS", "cpu,memory")) {
+		policy.Limits = append(policy.Limits, corev1.ResourceName(r))
+	}
+	if len(policy.Registries) == 0 {
+		fmt.Println("Error: ALLOWED_REGISTRIES is empty; every image would be refused")
+		os.Exit(1)
+	}
+	// Audit is the default: a new policy should start by reporting what it
+	// would break, not by breaking it.
+	mode := getEnv("POLICY_MODE", modeAudit)
+	if mode != modeEnforce && mode != modeAudit {
+		fmt.Printf("Error: POLICY_MODE must be enforce or audit, got %q\n", mode)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	tlsCfg, err := tlsConfig(ctx)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+
+	mux := http.NewServeMux()
+	handler := &Handler{Policy: policy, DefaultMode: mode}
+	mux.Handle("POST /validate", handler)
+	mux.Handle("POST /validate/{mode}", handler)
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) { w.Write([]byte("ok")) })
+	server := &http.Server{
+		Addr:              listenAddr,
+		Handler:           mux,
+		TLSConfig:         tlsCfg,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	metricsMux := http.NewServeMux()
+	metricsMux.Handle("GET /metrics", promhttp.Handler())
+	metrics := &http.Server{Addr: metricsAddr, Handler: metricsMux}
+	go func() {
+		if err := metrics.ListenAndServe(); err != http.ErrServerClosed {
+			fmt.Printf("Error starting metrics server: %s\n", err)
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		metrics.Shutdown(shutdownCtx)
+		server.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Printf("Listening on %s (TLS), default mode %s: registries %s, limits %v\n",
+		listenAddr, mode, strings.Join(policy.Registries, ", "), policy.Limits)
+	if err := server.ListenAndServeTLS("", ""); err != http.ErrServerClosed {
+		fmt.Printf("Error starting server: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("Shutting down...")
+}
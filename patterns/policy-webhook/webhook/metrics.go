@@ -0,0 +1,28 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Metrics are registered with the default registry via 'promauto', and
+// served on the plain-HTTP METRICS_ADDR, apart from the TLS admission port.
+var (
+	decisionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "policy_decisions_total",
+		Help: "Objects checked, by kind, mode and result (allowed, warned, denied, error).",
+	}, []string{"kind", "mode", "result"})
+
+	// In audit mode this is the to-do list before switching to enforce:
+	// which namespaces would break, and on which rule.
+	violationsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "policy_violations_total",
+		Help: "Rule violations found, by rule, namespace and mode.",
+	}, []string{"rule", "namespace", "mode"})
+
+	requestDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "policy_webhook_duration_seconds",
+		Help:    "Time to answer an AdmissionReview.",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, 1},
+	})
+)
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Policy is the whole rule set, in plain Go: every rule is a function of
+// the Pod spec, so it is easy to read, test and extend without a policy
+// language.
+type Policy struct {
+	// Registries lists allowed image prefixes: a registry host
+	// ("registry.k8s.io") or a host and path ("ghcr.io/myorg"), matched on
+	// whole path segments.
+	Registries []string
+	// Limits lists the resources every container must have a limit for.
+	Limits []corev1.ResourceName
+}
+
+// Violation is one broken rule in one container.
+type Violation struct {
+	Rule    string // "registry" or "limits"; the metrics label
+	Message string
+}
+
+// Check returns every violation in spec, init containers included: a
+// native sidecar or an init container runs the same code as any other.
+func (p *Policy) Check(spec *corev1.PodSpec) []Violation {
+	var out []Violation
+	for _, list := range [][]corev1.Container{spec.InitContainers, spec.Containers} {
+		for _, c := range list {
+			if !p.registryAllowed(c.Image) {
+				out = append(out, Violation{"registry", fmt.Sprintf(
+					"container %q: image %q is not from an allowed registry (%s)",
+					c.Name, c.Image, strings.Join(p.Registries, ", "))})
+			}
+			var missing []string
+			for _, r := range p.Limits {
+				if _, ok := c.Resources.Limits[r]; !ok {
+					missing = append(missing, string(r))
+				}
+			}
+			if len(missing) > 0 {
+				out = append(out, Violation{"limits", fmt.Sprintf(
+					"container %q: no %s limit", c.Name, strings.Join(missing, " or "))})
+			}
+		}
+	}
+	return out
+}
+
+func (p *Policy) registryAllowed(image string) bool {
+	repo := repository(image)
+	for _, allowed := range p.Registries {
+		if repo == allowed || strings.HasPrefix(repo, allowed+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// repository returns image's fully qualified repository, without tag or
+// digest, the way the container runtime resolves it:
+//
+//	busybox:1.36                 → docker.io/library/busybox
+//	bitnami/redis                → docker.io/bitnami/redis
+//	localhost:5000/app@sha256:.. → localhost:5000/app
+//
+// Matching the raw string instead would let "registry.k8s.io.evil.com/x"
+// pass a "registry.k8s.io" prefix check, and miss that "nginx" is pulled
+// from Docker Hub.
+func repository(image string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image = image[:i]
+	}
+	host, rest, found := strings.Cut(image, "/")
+	if !found {
+		return "docker.io/library/" + image
+	}
+	// The first segment is a registry only if it looks like a host name.
+	if !strings.ContainsAny(host, ".:") && host != "localhost" {
+		return "docker.io/" + image
+	}
+	if host == "index.docker.io" {
+		host = "docker.io"
+	}
+	if host == "docker.io" && !strings.Contains(rest, "/") {
+		rest = "library/" + rest
+	}
+	return host + "/" + rest
+}
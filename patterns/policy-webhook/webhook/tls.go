@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+// The API server only calls webhooks over HTTPS, and verifies the serving
+// certificate against the caBundle in the webhook configuration. There are
+// two ways to get both in place:
+//
+//   - TLS_MODE=files: something else (cert-manager) writes tls.crt and
+//     tls.key into a mounted Secret and injects the caBundle. The files are
+//     re-read when they change, so renewals need no restart.
+//   - TLS_MODE=bootstrap: the webhook makes its own CA and certificate,
+//     stores them in a Secret so every replica serves the same one, and
+//     writes the CA into the webhook configuration's caBundle.
+
+// fileCertificate serves the key pair at certFile/keyFile, reloading it
+// when the certificate file's modification time changes.
+type fileCertificate struct {
+	certFile, keyFile string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+func (f *fileCertificate) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	info, err := os.Stat(f.certFile)
+	if err != nil {
+		if f.cert != nil {
+			return f.cert, nil // mid-update: keep serving the one we have
+		}
+		return nil, err
+	}
+	if f.cert == nil || !info.ModTime().Equal(f.modTime) {
+		cert, err := tls.LoadX509KeyPair(f.certFile, f.keyFile)
+		if err != nil {
+			if f.cert != nil {
+				return f.cert, nil
+			}
+			return nil, err
+		}
+		if f.cert != nil {
+			fmt.Printf("Reloaded the serving certificate from %s\n", f.certFile)
+		}
+		f.cert, f.modTime = &cert, info.ModTime()
+	}
+	return f.cert, nil
+}
+
+// Bootstrap is TLS_MODE=bootstrap.
+type Bootstrap struct {
+	Client        kubernetes.Interface
+	Namespace     string
+	Secret        string // where the CA and serving key pair are kept
+	Service       string // the webhook's Service: the certificate's DNS names
+	WebhookConfig string // the ValidatingWebhookConfiguration to put the CA in
+}
+
+// Certificate returns the serving certificate from the Secret, creating
+// both on the first run. Replicas starting together race to create the
+// Secret; the losers read the winner's.
+func (b *Bootstrap) Certificate(ctx context.Context) (tls.Certificate, []byte, error) {
+	secrets := b.Client.CoreV1().Secrets(b.Namespace)
+	secret, err := secrets.Get(ctx, b.Secret, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		var data map[string][]byte
+		if data, err = b.generate(); err != nil {
+			return tls.Certificate{}, nil, err
+		}
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: b.Secret, Namespace: b.Namespace},
+			Type:       corev1.SecretTypeTLS,
+			Data:       data,
+		}
+		_, err = secrets.Create(ctx, secret, metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			secret, err = secrets.Get(ctx, b.Secret, metav1.GetOptions{})
+		} else if err == nil {
+			fmt.Printf("Generated a CA and serving certificate for %s.%s.svc in Secret %s\n", b.Service, b.Namespace, b.Secret)
+		}
+	}
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	cert, err := tls.X509KeyPair(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey])
+	if err != nil {
+		return tls.Certificate{}, nil, fmt.Errorf("secret %s: %w", b.Secret, err)
+	}
+	return cert, secret.Data["ca.crt"], nil
+}
+
+// generate makes a CA, and a serving certificate for the Service's DNS
+// names signed by it.
+func (b *Bootstrap) generate() (map[string][]byte, error) {
+	now := time.Now()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: b.Service + "-ca"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		return nil, err
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	// The API server calls https://<service>.<namespace>.svc:<port>/...
+	// and checks that name against the SANs. The CommonName is ignored.
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: fmt.Sprintf("%s.%s.svc", b.Service, b.Namespace)},
+		DNSNames: []string{
+			b.Service,
+			fmt.Sprintf("%s.%s", b.Service, b.Namespace),
+			fmt.Sprintf("%s.%s.svc", b.Service, b.Namespace),
+			fmt.Sprintf("%s.%s.svc.cluster.local", b.Service, b.Namespace),
+		},
+		NotBefore:   now.Add(-time.Hour),
+		NotAfter:    now.AddDate(1, 0, 0),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		return nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return map[string][]byte{
+		corev1.TLSCertKey:       pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		corev1.TLSPrivateKeyKey: pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+		"ca.crt":                pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}),
+	}, nil
+}
+
+// InjectCABundle writes the CA into every webhook of the configuration.
+// Until it is there, the API server can't verify the webhook, and applies
+// the failurePolicy to every call.
+func (b *Bootstrap) InjectCABundle(ctx context.Context, ca []byte) error {
+	configs := b.Client.AdmissionregistrationV1().ValidatingWebhookConfigurations()
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cfg, err := configs.Get(ctx, b.WebhookConfig, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		changed := false
+		for i := range cfg.Webhooks {
+			if !bytes.Equal(cfg.Webhooks[i].ClientConfig.CABundle, ca) {
+				cfg.Webhooks[i].ClientConfig.CABundle = ca
+				changed = true
+			}
+		}
+		if !changed {
+			return nil
+		}
+		if _, err := configs.Update(ctx, cfg, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+		fmt.Printf("Injected the CA into ValidatingWebhookConfiguration %s\n", b.WebhookConfig)
+		return nil
+	})
+}
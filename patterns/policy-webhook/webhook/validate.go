@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	modeEnforce = "enforce" // violations are denied
+	modeAudit   = "audit"   // violations are allowed, with warnings and metrics
+)
+
+// Handler serves POST /validate/{mode}. The mode comes from the path
+// (/validate/audit), so one server can back two webhooks with different
+// namespace selectors; on plain /validate, DefaultMode applies. A path
+// segment, not a query parameter: webhook service paths can't have one.
+type Handler struct {
+	Policy      *Policy
+	DefaultMode string
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	mode := r.PathValue("mode")
+	if mode == "" {
+		mode = h.DefaultMode
+	}
+	if mode != modeEnforce && mode != modeAudit {
+		http.Error(w, "mode must be enforce or audit, got "+mode, http.StatusBadRequest)
+		return
+	}
+	if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+		http.Error(w, "want Content-Type application/json, got "+ct, http.StatusUnsupportedMediaType)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 3<<20))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var review admissionv1.AdmissionReview
+	if err := json.Unmarshal(body, &review); err != nil || review.Request == nil {
+		http.Error(w, fmt.Sprintf("not an AdmissionReview: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	resp := h.review(review.Request, mode)
+	resp.UID = review.Request.UID
+	review.Response = resp
+	review.Request = nil
+
+	requestDuration.Observe(time.Since(start).Seconds())
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(review)
+}
+
+// podSpec extracts the Pod spec the policy applies to: the Pod's own, or
+// a Deployment's template. Checking the Deployment too means a bad image
+// is refused at kubectl apply, instead of surfacing later as a
+// ReplicaSet that can't create Pods.
+func podSpec(kind metav1.GroupVersionKind, raw []byte) (*corev1.PodSpec, bool, error) {
+	switch {
+	case kind.Group == "" && kind.Kind == "Pod":
+		var pod corev1.Pod
+		err := json.Unmarshal(raw, &pod)
+		return &pod.Spec, true, err
+	case kind.Group == "apps" && kind.Kind == "Deployment":
+		var deploy appsv1.Deployment
+		err := json.Unmarshal(raw, &deploy)
+		return &deploy.Spec.Template.Spec, true, err
+	}
+	return nil, false, nil
+}
+
+func (h *Handler) review(req *admissionv1.AdmissionRequest, mode string) *admissionv1.AdmissionResponse {
+	allow := &admissionv1.AdmissionResponse{Allowed: true}
+	kind := req.Kind.Kind
+	if req.Operation != admissionv1.Create && req.Operation != admissionv1.Update {
+		return allow
+	}
+	spec, ok, err := podSpec(req.Kind, req.Object.Raw)
+	if !ok {
+		return allow // not sent by webhook.yaml; nothing to check
+	}
+	if err != nil {
+		// A request the API server accepted, but we can't read: refuse
+		// it in enforce mode rather than let it through unchecked.
+		decisionsTotal.WithLabelValues(kind, mode, "error").Inc()
+		msg := fmt.Sprintf("policy webhook can't decode the %s: %s", kind, err)
+		fmt.Printf("Error: %s\n", msg)
+		if mode == modeAudit {
+			allow.Warnings = []string{msg}
+			return allow
+		}
+		return &admissionv1.AdmissionResponse{Result: &metav1.Status{Code: http.StatusBadRequest, Message: msg}}
+	}
+
+	violations := h.Policy.Check(spec)
+	// On UPDATE, violations the object already had are only warned about:
+	// otherwise a Deployment created before the policy could not even be
+	// scaled or relabeled. New violations are judged by the mode.
+	existing := map[string]bool{}
+	if req.Operation == admissionv1.Update {
+		if old, _, err := podSpec(req.Kind, req.OldObject.Raw); err == nil {
+			for _, v := range h.Policy.Check(old) {
+				existing[v.Message] = true
+			}
+		}
+	}
+
+	name := req.Name
+	if name == "" {
+		name = "<generated>"
+	}
+	ref := fmt.Sprintf("%s %s/%s", kind, req.Namespace, name)
+	var denied []string
+	for _, v := range violations {
+		violationsTotal.WithLabelValues(v.Rule, req.Namespace, mode).Inc()
+		switch {
+		case existing[v.Message]:
+			allow.Warnings = append(allow.Warnings, "[existing] "+v.Message)
+		case mode == modeAudit:
+			allow.Warnings = append(allow.Warnings, "[audit] "+v.Message)
+		default:
+			denied = append(denied, v.Message)
+		}
+	}
+
+	switch {
+	case len(denied) > 0:
+		decisionsTotal.WithLabelValues(kind, mode, "denied").Inc()
+		fmt.Printf("Denied %s %s: %s\n", strings.ToLower(string(req.Operation)), ref, strings.Join(denied, "; "))
+		return &admissionv1.AdmissionResponse{
+			Warnings: allow.Warnings,
+			Result: &metav1.Status{
+				Code:    http.StatusForbidden,
+				Reason:  metav1.StatusReasonForbidden,
+				Message: "image policy: " + strings.Join(denied, "; "),
+			},
+		}
+	case len(violations) > 0:
+		decisionsTotal.WithLabelValues(kind, mode, "warned").Inc()
+		fmt.Printf("Allowed %s %s with %d violation(s) (%s mode)\n", strings.ToLower(string(req.Operation)), ref, len(violations), mode)
+	default:
+		decisionsTotal.WithLabelValues(kind, mode, "allowed").Inc()
+	}
+	return allow
+}
@@ -0,0 +1,153 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ScaleTargetRef names the Deployment to scale, in the QueueScaler's
+// namespace.
+type ScaleTargetRef struct {
+	// Name is the Deployment's name.
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=253
+	// +required
+	Name string `json:"name"`
+}
+
+// QueueScalerSpec says which Deployment works off which queue, and how
+// many items one replica should have to itself.
+// +kubebuilder:validation:XValidation:rule="self.minReplicas <= self.maxReplicas",message="minReplicas must not be greater than maxReplicas"
+type QueueScalerSpec struct {
+	// ScaleTargetRef is the Deployment whose replicas are set. Nothing
+	// else may scale it: not an HPA, not kubectl scale.
+	// +required
+	ScaleTargetRef ScaleTargetRef `json:"scaleTargetRef"`
+
+	// QueueURL is the queue service's base URL; its GET /stats is polled.
+	// +kubebuilder:validation:Pattern=`^https?://`
+	// +required
+	QueueURL string `json:"queueURL"`
+
+	// ItemsPerReplica is the queue length one replica is sized for:
+	// desired replicas are ceil(queue length / itemsPerReplica).
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=10
+	// +optional
+	ItemsPerReplica int32 `json:"itemsPerReplica,omitempty"`
+
+	// ActivationThreshold is the queue length above which the queue is
+	// active: a Deployment at zero is woken, and the cooldown to zero
+	// starts over. At or below it, the queue counts as idle.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:default=0
+	// +optional
+	ActivationThreshold int32 `json:"activationThreshold,omitempty"`
+
+	// MinReplicas is the fewest replicas. 0 allows scaling to zero once
+	// the queue has been idle for cooldownPeriod.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:default=0
+	// +optional
+	MinReplicas int32 `json:"minReplicas"`
+
+	// MaxReplicas is the most replicas, however long the queue.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=10
+	// +optional
+	MaxReplicas int32 `json:"maxReplicas,omitempty"`
+
+	// PollingInterval is how often the queue is read.
+	// +kubebuilder:default="15s"
+	// +optional
+	PollingInterval metav1.Duration `json:"pollingInterval,omitempty"`
+
+	// CooldownPeriod is how long a scale down waits: since the last scale
+	// for a scale down, since the queue was last active for a scale to
+	// zero. Scale ups never wait.
+	// +kubebuilder:default="2m"
+	// +optional
+	CooldownPeriod metav1.Duration `json:"cooldownPeriod,omitempty"`
+}
+
+// ConditionReady is True when the queue was read and the Deployment
+// scaled as intended. While False, its reason says why: QueueUnavailable
+// (the replicas are left as they are) or TargetNotFound.
+const ConditionReady = "Ready"
+
+// ConditionActive is True while the queue is longer than the activation
+// threshold.
+const ConditionActive = "Active"
+
+// QueueScalerStatus reports the last reading of the queue, and what was
+// done with it.
+type QueueScalerStatus struct {
+	// QueueLength is the last queue length read: pending and leased items.
+	// +optional
+	QueueLength int64 `json:"queueLength"`
+
+	// CurrentReplicas is the Deployment's replicas after the last reconcile.
+	// +optional
+	CurrentReplicas int32 `json:"currentReplicas"`
+
+	// DesiredReplicas is what the queue length asks for, within min and
+	// max. It is ahead of currentReplicas during a cooldown.
+	// +optional
+	DesiredReplicas int32 `json:"desiredReplicas"`
+
+	// LastActiveTime is when the queue was last seen active.
+	// +optional
+	LastActiveTime *metav1.Time `json:"lastActiveTime,omitempty"`
+
+	// LastScaleTime is when the controller last changed the replicas.
+	// +optional
+	LastScaleTime *metav1.Time `json:"lastScaleTime,omitempty"`
+
+	// Conditions hold the Ready and Active conditions.
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName=qs
+// +kubebuilder:printcolumn:name="Target",type=string,JSONPath=`.spec.scaleTargetRef.name`
+// +kubebuilder:printcolumn:name="Min",type=integer,JSONPath=`.spec.minReplicas`
+// +kubebuilder:printcolumn:name="Max",type=integer,JSONPath=`.spec.maxReplicas`
+// +kubebuilder:printcolumn:name="Queue",type=integer,JSONPath=`.status.queueLength`
+// +kubebuilder:printcolumn:name="Desired",type=integer,JSONPath=`.status.desiredReplicas`
+// +kubebuilder:printcolumn:name="Replicas",type=integer,JSONPath=`.status.currentReplicas`
+// +kubebuilder:printcolumn:name="Active",type=string,JSONPath=`.status.conditions[?(@.type=="Active")].status`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// QueueScaler is the Schema for the queuescalers API
+type QueueScaler struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// metadata is a standard object metadata
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitzero"`
+
+	// spec defines the desired state of QueueScaler
+	// +required
+	Spec QueueScalerSpec `json:"spec"`
+
+	// status defines the observed state of QueueScaler
+	// +optional
+	Status QueueScalerStatus `json:"status,omitzero"`
+}
+
+// +kubebuilder:object:root=true
+
+// QueueScalerList contains a list of QueueScaler
+type QueueScalerList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitzero"`
+	Items           []QueueScaler `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&QueueScaler{}, &QueueScalerList{})
+}
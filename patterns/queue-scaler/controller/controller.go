@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	scalingv1alpha1 "queue-scaler/api/v1alpha1"
+)
+
+const (
+	defaultPollingInterval = 15 * time.Second
+	defaultCooldownPeriod  = 2 * time.Minute
+	defaultItemsPerReplica = 10
+)
+
+// QueueScalerReconciler sets a Deployment's replicas from the length of a
+// queue. The queue isn't a Kubernetes object, so there is nothing to
+// watch: each QueueScaler is requeued every spec.pollingInterval.
+type QueueScalerReconciler struct {
+	client.Client
+	Recorder record.EventRecorder
+	Queue    *QueueStats
+	// Now is the clock; tests set it to step through cooldowns.
+	Now func() time.Time
+}
+
+func (r *QueueScalerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+
+	var qs scalingv1alpha1.QueueScaler
+	if err := r.Get(ctx, req.NamespacedName, &qs); err != nil {
+		if apierrors.IsNotFound(err) {
+			queueLength.DeleteLabelValues(req.Namespace, req.Name)
+			desiredReplicas.DeleteLabelValues(req.Namespace, req.Name)
+			replicas.DeleteLabelValues(req.Namespace, req.Name)
+		}
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	before := qs.Status.DeepCopy()
+	interval := qs.Spec.PollingInterval.Duration
+	if interval <= 0 {
+		interval = defaultPollingInterval
+	}
+
+	// The scale subresource: the same call kubectl scale and the HPA make.
+	// It is read live, not from a cache, so no Deployments are watched.
+	target := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: qs.Spec.ScaleTargetRef.Name, Namespace: qs.Namespace}}
+	scale := &autoscalingv1.Scale{}
+	if err := r.SubResource("scale").Get(ctx, target, scale); err != nil {
+		if apierrors.IsNotFound(err) {
+			return requeue(interval, r.setReady(ctx, &qs, before, metav1.ConditionFalse, "TargetNotFound",
+				fmt.Sprintf("Deployment %s doesn't exist", target.Name)))
+		}
+		return ctrl.Result{}, err
+	}
+	current := scale.Spec.Replicas
+
+	length, err := r.Queue.Length(ctx, qs.Spec.QueueURL)
+	if err != nil {
+		// Not knowing the queue length is no reason to scale: the replicas
+		// stay as they are, at zero or at max. The error requeues with the
+		// controller's backoff (see SetupWithManager).
+		qs.Status.CurrentReplicas = current
+		if statusErr := r.setReady(ctx, &qs, before, metav1.ConditionFalse, "QueueUnavailable", err.Error()); statusErr != nil {
+			return ctrl.Result{}, statusErr
+		}
+		return ctrl.Result{}, fmt.Errorf("reading queue %s: %w", qs.Spec.QueueURL, err)
+	}
+
+	now := r.Now()
+	active := length > int64(qs.Spec.ActivationThreshold)
+	if active {
+		qs.Status.LastActiveTime = &metav1.Time{Time: now}
+		meta.SetStatusCondition(&qs.Status.Conditions, metav1.Condition{
+			Type: scalingv1alpha1.ConditionActive, Status: metav1.ConditionTrue, Reason: "QueueActive",
+			Message:            fmt.Sprintf("%d items queued, above the activation threshold of %d", length, qs.Spec.ActivationThreshold),
+			ObservedGeneration: qs.Generation,
+		})
+	} else {
+		meta.SetStatusCondition(&qs.Status.Conditions, metav1.Condition{
+			Type: scalingv1alpha1.ConditionActive, Status: metav1.ConditionFalse, Reason: "QueueIdle",
+			Message:            fmt.Sprintf("%d items queued, at or below the activation threshold of %d", length, qs.Spec.ActivationThreshold),
+			ObservedGeneration: qs.Generation,
+		})
+	}
+	desired := desiredFor(&qs.Spec, length, active)
+	next, hold := applyCooldown(&qs, current, desired, now)
+
+	if next != current {
+		scale.Spec.Replicas = next
+		if err := r.SubResource("scale").Update(ctx, target, client.WithSubResourceBody(scale)); err != nil {
+			return ctrl.Result{}, err
+		}
+		qs.Status.LastScaleTime = &metav1.Time{Time: now}
+		direction, reason := "up", "ScaledUp"
+		switch {
+		case current == 0:
+			direction = "from_zero"
+		case next == 0:
+			direction, reason = "to_zero", "ScaledDown"
+		case next < current:
+			direction, reason = "down", "ScaledDown"
+		}
+		scalesTotal.WithLabelValues(direction).Inc()
+		log.Info("Scaled", "deployment", target.Name, "from", current, "to", next, "queueLength", length)
+		r.Recorder.Eventf(&qs, corev1.EventTypeNormal, reason, "Deployment %s scaled from %d to %d: %d items queued",
+			target.Name, current, next, length)
+	}
+
+	qs.Status.QueueLength = length
+	qs.Status.DesiredReplicas = desired
+	qs.Status.CurrentReplicas = next
+	queueLength.WithLabelValues(qs.Namespace, qs.Name).Set(float64(length))
+	desiredReplicas.WithLabelValues(qs.Namespace, qs.Name).Set(float64(desired))
+	replicas.WithLabelValues(qs.Namespace, qs.Name).Set(float64(next))
+
+	if hold != "" {
+		return requeue(interval, r.setReady(ctx, &qs, before, metav1.ConditionTrue, "CoolingDown", hold))
+	}
+	return requeue(interval, r.setReady(ctx, &qs, before, metav1.ConditionTrue, "AtDesiredReplicas",
+		fmt.Sprintf("Deployment %s at its desired replicas (%d), for %d items queued", target.Name, next, length)))
+}
+
+// desiredFor is the replicas the queue length asks for: none beyond
+// minReplicas while the queue is idle, and otherwise one per
+// itemsPerReplica items, rounded up, at least one and at most maxReplicas.
+func desiredFor(spec *scalingv1alpha1.QueueScalerSpec, length int64, active bool) int32 {
+	if !active {
+		return spec.MinReplicas
+	}
+	per := int64(spec.ItemsPerReplica)
+	if per <= 0 {
+		per = defaultItemsPerReplica
+	}
+	want := (length + per - 1) / per
+	want = max(want, int64(spec.MinReplicas), 1)
+	if spec.MaxReplicas > 0 {
+		want = min(want, int64(spec.MaxReplicas))
+	}
+	return int32(want)
+}
+
+// applyCooldown returns the replicas to set now, and why they differ from
+// desired, if they do. Scale ups happen at once. A scale down waits for
+// cooldownPeriod since the last scale, so a queue that drains in bursts
+// doesn't make the Deployment flap. A scale to zero also waits for
+// cooldownPeriod since the queue was last active, and until then keeps
+// one replica.
+func applyCooldown(qs *scalingv1alpha1.QueueScaler, current, desired int32, now time.Time) (int32, string) {
+	if desired >= current {
+		return desired, ""
+	}
+	cooldown := qs.Spec.CooldownPeriod.Duration
+	if cooldown <= 0 {
+		cooldown = defaultCooldownPeriod
+	}
+
+	next, hold := desired, ""
+	if desired == 0 {
+		if last := qs.Status.LastActiveTime; last != nil && now.Sub(last.Time) < cooldown {
+			next = 1
+			hold = fmt.Sprintf("Idle; scaling to zero at %s", last.Add(cooldown).UTC().Format(time.RFC3339))
+		}
+	}
+	if next < current {
+		if last := qs.Status.LastScaleTime; last != nil && now.Sub(last.Time) < cooldown {
+			return current, fmt.Sprintf("Holding %d replicas, %d desired; scaling down at %s",
+				current, desired, last.Add(cooldown).UTC().Format(time.RFC3339))
+		}
+	}
+	return next, hold
+}
+
+// setReady sets the Ready condition, and writes the status if anything in
+// it changed.
+func (r *QueueScalerReconciler) setReady(ctx context.Context, qs *scalingv1alpha1.QueueScaler, before *scalingv1alpha1.QueueScalerStatus,
+	status metav1.ConditionStatus, reason, message string) error {
+	changed := meta.SetStatusCondition(&qs.Status.Conditions, metav1.Condition{
+		Type: scalingv1alpha1.ConditionReady, Status: status, Reason: reason, Message: message,
+		ObservedGeneration: qs.Generation,
+	})
+	if changed && status == metav1.ConditionFalse {
+		r.Recorder.Event(qs, corev1.EventTypeWarning, reason, message)
+	}
+	if equality.Semantic.DeepEqual(before, &qs.Status) {
+		return nil
+	}
+	return r.Status().Update(ctx, qs)
+}
+
+// requeue polls again after the interval, unless err asks for a backoff:
+// controller-runtime ignores the Result when there is an error.
+func requeue(interval time.Duration, err error) (ctrl.Result, error) {
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: interval}, nil
+}
+
+// SetupWithManager watches QueueScalers, for spec changes only: the status
+// changes at every poll of a busy queue, and each write would otherwise
+// wake the controller for another poll. Failures back off from 1s to 5m.
+func (r *QueueScalerReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&scalingv1alpha1.QueueScaler{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		WithOptions(controller.Options{
+			RateLimiter: workqueue.NewTypedItemExponentialFailureRateLimiter[reconcile.Request](time.Second, 5*time.Minute),
+		}).
+		Named("queuescaler").
+		Complete(r)
+}
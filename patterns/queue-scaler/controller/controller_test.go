@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	scalingv1alpha1 "queue-scaler/api/v1alpha1"
+)
+
+func TestDesiredFor(t *testing.T) {
+	spec := scalingv1alpha1.QueueScalerSpec{ItemsPerReplica: 10, MinReplicas: 0, MaxReplicas: 8}
+	for _, tc := range []struct {
+		name   string
+		min    int32
+		length int64
+		active bool
+		want   int32
+	}{
+		{name: "idle goes to minReplicas", length: 0, want: 0},
+		{name: "idle keeps minReplicas", min: 2, length: 0, want: 2},
+		{name: "idle with items under the threshold", length: 5, want: 0},
+		{name: "active rounds up", length: 21, active: true, want: 3},
+		{name: "active exact", length: 30, active: true, want: 3},
+		{name: "active is at least one replica", length: 1, active: true, want: 1},
+		{name: "active is at least minReplicas", min: 4, length: 1, active: true, want: 4},
+		{name: "clamped to maxReplicas", length: 1000, active: true, want: 8},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			spec := spec
+			spec.MinReplicas = tc.min
+			if got := desiredFor(&spec, tc.length, tc.active); got != tc.want {
+				t.Errorf("desiredFor(%d, active=%t) = %d, want %d", tc.length, tc.active, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestApplyCooldown(t *testing.T) {
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	ago := func(d time.Duration) *metav1.Time { return &metav1.Time{Time: now.Add(-d)} }
+	for _, tc := range []struct {
+		name             string
+		current, desired int32
+		lastScale        *metav1.Time
+		lastActive       *metav1.Time
+		want             int32
+		hold             string // a substring of the hold message; "" for none
+	}{
+		{name: "up ignores the cooldown", current: 2, desired: 5, lastScale: ago(time.Second), want: 5},
+		{name: "from zero ignores the cooldown", current: 0, desired: 3, lastScale: ago(time.Second), want: 3},
+		{name: "unchanged", current: 3, desired: 3, lastScale: ago(time.Second), want: 3},
+		{name: "down held after a recent scale", current: 5, desired: 2, lastScale: ago(time.Minute),
+			want: 5, hold: "Holding 5 replicas, 2 desired; scaling down at 2026-10-16T09:01:00Z"},
+		{name: "down once the cooldown is over", current: 5, desired: 2, lastScale: ago(2 * time.Minute), want: 2},
+		{name: "down with no scale yet", current: 5, desired: 2, want: 2},
+		{name: "to zero keeps one replica while recently active", current: 3, desired: 0,
+			lastScale: ago(time.Hour), lastActive: ago(time.Minute),
+			want: 1, hold: "Idle; scaling to zero at 2026-10-16T09:01:00Z"},
+		{name: "to zero once inactive for the cooldown", current: 1, desired: 0,
+			lastScale: ago(time.Hour), lastActive: ago(2 * time.Minute), want: 0},
+		{name: "to zero at once if never active", current: 2, desired: 0, want: 0},
+		{name: "to one still waits for the last scale", current: 3, desired: 0,
+			lastScale: ago(time.Minute), lastActive: ago(time.Minute),
+			want: 3, hold: "Holding 3 replicas"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			qs := &scalingv1alpha1.QueueScaler{
+				Spec:   scalingv1alpha1.QueueScalerSpec{CooldownPeriod: metav1.Duration{Duration: 2 * time.Minute}},
+				Status: scalingv1alpha1.QueueScalerStatus{LastScaleTime: tc.lastScale, LastActiveTime: tc.lastActive},
+			}
+			got, hold := applyCooldown(qs, tc.current, tc.desired, now)
+			if got != tc.want {
+				t.Errorf("applyCooldown(%d -> %d) = %d, want %d", tc.current, tc.desired, got, tc.want)
+			}
+			if tc.hold == "" && hold != "" || !strings.Contains(hold, tc.hold) {
+				t.Errorf("hold = %q, want %q", hold, tc.hold)
+			}
+		})
+	}
+}
+
+// TestReconcileScalesToZero runs Reconcile against a fake API server and
+// queue, stepping its clock through the cooldowns: up at once, down after
+// the last scale's cooldown, and to zero after the last activity's.
+func TestReconcileScalesToZero(t *testing.T) {
+	var length atomic.Int64
+	queue := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprintf(w, `{"items":{"pending":%d,"leased":0}}`, length.Load())
+	}))
+	defer queue.Close()
+
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = scalingv1alpha1.AddToScheme(scheme)
+	qs := &scalingv1alpha1.QueueScaler{
+		ObjectMeta: metav1.ObjectMeta{Name: "queue-worker", Namespace: "default"},
+		Spec: scalingv1alpha1.QueueScalerSpec{
+			ScaleTargetRef:      scalingv1alpha1.ScaleTargetRef{Name: "queue-worker"},
+			QueueURL:            queue.URL,
+			ItemsPerReplica:     10,
+			ActivationThreshold: 5,
+			MaxReplicas:         8,
+			CooldownPeriod:      metav1.Duration{Duration: 2 * time.Minute},
+		},
+	}
+	dep := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "queue-worker", Namespace: "default"},
+		Spec:       appsv1.DeploymentSpec{Replicas: ptr.To(int32(0))},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(qs, dep).
+		WithStatusSubresource(&scalingv1alpha1.QueueScaler{}).Build()
+
+	start := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	now := start
+	r := &QueueScalerReconciler{
+		Client:   c,
+		Recorder: record.NewFakeRecorder(100),
+		Queue:    &QueueStats{HTTP: queue.Client()},
+		Now:      func() time.Time { return now },
+	}
+	ctx := context.Background()
+	key := types.NamespacedName{Name: "queue-worker", Namespace: "default"}
+	step := func(d time.Duration, items int64, want int32) {
+		t.Helper()
+		now = now.Add(d)
+		length.Store(items)
+		if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err != nil {
+			t.Fatalf("Reconcile: %v", err)
+		}
+		var got appsv1.Deployment
+		if err := c.Get(ctx, key, &got); err != nil {
+			t.Fatal(err)
+		}
+		if *got.Spec.Replicas != want {
+			t.Fatalf("at +%s with %d items: %d replicas, want %d", now.Sub(start), items, *got.Spec.Replicas, want)
+		}
+	}
+
+	step(0, 5, 0)               // at the activation threshold: idle, stays at zero
+	step(15*time.Second, 55, 6) // from zero, at once
+	step(15*time.Second, 80, 8) // up, within the cooldown, clamped
+	step(15*time.Second, 20, 8) // down, held by the last scale
+	step(2*time.Minute, 20, 2)  // down, once it is over
+	step(15*time.Second, 0, 2)  // idle: held by the last scale
+	step(2*time.Minute, 15, 2)  // active again, at 2: only lastActiveTime moves
+	step(15*time.Second, 0, 1)  // idle: one replica stays, the queue was active 15s ago
+	step(time.Minute, 0, 1)     // still within the cooldown since then
+	step(time.Minute, 0, 0)     // both cooldowns are over
+}
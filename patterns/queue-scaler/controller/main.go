@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+
+	scalingv1alpha1 "queue-scaler/api/v1alpha1"
+)
+
+var scheme = runtime.NewScheme()
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(scalingv1alpha1.AddToScheme(scheme))
+}
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	if value, ok := os.LookupEnv(key); ok {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+		fmt.Printf("Invalid %s=%q, using default %t\n", key, value, fallback)
+	}
+	return fallback
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if value, ok := os.LookupEnv(key); ok {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+		fmt.Printf("Invalid %s=%q, using default %s\n", key, value, fallback)
+	}
+	return fallback
+}
+
+func main() {
+	ctrl.SetLogger(zap.New())
+
+	// The Manager authenticates as the Pod's ServiceAccount (or the local
+	// kubeconfig, for go run .); see manifests/rbac.yaml for what it may do.
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+		Scheme:                 scheme,
+		Metrics:                metricsserver.Options{BindAddress: getEnv("METRICS_ADDR", ":8080")},
+		HealthProbeBindAddress: getEnv("PROBE_ADDR", ":8081"),
+		// Two scalers writing the same replicas would fight over the
+		// cooldowns: only the leader reconciles.
+		LeaderElection:   getEnvBool("LEADER_ELECT", true),
+		LeaderElectionID: "queue-scaler.scaling.mydomain.com",
+		// The Lease lives in the Pod's namespace; out of cluster, say where.
+		LeaderElectionNamespace: getEnv("LEADER_ELECTION_NAMESPACE", ""),
+	})
+	if err != nil {
+		fmt.Printf("Error creating manager: %s\n", err)
+		os.Exit(1)
+	}
+
+	if err := (&QueueScalerReconciler{
+		Client:   mgr.GetClient(),
+		Recorder: mgr.GetEventRecorderFor("queue-scaler"),
+		Queue:    &QueueStats{HTTP: &http.Client{Timeout: getEnvDuration("QUEUE_TIMEOUT", 5*time.Second)}},
+		Now:      time.Now,
+	}).SetupWithManager(mgr); err != nil {
+		fmt.Printf("Error setting up controller: %s\n", err)
+		os.Exit(1)
+	}
+	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
+		fmt.Printf("Error adding health check: %s\n", err)
+		os.Exit(1)
+	}
+	if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
+		fmt.Printf("Error adding ready check: %s\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("Watching QueueScalers")
+	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+		fmt.Printf("Error running manager: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("Shutting down...")
+}
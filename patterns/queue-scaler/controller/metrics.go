@@ -0,0 +1,45 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Metrics go to controller-runtime's registry, which the Manager serves on
+// its /metrics next to the controller_runtime_* and workqueue_* metrics.
+var (
+	fetchesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "queuescaler_queue_fetches_total",
+		Help: "Reads of a queue's length, by result (ok, error).",
+	}, []string{"result"})
+
+	fetchDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "queuescaler_queue_fetch_duration_seconds",
+		Help:    "Time to read a queue's length.",
+		Buckets: prometheus.ExponentialBuckets(0.005, 2, 12),
+	})
+
+	queueLength = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "queuescaler_queue_length",
+		Help: "Last queue length read, per QueueScaler.",
+	}, []string{"namespace", "name"})
+
+	desiredReplicas = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "queuescaler_desired_replicas",
+		Help: "Replicas the queue length asks for, per QueueScaler.",
+	}, []string{"namespace", "name"})
+
+	replicas = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "queuescaler_replicas",
+		Help: "Replicas the target was set to, per QueueScaler.",
+	}, []string{"namespace", "name"})
+
+	scalesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "queuescaler_scales_total",
+		Help: "Changes to a target's replicas, by direction (up, down, to_zero, from_zero).",
+	}, []string{"direction"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(fetchesTotal, fetchDuration, queueLength, desiredReplicas, replicas, scalesTotal)
+}
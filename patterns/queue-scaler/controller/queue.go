@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// QueueStats reads a queue's length from the work queue service of the
+// job-workqueue pattern.
+type QueueStats struct {
+	HTTP *http.Client
+}
+
+// Length returns the queue's pending and leased items. Leased items count:
+// they are being worked on, and a replica removed now would leave its
+// item to run out its lease.
+func (q *QueueStats) Length(ctx context.Context, baseURL string) (int64, error) {
+	start := time.Now()
+	length, err := q.length(ctx, baseURL)
+	fetchDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		fetchesTotal.WithLabelValues("error").Inc()
+		return 0, err
+	}
+	fetchesTotal.WithLabelValues("ok").Inc()
+	return length, nil
+}
+
+func (q *QueueStats) length(ctx context.Context, baseURL string) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(baseURL, "/")+"/stats", nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := q.HTTP.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		if msg := strings.TrimSpace(string(body)); msg != "" {
+			return 0, fmt.Errorf("GET /stats: %s: %s", resp.Status, msg)
+		}
+		return 0, fmt.Errorf("GET /stats: %s", resp.Status)
+	}
+
+	var stats struct {
+		Items map[string]int64 `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return 0, fmt.Errorf("decoding GET /stats: %w", err)
+	}
+	return stats.Items["pending"] + stats.Items["leased"], nil
+}
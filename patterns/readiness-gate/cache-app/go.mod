@@ -0,0 +1,3 @@
+module cache-app
+
+go 1.24.3
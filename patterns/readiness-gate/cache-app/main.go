@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if value, ok := os.LookupEnv(key); ok {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+		fmt.Printf("Invalid %s=%q, using default %s\n", key, value, fallback)
+	}
+	return fallback
+}
+
+// A stand-in for a service with a cache it fills at startup: it can answer
+// at once, but slowly, until the cache is warm. Its readiness probe passes
+// as soon as it listens; the readiness gate is what holds traffic back
+// until /warmup says 200.
+func main() {
+	listenAddr := getEnv("LISTEN_ADDR", ":8080")
+	warmup := getEnvDuration("WARMUP_DURATION", 30*time.Second)
+	pod := getEnv("POD_NAME", "cache-app")
+	start := time.Now()
+
+	// progress is how warm the cache is, from 0 to 100.
+	progress := func() int {
+		if warmup <= 0 {
+			return 100
+		}
+		return min(100, int(time.Since(start)*100/warmup))
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, _ *http.Request) {
+		if p := progress(); p < 100 {
+			// A cold cache: every request goes to the slow backend.
+			time.Sleep(500 * time.Millisecond)
+			fmt.Fprintf(w, "Hello from %s (cache %d%% warm, slow path)\n", pod, p)
+			return
+		}
+		fmt.Fprintf(w, "Hello from %s (cache warm)\n", pod)
+	})
+	// The readiness gate controller's check.
+	mux.HandleFunc("GET /warmup", func(w http.ResponseWriter, _ *http.Request) {
+		if p := progress(); p < 100 {
+			http.Error(w, fmt.Sprintf("cache %d%% warm", p), http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("cache warm\n"))
+	})
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) { w.Write([]byte("ok")) })
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, _ *http.Request) { w.Write([]byte("ok")) })
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	go func() {
+		ticker := time.NewTicker(5 * time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			p := progress()
+			fmt.Printf("Cache %d%% warm\n", p)
+			if p == 100 {
+				return
+			}
+		}
+	}()
+
+	server := &http.Server{Addr: listenAddr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Printf("Listening on %s; warming the cache for %s\n", listenAddr, warmup)
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		fmt.Printf("Error starting server: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("Shutting down...")
+}
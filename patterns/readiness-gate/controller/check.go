@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	neturl "net/url"
+	"strings"
+)
+
+// Checker asks a Pod whether it should get traffic: GET
+// http://<podIP>:<port><path>, and a 200 means yes. Anything else, and the
+// first line of the body says why not ("cache 40% warm").
+type Checker struct {
+	Client *http.Client
+}
+
+// Check returns nil when the Pod passes, or an error whose message goes in
+// the condition's message.
+func (c *Checker) Check(ctx context.Context, podIP, port, path string) error {
+	url := "http://" + net.JoinHostPort(podIP, port) + path
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		// Without the "Get \"http://10.244.0.7:8080/warmup\":" prefix: the
+		// condition message names the path already.
+		var uerr *neturl.Error
+		if errors.As(err, &uerr) {
+			err = uerr.Err
+		}
+		return fmt.Errorf("GET %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+	reason, _, _ := strings.Cut(strings.TrimSpace(string(body)), "\n")
+	if reason == "" {
+		return fmt.Errorf("GET %s: %s", path, resp.Status)
+	}
+	return fmt.Errorf("GET %s: %s: %s", path, resp.Status, reason)
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+const (
+	// gateCondition is the readiness gate. A Pod that lists it in
+	// spec.readinessGates stays unready, whatever its probes say, until a
+	// condition of this type in its status is True. The kubelet never sets
+	// it: that is this controller's job.
+	gateCondition corev1.PodConditionType = "mydomain.com/traffic-approved"
+
+	// approvalLabel opts a Pod in: "mydomain.com/traffic-approval: enabled".
+	// The cache only holds Pods with it (see main.go); the gate alone can't
+	// be selected on.
+	approvalLabel = "mydomain.com/traffic-approval"
+
+	// Where the check goes: GET http://<podIP>:<port><path>.
+	checkPortAnnotation = "mydomain.com/traffic-check-port"
+	checkPathAnnotation = "mydomain.com/traffic-check-path"
+)
+
+// PodReconciler sets the gate's condition on each opted-in Pod: False until
+// its containers are ready and its check passes, then True for as long as
+// those containers keep running.
+type PodReconciler struct {
+	client.Client
+	Recorder record.EventRecorder
+	Checker  *Checker
+	// CheckInterval is how often a Pod that hasn't passed yet is checked.
+	CheckInterval time.Duration
+	Workers       int
+}
+
+func (r *PodReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+
+	var pod corev1.Pod
+	if err := r.Get(ctx, req.NamespacedName, &pod); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !pod.DeletionTimestamp.IsZero() || !hasGate(&pod) {
+		return ctrl.Result{}, nil
+	}
+	current := podCondition(&pod, gateCondition)
+
+	// A container that (re)started has an empty cache, whatever it had
+	// warmed before: back to False, and check again once it's up.
+	containersReady := podCondition(&pod, corev1.ContainersReady)
+	if containersReady == nil || containersReady.Status != corev1.ConditionTrue || pod.Status.PodIP == "" {
+		return ctrl.Result{}, r.setCondition(ctx, &pod, corev1.ConditionFalse, "ContainersNotReady", "Waiting for the containers to be ready")
+	}
+	// Approved: nothing to do until the containers restart. Readiness
+	// probes still take the Pod out of the Service if it stops serving.
+	if current != nil && current.Status == corev1.ConditionTrue {
+		return ctrl.Result{}, nil
+	}
+
+	port := pod.Annotations[checkPortAnnotation]
+	if port == "" {
+		// Not retried: the annotation has to change, and that's an update.
+		return ctrl.Result{}, r.setCondition(ctx, &pod, corev1.ConditionFalse, "Misconfigured",
+			fmt.Sprintf("The annotation %s is not set", checkPortAnnotation))
+	}
+	path := pod.Annotations[checkPathAnnotation]
+	if path == "" {
+		path = "/"
+	}
+
+	if err := r.Checker.Check(ctx, pod.Status.PodIP, port, path); err != nil {
+		checksTotal.WithLabelValues("failed").Inc()
+		if err := r.setCondition(ctx, &pod, corev1.ConditionFalse, "CheckFailed", err.Error()); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: r.CheckInterval}, nil
+	}
+	checksTotal.WithLabelValues("passed").Inc()
+	if err := r.setCondition(ctx, &pod, corev1.ConditionTrue, "CheckPassed", fmt.Sprintf("GET %s: 200 OK", path)); err != nil {
+		return ctrl.Result{}, err
+	}
+	waited := time.Since(containersReady.LastTransitionTime.Time)
+	approvalSeconds.Observe(waited.Seconds())
+	r.Recorder.Eventf(&pod, corev1.EventTypeNormal, "TrafficApproved", "GET %s passed %s after the containers were ready", path, waited.Round(time.Second))
+	log.Info("Approved", "pod", req.NamespacedName, "after", waited.Round(time.Second))
+	return ctrl.Result{}, nil
+}
+
+// setCondition writes the gate's condition, if it changed. It's a
+// strategic merge patch on pods/status: conditions merge by type, so the
+// kubelet's own conditions, written concurrently, are left alone. A JSON
+// merge patch would replace the whole list.
+func (r *PodReconciler) setCondition(ctx context.Context, pod *corev1.Pod, status corev1.ConditionStatus, reason, message string) error {
+	current := podCondition(pod, gateCondition)
+	if current != nil && current.Status == status && current.Reason == reason && current.Message == message {
+		return nil
+	}
+	patch := client.StrategicMergeFrom(pod.DeepCopy())
+	now := metav1.Now()
+	cond := corev1.PodCondition{Type: gateCondition, Status: status, Reason: reason, Message: message, LastProbeTime: now, LastTransitionTime: now}
+	if current != nil {
+		if current.Status == status {
+			cond.LastTransitionTime = current.LastTransitionTime
+		}
+		*current = cond
+	} else {
+		pod.Status.Conditions = append(pod.Status.Conditions, cond)
+	}
+	return r.Status().Patch(ctx, pod, patch)
+}
+
+func hasGate(pod *corev1.Pod) bool {
+	for _, g := range pod.Spec.ReadinessGates {
+		if g.ConditionType == gateCondition {
+			return true
+		}
+	}
+	return false
+}
+
+func podCondition(pod *corev1.Pod, t corev1.PodConditionType) *corev1.PodCondition {
+	for i := range pod.Status.Conditions {
+		if pod.Status.Conditions[i].Type == t {
+			return &pod.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// relevantUpdate filters Pod updates down to what the controller acts on.
+// Its own patches change the gate's message ("cache 40% warm", then 45%),
+// and must not trigger a check right away: failing Pods are checked every
+// CheckInterval, not as fast as the API server can echo a patch.
+func relevantUpdate(e event.UpdateEvent) bool {
+	before, after := e.ObjectOld.(*corev1.Pod), e.ObjectNew.(*corev1.Pod)
+	status := func(pod *corev1.Pod, t corev1.PodConditionType) corev1.ConditionStatus {
+		if c := podCondition(pod, t); c != nil {
+			return c.Status
+		}
+		return ""
+	}
+	return status(before, corev1.ContainersReady) != status(after, corev1.ContainersReady) ||
+		status(before, gateCondition) != status(after, gateCondition) ||
+		before.Status.PodIP != after.Status.PodIP ||
+		before.Annotations[checkPortAnnotation] != after.Annotations[checkPortAnnotation] ||
+		before.Annotations[checkPathAnnotation] != after.Annotations[checkPathAnnotation]
+}
+
+// SetupWithManager watches the opted-in Pods. Checks block a worker for up
+// to the check timeout, so several run at once.
+func (r *PodReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&corev1.Pod{}, builder.WithPredicates(predicate.Funcs{UpdateFunc: relevantUpdate})).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.Workers}).
+		Named("readiness-gate").
+		Complete(r)
+}
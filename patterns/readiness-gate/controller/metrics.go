@@ -0,0 +1,25 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Metrics go to controller-runtime's registry, which the Manager serves on
+// its /metrics next to the controller_runtime_* and workqueue_* metrics.
+var (
+	checksTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "readiness_gate_checks_total",
+		Help: "Checks against Pods, by result (passed, failed).",
+	}, []string{"result"})
+
+	approvalSeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "readiness_gate_approval_seconds",
+		Help:    "Time from ContainersReady to the gate's condition turning True.",
+		Buckets: prometheus.ExponentialBuckets(0.5, 2, 10),
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(checksTotal, approvalSeconds)
+}
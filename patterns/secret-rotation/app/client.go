@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+var errAuth = errors.New("authentication failed")
+
+// Client holds one authenticated connection to the backend, and knows which
+// credential generation it was opened with.
+type Client struct {
+	Addr    string
+	Timeout time.Duration
+
+	mu          sync.Mutex
+	conn        net.Conn
+	r           *bufio.Reader
+	generation  int
+	fingerprint string
+	connectedAt time.Time
+	lastErr     error
+}
+
+// ClientStatus is what /status shows about the connection.
+type ClientStatus struct {
+	Connected   bool      `json:"connected"`
+	Generation  int       `json:"generation"`
+	Fingerprint string    `json:"fingerprint,omitempty"`
+	ConnectedAt time.Time `json:"connectedAt,omitzero"`
+	LastError   string    `json:"lastError,omitempty"`
+}
+
+func (c *Client) Status() ClientStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st := ClientStatus{Connected: c.conn != nil, Generation: c.generation, Fingerprint: c.fingerprint, ConnectedAt: c.connectedAt}
+	if c.lastErr != nil {
+		st.LastError = c.lastErr.Error()
+	}
+	return st
+}
+
+// Generation is the credential generation of the open connection, 0 if
+// there is none.
+func (c *Client) Generation() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return 0
+	}
+	return c.generation
+}
+
+func (c *Client) dial(cred Credential) (net.Conn, *bufio.Reader, error) {
+	conn, err := net.DialTimeout("tcp", c.Addr, c.Timeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	r := bufio.NewReader(conn)
+	reply, err := c.roundTrip(conn, r, fmt.Sprintf("AUTH %s %s", cred.Username, cred.Password))
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	if reply != "OK" {
+		conn.Close()
+		return nil, nil, fmt.Errorf("%w: %s", errAuth, reply)
+	}
+	return conn, r, nil
+}
+
+func (c *Client) roundTrip(conn net.Conn, r *bufio.Reader, line string) (string, error) {
+	conn.SetDeadline(time.Now().Add(c.Timeout))
+	if _, err := fmt.Fprintln(conn, line); err != nil {
+		return "", err
+	}
+	reply, err := r.ReadString('\n')
+	return strings.TrimSpace(reply), err
+}
+
+// Connect opens a connection with cred, and only then closes the old one:
+// make before break. If the backend refuses cred (it doesn't know the new
+// password yet), the old connection stays in use and the error says why.
+func (c *Client) Connect(cred Credential, reason string) error {
+	conn, r, err := c.dial(cred)
+	result := "ok"
+	switch {
+	case errors.Is(err, errAuth):
+		result = "auth_failed"
+	case err != nil:
+		result = "error"
+	}
+	connectsTotal.WithLabelValues(reason, result).Inc()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil {
+		c.lastErr = err
+		return err
+	}
+	old := c.conn
+	c.conn, c.r, c.generation, c.fingerprint, c.connectedAt, c.lastErr = conn, r, cred.Generation, cred.Fingerprint, time.Now(), nil
+	connectionGeneration.Set(float64(cred.Generation))
+	backendConnected.Set(1)
+	if old != nil {
+		old.Close()
+	}
+	return nil
+}
+
+// Ping uses the connection. Any failure drops it, so the next attempt
+// reconnects from scratch with the current credential.
+func (c *Client) Ping() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return errors.New("not connected")
+	}
+	reply, err := c.roundTrip(c.conn, c.r, "PING")
+	if err == nil && reply != "PONG" {
+		err = fmt.Errorf("backend: %s", reply)
+	}
+	if err != nil {
+		c.conn.Close()
+		c.conn, c.lastErr = nil, err
+		backendConnected.Set(0)
+		connectionGeneration.Set(0)
+		pingsTotal.WithLabelValues("error").Inc()
+		return err
+	}
+	pingsTotal.WithLabelValues("ok").Inc()
+	return nil
+}
+
+func (c *Client) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if value, ok := os.LookupEnv(key); ok {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+		fmt.Printf("Invalid %s=%q, using default %s\n", key, value, fallback)
+	}
+	return fallback
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// maintain keeps the connection on the newest credential. It runs on every
+// tick and right after a rotation:
+//
+//   - no connection: connect with the current credential;
+//   - connection on an older generation: open a new one, then close the old
+//     one. If the backend refuses the new credential, keep the old
+//     connection and try again next tick;
+//   - otherwise: use it (a PING), which also notices a revoked session.
+func maintain(watcher *SecretWatcher, client *Client, everConnected *bool) {
+	cred := watcher.Current()
+	switch gen := client.Generation(); {
+	case gen == 0:
+		reason := "initial"
+		if *everConnected {
+			reason = "reconnect"
+		}
+		if err := client.Connect(cred, reason); err != nil {
+			fmt.Printf("Connecting with generation %d: %s\n", cred.Generation, err)
+			return
+		}
+		*everConnected = true
+		fmt.Printf("Connected to %s with generation %d (%s)\n", client.Addr, cred.Generation, reason)
+	case gen != cred.Generation:
+		if err := client.Connect(cred, "rotation"); err != nil {
+			fmt.Printf("Backend refuses generation %d (%s); still on generation %d\n", cred.Generation, err, gen)
+		} else {
+			fmt.Printf("Rotated: reconnected with generation %d, closed the generation %d connection\n", cred.Generation, gen)
+		}
+	}
+	if err := client.Ping(); err != nil && client.Generation() == 0 {
+		fmt.Printf("Backend connection lost: %s\n", err)
+	}
+}
+
+func main() {
+	listenAddr := getEnv("LISTEN_ADDR", ":8080")
+	dir := getEnv("CREDENTIALS_DIR", "/etc/credentials")
+
+	watcher, err := NewSecretWatcher(dir)
+	if err != nil {
+		fmt.Printf("Error reading credentials from %s: %s\n", dir, err)
+		os.Exit(1)
+	}
+	client := &Client{Addr: getEnv("BACKEND_ADDR", "secret-backend:5433"), Timeout: 3 * time.Second}
+	defer client.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	if err := watcher.Run(ctx, getEnvDuration("RESYNC_INTERVAL", time.Minute)); err != nil {
+		fmt.Printf("Error watching %s: %s\n", dir, err)
+		os.Exit(1)
+	}
+
+	go func() {
+		ticker := time.NewTicker(getEnvDuration("PING_INTERVAL", 2*time.Second))
+		defer ticker.Stop()
+		everConnected := false
+		maintain(watcher, client, &everConnected)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-watcher.Changed():
+			case <-ticker.C:
+			}
+			maintain(watcher, client, &everConnected)
+		}
+	}()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, _ *http.Request) {
+		cred := watcher.Current()
+		writeJSON(w, http.StatusOK, map[string]any{
+			"secret": map[string]any{
+				"generation":  cred.Generation,
+				"version":     cred.Version,
+				"fingerprint": cred.Fingerprint,
+				"loadedAt":    cred.LoadedAt,
+			},
+			"connection": client.Status(),
+		})
+	})
+	mux.Handle("GET /metrics", promhttp.Handler())
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) { w.Write([]byte("ok")) })
+	// Ready while connected, on any generation: a rotation the backend
+	// hasn't caught up with yet is not a reason to stop serving.
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, _ *http.Request) {
+		if client.Generation() == 0 {
+			http.Error(w, "no backend connection", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+
+	server := &http.Server{Addr: listenAddr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Printf("Listening on %s, credentials from %s\n", listenAddr, dir)
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		fmt.Printf("Error starting server: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("Shutting down...")
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Metrics are registered with the default registry via 'promauto'. A
+// rotation is done when backend_connection_generation catches up with
+// secret_generation; alert when it doesn't within a few minutes.
+var (
+	secretGeneration = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "secret_generation",
+		Help: "Credential versions seen since start: 1, then +1 per rotation.",
+	})
+
+	secretLastRotation = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "secret_last_rotation_timestamp_seconds",
+		Help: "When the current credential was loaded.",
+	})
+
+	connectionGeneration = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "backend_connection_generation",
+		Help: "Credential generation the backend connection was opened with; 0 when disconnected.",
+	})
+
+	backendConnected = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "backend_connected",
+		Help: "1 while an authenticated backend connection is open.",
+	})
+
+	connectsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "backend_connects_total",
+		Help: "Connection attempts by reason (initial, rotation, reconnect) and result (ok, auth_failed, error).",
+	}, []string{"reason", "result"})
+
+	pingsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "backend_pings_total",
+		Help: "Requests over the backend connection, by result.",
+	}, []string{"result"})
+)
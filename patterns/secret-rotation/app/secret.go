@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Credential is one version of the mounted Secret.
+type Credential struct {
+	Username    string
+	Password    string
+	Version     string // the ..data target: which write of the volume this is
+	Fingerprint string // a short hash of username and password, safe to log
+	Generation  int    // 1 for the credential found at start, +1 per rotation
+	LoadedAt    time.Time
+}
+
+// readCredential reads the username and password keys. The kubelet swaps
+// the whole volume at once (see README §3.A), so the two always belong to
+// the same version of the Secret; ..data is read before and after to make
+// sure a swap didn't happen in between.
+func readCredential(dir string) (Credential, error) {
+	for range 3 {
+		before, _ := os.Readlink(filepath.Join(dir, "..data"))
+		username, err := os.ReadFile(filepath.Join(dir, "username"))
+		if err != nil {
+			return Credential{}, err
+		}
+		password, err := os.ReadFile(filepath.Join(dir, "password"))
+		if err != nil {
+			return Credential{}, err
+		}
+		after, _ := os.Readlink(filepath.Join(dir, "..data"))
+		if before != after {
+			continue
+		}
+		c := Credential{
+			Username: strings.TrimSpace(string(username)),
+			Password: strings.TrimSpace(string(password)),
+			Version:  after,
+		}
+		if c.Username == "" || c.Password == "" {
+			return Credential{}, errors.New("username or password is empty")
+		}
+		sum := sha256.Sum256([]byte(c.Username + "\x00" + c.Password))
+		c.Fingerprint = hex.EncodeToString(sum[:4])
+		return c, nil
+	}
+	return Credential{}, errors.New("the volume kept changing while it was read")
+}
+
+// SecretWatcher keeps the newest credential from a Secret volume and
+// signals when it changes.
+type SecretWatcher struct {
+	Dir string
+
+	mu      sync.Mutex
+	current Credential
+	changed chan struct{} // a rotation happened; buffered, never blocks
+}
+
+// NewSecretWatcher reads the credential once. Without one there is nothing
+// to connect with, so an error here is fatal to the caller.
+func NewSecretWatcher(dir string) (*SecretWatcher, error) {
+	c, err := readCredential(dir)
+	if err != nil {
+		return nil, err
+	}
+	c.Generation, c.LoadedAt = 1, time.Now()
+	secretGeneration.Set(1)
+	secretLastRotation.SetToCurrentTime()
+	fmt.Printf("Loaded credential for %q (fingerprint %s, version %s)\n", c.Username, c.Fingerprint, c.Version)
+	return &SecretWatcher{Dir: dir, current: c, changed: make(chan struct{}, 1)}, nil
+}
+
+func (w *SecretWatcher) Current() Credential {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.current
+}
+
+func (w *SecretWatcher) Changed() <-chan struct{} { return w.changed }
+
+func (w *SecretWatcher) reload() {
+	c, err := readCredential(w.Dir)
+	if err != nil {
+		fmt.Printf("Ignoring an unreadable Secret update, keeping the current credential: %s\n", err)
+		return
+	}
+	w.mu.Lock()
+	prev := w.current
+	if c.Fingerprint == prev.Fingerprint {
+		// A swap with the same content: e.g. another key of the Secret
+		// changed, or the kubelet rewrote it after a restart.
+		w.current.Version = c.Version
+		w.mu.Unlock()
+		return
+	}
+	c.Generation, c.LoadedAt = prev.Generation+1, time.Now()
+	w.current = c
+	w.mu.Unlock()
+
+	secretGeneration.Set(float64(c.Generation))
+	secretLastRotation.SetToCurrentTime()
+	fmt.Printf("Secret rotated: generation %d, fingerprint %s → %s (version %s)\n", c.Generation, prev.Fingerprint, c.Fingerprint, c.Version)
+	select {
+	case w.changed <- struct{}{}:
+	default:
+	}
+}
+
+// Run watches the mount directory. The key files are symlinks through
+// ..data, which the kubelet swaps with a rename when the Secret changes, so
+// the directory is what emits events; they come in bursts and are
+// debounced. A slow resync poll backs up the watch: inotify events can be
+// dropped (the queue overflows), and a rotation must never be missed.
+func (w *SecretWatcher) Run(ctx context.Context, resync time.Duration) error {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	if err := fw.Add(w.Dir); err != nil {
+		fw.Close()
+		return err
+	}
+	go func() {
+		defer fw.Close()
+		poll := time.NewTicker(resync)
+		defer poll.Stop()
+		var debounce <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev := <-fw.Events:
+				if !ev.Has(fsnotify.Chmod) {
+					debounce = time.After(100 * time.Millisecond)
+				}
+			case err := <-fw.Errors:
+				fmt.Printf("Watch error (the resync poll covers it): %s\n", err)
+			case <-debounce:
+				w.reload()
+			case <-poll.C:
+				w.reload()
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,3 @@
+module secret-backend
+
+go 1.24.3
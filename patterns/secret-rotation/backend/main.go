@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if value, ok := os.LookupEnv(key); ok {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+		fmt.Printf("Invalid %s=%q, using default %s\n", key, value, fallback)
+	}
+	return fallback
+}
+
+// Credentials are the ones the backend accepts: the current password and,
+// during a rotation, the previous one. Dropping previous-password from the
+// Secret revokes it, and sessions still using it are closed.
+type Credentials struct {
+	mu       sync.RWMutex
+	username string
+	accepted []string
+}
+
+func (c *Credentials) load(dir string) error {
+	read := func(name string) string {
+		b, _ := os.ReadFile(filepath.Join(dir, name))
+		return strings.TrimSpace(string(b))
+	}
+	username, current, previous := read("username"), read("password"), read("previous-password")
+	if username == "" || current == "" {
+		return fmt.Errorf("%s must hold username and password", dir)
+	}
+	accepted := []string{current}
+	if previous != "" {
+		accepted = append(accepted, previous)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if username != c.username || strings.Join(accepted, "\x00") != strings.Join(c.accepted, "\x00") {
+		fmt.Printf("Accepting %d password(s) for %q\n", len(accepted), username)
+	}
+	c.username, c.accepted = username, accepted
+	return nil
+}
+
+// Valid compares in constant time, so response timing doesn't leak how
+// much of a guess was right.
+func (c *Credentials) Valid(username, password string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if subtle.ConstantTimeCompare([]byte(username), []byte(c.username)) != 1 {
+		return false
+	}
+	for _, p := range c.accepted {
+		if subtle.ConstantTimeCompare([]byte(password), []byte(p)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// serve speaks a tiny line protocol, standing in for a database:
+//
+//	AUTH <user> <password>  → OK | ERR auth failed
+//	PING                    → PONG, or ERR credentials revoked (and close)
+//
+// The session's credentials are re-checked on every command, so revoking a
+// password ends the sessions that use it, as many databases can be told to.
+func serve(conn net.Conn, creds *Credentials) {
+	defer conn.Close()
+	remote := conn.RemoteAddr().String()
+	r := bufio.NewScanner(conn)
+	var user, pass string
+	authed := false
+	for r.Scan() {
+		cmd, args, _ := strings.Cut(strings.TrimSpace(r.Text()), " ")
+		switch {
+		case cmd == "AUTH":
+			u, p, _ := strings.Cut(args, " ")
+			if !creds.Valid(u, p) {
+				fmt.Fprintln(conn, "ERR auth failed")
+				fmt.Printf("%s: auth failed for %q\n", remote, u)
+				return
+			}
+			user, pass, authed = u, p, true
+			fmt.Fprintln(conn, "OK")
+			fmt.Printf("%s: authenticated as %q\n", remote, u)
+		case !authed:
+			fmt.Fprintln(conn, "ERR not authenticated")
+			return
+		case !creds.Valid(user, pass):
+			fmt.Fprintln(conn, "ERR credentials revoked")
+			fmt.Printf("%s: closing session, its password was revoked\n", remote)
+			return
+		case cmd == "PING":
+			fmt.Fprintln(conn, "PONG")
+		default:
+			fmt.Fprintln(conn, "ERR unknown command")
+		}
+	}
+}
+
+func main() {
+	listenAddr := getEnv("LISTEN_ADDR", ":5433")
+	dir := getEnv("CREDENTIALS_DIR", "/etc/backend-credentials")
+	creds := &Credentials{}
+	if err := creds.load(dir); err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	// Polling is plenty for the server side of the demo; the app shows the
+	// event-driven way.
+	go func() {
+		ticker := time.NewTicker(getEnvDuration("RELOAD_INTERVAL", 2*time.Second))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := creds.load(dir); err != nil {
+					fmt.Printf("Keeping the last credentials: %s\n", err)
+				}
+			}
+		}
+	}()
+
+	ln, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		fmt.Printf("Error starting server: %s\n", err)
+		os.Exit(1)
+	}
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+	fmt.Printf("Backend listening on %s\n", listenAddr)
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				fmt.Println("Shutting down...")
+				return
+			}
+			fmt.Printf("Accept error: %s\n", err)
+			continue
+		}
+		go serve(conn, creds)
+	}
+}
@@ -0,0 +1,3 @@
+module sidecar-app
+
+go 1.24.3
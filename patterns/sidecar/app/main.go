@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func getEnvInt(key string, fallback int) int {
+	if value, ok := os.LookupEnv(key); ok {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+		fmt.Printf("Invalid %s=%q, using default %d\n", key, value, fallback)
+	}
+	return fallback
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if value, ok := os.LookupEnv(key); ok {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+		fmt.Printf("Invalid %s=%q, using default %s\n", key, value, fallback)
+	}
+	return fallback
+}
+
+// LogFile is an append-only log that rotates itself like logrotate's
+// "create" mode: the full file is renamed to <name>.1 and a new one is
+// started under the original name. The shipper has to notice the swap.
+type LogFile struct {
+	Path     string
+	MaxBytes int64
+
+	f       *os.File
+	written int64
+}
+
+func (l *LogFile) open() error {
+	f, err := os.OpenFile(l.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.f, l.written = f, info.Size()
+	return nil
+}
+
+func (l *LogFile) Write(line string) error {
+	if l.f == nil {
+		if err := l.open(); err != nil {
+			return err
+		}
+	}
+	if l.MaxBytes > 0 && l.written+int64(len(line)) > l.MaxBytes {
+		l.f.Close()
+		// Only one old file is kept; an older .1 is overwritten
+		if err := os.Rename(l.Path, l.Path+".1"); err != nil {
+			return err
+		}
+		if err := l.open(); err != nil {
+			return err
+		}
+	}
+	n, err := l.f.WriteString(line)
+	l.written += int64(n)
+	return err
+}
+
+func (l *LogFile) Close() error {
+	if l.f == nil {
+		return nil
+	}
+	return l.f.Close()
+}
+
+var paths = []string{"/", "/cart", "/checkout", "/login", "/search"}
+
+// nextLine returns a logfmt line, the format the shipper parses. One request
+// in twenty fails with a Go-style stack trace, whose continuation lines the
+// shipper must fold into the same entry.
+func nextLine(now time.Time, seq int) string {
+	path := paths[rand.Intn(len(paths))]
+	took := time.Duration(5+rand.Intn(300)) * time.Millisecond
+	ts := now.UTC().Format(time.RFC3339Nano)
+	switch n := rand.Intn(20); {
+	case n == 0:
+		return fmt.Sprintf("ts=%s level=error msg=\"request failed\" path=%s request_id=%d took=%s\n"+
+			"goroutine 1 [running]:\n"+
+			"\tmain.handle(0xc000012345)\n"+
+			"\t\t/app/main.go:42 +0x1d\n", ts, path, seq, took)
+	case n < 4:
+		return fmt.Sprintf("ts=%s level=warn msg=\"slow request\" path=%s request_id=%d took=%s\n", ts, path, seq, took)
+	default:
+		return fmt.Sprintf("ts=%s level=info msg=\"request served\" path=%s status=200 request_id=%d took=%s\n",
+			ts, path, seq, took)
+	}
+}
+
+func main() {
+	logPath := getEnv("LOG_FILE", "/var/log/app/app.log")
+	interval := getEnvDuration("LOG_INTERVAL", 500*time.Millisecond)
+	out := &LogFile{Path: logPath, MaxBytes: int64(getEnvInt("LOG_MAX_BYTES", 64*1024))}
+
+	if err := os.MkdirAll(filepath.Dir(logPath), 0o755); err != nil {
+		fmt.Printf("Error creating log directory: %s\n", err)
+		os.Exit(1)
+	}
+	// The app's own stdout stays quiet: everything goes to the file, as a
+	// legacy app that can't log to stdout would do.
+	fmt.Printf("Writing logs to %s every %s (rotating at %d bytes)\n", logPath, interval, out.MaxBytes)
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for seq := 1; ; seq++ {
+		select {
+		case <-stop:
+			out.Write(fmt.Sprintf("ts=%s level=info msg=\"shutting down\"\n", time.Now().UTC().Format(time.RFC3339Nano)))
+			out.Close()
+			fmt.Println("Shutting down...")
+			return
+		case now := <-ticker.C:
+			if err := out.Write(nextLine(now, seq)); err != nil {
+				fmt.Printf("Error writing log: %s\n", err)
+			}
+		}
+	}
+}
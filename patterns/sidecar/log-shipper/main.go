@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func getEnvInt(key string, fallback int) int {
+	if value, ok := os.LookupEnv(key); ok {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+		fmt.Printf("Invalid %s=%q, using default %d\n", key, value, fallback)
+	}
+	return fallback
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if value, ok := os.LookupEnv(key); ok {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+		fmt.Printf("Invalid %s=%q, using default %s\n", key, value, fallback)
+	}
+	return fallback
+}
+
+// Batcher turns lines into entries and hands them to the shipper in
+// batches: when BatchSize entries are waiting, or FlushInterval after the
+// first one. Continuation lines are folded into the entry before them, which
+// is why an entry is only complete once the next line of its file arrives
+// (or the flush comes).
+type Batcher struct {
+	Shipper       Shipper
+	BatchSize     int
+	FlushInterval time.Duration
+
+	batch []Entry
+	// open is the last entry of each file, still taking continuation lines
+	open map[string]*Entry
+}
+
+// Run batches lines until the channel is closed, then ships what is left.
+// Shipping uses its own context, so the last batch still goes out while the
+// pod is terminating.
+func (b *Batcher) Run(lines <-chan Line) {
+	b.open = map[string]*Entry{}
+	ticker := time.NewTicker(b.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				b.flush()
+				return
+			}
+			b.add(line)
+			if len(b.batch) >= b.BatchSize {
+				b.flush()
+			}
+		case <-ticker.C:
+			b.flush()
+		}
+	}
+}
+
+func (b *Batcher) add(line Line) {
+	if prev := b.open[line.File]; prev != nil && continuation(line.Text) {
+		prev.Message += "\n" + line.Text
+		return
+	}
+	if prev := b.open[line.File]; prev != nil {
+		b.batch = append(b.batch, *prev)
+	}
+	e := Parse(line.Text, time.Now())
+	e.File = line.File
+	b.open[line.File] = &e
+}
+
+// flush ships the batch along with the open entries: a stack trace split by
+// a flush is better than one held back indefinitely.
+func (b *Batcher) flush() {
+	for file, e := range b.open {
+		b.batch = append(b.batch, *e)
+		delete(b.open, file)
+	}
+	if len(b.batch) == 0 {
+		return
+	}
+	sink := b.Shipper.Name()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	start := time.Now()
+	err := b.Shipper.Ship(ctx, b.batch)
+	shipDuration.WithLabelValues(sink).Observe(time.Since(start).Seconds())
+	if err != nil {
+		fmt.Printf("Error shipping %d entries to %s, dropping them: %s\n", len(b.batch), sink, err)
+		entriesDropped.WithLabelValues(sink).Add(float64(len(b.batch)))
+	} else {
+		entriesShipped.WithLabelValues(sink).Add(float64(len(b.batch)))
+	}
+	b.batch = b.batch[:0]
+}
+
+func main() {
+	files := strings.Split(getEnv("LOG_FILES", "/var/log/app/app.log"), ",")
+	metricsAddr := getEnv("METRICS_ADDR", ":9090")
+	pod := PodInfoFromEnv()
+
+	shipper, err := NewShipper(getEnv("SHIP_MODE", "stdout"), getEnv("LOKI_URL", ""), pod)
+	if err != nil {
+		fmt.Printf("Error configuring the shipper: %s\n", err)
+		os.Exit(1)
+	}
+	if loki, ok := shipper.(*LokiShipper); ok {
+		loki.Retries = getEnvInt("SHIP_RETRIES", 3)
+	}
+	batcher := &Batcher{
+		Shipper:       shipper,
+		BatchSize:     getEnvInt("BATCH_SIZE", 100),
+		FlushInterval: getEnvDuration("FLUSH_INTERVAL", 2*time.Second),
+	}
+	if batcher.BatchSize < 1 {
+		fmt.Printf("BATCH_SIZE must be at least 1, got %d\n", batcher.BatchSize)
+		os.Exit(1)
+	}
+
+	go func() {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.Handler())
+		mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) { w.Write([]byte("ok")) })
+		if err := http.ListenAndServe(metricsAddr, mux); err != nil {
+			fmt.Printf("Error starting metrics server: %s\n", err)
+		}
+	}()
+	fmt.Printf("Shipping %v to %s as %s/%s (container %s), metrics on %s/metrics\n",
+		files, shipper.Name(), pod.Namespace, pod.Pod, pod.Container, metricsAddr)
+
+	// SIGTERM stops the tailers; each reads its file one last time, the
+	// batcher ships what they sent, and only then does the process exit.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+	lines := make(chan Line, 1000)
+	var tailers sync.WaitGroup
+	for _, file := range files {
+		t := &Tailer{
+			Path:         strings.TrimSpace(file),
+			PollInterval: getEnvDuration("POLL_INTERVAL", 250*time.Millisecond),
+			FromStart:    true,
+		}
+		tailers.Add(1)
+		go func() {
+			defer tailers.Done()
+			t.Run(ctx, lines)
+		}()
+	}
+	go func() {
+		tailers.Wait()
+		close(lines)
+	}()
+	batcher.Run(lines)
+	fmt.Println("Shutting down...")
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// The shipper's own health: a log pipeline that silently stops shipping is
+// worse than none, so what it read, sent and dropped is counted.
+var (
+	linesRead = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "log_shipper_lines_read_total",
+		Help: "Lines read from the shared volume, by file.",
+	}, []string{"file"})
+
+	tailerRotations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "log_shipper_rotations_total",
+		Help: "Times a file was found rotated or truncated, by file.",
+	}, []string{"file"})
+
+	tailerErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "log_shipper_read_errors_total",
+		Help: "Errors opening or reading a file, by file.",
+	}, []string{"file"})
+
+	entriesShipped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "log_shipper_entries_shipped_total",
+		Help: "Entries delivered, by sink.",
+	}, []string{"sink"})
+
+	entriesDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "log_shipper_entries_dropped_total",
+		Help: "Entries given up on after the sink kept failing, by sink.",
+	}, []string{"sink"})
+
+	shipDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "log_shipper_ship_duration_seconds",
+		Help:    "Time to deliver a batch, retries included, by sink.",
+		Buckets: []float64{.001, .005, .01, .05, .1, .5, 1, 5, 10},
+	}, []string{"sink"})
+)
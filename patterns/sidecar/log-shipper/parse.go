@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Entry is a parsed log event: one line, or several when continuation lines
+// (a stack trace) were folded into it.
+type Entry struct {
+	Time    time.Time
+	Level   string
+	Message string
+	Fields  map[string]string
+	File    string
+}
+
+// Parse turns a line into an Entry. It understands, in order:
+//   - JSON objects, as written by most structured loggers;
+//   - logfmt (key=value pairs, values optionally quoted);
+//   - anything else, kept whole as the message.
+//
+// The well-known keys (time/ts, level/lvl, msg/message) become the Entry's
+// own fields; the rest are kept in Fields. Without a timestamp of its own,
+// the entry is stamped with now.
+func Parse(line string, now time.Time) Entry {
+	e := Entry{Time: now, Fields: map[string]string{}}
+	fields, ok := parseJSON(line)
+	if !ok {
+		fields, ok = parseLogfmt(line)
+	}
+	if !ok {
+		e.Message = line
+		return e
+	}
+	for key, value := range fields {
+		switch strings.ToLower(key) {
+		case "time", "ts", "timestamp":
+			if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+				e.Time = t
+				continue
+			}
+		case "level", "lvl", "severity":
+			e.Level = strings.ToLower(value)
+			continue
+		case "msg", "message":
+			e.Message = value
+			continue
+		}
+		e.Fields[key] = value
+	}
+	return e
+}
+
+// parseJSON reads a JSON object, flattening its values to strings.
+func parseJSON(line string) (map[string]string, bool) {
+	if !strings.HasPrefix(strings.TrimSpace(line), "{") {
+		return nil, false
+	}
+	var obj map[string]any
+	if err := json.Unmarshal([]byte(line), &obj); err != nil {
+		return nil, false
+	}
+	fields := make(map[string]string, len(obj))
+	for key, value := range obj {
+		switch v := value.(type) {
+		case string:
+			fields[key] = v
+		case nil:
+			fields[key] = ""
+		default:
+			b, _ := json.Marshal(v)
+			fields[key] = string(b)
+		}
+	}
+	return fields, true
+}
+
+// parseLogfmt reads key=value pairs separated by spaces. A line with any
+// word that isn't a pair isn't logfmt, and is left to the caller.
+func parseLogfmt(line string) (map[string]string, bool) {
+	fields := map[string]string{}
+	rest := strings.TrimSpace(line)
+	for rest != "" {
+		eq := strings.IndexByte(rest, '=')
+		if eq <= 0 || strings.IndexFunc(rest[:eq], unicode.IsSpace) >= 0 {
+			return nil, false
+		}
+		key := rest[:eq]
+		rest = rest[eq+1:]
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := closingQuote(rest)
+			if end < 0 {
+				return nil, false
+			}
+			var err error
+			if value, err = strconv.Unquote(rest[:end+1]); err != nil {
+				return nil, false
+			}
+			rest = rest[end+1:]
+		} else {
+			end := strings.IndexFunc(rest, unicode.IsSpace)
+			if end < 0 {
+				end = len(rest)
+			}
+			value, rest = rest[:end], rest[end:]
+		}
+		fields[key] = value
+		rest = strings.TrimLeftFunc(rest, unicode.IsSpace)
+	}
+	return fields, len(fields) > 0
+}
+
+// closingQuote returns the index of the quote closing the string s starts
+// with, skipping escaped ones, or -1.
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// continuation reports whether line belongs to the entry before it: stack
+// trace frames and wrapped messages are indented, and Go's traces start
+// with "goroutine N [...]:".
+func continuation(line string) bool {
+	return strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") ||
+		strings.HasPrefix(line, "goroutine ") || strings.HasPrefix(line, "Caused by:")
+}
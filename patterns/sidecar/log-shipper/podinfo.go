@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PodInfo is what the Downward API tells the shipper about the pod it runs
+// in. The pod's name, namespace, node and IP come in as environment
+// variables (fieldRef), fixed for the pod's life. Labels and annotations
+// come as files in a downwardAPI volume, which the kubelet rewrites when
+// they change, so they are read again when the file does.
+type PodInfo struct {
+	Namespace string
+	Pod       string
+	Node      string
+	IP        string
+	Container string
+	// Dir is the downwardAPI volume's mount path, with "labels" and
+	// "annotations" files; empty to go without.
+	Dir string
+
+	mu          sync.Mutex
+	modTime     time.Time
+	labels      map[string]string
+	annotations map[string]string
+}
+
+// PodInfoFromEnv reads the fieldRef environment variables of the manifest.
+func PodInfoFromEnv() *PodInfo {
+	return &PodInfo{
+		Namespace: getEnv("POD_NAMESPACE", ""),
+		Pod:       getEnv("POD_NAME", ""),
+		Node:      getEnv("NODE_NAME", ""),
+		IP:        getEnv("POD_IP", ""),
+		Container: getEnv("APP_CONTAINER", "app"),
+		Dir:       getEnv("PODINFO_DIR", "/etc/podinfo"),
+	}
+}
+
+// Labels returns the pod's labels and annotations, reloaded if the kubelet
+// has rewritten the files since they were last read.
+func (p *PodInfo) Labels() (labels, annotations map[string]string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.Dir == "" {
+		return nil, nil
+	}
+	// The kubelet swaps the whole directory with a symlink (..data), like
+	// for ConfigMap volumes; the file's mtime changes with it.
+	info, err := os.Stat(filepath.Join(p.Dir, "labels"))
+	if err != nil || info.ModTime().Equal(p.modTime) {
+		return p.labels, p.annotations
+	}
+	p.modTime = info.ModTime()
+	p.labels = readDownwardFile(filepath.Join(p.Dir, "labels"))
+	p.annotations = readDownwardFile(filepath.Join(p.Dir, "annotations"))
+	return p.labels, p.annotations
+}
+
+// readDownwardFile parses the Downward API's key="value" lines. The values
+// are quoted and escaped like Go strings.
+func readDownwardFile(path string) map[string]string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	values := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, quoted, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		value, err := strconv.Unquote(quoted)
+		if err != nil {
+			value = quoted
+		}
+		values[key] = value
+	}
+	return values
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Shipper delivers a batch of entries to a sink.
+type Shipper interface {
+	Ship(ctx context.Context, entries []Entry) error
+	Name() string
+}
+
+// NewShipper returns the shipper for mode: "stdout" or "loki".
+func NewShipper(mode, url string, pod *PodInfo) (Shipper, error) {
+	switch mode {
+	case "stdout":
+		return &StdoutShipper{Out: os.Stdout, Pod: pod}, nil
+	case "loki":
+		if url == "" {
+			return nil, fmt.Errorf("SHIP_MODE=loki needs LOKI_URL")
+		}
+		return &LokiShipper{URL: url, Client: &http.Client{Timeout: 10 * time.Second}, Pod: pod}, nil
+	}
+	return nil, fmt.Errorf("unknown SHIP_MODE %q (want stdout or loki)", mode)
+}
+
+// record is an entry as shipped: the parsed line plus where it came from.
+type record struct {
+	Time       time.Time         `json:"time"`
+	Level      string            `json:"level,omitempty"`
+	Message    string            `json:"message"`
+	Fields     map[string]string `json:"fields,omitempty"`
+	File       string            `json:"file"`
+	Kubernetes kubernetesMeta    `json:"kubernetes"`
+}
+
+type kubernetesMeta struct {
+	Namespace   string            `json:"namespace,omitempty"`
+	Pod         string            `json:"pod,omitempty"`
+	Node        string            `json:"node,omitempty"`
+	PodIP       string            `json:"pod_ip,omitempty"`
+	Container   string            `json:"container,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+func (p *PodInfo) meta() kubernetesMeta {
+	labels, annotations := p.Labels()
+	return kubernetesMeta{
+		Namespace: p.Namespace, Pod: p.Pod, Node: p.Node, PodIP: p.IP, Container: p.Container,
+		Labels: labels, Annotations: annotations,
+	}
+}
+
+// StdoutShipper writes one JSON object per entry to Out. In a cluster,
+// that's the shipper container's log, which the node's log agent (see
+// patterns/daemonset-collector) picks up: the file the app wrote is turned
+// into structured stdout, the format the rest of the platform expects.
+type StdoutShipper struct {
+	Out io.Writer
+	Pod *PodInfo
+}
+
+func (s *StdoutShipper) Name() string { return "stdout" }
+
+func (s *StdoutShipper) Ship(_ context.Context, entries []Entry) error {
+	meta := s.Pod.meta()
+	enc := json.NewEncoder(s.Out)
+	for _, e := range entries {
+		if err := enc.Encode(record{
+			Time: e.Time, Level: e.Level, Message: e.Message, Fields: e.Fields, File: e.File, Kubernetes: meta,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LokiShipper pushes to Loki's /loki/api/v1/push JSON API. The pod metadata
+// becomes stream labels, indexed by Loki; the entry's own fields stay in the
+// line, as high-cardinality values (request IDs) make poor labels.
+type LokiShipper struct {
+	URL    string
+	Client *http.Client
+	Pod    *PodInfo
+	// Retries is how many times a failed push is tried again, with a
+	// doubling delay from one second. Past that the batch is dropped: the
+	// app's file keeps growing meanwhile, and an unbounded buffer would
+	// take the pod's memory limit down with it.
+	Retries int
+}
+
+type lokiPush struct {
+	Streams []lokiStream `json:"streams"`
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+func (s *LokiShipper) Name() string { return "loki" }
+
+func (s *LokiShipper) Ship(ctx context.Context, entries []Entry) error {
+	body, err := json.Marshal(s.push(entries))
+	if err != nil {
+		return err
+	}
+	delay := time.Second
+	for attempt := 0; ; attempt++ {
+		retry, err := s.post(ctx, body)
+		if err == nil || !retry || attempt >= s.Retries {
+			return err
+		}
+		fmt.Printf("Error pushing to Loki (retrying in %s): %s\n", delay, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// push groups the entries into one stream per level: the other labels are
+// the same for the whole pod.
+func (s *LokiShipper) push(entries []Entry) lokiPush {
+	labels, _ := s.Pod.Labels()
+	// Indexes into push.Streams, which moves as it grows
+	streams := map[string]int{}
+	var push lokiPush
+	for _, e := range entries {
+		level := e.Level
+		if level == "" {
+			level = "unknown"
+		}
+		i, ok := streams[level]
+		if !ok {
+			stream := map[string]string{
+				"namespace": s.Pod.Namespace,
+				"pod":       s.Pod.Pod,
+				"container": s.Pod.Container,
+				"node":      s.Pod.Node,
+				"level":     level,
+			}
+			// The app label ties the stream to its Deployment
+			if app, ok := labels["app"]; ok {
+				stream["app"] = app
+			}
+			i = len(push.Streams)
+			push.Streams = append(push.Streams, lokiStream{Stream: stream})
+			streams[level] = i
+		}
+		line := map[string]string{"msg": e.Message, "file": e.File}
+		for key, value := range e.Fields {
+			line[key] = value
+		}
+		text, _ := json.Marshal(line)
+		push.Streams[i].Values = append(push.Streams[i].Values, [2]string{strconv.FormatInt(e.Time.UnixNano(), 10), string(text)})
+	}
+	return push
+}
+
+// post sends one push, and reports whether a failure is worth retrying:
+// network errors, 429 and 5xx are; other answers won't change.
+func (s *LokiShipper) post(ctx context.Context, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		return false, nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500, err
+}
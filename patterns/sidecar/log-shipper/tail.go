@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+// Line is one line read from a log file, without its newline.
+type Line struct {
+	File string
+	Text string
+}
+
+// Tailer follows a file like "tail -F": it survives the file not existing
+// yet, being truncated, and being rotated (renamed away and recreated).
+//
+// Rotation is detected by comparing the open file with whatever is at Path
+// now (os.SameFile compares device and inode). The old file is read to its
+// end before switching, so the lines written just before the rename aren't
+// lost.
+type Tailer struct {
+	Path         string
+	PollInterval time.Duration
+	// FromStart reads an existing file from the beginning. The shipper
+	// starts with an empty emptyDir, so there's nothing to skip; a restart
+	// of the shipper container alone would ship the file again, though.
+	FromStart bool
+
+	f      *os.File
+	r      *bufio.Reader
+	offset int64
+	// partial is a line whose newline hasn't been written yet
+	partial string
+}
+
+// Run sends every complete line to lines until ctx is done. After that, it
+// reads what is left in the file once more, so a shutdown doesn't drop the
+// app's last words, and returns.
+func (t *Tailer) Run(ctx context.Context, lines chan<- Line) {
+	defer t.close()
+	for {
+		t.readAvailable(lines)
+		select {
+		case <-ctx.Done():
+			t.readAvailable(lines)
+			if t.partial != "" {
+				lines <- Line{File: t.Path, Text: t.partial}
+			}
+			return
+		case <-time.After(t.PollInterval):
+		}
+	}
+}
+
+// readAvailable reads up to the current end of the file, handling a
+// missing, truncated or rotated file on the way.
+func (t *Tailer) readAvailable(lines chan<- Line) {
+	if t.f == nil && !t.open(t.FromStart) {
+		return
+	}
+	t.drain(lines)
+
+	info, err := os.Stat(t.Path)
+	if err != nil {
+		// Renamed away and not recreated yet: keep the old one until it is
+		return
+	}
+	current, err := t.f.Stat()
+	if err != nil {
+		return
+	}
+	switch {
+	case !os.SameFile(info, current):
+		// Rotated: the old file was read to its end above. The new one is
+		// read from its start, as everything in it is new.
+		t.flushPartial(lines)
+		t.close()
+		tailerRotations.WithLabelValues(t.Path).Inc()
+		if t.open(true) {
+			t.drain(lines)
+		}
+	case info.Size() < t.offset:
+		// Truncated in place (copytruncate): start over
+		t.flushPartial(lines)
+		tailerRotations.WithLabelValues(t.Path).Inc()
+		if _, err := t.f.Seek(0, io.SeekStart); err == nil {
+			t.r.Reset(t.f)
+			t.offset = 0
+			t.drain(lines)
+		}
+	}
+}
+
+// open opens Path, at its start or end. It reports whether the file is
+// open, which it isn't when it doesn't exist yet.
+func (t *Tailer) open(fromStart bool) bool {
+	f, err := os.Open(t.Path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			tailerErrors.WithLabelValues(t.Path).Inc()
+		}
+		return false
+	}
+	t.offset = 0
+	if !fromStart {
+		if t.offset, err = f.Seek(0, io.SeekEnd); err != nil {
+			f.Close()
+			tailerErrors.WithLabelValues(t.Path).Inc()
+			return false
+		}
+	}
+	t.f, t.r = f, bufio.NewReader(f)
+	return true
+}
+
+// drain sends the complete lines up to the end of the open file. A line
+// without its newline yet is kept in partial, to be completed later.
+func (t *Tailer) drain(lines chan<- Line) {
+	for {
+		chunk, err := t.r.ReadString('\n')
+		t.offset += int64(len(chunk))
+		if err != nil {
+			t.partial += chunk
+			if !errors.Is(err, io.EOF) {
+				tailerErrors.WithLabelValues(t.Path).Inc()
+			}
+			return
+		}
+		text := t.partial + chunk[:len(chunk)-1]
+		t.partial = ""
+		linesRead.WithLabelValues(t.Path).Inc()
+		lines <- Line{File: t.Path, Text: text}
+	}
+}
+
+// flushPartial sends a last line that never got its newline: the file it
+// was in won't be written to again.
+func (t *Tailer) flushPartial(lines chan<- Line) {
+	if t.partial != "" {
+		lines <- Line{File: t.Path, Text: t.partial}
+		t.partial = ""
+	}
+}
+
+func (t *Tailer) close() {
+	if t.f != nil {
+		t.f.Close()
+		t.f, t.r = nil, nil
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Identity is who this Pod is within the StatefulSet. All of it is stable:
+// kv-2 is kv-2 across restarts, rescheduling to another node, and the PVC
+// it gets back (data-kv-2).
+type Identity struct {
+	Name      string // kv-2: the hostname, which a StatefulSet sets to the Pod name
+	Set       string // kv: the StatefulSet's name
+	Ordinal   int    // 2
+	Service   string // kv-headless: the governing Service (spec.serviceName)
+	Namespace string
+	Domain    string // the cluster domain, usually cluster.local
+}
+
+// ParseIdentity splits a StatefulSet Pod name into set and ordinal. Pod
+// names are always <statefulset>-<ordinal>; the set name may itself contain
+// dashes, so the ordinal is whatever follows the last one.
+func ParseIdentity(name, service, namespace, domain string) (Identity, error) {
+	i := strings.LastIndex(name, "-")
+	if i <= 0 {
+		return Identity{}, fmt.Errorf("hostname %q is not <statefulset>-<ordinal>: is this Pod part of a StatefulSet?", name)
+	}
+	ordinal, err := strconv.Atoi(name[i+1:])
+	if err != nil || ordinal < 0 {
+		return Identity{}, fmt.Errorf("hostname %q does not end in an ordinal: is this Pod part of a StatefulSet?", name)
+	}
+	return Identity{
+		Name:      name,
+		Set:       name[:i],
+		Ordinal:   ordinal,
+		Service:   service,
+		Namespace: namespace,
+		Domain:    domain,
+	}, nil
+}
+
+// PeerHost is the stable DNS name of ordinal n. The headless Service gives
+// each Pod of the set an A record <pod>.<service>.<namespace>.svc.<domain>,
+// which follows the Pod to whatever IP it gets next.
+func (id Identity) PeerHost(n int) string {
+	return fmt.Sprintf("%s-%d.%s.%s.svc.%s", id.Set, n, id.Service, id.Namespace, id.Domain)
+}
+
+// IsPrimary: ordinal 0 is the primary. No election is needed, because a
+// StatefulSet never runs two Pods with the same ordinal (see the README on
+// what that guarantee does and doesn't cover).
+func (id Identity) IsPrimary() bool { return id.Ordinal == 0 }
+
+// Peer is one member of the set, as found in DNS.
+type Peer struct {
+	Name    string `json:"name"`
+	Host    string `json:"host"`
+	Ordinal int    `json:"ordinal"`
+}
+
+// DiscoverPeers asks the headless Service's DNS for its members. An SRV
+// lookup on a named port returns one target per Pod, by its stable name,
+// so the result says who is who, not just which IPs exist. Because the
+// Service has publishNotReadyAddresses, Pods show up as soon as they have
+// an IP, before they pass readiness.
+func DiscoverPeers(ctx context.Context, id Identity, port string) ([]Peer, error) {
+	var r net.Resolver
+	_, addrs, err := r.LookupSRV(ctx, port, "tcp", fmt.Sprintf("%s.%s.svc.%s", id.Service, id.Namespace, id.Domain))
+	if err != nil {
+		return nil, err
+	}
+	var peers []Peer
+	for _, a := range addrs {
+		host := strings.TrimSuffix(a.Target, ".")
+		name, _, _ := strings.Cut(host, ".")
+		p, err := ParseIdentity(name, id.Service, id.Namespace, id.Domain)
+		if err != nil || p.Set != id.Set {
+			continue // not one of ours
+		}
+		peers = append(peers, Peer{Name: name, Host: host, Ordinal: p.Ordinal})
+	}
+	sort.Slice(peers, func(i, j int) bool { return peers[i].Ordinal < peers[j].Ordinal })
+	return peers, nil
+}
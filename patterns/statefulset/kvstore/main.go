@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func getEnvInt(key string, fallback int) int {
+	if value, ok := os.LookupEnv(key); ok {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+		fmt.Printf("Invalid %s=%q, using default %d\n", key, value, fallback)
+	}
+	return fallback
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if value, ok := os.LookupEnv(key); ok {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+		fmt.Printf("Invalid %s=%q, using default %s\n", key, value, fallback)
+	}
+	return fallback
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+type server struct {
+	id       Identity
+	port     string
+	log      *Log
+	replicas *Replicas // primary only
+	follower *Follower // replicas only
+
+	syncReplicas int
+	ackTimeout   time.Duration
+	maxLag       uint64
+}
+
+func (s *server) primaryURL() string {
+	return fmt.Sprintf("http://%s:%s", s.id.PeerHost(0), s.port)
+}
+
+func (s *server) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /kv/{key}", s.get)
+	mux.HandleFunc("PUT /kv/{key}", s.write)
+	mux.HandleFunc("DELETE /kv/{key}", s.write)
+	mux.HandleFunc("GET /status", s.status)
+	mux.HandleFunc("GET /peers", func(w http.ResponseWriter, r *http.Request) {
+		peers, err := DiscoverPeers(r.Context(), s.id, "http")
+		if err != nil {
+			writeJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, peers)
+	})
+	if s.id.IsPrimary() {
+		mux.HandleFunc("GET /replicate", replicateHandler(s.log, s.replicas, 500))
+	}
+	mux.Handle("GET /metrics", promhttp.Handler())
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) { w.Write([]byte("ok")) })
+	mux.HandleFunc("GET /readyz", s.ready)
+	return mux
+}
+
+// get serves reads from the local log, on any member. A replica's answer
+// may be behind the primary's by X-Replication-Lag entries.
+func (s *server) get(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("X-Served-By", s.id.Name)
+	w.Header().Set("X-Log-Index", strconv.FormatUint(s.log.LastIndex(), 10))
+	if s.follower != nil {
+		w.Header().Set("X-Replication-Lag", strconv.FormatUint(s.follower.Status().Lag, 10))
+	}
+	e, ok := s.log.Get(r.PathValue("key"))
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "no such key"})
+		return
+	}
+	writeJSON(w, http.StatusOK, e)
+}
+
+// write appends a PUT or DELETE to the log. Only the primary takes writes:
+// a replica redirects to ordinal 0 by its stable name, with 307 so the
+// client repeats the same method and body there.
+func (s *server) write(w http.ResponseWriter, r *http.Request) {
+	if !s.id.IsPrimary() {
+		writesTotal.WithLabelValues("redirected").Inc()
+		http.Redirect(w, r, s.primaryURL()+r.URL.RequestURI(), http.StatusTemporaryRedirect)
+		return
+	}
+	var value []byte
+	if r.Method == http.MethodPut {
+		var err error
+		if value, err = io.ReadAll(io.LimitReader(r.Body, 64<<10)); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+	}
+	e, err := s.log.Append(r.PathValue("key"), string(value), r.Method == http.MethodDelete)
+	if err != nil {
+		writesTotal.WithLabelValues("error").Inc()
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	logIndex.Set(float64(e.Index))
+
+	// SYNC_REPLICAS > 0: answer only once that many replicas have the
+	// entry, so losing the primary's disk loses no acknowledged write.
+	status := http.StatusOK
+	if s.syncReplicas > 0 {
+		ctx, cancel := context.WithTimeout(r.Context(), s.ackTimeout)
+		defer cancel()
+		n := s.replicas.WaitAcked(ctx, e.Index, s.syncReplicas)
+		w.Header().Set("X-Replicated-To", strconv.Itoa(n))
+		if n < s.syncReplicas {
+			// Written here, but not replicated in time. 202 tells the client
+			// it is not as durable as it asked for.
+			status = http.StatusAccepted
+		}
+	}
+	if status == http.StatusOK {
+		writesTotal.WithLabelValues("ok").Inc()
+	} else {
+		writesTotal.WithLabelValues("unreplicated").Inc()
+	}
+	writeJSON(w, status, e)
+}
+
+func (s *server) status(w http.ResponseWriter, r *http.Request) {
+	st := map[string]any{
+		"name":      s.id.Name,
+		"ordinal":   s.id.Ordinal,
+		"role":      "replica",
+		"primary":   s.id.PeerHost(0),
+		"lastIndex": s.log.LastIndex(),
+		"keys":      s.log.Keys(),
+	}
+	if s.id.IsPrimary() {
+		st["role"] = "primary"
+		st["replicas"] = s.replicas.Status()
+	} else {
+		st["replication"] = s.follower.Status()
+	}
+	writeJSON(w, http.StatusOK, st)
+}
+
+// ready: the primary once its log is loaded; a replica once it has heard
+// from the primary recently and is within MAX_LAG entries of it. With
+// OrderedReady, kv-2 isn't even created until kv-1 is ready, so a new
+// member joins only behind a caught-up one.
+func (s *server) ready(w http.ResponseWriter, _ *http.Request) {
+	if s.follower != nil {
+		st := s.follower.Status()
+		switch {
+		case st.Error != "" || time.Since(st.LastContact) > 30*time.Second:
+			http.Error(w, "not replicating from the primary: "+st.Error, http.StatusServiceUnavailable)
+			return
+		case st.Lag > s.maxLag:
+			http.Error(w, fmt.Sprintf("%d entries behind the primary", st.Lag), http.StatusServiceUnavailable)
+			return
+		}
+	}
+	w.Write([]byte("ok"))
+}
+
+func main() {
+	hostname, _ := os.Hostname()
+	id, err := ParseIdentity(
+		getEnv("POD_NAME", hostname),
+		getEnv("HEADLESS_SERVICE", "kv-headless"),
+		getEnv("POD_NAMESPACE", "default"),
+		getEnv("CLUSTER_DOMAIN", "cluster.local"),
+	)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+	listenPort := getEnv("PORT", "8080")
+	dataDir := getEnv("DATA_DIR", "/data")
+
+	log, err := OpenLog(filepath.Join(dataDir, "kv.log"))
+	if err != nil {
+		// Starting empty would make this member silently diverge: refuse.
+		fmt.Printf("Error opening the log in %s: %s\n", dataDir, err)
+		os.Exit(1)
+	}
+	defer log.Close()
+	logIndex.Set(float64(log.LastIndex()))
+
+	s := &server{
+		id:           id,
+		port:         listenPort,
+		log:          log,
+		syncReplicas: getEnvInt("SYNC_REPLICAS", 0),
+		ackTimeout:   getEnvDuration("ACK_TIMEOUT", 2*time.Second),
+		maxLag:       uint64(getEnvInt("MAX_LAG", 10)),
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	if id.IsPrimary() {
+		isPrimary.Set(1)
+		s.replicas = NewReplicas()
+		fmt.Printf("%s (ordinal %d): primary, log at index %d\n", id.Name, id.Ordinal, log.LastIndex())
+	} else {
+		s.follower = &Follower{
+			Log:        log,
+			PrimaryURL: s.primaryURL(),
+			Name:       id.Name,
+			Client:     &http.Client{Timeout: 15 * time.Second},
+		}
+		fmt.Printf("%s (ordinal %d): replica of %s, log at index %d\n", id.Name, id.Ordinal, s.primaryURL(), log.LastIndex())
+		go s.follower.Run(ctx)
+		go func() {
+			ticker := time.NewTicker(time.Second)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					logIndex.Set(float64(log.LastIndex()))
+				}
+			}
+		}()
+	}
+
+	srv := &http.Server{Addr: ":" + listenPort, Handler: s.routes()}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Printf("Listening on :%s, peers via %s.%s.svc.%s\n", listenPort, id.Service, id.Namespace, id.Domain)
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		fmt.Printf("Error starting server: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("Shutting down...")
+}
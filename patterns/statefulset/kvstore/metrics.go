@@ -0,0 +1,36 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Metrics are registered with the default registry via 'promauto'. Every
+// member exports kv_is_primary and kv_log_index; comparing kv_log_index
+// across the set shows replication at a glance.
+var (
+	isPrimary = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "kv_is_primary",
+		Help: "1 on the primary (ordinal 0), 0 on replicas.",
+	})
+
+	logIndex = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "kv_log_index",
+		Help: "Index of the newest entry in this member's log.",
+	})
+
+	writesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kv_writes_total",
+		Help: "Writes by result (ok, unreplicated, redirected, error).",
+	}, []string{"result"})
+
+	replicaLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kv_replica_lag_entries",
+		Help: "On the primary: entries each replica had yet to copy at its last fetch.",
+	}, []string{"replica"})
+
+	replicationLag = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "kv_replication_lag_entries",
+		Help: "On a replica: entries behind the primary's last known index.",
+	})
+)
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var errDiverged = errors.New("replica log is ahead of the primary's: the logs have diverged")
+
+// Replicas is the primary's view of its replicas: how far each one has
+// copied the log. A replica's fetch from index N acknowledges 1..N-1, so
+// the fetches themselves carry the acks.
+type Replicas struct {
+	mu      sync.Mutex
+	acked   map[string]ReplicaStatus
+	changed chan struct{}
+}
+
+type ReplicaStatus struct {
+	Acked    uint64    `json:"acked"`
+	LastSeen time.Time `json:"lastSeen"`
+}
+
+func NewReplicas() *Replicas {
+	return &Replicas{acked: map[string]ReplicaStatus{}, changed: make(chan struct{})}
+}
+
+func (r *Replicas) Ack(name string, index uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.acked[name] = ReplicaStatus{Acked: index, LastSeen: time.Now()}
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+func (r *Replicas) Status() map[string]ReplicaStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make(map[string]ReplicaStatus, len(r.acked))
+	for name, s := range r.acked {
+		out[name] = s
+	}
+	return out
+}
+
+// WaitAcked blocks until n replicas have acknowledged index, or ctx is
+// done. It returns how many had.
+func (r *Replicas) WaitAcked(ctx context.Context, index uint64, n int) int {
+	for {
+		r.mu.Lock()
+		count := 0
+		for _, s := range r.acked {
+			if s.Acked >= index {
+				count++
+			}
+		}
+		changed := r.changed
+		r.mu.Unlock()
+		if count >= n {
+			return count
+		}
+		select {
+		case <-ctx.Done():
+			return count
+		case <-changed:
+		}
+	}
+}
+
+// replicateHandler serves GET /replicate?from=N&replica=NAME&wait=D on the
+// primary: entries from N on, or, when there are none yet, a long poll
+// that answers as soon as one is written (or after wait, empty).
+func replicateHandler(log *Log, replicas *Replicas, maxBatch int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		from, err := strconv.ParseUint(r.URL.Query().Get("from"), 10, 64)
+		if err != nil || from == 0 {
+			http.Error(w, "from must be a log index >= 1", http.StatusBadRequest)
+			return
+		}
+		wait, err := time.ParseDuration(r.URL.Query().Get("wait"))
+		if err != nil || wait > time.Minute {
+			wait = 10 * time.Second
+		}
+		name := r.URL.Query().Get("replica")
+
+		last := log.LastIndex()
+		w.Header().Set("X-Last-Index", strconv.FormatUint(last, 10))
+		if from > last+1 {
+			// The replica has entries we don't: this primary lost its data
+			// (a new PVC?) and must not be followed blindly.
+			http.Error(w, errDiverged.Error(), http.StatusConflict)
+			return
+		}
+		if name != "" {
+			replicas.Ack(name, from-1)
+			replicaLag.WithLabelValues(name).Set(float64(last - (from - 1)))
+		}
+		if from > last {
+			ctx, cancel := context.WithTimeout(r.Context(), wait)
+			defer cancel()
+			log.Wait(ctx, from)
+		}
+		entries := log.Since(from, maxBatch)
+		w.Header().Set("X-Last-Index", strconv.FormatUint(log.LastIndex(), 10))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(entries)
+	}
+}
+
+// Follower copies the primary's log to a replica, one long poll at a time.
+type Follower struct {
+	Log        *Log
+	PrimaryURL string // http://kv-0.kv-headless.default.svc.cluster.local:8080
+	Name       string
+	Client     *http.Client
+
+	mu          sync.Mutex
+	primaryLast uint64
+	lastContact time.Time
+	lastErr     error
+}
+
+// FollowerStatus is what a replica reports about its replication.
+type FollowerStatus struct {
+	Primary      string    `json:"primary"`
+	PrimaryIndex uint64    `json:"primaryIndex"`
+	Lag          uint64    `json:"lag"`
+	LastContact  time.Time `json:"lastContact"`
+	Error        string    `json:"error,omitempty"`
+}
+
+func (f *Follower) Status() FollowerStatus {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	st := FollowerStatus{Primary: f.PrimaryURL, PrimaryIndex: f.primaryLast, LastContact: f.lastContact}
+	if last := f.Log.LastIndex(); f.primaryLast > last {
+		st.Lag = f.primaryLast - last
+	}
+	if f.lastErr != nil {
+		st.Error = f.lastErr.Error()
+	}
+	return st
+}
+
+// Run follows the primary until ctx is done. Errors are retried with a
+// backoff: the primary may simply not exist yet, since ordinal 0 starts
+// first but a replica can also be restarted while ordinal 0 is down.
+func (f *Follower) Run(ctx context.Context) {
+	backoff := time.Second
+	for ctx.Err() == nil {
+		err := f.fetch(ctx)
+		f.mu.Lock()
+		f.lastErr = err
+		f.mu.Unlock()
+		replicationLag.Set(float64(f.Status().Lag))
+		if err == nil {
+			backoff = time.Second
+			continue
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		fmt.Printf("Replication from %s: %s (retrying in %s)\n", f.PrimaryURL, err, backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, 30*time.Second)
+	}
+}
+
+func (f *Follower) fetch(ctx context.Context) error {
+	from := f.Log.LastIndex() + 1
+	q := url.Values{"from": {strconv.FormatUint(from, 10)}, "replica": {f.Name}, "wait": {"10s"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.PrimaryURL+"/replicate?"+q.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	primaryLast, _ := strconv.ParseUint(resp.Header.Get("X-Last-Index"), 10, 64)
+	f.mu.Lock()
+	f.primaryLast, f.lastContact = primaryLast, time.Now()
+	f.mu.Unlock()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusConflict:
+		return fmt.Errorf("%w (local %d, primary %d)", errDiverged, from-1, primaryLast)
+	default:
+		return fmt.Errorf("primary answered %s", resp.Status)
+	}
+	var entries []Entry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return err
+	}
+	return f.Log.Replicate(entries)
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+var errGap = errors.New("entries do not follow the local log")
+
+// Entry is one write. The log of entries is the source of truth; the map
+// of keys is just the log replayed.
+type Entry struct {
+	Index   uint64    `json:"index"`
+	Key     string    `json:"key"`
+	Value   string    `json:"value,omitempty"`
+	Deleted bool      `json:"deleted,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// Log is an append-only key-value log, persisted as JSON lines on the Pod's
+// own PVC. The primary appends to it; replicas copy the primary's entries
+// in order, so every member holds a prefix of the primary's log.
+type Log struct {
+	mu      sync.Mutex
+	file    *os.File
+	entries []Entry
+	data    map[string]Entry
+	changed chan struct{} // closed and replaced on every append
+}
+
+// OpenLog replays the log file at path, creating it if needed. A last line
+// cut short by a crash mid-write is dropped: it was never acknowledged.
+func OpenLog(path string) (*Log, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	l := &Log{file: f, data: map[string]Entry{}, changed: make(chan struct{})}
+	r := bufio.NewReader(f)
+	var good int64
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				fmt.Printf("Dropping a partial last entry (%d bytes) from %s\n", len(line), path)
+			}
+			break
+		}
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		var e Entry
+		if err := json.Unmarshal(line, &e); err != nil || e.Index != l.last()+1 {
+			f.Close()
+			return nil, fmt.Errorf("%s: corrupt entry after index %d", path, l.last())
+		}
+		l.apply(e)
+		good += int64(len(line))
+	}
+	if err := f.Truncate(good); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(good, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Log) last() uint64 {
+	if len(l.entries) == 0 {
+		return 0
+	}
+	return l.entries[len(l.entries)-1].Index
+}
+
+func (l *Log) apply(e Entry) {
+	l.entries = append(l.entries, e)
+	if e.Deleted {
+		delete(l.data, e.Key)
+	} else {
+		l.data[e.Key] = e
+	}
+}
+
+// write persists entries and only then applies them: an entry a client or
+// replica has seen is always on disk.
+func (l *Log) write(entries []Entry) error {
+	var buf []byte
+	for _, e := range entries {
+		b, _ := json.Marshal(e)
+		buf = append(append(buf, b...), '\n')
+	}
+	if _, err := l.file.Write(buf); err != nil {
+		return err
+	}
+	if err := l.file.Sync(); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		l.apply(e)
+	}
+	close(l.changed)
+	l.changed = make(chan struct{})
+	return nil
+}
+
+// Append adds a write at the next index. Only the primary calls it.
+func (l *Log) Append(key, value string, deleted bool) (Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e := Entry{Index: l.last() + 1, Key: key, Value: value, Deleted: deleted, Time: time.Now().UTC()}
+	return e, l.write([]Entry{e})
+}
+
+// Replicate adds entries copied from the primary. They must continue the
+// local log exactly; anything else means the two logs have diverged.
+func (l *Log) Replicate(entries []Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, e := range entries {
+		if e.Index != l.last()+uint64(i)+1 {
+			return fmt.Errorf("%w: got index %d, local log ends at %d", errGap, e.Index, l.last())
+		}
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	return l.write(entries)
+}
+
+// Since returns up to max entries from index from on.
+func (l *Log) Since(from uint64, max int) []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if from == 0 {
+		from = 1
+	}
+	if from > l.last() {
+		return nil
+	}
+	out := l.entries[from-1:]
+	if len(out) > max {
+		out = out[:max]
+	}
+	return append([]Entry(nil), out...)
+}
+
+// Wait blocks until the log reaches index, or ctx is done. It reports
+// whether the index was reached.
+func (l *Log) Wait(ctx context.Context, index uint64) bool {
+	for {
+		l.mu.Lock()
+		last, changed := l.last(), l.changed
+		l.mu.Unlock()
+		if last >= index {
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-changed:
+		}
+	}
+}
+
+func (l *Log) Get(key string) (Entry, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e, ok := l.data[key]
+	return e, ok
+}
+
+// LastIndex is the index of the newest entry, 0 for an empty log.
+func (l *Log) LastIndex() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.last()
+}
+
+// Keys is the number of live keys.
+func (l *Log) Keys() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.data)
+}
+
+func (l *Log) Close() error { return l.file.Close() }